@@ -30,14 +30,30 @@ type Object struct {
 
 type Expr interface{}
 
+// A Comment node represents a single //-style or /*-style comment.
+type Comment struct {
+	Slash token.Pos // position of "/" starting the comment
+	Text  string    // comment text (excluding '\n' for //-style comments)
+}
+
+// A CommentGroup represents a sequence of comments
+// with no other tokens and no empty lines between.
+type CommentGroup struct {
+	List []*Comment // len(List) > 0
+}
+
 type Field struct {
-	Names  []*Ident
-	Type   Expr
-	Offset int
+	Doc     *CommentGroup // associated documentation; or nil
+	Names   []*Ident
+	Type    Expr
+	Comment *CommentGroup // line comments; or nil
+	Offset  int
 }
 
 type FieldList struct {
-	List []*Field
+	Opening token.Pos // position of opening parenthesis/brace, if any
+	List    []*Field
+	Closing token.Pos // position of closing parenthesis/brace, if any
 }
 
 type Ident struct {
@@ -50,26 +66,33 @@ func (x *Ident) Pos() token.Pos    { return x.NamePos }
 func (x *TypeSpec) Pos() token.Pos { return x.NamePos }
 
 type Ellipsis struct {
-	Elt Expr
+	Ellipsis token.Pos // position of "..."
+	Elt      Expr
 }
 
 type BasicLit struct {
-	Kind  token.Token // token.INT, token.CHAR, or token.STRING
-	Value string
+	ValuePos token.Pos   // literal position
+	Kind     token.Token // token.INT, token.CHAR, or token.STRING
+	Value    string
 }
 
 type CompositeLit struct {
-	Type Expr
-	Elts []Expr
+	Type   Expr
+	Lbrace token.Pos // position of "{"
+	Elts   []Expr
+	Rbrace token.Pos // position of "}"
 }
 
 type KeyValueExpr struct {
 	Key   Expr
+	Colon token.Pos // position of ":"
 	Value Expr
 }
 
 type ParenExpr struct {
-	X Expr
+	Lparen token.Pos // position of "("
+	X      Expr
+	Rparen token.Pos // position of ")"
 }
 
 type SelectorExpr struct {
@@ -78,64 +101,80 @@ type SelectorExpr struct {
 }
 
 type IndexExpr struct {
-	X     Expr
-	Index Expr
+	X      Expr
+	Lbrack token.Pos // position of "["
+	Index  Expr
+	Rbrack token.Pos // position of "]"
 }
 
 type SliceExpr struct {
 	X      Expr
+	Lbrack token.Pos // position of "["
 	Low    Expr
 	High   Expr
 	Max    Expr
 	Slice3 bool
+	Rbrack token.Pos // position of "]"
 }
 
 type CallExpr struct {
-	Fun      Expr   // function expression
-	Args     []Expr // function arguments; or nil
-	Ellipsis token.Pos
+	Fun      Expr      // function expression
+	Lparen   token.Pos // position of "("
+	Args     []Expr    // function arguments; or nil
+	Ellipsis token.Pos // position of "..." (token.NoPos if there is no "...")
+	Rparen   token.Pos // position of ")"
 }
 
 type StarExpr struct {
-	X Expr
+	Star token.Pos // position of "*"
+	X    Expr
 }
 
 type UnaryExpr struct {
-	X  Expr
-	Op token.Token
+	OpPos token.Pos // position of Op
+	X     Expr
+	Op    token.Token
 }
 
 type BinaryExpr struct {
-	X  Expr
-	Y  Expr
-	Op token.Token
+	X     Expr
+	OpPos token.Pos // position of Op
+	Y     Expr
+	Op    token.Token
 }
 
 type TypeAssertExpr struct {
-	X    Expr
-	Type Expr // asserted type; nil means type switch X.(type)
+	X      Expr
+	Lparen token.Pos // position of "("
+	Type   Expr      // asserted type; nil means type switch X.(type)
+	Rparen token.Pos // position of ")"
 }
 
 // Type nodes
 type ArrayType struct {
-	Len Expr
-	Elt Expr
+	Lbrack token.Pos // position of "["
+	Len    Expr
+	Elt    Expr
 }
 
 type StructType struct {
+	Struct token.Pos // position of "struct" keyword
 	Fields *FieldList
 }
 
 type InterfaceType struct {
-	Methods []string
+	Interface token.Pos  // position of "interface" keyword
+	Methods   *FieldList // list of embedded interfaces, methods, or types
 }
 
 type MapType struct {
+	Map   token.Pos // position of "map" keyword
 	Key   Expr
 	Value Expr
 }
 
 type FuncType struct {
+	Func    token.Pos // position of "func" keyword (token.NoPos if there is no "func")
 	Params  *FieldList
 	Results *FieldList
 }
@@ -151,31 +190,38 @@ type ExprStmt struct {
 }
 
 type IncDecStmt struct {
-	X   Expr
-	Tok token.Token
+	X      Expr
+	TokPos token.Pos // position of Tok
+	Tok    token.Token
 }
 
 type AssignStmt struct {
 	Lhs     []Expr
+	TokPos  token.Pos // position of Tok
 	Tok     token.Token
 	Rhs     []Expr
 	IsRange bool
 }
 
 type ReturnStmt struct {
+	Return  token.Pos // position of "return" keyword
 	Results []Expr
 }
 
 type BranchStmt struct {
-	Tok   token.Token
-	Label string
+	TokPos token.Pos // position of Tok
+	Tok    token.Token
+	Label  string
 }
 
 type BlockStmt struct {
-	List []Stmt
+	Lbrace token.Pos // position of "{"
+	List   []Stmt
+	Rbrace token.Pos // position of "}"
 }
 
 type IfStmt struct {
+	If   token.Pos // position of "if" keyword
 	Init Stmt
 	Cond Expr
 	Body *BlockStmt
@@ -183,23 +229,28 @@ type IfStmt struct {
 }
 
 type CaseClause struct {
-	List []Expr
-	Body []Stmt
+	Case  token.Pos // position of "case" or "default" keyword
+	List  []Expr
+	Colon token.Pos // position of ":"
+	Body  []Stmt
 }
 
 type SwitchStmt struct {
-	Init Expr
-	Tag  Expr
-	Body *BlockStmt
+	Switch token.Pos // position of "switch" keyword
+	Init   Expr
+	Tag    Expr
+	Body   *BlockStmt
 	// lableExit string
 }
 
 type TypeSwitchStmt struct {
+	Switch token.Pos // position of "switch" keyword
 	Assign Stmt
 	Body   *BlockStmt
 }
 
 type ForStmt struct {
+	For  token.Pos // position of "for" keyword
 	Init Stmt
 	Cond Expr
 	Post Stmt
@@ -207,19 +258,25 @@ type ForStmt struct {
 }
 
 type RangeStmt struct {
-	Key   Expr
-	Value Expr
-	X     Expr
-	Body  *BlockStmt
-	Tok   token.Token
+	For    token.Pos // position of "for" keyword
+	Key    Expr
+	Value  Expr
+	TokPos token.Pos // position of Tok; invalid if Key == nil
+	X      Expr
+	Body   *BlockStmt
+	Tok    token.Token
 }
 
 type GoStmt struct {
+	Go   token.Pos // position of "go" keyword
 	Call *CallExpr
 }
 
 type ImportSpec struct {
-	Path *BasicLit
+	Doc     *CommentGroup // associated documentation; or nil
+	Path    *BasicLit
+	Comment *CommentGroup // line comments; or nil
+	EndPos  token.Pos     // end of spec (overrides Path.Pos if nonzero)
 }
 
 type ValueSpec struct {
@@ -231,7 +288,7 @@ type ValueSpec struct {
 type TypeSpec struct {
 	Name    *Ident
 	NamePos token.Pos
-	Assign  bool // isAlias
+	Assign  token.Pos // position of '=', if any
 	Type    Expr
 }
 
@@ -243,10 +300,16 @@ type Decl interface {
 type Spec interface{}
 
 type GenDecl struct {
-	Specs []Spec
+	Doc    *CommentGroup // associated documentation; or nil
+	TokPos token.Pos     // position of Tok
+	Tok    token.Token   // IMPORT, CONST, TYPE, or VAR
+	Lparen token.Pos     // position of '(', if any
+	Specs  []Spec
+	Rparen token.Pos // position of ')', if any
 }
 
 type FuncDecl struct {
+	Doc  *CommentGroup // associated documentation; or nil
 	Recv *FieldList
 	Name *Ident
 	Type *FuncType
@@ -259,12 +322,14 @@ func (x *FuncDecl) Pos() token.Pos {
 }
 
 type File struct {
-	Name       *Ident
+	Doc        *CommentGroup // associated documentation; or nil
 	Package    token.Pos
-	Imports    []*ImportSpec
+	Name       *Ident
 	Decls      []Decl
-	Unresolved []*Ident
 	Scope      *Scope
+	Imports    []*ImportSpec
+	Unresolved []*Ident
+	Comments   []*CommentGroup // list of all comments in the source file
 }
 
 type Scope struct {
@@ -0,0 +1,184 @@
+package diff
+
+import "github.com/DQNEO/babygo/lib/strconv"
+
+// number of unchanged lines shown around each change
+const contextLines int = 3
+
+// A line of the edit script
+type op struct {
+	kind byte // ' ' (keep), '-' (delete) or '+' (insert)
+	text string
+}
+
+// Unified returns a unified diff of a and b, or nil if they are equal.
+// aName and bName are used in the file header lines.
+func Unified(aName string, a []byte, bName string, b []byte) []byte {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var changed bool
+	for _, o := range ops {
+		if o.kind != ' ' {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	out := "--- " + aName + "\n" + "+++ " + bName + "\n"
+
+	// aLine[i] and bLine[i] are the 1-based line numbers of ops[i] in a and b
+	var aLine []int
+	var bLine []int
+	an := 1
+	bn := 1
+	for _, o := range ops {
+		aLine = append(aLine, an)
+		bLine = append(bLine, bn)
+		if o.kind != '+' {
+			an++
+		}
+		if o.kind != '-' {
+			bn++
+		}
+	}
+
+	i := 0
+	for i < len(ops) {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// a hunk starts contextLines before the first change ...
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+		// ... and extends until a run of more than 2*contextLines unchanged lines
+		last := i
+		j := i + 1
+		for j < len(ops) && j-last <= 2*contextLines {
+			if ops[j].kind != ' ' {
+				last = j
+			}
+			j++
+		}
+		end := last + 1 + contextLines
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		var aCount int
+		var bCount int
+		var body string
+		for k := start; k < end; k++ {
+			o := ops[k]
+			if o.kind != '+' {
+				aCount++
+			}
+			if o.kind != '-' {
+				bCount++
+			}
+			body = body + string([]byte{o.kind}) + o.text + "\n"
+		}
+		aStart := aLine[start]
+		if aCount == 0 {
+			aStart = aStart - 1
+		}
+		bStart := bLine[start]
+		if bCount == 0 {
+			bStart = bStart - 1
+		}
+		out = out + "@@ -" + hunkRange(aStart, aCount) + " +" + hunkRange(bStart, bCount) + " @@\n" + body
+		i = end
+	}
+
+	return []byte(out)
+}
+
+func hunkRange(start int, count int) string {
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return strconv.Itoa(start) + "," + strconv.Itoa(count)
+}
+
+// splitLines splits text into lines without their trailing newlines.
+func splitLines(text []byte) []string {
+	var lines []string
+	var buf []byte
+	for _, ch := range text {
+		if ch == '\n' {
+			lines = append(lines, string(buf))
+			buf = nil
+		} else {
+			buf = append(buf, ch)
+		}
+	}
+	if len(buf) > 0 {
+		lines = append(lines, string(buf))
+	}
+	return lines
+}
+
+// diffLines computes an edit script turning a into b.
+// Common leading and trailing lines are stripped first so that
+// the quadratic LCS table only covers the region that changed.
+func diffLines(a []string, b []string) []*op {
+	var ops []*op
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, &op{kind: ' ', text: a[i]})
+	}
+
+	x := a[prefix : len(a)-suffix]
+	y := b[prefix : len(b)-suffix]
+	m := len(x)
+	n := len(y)
+
+	// lcs[i*(n+1)+j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([]int, (m+1)*(n+1), (m+1)*(n+1))
+	for i := m - 1; i >= 0; i-- {
+		for j := n - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i*(n+1)+j] = lcs[(i+1)*(n+1)+j+1] + 1
+			} else if lcs[(i+1)*(n+1)+j] >= lcs[i*(n+1)+j+1] {
+				lcs[i*(n+1)+j] = lcs[(i+1)*(n+1)+j]
+			} else {
+				lcs[i*(n+1)+j] = lcs[i*(n+1)+j+1]
+			}
+		}
+	}
+
+	i := 0
+	j := 0
+	for i < m || j < n {
+		if i < m && j < n && x[i] == y[j] {
+			ops = append(ops, &op{kind: ' ', text: x[i]})
+			i++
+			j++
+		} else if j == n || (i < m && lcs[(i+1)*(n+1)+j] >= lcs[i*(n+1)+j+1]) {
+			ops = append(ops, &op{kind: '-', text: x[i]})
+			i++
+		} else {
+			ops = append(ops, &op{kind: '+', text: y[j]})
+			j++
+		}
+	}
+
+	for k := len(a) - suffix; k < len(a); k++ {
+		ops = append(ops, &op{kind: ' ', text: a[k]})
+	}
+	return ops
+}
@@ -0,0 +1,332 @@
+package fmt
+
+import "github.com/DQNEO/babygo/lib/strconv"
+
+const ldigits string = "0123456789abcdefx"
+const udigits string = "0123456789ABCDEFX"
+
+// maxInt is the largest int.
+const maxInt int = 9223372036854775807
+
+const signed bool = true
+const unsigned bool = false
+
+func (p *pp) writeString(s string) {
+	for i := 0; i < len(s); i++ {
+		p.buf = append(p.buf, s[i])
+	}
+}
+
+func (p *pp) writeBytes(b []uint8) {
+	for _, c := range b {
+		p.buf = append(p.buf, c)
+	}
+}
+
+func (p *pp) writeByte(c uint8) {
+	p.buf = append(p.buf, c)
+}
+
+func (p *pp) writeRune(r int) {
+	p.buf = strconv.AppendRune(p.buf, r)
+}
+
+func (p *pp) clearflags() {
+	p.widPresent = false
+	p.precPresent = false
+	p.minus = false
+	p.plus = false
+	p.sharp = false
+	p.space = false
+	p.zero = false
+	p.plusV = false
+	p.sharpV = false
+	p.wid = 0
+	p.prec = 0
+}
+
+// runeCount returns the number of runes in s.
+func runeCount(s string) int {
+	var n int
+	for i := 0; i < len(s); {
+		_, w := strconv.DecodeRune(s, i)
+		i += w
+		n++
+	}
+	return n
+}
+
+// appendDigits appends the digits of u in the given base to buf in reverse order.
+// A negative u is taken as the unsigned number of the same bits. Only non-negative numbers are divided,
+// because the division of babygo is unsigned.
+func appendDigits(buf []uint8, u int, base int, digits string) []uint8 {
+	if u < 0 {
+		// Divide once with the top bit taken apart, which is 2**63 = maxInt + 1.
+		low := u + maxInt + 1
+		t := (maxInt%base+1)%base + low%base
+		buf = append(buf, digits[t%base])
+		u = maxInt/base + (maxInt%base+1)/base + low/base + t/base
+		if u == 0 {
+			return buf
+		}
+	}
+	for {
+		buf = append(buf, digits[u%base])
+		u = u / base
+		if u == 0 {
+			return buf
+		}
+	}
+}
+
+// reverse returns the bytes of buf in reverse order.
+func reverse(buf []uint8) []uint8 {
+	n := len(buf)
+	r := make([]uint8, n, n)
+	for i := 0; i < n; i++ {
+		r[i] = buf[n-1-i]
+	}
+	return r
+}
+
+// writePadding generates n bytes of padding.
+func (p *pp) writePadding(n int) {
+	var padByte uint8 = ' '
+	if p.zero && !p.minus {
+		padByte = '0'
+	}
+	for i := 0; i < n; i++ {
+		p.buf = append(p.buf, padByte)
+	}
+}
+
+// padString appends s to the buffer, padded on left (!minus) or right (minus).
+func (p *pp) padString(s string) {
+	if !p.widPresent || p.wid == 0 {
+		p.writeString(s)
+		return
+	}
+	width := p.wid - runeCount(s)
+	if !p.minus {
+		// left padding
+		p.writePadding(width)
+		p.writeString(s)
+	} else {
+		// right padding
+		p.writeString(s)
+		p.writePadding(width)
+	}
+}
+
+// fmtBoolean formats a boolean.
+func (p *pp) fmtBoolean(v bool) {
+	if v {
+		p.padString("true")
+	} else {
+		p.padString("false")
+	}
+}
+
+// fmtUnicode formats an integer as "U+0078" or with p.sharp set as "U+0078 'x'".
+func (p *pp) fmtUnicode(u int) {
+	r := u
+	prec := 4
+	if p.precPresent && p.prec > 4 {
+		prec = p.prec
+	}
+	buf := appendDigits(nil, u, 16, udigits)
+	for len(buf) < prec {
+		buf = append(buf, '0')
+	}
+	digits := string(reverse(buf))
+	s := "U+" + digits
+	if p.sharp && r <= 1114111 && strconv.IsPrint(r) {
+		s = s + " '" + string(strconv.AppendRune(nil, r)) + "'"
+	}
+	oldZero := p.zero
+	p.zero = false
+	p.padString(s)
+	p.zero = oldZero
+}
+
+// formatInteger formats a signed or unsigned integer in the given base.
+// A negative value of a signed integer is formatted with its sign,
+// that of an unsigned one as the unsigned value of the same bits.
+func (p *pp) formatInteger(u int, base int, isSigned bool, verb int, digits string) {
+	negative := isSigned && u < 0
+
+	prec := 0
+	if p.precPresent {
+		prec = p.prec
+		// Precision of 0 and value of 0 means "print nothing" but padding.
+		if prec == 0 && u == 0 {
+			oldZero := p.zero
+			p.zero = false
+			p.writePadding(p.wid)
+			p.zero = oldZero
+			return
+		}
+	} else if p.zero && !p.minus && p.widPresent { // Zero padding is allowed only to the left.
+		prec = p.wid
+		if negative || p.plus || p.space {
+			prec-- // leave room for sign
+		}
+	}
+
+	// A negative number is formatted by its magnitude, which is the unsigned value of its negation.
+	if negative {
+		u = -u
+	}
+	buf := appendDigits(nil, u, base, digits)
+	for prec > len(buf) {
+		buf = append(buf, '0')
+	}
+
+	// Various prefixes: 0x, etc.
+	if p.sharp {
+		switch base {
+		case 2:
+			// Add a leading 0b.
+			buf = append(buf, 'b')
+			buf = append(buf, '0')
+		case 8:
+			if buf[len(buf)-1] != '0' {
+				buf = append(buf, '0')
+			}
+		case 16:
+			// Add a leading 0x or 0X.
+			buf = append(buf, digits[16])
+			buf = append(buf, '0')
+		}
+	}
+	if verb == 'O' {
+		buf = append(buf, 'o')
+		buf = append(buf, '0')
+	}
+
+	if negative {
+		buf = append(buf, '-')
+	} else if p.plus {
+		buf = append(buf, '+')
+	} else if p.space {
+		buf = append(buf, ' ')
+	}
+
+	// Left padding with zeros has already been handled like precision earlier
+	// or the p.zero flag is ignored due to an explicit precision.
+	oldZero := p.zero
+	p.zero = false
+	p.padString(string(reverse(buf)))
+	p.zero = oldZero
+}
+
+// truncate truncates the string s to the specified precision, if present.
+func (p *pp) truncate(s string) string {
+	if p.precPresent {
+		n := p.prec
+		for i := 0; i < len(s); {
+			n--
+			if n < 0 {
+				return s[0:i]
+			}
+			_, w := strconv.DecodeRune(s, i)
+			i += w
+		}
+	}
+	return s
+}
+
+// fmtS formats a string.
+func (p *pp) fmtS(s string) {
+	s = p.truncate(s)
+	p.padString(s)
+}
+
+// fmtSx formats a string as a hexadecimal encoding of its bytes.
+func (p *pp) fmtSx(s string, digits string) {
+	length := len(s)
+	// Set length to not process more bytes than the precision demands.
+	if p.precPresent && p.prec < length {
+		length = p.prec
+	}
+	// Compute width of the encoding taking into account the p.sharp and p.space flag.
+	width := 2 * length
+	if width > 0 {
+		if p.space {
+			// Each element encoded by two hexadecimals will get a leading 0x or 0X.
+			if p.sharp {
+				width = width * 2
+			}
+			// Elements will be separated by a space.
+			width += length - 1
+		} else if p.sharp {
+			// Only a leading 0x or 0X will be added for the whole string.
+			width += 2
+		}
+	} else { // The byte slice or string that should be encoded is empty.
+		if p.widPresent {
+			p.writePadding(p.wid)
+		}
+		return
+	}
+	// Handle padding to the left.
+	if p.widPresent && p.wid > width && !p.minus {
+		p.writePadding(p.wid - width)
+	}
+	// Write the encoding directly into the output buffer.
+	if p.sharp {
+		// Add leading 0x or 0X.
+		p.writeByte('0')
+		p.writeByte(digits[16])
+	}
+	for i := 0; i < length; i++ {
+		if p.space && i > 0 {
+			// Separate elements with a space.
+			p.writeByte(' ')
+			if p.sharp {
+				// Add leading 0x or 0X for each element.
+				p.writeByte('0')
+				p.writeByte(digits[16])
+			}
+		}
+		c := int(s[i])
+		p.writeByte(digits[c/16])
+		p.writeByte(digits[c%16])
+	}
+	// Handle padding to the right.
+	if p.widPresent && p.wid > width && p.minus {
+		p.writePadding(p.wid - width)
+	}
+}
+
+// fmtQ formats a string as a double-quoted, escaped Go string constant.
+// If p.sharp is set a raw (backquoted) string may be returned instead
+// if the string does not contain any control characters other than tab.
+func (p *pp) fmtQ(s string) {
+	s = p.truncate(s)
+	if p.sharp && strconv.CanBackquote(s) {
+		p.padString("`" + s + "`")
+		return
+	}
+	if p.plus {
+		p.padString(strconv.QuoteToASCII(s))
+	} else {
+		p.padString(strconv.Quote(s))
+	}
+}
+
+// fmtC formats an integer as a Unicode character.
+// If the character is not valid Unicode, it will print '�'.
+func (p *pp) fmtC(c int) {
+	p.padString(string(strconv.AppendRune(nil, c)))
+}
+
+// fmtQc formats an integer as a single-quoted, escaped Go character constant.
+// If the character is not valid Unicode, it will print '�'.
+func (p *pp) fmtQc(c int) {
+	if p.plus {
+		p.padString(strconv.QuoteRuneToASCII(c))
+	} else {
+		p.padString(strconv.QuoteRune(c))
+	}
+}
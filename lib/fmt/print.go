@@ -0,0 +1,882 @@
+// Package fmt implements formatted I/O with functions analogous to C's printf and scanf.
+// The verbs and flags are those of Go's fmt, for the types babygo has:
+// there are no floating-point or complex numbers, and no Formatter or Scanner interfaces.
+package fmt
+
+import (
+	"github.com/DQNEO/babygo/lib/strconv"
+	"io"
+	"os"
+	"reflect"
+)
+
+// Strings for use with buffer.WriteString.
+const commaSpaceString string = ", "
+const nilAngleString string = "<nil>"
+const nilParenString string = "(nil)"
+const nilString string = "nil"
+const mapString string = "map["
+const percentBangString string = "%!"
+const missingString string = "(MISSING)"
+const badIndexString string = "(BADINDEX)"
+const extraString string = "%!(EXTRA "
+const badWidthString string = "%!(BADWIDTH)"
+const badPrecString string = "%!(BADPREC)"
+const noVerbString string = "%!(NOVERB)"
+const invReflectString string = "<invalid reflect.Value>"
+
+// Stringer is implemented by any value that has a String method,
+// which defines the “native” format for that value.
+// The String method is used to print values passed as an operand
+// to any format that accepts a string or to an unformatted printer such as Print.
+type Stringer interface {
+	String() string
+}
+
+// GoStringer is implemented by any value that has a GoString method,
+// which defines the Go syntax for that value.
+// The GoString method is used to print values passed as an operand to a %#v format.
+type GoStringer interface {
+	GoString() string
+}
+
+// pp is used to store a printer's state.
+type pp struct {
+	buf []uint8
+
+	// flags of the verb being formatted
+	widPresent  bool
+	precPresent bool
+	minus       bool
+	plus        bool
+	sharp       bool
+	space       bool
+	zero        bool
+	plusV       bool // %+v
+	sharpV      bool // %#v
+	wid         int  // width
+	prec        int  // precision
+
+	// reordered records whether the format string used argument reordering.
+	reordered bool
+	// goodArgNum records whether the most recent reordering directive was valid.
+	goodArgNum bool
+	// erroring is set when printing an error string to guard against calling handleMethods.
+	erroring bool
+	// wrapErrs is set when the format string may contain a %w verb.
+	wrapErrs bool
+	// wrappedErrs records the targets of the %w verb.
+	wrappedErrs []int
+}
+
+func newPrinter() *pp {
+	return &pp{}
+}
+
+// Fprintf formats according to a format specifier and writes to w.
+// It returns the number of bytes written and any write error encountered.
+func Fprintf(w io.Writer, format string, a ...interface{}) (int, error) {
+	p := newPrinter()
+	p.doPrintf(format, a)
+	n, err := w.Write(p.buf)
+	return n, err
+}
+
+// Printf formats according to a format specifier and writes to standard output.
+// It returns the number of bytes written and any write error encountered.
+func Printf(format string, a ...interface{}) (int, error) {
+	n, err := Fprintf(os.Stdout, format, a...)
+	return n, err
+}
+
+// Sprintf formats according to a format specifier and returns the resulting string.
+func Sprintf(format string, a ...interface{}) string {
+	p := newPrinter()
+	p.doPrintf(format, a)
+	return string(p.buf)
+}
+
+// Fprint formats using the default formats for its operands and writes to w.
+// Spaces are added between operands when neither is a string.
+// It returns the number of bytes written and any write error encountered.
+func Fprint(w io.Writer, a ...interface{}) (int, error) {
+	p := newPrinter()
+	p.doPrint(a)
+	n, err := w.Write(p.buf)
+	return n, err
+}
+
+// Print formats using the default formats for its operands and writes to standard output.
+// Spaces are added between operands when neither is a string.
+// It returns the number of bytes written and any write error encountered.
+func Print(a ...interface{}) (int, error) {
+	n, err := Fprint(os.Stdout, a...)
+	return n, err
+}
+
+// Sprint formats using the default formats for its operands and returns the resulting string.
+// Spaces are added between operands when neither is a string.
+func Sprint(a ...interface{}) string {
+	p := newPrinter()
+	p.doPrint(a)
+	return string(p.buf)
+}
+
+// Fprintln formats using the default formats for its operands and writes to w.
+// Spaces are always added between operands and a newline is appended.
+// It returns the number of bytes written and any write error encountered.
+func Fprintln(w io.Writer, a ...interface{}) (int, error) {
+	p := newPrinter()
+	p.doPrintln(a)
+	n, err := w.Write(p.buf)
+	return n, err
+}
+
+// Println formats using the default formats for its operands and writes to standard output.
+// Spaces are always added between operands and a newline is appended.
+// It returns the number of bytes written and any write error encountered.
+func Println(a ...interface{}) (int, error) {
+	n, err := Fprintln(os.Stdout, a...)
+	return n, err
+}
+
+// Sprintln formats using the default formats for its operands and returns the resulting string.
+// Spaces are always added between operands and a newline is appended.
+func Sprintln(a ...interface{}) string {
+	p := newPrinter()
+	p.doPrintln(a)
+	return string(p.buf)
+}
+
+// getField gets the i'th field of the struct value.
+// If the field itself is a non-nil interface, return a value for
+// the thing inside the interface, not the interface itself.
+func getField(v reflect.Value, i int) reflect.Value {
+	val := v.Field(i)
+	if val.Kind() == reflect.Interface && !val.IsNil() {
+		val = val.Elem()
+	}
+	return val
+}
+
+// tooLarge reports whether the magnitude of the integer is
+// too large to be used as a formatting width or precision.
+func tooLarge(x int) bool {
+	return x > 1000000 || x < -1000000
+}
+
+// parsenum converts ASCII to integer. num is 0 (and isnum is false) if no number present.
+func parsenum(s string, start int, end int) (int, bool, int) {
+	if start >= end {
+		return 0, false, end
+	}
+	var num int
+	var isnum bool
+	newi := start
+	for newi < end && '0' <= s[newi] && s[newi] <= '9' {
+		if tooLarge(num) {
+			return 0, false, end // Overflow; crazy long number most likely.
+		}
+		num = num*10 + int(s[newi]-'0')
+		isnum = true
+		newi++
+	}
+	return num, isnum, newi
+}
+
+func (p *pp) unknownType(v reflect.Value) {
+	if !v.IsValid() {
+		p.writeString(nilAngleString)
+		return
+	}
+	p.writeByte('?')
+	p.writeString(v.Type().String())
+	p.writeByte('?')
+}
+
+func (p *pp) badVerb(arg interface{}, value reflect.Value, verb int) {
+	p.erroring = true
+	p.writeString(percentBangString)
+	p.writeRune(verb)
+	p.writeByte('(')
+	if arg != nil {
+		p.writeString(reflect.TypeOf(arg).String())
+		p.writeByte('=')
+		p.printArg(arg, 'v')
+	} else if value.IsValid() {
+		p.writeString(value.Type().String())
+		p.writeByte('=')
+		p.printValue(value, 'v', 0)
+	} else {
+		p.writeString(nilAngleString)
+	}
+	p.writeByte(')')
+	p.erroring = false
+}
+
+func (p *pp) fmtBool(arg interface{}, value reflect.Value, v bool, verb int) {
+	switch verb {
+	case 't', 'v':
+		p.fmtBoolean(v)
+	default:
+		p.badVerb(arg, value, verb)
+	}
+}
+
+// fmt0x64 formats an integer in hexadecimal and prefixes it with 0x or
+// not, as requested, by temporarily setting the sharp flag.
+func (p *pp) fmt0x64(v int, leading0x bool) {
+	sharp := p.sharp
+	p.sharp = leading0x
+	p.formatInteger(v, 16, unsigned, 'v', ldigits)
+	p.sharp = sharp
+}
+
+// fmtInteger formats a signed or unsigned integer.
+func (p *pp) fmtInteger(arg interface{}, value reflect.Value, v int, isSigned bool, verb int) {
+	switch verb {
+	case 'v':
+		if p.sharpV && !isSigned {
+			p.fmt0x64(v, true)
+		} else {
+			p.formatInteger(v, 10, isSigned, verb, ldigits)
+		}
+	case 'd':
+		p.formatInteger(v, 10, isSigned, verb, ldigits)
+	case 'b':
+		p.formatInteger(v, 2, isSigned, verb, ldigits)
+	case 'o', 'O':
+		p.formatInteger(v, 8, isSigned, verb, ldigits)
+	case 'x':
+		p.formatInteger(v, 16, isSigned, verb, ldigits)
+	case 'X':
+		p.formatInteger(v, 16, isSigned, verb, udigits)
+	case 'c':
+		p.fmtC(v)
+	case 'q':
+		p.fmtQc(v)
+	case 'U':
+		p.fmtUnicode(v)
+	default:
+		p.badVerb(arg, value, verb)
+	}
+}
+
+func (p *pp) fmtString(arg interface{}, value reflect.Value, v string, verb int) {
+	switch verb {
+	case 'v':
+		if p.sharpV {
+			p.fmtQ(v)
+		} else {
+			p.fmtS(v)
+		}
+	case 's':
+		p.fmtS(v)
+	case 'x':
+		p.fmtSx(v, ldigits)
+	case 'X':
+		p.fmtSx(v, udigits)
+	case 'q':
+		p.fmtQ(v)
+	default:
+		p.badVerb(arg, value, verb)
+	}
+}
+
+func (p *pp) fmtBytes(v []uint8, verb int, typeString string) {
+	switch verb {
+	case 'v', 'd':
+		if p.sharpV {
+			p.writeString(typeString)
+			if v == nil {
+				p.writeString(nilParenString)
+				return
+			}
+			p.writeByte('{')
+			for i, c := range v {
+				if i > 0 {
+					p.writeString(commaSpaceString)
+				}
+				p.fmt0x64(int(c), true)
+			}
+			p.writeByte('}')
+		} else {
+			p.writeByte('[')
+			for i, c := range v {
+				if i > 0 {
+					p.writeByte(' ')
+				}
+				p.formatInteger(int(c), 10, unsigned, verb, ldigits)
+			}
+			p.writeByte(']')
+		}
+	case 's':
+		p.fmtS(string(v))
+	case 'x':
+		p.fmtSx(string(v), ldigits)
+	case 'X':
+		p.fmtSx(string(v), udigits)
+	case 'q':
+		p.fmtQ(string(v))
+	default:
+		p.printValue(reflect.ValueOf(v), verb, 0)
+	}
+}
+
+func (p *pp) fmtPointer(arg interface{}, value reflect.Value, verb int) {
+	var u int
+	switch value.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Map, reflect.Pointer, reflect.Slice, reflect.UnsafePointer:
+		u = int(value.Pointer())
+	default:
+		p.badVerb(arg, value, verb)
+		return
+	}
+
+	switch verb {
+	case 'v':
+		if p.sharpV {
+			p.writeByte('(')
+			p.writeString(value.Type().String())
+			p.writeString(")(")
+			if u == 0 {
+				p.writeString(nilString)
+			} else {
+				p.fmt0x64(u, true)
+			}
+			p.writeByte(')')
+		} else {
+			if u == 0 {
+				p.padString(nilAngleString)
+			} else {
+				p.fmt0x64(u, !p.sharp)
+			}
+		}
+	case 'p':
+		p.fmt0x64(u, !p.sharp)
+	case 'b', 'o', 'd', 'x', 'X':
+		p.fmtInteger(arg, value, u, unsigned, verb)
+	default:
+		p.badVerb(arg, value, verb)
+	}
+}
+
+func (p *pp) handleMethods(arg interface{}, value reflect.Value, verb int) bool {
+	if p.erroring {
+		return false
+	}
+	if verb == 'w' {
+		// It is invalid to use %w other than with Errorf or with a non-error arg.
+		_, ok := arg.(error)
+		if !ok || !p.wrapErrs {
+			p.badVerb(arg, value, verb)
+			return true
+		}
+		verb = 'v'
+	}
+
+	// If we're doing Go syntax and the argument knows how to supply it, take care of it now.
+	if p.sharpV {
+		stringer, ok := arg.(GoStringer)
+		if ok {
+			// Print the result of GoString unadorned.
+			p.fmtS(stringer.GoString())
+			return true
+		}
+		return false
+	}
+	// If a string is acceptable according to the format, see if
+	// the value satisfies one of the string-valued interfaces.
+	// Println etc. set verb to %v, which is "stringable".
+	switch verb {
+	case 'v', 's', 'x', 'X', 'q':
+		// Is it an error or Stringer?
+		switch v := arg.(type) {
+		case error:
+			p.fmtString(arg, value, v.Error(), verb)
+			return true
+		case Stringer:
+			p.fmtString(arg, value, v.String(), verb)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *pp) printArg(arg interface{}, verb int) {
+	var noValue reflect.Value // the values printed without reflection have none
+	if arg == nil {
+		switch verb {
+		case 'T', 'v':
+			p.padString(nilAngleString)
+		default:
+			p.badVerb(arg, noValue, verb)
+		}
+		return
+	}
+
+	// Special processing considerations.
+	// %T (the value's type) and %p (its address) are special; we always do them first.
+	switch verb {
+	case 'T':
+		p.fmtS(reflect.TypeOf(arg).String())
+		return
+	case 'p':
+		p.fmtPointer(arg, reflect.ValueOf(arg), 'p')
+		return
+	}
+
+	// Some types can be done without reflection.
+	switch f := arg.(type) {
+	case bool:
+		p.fmtBool(arg, noValue, f, verb)
+	case int:
+		p.fmtInteger(arg, noValue, f, signed, verb)
+	case uint8:
+		p.fmtInteger(arg, noValue, int(f), unsigned, verb)
+	case uint16:
+		p.fmtInteger(arg, noValue, int(f), unsigned, verb)
+	case uintptr:
+		p.fmtInteger(arg, noValue, int(f), unsigned, verb)
+	case string:
+		p.fmtString(arg, noValue, f, verb)
+	case []uint8:
+		p.fmtBytes(f, verb, "[]byte")
+	default:
+		// If the type is not simple, it might have methods.
+		if !p.handleMethods(arg, noValue, verb) {
+			// Need to use reflection, since the type had no
+			// interface methods that could be used for formatting.
+			p.printValue(reflect.ValueOf(arg), verb, 0)
+		}
+	}
+}
+
+// printValue is similar to printArg but starts with a reflect value, not an interface{} value.
+// It does not handle 'p' and 'T' verbs because these should have been already handled by printArg.
+func (p *pp) printValue(value reflect.Value, verb int, depth int) {
+	// Handle values with special methods if not already handled by printArg (depth == 0).
+	if depth > 0 && value.IsValid() && value.CanInterface() {
+		arg := value.Interface()
+		if p.handleMethods(arg, value, verb) {
+			return
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Invalid:
+		if depth == 0 {
+			p.writeString(invReflectString)
+		} else {
+			switch verb {
+			case 'v':
+				p.writeString(nilAngleString)
+			default:
+				p.badVerb(nil, value, verb)
+			}
+		}
+	case reflect.Bool:
+		p.fmtBool(nil, value, value.Bool(), verb)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		p.fmtInteger(nil, value, int(value.Int()), signed, verb)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		p.fmtInteger(nil, value, int(value.Uint()), unsigned, verb)
+	case reflect.String:
+		p.fmtString(nil, value, value.String(), verb)
+	case reflect.Map:
+		if p.sharpV {
+			p.writeString(value.Type().String())
+			if value.IsNil() {
+				p.writeString(nilParenString)
+				return
+			}
+			p.writeByte('{')
+		} else {
+			p.writeString(mapString)
+		}
+		keys, values := sortedMap(value)
+		for i := 0; i < len(keys); i++ {
+			if i > 0 {
+				if p.sharpV {
+					p.writeString(commaSpaceString)
+				} else {
+					p.writeByte(' ')
+				}
+			}
+			p.printValue(keys[i], verb, depth+1)
+			p.writeByte(':')
+			p.printValue(values[i], verb, depth+1)
+		}
+		if p.sharpV {
+			p.writeByte('}')
+		} else {
+			p.writeByte(']')
+		}
+	case reflect.Struct:
+		if p.sharpV {
+			p.writeString(value.Type().String())
+		}
+		p.writeByte('{')
+		t := value.Type()
+		for i := 0; i < value.NumField(); i++ {
+			if i > 0 {
+				if p.sharpV {
+					p.writeString(commaSpaceString)
+				} else {
+					p.writeByte(' ')
+				}
+			}
+			if p.plusV || p.sharpV {
+				field := t.Field(i)
+				if field.Name != "" {
+					p.writeString(field.Name)
+					p.writeByte(':')
+				}
+			}
+			p.printValue(getField(value, i), verb, depth+1)
+		}
+		p.writeByte('}')
+	case reflect.Interface:
+		elem := value.Elem()
+		if !elem.IsValid() {
+			if p.sharpV {
+				p.writeString(value.Type().String())
+				p.writeString(nilParenString)
+			} else {
+				p.writeString(nilAngleString)
+			}
+		} else {
+			p.printValue(elem, verb, depth+1)
+		}
+	case reflect.Array, reflect.Slice:
+		p.printList(value, verb, depth)
+	case reflect.Pointer:
+		// pointer to array or slice or struct? ok at top level
+		// but not embedded (avoid loops)
+		if depth == 0 && value.Pointer() != 0 {
+			elem := value.Elem()
+			switch elem.Kind() {
+			case reflect.Array, reflect.Slice, reflect.Struct, reflect.Map:
+				p.writeByte('&')
+				p.printValue(elem, verb, depth+1)
+				return
+			}
+		}
+		p.fmtPointer(nil, value, verb)
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		p.fmtPointer(nil, value, verb)
+	default:
+		p.unknownType(value)
+	}
+}
+
+// printList prints an array or a slice.
+func (p *pp) printList(value reflect.Value, verb int, depth int) {
+	switch verb {
+	case 's', 'q', 'x', 'X':
+		// Handle byte and uint8 slices and arrays special for the above verbs.
+		t := value.Type()
+		if t.Elem().Kind() == reflect.Uint8 {
+			n := value.Len()
+			bytes := make([]uint8, n, n)
+			for i := 0; i < n; i++ {
+				bytes[i] = uint8(value.Index(i).Uint())
+			}
+			p.fmtBytes(bytes, verb, t.String())
+			return
+		}
+	}
+	if p.sharpV {
+		p.writeString(value.Type().String())
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			p.writeString(nilParenString)
+			return
+		}
+		p.writeByte('{')
+		for i := 0; i < value.Len(); i++ {
+			if i > 0 {
+				p.writeString(commaSpaceString)
+			}
+			p.printValue(value.Index(i), verb, depth+1)
+		}
+		p.writeByte('}')
+	} else {
+		p.writeByte('[')
+		for i := 0; i < value.Len(); i++ {
+			if i > 0 {
+				p.writeByte(' ')
+			}
+			p.printValue(value.Index(i), verb, depth+1)
+		}
+		p.writeByte(']')
+	}
+}
+
+// intFromArg gets the argNumth element of a. On return, isInt reports whether the argument has integer type.
+func intFromArg(a []interface{}, argNum int) (int, bool, int) {
+	var num int
+	var isInt bool
+	newArgNum := argNum
+	if argNum < len(a) {
+		n, ok := a[argNum].(int) // Almost always OK.
+		if ok {
+			num = n
+			isInt = true
+		} else {
+			// Work harder.
+			v := reflect.ValueOf(a[argNum])
+			switch v.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				num = int(v.Int())
+				isInt = true
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+				num = int(v.Uint())
+				isInt = num >= 0
+			}
+		}
+		newArgNum = argNum + 1
+		if tooLarge(num) {
+			num = 0
+			isInt = false // Argument too large.
+		}
+	}
+	return num, isInt, newArgNum
+}
+
+// parseArgNumber returns the value of the bracketed number, minus 1
+// (explicit argument numbers are one-indexed but we want zero-indexed).
+// The opening bracket is known to be present at format[0].
+// The returned values are the index, the number of bytes to consume
+// up to the closing paren, if present, and whether the number parsed
+// ok. The bytes to consume will be 1 if no closing paren is present.
+func parseArgNumber(format string) (int, int, bool) {
+	// There must be at least 3 bytes: [n].
+	if len(format) < 3 {
+		return 0, 1, false
+	}
+
+	// Find closing bracket.
+	for i := 1; i < len(format); i++ {
+		if format[i] == ']' {
+			width, ok, newi := parsenum(format, 1, i)
+			if !ok || newi != i {
+				return 0, i + 1, false
+			}
+			return width - 1, i + 1, true // arg numbers are one-indexed and skip paren.
+		}
+	}
+	return 0, 1, false
+}
+
+// argNumber returns the next argument to evaluate, which is either the value of the passed-in
+// argNum or the value of the bracketed integer that begins format[i:]. It also returns
+// the new value of i, that is, the index of the next byte of the format to process.
+func (p *pp) argNumber(argNum int, format string, i int, numArgs int) (int, int, bool) {
+	if len(format) <= i || format[i] != '[' {
+		return argNum, i, false
+	}
+	p.reordered = true
+	index, wid, ok := parseArgNumber(format[i:])
+	if ok && 0 <= index && index < numArgs {
+		return index, i + wid, true
+	}
+	p.goodArgNum = false
+	return argNum, i + wid, ok
+}
+
+func (p *pp) badArgNum(verb int) {
+	p.writeString(percentBangString)
+	p.writeRune(verb)
+	p.writeString(badIndexString)
+}
+
+func (p *pp) missingArg(verb int) {
+	p.writeString(percentBangString)
+	p.writeRune(verb)
+	p.writeString(missingString)
+}
+
+// setFlag sets the flag c and reports whether c is a flag.
+func (p *pp) setFlag(c uint8) bool {
+	switch c {
+	case '#':
+		p.sharp = true
+	case '0':
+		p.zero = true
+	case '+':
+		p.plus = true
+	case '-':
+		p.minus = true
+	case ' ':
+		p.space = true
+	default:
+		return false
+	}
+	return true
+}
+
+func (p *pp) doPrintf(format string, a []interface{}) {
+	end := len(format)
+	argNum := 0         // we process one argument per non-trivial format
+	afterIndex := false // previous item in format was an index like [3].
+	p.reordered = false
+	for i := 0; i < end; {
+		p.goodArgNum = true
+		lasti := i
+		for i < end && format[i] != '%' {
+			i++
+		}
+		if i > lasti {
+			p.writeString(format[lasti:i])
+		}
+		if i >= end {
+			// done processing format string
+			break
+		}
+
+		// Process one verb
+		i++
+
+		// Do we have flags?
+		p.clearflags()
+		for i < end && p.setFlag(format[i]) {
+			i++
+		}
+
+		// Do we have an explicit argument index?
+		argNum, i, afterIndex = p.argNumber(argNum, format, i, len(a))
+
+		// Do we have width?
+		if i < end && format[i] == '*' {
+			i++
+			p.wid, p.widPresent, argNum = intFromArg(a, argNum)
+
+			if !p.widPresent {
+				p.writeString(badWidthString)
+			}
+
+			// We have a negative width, so take its value and ensure
+			// that the minus flag is set
+			if p.wid < 0 {
+				p.wid = -p.wid
+				p.minus = true
+				p.zero = false // Do not pad with zeros to the right.
+			}
+			afterIndex = false
+		} else {
+			p.wid, p.widPresent, i = parsenum(format, i, end)
+			if afterIndex && p.widPresent { // "%[3]2d"
+				p.goodArgNum = false
+			}
+		}
+
+		// Do we have precision?
+		if i+1 < end && format[i] == '.' {
+			i++
+			if afterIndex { // "%[3].2d"
+				p.goodArgNum = false
+			}
+			argNum, i, afterIndex = p.argNumber(argNum, format, i, len(a))
+			if i < end && format[i] == '*' {
+				i++
+				p.prec, p.precPresent, argNum = intFromArg(a, argNum)
+				// Negative precision arguments don't make sense
+				if p.prec < 0 {
+					p.prec = 0
+					p.precPresent = false
+				}
+				if !p.precPresent {
+					p.writeString(badPrecString)
+				}
+				afterIndex = false
+			} else {
+				p.prec, p.precPresent, i = parsenum(format, i, end)
+				if !p.precPresent {
+					p.prec = 0
+					p.precPresent = true
+				}
+			}
+		}
+
+		if !afterIndex {
+			argNum, i, afterIndex = p.argNumber(argNum, format, i, len(a))
+		}
+
+		if i >= end {
+			p.writeString(noVerbString)
+			break
+		}
+
+		verb, size := strconv.DecodeRune(format, i)
+		i += size
+
+		if verb == '%' { // Percent does not absorb operands and ignores f.wid and f.prec.
+			p.writeByte('%')
+		} else if !p.goodArgNum {
+			p.badArgNum(verb)
+		} else if argNum >= len(a) { // No argument left over to print for the current verb.
+			p.missingArg(verb)
+		} else {
+			if verb == 'w' {
+				p.wrappedErrs = append(p.wrappedErrs, argNum)
+			}
+			if verb == 'v' || verb == 'w' {
+				// Go syntax
+				p.sharpV = p.sharp
+				p.sharp = false
+				// Struct-field syntax
+				p.plusV = p.plus
+				p.plus = false
+			}
+			p.printArg(a[argNum], verb)
+			argNum++
+		}
+	}
+
+	// Check for extra arguments unless the call accessed the arguments
+	// out of order, in which case it's too expensive to detect if they've all
+	// been used and arguably OK if they're not.
+	if !p.reordered && argNum < len(a) {
+		p.clearflags()
+		p.writeString(extraString)
+		for i := argNum; i < len(a); i++ {
+			arg := a[i]
+			if i > argNum {
+				p.writeString(commaSpaceString)
+			}
+			if arg == nil {
+				p.writeString(nilAngleString)
+			} else {
+				p.writeString(reflect.TypeOf(arg).String())
+				p.writeByte('=')
+				p.printArg(arg, 'v')
+			}
+		}
+		p.writeByte(')')
+	}
+}
+
+func (p *pp) doPrint(a []interface{}) {
+	prevString := false
+	for argNum, arg := range a {
+		isString := arg != nil && reflect.TypeOf(arg).Kind() == reflect.String
+		// Add a space between two non-string arguments.
+		if argNum > 0 && !isString && !prevString {
+			p.writeByte(' ')
+		}
+		p.printArg(arg, 'v')
+		prevString = isString
+	}
+}
+
+// doPrintln is like doPrint but always adds a space between arguments
+// and a newline after the last argument.
+func (p *pp) doPrintln(a []interface{}) {
+	for argNum, arg := range a {
+		if argNum > 0 {
+			p.writeByte(' ')
+		}
+		p.printArg(arg, 'v')
+	}
+	p.writeByte('\n')
+}
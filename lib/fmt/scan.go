@@ -0,0 +1,829 @@
+package fmt
+
+import (
+	"errors"
+	"github.com/DQNEO/babygo/lib/strconv"
+	"io"
+	"reflect"
+)
+
+// Sscan scans the argument string, storing successive space-separated
+// values into successive arguments. Newlines count as space. It
+// returns the number of items successfully scanned. If that is less
+// than the number of arguments, err will report why.
+//
+// The arguments must be pointers to bool, int, uint8, uint16, uintptr, string or []byte.
+func Sscan(str string, a ...interface{}) (int, error) {
+	s := newScanState(str, true, false)
+	n, err := s.doScan(a)
+	return n, err
+}
+
+// Sscanln is similar to Sscan, but stops scanning at a newline and
+// after the final item there must be a newline or EOF.
+func Sscanln(str string, a ...interface{}) (int, error) {
+	s := newScanState(str, false, true)
+	n, err := s.doScan(a)
+	return n, err
+}
+
+// Sscanf scans the argument string, storing successive space-separated
+// values into successive arguments as determined by the format. It
+// returns the number of items successfully parsed.
+// Newlines in the input must match newlines in the format.
+func Sscanf(str string, format string, a ...interface{}) (int, error) {
+	s := newScanState(str, false, false)
+	n, err := s.doScanf(format, a)
+	return n, err
+}
+
+// eof is returned by getRune at the end of the input.
+const eof int = -1
+
+// hugeWid is the width of a verb that has none.
+const hugeWid int = 1073741824
+
+// ss is the scanner state. Instead of panicking like Go's scanner,
+// it records the first error in err, after which it reads nothing more.
+type ss struct {
+	str       string  // the input
+	i         int     // index of the next byte of the input
+	prev      int     // index of the last rune read, for unreadRune
+	count     int     // runes consumed so far.
+	atEOF     bool    // already read EOF
+	nlIsEnd   bool    // whether newline terminates scan
+	nlIsSpace bool    // whether newline counts as white space
+	argLimit  int     // max value of ss.count for this arg; argLimit <= limit
+	limit     int     // max value of ss.count.
+	maxWid    int     // width of this arg.
+	buf       []uint8 // token accumulator
+	err       error
+}
+
+func newScanState(str string, nlIsSpace bool, nlIsEnd bool) *ss {
+	return &ss{
+		str:       str,
+		nlIsSpace: nlIsSpace,
+		nlIsEnd:   nlIsEnd,
+		argLimit:  hugeWid,
+		limit:     hugeWid,
+		maxWid:    hugeWid,
+	}
+}
+
+// getRune returns the next rune of the input, or eof at the end of the input,
+// at the width limit of the argument or after an error.
+func (s *ss) getRune() int {
+	if s.err != nil || s.atEOF || s.count >= s.argLimit {
+		return eof
+	}
+	if s.i >= len(s.str) {
+		s.atEOF = true
+		return eof
+	}
+	r, size := strconv.DecodeRune(s.str, s.i)
+	s.prev = s.i
+	s.i += size
+	s.count++
+	if s.nlIsEnd && r == '\n' {
+		s.atEOF = true
+	}
+	return r
+}
+
+// mustReadRune turns EOF into an io.ErrUnexpectedEOF error.
+// It is called in cases such as string scanning where an EOF is a
+// syntax error.
+func (s *ss) mustReadRune() int {
+	r := s.getRune()
+	if r == eof {
+		s.error(io.ErrUnexpectedEOF)
+	}
+	return r
+}
+
+// unreadRune unreads the last rune read by getRune.
+func (s *ss) unreadRune() {
+	s.i = s.prev
+	s.atEOF = false
+	s.count--
+}
+
+func (s *ss) error(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *ss) errorString(err string) {
+	s.error(errors.New(err))
+}
+
+// isSpace reports whether r is a space character, as defined by Unicode.
+func isSpace(r int) bool {
+	if r >= 9 && r <= 13 || r == ' ' || r == 133 || r == 160 || r == 5760 {
+		return true
+	}
+	if r >= 8192 && r <= 8202 {
+		return true
+	}
+	return r == 8232 || r == 8233 || r == 8239 || r == 8287 || r == 12288
+}
+
+// skipSpace skips space and newline characters in keeping with the current
+// scanning mode set by format strings and Sscan/Sscanln.
+func (s *ss) skipSpace() {
+	for {
+		r := s.getRune()
+		if r == eof {
+			return
+		}
+		if r == '\r' && s.peek("\n") {
+			continue
+		}
+		if r == '\n' {
+			if s.nlIsSpace {
+				continue
+			}
+			s.errorString("unexpected newline")
+			return
+		}
+		if !isSpace(r) {
+			s.unreadRune()
+			break
+		}
+	}
+}
+
+// token returns the next space-delimited string from the input. It
+// skips white space. For Sscanln, it stops at newlines. For Sscan,
+// newlines are treated as spaces.
+func (s *ss) token() []uint8 {
+	s.skipSpace()
+	// read until white space or newline
+	for {
+		r := s.getRune()
+		if r == eof {
+			break
+		}
+		if isSpace(r) {
+			s.unreadRune()
+			break
+		}
+		s.buf = strconv.AppendRune(s.buf, r)
+	}
+	return s.buf
+}
+
+var errBool error = errors.New("syntax error scanning boolean")
+
+func indexRune(s string, r int) int {
+	for i := 0; i < len(s); i++ {
+		if int(s[i]) == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// consume reads the next rune in the input and reports whether it is in the ok string.
+// If accept is true, it puts the character into the input token.
+func (s *ss) consume(ok string, accept bool) bool {
+	r := s.getRune()
+	if r == eof {
+		return false
+	}
+	if indexRune(ok, r) >= 0 {
+		if accept {
+			s.buf = strconv.AppendRune(s.buf, r)
+		}
+		return true
+	}
+	if accept {
+		s.unreadRune()
+	}
+	return false
+}
+
+// peek reports whether the next character is in the ok string, without consuming it.
+func (s *ss) peek(ok string) bool {
+	r := s.getRune()
+	if r != eof {
+		s.unreadRune()
+	}
+	return indexRune(ok, r) >= 0
+}
+
+// notEOF guarantees there is data to be read, or sets the error to io.EOF.
+func (s *ss) notEOF() {
+	r := s.getRune()
+	if r == eof {
+		s.error(io.EOF)
+		return
+	}
+	s.unreadRune()
+}
+
+// accept checks the next rune in the input. If it's a byte (sic) in the string, it puts it in the
+// buffer and returns true. Otherwise it return false.
+func (s *ss) accept(ok string) bool {
+	return s.consume(ok, true)
+}
+
+// okVerb verifies that the verb is present in the list, setting s.err appropriately if not.
+func (s *ss) okVerb(verb int, okVerbs string, typ string) bool {
+	if indexRune(okVerbs, verb) >= 0 {
+		return true
+	}
+	s.errorString("bad verb '%" + string(strconv.AppendRune(nil, verb)) + "' for " + typ)
+	return false
+}
+
+// scanBool returns the value of the boolean represented by the next token.
+func (s *ss) scanBool(verb int) bool {
+	s.skipSpace()
+	s.notEOF()
+	if !s.okVerb(verb, "tv", "boolean") {
+		return false
+	}
+	// Syntax-checking a boolean is annoying. We're not fastidious about case.
+	switch s.getRune() {
+	case '0':
+		return false
+	case '1':
+		return true
+	case 't', 'T':
+		if s.accept("rR") && (!s.accept("uU") || !s.accept("eE")) {
+			s.error(errBool)
+		}
+		return true
+	case 'f', 'F':
+		if s.accept("aA") && (!s.accept("lL") || !s.accept("sS") || !s.accept("eE")) {
+			s.error(errBool)
+		}
+		return false
+	}
+	return false
+}
+
+// Numerical elements
+const binaryDigits string = "01"
+const octalDigits string = "01234567"
+const decimalDigits string = "0123456789"
+const hexadecimalDigits string = "0123456789aAbBcCdDeEfF"
+const sign string = "+-"
+
+// getBase returns the numeric base represented by the verb and its digit string.
+func (s *ss) getBase(verb int) (int, string) {
+	s.okVerb(verb, "bdoUxXv", "integer") // sets s.err
+	switch verb {
+	case 'b':
+		return 2, binaryDigits
+	case 'o':
+		return 8, octalDigits
+	case 'x', 'X', 'U':
+		return 16, hexadecimalDigits
+	}
+	return 10, decimalDigits
+}
+
+// scanNumber returns the numerical string with specified digits starting here.
+func (s *ss) scanNumber(digits string, haveDigits bool) string {
+	if !haveDigits {
+		s.notEOF()
+		if !s.accept(digits) {
+			s.errorString("expected integer")
+		}
+	}
+	for s.accept(digits) {
+	}
+	return string(s.buf)
+}
+
+// scanRune returns the next rune value in the input.
+func (s *ss) scanRune() int {
+	s.notEOF()
+	return s.getRune()
+}
+
+// scanBasePrefix reports whether the integer begins with a base prefix
+// and returns the base, digit string, and whether a zero was found.
+// It is called only if the verb is %v.
+func (s *ss) scanBasePrefix() (int, string, bool) {
+	if !s.peek("0") {
+		return 0, decimalDigits + "_", false
+	}
+	s.accept("0")
+	// Special cases for 0, 0b, 0o, 0x.
+	if s.peek("bB") {
+		s.consume("bB", true)
+		return 0, binaryDigits + "_", true
+	}
+	if s.peek("oO") {
+		s.consume("oO", true)
+		return 0, octalDigits + "_", true
+	}
+	if s.peek("xX") {
+		s.consume("xX", true)
+		return 0, hexadecimalDigits + "_", true
+	}
+	return 0, octalDigits + "_", true
+}
+
+// scanInt returns the value of the integer represented by the next
+// token, checking for overflow. Any error is stored in s.err.
+func (s *ss) scanInt(verb int) int {
+	if verb == 'c' {
+		return s.scanRune()
+	}
+	s.skipSpace()
+	s.notEOF()
+	base, digits := s.getBase(verb)
+	haveDigits := false
+	if verb == 'U' {
+		if !s.consume("U", false) || !s.consume("+", false) {
+			s.errorString("bad unicode format ")
+		}
+	} else {
+		s.accept(sign) // If there's a sign, it will be left in the token buffer.
+		if verb == 'v' {
+			base, digits, haveDigits = s.scanBasePrefix()
+		}
+	}
+	tok := s.scanNumber(digits, haveDigits)
+	if s.err != nil {
+		return 0
+	}
+	i, err := parseInt(tok, base, "ParseInt")
+	if err != nil {
+		s.error(err)
+	}
+	return i
+}
+
+// scanUint returns the value of the unsigned integer represented
+// by the next token, checking for overflow against max. Any error is stored in s.err.
+func (s *ss) scanUint(verb int, max int) int {
+	if verb == 'c' {
+		return s.scanRune()
+	}
+	s.skipSpace()
+	s.notEOF()
+	base, digits := s.getBase(verb)
+	haveDigits := false
+	if verb == 'U' {
+		if !s.consume("U", false) || !s.consume("+", false) {
+			s.errorString("bad unicode format ")
+		}
+	} else if verb == 'v' {
+		base, digits, haveDigits = s.scanBasePrefix()
+	}
+	tok := s.scanNumber(digits, haveDigits)
+	if s.err != nil {
+		return 0
+	}
+	i, err := parseInt(tok, base, "ParseUint")
+	if err != nil {
+		s.error(err)
+		return 0
+	}
+	if i > max {
+		s.errorString("unsigned integer overflow on token " + tok)
+	}
+	return i
+}
+
+// parseInt interprets the token tok in the given base, or, for base 0, in the base implied by its prefix,
+// and reports errors the way strconv's function fn does.
+func parseInt(tok string, base int, fn string) (int, error) {
+	s := tok
+	neg := false
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	underscores := false
+	prefix := false
+	if base == 0 {
+		base = 10
+		if len(s) > 1 && s[0] == '0' {
+			switch s[1] {
+			case 'b', 'B':
+				base = 2
+				s = s[2:]
+			case 'o', 'O':
+				base = 8
+				s = s[2:]
+			case 'x', 'X':
+				base = 16
+				s = s[2:]
+			default:
+				base = 8
+				s = s[1:]
+			}
+			prefix = true
+		}
+		underscores = true
+	}
+	if len(s) == 0 {
+		return 0, errors.New("strconv." + fn + ": parsing " + strconv.Quote(tok) + ": invalid syntax")
+	}
+	var n int
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && underscores {
+			// an underscore must separate digits, or the base prefix and a digit
+			if i+1 >= len(s) || s[i+1] == '_' || i == 0 && !prefix {
+				return 0, errors.New("strconv." + fn + ": parsing " + strconv.Quote(tok) + ": invalid syntax")
+			}
+			continue
+		}
+		d := indexRune(hexadecimalDigits, int(c))
+		if d < 0 {
+			return 0, errors.New("strconv." + fn + ": parsing " + strconv.Quote(tok) + ": invalid syntax")
+		}
+		if d >= 10 {
+			d = 10 + (d-10)/2
+		}
+		if d >= base {
+			return 0, errors.New("strconv." + fn + ": parsing " + strconv.Quote(tok) + ": invalid syntax")
+		}
+		// Accumulate negatively, as the magnitude of the most negative int is one more than the largest int.
+		// -n is negative only for the most negative int.
+		if -n < 0 || -n > (maxInt-d)/base+((maxInt-d)%base+1)/base {
+			return 0, errors.New("strconv." + fn + ": parsing " + strconv.Quote(tok) + ": value out of range")
+		}
+		n = n*base - d
+	}
+	if !neg {
+		if -n < 0 {
+			return 0, errors.New("strconv." + fn + ": parsing " + strconv.Quote(tok) + ": value out of range")
+		}
+		n = -n
+	}
+	return n, nil
+}
+
+// convertString returns the string represented by the next input characters.
+// The format of the input is determined by the verb.
+func (s *ss) convertString(verb int) string {
+	if !s.okVerb(verb, "svqxX", "string") {
+		return ""
+	}
+	s.skipSpace()
+	s.notEOF()
+	switch verb {
+	case 'q':
+		return s.quotedString()
+	case 'x', 'X':
+		return s.hexString()
+	}
+	return string(s.token()) // %s and %v just return the next word
+}
+
+// quotedString returns the double- or back-quoted string represented by the next input characters.
+func (s *ss) quotedString() string {
+	s.notEOF()
+	quote := s.getRune()
+	switch quote {
+	case '`':
+		// Back-quoted: Anything goes until EOF or back quote.
+		for {
+			r := s.mustReadRune()
+			if r == quote || r == eof {
+				break
+			}
+			s.buf = strconv.AppendRune(s.buf, r)
+		}
+		return string(s.buf)
+	case '"':
+		// Double-quoted: Include the quotes and let strconv.Unquote do the backslash escapes.
+		s.buf = append(s.buf, '"')
+		for {
+			r := s.mustReadRune()
+			if r == eof {
+				return ""
+			}
+			s.buf = strconv.AppendRune(s.buf, r)
+			if r == '\\' {
+				// In a legal backslash escape, no matter how long, only the character
+				// immediately after the escape can itself be a backslash or quote.
+				// Thus we only need to protect the first character after the backslash.
+				s.buf = strconv.AppendRune(s.buf, s.mustReadRune())
+			} else if r == '"' {
+				break
+			}
+		}
+		result, err := strconv.Unquote(string(s.buf))
+		if err != nil {
+			s.error(err)
+		}
+		return result
+	case eof:
+		return ""
+	}
+	s.errorString("expected quoted string")
+	return ""
+}
+
+// hexDigit returns the value of the hexadecimal digit.
+func hexDigit(d int) (int, bool) {
+	switch d {
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return d - '0', true
+	case 'a', 'b', 'c', 'd', 'e', 'f':
+		return 10 + d - 'a', true
+	case 'A', 'B', 'C', 'D', 'E', 'F':
+		return 10 + d - 'A', true
+	}
+	return -1, false
+}
+
+// hexByte returns the next hex-encoded (two-character) byte from the input.
+// It returns ok==false if the next bytes in the input do not encode a hex byte.
+// If the first byte is hex and the second is not, processing stops.
+func (s *ss) hexByte() (uint8, bool) {
+	rune1 := s.getRune()
+	if rune1 == eof {
+		return 0, false
+	}
+	value1, ok := hexDigit(rune1)
+	if !ok {
+		s.unreadRune()
+		return 0, false
+	}
+	value2, ok2 := hexDigit(s.mustReadRune())
+	if !ok2 {
+		s.errorString("illegal hex digit")
+		return 0, false
+	}
+	return uint8(value1*16 + value2), true
+}
+
+// hexString returns the space-delimited hexpair-encoded string.
+func (s *ss) hexString() string {
+	s.notEOF()
+	for {
+		b, ok := s.hexByte()
+		if !ok {
+			break
+		}
+		s.buf = append(s.buf, b)
+	}
+	if len(s.buf) == 0 {
+		s.errorString("no hex data for %x string")
+		return ""
+	}
+	return string(s.buf)
+}
+
+// scanPercent scans a literal percent character.
+func (s *ss) scanPercent() {
+	s.skipSpace()
+	s.notEOF()
+	if !s.accept("%") {
+		s.errorString("missing literal %")
+	}
+}
+
+// scanOne scans a single value, deriving the scanner from the type of the argument.
+// The argument is left unchanged if scanning fails.
+func (s *ss) scanOne(verb int, arg interface{}) {
+	s.buf = nil
+	switch v := arg.(type) {
+	case *bool:
+		b := s.scanBool(verb)
+		if s.err == nil {
+			*v = b
+		}
+	case *int:
+		i := s.scanInt(verb)
+		if s.err == nil {
+			*v = i
+		}
+	case *uint8:
+		i := s.scanUint(verb, 255)
+		if s.err == nil {
+			*v = uint8(i)
+		}
+	case *uint16:
+		i := s.scanUint(verb, 65535)
+		if s.err == nil {
+			*v = uint16(i)
+		}
+	case *uintptr:
+		i := s.scanUint(verb, maxInt)
+		if s.err == nil {
+			*v = uintptr(i)
+		}
+	case *string:
+		str := s.convertString(verb)
+		if s.err == nil {
+			*v = str
+		}
+	case *[]uint8:
+		// We scan to string and convert so we get a copy of the data.
+		// If we scanned to bytes, the slice would point at the buffer.
+		str := s.convertString(verb)
+		if s.err == nil {
+			*v = []uint8(str)
+		}
+	default:
+		t := reflect.TypeOf(arg)
+		if t == nil || t.Kind() != reflect.Pointer {
+			typeString := "<nil>"
+			if t != nil {
+				typeString = t.String()
+			}
+			s.errorString("type not a pointer: " + typeString)
+			return
+		}
+		s.errorString("can't scan type: " + t.String())
+	}
+}
+
+// doScan does the real work for scanning without a format string.
+func (s *ss) doScan(a []interface{}) (int, error) {
+	var numProcessed int
+	for _, arg := range a {
+		s.scanOne('v', arg)
+		if s.err != nil {
+			return numProcessed, s.err
+		}
+		numProcessed++
+	}
+	// Check for newline (or EOF) if required (Sscanln).
+	if s.nlIsEnd {
+		for {
+			r := s.getRune()
+			if r == '\n' || r == eof {
+				break
+			}
+			if !isSpace(r) {
+				s.errorString("expected newline")
+				break
+			}
+		}
+	}
+	return numProcessed, s.err
+}
+
+// advance determines whether the next characters in the input match
+// those of the format. It returns the number of bytes (sic) consumed
+// in the format. All runs of space characters in either input or
+// format behave as a single space. Newlines are special, though:
+// newlines in the format must match those in the input and vice versa.
+// This routine also handles the %% case. If the return value is zero,
+// either format starts with a % (with no following %) or the input
+// is empty. If it is negative, the input did not match the string.
+func (s *ss) advance(format string) int {
+	i := 0
+	for i < len(format) && s.err == nil {
+		fmtc, w := strconv.DecodeRune(format, i)
+
+		// Space processing.
+		// In the rest of this comment "space" means spaces other than newline.
+		// Newline in the format matches input of zero or more spaces and then newline or end-of-input.
+		// Spaces in the format before the newline are collapsed into the newline.
+		// Spaces in the format after the newline match zero or more spaces after the corresponding input newline.
+		// Other spaces in the format match input of one or more spaces or end-of-input.
+		if isSpace(fmtc) {
+			newlines := 0
+			trailingSpace := false
+			for isSpace(fmtc) && i < len(format) {
+				if fmtc == '\n' {
+					newlines++
+					trailingSpace = false
+				} else {
+					trailingSpace = true
+				}
+				i += w
+				if i < len(format) {
+					fmtc, w = strconv.DecodeRune(format, i)
+				}
+			}
+			for j := 0; j < newlines; j++ {
+				inputc := s.getRune()
+				for isSpace(inputc) && inputc != '\n' {
+					inputc = s.getRune()
+				}
+				if inputc != '\n' && inputc != eof {
+					s.errorString("newline in format does not match input")
+				}
+			}
+			if trailingSpace {
+				inputc := s.getRune()
+				if newlines == 0 {
+					// If the trailing space stood alone (did not follow a newline),
+					// it must find at least one space to consume.
+					if !isSpace(inputc) && inputc != eof {
+						s.errorString("expected space in input to match format")
+					}
+					if inputc == '\n' {
+						s.errorString("newline in input does not match format")
+					}
+				}
+				for isSpace(inputc) && inputc != '\n' {
+					inputc = s.getRune()
+				}
+				if inputc != eof {
+					s.unreadRune()
+				}
+			}
+			continue
+		}
+
+		// Verbs.
+		if fmtc == '%' {
+			// % at end of string is an error.
+			if i+w == len(format) {
+				s.errorString("missing verb: % at end of format string")
+				return i
+			}
+			// %% acts like a real percent
+			if format[i+w] != '%' {
+				return i
+			}
+			i += w // skip the first %
+		}
+
+		// Literals.
+		inputc := s.mustReadRune()
+		if inputc == eof {
+			return i
+		}
+		if fmtc != inputc {
+			s.unreadRune()
+			return -1
+		}
+		i += w
+	}
+	return i
+}
+
+// doScanf does the real work when scanning with a format string.
+// At the moment, it handles only pointers to basic types.
+func (s *ss) doScanf(format string, a []interface{}) (int, error) {
+	var numProcessed int
+	end := len(format) - 1
+	// We process one item per non-trivial format
+	for i := 0; i <= end && s.err == nil; {
+		w := s.advance(format[i:])
+		if s.err != nil {
+			break
+		}
+		if w > 0 {
+			i += w
+			continue
+		}
+		// Either we failed to advance, we have a percent character, or we ran out of input.
+		if format[i] != '%' {
+			// Can't advance format. Why not?
+			if w < 0 {
+				s.errorString("input does not match format")
+			}
+			// Otherwise at EOF; "too many operands" error handled below
+			break
+		}
+		i++ // % is one byte
+
+		// do we have 20 (width)?
+		var widPresent bool
+		s.maxWid, widPresent, i = parsenum(format, i, end)
+		if !widPresent {
+			s.maxWid = hugeWid
+		}
+
+		c, size := strconv.DecodeRune(format, i)
+		i += size
+
+		if c != 'c' {
+			s.skipSpace()
+		}
+		if c == '%' {
+			s.scanPercent()
+			continue // Do not consume an argument.
+		}
+		s.argLimit = s.limit
+		if s.count+s.maxWid < s.argLimit {
+			s.argLimit = s.count + s.maxWid
+		}
+
+		if numProcessed >= len(a) { // out of operands
+			s.errorString("too few operands for format '%" + format[i-size:] + "'")
+			break
+		}
+		arg := a[numProcessed]
+
+		s.scanOne(c, arg)
+		if s.err != nil {
+			break
+		}
+		numProcessed++
+		s.argLimit = s.limit
+	}
+	if numProcessed < len(a) {
+		s.errorString("too many operands")
+	}
+	return numProcessed, s.err
+}
@@ -0,0 +1,107 @@
+package fmt
+
+import "reflect"
+
+// sortedMap returns the keys and values of the map value, sorted by key
+// so that maps print in a stable order.
+func sortedMap(value reflect.Value) ([]reflect.Value, []reflect.Value) {
+	var keys []reflect.Value
+	var values []reflect.Value
+	iter := value.MapRange()
+	for iter.Next() {
+		k := iter.Key()
+		v := iter.Value()
+		// insertion sort: maps to print are small
+		i := len(keys)
+		keys = append(keys, k)
+		values = append(values, v)
+		for i > 0 && compare(keys[i-1], k) > 0 {
+			keys[i] = keys[i-1]
+			values[i] = values[i-1]
+			i--
+		}
+		keys[i] = k
+		values[i] = v
+	}
+	return keys, values
+}
+
+// compare compares two values of the same type. It returns -1, 0, 1
+// according to whether a > b (1), a == b (0), or a < b (-1).
+// Ints, uints and strings compare by value, false sorts before true,
+// pointers compare by machine address, and interface values compare
+// first by the kind of their contents and then by the contents, nil first.
+func compare(a reflect.Value, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareInt(int(a.Int()), int(b.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return compareInt(int(a.Uint()), int(b.Uint()))
+	case reflect.String:
+		return compareString(a.String(), b.String())
+	case reflect.Bool:
+		if a.Bool() == b.Bool() {
+			return 0
+		}
+		if a.Bool() {
+			return 1
+		}
+		return -1
+	case reflect.Pointer, reflect.UnsafePointer, reflect.Chan:
+		return compareInt(int(a.Pointer()), int(b.Pointer()))
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			c := compare(a.Index(i), b.Index(i))
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			c := compare(a.Field(i), b.Field(i))
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	case reflect.Interface:
+		ae := a.Elem()
+		be := b.Elem()
+		if !ae.IsValid() || !be.IsValid() {
+			if ae.IsValid() {
+				return 1
+			}
+			if be.IsValid() {
+				return -1
+			}
+			return 0
+		}
+		c := compareInt(int(ae.Kind()), int(be.Kind()))
+		if c != 0 {
+			return c
+		}
+		return compare(ae, be)
+	}
+	return 0
+}
+
+func compareInt(a int, b int) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// compareString compares strings byte by byte, as babygo cannot order strings with < and >.
+func compareString(a string, b string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return compareInt(int(a[i]), int(b[i]))
+		}
+	}
+	return compareInt(len(a), len(b))
+}
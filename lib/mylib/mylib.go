@@ -11,6 +11,14 @@ func (mt *Type) Method() int {
 	return mt.Field
 }
 
+// AsInterface returns values converted to interface{} in this package
+func AsInterface(n int) interface{} {
+	if n == 0 {
+		return "zero"
+	}
+	return &Type{Field: n}
+}
+
 func InArray(x string, list []string) bool {
 	for _, v := range list {
 		if v == x {
@@ -29,8 +37,8 @@ func Sum2(a int, b int) int {
 }
 
 func Readdirnames(dir string) ([]string, error) {
-	f, _ := os.Open(dir)
-	if f.Fd() < 0 {
+	f, err := os.Open(dir)
+	if err != nil {
 		panic("cannot open " + dir)
 	}
 
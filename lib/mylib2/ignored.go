@@ -0,0 +1,8 @@
+//go:build ignore
+
+package mylib2
+
+// excluded by the build constraint
+func Platform() string {
+	return "ignored"
+}
@@ -0,0 +1,5 @@
+package mylib2
+
+func Platform() string {
+	return "linux"
+}
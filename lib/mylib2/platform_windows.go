@@ -0,0 +1,6 @@
+package mylib2
+
+// excluded by the _windows suffix
+func Platform() string {
+	return "windows"
+}
@@ -0,0 +1,8 @@
+//go:build babygo && (linux || darwin)
+
+package mylib2
+
+// Sum3 has the same behavior in both variants
+func Sum3(a int, b int, c int) int {
+	return a + b + c
+}
@@ -0,0 +1,9 @@
+//go:build !babygo
+// +build !babygo
+
+package mylib2
+
+// Sum3 has the same behavior in both variants
+func Sum3(a int, b int, c int) int {
+	return Sum2(Sum2(a, b), c)
+}
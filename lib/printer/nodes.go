@@ -0,0 +1,1611 @@
+// This file implements printing of AST nodes; specifically
+// expressions, statements, declarations, and files. It uses
+// the print functionality implemented in printer.go.
+
+package printer
+
+import (
+	"github.com/DQNEO/babygo/lib/ast"
+	"github.com/DQNEO/babygo/lib/token"
+)
+
+// Formatting issues:
+// - better comment formatting for /*-style comments at the end of a line (e.g. a declaration)
+//   when the comment spans multiple lines; if such a comment is just two lines, formatting is
+//   not idempotent
+// - formatting of expression lists
+// - should use blank instead of tab to separate one-line function bodies from
+//   the function header unless there is a group of consecutive one-liners
+
+// ----------------------------------------------------------------------------
+// Common AST nodes.
+
+// Print as many newlines as necessary (but at least min newlines) to get to
+// the current line. ws is printed before the first line break. If newSection
+// is set, the first line break is printed as formfeed. Returns 0 if no line
+// breaks were printed, returns 1 if there was exactly one newline printed,
+// and returns a value > 1 if there was a formfeed or more than one newline
+// printed.
+func (p *printer) linebreak(line int, min int, ws whiteSpace, newSection bool) int {
+	var nbreaks int
+	n := nlimit(line - p.pos.Line)
+	if n < min {
+		n = min
+	}
+	if n > 0 {
+		p.printWS(ws)
+		if newSection {
+			p.printWS(formfeed)
+			n--
+			nbreaks = 2
+		}
+		nbreaks = nbreaks + n
+		for n > 0 {
+			p.printWS(newline)
+			n--
+		}
+	}
+	return nbreaks
+}
+
+// setComment sets g as the next comment if g != nil and if node comments
+// are enabled - this mode is used when printing source code fragments such
+// as exports only. It assumes that there is no pending comment in p.comments
+// and at most one pending comment in the p.comment cache.
+func (p *printer) setComment(g *ast.CommentGroup) {
+	if g == nil || !p.useNodeComments {
+		return
+	}
+	if len(p.comments) == 0 {
+		// initialize p.comments lazily
+		p.comments = make([]*ast.CommentGroup, 1, 1)
+	} else if p.cindex < len(p.comments) {
+		// for some reason there are pending comments; this
+		// should never happen - handle gracefully and flush
+		// all comments up to g, ignore anything after that
+		p.flush(p.posFor(g.List[0].Slash), "")
+		p.comments = p.comments[0:1]
+	}
+	p.comments[0] = g
+	p.cindex = 0
+	// don't overwrite any pending comment in the p.comment cache
+	// (there may be a pending comment when a line comment is
+	// immediately followed by a lead comment with no other
+	// tokens between)
+	if p.commentOffset == infinity {
+		p.nextComment() // get comment ready for use
+	}
+}
+
+type exprListMode int
+
+const commaTerm exprListMode = 1 // list is optionally terminated by a comma
+const noIndent exprListMode = 2  // no extra indentation in multi-line lists
+
+// If indent is set, a multi-line identifier list is indented after the
+// first linebreak encountered.
+func (p *printer) identList(list []*ast.Ident, indent bool) {
+	// convert into an expression list so we can re-use exprList formatting
+	var xlist []ast.Expr
+	for _, x := range list {
+		xlist = append(xlist, x)
+	}
+	var mode exprListMode
+	if !indent {
+		mode = noIndent
+	}
+	p.exprList(token.NoPos, xlist, 1, mode, token.NoPos)
+}
+
+// Print a list of expressions. If the list spans multiple
+// source lines, the original line breaks are respected between
+// expressions.
+//
+// TODO(gri) Consider rewriting this to be independent of []ast.Expr
+// so that we can use the algorithm for any kind of list
+//
+//	(e.g., pass list via a channel over which to range).
+func (p *printer) exprList(prev0 token.Pos, list []ast.Expr, depth int, mode exprListMode, next0 token.Pos) {
+	if len(list) == 0 {
+		return
+	}
+
+	prev := p.posFor(prev0)
+	next := p.posFor(next0)
+	line := p.lineFor(nodePos(list[0]))
+	endLine := p.lineFor(nodeEnd(list[len(list)-1]))
+
+	if prev.IsValid() && prev.Line == line && line == endLine {
+		// all list entries on a single line
+		for i, x := range list {
+			if i > 0 {
+				// use position of expression following the comma as
+				// comma position for correct comment placement
+				p.setPos(nodePos(x))
+				p.printTok(",")
+				p.printWS(blank)
+			}
+			p.expr0(x, depth)
+		}
+		return
+	}
+
+	// list entries span multiple lines;
+	// use source code positions to guide line breaks
+
+	// Don't add extra indentation if noIndent is set;
+	// i.e., pretend that the first line is already indented.
+	ws := ignore
+	if mode&noIndent == 0 {
+		ws = indent
+	}
+
+	// The first linebreak is always a formfeed since this section must not
+	// depend on any previous formatting.
+	prevBreak := -1 // index of last expression that was followed by a linebreak
+	if prev.IsValid() && prev.Line < line && p.linebreak(line, 0, ws, true) > 0 {
+		ws = ignore
+		prevBreak = 0
+	}
+
+	// initialize expression/key size: a zero value indicates expr/key doesn't fit on a single line
+	size := 0
+
+	// We use the ratio between the geometric mean of the previous key sizes and
+	// the current size to determine if there should be a break in the alignment.
+	// To compute the geometric mean we accumulate the ln(size) values (lnsum)
+	// and the number of sizes included (count).
+	log2sum := 0
+	count := 0
+
+	// print all list elements
+	prevLine := prev.Line
+	for i, x := range list {
+		line = p.lineFor(nodePos(x))
+
+		// Determine if the next linebreak, if any, needs to use formfeed:
+		// in general, use the entire node size to make the decision; for
+		// key:value expressions, use the key size.
+		// TODO(gri) for a better result, should probably incorporate both
+		//           the key and the node size into the decision process
+		useFF := true
+
+		// Determine element size: All bets are off if we don't have
+		// position information for the previous and next token (likely
+		// generated code - simply ignore the size in this case by setting
+		// it to 0).
+		prevSize := size
+		size = p.nodeSize(x, infinity)
+		pair, isPair := x.(*ast.KeyValueExpr)
+		if size <= infinity && prev.IsValid() && next.IsValid() {
+			// x fits on a single line
+			if isPair {
+				size = p.nodeSize(pair.Key, infinity) // size <= infinity
+			}
+		} else {
+			// size too large or we don't have good layout information
+			size = 0
+		}
+
+		// If the previous line and the current line had single-
+		// line-expressions and the key sizes are small or the
+		// ratio between the current key and the geometric mean
+		// if the previous key sizes does not exceed a threshold,
+		// align columns and do not use formfeed.
+		if prevSize > 0 && size > 0 {
+			if count == 0 || prevSize <= smallSize && size <= smallSize {
+				useFF = false
+			} else {
+				// threshold r = 2.5
+				geomean := exp2ish(log2sum / count) // count > 0
+				useFF = 5*size*log2Scale <= 2*geomean || 5*geomean <= 2*size*log2Scale
+			}
+		}
+
+		needsLinebreak := 0 < prevLine && prevLine < line
+		if i > 0 {
+			// Use position of expression following the comma as
+			// comma position for correct comment placement, but
+			// only if the expression is on the same line.
+			if !needsLinebreak {
+				p.setPos(nodePos(x))
+			}
+			p.printTok(",")
+			needsBlank := true
+			if needsLinebreak {
+				// Lines are broken using newlines so comments remain aligned
+				// unless useFF is set or there are multiple expressions on
+				// the same line in which case formfeed is used.
+				nbreaks := p.linebreak(line, 0, ws, useFF || prevBreak+1 < i)
+				if nbreaks > 0 {
+					ws = ignore
+					prevBreak = i
+					needsBlank = false // we got a line break instead
+				}
+				// If there was a new section or more than one new line
+				// (which means that the tabwriter will implicitly break
+				// the section), reset the geomean variables since we are
+				// starting a new group of elements with the next element.
+				if nbreaks > 1 {
+					log2sum = 0
+					count = 0
+				}
+			}
+			if needsBlank {
+				p.printWS(blank)
+			}
+		}
+
+		if len(list) > 1 && isPair && size > 0 && needsLinebreak {
+			// We have a key:value expression that fits onto one line
+			// and it's not on the same line as the prior expression:
+			// Use a column for the key such that consecutive entries
+			// can align if possible.
+			// (needsLinebreak is set if we started a new line before)
+			p.expr(pair.Key)
+			p.setPos(pair.Colon)
+			p.printTok(":")
+			p.printWS(vtab)
+			p.expr(pair.Value)
+		} else {
+			p.expr0(x, depth)
+		}
+
+		if size > 0 {
+			log2sum = log2sum + log2ish(size)
+			count++
+		}
+
+		prevLine = line
+	}
+
+	if mode&commaTerm != 0 && next.IsValid() && p.pos.Line < next.Line {
+		// Print a terminating comma if the next token is on a new line.
+		p.printTok(",")
+		if ws == ignore && mode&noIndent == 0 {
+			// unindent if we indented
+			p.printWS(unindent)
+		}
+		p.printWS(formfeed) // terminating comma needs a line break to look good
+		return
+	}
+
+	if ws == ignore && mode&noIndent == 0 {
+		// unindent if we indented
+		p.printWS(unindent)
+	}
+}
+
+const smallSize int = 40            // size of small keys in exprList that are always aligned
+const oneLineFieldListSize int = 30 // max. size of a one-line struct/interface field list
+const oneLineFuncBodySize int = 100 // max. size of a one-line function
+
+// log2Scale is the fixed-point scale of the values computed by log2ish and exp2ish.
+const log2Scale int = 1048576
+
+// log2ish returns a crude approximation to log₂(x), scaled by log2Scale.
+// The result is only used for heuristic alignment decisions.
+func log2ish(x int) int {
+	// x = f * 2**e with 0.5 <= f < 1
+	e := 0
+	pow := 1
+	for pow <= x {
+		pow = pow * 2
+		e++
+	}
+	return (e-2)*log2Scale + 2*x*log2Scale/pow
+}
+
+// exp2ish returns a crude approximation to 2**x, scaled by log2Scale;
+// x is scaled by log2Scale as well.
+func exp2ish(x int) int {
+	n := x / log2Scale
+	f := x - n*log2Scale
+	r := log2Scale + f
+	for n > 0 {
+		r = r * 2
+		n--
+	}
+	return r
+}
+
+func (p *printer) parameters(fields *ast.FieldList) {
+	p.setPos(fields.Opening)
+	p.printTok("(")
+	if len(fields.List) > 0 {
+		prevLine := p.lineFor(fields.Opening)
+		ws := indent
+		for i, par := range fields.List {
+			// determine par begin and end line (may be different
+			// if there are multiple parameter names for this par
+			// or the type is on a separate line)
+			parLineBeg := p.lineFor(fieldPos(par))
+			parLineEnd := p.lineFor(fieldEnd(par))
+			// separating "," if needed
+			needsLinebreak := 0 < prevLine && prevLine < parLineBeg
+			if i > 0 {
+				// use position of parameter following the comma as
+				// comma position for correct comma placement, but
+				// only if the next parameter is on the same line
+				if !needsLinebreak {
+					p.setPos(fieldPos(par))
+				}
+				p.printTok(",")
+			}
+			// separator if needed (linebreak or blank)
+			if needsLinebreak && p.linebreak(parLineBeg, 0, ws, true) > 0 {
+				// break line if the opening "(" or previous parameter ended on a different line
+				ws = ignore
+			} else if i > 0 {
+				p.printWS(blank)
+			}
+			// parameter names
+			if len(par.Names) > 0 {
+				// Very subtle: If we indented before (ws == ignore), identList
+				// won't indent again. If we didn't (ws == indent), identList will
+				// indent if the identList spans multiple lines, and it will outdent
+				// again at the end (and still ws == indent). Thus, a subsequent indent
+				// by a linebreak call after a type, or in the next multi-line identList
+				// will do the right thing.
+				p.identList(par.Names, ws == indent)
+				p.printWS(blank)
+			}
+			// parameter type
+			p.expr(stripParensAlways(par.Type))
+			prevLine = parLineEnd
+		}
+
+		// if the closing ")" is on a separate line from the last parameter,
+		// print an additional "," and line break
+		closing := p.lineFor(fields.Closing)
+		if 0 < prevLine && prevLine < closing {
+			p.printTok(",")
+			p.linebreak(closing, 0, ignore, true)
+		}
+
+		// unindent if we indented
+		if ws == ignore {
+			p.printWS(unindent)
+		}
+	}
+
+	p.setPos(fields.Closing)
+	p.printTok(")")
+}
+
+func (p *printer) signature(sig *ast.FuncType) {
+	if sig.Params != nil {
+		p.parameters(sig.Params)
+	} else {
+		p.printTok("(")
+		p.printTok(")")
+	}
+	res := sig.Results
+	n := 0
+	if res != nil {
+		n = len(res.List)
+	}
+	if n > 0 {
+		// res != nil
+		p.printWS(blank)
+		if n == 1 && len(res.List[0].Names) == 0 {
+			// single anonymous res; no ()'s
+			p.expr(stripParensAlways(res.List[0].Type))
+			return
+		}
+		p.parameters(res)
+	}
+}
+
+func identListSize(list []*ast.Ident, maxSize int) int {
+	size := 0
+	for i, x := range list {
+		if i > 0 {
+			size = size + len(", ")
+		}
+		size = size + runeCount(x.Name)
+		if size >= maxSize {
+			break
+		}
+	}
+	return size
+}
+
+func (p *printer) isOneLineFieldList(list []*ast.Field) bool {
+	if len(list) != 1 {
+		return false // allow only one field
+	}
+	f := list[0]
+	if f.Comment != nil {
+		return false // don't allow comments
+	}
+	// only name(s) and type
+	namesSize := identListSize(f.Names, oneLineFieldListSize)
+	if namesSize > 0 {
+		namesSize = 1 // blank between names and types
+	}
+	typeSize := p.nodeSize(f.Type, oneLineFieldListSize)
+	return namesSize+typeSize <= oneLineFieldListSize
+}
+
+func (p *printer) fieldList(fields *ast.FieldList, isStruct bool) {
+	lbrace := fields.Opening
+	list := fields.List
+	rbrace := fields.Closing
+	hasComments := p.commentBefore(p.posFor(rbrace))
+	srcIsOneLine := lbrace.IsValid() && rbrace.IsValid() && p.lineFor(lbrace) == p.lineFor(rbrace)
+
+	if !hasComments && srcIsOneLine {
+		// possibly a one-line struct/interface
+		if len(list) == 0 {
+			// no blank between keyword and {} in this case
+			p.setPos(lbrace)
+			p.printTok("{")
+			p.setPos(rbrace)
+			p.printTok("}")
+			return
+		} else if p.isOneLineFieldList(list) {
+			// small enough - print on one line
+			// (don't use identList and ignore source line breaks)
+			p.setPos(lbrace)
+			p.printTok("{")
+			p.printWS(blank)
+			f := list[0]
+			if isStruct {
+				for i, x := range f.Names {
+					if i > 0 {
+						// no comments so no need for comma position
+						p.printTok(",")
+						p.printWS(blank)
+					}
+					p.expr(x)
+				}
+				if len(f.Names) > 0 {
+					p.printWS(blank)
+				}
+				p.expr(f.Type)
+			} else { // interface
+				p.interfaceElem(f)
+			}
+			p.printWS(blank)
+			p.setPos(rbrace)
+			p.printTok("}")
+			return
+		}
+	}
+	// hasComments || !srcIsOneLine
+
+	p.printWS(blank)
+	p.setPos(lbrace)
+	p.printTok("{")
+	p.printWS(indent)
+	if hasComments || len(list) > 0 {
+		p.printWS(formfeed)
+	}
+
+	if isStruct {
+		sep := vtab
+		if len(list) == 1 {
+			sep = blank
+		}
+		var line int
+		for i, f := range list {
+			if i > 0 {
+				p.linebreak(p.lineFor(fieldPos(f)), 1, ignore, p.linesFrom(line) > 0)
+			}
+			extraTabs := 0
+			p.setComment(f.Doc)
+			p.recordLine(&line)
+			if len(f.Names) > 0 {
+				// named fields
+				p.identList(f.Names, false)
+				p.printWS(sep)
+				p.expr(f.Type)
+				extraTabs = 1
+			} else {
+				// anonymous field
+				p.expr(f.Type)
+				extraTabs = 2
+			}
+			if f.Comment != nil {
+				for extraTabs > 0 {
+					p.printWS(sep)
+					extraTabs--
+				}
+				p.setComment(f.Comment)
+			}
+		}
+	} else { // interface
+		var line int
+		for i, f := range list {
+			if i > 0 {
+				p.linebreak(p.lineFor(fieldPos(f)), 1, ignore, p.linesFrom(line) > 0)
+			}
+			p.setComment(f.Doc)
+			p.recordLine(&line)
+			p.interfaceElem(f)
+			p.setComment(f.Comment)
+		}
+	}
+	p.printWS(unindent)
+	p.printWS(formfeed)
+	p.setPos(rbrace)
+	p.printTok("}")
+}
+
+// interfaceElem prints a method of an interface as its name followed by its signature,
+// or an embedded type.
+func (p *printer) interfaceElem(f *ast.Field) {
+	if len(f.Names) > 0 {
+		p.expr(f.Names[0])                  // method name
+		p.signature(f.Type.(*ast.FuncType)) // don't print "func"
+		return
+	}
+	// embedded interface
+	p.expr(f.Type)
+}
+
+// ----------------------------------------------------------------------------
+// Expressions
+
+// binaryInfo is the result of walkBinary.
+type binaryInfo struct {
+	has4       bool
+	has5       bool
+	maxProblem int
+}
+
+func walkBinary(e *ast.BinaryExpr) *binaryInfo {
+	r := &binaryInfo{}
+	switch e.Op.Precedence() {
+	case 4:
+		r.has4 = true
+	case 5:
+		r.has5 = true
+	}
+
+	l, ok := e.X.(*ast.BinaryExpr)
+	if ok {
+		if l.Op.Precedence() >= e.Op.Precedence() {
+			// parens will be inserted.
+			// pretend this is an *ast.ParenExpr and do nothing.
+			r.merge(walkBinary(l))
+		}
+	}
+
+	switch y := e.Y.(type) {
+	case *ast.BinaryExpr:
+		if y.Op.Precedence() > e.Op.Precedence() {
+			// parens will be inserted.
+			// pretend this is an *ast.ParenExpr and do nothing.
+			r.merge(walkBinary(y))
+		}
+	case *ast.StarExpr:
+		if e.Op == "/" { // `*/`
+			r.maxProblem = 5
+		}
+	case *ast.UnaryExpr:
+		ops := string(e.Op) + string(y.Op)
+		if ops == "/*" || ops == "&&" || ops == "&^" {
+			r.maxProblem = 5
+		} else if ops == "++" || ops == "--" {
+			if r.maxProblem < 4 {
+				r.maxProblem = 4
+			}
+		}
+	}
+	return r
+}
+
+func (r *binaryInfo) merge(s *binaryInfo) {
+	r.has4 = r.has4 || s.has4
+	r.has5 = r.has5 || s.has5
+	if r.maxProblem < s.maxProblem {
+		r.maxProblem = s.maxProblem
+	}
+}
+
+func cutoff(e *ast.BinaryExpr, depth int) int {
+	r := walkBinary(e)
+	if r.maxProblem > 0 {
+		return r.maxProblem + 1
+	}
+	if r.has4 && r.has5 {
+		if depth == 1 {
+			return 5
+		}
+		return 4
+	}
+	if depth == 1 {
+		return 6
+	}
+	return 4
+}
+
+func diffPrec(expr ast.Expr, prec int) int {
+	x, ok := expr.(*ast.BinaryExpr)
+	if !ok || prec != x.Op.Precedence() {
+		return 1
+	}
+	return 0
+}
+
+func reduceDepth(depth int) int {
+	depth--
+	if depth < 1 {
+		depth = 1
+	}
+	return depth
+}
+
+// Format the binary expression: decide the cutoff and then format.
+// Let's call depth == 1 Normal mode, and depth > 1 Compact mode.
+// (Algorithm suggestion by Russ Cox.)
+//
+// The precedences are:
+//
+//	5             *  /  %  <<  >>  &  &^
+//	4             +  -  |  ^
+//	3             ==  !=  <  <=  >  >=
+//	2             &&
+//	1             ||
+//
+// The only decision is whether there will be spaces around levels 4 and 5.
+// There are never spaces at level 6 (unary), and always spaces at levels 3 and below.
+//
+// To choose the cutoff, look at the whole expression but excluding primary
+// expressions (function calls, parenthesized exprs), and apply these rules:
+//
+//  1. If there is a binary operator with a right side unary operand
+//     that would clash without a space, the cutoff must be (in order):
+//
+//     /*	6
+//     &&	6
+//     &^	6
+//     ++	5
+//     --	5
+//
+//     (Comparison operators always have spaces around them.)
+//
+//  2. If there is a mix of level 5 and level 4 operators, then the cutoff
+//     is 5 (use spaces to distinguish precedence) in Normal mode
+//     and 4 (never use spaces) in Compact mode.
+//
+//  3. If there are no level 4 operators or no level 5 operators, then the
+//     cutoff is 6 (always use spaces) in Normal mode
+//     and 4 (never use spaces) in Compact mode.
+func (p *printer) binaryExpr(x *ast.BinaryExpr, prec1 int, cutoff int, depth int) {
+	prec := x.Op.Precedence()
+	if prec < prec1 {
+		// parenthesis needed
+		// Note: The parser inserts an ast.ParenExpr node; thus this case
+		//       can only occur if the AST is created in a different way.
+		p.printTok("(")
+		p.expr0(x, reduceDepth(depth)) // parentheses undo one level of depth
+		p.printTok(")")
+		return
+	}
+
+	printBlank := prec < cutoff
+
+	ws := indent
+	p.expr1(x.X, prec, depth+diffPrec(x.X, prec))
+	if printBlank {
+		p.printWS(blank)
+	}
+	xline := p.pos.Line // before the operator (it may be on the next line!)
+	yline := p.lineFor(nodePos(x.Y))
+	p.setPos(x.OpPos)
+	p.printTok(x.Op)
+	if xline != yline && xline > 0 && yline > 0 {
+		// at least one line break, but respect an extra empty line
+		// in the source
+		if p.linebreak(yline, 1, ws, true) > 0 {
+			ws = ignore
+			printBlank = false // no blank after line break
+		}
+	}
+	if printBlank {
+		p.printWS(blank)
+	}
+	p.expr1(x.Y, prec+1, depth+1)
+	if ws == ignore {
+		p.printWS(unindent)
+	}
+}
+
+func isBinary(expr ast.Expr) bool {
+	_, ok := expr.(*ast.BinaryExpr)
+	return ok
+}
+
+func (p *printer) expr1(expr ast.Expr, prec1 int, depth int) {
+	p.setPos(nodePos(expr))
+
+	switch x := expr.(type) {
+	case *ast.Ident:
+		p.printIdent(x)
+
+	case *ast.BinaryExpr:
+		if depth < 1 {
+			depth = 1
+		}
+		p.binaryExpr(x, prec1, cutoff(x, depth), depth)
+
+	case *ast.KeyValueExpr:
+		p.expr(x.Key)
+		p.setPos(x.Colon)
+		p.printTok(":")
+		p.printWS(blank)
+		p.expr(x.Value)
+
+	case *ast.StarExpr:
+		if token.UnaryPrec < prec1 {
+			// parenthesis needed
+			p.printTok("(")
+			p.printTok("*")
+			p.expr(x.X)
+			p.printTok(")")
+		} else {
+			// no parenthesis needed
+			p.printTok("*")
+			p.expr(x.X)
+		}
+
+	case *ast.UnaryExpr:
+		if token.UnaryPrec < prec1 {
+			// parenthesis needed
+			p.printTok("(")
+			p.expr(x)
+			p.printTok(")")
+		} else {
+			// no parenthesis needed
+			p.printTok(x.Op)
+			if x.Op == "range" {
+				// TODO(gri) Remove this code if it cannot be reached.
+				p.printWS(blank)
+			}
+			p.expr1(x.X, token.UnaryPrec, depth)
+		}
+
+	case *ast.BasicLit:
+		p.printLit(x)
+
+	case *ast.ParenExpr:
+		_, hasParens := x.X.(*ast.ParenExpr)
+		if hasParens {
+			// don't print parentheses around an already parenthesized expression
+			// TODO(gri) consider making this more general and incorporate precedence levels
+			p.expr0(x.X, depth)
+		} else {
+			p.printTok("(")
+			p.expr0(x.X, reduceDepth(depth)) // parentheses undo one level of depth
+			p.setPos(x.Rparen)
+			p.printTok(")")
+		}
+
+	case *ast.SelectorExpr:
+		p.selectorExpr(x, depth, false)
+
+	case *ast.TypeAssertExpr:
+		p.expr1(x.X, token.HighestPrec, depth)
+		p.printTok(".")
+		p.setPos(x.Lparen)
+		p.printTok("(")
+		if x.Type != nil {
+			p.expr(x.Type)
+		} else {
+			p.printTok("type")
+		}
+		p.setPos(x.Rparen)
+		p.printTok(")")
+
+	case *ast.IndexExpr:
+		// TODO(gri): should treat[] like parentheses and undo one level of depth
+		p.expr1(x.X, token.HighestPrec, 1)
+		p.setPos(x.Lbrack)
+		p.printTok("[")
+		p.expr0(x.Index, depth+1)
+		p.setPos(x.Rbrack)
+		p.printTok("]")
+
+	case *ast.SliceExpr:
+		// TODO(gri): should treat[] like parentheses and undo one level of depth
+		p.expr1(x.X, token.HighestPrec, 1)
+		p.setPos(x.Lbrack)
+		p.printTok("[")
+		indices := []ast.Expr{x.Low, x.High}
+		if x.Max != nil {
+			indices = append(indices, x.Max)
+		}
+		// determine if we need extra blanks around ':'
+		var needsBlanks bool
+		if depth <= 1 {
+			var indexCount int
+			var hasBinaries bool
+			for _, y := range indices {
+				if y != nil {
+					indexCount++
+					if isBinary(y) {
+						hasBinaries = true
+					}
+				}
+			}
+			if indexCount > 1 && hasBinaries {
+				needsBlanks = true
+			}
+		}
+		for i, y := range indices {
+			if i > 0 {
+				if indices[i-1] != nil && needsBlanks {
+					p.printWS(blank)
+				}
+				p.printTok(":")
+				if y != nil && needsBlanks {
+					p.printWS(blank)
+				}
+			}
+			if y != nil {
+				p.expr0(y, depth+1)
+			}
+		}
+		p.setPos(x.Rbrack)
+		p.printTok("]")
+
+	case *ast.CallExpr:
+		if len(x.Args) > 1 {
+			depth++
+		}
+
+		// Conversions to literal function types require parentheses around the type.
+		_, paren := x.Fun.(*ast.FuncType)
+		if paren {
+			p.printTok("(")
+		}
+		wasIndented := p.possibleSelectorExpr(x.Fun, token.HighestPrec, depth)
+		if paren {
+			p.printTok(")")
+		}
+
+		p.setPos(x.Lparen)
+		p.printTok("(")
+		if x.Ellipsis.IsValid() {
+			p.exprList(x.Lparen, x.Args, depth, 0, x.Ellipsis)
+			p.setPos(x.Ellipsis)
+			p.printTok("...")
+			if x.Rparen.IsValid() && p.lineFor(x.Ellipsis) < p.lineFor(x.Rparen) {
+				p.printTok(",")
+				p.printWS(formfeed)
+			}
+		} else {
+			p.exprList(x.Lparen, x.Args, depth, commaTerm, x.Rparen)
+		}
+		p.setPos(x.Rparen)
+		p.printTok(")")
+		if wasIndented {
+			p.printWS(unindent)
+		}
+
+	case *ast.CompositeLit:
+		// composite literal elements that are composite literals themselves may have the type omitted
+		if x.Type != nil {
+			p.expr1(x.Type, token.HighestPrec, depth)
+		}
+		p.level++
+		p.setPos(x.Lbrace)
+		p.printTok("{")
+		p.exprList(x.Lbrace, x.Elts, 1, commaTerm, x.Rbrace)
+		// do not insert extra line break following a /*-style comment
+		// before the closing '}' as it might break the code if there
+		// is no trailing ','
+		mode := noExtraLinebreak
+		// do not insert extra blank following a /*-style comment
+		// before the closing '}' unless the literal is empty
+		if len(x.Elts) > 0 {
+			mode = mode | noExtraBlank
+		}
+		// need the initial indent to print lone comments with
+		// the proper level of indentation
+		p.printWS(indent)
+		p.printWS(unindent)
+		p.printMode(mode)
+		p.setPos(x.Rbrace)
+		p.printTok("}")
+		p.printMode(mode)
+		p.level--
+
+	case *ast.Ellipsis:
+		p.printTok("...")
+		if x.Elt != nil {
+			p.expr(x.Elt)
+		}
+
+	case *ast.ArrayType:
+		p.printTok("[")
+		if x.Len != nil {
+			p.expr(x.Len)
+		}
+		p.printTok("]")
+		p.expr(x.Elt)
+
+	case *ast.StructType:
+		p.printTok("struct")
+		p.fieldList(x.Fields, true)
+
+	case *ast.FuncType:
+		p.printTok("func")
+		p.signature(x)
+
+	case *ast.InterfaceType:
+		p.printTok("interface")
+		p.fieldList(x.Methods, false)
+
+	case *ast.MapType:
+		p.printTok("map")
+		p.printTok("[")
+		p.expr(x.Key)
+		p.printTok("]")
+		p.expr(x.Value)
+
+	default:
+		panic("printer: unexpected expression")
+	}
+}
+
+func (p *printer) possibleSelectorExpr(expr ast.Expr, prec1 int, depth int) bool {
+	x, ok := expr.(*ast.SelectorExpr)
+	if ok {
+		return p.selectorExpr(x, depth, true)
+	}
+	p.expr1(expr, prec1, depth)
+	return false
+}
+
+// selectorExpr handles an *ast.SelectorExpr node and reports whether x spans
+// multiple lines.
+func (p *printer) selectorExpr(x *ast.SelectorExpr, depth int, isMethod bool) bool {
+	p.expr1(x.X, token.HighestPrec, depth)
+	p.printTok(".")
+	line := p.lineFor(x.Sel.NamePos)
+	if p.pos.IsValid() && p.pos.Line < line {
+		p.printWS(indent)
+		p.printWS(newline)
+		p.setPos(x.Sel.NamePos)
+		p.printIdent(x.Sel)
+		if !isMethod {
+			p.printWS(unindent)
+		}
+		return true
+	}
+	p.setPos(x.Sel.NamePos)
+	p.printIdent(x.Sel)
+	return false
+}
+
+func (p *printer) expr0(x ast.Expr, depth int) {
+	p.expr1(x, token.LowestPrec, depth)
+}
+
+func (p *printer) expr(x ast.Expr) {
+	p.expr1(x, token.LowestPrec, 1)
+}
+
+// ----------------------------------------------------------------------------
+// Statements
+
+// Print the statement list indented, but without a newline after the last statement.
+// Extra line breaks between statements in the source are respected but at most one
+// empty line is printed between statements.
+func (p *printer) stmtList(list []ast.Stmt, nindent int, nextIsRBrace bool) {
+	if nindent > 0 {
+		p.printWS(indent)
+	}
+	var line int
+	for i, s := range list {
+		// nindent == 0 only for lists of switch/select case clauses;
+		// in those cases each clause is a new section
+		if len(p.output) > 0 {
+			// only print line break if we are not at the beginning of the output
+			// (i.e., we are not printing only a partial program)
+			p.linebreak(p.lineFor(nodePos(s)), 1, ignore, i == 0 || nindent == 0 || p.linesFrom(line) > 0)
+		}
+		p.recordLine(&line)
+		p.stmt(s, nextIsRBrace && i == len(list)-1)
+	}
+	if nindent > 0 {
+		p.printWS(unindent)
+	}
+}
+
+// block prints an *ast.BlockStmt; it always spans at least two lines.
+func (p *printer) block(b *ast.BlockStmt, nindent int) {
+	p.setPos(b.Lbrace)
+	p.printTok("{")
+	p.stmtList(b.List, nindent, true)
+	p.linebreak(p.lineFor(b.Rbrace), 1, ignore, true)
+	p.setPos(b.Rbrace)
+	p.printTok("}")
+}
+
+func isTypeName(x ast.Expr) bool {
+	switch t := x.(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		return isTypeName(t.X)
+	}
+	return false
+}
+
+// hasTypeNameLit reports whether x contains a composite literal with a type
+// name that is not enclosed in parentheses.
+func hasTypeNameLit(x ast.Expr) bool {
+	switch e := x.(type) {
+	case *ast.ParenExpr:
+		return false
+	case *ast.CompositeLit:
+		return isTypeName(e.Type)
+	case *ast.BinaryExpr:
+		return hasTypeNameLit(e.X) || hasTypeNameLit(e.Y)
+	case *ast.UnaryExpr:
+		return hasTypeNameLit(e.X)
+	case *ast.StarExpr:
+		return hasTypeNameLit(e.X)
+	case *ast.SelectorExpr:
+		return hasTypeNameLit(e.X)
+	case *ast.TypeAssertExpr:
+		return hasTypeNameLit(e.X)
+	case *ast.IndexExpr:
+		return hasTypeNameLit(e.X) || hasTypeNameLit(e.Index)
+	case *ast.SliceExpr:
+		return hasTypeNameLit(e.X) || e.Low != nil && hasTypeNameLit(e.Low) ||
+			e.High != nil && hasTypeNameLit(e.High) || e.Max != nil && hasTypeNameLit(e.Max)
+	case *ast.KeyValueExpr:
+		return hasTypeNameLit(e.Key) || hasTypeNameLit(e.Value)
+	case *ast.CallExpr:
+		if hasTypeNameLit(e.Fun) {
+			return true
+		}
+		for _, arg := range e.Args {
+			if hasTypeNameLit(arg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func stripParens(x ast.Expr) ast.Expr {
+	px, strip := x.(*ast.ParenExpr)
+	if strip {
+		// parentheses must not be stripped if there are any
+		// unparenthesized composite literals starting with
+		// a type name
+		if !hasTypeNameLit(px.X) {
+			return stripParens(px.X)
+		}
+	}
+	return x
+}
+
+func stripParensAlways(x ast.Expr) ast.Expr {
+	px, ok := x.(*ast.ParenExpr)
+	if ok {
+		return stripParensAlways(px.X)
+	}
+	return x
+}
+
+func (p *printer) controlClause(isForStmt bool, init ast.Stmt, expr ast.Expr, post ast.Stmt) {
+	p.printWS(blank)
+	needsBlank := false
+	if init == nil && post == nil {
+		// no semicolons required
+		if expr != nil {
+			p.expr(stripParens(expr))
+			needsBlank = true
+		}
+	} else {
+		// all semicolons required
+		// (they are not separators, print them explicitly)
+		if init != nil {
+			p.stmt(init, false)
+		}
+		p.printTok(";")
+		p.printWS(blank)
+		if expr != nil {
+			p.expr(stripParens(expr))
+			needsBlank = true
+		}
+		if isForStmt {
+			p.printTok(";")
+			p.printWS(blank)
+			needsBlank = false
+			if post != nil {
+				p.stmt(post, false)
+				needsBlank = true
+			}
+		}
+	}
+	if needsBlank {
+		p.printWS(blank)
+	}
+}
+
+func isCompositeLitLike(x ast.Expr) bool {
+	switch e := stripParensAlways(x).(type) {
+	case *ast.CompositeLit:
+		return true
+	case *ast.UnaryExpr:
+		_, ok := stripParensAlways(e.X).(*ast.CompositeLit)
+		return e.Op == "&" && ok
+	}
+	return false
+}
+
+// indentList reports whether an expression list would look better if it
+// were indented wholesale (starting with the very first element, rather
+// than starting at the first line break).
+func (p *printer) indentList(list []ast.Expr) bool {
+	// Heuristic: indentList reports whether there are more than one multi-
+	// line element in the list, or if there is any element that is not
+	// starting on the same line as the previous one ends.
+	if len(list) >= 2 {
+		var b = p.lineFor(nodePos(list[0]))
+		var e = p.lineFor(nodeEnd(list[len(list)-1]))
+		if 0 < b && b < e {
+			// list spans multiple lines
+			n := 0 // multi-line element count
+			line := b
+			for _, x := range list {
+				xb := p.lineFor(nodePos(x))
+				xe := p.lineFor(nodeEnd(x))
+				if line < xb {
+					// x is not starting on the same
+					// line as the previous one ended
+					return true
+				}
+				if xb < xe && !isCompositeLitLike(x) {
+					// x is a multi-line element
+					n++
+				}
+				line = xe
+			}
+			return n > 1
+		}
+	}
+	return false
+}
+
+func (p *printer) stmt(stmt ast.Stmt, nextIsRBrace bool) {
+	p.setPos(nodePos(stmt))
+
+	switch s := stmt.(type) {
+	case *ast.DeclStmt:
+		p.decl(s.Decl)
+
+	case *ast.ExprStmt:
+		p.expr0(s.X, 1)
+
+	case *ast.IncDecStmt:
+		p.expr0(s.X, 2)
+		p.setPos(s.TokPos)
+		p.printTok(s.Tok)
+
+	case *ast.AssignStmt:
+		var depth = 1
+		if len(s.Lhs) > 1 && len(s.Rhs) > 1 {
+			depth++
+		}
+		p.exprList(nodePos(s), s.Lhs, depth, 0, s.TokPos)
+		p.printWS(blank)
+		p.setPos(s.TokPos)
+		p.printTok(s.Tok)
+		p.printWS(blank)
+		p.exprList(s.TokPos, s.Rhs, depth, 0, token.NoPos)
+
+	case *ast.GoStmt:
+		p.printTok("go")
+		p.printWS(blank)
+		p.expr(s.Call)
+
+	case *ast.ReturnStmt:
+		p.printTok("return")
+		if len(s.Results) > 0 {
+			p.printWS(blank)
+			// Use indentList heuristic to make corner cases look
+			// better (issue 1207). A more systematic approach would
+			// always indent, but this would cause significant
+			// reformatting of the code base and not necessarily
+			// lead to more nicely formatted code in general.
+			if p.indentList(s.Results) {
+				p.printWS(indent)
+				// Use NoPos so that a newline never goes before
+				// the results (see issue #32854).
+				p.exprList(token.NoPos, s.Results, 1, noIndent, token.NoPos)
+				p.printWS(unindent)
+			} else {
+				p.exprList(token.NoPos, s.Results, 1, 0, token.NoPos)
+			}
+		}
+
+	case *ast.BranchStmt:
+		p.printTok(s.Tok)
+
+	case *ast.BlockStmt:
+		p.block(s, 1)
+
+	case *ast.IfStmt:
+		p.printTok("if")
+		p.controlClause(false, nil, s.Cond, nil)
+		p.block(s.Body, 1)
+		if s.Else != nil {
+			p.printWS(blank)
+			p.printTok("else")
+			p.printWS(blank)
+			if isBlockOrIf(s.Else) {
+				p.stmt(s.Else, nextIsRBrace)
+			} else {
+				// This can only happen with an incorrectly
+				// constructed AST. Permit it but print so
+				// that it can be parsed without errors.
+				p.printTok("{")
+				p.printWS(indent)
+				p.printWS(formfeed)
+				p.stmt(s.Else, true)
+				p.printWS(unindent)
+				p.printWS(formfeed)
+				p.printTok("}")
+			}
+		}
+
+	case *ast.CaseClause:
+		if len(s.List) > 0 {
+			p.printTok("case")
+			p.printWS(blank)
+			p.exprList(nodePos(s), s.List, 1, 0, s.Colon)
+		} else {
+			p.printTok("default")
+		}
+		p.setPos(s.Colon)
+		p.printTok(":")
+		p.stmtList(s.Body, 1, nextIsRBrace)
+
+	case *ast.SwitchStmt:
+		p.printTok("switch")
+		p.controlClause(false, nil, s.Tag, nil)
+		p.block(s.Body, 0)
+
+	case *ast.TypeSwitchStmt:
+		p.printTok("switch")
+		p.printWS(blank)
+		p.stmt(s.Assign, false)
+		p.printWS(blank)
+		p.block(s.Body, 0)
+
+	case *ast.ForStmt:
+		p.printTok("for")
+		p.controlClause(true, s.Init, s.Cond, s.Post)
+		p.block(s.Body, 1)
+
+	case *ast.RangeStmt:
+		p.printTok("for")
+		p.printWS(blank)
+		if s.Key != nil {
+			p.expr(s.Key)
+			if s.Value != nil {
+				// use position of value following the comma as
+				// comma position for correct comment placement
+				p.setPos(nodePos(s.Value))
+				p.printTok(",")
+				p.printWS(blank)
+				p.expr(s.Value)
+			}
+			p.printWS(blank)
+			p.setPos(s.TokPos)
+			p.printTok(s.Tok)
+			p.printWS(blank)
+		}
+		p.printTok("range")
+		p.printWS(blank)
+		p.expr(stripParens(s.X))
+		p.printWS(blank)
+		p.block(s.Body, 1)
+
+	default:
+		panic("printer: unexpected statement")
+	}
+}
+
+func isBlockOrIf(s ast.Stmt) bool {
+	_, isBlock := s.(*ast.BlockStmt)
+	_, isIf := s.(*ast.IfStmt)
+	return isBlock || isIf
+}
+
+// ----------------------------------------------------------------------------
+// Declarations
+
+// The parameter n is the number of specs in the group. If doIndent is set,
+// multi-line identifier lists in the spec are indented when the first
+// linebreak is encountered.
+func (p *printer) spec(spec ast.Spec, n int, doIndent bool) {
+	switch s := spec.(type) {
+	case *ast.ImportSpec:
+		p.setComment(s.Doc)
+		p.expr(s.Path)
+		p.setComment(s.Comment)
+		p.setPos(s.EndPos)
+
+	case *ast.ValueSpec:
+		p.identList(s.Names, doIndent) // always present
+		if s.Type != nil {
+			p.printWS(blank)
+			p.expr(s.Type)
+		}
+		if len(s.Values) > 0 {
+			p.printWS(blank)
+			p.printTok("=")
+			p.printWS(blank)
+			p.exprList(token.NoPos, s.Values, 1, 0, token.NoPos)
+		}
+
+	case *ast.TypeSpec:
+		p.expr(s.Name)
+		if n == 1 {
+			p.printWS(blank)
+		} else {
+			p.printWS(vtab)
+		}
+		if s.Assign.IsValid() {
+			p.printTok("=")
+			p.printWS(blank)
+		}
+		p.expr(s.Type)
+
+	default:
+		panic("printer: unexpected spec")
+	}
+}
+
+func (p *printer) genDecl(d *ast.GenDecl) {
+	p.setComment(d.Doc)
+	p.setPos(d.TokPos)
+	p.printTok(d.Tok)
+	p.printWS(blank)
+
+	if d.Lparen.IsValid() || len(d.Specs) != 1 {
+		// group of parenthesized declarations
+		p.setPos(d.Lparen)
+		p.printTok("(")
+		n := len(d.Specs)
+		if n > 0 {
+			p.printWS(indent)
+			p.printWS(formfeed)
+			var line int
+			for i, s := range d.Specs {
+				if i > 0 {
+					p.linebreak(p.lineFor(nodePos(s)), 1, ignore, p.linesFrom(line) > 0)
+				}
+				p.recordLine(&line)
+				p.spec(s, n, false)
+			}
+			p.printWS(unindent)
+			p.printWS(formfeed)
+		}
+		p.setPos(d.Rparen)
+		p.printTok(")")
+
+	} else if len(d.Specs) > 0 {
+		// single declaration
+		p.spec(d.Specs[0], 1, true)
+	}
+}
+
+// nodeSize determines the size of n in chars after formatting.
+// The result is <= maxSize if the node fits on one line with at
+// most maxSize chars and the formatted output doesn't contain
+// any control chars. Otherwise, the result is > maxSize.
+func (p *printer) nodeSize(n interface{}, maxSize int) int {
+	// nodeSize invokes the printer, which may invoke nodeSize
+	// recursively. For deep composite literal nests, this can
+	// lead to an exponential algorithm. Remember previous
+	// results to prune the recursion (was issue 1628).
+	size := maxSize + 1 // assume n doesn't fit
+
+	// nodeSize computation must be independent of particular
+	// style so that we always get the same decision; print
+	// in RawFormat
+	cfg := &Config{Mode: RawFormat}
+	b := cfg.format(p.fset, n)
+	if len(b) <= maxSize {
+		for _, ch := range b {
+			if ch == '\n' || ch == 12 {
+				return size
+			}
+		}
+		size = len(b)
+	}
+	return size
+}
+
+// numLines returns the number of lines spanned by node n in the original source.
+func (p *printer) numLines(n interface{}) int {
+	from := nodePos(n)
+	if from.IsValid() {
+		to := nodeEnd(n)
+		if to.IsValid() {
+			return p.lineFor(to) - p.lineFor(from) + 1
+		}
+	}
+	return infinity
+}
+
+// bodySize is like nodeSize but it is specialized for *ast.BlockStmt's.
+func (p *printer) bodySize(b *ast.BlockStmt, maxSize int) int {
+	pos1 := b.Lbrace
+	pos2 := b.Rbrace
+	if pos1.IsValid() && pos2.IsValid() && p.lineFor(pos1) != p.lineFor(pos2) {
+		// opening and closing brace are on different lines - don't make it a one-liner
+		return maxSize + 1
+	}
+	if len(b.List) > 5 {
+		// too many statements - don't make it a one-liner
+		return maxSize + 1
+	}
+	// otherwise, estimate body size
+	bodySize := p.commentSizeBefore(p.posFor(pos2))
+	for i, s := range b.List {
+		if bodySize > maxSize {
+			break // no need to continue
+		}
+		if i > 0 {
+			bodySize = bodySize + 2 // space for a semicolon and blank
+		}
+		bodySize = bodySize + p.nodeSize(s, maxSize)
+	}
+	return bodySize
+}
+
+// funcBody prints a function body following a function header of given headerSize.
+// If the header's and block's size are "small enough" and the block is "simple enough",
+// the block is printed on the current line, without line breaks, spaced from the header
+// by sep. Otherwise the block's opening "{" is printed on the current line, followed by
+// lines for the block's statements and its closing "}".
+func (p *printer) funcBody(headerSize int, sep whiteSpace, b *ast.BlockStmt) {
+	if b == nil {
+		return
+	}
+
+	// save/restore composite literal nesting level
+	level := p.level
+	p.level = 0
+
+	if headerSize+p.bodySize(b, oneLineFuncBodySize) <= oneLineFuncBodySize {
+		p.printWS(sep)
+		p.setPos(b.Lbrace)
+		p.printTok("{")
+		if len(b.List) > 0 {
+			p.printWS(blank)
+			for i, s := range b.List {
+				if i > 0 {
+					p.printTok(";")
+					p.printWS(blank)
+				}
+				p.stmt(s, i == len(b.List)-1)
+			}
+			p.printWS(blank)
+		}
+		p.printMode(noExtraLinebreak)
+		p.setPos(b.Rbrace)
+		p.printTok("}")
+		p.printMode(noExtraLinebreak)
+		p.level = level
+		return
+	}
+
+	if sep != ignore {
+		p.printWS(blank) // always use blank
+	}
+	p.block(b, 1)
+	p.level = level
+}
+
+// distanceFrom returns the column difference between p.out (the current output
+// position) and startOutCol. If the start position is on a different line from
+// the current position (or either is unknown), the result is infinity.
+func (p *printer) distanceFrom(startPos token.Pos, startOutCol int) int {
+	if startPos.IsValid() && p.pos.IsValid() && p.posFor(startPos).Line == p.pos.Line {
+		return p.out.Column - startOutCol
+	}
+	return infinity
+}
+
+func (p *printer) funcDecl(d *ast.FuncDecl) {
+	p.setComment(d.Doc)
+	p.setPos(d.TPos)
+	p.printTok("func")
+	p.printWS(blank)
+	// We have to save startCol only after emitting FUNC; otherwise it can be on a
+	// different line (all whitespace preceding the FUNC is emitted only when the
+	// FUNC is emitted).
+	startCol := p.out.Column - len("func ")
+	if d.Recv != nil {
+		p.parameters(d.Recv) // method: print receiver
+		p.printWS(blank)
+	}
+	p.expr(d.Name)
+	p.signature(d.Type)
+	p.funcBody(p.distanceFrom(d.TPos, startCol), vtab, d.Body)
+}
+
+func (p *printer) decl(decl ast.Decl) {
+	switch d := decl.(type) {
+	case *ast.GenDecl:
+		p.genDecl(d)
+	case *ast.FuncDecl:
+		p.funcDecl(d)
+	default:
+		panic("printer: unexpected declaration")
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Files
+
+func declToken(decl ast.Decl) token.Token {
+	switch d := decl.(type) {
+	case *ast.GenDecl:
+		return d.Tok
+	case *ast.FuncDecl:
+		return "func"
+	}
+	return ""
+}
+
+func getDoc(decl ast.Decl) *ast.CommentGroup {
+	switch d := decl.(type) {
+	case *ast.GenDecl:
+		return d.Doc
+	case *ast.FuncDecl:
+		return d.Doc
+	}
+	return nil
+}
+
+func (p *printer) declList(list []ast.Decl) {
+	var tok token.Token
+	for _, d := range list {
+		prev := tok
+		tok = declToken(d)
+		// If the declaration token changed (e.g., from CONST to TYPE)
+		// or the next declaration has documentation associated with it,
+		// print an empty line between top-level declarations.
+		// (because p.linebreak is called with the position of d, which
+		// is past any documentation, the minimum requirement is satisfied
+		// even w/o the extra getDoc(d) nil-check - leave it in case the
+		// linebreak logic improves - there's already a TODO).
+		if len(p.output) > 0 {
+			// only print line break if we are not at the beginning of the output
+			// (i.e., we are not printing only a partial program)
+			min := 1
+			if prev != tok || getDoc(d) != nil {
+				min = 2
+			}
+			// start a new section if the next declaration is a function
+			// that spans multiple lines (see also issue #19544)
+			p.linebreak(p.lineFor(nodePos(d)), min, ignore, tok == "func" && p.numLines(d) > 1)
+		}
+		p.decl(d)
+	}
+}
+
+func (p *printer) file(src *ast.File) {
+	p.setComment(src.Doc)
+	p.setPos(src.Package)
+	p.printTok("package")
+	p.printWS(blank)
+	p.expr(src.Name)
+	p.declList(src.Decls)
+	p.printWS(newline)
+}
@@ -0,0 +1,322 @@
+package printer
+
+import (
+	"github.com/DQNEO/babygo/lib/ast"
+	"github.com/DQNEO/babygo/lib/token"
+)
+
+// nodePos returns the position of the first character belonging to the node.
+func nodePos(node interface{}) token.Pos {
+	switch n := node.(type) {
+	case *ast.Ident:
+		return n.NamePos
+	case *ast.Ellipsis:
+		return n.Ellipsis
+	case *ast.BasicLit:
+		return n.ValuePos
+	case *ast.CompositeLit:
+		if n.Type != nil {
+			return nodePos(n.Type)
+		}
+		return n.Lbrace
+	case *ast.ParenExpr:
+		return n.Lparen
+	case *ast.SelectorExpr:
+		return nodePos(n.X)
+	case *ast.IndexExpr:
+		return nodePos(n.X)
+	case *ast.SliceExpr:
+		return nodePos(n.X)
+	case *ast.TypeAssertExpr:
+		return nodePos(n.X)
+	case *ast.CallExpr:
+		return nodePos(n.Fun)
+	case *ast.StarExpr:
+		return n.Star
+	case *ast.UnaryExpr:
+		return n.OpPos
+	case *ast.BinaryExpr:
+		return nodePos(n.X)
+	case *ast.KeyValueExpr:
+		return nodePos(n.Key)
+	case *ast.ArrayType:
+		return n.Lbrack
+	case *ast.StructType:
+		return n.Struct
+	case *ast.FuncType:
+		if n.Func.IsValid() || n.Params == nil {
+			return n.Func
+		}
+		return n.Params.Opening
+	case *ast.InterfaceType:
+		return n.Interface
+	case *ast.MapType:
+		return n.Map
+
+	case *ast.DeclStmt:
+		return nodePos(n.Decl)
+	case *ast.ExprStmt:
+		return nodePos(n.X)
+	case *ast.IncDecStmt:
+		return nodePos(n.X)
+	case *ast.AssignStmt:
+		return nodePos(n.Lhs[0])
+	case *ast.GoStmt:
+		return n.Go
+	case *ast.ReturnStmt:
+		return n.Return
+	case *ast.BranchStmt:
+		return n.TokPos
+	case *ast.BlockStmt:
+		return n.Lbrace
+	case *ast.IfStmt:
+		return n.If
+	case *ast.CaseClause:
+		return n.Case
+	case *ast.SwitchStmt:
+		return n.Switch
+	case *ast.TypeSwitchStmt:
+		return n.Switch
+	case *ast.ForStmt:
+		return n.For
+	case *ast.RangeStmt:
+		return n.For
+
+	case *ast.ImportSpec:
+		return n.Path.ValuePos
+	case *ast.ValueSpec:
+		return n.Names[0].NamePos
+	case *ast.TypeSpec:
+		return n.Name.NamePos
+
+	case *ast.GenDecl:
+		return n.TokPos
+	case *ast.FuncDecl:
+		return n.TPos
+
+	case *ast.Field:
+		return fieldPos(n)
+	case *ast.File:
+		return n.Package
+	}
+	return token.NoPos
+}
+
+// nodeEnd returns the position of the first character immediately after the node.
+func nodeEnd(node interface{}) token.Pos {
+	switch n := node.(type) {
+	case *ast.Ident:
+		return n.NamePos + token.Pos(len(n.Name))
+	case *ast.Ellipsis:
+		if n.Elt != nil {
+			return nodeEnd(n.Elt)
+		}
+		return n.Ellipsis + 3 // len("...")
+	case *ast.BasicLit:
+		return n.ValuePos + token.Pos(len(n.Value))
+	case *ast.CompositeLit:
+		return n.Rbrace + 1
+	case *ast.ParenExpr:
+		return n.Rparen + 1
+	case *ast.SelectorExpr:
+		return nodeEnd(n.Sel)
+	case *ast.IndexExpr:
+		return n.Rbrack + 1
+	case *ast.SliceExpr:
+		return n.Rbrack + 1
+	case *ast.TypeAssertExpr:
+		return n.Rparen + 1
+	case *ast.CallExpr:
+		return n.Rparen + 1
+	case *ast.StarExpr:
+		return nodeEnd(n.X)
+	case *ast.UnaryExpr:
+		return nodeEnd(n.X)
+	case *ast.BinaryExpr:
+		return nodeEnd(n.Y)
+	case *ast.KeyValueExpr:
+		return nodeEnd(n.Value)
+	case *ast.ArrayType:
+		return nodeEnd(n.Elt)
+	case *ast.StructType:
+		return fieldListEnd(n.Fields)
+	case *ast.FuncType:
+		if n.Results != nil {
+			return fieldListEnd(n.Results)
+		}
+		return fieldListEnd(n.Params)
+	case *ast.InterfaceType:
+		return fieldListEnd(n.Methods)
+	case *ast.MapType:
+		return nodeEnd(n.Value)
+
+	case *ast.DeclStmt:
+		return nodeEnd(n.Decl)
+	case *ast.ExprStmt:
+		return nodeEnd(n.X)
+	case *ast.IncDecStmt:
+		return n.TokPos + 2 // len("++")
+	case *ast.AssignStmt:
+		return nodeEnd(n.Rhs[len(n.Rhs)-1])
+	case *ast.GoStmt:
+		return nodeEnd(n.Call)
+	case *ast.ReturnStmt:
+		if len(n.Results) > 0 {
+			return nodeEnd(n.Results[len(n.Results)-1])
+		}
+		return n.Return + 6 // len("return")
+	case *ast.BranchStmt:
+		return n.TokPos + token.Pos(len(string(n.Tok)))
+	case *ast.BlockStmt:
+		if n.Rbrace.IsValid() {
+			return n.Rbrace + 1
+		}
+		if len(n.List) > 0 {
+			return nodeEnd(n.List[len(n.List)-1])
+		}
+		return n.Lbrace + 1
+	case *ast.IfStmt:
+		if n.Else != nil {
+			return nodeEnd(n.Else)
+		}
+		return nodeEnd(n.Body)
+	case *ast.CaseClause:
+		if len(n.Body) > 0 {
+			return nodeEnd(n.Body[len(n.Body)-1])
+		}
+		return n.Colon + 1
+	case *ast.SwitchStmt:
+		return nodeEnd(n.Body)
+	case *ast.TypeSwitchStmt:
+		return nodeEnd(n.Body)
+	case *ast.ForStmt:
+		return nodeEnd(n.Body)
+	case *ast.RangeStmt:
+		return nodeEnd(n.Body)
+
+	case *ast.ImportSpec:
+		if n.EndPos.IsValid() {
+			return n.EndPos
+		}
+		return nodeEnd(n.Path)
+	case *ast.ValueSpec:
+		if len(n.Values) > 0 {
+			return nodeEnd(n.Values[len(n.Values)-1])
+		}
+		if n.Type != nil {
+			return nodeEnd(n.Type)
+		}
+		return nodeEnd(n.Names[len(n.Names)-1])
+	case *ast.TypeSpec:
+		return nodeEnd(n.Type)
+
+	case *ast.GenDecl:
+		if n.Rparen.IsValid() {
+			return n.Rparen + 1
+		}
+		return nodeEnd(n.Specs[0])
+	case *ast.FuncDecl:
+		if n.Body != nil {
+			return nodeEnd(n.Body)
+		}
+		return nodeEnd(n.Type)
+
+	case *ast.Field:
+		return fieldEnd(n)
+	case *ast.File:
+		if len(n.Decls) > 0 {
+			return nodeEnd(n.Decls[len(n.Decls)-1])
+		}
+		return nodeEnd(n.Name)
+	}
+	return token.NoPos
+}
+
+func fieldPos(f *ast.Field) token.Pos {
+	if len(f.Names) > 0 {
+		return f.Names[0].NamePos
+	}
+	if f.Type != nil {
+		return nodePos(f.Type)
+	}
+	return token.NoPos
+}
+
+func fieldEnd(f *ast.Field) token.Pos {
+	if f.Type != nil {
+		return nodeEnd(f.Type)
+	}
+	if len(f.Names) > 0 {
+		return nodeEnd(f.Names[len(f.Names)-1])
+	}
+	return token.NoPos
+}
+
+func fieldListEnd(f *ast.FieldList) token.Pos {
+	if f.Closing.IsValid() {
+		return f.Closing + 1
+	}
+	// the list should not be empty in this case;
+	// be conservative and guard against bad ASTs
+	n := len(f.List)
+	if n > 0 {
+		return fieldEnd(f.List[n-1])
+	}
+	return token.NoPos
+}
+
+func isDecl(node interface{}) bool {
+	switch node.(type) {
+	case *ast.GenDecl:
+		return true
+	case *ast.FuncDecl:
+		return true
+	}
+	return false
+}
+
+func isSpec(node interface{}) bool {
+	switch node.(type) {
+	case *ast.ImportSpec:
+		return true
+	case *ast.ValueSpec:
+		return true
+	case *ast.TypeSpec:
+		return true
+	}
+	return false
+}
+
+func isStmt(node interface{}) bool {
+	switch node.(type) {
+	case *ast.DeclStmt:
+		return true
+	case *ast.ExprStmt:
+		return true
+	case *ast.IncDecStmt:
+		return true
+	case *ast.AssignStmt:
+		return true
+	case *ast.GoStmt:
+		return true
+	case *ast.ReturnStmt:
+		return true
+	case *ast.BranchStmt:
+		return true
+	case *ast.BlockStmt:
+		return true
+	case *ast.IfStmt:
+		return true
+	case *ast.CaseClause:
+		return true
+	case *ast.SwitchStmt:
+		return true
+	case *ast.TypeSwitchStmt:
+		return true
+	case *ast.ForStmt:
+		return true
+	case *ast.RangeStmt:
+		return true
+	}
+	return false
+}
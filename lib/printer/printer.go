@@ -0,0 +1,1124 @@
+// Package printer implements printing of AST nodes.
+// It is a port of go/printer restricted to the language subset babygo understands.
+package printer
+
+import (
+	"io"
+
+	"github.com/DQNEO/babygo/lib/ast"
+	"github.com/DQNEO/babygo/lib/token"
+)
+
+const maxNewlines int = 2    // max. number of newlines between source text
+const infinity int = 1000000 // larger than any source position
+
+type whiteSpace int
+
+const ignore whiteSpace = 0
+const blank whiteSpace = 32   // ' '
+const vtab whiteSpace = 11    // '\v'
+const newline whiteSpace = 10 // '\n'
+const formfeed whiteSpace = 12
+const indent whiteSpace = 62   // '>'
+const unindent whiteSpace = 60 // '<'
+
+// A pmode value represents the current printer mode.
+type pmode int
+
+const noExtraBlank pmode = 1     // disables extra blank after /*-style comment
+const noExtraLinebreak pmode = 2 // disables extra line break after /*-style comment
+
+// A position describes a location in source or output space.
+type position struct {
+	Offset int // offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // column number, starting at 1 (byte count)
+}
+
+func (pos *position) IsValid() bool {
+	return pos.Line > 0
+}
+
+type printer struct {
+	// Configuration (does not change after initialization)
+	cfg  *Config
+	fset *token.FileSet
+
+	// Current state
+	output       []byte       // raw printer result
+	indent       int          // current indentation
+	level        int          // level == 0: outside composite literal; level > 0: inside composite literal
+	mode         pmode        // current printer mode
+	endAlignment bool         // if set, terminate alignment immediately
+	impliedSemi  bool         // if set, a linebreak implies a semicolon
+	lastTok      token.Token  // last token printed ("" if it's whitespace)
+	prevOpen     token.Token  // previous non-brace "open" token (, [, or ""
+	wsbuf        []whiteSpace // delayed white space
+
+	// Positions
+	pos     position // current position in AST (source) space
+	out     position // current position in output space
+	last    position // value of pos after calling writeString
+	linePtr *int     // if set, record out.Line for the next token in *linePtr
+
+	// The list of all source comments, in order of appearance.
+	comments        []*ast.CommentGroup // may be nil
+	useNodeComments bool                // if not set, ignore lead and line comments of nodes
+
+	// Information about p.comments[p.cindex]; set up by nextComment.
+	cindex         int               // index of the next comment
+	comment        *ast.CommentGroup // = printer.comments[cindex-1]; or nil
+	commentOffset  int               // = printer.posFor(printer.comments[cindex-1].List[0].Pos()).Offset; or infinity
+	commentNewline bool              // true if the comment group contains newlines
+}
+
+func newPrinter(cfg *Config, fset *token.FileSet) *printer {
+	p := &printer{
+		cfg:  cfg,
+		fset: fset,
+	}
+	p.pos.Line = 1
+	p.pos.Column = 1
+	p.out.Line = 1
+	p.out.Column = 1
+	return p
+}
+
+// commentsHaveNewline reports whether a list of comments belonging to
+// an *ast.CommentGroup contains newlines.
+func (p *printer) commentsHaveNewline(list []*ast.Comment) bool {
+	// len(list) > 0
+	line := p.lineFor(list[0].Slash)
+	for i, c := range list {
+		if i > 0 && p.lineFor(list[i].Slash) != line {
+			// not all comments on the same line
+			return true
+		}
+		t := c.Text
+		if len(t) >= 2 && (t[1] == '/' || containsNewline(t)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *printer) nextComment() {
+	for p.cindex < len(p.comments) {
+		c := p.comments[p.cindex]
+		p.cindex++
+		list := c.List
+		if len(list) > 0 {
+			p.comment = c
+			p.commentOffset = p.posFor(list[0].Slash).Offset
+			p.commentNewline = p.commentsHaveNewline(list)
+			return
+		}
+		// we should not reach here (correct ASTs don't have empty
+		// ast.CommentGroup nodes), but be conservative and try again
+	}
+	// no more comments
+	p.commentOffset = infinity
+}
+
+// commentBefore reports whether the current comment group occurs
+// before the next position in the source code and printing it does
+// not introduce implicit semicolons.
+func (p *printer) commentBefore(next *position) bool {
+	return p.commentOffset < next.Offset && (!p.impliedSemi || !p.commentNewline)
+}
+
+// commentSizeBefore returns the estimated size of the
+// comments on the same line before the next position.
+func (p *printer) commentSizeBefore(next *position) int {
+	// save/restore current p.commentInfo (p.nextComment() modifies it)
+	cindex := p.cindex
+	comment := p.comment
+	commentOffset := p.commentOffset
+	commentNewline := p.commentNewline
+
+	size := 0
+	for p.commentBefore(next) {
+		for _, c := range p.comment.List {
+			size = size + len(c.Text)
+		}
+		p.nextComment()
+	}
+
+	p.cindex = cindex
+	p.comment = comment
+	p.commentOffset = commentOffset
+	p.commentNewline = commentNewline
+	return size
+}
+
+// recordLine records the output line number for the next non-whitespace
+// token in *linePtr.
+func (p *printer) recordLine(linePtr *int) {
+	p.linePtr = linePtr
+}
+
+// linesFrom returns the number of output lines between the current
+// output line and the line argument, ignoring any pending (not yet
+// emitted) whitespace or comments.
+func (p *printer) linesFrom(line int) int {
+	return p.out.Line - line
+}
+
+func (p *printer) posFor(pos token.Pos) *position {
+	r := &position{}
+	if !pos.IsValid() {
+		return r
+	}
+	f := p.fset.File(pos)
+	r.Line = f.Line(pos)
+	r.Offset = f.Offset(pos)
+	r.Column = int(pos) - int(f.LineStart(r.Line)) + 1
+	return r
+}
+
+func (p *printer) lineFor(pos token.Pos) int {
+	if !pos.IsValid() {
+		return 0
+	}
+	return p.fset.File(pos).Line(pos)
+}
+
+// writeIndent writes indentation.
+func (p *printer) writeIndent() {
+	n := p.indent
+	for i := 0; i < n; i++ {
+		p.output = append(p.output, '\t')
+	}
+
+	// update positions
+	p.pos.Offset = p.pos.Offset + n
+	p.pos.Column = p.pos.Column + n
+	p.out.Column = p.out.Column + n
+}
+
+// writeByte writes ch n times to p.output and updates p.pos.
+// Only used to write formatting (white space) characters.
+func (p *printer) writeByte(ch byte, n int) {
+	if p.endAlignment {
+		// Ignore any alignment control character;
+		// and at the end of the line, break with
+		// a formfeed to indicate termination of
+		// existing columns.
+		if ch == '\t' || ch == 11 {
+			ch = ' '
+		} else if ch == '\n' || ch == 12 {
+			ch = 12
+			p.endAlignment = false
+		}
+	}
+
+	if p.out.Column == 1 {
+		// no current indentation
+		p.writeIndent()
+	}
+
+	for i := 0; i < n; i++ {
+		p.output = append(p.output, ch)
+	}
+
+	// update positions
+	p.pos.Offset = p.pos.Offset + n
+	if ch == '\n' || ch == 12 {
+		p.pos.Line = p.pos.Line + n
+		p.out.Line = p.out.Line + n
+		p.pos.Column = 1
+		p.out.Column = 1
+		return
+	}
+	p.pos.Column = p.pos.Column + n
+	p.out.Column = p.out.Column + n
+}
+
+// writeString writes the string s to p.output and updates p.pos, p.out,
+// and p.last. If isLit is set, s is escaped w/ tabwriter.Escape characters
+// to protect s from being interpreted by the tabwriter.
+func (p *printer) writeString(pos *position, s string, isLit bool) {
+	if p.out.Column == 1 {
+		// no current indentation
+		p.writeIndent()
+	}
+
+	if pos.IsValid() {
+		// update p.pos (if pos is invalid, continue with existing p.pos)
+		p.pos = *pos
+	}
+
+	if isLit {
+		// Protect s such that is passes through the tabwriter
+		// unchanged. Note that valid Go programs cannot contain
+		// tabwriter.Escape bytes since they do not appear in legal
+		// UTF-8 sequences.
+		p.output = append(p.output, tabwriterEscape)
+	}
+
+	for i := 0; i < len(s); i++ {
+		p.output = append(p.output, s[i])
+	}
+
+	// update positions
+	nlines := 0
+	var li int // index of last newline; valid if nlines > 0
+	for i := 0; i < len(s); i++ {
+		// Raw string literals may cross lines.
+		ch := s[i]
+		if ch == '\n' || ch == 12 {
+			nlines++
+			li = i
+			// If a string literal spans lines, the tabwriter
+			// must not align the text on the following line.
+			p.endAlignment = true
+		}
+	}
+	p.pos.Offset = p.pos.Offset + len(s)
+	if nlines > 0 {
+		p.pos.Line = p.pos.Line + nlines
+		p.out.Line = p.out.Line + nlines
+		c := len(s) - li
+		p.pos.Column = c
+		p.out.Column = c
+	} else {
+		p.pos.Column = p.pos.Column + len(s)
+		p.out.Column = p.out.Column + len(s)
+	}
+
+	if isLit {
+		p.output = append(p.output, tabwriterEscape)
+	}
+
+	p.last = p.pos
+}
+
+// writeCommentPrefix writes the whitespace before a comment.
+// If there is any pending whitespace, it consumes as much of
+// it as is likely to help position the comment nicely.
+// pos is the comment position, next the position of the item
+// after all pending comments, prev is the previous comment in
+// a group of comments (or nil), and tok is the next token.
+func (p *printer) writeCommentPrefix(pos *position, next *position, prev *ast.Comment, tok token.Token) {
+	if len(p.output) == 0 {
+		// the comment is the first item to be printed - don't write any whitespace
+		return
+	}
+
+	if pos.Line == p.last.Line && (prev == nil || prev.Text[1] != '/') {
+		// comment on the same line as last item:
+		// separate with at least one separator
+		hasSep := false
+		if prev == nil {
+			// first comment of a comment group
+			j := 0
+			for i, ch := range p.wsbuf {
+				if ch == blank {
+					// ignore any blanks before a comment
+					p.wsbuf[i] = ignore
+					continue
+				} else if ch == vtab {
+					// respect existing tabs - important
+					// for proper formatting of commented structs
+					hasSep = true
+					continue
+				} else if ch == indent {
+					// apply pending indentation
+					continue
+				}
+				j = i
+				break
+			}
+			p.writeWhitespace(j)
+		}
+		// make sure there is at least one separator
+		if !hasSep {
+			var sep byte = '\t'
+			if pos.Line == next.Line {
+				// next item is on the same line as the comment
+				// (which must be a /*-style comment): separate
+				// with a blank instead of a tab
+				sep = ' '
+			}
+			p.writeByte(sep, 1)
+		}
+
+	} else {
+		// comment on a different line:
+		// separate with at least one line break
+		droppedLinebreak := false
+		j := 0
+		for i, ch := range p.wsbuf {
+			if ch == blank || ch == vtab {
+				// ignore any horizontal whitespace before line breaks
+				p.wsbuf[i] = ignore
+				continue
+			} else if ch == indent {
+				// apply pending indentation
+				continue
+			} else if ch == unindent {
+				// if this is not the last unindent, apply it
+				// as it is (likely) belonging to the last
+				// construct (e.g., a multi-line expression list)
+				// and is not part of closing a block
+				if i+1 < len(p.wsbuf) && p.wsbuf[i+1] == unindent {
+					continue
+				}
+				// if the next token is not a closing }, apply the unindent
+				// if it appears that the comment is aligned with the
+				// token; otherwise assume the unindent is part of a
+				// closing block and stop (this scenario appears with
+				// comments before a case label where the comments
+				// apply to the next case instead of the current one)
+				if tok != "}" && pos.Column == next.Column {
+					continue
+				}
+			} else if ch == newline || ch == formfeed {
+				p.wsbuf[i] = ignore
+				droppedLinebreak = prev == nil // record only if first comment of a group
+			}
+			j = i
+			break
+		}
+		p.writeWhitespace(j)
+
+		// determine number of linebreaks before the comment
+		n := 0
+		if pos.IsValid() && p.last.IsValid() {
+			n = pos.Line - p.last.Line
+			if n < 0 { // should never happen
+				n = 0
+			}
+		}
+
+		// at the package scope level only (p.indent == 0),
+		// add an extra newline if we dropped one before:
+		// this preserves a blank line before documentation
+		// comments at the package scope level (issue 2570)
+		if p.indent == 0 && droppedLinebreak {
+			n++
+		}
+
+		// make sure there is at least one line break
+		// if the previous comment was a line comment
+		if n == 0 && prev != nil && prev.Text[1] == '/' {
+			n = 1
+		}
+
+		if n > 0 {
+			// use formfeeds to break columns before a comment;
+			// this is analogous to using formfeeds to separate
+			// individual lines of /*-style comments
+			p.writeByte(12, nlimit(n))
+		}
+	}
+}
+
+// Returns true if s contains only white space
+// (only tabs and blanks can appear in the printer's context).
+func isBlank(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] > ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+// commonPrefix returns the common prefix of a and b.
+func commonPrefix(a string, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] && (a[i] <= ' ' || a[i] == '*') {
+		i++
+	}
+	return a[0:i]
+}
+
+// trimRight returns s with trailing whitespace removed.
+func trimRight(s string) string {
+	i := len(s)
+	for i > 0 && (s[i-1] == ' ' || s[i-1] == '\t' || s[i-1] == '\n' || s[i-1] == '\r' || s[i-1] == 11 || s[i-1] == 12) {
+		i--
+	}
+	return s[0:i]
+}
+
+func containsNewline(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			return true
+		}
+	}
+	return false
+}
+
+// splitLines splits s at every '\n'.
+func splitLines(s string) []string {
+	var lines []string
+	var start int
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			lines = append(lines, s[start:i])
+			start = i + 1
+		}
+	}
+	lines = append(lines, s[start:])
+	return lines
+}
+
+// stripCommonPrefix removes a common prefix from /*-style comment lines (unless no
+// comment line is indented, all but the first line have some form of space prefix).
+// The prefix is computed using heuristics such that is likely that the comment
+// contents are nicely laid out after re-printing each line using the printer's
+// current indentation.
+func stripCommonPrefix(lines []string) {
+	if len(lines) <= 1 {
+		return // at most one line - nothing to do
+	}
+	// len(lines) > 1
+
+	// The heuristic in this function tries to handle a few
+	// common patterns of /*-style comments: Comments where
+	// the opening /* and closing */ are aligned and the
+	// rest of the comment text is aligned and indented with
+	// blanks or tabs, cases with a vertical "line of stars"
+	// on the left, and cases where the closing */ is on the
+	// same line as the last comment text.
+
+	// Compute maximum common white prefix of all but the first,
+	// last, and blank lines, and replace blank lines with empty
+	// lines (the first line starts with /* and has no prefix).
+	// In cases where only the first and last lines are not blank,
+	// such as two-line comments, or comments where all inner lines
+	// are blank, consider the last line for the prefix computation
+	// since otherwise the prefix would be empty.
+	//
+	// Note that the first and last line are never empty (they
+	// contain the opening /* and closing */ respectively) and
+	// thus they can be ignored by the blank line check.
+	prefix := ""
+	prefixSet := false
+	if len(lines) > 2 {
+		for i := 1; i < len(lines)-1; i++ {
+			line := lines[i]
+			if isBlank(line) {
+				lines[i] = ""
+			} else {
+				if !prefixSet {
+					prefix = line
+					prefixSet = true
+				}
+				prefix = commonPrefix(prefix, line)
+			}
+		}
+	}
+	// If we don't have a prefix yet, consider the last line.
+	if !prefixSet {
+		line := lines[len(lines)-1]
+		prefix = commonPrefix(line, line)
+	}
+
+	// Check for vertical "line of stars" and correct prefix accordingly.
+	lineOfStars := false
+	starIdx := indexByte(prefix, '*')
+	if starIdx >= 0 {
+		// remove trailing blank from prefix so stars remain aligned
+		prefix = prefix[0:starIdx]
+		if len(prefix) > 0 && prefix[len(prefix)-1] == ' ' {
+			prefix = prefix[0 : len(prefix)-1]
+		}
+		lineOfStars = true
+	} else {
+		// No line of stars present.
+		// Determine the white space on the first line after the /*
+		// and before the beginning of the comment text, assume two
+		// blanks instead of the /* unless the first character after
+		// the /* is a tab. If the first comment line is empty but
+		// for the opening /*, assume up to 3 blanks or a tab. This
+		// whitespace may be found as suffix in the common prefix.
+		first := lines[0]
+		if isBlank(first[2:]) {
+			// no comment text on the first line:
+			// reduce prefix by up to 3 blanks or a tab
+			// if present - this keeps comment text indented
+			// relative to the /* and */'s if it was indented
+			// in the first place
+			i := len(prefix)
+			for n := 0; n < 3 && i > 0 && prefix[i-1] == ' '; n++ {
+				i--
+			}
+			if i == len(prefix) && i > 0 && prefix[i-1] == '\t' {
+				i--
+			}
+			prefix = prefix[0:i]
+		} else {
+			// comment text on the first line
+			var suffix []byte
+			suffix = append(suffix, ' ')
+			suffix = append(suffix, ' ')
+			n := 2 // start after opening /*
+			for n < len(first) && first[n] <= ' ' {
+				suffix = append(suffix, first[n])
+				n++
+			}
+			if n > 2 && suffix[2] == '\t' {
+				// assume the '\t' compensates for the /*
+				suffix = suffix[2:n]
+			}
+			// if the suffix is not a prefix of prefix, don't strip it
+			s := string(suffix)
+			if len(s) <= len(prefix) && prefix[len(prefix)-len(s):] == s {
+				prefix = prefix[0 : len(prefix)-len(s)]
+			}
+		}
+	}
+
+	// Handle last line: If it only contains a closing */, align it
+	// with the opening /*, otherwise align the text with the other
+	// lines.
+	last := lines[len(lines)-1]
+	closing := "*/"
+	before := last[0:indexString(last, closing)] // closing always present
+	if isBlank(before) {
+		// last line only contains closing */
+		if lineOfStars {
+			closing = " */" // add blank to align final star
+		}
+		lines[len(lines)-1] = prefix + closing
+	} else {
+		// last line contains more comment text - assume
+		// it is aligned like the other lines and include
+		// in prefix computation
+		prefix = commonPrefix(prefix, last)
+	}
+
+	// Remove the common prefix from all but the first and empty lines.
+	for i, line := range lines {
+		if i > 0 && line != "" {
+			lines[i] = line[len(prefix):]
+		}
+	}
+}
+
+func indexByte(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+func indexString(s string, sub string) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if s[i:i+len(sub)] == sub {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *printer) writeComment(comment *ast.Comment) {
+	text := comment.Text
+	pos := p.posFor(comment.Slash)
+
+	// shortcut common case of //-style comments
+	if text[1] == '/' {
+		p.writeString(pos, trimRight(text), true)
+		return
+	}
+
+	// for /*-style comments, print line by line and let the
+	// write function take care of the proper indentation
+	lines := splitLines(text)
+
+	// The comment started in the first column but is going
+	// to be indented. For an idempotent result, add indentation
+	// to all lines such that they look like they were indented
+	// before - this will make sure the common prefix computation
+	// is the same independent of how many times formatting is
+	// applied (was issue 1835).
+	if pos.IsValid() && pos.Column == 1 && p.indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			lines[i] = "   " + lines[i]
+		}
+	}
+
+	stripCommonPrefix(lines)
+
+	// write comment lines, separated by formfeed,
+	// without a line break after the last line
+	for i, line := range lines {
+		if i > 0 {
+			p.writeByte(12, 1)
+			pos = &position{}
+			*pos = p.pos
+		}
+		if len(line) > 0 {
+			p.writeString(pos, trimRight(line), true)
+		}
+	}
+}
+
+// writeCommentSuffix writes a line break after a comment if indicated
+// and processes any leftover indentation information. If a line break
+// is needed, the kind of break (newline vs formfeed) depends on the
+// pending whitespace. The writeCommentSuffix result indicates if a
+// newline was written or if a formfeed was dropped from the whitespace
+// buffer.
+func (p *printer) writeCommentSuffix(needsLinebreak bool) (bool, bool) {
+	var wroteNewline bool
+	var droppedFF bool
+	for i, ch := range p.wsbuf {
+		if ch == blank || ch == vtab {
+			// ignore trailing whitespace
+			p.wsbuf[i] = ignore
+		} else if ch == indent || ch == unindent {
+			// don't lose indentation information
+		} else if ch == newline || ch == formfeed {
+			// if we need a line break, keep exactly one
+			// but remember if we dropped any formfeeds
+			if needsLinebreak {
+				needsLinebreak = false
+				wroteNewline = true
+			} else {
+				if ch == formfeed {
+					droppedFF = true
+				}
+				p.wsbuf[i] = ignore
+			}
+		}
+	}
+	p.writeWhitespace(len(p.wsbuf))
+
+	// make sure we have a line break
+	if needsLinebreak {
+		p.writeByte('\n', 1)
+		wroteNewline = true
+	}
+
+	return wroteNewline, droppedFF
+}
+
+// containsLinebreak reports whether the whitespace buffer contains any line breaks.
+func (p *printer) containsLinebreak() bool {
+	for _, ch := range p.wsbuf {
+		if ch == newline || ch == formfeed {
+			return true
+		}
+	}
+	return false
+}
+
+// intersperseComments consumes all comments that appear before the next token
+// tok and prints it together with the buffered whitespace (i.e., the whitespace
+// that needs to be written before the next token). A heuristic is used to mix
+// the comments and whitespace. The intersperseComments result indicates if a
+// newline was written or if a formfeed was dropped from the whitespace buffer.
+func (p *printer) intersperseComments(next *position, tok token.Token) (bool, bool) {
+	var last *ast.Comment
+	for p.commentBefore(next) {
+		for _, c := range p.comment.List {
+			p.writeCommentPrefix(p.posFor(c.Slash), next, last, tok)
+			p.writeComment(c)
+			last = c
+		}
+		p.nextComment()
+	}
+
+	if last != nil {
+		// If the last comment is a /*-style comment and the next item
+		// follows on the same line but is not a comma, and not a "closing"
+		// token immediately following its corresponding "opening" token,
+		// add an extra separator unless explicitly disabled. Use a blank
+		// as separator unless we have pending linebreaks, they are not
+		// disabled, and we are outside a composite literal, in which case
+		// we want a linebreak (issue 15137).
+		// TODO(gri) This has become overly complicated. We should be able
+		// to track whether we're inside an expression or statement and
+		// use that information to decide more directly.
+		needsLinebreak := false
+		if p.mode&noExtraBlank == 0 &&
+			last.Text[1] == '*' && p.lineFor(last.Slash) == next.Line &&
+			tok != "," &&
+			(tok != ")" || p.prevOpen == "(") &&
+			(tok != "]" || p.prevOpen == "[") {
+			if p.containsLinebreak() && p.mode&noExtraLinebreak == 0 && p.level == 0 {
+				needsLinebreak = true
+			} else {
+				p.writeByte(' ', 1)
+			}
+		}
+		// Ensure that there is a line break after a //-style comment,
+		// before EOF, and before a closing '}' unless explicitly disabled.
+		if last.Text[1] == '/' ||
+			tok == "EOF" ||
+			tok == "}" && p.mode&noExtraLinebreak == 0 {
+			needsLinebreak = true
+		}
+		wroteNewline, droppedFF := p.writeCommentSuffix(needsLinebreak)
+		return wroteNewline, droppedFF
+	}
+
+	// no comment was written - we should never reach here since
+	// intersperseComments should not be called in that case
+	return false, false
+}
+
+// writeWhitespace writes the first n whitespace entries.
+func (p *printer) writeWhitespace(n int) {
+	// write entries
+	for i := 0; i < n; i++ {
+		ch := p.wsbuf[i]
+		if ch == ignore {
+			// ignore!
+		} else if ch == indent {
+			p.indent++
+		} else if ch == unindent {
+			p.indent--
+			if p.indent < 0 {
+				p.indent = 0
+			}
+		} else if (ch == newline || ch == formfeed) && i+1 < n && p.wsbuf[i+1] == unindent {
+			// A line break immediately followed by a "correcting"
+			// unindent is swapped with the unindent - this permits
+			// proper label positioning. If a comment is between
+			// the line break and the label, the unindent is not
+			// part of the comment whitespace prefix and the comment
+			// will be positioned correctly indented.
+			p.wsbuf[i] = unindent
+			p.wsbuf[i+1] = formfeed
+			i-- // do it again
+		} else {
+			p.writeByte(byte(ch), 1)
+		}
+	}
+
+	// shift remaining entries down
+	var rest []whiteSpace
+	for i := n; i < len(p.wsbuf); i++ {
+		rest = append(rest, p.wsbuf[i])
+	}
+	p.wsbuf = rest
+}
+
+// ----------------------------------------------------------------------------
+// Printing interface
+
+// nlimit limits n to maxNewlines.
+func nlimit(n int) int {
+	if n > maxNewlines {
+		return maxNewlines
+	}
+	return n
+}
+
+func mayCombine(prev token.Token, next byte) bool {
+	switch prev {
+	case "INT":
+		return next == '.' // 1.
+	case "+":
+		return next == '+' // ++
+	case "-":
+		return next == '-' // --
+	case "/":
+		return next == '*' // /*
+	case "<":
+		return next == '-' || next == '<' // <- or <<
+	case "&":
+		return next == '&' || next == '^' // && or &^
+	}
+	return false
+}
+
+func (p *printer) setPos(pos token.Pos) {
+	if pos.IsValid() {
+		p.pos = *p.posFor(pos) // accurate position of next item
+	}
+}
+
+// printWS adds a whiteSpace item to the whitespace buffer.
+func (p *printer) printWS(x whiteSpace) {
+	p.updatePrevOpen()
+	if x == ignore {
+		return
+	}
+	p.wsbuf = append(p.wsbuf, x)
+	if x == newline || x == formfeed {
+		// newlines affect the current state (p.impliedSemi)
+		// and not the state after printing arg (impliedSemi)
+		// because comments can be interspersed before the arg
+		// in this case
+		p.impliedSemi = false
+	}
+	p.lastTok = ""
+}
+
+// printMode toggles the printer mode bits set in x.
+func (p *printer) printMode(x pmode) {
+	p.updatePrevOpen()
+	if x&noExtraBlank != 0 {
+		if p.mode&noExtraBlank != 0 {
+			p.mode = p.mode - noExtraBlank
+		} else {
+			p.mode = p.mode + noExtraBlank
+		}
+	}
+	if x&noExtraLinebreak != 0 {
+		if p.mode&noExtraLinebreak != 0 {
+			p.mode = p.mode - noExtraLinebreak
+		} else {
+			p.mode = p.mode + noExtraLinebreak
+		}
+	}
+}
+
+// printIdent prints an identifier.
+func (p *printer) printIdent(x *ast.Ident) {
+	p.updatePrevOpen()
+	p.lastTok = "IDENT"
+	p.printData(x.Name, false, true)
+}
+
+// printLit prints a basic literal.
+func (p *printer) printLit(x *ast.BasicLit) {
+	p.updatePrevOpen()
+	p.lastTok = x.Kind
+	p.printData(x.Value, true, true)
+}
+
+// printTok prints a token.
+func (p *printer) printTok(x token.Token) {
+	p.updatePrevOpen()
+	s := x.String()
+	if mayCombine(p.lastTok, s[0]) {
+		// the previous and the current token must be
+		// separated by a blank otherwise they combine
+		// into a different incorrect token sequence
+		// (except for token.INT followed by a '.' this
+		// should never happen because it is taken care
+		// of via binary expression formatting)
+		p.wsbuf = []whiteSpace{blank}
+	}
+	var impliedSemi bool
+	switch x {
+	case "break", "continue", "fallthrough", "return", "++", "--", ")", "]", "}":
+		impliedSemi = true
+	}
+	p.lastTok = x
+	p.printData(s, false, impliedSemi)
+}
+
+// printString prints a string verbatim, e.g. a comment.
+func (p *printer) printString(x string) {
+	p.updatePrevOpen()
+	p.lastTok = "STRING"
+	p.printData(x, true, true)
+}
+
+func (p *printer) updatePrevOpen() {
+	if p.lastTok == "" {
+		// ignore (white space)
+	} else if p.lastTok == "(" || p.lastTok == "[" {
+		p.prevOpen = p.lastTok
+	} else {
+		// other tokens followed any opening token
+		p.prevOpen = ""
+	}
+}
+
+// printData prints data, taking care of interspersed comments
+// and pending whitespace.
+func (p *printer) printData(data string, isLit bool, impliedSemi bool) {
+	// intersperse extra newlines if present in the source and
+	// if they don't cause extra semicolons (don't do this in
+	// flush as it will cause extra newlines at the end of a file)
+	next := &position{}
+	*next = p.pos // estimated/accurate position of next item
+	wroteNewline, droppedFF := p.flush(next, p.lastTok)
+
+	// intersperse extra newlines if present in the source and
+	// if they don't cause extra semicolons (don't do this in
+	// flush as it will cause extra newlines at the end of a file)
+	if !p.impliedSemi {
+		n := nlimit(next.Line - p.pos.Line)
+		// don't exceed maxNewlines if we already wrote one
+		if wroteNewline && n == maxNewlines {
+			n = maxNewlines - 1
+		}
+		if n > 0 {
+			var ch byte = '\n'
+			if droppedFF {
+				ch = 12 // use formfeed since we dropped one before
+			}
+			p.writeByte(ch, n)
+			impliedSemi = false
+		}
+	}
+
+	// the next token starts now - record its line number if requested
+	if p.linePtr != nil {
+		*p.linePtr = p.out.Line
+		p.linePtr = nil
+	}
+
+	p.writeString(next, data, isLit)
+	p.impliedSemi = impliedSemi
+}
+
+// flush prints any pending comments and whitespace occurring textually
+// before the position of the next token tok. The flush result indicates
+// if a newline was written or if a formfeed was dropped from the whitespace
+// buffer.
+func (p *printer) flush(next *position, tok token.Token) (bool, bool) {
+	var wroteNewline bool
+	var droppedFF bool
+	if p.commentBefore(next) {
+		// if there are comments before the next item, intersperse them
+		wroteNewline, droppedFF = p.intersperseComments(next, tok)
+	} else {
+		// otherwise, write any leftover whitespace
+		p.writeWhitespace(len(p.wsbuf))
+	}
+	return wroteNewline, droppedFF
+}
+
+func (p *printer) printNode(node interface{}) {
+	f, isFile := node.(*ast.File)
+	if isFile {
+		p.comments = f.Comments
+	}
+
+	// if there are no comments, use node comments
+	p.useNodeComments = len(p.comments) == 0
+
+	// get comments ready for use
+	p.nextComment()
+
+	p.printMode(0)
+
+	// format node
+	if isFile {
+		p.file(f)
+	} else if isDecl(node) {
+		p.decl(node)
+	} else if isSpec(node) {
+		p.spec(node, 1, false)
+	} else if isStmt(node) {
+		p.stmt(node, false)
+	} else {
+		p.expr(node)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Trimmer
+
+// trim strips trailing whitespace (blanks and tabs) from each line,
+// converts formfeed and vtab characters into newlines and htabs,
+// and removes the tabwriter.Escape characters protecting text
+// segments from being interpreted by the tabwriter.
+func trim(data []byte) []byte {
+	var r []byte
+	var space []byte
+	var inEscape bool
+	for _, b := range data {
+		if b == 11 {
+			b = '\t' // convert to htab
+		}
+		if inEscape {
+			if b == tabwriterEscape {
+				inEscape = false
+			} else {
+				r = append(r, b)
+			}
+			continue
+		}
+		if b == '\t' || b == ' ' {
+			space = append(space, b)
+		} else if b == '\n' || b == 12 {
+			space = nil // discard trailing space
+			r = append(r, '\n')
+		} else if b == tabwriterEscape {
+			for _, s := range space {
+				r = append(r, s)
+			}
+			space = nil
+			inEscape = true
+		} else {
+			for _, s := range space {
+				r = append(r, s)
+			}
+			space = nil
+			r = append(r, b)
+		}
+	}
+	for _, s := range space {
+		r = append(r, s)
+	}
+	return r
+}
+
+// ----------------------------------------------------------------------------
+// Public interface
+
+// A Mode value is a set of flags (or 0). They control printing.
+type Mode int
+
+var RawFormat Mode = 1 // do not use a tabwriter; if set, UseSpaces is ignored
+var TabIndent Mode = 2 // use tabs for indentation independent of UseSpaces
+var UseSpaces Mode = 4 // use spaces instead of tabs for alignment
+
+// A Config node controls the output of Fprint.
+type Config struct {
+	Mode     Mode // default: 0
+	Tabwidth int  // default: 8
+}
+
+// format formats node and returns the resulting bytes.
+func (cfg *Config) format(fset *token.FileSet, node interface{}) []byte {
+	p := newPrinter(cfg, fset)
+	p.printNode(node)
+	p.impliedSemi = false // EOF acts like a newline
+	eof := &position{Offset: infinity, Line: infinity}
+	p.flush(eof, "EOF")
+
+	// redirect output through a tabwriter if necessary
+	output := p.output
+	if cfg.Mode&RawFormat == 0 {
+		minwidth := cfg.Tabwidth
+
+		var padchar byte = '\t'
+		if cfg.Mode&UseSpaces != 0 {
+			padchar = ' '
+		}
+
+		twmode := tabwriterDiscardEmptyColumns
+		if cfg.Mode&TabIndent != 0 {
+			minwidth = 0
+			twmode = twmode | tabwriterTabIndent
+		}
+
+		tw := newTabwriter(minwidth, cfg.Tabwidth, 1, padchar, twmode)
+		output = tw.format(output)
+	}
+
+	return trim(output)
+}
+
+// Fprint "pretty-prints" an AST node to output for a given configuration cfg.
+// Position information is interpreted relative to the file set fset.
+// The node type must be *ast.File, or assignment-compatible to ast.Expr,
+// ast.Decl, ast.Spec, or ast.Stmt.
+func (cfg *Config) Fprint(output io.Writer, fset *token.FileSet, node interface{}) error {
+	b := cfg.format(fset, node)
+	_, err := output.Write(b)
+	return err
+}
+
+// Fprint "pretty-prints" an AST node to output.
+// It calls Config.Fprint with default settings.
+func Fprint(output io.Writer, fset *token.FileSet, node interface{}) error {
+	cfg := &Config{Tabwidth: 8}
+	return cfg.Fprint(output, fset, node)
+}
@@ -0,0 +1,334 @@
+package printer
+
+// This file is a port of text/tabwriter, reduced to what the printer needs.
+// Instead of filtering an io.Writer stream it formats a complete buffer.
+
+// To escape a text segment, bracket it with Escape characters.
+// For instance, the tab in this string "Ignore this tab: \xff\t\xff"
+// does not terminate a cell and constitutes a single character of
+// width one for formatting purposes.
+//
+// The value 0xff was chosen because it cannot appear in a valid UTF-8 sequence.
+const tabwriterEscape byte = 255
+
+// Formatting can be controlled with these flags.
+type tabwriterFlags int
+
+// Handle empty columns as if they were not present in
+// the input in the first place.
+const tabwriterDiscardEmptyColumns tabwriterFlags = 8
+
+// Always use tabs for indentation columns (i.e., padding of
+// leading empty cells on the left) independent of padchar.
+const tabwriterTabIndent tabwriterFlags = 16
+
+// A cell represents a segment of text terminated by tabs or line breaks.
+// The text itself is stored in a separate buffer; cell only describes the
+// segment's size in bytes, its width in runes, and whether it's an htab
+// ('\t') terminated cell.
+type cell struct {
+	size  int  // cell size in bytes
+	width int  // cell width in runes
+	htab  bool // true if the cell is terminated by an htab ('\t')
+}
+
+// A tabwriter is a filter that inserts padding around tab-delimited
+// columns in its input to align them in the output.
+//
+// The text is assumed to be UTF-8 encoded. Tab-terminated cells in
+// contiguous lines constitute a column. Padding is inserted as needed
+// to make all cells in a column have the same width.
+type tabwriter struct {
+	// configuration
+	minwidth int
+	tabwidth int
+	padding  int
+	padchar  byte
+	flags    tabwriterFlags
+
+	// current state
+	output  []byte    // formatted result
+	buf     []byte    // collected text excluding tabs or line breaks
+	pos     int       // buffer position up to which cell.width of incomplete cell has been computed
+	cell    *cell     // current incomplete cell; cell.width is up to buf[pos] excluding ignored sections
+	escaped bool      // true if inside an escaped text segment
+	lines   [][]*cell // list of lines; each line is a list of cells
+	widths  []int     // list of column widths in runes - re-used during formatting
+}
+
+func newTabwriter(minwidth int, tabwidth int, padding int, padchar byte, flags tabwriterFlags) *tabwriter {
+	if minwidth < 0 || tabwidth < 0 || padding < 0 {
+		panic("negative minwidth, tabwidth, or padding")
+	}
+	b := &tabwriter{
+		minwidth: minwidth,
+		tabwidth: tabwidth,
+		padding:  padding,
+		padchar:  padchar,
+		flags:    flags,
+	}
+	b.reset()
+	return b
+}
+
+// addLine adds a new line.
+func (b *tabwriter) addLine() {
+	var line []*cell
+	b.lines = append(b.lines, line)
+}
+
+// Reset the current state.
+func (b *tabwriter) reset() {
+	b.buf = nil
+	b.pos = 0
+	b.cell = &cell{}
+	b.escaped = false
+	b.lines = nil
+	b.widths = nil
+	b.addLine()
+}
+
+func (b *tabwriter) write0(buf []byte) {
+	for _, ch := range buf {
+		b.output = append(b.output, ch)
+	}
+}
+
+func (b *tabwriter) writeN(ch byte, n int) {
+	for i := 0; i < n; i++ {
+		b.output = append(b.output, ch)
+	}
+}
+
+func (b *tabwriter) writePadding(textw int, cellw int, useTabs bool) {
+	if b.padchar == '\t' || useTabs {
+		// padding is done with tabs
+		if b.tabwidth == 0 {
+			return // tabs have no width - can't do any padding
+		}
+		// make cellw the smallest multiple of b.tabwidth
+		cellw = (cellw + b.tabwidth - 1) / b.tabwidth * b.tabwidth
+		n := cellw - textw // amount of padding
+		if n < 0 {
+			panic("internal error")
+		}
+		b.writeN('\t', (n+b.tabwidth-1)/b.tabwidth)
+		return
+	}
+
+	// padding is done with non-tab characters
+	b.writeN(b.padchar, cellw-textw)
+}
+
+func (b *tabwriter) writeLines(pos0 int, line0 int, line1 int) int {
+	pos := pos0
+	for i := line0; i < line1; i++ {
+		line := b.lines[i]
+
+		// if TabIndent is set, use tabs to pad leading empty cells
+		useTabs := b.flags&tabwriterTabIndent != 0
+
+		for j, c := range line {
+			if c.size == 0 {
+				// empty cell
+				if j < len(b.widths) {
+					b.writePadding(c.width, b.widths[j], useTabs)
+				}
+			} else {
+				// non-empty cell
+				useTabs = false
+				// align left
+				b.write0(b.buf[pos : pos+c.size])
+				pos = pos + c.size
+				if j < len(b.widths) {
+					b.writePadding(c.width, b.widths[j], false)
+				}
+			}
+		}
+
+		if i+1 == len(b.lines) {
+			// last buffered line - we don't have a newline, so just write
+			// any outstanding buffered data
+			b.write0(b.buf[pos : pos+b.cell.size])
+			pos = pos + b.cell.size
+		} else {
+			// not the last line - write newline
+			b.output = append(b.output, '\n')
+		}
+	}
+	return pos
+}
+
+// Format the text between line0 and line1 (excluding line1); pos
+// is the buffer position corresponding to the beginning of line0.
+// Returns the buffer position corresponding to the beginning of
+// line1.
+func (b *tabwriter) formatLines(pos0 int, line0 int, line1 int) int {
+	pos := pos0
+	column := len(b.widths)
+	for this := line0; this < line1; this++ {
+		line := b.lines[this]
+
+		if column >= len(line)-1 {
+			continue
+		}
+		// cell exists in this column => this line
+		// has more cells than the previous line
+		// (the last cell per line is ignored because cells are
+		// tab-terminated; the last cell per line describes the
+		// text before the newline/formfeed and does not belong
+		// to a column)
+
+		// print unprinted lines until beginning of block
+		pos = b.writeLines(pos, line0, this)
+		line0 = this
+
+		// column block begin
+		width := b.minwidth // minimal column width
+		discardable := true // true if all cells in this column are empty and "soft"
+		for this < line1 {
+			line = b.lines[this]
+			if column >= len(line)-1 {
+				break
+			}
+			// cell exists in this column
+			c := line[column]
+			// update width
+			w := c.width + b.padding
+			if w > width {
+				width = w
+			}
+			// update discardable
+			if c.width > 0 || c.htab {
+				discardable = false
+			}
+			this++
+		}
+		// column block end
+
+		// discard empty columns if necessary
+		if discardable && b.flags&tabwriterDiscardEmptyColumns != 0 {
+			width = 0
+		}
+
+		// format and print all columns to the right of this column
+		// (we know the widths of this column and all columns to the left)
+		b.widths = append(b.widths, width) // push width
+		pos = b.formatLines(pos, line0, this)
+		b.widths = b.widths[0 : len(b.widths)-1] // pop width
+		line0 = this
+		this-- // compensate for the loop increment
+	}
+
+	// print unprinted lines until end
+	return b.writeLines(pos, line0, line1)
+}
+
+// Append text to current cell.
+func (b *tabwriter) append(text []byte) {
+	for _, ch := range text {
+		b.buf = append(b.buf, ch)
+	}
+	b.cell.size = b.cell.size + len(text)
+}
+
+// Update the cell width.
+func (b *tabwriter) updateWidth() {
+	b.cell.width = b.cell.width + runeCountBytes(b.buf[b.pos:])
+	b.pos = len(b.buf)
+}
+
+// Terminate the current cell by adding it to the list of cells of the
+// current line. Returns the number of cells in that line.
+func (b *tabwriter) terminateCell(htab bool) int {
+	b.cell.htab = htab
+	n := len(b.lines) - 1
+	b.lines[n] = append(b.lines[n], b.cell)
+	b.cell = &cell{}
+	return len(b.lines[n])
+}
+
+func (b *tabwriter) flushLines() {
+	// add current cell if not empty
+	if b.cell.size > 0 {
+		if b.escaped {
+			b.endEscape()
+		}
+		b.terminateCell(false)
+	}
+
+	// format contents of buffer
+	b.formatLines(0, 0, len(b.lines))
+	b.reset()
+}
+
+func (b *tabwriter) endEscape() {
+	b.updateWidth()
+	b.cell.width = b.cell.width - 2 // don't count the Escape chars
+	b.pos = len(b.buf)
+	b.escaped = false
+}
+
+// format formats buf and returns the result.
+func (b *tabwriter) format(buf []byte) []byte {
+	n := 0
+	for i, ch := range buf {
+		if !b.escaped {
+			// inside text segment
+			if ch == '\t' || ch == 11 || ch == '\n' || ch == 12 {
+				// end of cell
+				b.append(buf[n:i])
+				b.updateWidth()
+				n = i + 1 // ch consumed
+				ncells := b.terminateCell(ch == '\t')
+				if ch == '\n' || ch == 12 {
+					// terminate line
+					b.addLine()
+					if ch == 12 || ncells == 1 {
+						// A '\f' always forces a flush. Otherwise, if the previous
+						// line has only one cell which does not have an impact on
+						// the formatting of the following lines (the last cell per
+						// line is ignored by format()), thus we can flush the
+						// tabwriter contents.
+						b.flushLines()
+					}
+				}
+			} else if ch == tabwriterEscape {
+				// start of escaped sequence
+				b.append(buf[n:i])
+				b.updateWidth()
+				n = i
+				b.escaped = true
+			}
+		} else {
+			// inside escaped segment
+			if ch == tabwriterEscape {
+				// end of escaped sequence
+				b.append(buf[n : i+1])
+				n = i + 1 // ch consumed
+				b.endEscape()
+			}
+		}
+	}
+
+	// append leftover text
+	b.append(buf[n:])
+	b.flushLines()
+	return b.output
+}
+
+// runeCount returns the number of runes in the UTF-8 encoded string s.
+func runeCount(s string) int {
+	return runeCountBytes([]byte(s))
+}
+
+func runeCountBytes(buf []byte) int {
+	n := 0
+	for _, ch := range buf {
+		// count every byte that is not a continuation byte
+		if ch < 128 || ch >= 192 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,378 @@
+package strconv
+
+import "errors"
+
+const lowerhex string = "0123456789abcdef"
+
+const runeError int = 65533 // U+FFFD, the replacement character
+const maxRune int = 1114111 // U+10FFFF
+
+// DecodeRune decodes the UTF-8 encoded rune at s[i:] and returns it and its width in bytes.
+// An invalid or truncated encoding is returned as (U+FFFD, 1).
+func DecodeRune(s string, i int) (int, int) {
+	c := int(s[i])
+	if c < 128 {
+		return c, 1
+	}
+	var n int
+	var r int
+	var min int
+	if c >= 192 && c < 224 {
+		n = 2
+		r = c - 192
+		min = 128
+	} else if c >= 224 && c < 240 {
+		n = 3
+		r = c - 224
+		min = 2048
+	} else if c >= 240 && c < 248 {
+		n = 4
+		r = c - 240
+		min = 65536
+	} else {
+		return runeError, 1
+	}
+	if i+n > len(s) {
+		return runeError, 1
+	}
+	for j := 1; j < n; j++ {
+		cc := int(s[i+j])
+		if cc < 128 || cc >= 192 {
+			return runeError, 1
+		}
+		r = r*64 + cc - 128
+	}
+	if r < min || !validRune(r) {
+		return runeError, 1
+	}
+	return r, n
+}
+
+// AppendRune appends the UTF-8 encoding of r to buf. An invalid rune is encoded as U+FFFD.
+func AppendRune(buf []uint8, r int) []uint8 {
+	if !validRune(r) {
+		r = runeError
+	}
+	if r < 128 {
+		return append(buf, uint8(r))
+	}
+	if r < 2048 {
+		buf = append(buf, uint8(192+r/64))
+		return append(buf, uint8(128+r%64))
+	}
+	if r < 65536 {
+		buf = append(buf, uint8(224+r/4096))
+		buf = append(buf, uint8(128+r/64%64))
+		return append(buf, uint8(128+r%64))
+	}
+	buf = append(buf, uint8(240+r/262144))
+	buf = append(buf, uint8(128+r/4096%64))
+	buf = append(buf, uint8(128+r/64%64))
+	return append(buf, uint8(128+r%64))
+}
+
+func validRune(r int) bool {
+	if r < 0 || r > maxRune {
+		return false
+	}
+	return r < 55296 || r > 57343 // not a surrogate half
+}
+
+// IsPrint reports whether the rune is printable.
+// Besides the ASCII control characters, only the C1 controls and the soft hyphen are deemed unprintable.
+func IsPrint(r int) bool {
+	if r < 32 || r >= 127 && r < 160 || r == 173 {
+		return false
+	}
+	return validRune(r)
+}
+
+// Quote returns a double-quoted Go string literal representing s.
+// The returned string uses Go escape sequences (\t, \n, \xFF, \u0100) for control characters and non-printable characters.
+func Quote(s string) string {
+	return string(appendQuotedWith(nil, s, '"', false))
+}
+
+// QuoteToASCII returns a double-quoted Go string literal representing s,
+// which uses Go escape sequences for non-ASCII characters as well.
+func QuoteToASCII(s string) string {
+	return string(appendQuotedWith(nil, s, '"', true))
+}
+
+// QuoteRune returns a single-quoted Go character literal representing the rune.
+func QuoteRune(r int) string {
+	return string(appendQuotedRuneWith(nil, r, '\'', false))
+}
+
+// QuoteRuneToASCII returns a single-quoted Go character literal representing the rune,
+// which uses Go escape sequences for non-ASCII characters as well.
+func QuoteRuneToASCII(r int) string {
+	return string(appendQuotedRuneWith(nil, r, '\'', true))
+}
+
+// CanBackquote reports whether the string s can be represented unchanged as a single-line backquoted string
+// without control characters other than tab.
+func CanBackquote(s string) bool {
+	for i := 0; i < len(s); {
+		r, width := DecodeRune(s, i)
+		i += width
+		if width > 1 {
+			if r == 65279 { // U+FEFF, the byte order mark
+				return false
+			}
+			continue
+		}
+		if r == runeError {
+			return false
+		}
+		if (r < ' ' && r != '\t') || r == '`' || r == 127 {
+			return false
+		}
+	}
+	return true
+}
+
+func appendQuotedWith(buf []uint8, s string, quote uint8, asciiOnly bool) []uint8 {
+	buf = append(buf, quote)
+	for i := 0; i < len(s); {
+		r, width := DecodeRune(s, i)
+		if width == 1 && r == runeError {
+			buf = append(buf, '\\')
+			buf = append(buf, 'x')
+			buf = append(buf, lowerhex[int(s[i])/16])
+			buf = append(buf, lowerhex[int(s[i])%16])
+			i++
+			continue
+		}
+		buf = appendEscapedRune(buf, r, quote, asciiOnly)
+		i += width
+	}
+	return append(buf, quote)
+}
+
+func appendQuotedRuneWith(buf []uint8, r int, quote uint8, asciiOnly bool) []uint8 {
+	buf = append(buf, quote)
+	if !validRune(r) {
+		r = runeError
+	}
+	buf = appendEscapedRune(buf, r, quote, asciiOnly)
+	return append(buf, quote)
+}
+
+func appendEscapedRune(buf []uint8, r int, quote uint8, asciiOnly bool) []uint8 {
+	if r == int(quote) || r == '\\' { // always backslashed
+		buf = append(buf, '\\')
+		return append(buf, uint8(r))
+	}
+	if asciiOnly {
+		if r < 128 && IsPrint(r) {
+			return append(buf, uint8(r))
+		}
+	} else if IsPrint(r) {
+		return AppendRune(buf, r)
+	}
+	var esc uint8
+	switch r {
+	case 7:
+		esc = 'a'
+	case 8:
+		esc = 'b'
+	case 12:
+		esc = 'f'
+	case '\n':
+		esc = 'n'
+	case '\r':
+		esc = 'r'
+	case '\t':
+		esc = 't'
+	case 11:
+		esc = 'v'
+	}
+	if esc != 0 {
+		buf = append(buf, '\\')
+		return append(buf, esc)
+	}
+	if r < ' ' || r == 127 {
+		buf = append(buf, '\\')
+		buf = append(buf, 'x')
+		buf = append(buf, lowerhex[r/16])
+		return append(buf, lowerhex[r%16])
+	}
+	if !validRune(r) {
+		r = runeError
+	}
+	n := 4
+	buf = append(buf, '\\')
+	if r < 65536 {
+		buf = append(buf, 'u')
+	} else {
+		buf = append(buf, 'U')
+		n = 8
+	}
+	for s := n - 1; s >= 0; s-- {
+		d := r
+		for j := 0; j < s; j++ {
+			d = d / 16
+		}
+		buf = append(buf, lowerhex[d%16])
+	}
+	return buf
+}
+
+// ErrSyntax indicates that a value does not have the right syntax for the target type.
+var ErrSyntax = errors.New("invalid syntax")
+
+// Unquote interprets s as a single-quoted, double-quoted, or backquoted Go string literal,
+// returning the string value that s quotes.
+// (If s is single-quoted, it would be a Go character literal; Unquote returns the corresponding one-character string.)
+func Unquote(s string) (string, error) {
+	n := len(s)
+	if n < 2 {
+		return "", ErrSyntax
+	}
+	quote := s[0]
+	if quote != s[n-1] {
+		return "", ErrSyntax
+	}
+	s = s[1 : n-1]
+
+	if quote == '`' {
+		var buf []uint8
+		for i := 0; i < len(s); i++ {
+			if s[i] == '`' {
+				return "", ErrSyntax
+			}
+			// carriage returns are removed from raw strings
+			if s[i] != '\r' {
+				buf = append(buf, s[i])
+			}
+		}
+		return string(buf), nil
+	}
+	if quote != '"' && quote != '\'' {
+		return "", ErrSyntax
+	}
+
+	var buf []uint8
+	var nrunes int
+	for i := 0; i < len(s); {
+		value, multibyte, next, err := unquoteChar(s, i, quote)
+		if err != nil {
+			return "", err
+		}
+		i = next
+		if value < 128 || !multibyte {
+			buf = append(buf, uint8(value))
+		} else {
+			buf = AppendRune(buf, value)
+		}
+		nrunes++
+	}
+	if quote == '\'' && nrunes != 1 {
+		// single-quoted must be a single character
+		return "", ErrSyntax
+	}
+	return string(buf), nil
+}
+
+// unquoteChar decodes the character or escape sequence at s[i:] of a string literal quoted by quote.
+// It returns the decoded value, whether it is a character rather than a byte, and the index of the rest of s.
+func unquoteChar(s string, i int, quote uint8) (int, bool, int, error) {
+	c := s[i]
+	if c == quote || c == '\n' {
+		return 0, false, i, ErrSyntax
+	}
+	if c >= 128 {
+		r, size := DecodeRune(s, i)
+		return r, true, i + size, nil
+	}
+	if c != '\\' {
+		return int(c), false, i + 1, nil
+	}
+
+	// hard case: c is backslash
+	if i+1 >= len(s) {
+		return 0, false, i, ErrSyntax
+	}
+	c = s[i+1]
+	i = i + 2
+	switch c {
+	case 'a':
+		return 7, false, i, nil
+	case 'b':
+		return 8, false, i, nil
+	case 'f':
+		return 12, false, i, nil
+	case 'n':
+		return '\n', false, i, nil
+	case 'r':
+		return '\r', false, i, nil
+	case 't':
+		return '\t', false, i, nil
+	case 'v':
+		return 11, false, i, nil
+	case 'x', 'u', 'U':
+		n := 2
+		if c == 'u' {
+			n = 4
+		} else if c == 'U' {
+			n = 8
+		}
+		if i+n > len(s) {
+			return 0, false, i, ErrSyntax
+		}
+		var v int
+		for j := 0; j < n; j++ {
+			x, ok := unhex(s[i+j])
+			if !ok {
+				return 0, false, i, ErrSyntax
+			}
+			v = v*16 + x
+		}
+		if c == 'x' {
+			// single-byte string, possibly not UTF-8
+			return v, false, i + n, nil
+		}
+		if !validRune(v) {
+			return 0, false, i, ErrSyntax
+		}
+		return v, true, i + n, nil
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		v := int(c - '0')
+		if i+2 > len(s) {
+			return 0, false, i, ErrSyntax
+		}
+		for j := 0; j < 2; j++ { // one digit already; two more
+			x := int(s[i+j]) - '0'
+			if x < 0 || x > 7 {
+				return 0, false, i, ErrSyntax
+			}
+			v = v*8 + x
+		}
+		if v > 255 {
+			return 0, false, i, ErrSyntax
+		}
+		return v, false, i + 2, nil
+	case '\\':
+		return '\\', false, i, nil
+	case '\'', '"':
+		if c != quote {
+			return 0, false, i, ErrSyntax
+		}
+		return int(c), false, i, nil
+	}
+	return 0, false, i, ErrSyntax
+}
+
+func unhex(c uint8) (int, bool) {
+	if '0' <= c && c <= '9' {
+		return int(c - '0'), true
+	}
+	if 'a' <= c && c <= 'f' {
+		return int(c-'a') + 10, true
+	}
+	if 'A' <= c && c <= 'F' {
+		return int(c-'A') + 10, true
+	}
+	return 0, false
+}
@@ -22,6 +22,9 @@ func Split(s string, ssep string) []string {
 }
 
 func HasPrefix(s string, prefix string) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
 	for i, bp := range []byte(prefix) {
 		if bp != s[i] {
 			return false
@@ -60,30 +63,14 @@ func Contains(s string, substr string) bool {
 	return Index(s, substr) >= 0
 }
 
+// Index returns the index of the first instance of substr in s, or -1 if substr is not present in s.
 func Index(s string, substr string) int {
-	var in bool
-	var subIndex int
-	var r int = -1 // not found
-	for i, b := range []byte(s) {
-		if !in && b == substr[0] {
-			in = true
-			r = i
-			subIndex = 0
-		}
-
-		if in {
-			if b == substr[subIndex] {
-				if subIndex == len(substr)-1 {
-					return r
-				}
-			} else {
-				in = false
-				r = -1
-				subIndex = 0
-			}
+	n := len(substr)
+	for i := 0; i+n <= len(s); i++ {
+		if s[i:i+n] == substr {
+			return i
 		}
 	}
-
 	return -1
 }
 
@@ -97,3 +84,18 @@ func LastIndexByte(s string, c uint8) int {
 	// not found
 	return -1
 }
+
+func isSpace(c uint8) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// TrimSpace returns s with leading and trailing white space removed.
+func TrimSpace(s string) string {
+	for len(s) > 0 && isSpace(s[0]) {
+		s = s[1:]
+	}
+	for len(s) > 0 && isSpace(s[len(s)-1]) {
+		s = s[0 : len(s)-1]
+	}
+	return s
+}
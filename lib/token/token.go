@@ -1,9 +1,5 @@
 package token
 
-import (
-	"github.com/DQNEO/babygo/lib/fmt"
-)
-
 type Token string
 type Pos int
 
@@ -13,6 +9,10 @@ var STRING Token = "STRING"
 
 var NoPos Pos = 0
 
+func (p Pos) IsValid() bool {
+	return p != NoPos
+}
+
 // Token
 var ADD Token = "+"
 var SUB Token = "-"
@@ -22,6 +22,29 @@ func (tok Token) String() string {
 	return string(tok)
 }
 
+// A set of constants for precedence-based expression parsing.
+var LowestPrec int = 0
+var UnaryPrec int = 6
+var HighestPrec int = 7
+
+// Precedence returns the operator precedence of the binary operator op.
+// If op is not a binary operator, the result is LowestPrec.
+func (op Token) Precedence() int {
+	switch op {
+	case "||":
+		return 1
+	case "&&":
+		return 2
+	case "==", "!=", "<", "<=", ">", ">=":
+		return 3
+	case "+", "-", "|", "^":
+		return 4
+	case "*", "/", "%", "<<", ">>", "&", "&^":
+		return 5
+	}
+	return LowestPrec
+}
+
 type File struct {
 	Name  string // absolute path
 	Base  int
@@ -54,28 +77,68 @@ func (fs *FileSet) AddFile(filename string, base int, size int) *File {
 	return f
 }
 
-func (fs *FileSet) Position(pos Pos) string {
+// File returns the file that contains the position p.
+func (fs *FileSet) File(p Pos) *File {
 	var currentFile *File
 	if len(fs.Files) > 0 {
 		currentFile = fs.Files[0]
 	}
 	for _, nextFile := range fs.Files[1:] {
-		if int(pos) < nextFile.Base {
+		if int(p) < nextFile.Base {
 			break
 		}
 		currentFile = nextFile
 	}
+	return currentFile
+}
+
+// Position describes an arbitrary source position
+// including the file, line, and column location.
+type Position struct {
+	Filename string // filename, if any
+	Offset   int    // offset, starting at 0
+	Line     int    // line number, starting at 1
+	Column   int    // column number, starting at 1 (byte count)
+}
+
+// Position converts a Pos p in the fileset into a Position value.
+func (fs *FileSet) Position(pos Pos) *Position {
+	currentFile := fs.File(pos)
 
 	// debug:
 	//	fmt.Fprintf(os.Stderr, "[token.Position] currentFile=%s, firstPos=%d\n", currentFile.Name, int(currentFile.Lines[0]))
 
-	var currentLineNo int = 1 // Line starts from 1
-	for id, nextLinePos := range currentFile.Lines {
-		if pos < nextLinePos {
-			break
+	line := currentFile.Line(pos)
+	return &Position{
+		Filename: currentFile.Name,
+		Offset:   currentFile.Offset(pos),
+		Line:     line,
+		Column:   int(pos-currentFile.LineStart(line)) + 1,
+	}
+}
+
+// Offset returns the offset for the given file position p.
+func (f *File) Offset(p Pos) int {
+	return int(p) - f.Base
+}
+
+// Line returns the line number for the given file position p.
+func (f *File) Line(p Pos) int {
+	// binary search for the last line starting at or before p
+	var lo int = 0
+	var hi int = len(f.Lines)
+	for hi-lo > 1 {
+		var mid = lo + (hi-lo)/2
+		if p < f.Lines[mid] {
+			hi = mid
+		} else {
+			lo = mid
 		}
-		currentLineNo = id + 1
 	}
+	return lo + 1 // Line starts from 1
+}
 
-	return fmt.Sprintf("%s:%d", currentFile.Name, int(currentLineNo))
+// LineStart returns the position of the first character in the line.
+func (f *File) LineStart(line int) Pos {
+	return f.Lines[line-1]
 }
@@ -12,6 +12,7 @@ import (
 func (p *parser) init(fset *token.FileSet, filename string, src []uint8) {
 	f := fset.AddFile(filename, -1, len(src))
 	p.fset = fset
+	p.file = f
 	p.filename = filename
 	p.scanner = &scanner{}
 	p.scanner.Init(f, src)
@@ -27,6 +28,12 @@ type parser struct {
 	imports    []*ast.ImportSpec
 	filename   string
 	fset       *token.FileSet
+	file       *token.File
+
+	// Comments
+	comments    []*ast.CommentGroup
+	leadComment *ast.CommentGroup // last lead comment
+	lineComment *ast.CommentGroup // last line comment
 }
 
 func (p *parser) Pos() token.Pos {
@@ -40,8 +47,46 @@ func (p *parser) closeScope() {
 	p.topScope = p.topScope.Outer
 }
 
-func (p *parser) consumeComment() {
+// Consume a comment and return it and the line on which it ends.
+func (p *parser) consumeComment() (*ast.Comment, int) {
+	// /*-style comments may end on a different line than where they start.
+	// Scan the comment for '\n' chars and adjust endline accordingly.
+	endline := p.file.Line(p.Pos())
+	if p.tok.lit[1] == '*' {
+		for _, ch := range []uint8(p.tok.lit) {
+			if ch == '\n' {
+				endline++
+			}
+		}
+	}
+
+	comment := &ast.Comment{
+		Slash: p.Pos(),
+		Text:  p.tok.lit,
+	}
 	p.next0()
+	return comment, endline
+}
+
+// Consume a group of adjacent comments, add it to the parser's
+// comments list, and return it together with the line at which
+// the last comment in the group ends. A non-comment token or n
+// empty lines terminate a comment group.
+func (p *parser) consumeCommentGroup(n int) (*ast.CommentGroup, int) {
+	var list []*ast.Comment
+	endline := p.file.Line(p.Pos())
+	for p.tok.tok == "COMMENT" && p.file.Line(p.Pos()) <= endline+n {
+		comment, end := p.consumeComment()
+		endline = end
+		list = append(list, comment)
+	}
+
+	// add comment group to the comments list
+	comments := &ast.CommentGroup{
+		List: list,
+	}
+	p.comments = append(p.comments, comments)
+	return comments, endline
 }
 
 func (p *parser) next0() {
@@ -50,7 +95,16 @@ func (p *parser) next0() {
 	//logf("[parser.next0] pos=%d\n", p.tok.pos)
 }
 
+// Advance to the next non-comment token. In the process, collect
+// any comment groups encountered, and remember the last lead and
+// line comments.
 func (p *parser) next() {
+	p.leadComment = nil
+	p.lineComment = nil
+	var prev token.Pos
+	if p.tok != nil {
+		prev = p.Pos()
+	}
 	p.next0()
 	if p.tok.tok == ";" {
 		logff(" [parser] pointing at : \"%s\" newline (%s)\n", p.tok.tok, strconv.Itoa(p.scanner.offset))
@@ -61,8 +115,30 @@ func (p *parser) next() {
 	}
 
 	if p.tok.tok == "COMMENT" {
+		var comment *ast.CommentGroup
+		var endline int
+
+		if prev.IsValid() && p.file.Line(p.Pos()) == p.file.Line(prev) {
+			// The comment is on same line as the previous token; it
+			// cannot be a lead comment but may be a line comment.
+			comment, endline = p.consumeCommentGroup(0)
+			if p.file.Line(p.Pos()) != endline || p.tok.tok == ";" || p.tok.tok == "EOF" {
+				// The next token is on a different line, thus
+				// the last comment group is a line comment.
+				p.lineComment = comment
+			}
+		}
+
+		// consume successor comments, if any
+		endline = -1
 		for p.tok.tok == "COMMENT" {
-			p.consumeComment()
+			comment, endline = p.consumeCommentGroup(1)
+		}
+
+		if endline+1 == p.file.Line(p.Pos()) {
+			// The next token is following on the line immediately after the
+			// comment group, thus the last comment group is a lead comment.
+			p.leadComment = comment
 		}
 	}
 }
@@ -70,7 +146,7 @@ func (p *parser) next() {
 func (p *parser) expect(tok string, who string) {
 	if p.tok.tok != tok {
 		var s = fmt.Sprintf("%s expected, but got %s", tok, p.tok.tok)
-		panic2(who, s)
+		p.error(s)
 	}
 	logff(" [%s] consumed \"%s\"\n", who, p.tok.tok)
 	p.next()
@@ -83,35 +159,42 @@ func (p *parser) expectSemi(caller string) {
 			logff(" [%s] consumed semicolon %s\n", caller, p.tok.tok)
 			p.next()
 		default:
-			panic2(caller, "semicolon expected, but got token "+p.tok.tok)
+			p.error("semicolon expected, but got token " + p.tok.tok)
 		}
 	}
 }
 
 func (p *parser) parseIdent() *ast.Ident {
+	pos := p.Pos()
 	var name string
 	if p.tok.tok == "IDENT" {
 		name = p.tok.lit
 		p.next()
 	} else {
-		panic2(__func__, "IDENT expected, but got "+p.tok.tok)
+		p.error("IDENT expected, but got " + p.tok.tok)
 	}
 	logff(" [%s] ident name = %s\n", __func__, name)
 
 	return &ast.Ident{
-		NamePos: p.Pos(),
+		NamePos: pos,
 		Name:    name,
 	}
 }
 
 func (p *parser) parseImportSpec() *ast.ImportSpec {
+	doc := p.leadComment
+	pos := p.Pos()
 	var pth = p.tok.lit
 	p.next()
+	p.expectSemi(__func__)
 	spec := &ast.ImportSpec{
+		Doc: doc,
 		Path: &ast.BasicLit{
-			Kind:  token.STRING,
-			Value: pth,
+			ValuePos: pos,
+			Kind:     token.STRING,
+			Value:    pth,
 		},
+		Comment: p.lineComment,
 	}
 	p.imports = append(p.imports, spec)
 	return spec
@@ -119,16 +202,18 @@ func (p *parser) parseImportSpec() *ast.ImportSpec {
 
 func (p *parser) tryVarType(ellipsisOK bool) ast.Expr {
 	if ellipsisOK && p.tok.tok == "..." {
+		pos := p.Pos()
 		p.next() // consume "..."
 		var typ = p.tryIdentOrType()
 		if typ != nil {
 			p.resolve(typ)
 		} else {
-			panic2(__func__, "Syntax error")
+			p.error("Syntax error")
 		}
 
 		return (&ast.Ellipsis{
-			Elt: typ,
+			Ellipsis: pos,
+			Elt:      typ,
 		})
 	}
 	return p.tryIdentOrType()
@@ -138,7 +223,7 @@ func (p *parser) parseVarType(ellipsisOK bool) ast.Expr {
 	logff(" [%s] begin\n", __func__)
 	var typ = p.tryVarType(ellipsisOK)
 	if typ == nil {
-		panic2(__func__, "nil is not expected")
+		p.error("nil is not expected")
 	}
 	logff(" [%s] end\n", __func__)
 	return typ
@@ -160,14 +245,17 @@ func (p *parser) parseType() ast.Expr {
 }
 
 func (p *parser) parsePointerType() ast.Expr {
+	star := p.Pos()
 	p.expect("*", __func__)
 	var base = p.parseType()
 	return (&ast.StarExpr{
-		X: base,
+		Star: star,
+		X:    base,
 	})
 }
 
 func (p *parser) parseArrayType() ast.Expr {
+	lbrack := p.Pos()
 	p.expect("[", __func__)
 	var ln ast.Expr
 	if p.tok.tok != "]" {
@@ -177,12 +265,14 @@ func (p *parser) parseArrayType() ast.Expr {
 	var elt = p.parseType()
 
 	return (&ast.ArrayType{
-		Elt: elt,
-		Len: ln,
+		Lbrack: lbrack,
+		Elt:    elt,
+		Len:    ln,
 	})
 }
 
 func (p *parser) parseFieldDecl(scope *ast.Scope) *ast.Field {
+	doc := p.leadComment
 
 	var varType = p.parseVarType(false)
 	var typ = p.tryVarType(false)
@@ -190,16 +280,41 @@ func (p *parser) parseFieldDecl(scope *ast.Scope) *ast.Field {
 	p.expectSemi(__func__)
 	ident := varType.(*ast.Ident)
 	var field = &ast.Field{
-		Type:  typ,
-		Names: []*ast.Ident{ident},
+		Doc:     doc,
+		Type:    typ,
+		Names:   []*ast.Ident{ident},
+		Comment: p.lineComment,
 	}
 	declareField(field, scope, ast.Var, ident)
 	p.resolve(typ)
 	return field
 }
 
+// parseMethodSpec parses a method of an interface type.
+// Embedded interfaces are not supported.
+func (p *parser) parseMethodSpec() *ast.Field {
+	doc := p.leadComment
+	ident := p.parseIdent()
+	var scope = ast.NewScope(p.topScope)
+	var sig = p.parseSignature(scope)
+	p.expectSemi(__func__)
+	typ := &ast.FuncType{
+		Func:    ident.NamePos,
+		Params:  sig.Params,
+		Results: sig.Results,
+	}
+	return &ast.Field{
+		Doc:     doc,
+		Names:   []*ast.Ident{ident},
+		Type:    typ,
+		Comment: p.lineComment,
+	}
+}
+
 func (p *parser) parseStructType() ast.Expr {
+	pos := p.Pos()
 	p.expect("struct", __func__)
+	lbrace := p.Pos()
 	p.expect("{", __func__)
 
 	var _nil *ast.Scope
@@ -210,22 +325,28 @@ func (p *parser) parseStructType() ast.Expr {
 		var field *ast.Field = p.parseFieldDecl(scope)
 		list = append(list, field)
 	}
+	rbrace := p.Pos()
 	p.expect("}", __func__)
 
 	return (&ast.StructType{
+		Struct: pos,
 		Fields: &ast.FieldList{
-			List: list,
+			Opening: lbrace,
+			List:    list,
+			Closing: rbrace,
 		},
 	})
 }
 
 func (p *parser) parseMaptype() ast.Expr {
+	pos := p.Pos()
 	p.expect("map", __func__)
 	p.expect("[", __func__)
 	keyType := p.parseType()
 	p.expect("]", __func__)
 	valueType := p.parseType()
 	return &ast.MapType{
+		Map:   pos,
 		Key:   keyType,
 		Value: valueType,
 	}
@@ -264,21 +385,37 @@ func (p *parser) tryIdentOrType() ast.Expr {
 	case "*":
 		return p.parsePointerType()
 	case "interface":
+		pos := p.Pos()
 		p.next()
+		lbrace := p.Pos()
 		p.expect("{", __func__)
-		// @TODO parser method sets
+		var list []*ast.Field
+		for p.tok.tok == "IDENT" {
+			var method *ast.Field = p.parseMethodSpec()
+			list = append(list, method)
+		}
+		rbrace := p.Pos()
 		p.expect("}", __func__)
 		return (&ast.InterfaceType{
-			Methods: nil,
+			Interface: pos,
+			Methods: &ast.FieldList{
+				Opening: lbrace,
+				List:    list,
+				Closing: rbrace,
+			},
 		})
 	case "func":
 		return p.parseFuncType()
 	case "(":
+		lparen := p.Pos()
 		p.next()
 		var _typ = p.parseType()
+		rparen := p.Pos()
 		p.expect(")", __func__)
 		return (&ast.ParenExpr{
-			X: _typ,
+			Lparen: lparen,
+			X:      _typ,
+			Rparen: rparen,
 		})
 	case "type":
 		p.next()
@@ -308,7 +445,7 @@ func (p *parser) parseParameterList(scope *ast.Scope, ellipsisOK bool) []*ast.Fi
 	var typ = p.tryVarType(ellipsisOK)
 	if typ != nil {
 		if len(list) > 1 {
-			panic2(__func__, "Ident list is not supported")
+			p.error("Ident list is not supported")
 		}
 		var eIdent = list[0]
 		ident := eIdent.(*ast.Ident)
@@ -360,14 +497,18 @@ func (p *parser) parseParameterList(scope *ast.Scope, ellipsisOK bool) []*ast.Fi
 func (p *parser) parseParameters(scope *ast.Scope, ellipsisOk bool) *ast.FieldList {
 	logff(" [%s] begin\n", __func__)
 	var params []*ast.Field
+	lparen := p.Pos()
 	p.expect("(", __func__)
 	if p.tok.tok != ")" {
 		params = p.parseParameterList(scope, ellipsisOk)
 	}
+	rparen := p.Pos()
 	p.expect(")", __func__)
 	logff(" [%s] end\n", __func__)
 	return &ast.FieldList{
-		List: params,
+		Opening: lparen,
+		List:    params,
+		Closing: rparen,
 	}
 }
 
@@ -481,26 +622,31 @@ func (p *parser) parseOperand(lhs bool) ast.Expr {
 		return eIdent
 	case "INT", "STRING", "CHAR":
 		var basicLit = &ast.BasicLit{
-			Kind:  token.Token(p.tok.tok),
-			Value: p.tok.lit,
+			ValuePos: p.Pos(),
+			Kind:     token.Token(p.tok.tok),
+			Value:    p.tok.lit,
 		}
 		p.next()
 		logff("   end %s\n", __func__)
 		return (basicLit)
 	case "(":
+		lparen := p.Pos()
 		p.next() // consume "("
 		parserExprLev++
 		var x = p.parseRhsOrType()
 		parserExprLev--
+		rparen := p.Pos()
 		p.expect(")", __func__)
 		return (&ast.ParenExpr{
-			X: x,
+			Lparen: lparen,
+			X:      x,
+			Rparen: rparen,
 		})
 	}
 
 	var typ = p.tryIdentOrType()
 	if typ == nil {
-		panic2(__func__, "# typ should not be nil\n")
+		p.error("expected operand, but got " + p.tok.tok)
 	}
 	logff("   end %s\n", __func__)
 
@@ -513,6 +659,7 @@ func (p *parser) parseRhsOrType() ast.Expr {
 }
 
 func (p *parser) parseCallExpr(fn ast.Expr) ast.Expr {
+	lparen := p.Pos()
 	p.expect("(", __func__)
 	logff(" [parseCallExpr] p.tok.tok=%s\n", p.tok.tok)
 	var list []ast.Expr
@@ -532,15 +679,18 @@ func (p *parser) parseCallExpr(fn ast.Expr) ast.Expr {
 	}
 
 	if p.tok.tok == "..." {
+		ellipsis = p.Pos()
 		p.next()
-		ellipsis = 1 // this means true
 	}
 
+	rparen := p.Pos()
 	p.expect(")", __func__)
 	return (&ast.CallExpr{
 		Fun:      fn,
+		Lparen:   lparen,
 		Args:     list,
 		Ellipsis: ellipsis,
+		Rparen:   rparen,
 	})
 }
 
@@ -556,7 +706,7 @@ func (p *parser) parsePrimaryExpr(lhs bool) ast.Expr {
 		cnt++
 		logff("    [%s] tok=%s\n", __func__, p.tok.tok)
 		if cnt > 100 {
-			panic2(__func__, "too many iteration")
+			p.error("too many iteration")
 		}
 
 		switch p.tok.tok {
@@ -583,7 +733,7 @@ func (p *parser) parsePrimaryExpr(lhs bool) ast.Expr {
 			case "(": // type assertion
 				x = p.parseTypeAssertion(x)
 			default:
-				panic2(__func__, "Unexpected token:"+p.tok.tok)
+				p.error("Unexpected token:" + p.tok.tok)
 			}
 		case "(":
 			// a simpleStmt like x() is parsed in lhs=true mode.
@@ -612,12 +762,16 @@ func (p *parser) parsePrimaryExpr(lhs bool) ast.Expr {
 }
 
 func (p *parser) parseTypeAssertion(x ast.Expr) ast.Expr {
+	lparen := p.Pos()
 	p.expect("(", __func__)
 	typ := p.parseType()
+	rparen := p.Pos()
 	p.expect(")", __func__)
 	return (&ast.TypeAssertExpr{
-		X:    x,
-		Type: typ,
+		X:      x,
+		Lparen: lparen,
+		Type:   typ,
+		Rparen: rparen,
 	})
 }
 
@@ -626,10 +780,12 @@ func (p *parser) parseElement() ast.Expr {
 	var v ast.Expr
 	var kvExpr *ast.KeyValueExpr
 	if p.tok.tok == ":" {
+		colon := p.Pos()
 		p.next() // skip ":"
 		v = p.parseExpr(false)
 		kvExpr = &ast.KeyValueExpr{
 			Key:   x,
+			Colon: colon,
 			Value: v,
 		}
 		x = (kvExpr)
@@ -653,17 +809,21 @@ func (p *parser) parseElementList() []ast.Expr {
 
 func (p *parser) parseLiteralValue(typ ast.Expr) ast.Expr {
 	logff("   start %s\n", __func__)
+	lbrace := p.Pos()
 	p.expect("{", __func__)
 	var elts []ast.Expr
 	if p.tok.tok != "}" {
 		elts = p.parseElementList()
 	}
+	rbrace := p.Pos()
 	p.expect("}", __func__)
 
 	logff("   end %s\n", __func__)
 	return (&ast.CompositeLit{
-		Type: typ,
-		Elts: elts,
+		Type:   typ,
+		Lbrace: lbrace,
+		Elts:   elts,
+		Rbrace: rbrace,
 	})
 }
 
@@ -683,6 +843,7 @@ func isLiteralType(expr ast.Expr) bool {
 }
 
 func (p *parser) parseIndexOrSlice(x ast.Expr) ast.Expr {
+	lbrack := p.Pos()
 	p.expect("[", __func__)
 	var index = make([]ast.Expr, 3, 3)
 	if p.tok.tok != ":" {
@@ -696,6 +857,7 @@ func (p *parser) parseIndexOrSlice(x ast.Expr) ast.Expr {
 			index[ncolons] = p.parseRhs()
 		}
 	}
+	rbrack := p.Pos()
 	p.expect("]", __func__)
 
 	if ncolons > 0 {
@@ -703,8 +865,10 @@ func (p *parser) parseIndexOrSlice(x ast.Expr) ast.Expr {
 		var sliceExpr = &ast.SliceExpr{
 			Slice3: false,
 			X:      x,
+			Lbrack: lbrack,
 			Low:    index[0],
 			High:   index[1],
+			Rbrack: rbrack,
 		}
 		if ncolons == 2 {
 			sliceExpr.Max = index[2]
@@ -714,7 +878,9 @@ func (p *parser) parseIndexOrSlice(x ast.Expr) ast.Expr {
 
 	var indexExpr = &ast.IndexExpr{}
 	indexExpr.X = x
+	indexExpr.Lbrack = lbrack
 	indexExpr.Index = index[0]
+	indexExpr.Rbrack = rbrack
 	return (indexExpr)
 }
 
@@ -722,19 +888,23 @@ func (p *parser) parseUnaryExpr(lhs bool) ast.Expr {
 	var r ast.Expr
 	switch p.tok.tok {
 	case "+", "-", "!", "&":
+		pos := p.Pos()
 		var tok = p.tok.tok
 		p.next()
 		var x = p.parseUnaryExpr(false)
 		r = (&ast.UnaryExpr{
-			X:  x,
-			Op: token.Token(tok),
+			OpPos: pos,
+			X:     x,
+			Op:    token.Token(tok),
 		})
 		return r
 	case "*":
+		pos := p.Pos()
 		p.next() // consume "*"
 		var x = p.parseUnaryExpr(false)
 		r = (&ast.StarExpr{
-			X: x,
+			Star: pos,
+			X:    x,
 		})
 		return r
 	}
@@ -742,38 +912,18 @@ func (p *parser) parseUnaryExpr(lhs bool) ast.Expr {
 	return r
 }
 
-const LowestPrec int = 0
-
-// https://golang.org/ref/spec#Operators
-func precedence(op string) int {
-	switch op {
-	case "*", "/", "%", "&":
-		return 5
-	case "+", "-", "|":
-		return 4
-	case "==", "!=", "<", "<=", ">", ">=":
-		return 3
-	case "&&":
-		return 2
-	case "||":
-		return 1
-	default:
-		return 0
-	}
-	return 0
-}
-
 func (p *parser) parseBinaryExpr(lhs bool, prec1 int) ast.Expr {
 	logff("   begin parseBinaryExpr() prec1=%s\n", strconv.Itoa(prec1))
 	var x = p.parseUnaryExpr(lhs)
 	var oprec int
 	for {
 		var op = p.tok.tok
-		oprec = precedence(op)
+		oprec = token.Token(op).Precedence()
 		if oprec < prec1 {
 			logff("   end parseBinaryExpr() (NonBinary)\n")
 			return x
 		}
+		pos := p.Pos()
 		p.expect(op, __func__)
 		if lhs {
 			// x + y
@@ -785,6 +935,7 @@ func (p *parser) parseBinaryExpr(lhs bool, prec1 int) ast.Expr {
 		var y = p.parseBinaryExpr(false, oprec+1)
 		var binaryExpr = &ast.BinaryExpr{}
 		binaryExpr.X = x
+		binaryExpr.OpPos = pos
 		binaryExpr.Y = y
 		binaryExpr.Op = token.Token(op)
 		var r = (binaryExpr)
@@ -817,16 +968,19 @@ func makeExpr(s ast.Stmt) ast.Expr {
 }
 
 func (p *parser) parseGoStmt() ast.Stmt {
+	pos := p.Pos()
 	p.expect("go", __func__)
 	expr := p.parsePrimaryExpr(false)
 	p.expectSemi(__func__)
 	return &ast.GoStmt{
+		Go:   pos,
 		Call: expr.(*ast.CallExpr),
 	}
 }
 
 func (p *parser) parseForStmt() ast.Stmt {
 	logff(" begin %s\n", __func__)
+	pos := p.Pos()
 	p.expect("for", __func__)
 	p.openScope()
 
@@ -877,13 +1031,15 @@ func (p *parser) parseForStmt() ast.Stmt {
 			key = as.Lhs[0]
 			value = as.Lhs[1]
 		default:
-			panic2(__func__, "Unexpected len of as.Lhs")
+			p.error("Unexpected len of as.Lhs")
 		}
 
 		rangeX = as.Rhs[0].(*ast.UnaryExpr).X
 		var rangeStmt = &ast.RangeStmt{}
+		rangeStmt.For = pos
 		rangeStmt.Key = key
 		rangeStmt.Value = value
+		rangeStmt.TokPos = as.TokPos
 		rangeStmt.X = rangeX
 		rangeStmt.Body = body
 		rangeStmt.Tok = token.Token(as.Tok)
@@ -892,6 +1048,7 @@ func (p *parser) parseForStmt() ast.Stmt {
 		return rangeStmt
 	}
 	var forStmt = &ast.ForStmt{}
+	forStmt.For = pos
 	forStmt.Init = s1
 	forStmt.Cond = makeExpr(s2)
 	forStmt.Post = s3
@@ -902,6 +1059,7 @@ func (p *parser) parseForStmt() ast.Stmt {
 }
 
 func (p *parser) parseIfStmt() ast.Stmt {
+	pos := p.Pos()
 	p.expect("if", __func__)
 	parserExprLev = -1
 	var condStmt ast.Stmt = p.parseSimpleStmt(false)
@@ -923,6 +1081,7 @@ func (p *parser) parseIfStmt() ast.Stmt {
 		p.expectSemi(__func__)
 	}
 	var ifStmt = &ast.IfStmt{}
+	ifStmt.If = pos
 	ifStmt.Cond = cond
 	ifStmt.Body = body
 	ifStmt.Else = else_
@@ -932,6 +1091,7 @@ func (p *parser) parseIfStmt() ast.Stmt {
 
 func (p *parser) parseCaseClause() *ast.CaseClause {
 	logff(" [%s] start\n", __func__)
+	pos := p.Pos()
 	var list []ast.Expr
 	if p.tok.tok == "case" {
 		p.next() // consume "case"
@@ -939,10 +1099,13 @@ func (p *parser) parseCaseClause() *ast.CaseClause {
 	} else {
 		p.expect("default", __func__)
 	}
+	colon := p.Pos()
 	p.expect(":", __func__)
 	p.openScope()
 	var body = p.parseStmtList()
 	var r = &ast.CaseClause{}
+	r.Case = pos
+	r.Colon = colon
 	r.Body = body
 	r.List = list
 	p.closeScope()
@@ -970,6 +1133,7 @@ func isTypeSwitchGuard(stmt ast.Stmt) bool {
 }
 
 func (p *parser) parseSwitchStmt() ast.Stmt {
+	pos := p.Pos()
 	p.expect("switch", __func__)
 	p.openScope()
 
@@ -978,6 +1142,7 @@ func (p *parser) parseSwitchStmt() ast.Stmt {
 	s2 = p.parseSimpleStmt(false)
 	parserExprLev = 0
 
+	lbrace := p.Pos()
 	p.expect("{", __func__)
 	var list []ast.Stmt
 	var cc *ast.CaseClause
@@ -987,23 +1152,28 @@ func (p *parser) parseSwitchStmt() ast.Stmt {
 		ccs = cc
 		list = append(list, ccs)
 	}
+	rbrace := p.Pos()
 	p.expect("}", __func__)
 	p.expectSemi(__func__)
 	var body = &ast.BlockStmt{}
+	body.Lbrace = lbrace
 	body.List = list
+	body.Rbrace = rbrace
 
 	typeSwitch := isTypeSwitchGuard(s2)
 
 	p.closeScope()
 	if typeSwitch {
 		return &ast.TypeSwitchStmt{
+			Switch: pos,
 			Assign: s2,
 			Body:   body,
 		}
 	} else {
 		return &ast.SwitchStmt{
-			Body: body,
-			Tag:  makeExpr(s2),
+			Switch: pos,
+			Body:   body,
+			Tag:    makeExpr(s2),
 		}
 	}
 }
@@ -1032,14 +1202,17 @@ func (p *parser) parseSimpleStmt(isRangeOK bool) ast.Stmt {
 	var y ast.Expr
 	var rangeX ast.Expr
 	var rangeUnary *ast.UnaryExpr
+	pos := p.Pos()
 	switch stok {
 	case ":=", "=", "+=", "-=":
 		var assignToken = stok
 		p.next() // consume =
 		if isRangeOK && p.tok.tok == "range" {
+			rangePos := p.Pos()
 			p.next() // consume "range"
 			rangeX = p.parseRhs()
 			rangeUnary = &ast.UnaryExpr{}
+			rangeUnary.OpPos = rangePos
 			rangeUnary.Op = "range"
 			rangeUnary.X = rangeX
 			y = (rangeUnary)
@@ -1048,6 +1221,7 @@ func (p *parser) parseSimpleStmt(isRangeOK bool) ast.Stmt {
 			y = p.parseExpr(false) // rhs
 		}
 		var as = &ast.AssignStmt{}
+		as.TokPos = pos
 		as.Tok = token.Token(assignToken)
 		as.Lhs = x
 		as.Rhs = make([]ast.Expr, 1, 1)
@@ -1074,6 +1248,7 @@ func (p *parser) parseSimpleStmt(isRangeOK bool) ast.Stmt {
 	case "++", "--":
 		var sInc = &ast.IncDecStmt{}
 		sInc.X = x[0]
+		sInc.TokPos = pos
 		sInc.Tok = token.Token(stok)
 		p.next() // consume "++" or "--"
 		return sInc
@@ -1110,7 +1285,7 @@ func (p *parser) parseStmt() ast.Stmt {
 	case "go":
 		s = p.parseGoStmt()
 	default:
-		panic2(__func__, "TBI 3:"+p.tok.tok)
+		p.error("TBI 3:" + p.tok.tok)
 	}
 	logff(" = end parseStmt()\n")
 	return s
@@ -1137,16 +1312,19 @@ func (p *parser) parseRhsList() []ast.Expr {
 }
 
 func (p *parser) parseBranchStmt(tok string) ast.Stmt {
+	pos := p.Pos()
 	p.expect(tok, __func__)
 
 	p.expectSemi(__func__)
 
 	return &ast.BranchStmt{
-		Tok: token.Token(tok),
+		TokPos: pos,
+		Tok:    token.Token(tok),
 	}
 }
 
 func (p *parser) parseReturnStmt() ast.Stmt {
+	pos := p.Pos()
 	p.expect("return", __func__)
 	var x []ast.Expr
 	if p.tok.tok != ";" && p.tok.tok != "}" {
@@ -1154,6 +1332,7 @@ func (p *parser) parseReturnStmt() ast.Stmt {
 	}
 	p.expectSemi(__func__)
 	var returnStmt = &ast.ReturnStmt{}
+	returnStmt.Return = pos
 	returnStmt.Results = x
 	return returnStmt
 }
@@ -1168,6 +1347,7 @@ func (p *parser) parseStmtList() []ast.Stmt {
 }
 
 func (p *parser) parseBody(scope *ast.Scope) *ast.BlockStmt {
+	lbrace := p.Pos()
 	p.expect("{", __func__)
 	p.topScope = scope
 	logff(" begin parseStmtList()\n")
@@ -1175,22 +1355,29 @@ func (p *parser) parseBody(scope *ast.Scope) *ast.BlockStmt {
 	logff(" end parseStmtList()\n")
 
 	p.closeScope()
+	rbrace := p.Pos()
 	p.expect("}", __func__)
 	var r = &ast.BlockStmt{}
+	r.Lbrace = lbrace
 	r.List = list
+	r.Rbrace = rbrace
 	return r
 }
 
 func (p *parser) parseBlockStmt() *ast.BlockStmt {
+	lbrace := p.Pos()
 	p.expect("{", __func__)
 	p.openScope()
 	logff(" begin parseStmtList()\n")
 	var list = p.parseStmtList()
 	logff(" end parseStmtList()\n")
 	p.closeScope()
+	rbrace := p.Pos()
 	p.expect("}", __func__)
 	var r = &ast.BlockStmt{}
+	r.Lbrace = lbrace
 	r.List = list
+	r.Rbrace = rbrace
 	return r
 }
 
@@ -1198,6 +1385,7 @@ func (p *parser) parseDecl(keyword string) *ast.GenDecl {
 	var r *ast.GenDecl
 	switch p.tok.tok {
 	case "var":
+		pos := p.Pos()
 		p.expect(keyword, __func__)
 		var ident = p.parseIdent()
 		var typ = p.parseType()
@@ -1218,10 +1406,12 @@ func (p *parser) parseDecl(keyword string) *ast.GenDecl {
 		declare(valSpec, p.topScope, ast.Var, ident)
 		specs := []ast.Spec{valSpec}
 		return &ast.GenDecl{
-			Specs: specs,
+			TokPos: pos,
+			Tok:    token.Token(keyword),
+			Specs:  specs,
 		}
 	default:
-		panic2(__func__, "TBI\n")
+		p.error("TBI")
 	}
 	return r
 }
@@ -1233,14 +1423,14 @@ func (p *parser) parserTypeSpec() *ast.TypeSpec {
 	logff(" decl type %s\n", ident.Name)
 
 	var spec = &ast.TypeSpec{
-		NamePos: p.Pos(),
+		NamePos: ident.NamePos,
 	}
 	spec.Name = ident
 	declare(spec, p.topScope, ast.Typ, ident)
 	if p.tok.tok == "=" {
 		// type alias
+		spec.Assign = p.Pos()
 		p.next()
-		spec.Assign = true
 	}
 	var typ = p.parseType()
 
@@ -1279,12 +1469,14 @@ func (p *parser) parseValueSpec(keyword string) *ast.ValueSpec {
 }
 
 func (p *parser) parseFuncType() ast.Expr {
+	pos := p.Pos()
 	p.next()
 	var scope = ast.NewScope(p.topScope) // function scope
 	var sig = p.parseSignature(scope)
 	var params = sig.Params
 	var results = sig.Results
 	ft := &ast.FuncType{
+		Func:    pos,
 		Params:  params,
 		Results: results,
 	}
@@ -1292,6 +1484,7 @@ func (p *parser) parseFuncType() ast.Expr {
 }
 
 func (p *parser) parseFuncDecl() ast.Decl {
+	doc := p.leadComment
 	pos := p.tok.pos
 	p.expect("func", __func__)
 	var scope = ast.NewScope(p.topScope) // function scope
@@ -1326,10 +1519,12 @@ func (p *parser) parseFuncDecl() ast.Decl {
 	//logf("[parser] p.tok.pos=%d\n", p.tok.pos)
 
 	var funcDecl = &ast.FuncDecl{}
+	funcDecl.Doc = doc
 	funcDecl.Recv = receivers
 	funcDecl.Name = ident
 	funcDecl.TPos = token.Pos(pos)
 	funcDecl.Type = &ast.FuncType{}
+	funcDecl.Type.Func = token.Pos(pos)
 	funcDecl.Type.Params = params
 	funcDecl.Type.Results = results
 	funcDecl.Body = body
@@ -1341,7 +1536,9 @@ func (p *parser) parseFuncDecl() ast.Decl {
 }
 
 func (p *parser) parseFile(importsOnly bool) *ast.File {
-	// expect "package" keyword
+	// package clause
+	doc := p.leadComment
+	pos := p.Pos()
 	p.expect("package", __func__)
 	p.unresolved = nil
 	var ident = p.parseIdent()
@@ -1351,48 +1548,67 @@ func (p *parser) parseFile(importsOnly bool) *ast.File {
 	p.topScope = ast.NewScope(nil) // open scope
 	p.pkgScope = p.topScope
 
+	var decls []ast.Decl
+	var decl ast.Decl
+
 	for p.tok.tok == "import" {
+		var importDecl = &ast.GenDecl{}
+		importDecl.Doc = p.leadComment
+		importDecl.TokPos = p.Pos()
+		importDecl.Tok = "import"
 		p.expect("import", __func__)
 		if p.tok.tok == "(" {
+			importDecl.Lparen = p.Pos()
 			p.next()
 			for p.tok.tok != ")" {
-				p.parseImportSpec()
-				p.expectSemi(__func__)
+				spec := p.parseImportSpec()
+				importDecl.Specs = append(importDecl.Specs, spec)
 			}
+			importDecl.Rparen = p.Pos()
 			p.next()
 			p.expectSemi(__func__)
 		} else {
-			p.parseImportSpec()
-			p.expectSemi(__func__)
+			spec := p.parseImportSpec()
+			importDecl.Specs = append(importDecl.Specs, spec)
 		}
+		decls = append(decls, importDecl)
 	}
 
 	logff("\n")
 	logff(" [parser] Parsing Top level decls\n")
-	var decls []ast.Decl
-	var decl ast.Decl
 
 	for !importsOnly && p.tok.tok != "EOF" {
 		switch p.tok.tok {
 		case "var", "const":
+			declDoc := p.leadComment
+			declPos := p.Pos()
+			declTok := p.tok.tok
 			var spec = p.parseValueSpec(p.tok.tok)
 			specs := []ast.Spec{spec}
 			decl = &ast.GenDecl{
-				Specs: specs,
+				Doc:    declDoc,
+				TokPos: declPos,
+				Tok:    token.Token(declTok),
+				Specs:  specs,
 			}
 		case "func":
 			logff("\n\n")
 			decl = p.parseFuncDecl()
 			//logff(" func decl parsed:%s\n", decl.funcDecl.Name.Name)
 		case "type":
+			declDoc := p.leadComment
+			declPos := p.Pos()
 			spec := p.parserTypeSpec()
 			specs := []ast.Spec{spec}
 			decl = &ast.GenDecl{
-				Specs: specs,
+				Doc:    declDoc,
+				TokPos: declPos,
+				Tok:    "type",
+				Specs:  specs,
 			}
 			logff(" type parsed:%s\n", "")
 		default:
-			panic2(__func__, "TBI:"+p.tok.tok)
+			p.error("TBI:" + p.tok.tok)
 		}
 		decls = append(decls, decl)
 	}
@@ -1411,17 +1627,23 @@ func (p *parser) parseFile(importsOnly bool) *ast.File {
 	}
 
 	var f = &ast.File{}
+	f.Doc = doc
+	f.Package = pos
 	f.Name = packageName
 	f.Scope = p.pkgScope
 	f.Decls = decls
 	f.Unresolved = unresolved
 	f.Imports = p.imports
+	f.Comments = p.comments
 	return f
 }
 
-func readSource(filename string) []uint8 {
-	buf, _ := os.ReadFile(filename)
-	return buf
+func readSource(filename string) ([]uint8, error) {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
 }
 
 func ParseFile(fset *token.FileSet, filename string, src interface{}, mode uint8) (*ast.File, *ParserError) {
@@ -1431,12 +1653,16 @@ func ParseFile(fset *token.FileSet, filename string, src interface{}, mode uint8
 		importsOnly = true
 	}
 
-	text := readSource(filename)
+	text, err := readSource(filename)
+	if err != nil {
+		return nil, &ParserError{msg: err.Error()}
+	}
 	var p = &parser{}
-	packagePos := token.Pos(fset.Base)
 	p.init(fset, filename, text)
 	astFile := p.parseFile(importsOnly)
-	astFile.Package = packagePos
+	if mode&parserParseComments == 0 {
+		astFile.Comments = nil
+	}
 	return astFile, nil
 }
 
@@ -1445,8 +1671,17 @@ func isExprIdent(e ast.Expr) bool {
 	return ok
 }
 
-func panic2(caller string, x string) {
-	panic(caller + ": " + x)
+// error reports a syntax error at the current token, and exits with status 2 like gofmt.
+func (p *parser) error(msg string) {
+	syntaxError(p.file, p.Pos(), msg)
+}
+
+// syntaxError prints msg at the position pos of file, and exits with status 2.
+func syntaxError(file *token.File, pos token.Pos, msg string) {
+	line := file.Line(pos)
+	column := int(pos-file.LineStart(line)) + 1
+	fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", file.Name, line, column, msg)
+	os.Exit(2)
 }
 
 type ParserError struct {
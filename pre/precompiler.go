@@ -1,13 +1,19 @@
 package main
 
 import (
+	"bufio"
 	"os"
+	"os/exec"
+	"syscall"
+	"time"
 	"unsafe"
 
 	"go/ast"
 	"go/parser"
+	"go/printer"
 	"go/token"
 
+	"github.com/DQNEO/babygo/lib/diff"
 	"github.com/DQNEO/babygo/lib/mylib"
 	"github.com/DQNEO/babygo/lib/path"
 	"github.com/DQNEO/babygo/lib/strconv"
@@ -37,9 +43,17 @@ func unexpectedKind(knd TypeKind) {
 	panic("Unexpected Kind: " + string(knd))
 }
 
-var fout *os.File
+var fout *bufio.Writer
 
 func printf(format string, a ...interface{}) {
+	if asmCapture {
+		capturedAsm = append(capturedAsm, fmt.Sprintf(format, a...))
+		return
+	}
+	if optLevel > 0 {
+		peepWrite(fmt.Sprintf(format, a...))
+		return
+	}
 	fmt.Fprintf(fout, format, a...)
 }
 
@@ -80,11 +94,16 @@ func emitConstInt(expr ast.Expr) {
 }
 
 func evalInt(expr ast.Expr) int {
-	switch e := expr.(type) {
-	case *ast.BasicLit:
-		return strconv.Atoi(e.Value)
+	v, ok := constLitInt(foldConstExpr(expr))
+	if !ok {
+		panic("Unknown type")
 	}
-	panic("Unknown type")
+	return v
+}
+
+// isImm32 reports whether v fits in the sign-extended 32-bit immediate of an instruction.
+func isImm32(v int) bool {
+	return v >= -2147483647 && v <= 2147483647
 }
 
 func emitPopPrimitive(comment string) {
@@ -205,8 +224,15 @@ func emitFuncAddr(funcQI QualifiedIdent) {
 
 func emitVariableAddr(variable *Variable) {
 	emitComment(2, "emit Addr of variable \"%s\" \n", variable.Name)
+	if variable.Vreg != 0 {
+		// the register backend has to keep it in memory
+		variable.AddrTaken = true
+		raCur.retry = true
+	}
 
-	if variable.IsGlobal {
+	if variable.HeapAddr != nil {
+		printf("  movq %d(%%rbp), %%rax # local variable \"%s\" moved to heap\n", variable.HeapAddr.LocalOffset, variable.Name)
+	} else if variable.IsGlobal {
 		printf("  leaq %s(%%rip), %%rax # global variable \"%s\"\n", variable.GlobalSymbol, variable.Name)
 	} else {
 		printf("  leaq %d(%%rbp), %%rax # local variable \"%s\"\n", variable.LocalOffset, variable.Name)
@@ -252,10 +278,18 @@ func emitAddr(meta MetaExpr) {
 		} else {
 			elmType := getTypeOfExpr(m)
 			emitExpr(m.Index) // index number
-			emitListElementAddr(m.X, elmType)
+			if noRuntimeChecks {
+				emitListElementAddr(m.X, elmType)
+			} else {
+				emitCheckedElementAddr(m.X, elmType, m.e.Lbrack)
+			}
 		}
 	case *MetaStarExpr:
 		emitExpr(m.X)
+		if !noRuntimeChecks {
+			printf("  movq (%%rsp), %%rax # pointer\n")
+			emitNonZeroCheck("%rax", "runtime.panicNil", m.e.Star)
+		}
 	case *MetaSelectorExpr:
 		if isQI(m.e) { // pkg.Var|pkg.Const
 			qi := selector2QI(m.e)
@@ -298,6 +332,15 @@ func emitAddr(meta MetaExpr) {
 		default:
 			unexpectedKind(knd)
 		}
+	case *MetaCallExpr:
+		knd := kind(getTypeOfExpr(m))
+		switch knd {
+		case T_STRUCT, T_ARRAY:
+			// result of a call returning a struct is the address of its copy
+			emitExpr(m)
+		default:
+			unexpectedKind(knd)
+		}
 	default:
 		throw(meta)
 	}
@@ -478,6 +521,210 @@ func emitCallMalloc(size int) {
 	emitCallFF(ff)
 }
 
+// -B: do not emit runtime checks
+var noRuntimeChecks bool
+
+// checkPosition returns pos in the form "file:line", which is reported by a failed runtime check.
+func checkPosition(pos token.Pos) string {
+	position := fset.Position(pos)
+	return fmt.Sprintf("%s:%d", position.Filename, position.Line)
+}
+
+// runtimeStringLabel returns the label of the string s emitted with the package.
+// Such strings are read by the runtime: positions of checks, and names and files in the call site table.
+func runtimeStringLabel(s string) string {
+	if currentPkg.runtimeStringLabels == nil {
+		currentPkg.runtimeStringLabels = make(map[string]string)
+	}
+	label, ok := currentPkg.runtimeStringLabels[s]
+	if !ok {
+		label = fmt.Sprintf(".rtstr_%d", len(currentPkg.runtimeStrings))
+		currentPkg.runtimeStringLabels[s] = label
+		currentPkg.runtimeStrings = append(currentPkg.runtimeStrings, s)
+	}
+	return label
+}
+
+// emitCheckFailure calls the runtime function fn reporting a failed check at pos.
+// x and y are the values passed to fn before the position, "" if none. fn does not return.
+func emitCheckFailure(fn string, pos token.Pos, x string, y string) {
+	position := checkPosition(pos)
+	printf("  pushq $%d # position len\n", len(position))
+	printf("  leaq %s(%%rip), %%rsi # %s\n", runtimeStringLabel(position), position)
+	printf("  pushq %%rsi # position ptr\n")
+	if y != "" {
+		printf("  pushq %s\n", y)
+	}
+	if x != "" {
+		printf("  pushq %s\n", x)
+	}
+	emitCallInstr(fn, pos)
+}
+
+// emitBoundsCheck calls fn with x and y unless x cc y holds as unsigned integers.
+func emitBoundsCheck(cc string, x string, y string, fn string, pos token.Pos) {
+	labelid++
+	labelOK := fmt.Sprintf(".L.%d.inbounds", labelid)
+	printf("  cmpq %s, %s\n", y, x)
+	printf("  j%s %s\n", cc, labelOK)
+	emitCheckFailure(fn, pos, x, y)
+	printf("  %s:\n", labelOK)
+}
+
+// emitNonZeroCheck calls fn if the register reg is zero.
+func emitNonZeroCheck(reg string, fn string, pos token.Pos) {
+	labelid++
+	labelOK := fmt.Sprintf(".L.%d.nonzero", labelid)
+	printf("  testq %s, %s\n", reg, reg)
+	printf("  jne %s\n", labelOK)
+	emitCheckFailure(fn, pos, "", "")
+	printf("  %s:\n", labelOK)
+}
+
+// The call site table maps the return address of each call to the function and the line of the call.
+// The runtime looks up the return addresses found by following the frame pointers
+// to print the stack trace of a panic.
+// The function table gives the range of the code of each function and the line of each statement in it,
+// which locate an instruction that caused a signal.
+// Every package emits its entries in the sections babygo_calltab and babygo_functab,
+// and the linker concatenates them.
+
+type funcInfo struct {
+	label  string
+	symbol string
+	name   string // name printed in stack traces
+	file   string
+	line   int
+	params int
+	lines  []*lineMark // statements starting on another line than the previous one
+}
+
+type lineMark struct {
+	label string
+	line  int
+}
+
+type callSite struct {
+	label string
+	fn    *funcInfo
+	line  int
+}
+
+// function being emitted, nil outside functions
+var callSiteFunc *funcInfo
+
+// position of the statement being emitted, reported for calls that have no position of their own
+var callSiteStmtPos token.Pos
+
+// registerFuncInfo makes fnc, emitted as symbol, the function of the following calls.
+func registerFuncInfo(fnc *Func, symbol string) {
+	name := currentPkg.path + "." + fnc.Name
+	if fnc.Method != nil {
+		if fnc.Method.IsPtrMethod {
+			name = currentPkg.path + ".(*" + fnc.Method.RcvNamedType.Name + ")." + fnc.Name
+		} else {
+			name = currentPkg.path + "." + fnc.Method.RcvNamedType.Name + "." + fnc.Name
+		}
+	}
+	callSiteFunc = &funcInfo{
+		label:  ".L.info." + symbol,
+		symbol: symbol,
+		name:   name,
+		file:   fset.Position(fnc.Pos).Filename,
+		line:   fset.Position(fnc.Pos).Line,
+		params: len(fnc.Params),
+	}
+	currentPkg.funcInfos = append(currentPkg.funcInfos, callSiteFunc)
+	callSiteStmtPos = fnc.Pos
+}
+
+// emitCallInstr emits a call of target, which is a symbol or "*%rax",
+// and registers its return address in the call site table.
+// pos is the position of the call, or NoPos for calls the compiler emits by itself.
+func emitCallInstr(target string, pos token.Pos) {
+	printf("  callq %s\n", target)
+	if callSiteFunc == nil {
+		return
+	}
+	if pos == token.NoPos {
+		pos = callSiteStmtPos
+	}
+	site := &callSite{
+		label: fmt.Sprintf(".L.call.%d", len(currentPkg.callSites)),
+		fn:    callSiteFunc,
+		line:  fset.Position(pos).Line,
+	}
+	currentPkg.callSites = append(currentPkg.callSites, site)
+	printf("  %s:\n", site.label)
+}
+
+// markLine registers the code following it as that of a statement at pos.
+// It returns the label to emit there, or "" if the line is the same as the previous statement.
+func markLine(pos token.Pos) string {
+	callSiteStmtPos = pos
+	if callSiteFunc == nil {
+		return ""
+	}
+	line := fset.Position(pos).Line
+	lines := callSiteFunc.lines
+	if len(lines) > 0 && lines[len(lines)-1].line == line {
+		return ""
+	}
+	mark := &lineMark{
+		label: fmt.Sprintf(".L.line.%d", currentPkg.lineMarks),
+		line:  line,
+	}
+	currentPkg.lineMarks++
+	callSiteFunc.lines = append(lines, mark)
+	return mark.label
+}
+
+func emitCallSiteTable(pkg *PkgContainer) {
+	printf("#--- function table\n")
+	printf(".section babygo_functab,\"a\"\n")
+	for _, fn := range pkg.funcInfos {
+		printf("%s:\n", fn.label)
+		printf("  .quad %s # entry\n", fn.symbol)
+		printf("  .quad .L.end.%s\n", fn.symbol)
+		printf("  .quad %s # name\n", runtimeStringLabel(fn.name))
+		printf("  .quad %d\n", len(fn.name))
+		printf("  .quad %s # file\n", runtimeStringLabel(fn.file))
+		printf("  .quad %d\n", len(fn.file))
+		printf("  .quad %d # line\n", fn.line)
+		printf("  .quad %d # params\n", fn.params)
+		printf("  .quad .L.lines.%s\n", fn.symbol)
+		printf("  .quad %d\n", len(fn.lines))
+	}
+	printf(".data\n")
+	for _, fn := range pkg.funcInfos {
+		printf(".L.lines.%s:\n", fn.symbol)
+		for _, mark := range fn.lines {
+			printf("  .long %s - %s, %d\n", mark.label, fn.symbol, mark.line)
+		}
+	}
+	printf("#--- call site table\n")
+	printf(".section babygo_calltab,\"a\"\n")
+	for _, site := range pkg.callSites {
+		printf("  .quad %s, %s, %d\n", site.label, site.fn.label, site.line)
+	}
+}
+
+// emitFrameAlloc clears a storage in the frame and pushes its address.
+func emitFrameAlloc(vr *Variable) {
+	size := getSizeOfType(vr.Typ)
+	if size <= 64 {
+		for off := 0; off < size; off += 8 {
+			printf("  movq $0, %d(%%rbp) # clear %s\n", vr.LocalOffset+off, vr.Name)
+		}
+	} else {
+		printf("  leaq %d(%%rbp), %%rdi # clear %s\n", vr.LocalOffset, vr.Name)
+		printf("  movq $%d, %%rcx\n", (size+7)/8)
+		printf("  movq $0, %%rax\n")
+		printf("  rep stosq\n")
+	}
+	emitVariableAddr(vr)
+}
+
 type MetaStructLiteralElement struct {
 	field     *ast.Field
 	fieldType *Type
@@ -488,7 +735,11 @@ func emitStructLiteral(meta *MetaCompositLit) {
 	// allocate heap area with zero value
 	emitComment(2, "emitStructLiteral\n")
 	structType := meta.typ
-	emitZeroValue(structType) // push address of the new storage
+	if meta.stackSlot != nil {
+		emitFrameAlloc(meta.stackSlot)
+	} else {
+		emitZeroValue(structType) // push address of the new storage
+	}
 	metaElms := meta.strctEements
 	for _, metaElm := range metaElms {
 		// push lhs address
@@ -511,7 +762,11 @@ func emitArrayLiteral(meta *MetaCompositLit) {
 	elmSize := getSizeOfType(elmType)
 	memSize := elmSize * meta.len
 
-	emitCallMalloc(memSize) // push
+	if meta.stackSlot != nil {
+		emitFrameAlloc(meta.stackSlot)
+	} else {
+		emitCallMalloc(memSize) // push
+	}
 	for i, elm := range meta.metaElms {
 		// push lhs address
 		emitPushStackTop(tUintptr, 0, "malloced address")
@@ -525,6 +780,13 @@ func emitArrayLiteral(meta *MetaCompositLit) {
 	}
 }
 
+// emitDivideCheck checks the divisor in %rcx unless it is a constant.
+func emitDivideCheck(meta *MetaBinaryExpr) {
+	if !noRuntimeChecks && !raImmediate(meta.Y) {
+		emitNonZeroCheck("%rcx", "runtime.panicDivide", meta.e.OpPos)
+	}
+}
+
 func emitInvertBoolValue() {
 	emitPopBool("")
 	printf("  xorq $1, %%rax\n")
@@ -651,6 +913,13 @@ func emitCallDirect(symbol string, args []*MetaArg, resultList *ast.FieldList) {
 func emitCall(fv *FuncValue, args []*MetaArg, resultList *ast.FieldList) {
 	emitComment(2, "emitCall len(args)=%d\n", len(args))
 
+	if fv.method != "" {
+		// the receiver is the data word of the interface value
+		emitVariableAddr(fv.ifcVar)
+		emitExpr(fv.expr)
+		emitStore(tEface, true, false)
+	}
+
 	var totalParamSize int
 	var offsets []int
 	for _, arg := range args {
@@ -664,21 +933,26 @@ func emitCall(fv *FuncValue, args []*MetaArg, resultList *ast.FieldList) {
 	emitAllocReturnVarsArea(getTotalFieldsSize(resultList))
 	printf("  subq $%d, %%rsp # alloc parameters area\n", totalParamSize)
 	for i, arg := range args {
-		paramType := arg.paramType
-		if arg.meta == nil {
-			panic("arg.meta should not be nil")
-		}
-		emitExpr(arg.meta)
-		mayEmitConvertTooIfc(arg.meta, paramType)
-		emitPop(kind(paramType))
-		printf("  leaq %d(%%rsp), %%rsi # place to save\n", offsets[i])
-		printf("  pushq %%rsi # place to save\n")
-		emitRegiToMem(paramType)
+		emitArgument(arg, offsets[i])
 	}
 
 	emitCallQ(fv, totalParamSize, resultList)
 }
 
+// emitArgument evaluates arg and stores it in the parameters area at offset.
+func emitArgument(arg *MetaArg, offset int) {
+	paramType := arg.paramType
+	if arg.meta == nil {
+		panic("arg.meta should not be nil")
+	}
+	emitExpr(arg.meta)
+	mayEmitConvertTooIfc(arg.meta, paramType)
+	emitPop(kind(paramType))
+	printf("  leaq %d(%%rsp), %%rsi # place to save\n", offset)
+	printf("  pushq %%rsi # place to save\n")
+	emitRegiToMem(paramType)
+}
+
 func emitAllocReturnVarsAreaFF(ff *ForeignFunc) {
 	emitAllocReturnVarsArea(getTotalFieldsSize(ff.funcType.Results))
 }
@@ -707,9 +981,12 @@ func NewFuncValueFromSymbol(symbol string) *FuncValue {
 }
 
 type FuncValue struct {
-	isDirect bool     // direct or indirect
-	symbol   string   // for direct call
-	expr     MetaExpr // for indirect call
+	isDirect bool      // direct or indirect
+	symbol   string    // for direct call
+	expr     MetaExpr  // for indirect call, or the interface value for a call through an interface
+	method   string    // for a call through an interface: the name of the method
+	ifcVar   *Variable // and the variable holding the interface value
+	pos      token.Pos // position of the call, NoPos for calls the compiler emits by itself
 }
 
 func emitCallQ(fv *FuncValue, totalParamSize int, resultList *ast.FieldList) {
@@ -717,11 +994,15 @@ func emitCallQ(fv *FuncValue, totalParamSize int, resultList *ast.FieldList) {
 		if fv.symbol == "" {
 			panic("callq target must not be empty")
 		}
-		printf("  callq %s\n", fv.symbol)
+		emitCallInstr(fv.symbol, fv.pos)
+	} else if fv.method != "" {
+		emitInterfaceMethodAddr(fv)
+		printf("  popq %%rax\n")
+		emitCallInstr("*%rax", fv.pos)
 	} else {
 		emitExpr(fv.expr)
 		printf("  popq %%rax\n")
-		printf("  callq *%%rax\n")
+		emitCallInstr("*%rax", fv.pos)
 	}
 
 	emitFreeParametersArea(totalParamSize)
@@ -729,6 +1010,20 @@ func emitCallQ(fv *FuncValue, totalParamSize int, resultList *ast.FieldList) {
 	emitFreeAndPushReturnedValue(resultList)
 }
 
+// emitInterfaceMethodAddr pushes the address of the function calling the method of the dynamic type
+// of the interface value held in fv.ifcVar. The function takes the data word of the value as its receiver.
+func emitInterfaceMethodAddr(fv *FuncValue) {
+	printf("  subq $8, %%rsp # result\n")
+	printf("  pushq $%d # method name len\n", len(fv.method))
+	printf("  leaq %s(%%rip), %%rsi # %s\n", runtimeStringLabel(fv.method), fv.method)
+	printf("  pushq %%rsi # method name ptr\n")
+	emitVariableAddr(fv.ifcVar)
+	emitPopAddress("interface value")
+	printf("  pushq (%%rax) # dtype\n")
+	emitCallInstr("runtime.ifaceMethod", fv.pos)
+	printf("  addq $24, %%rsp # free parameters area\n")
+}
+
 // callee
 func emitReturnStmt(meta *MetaReturnStmt) {
 	funcDef := meta.Fnc
@@ -740,6 +1035,11 @@ func emitReturnStmt(meta *MetaReturnStmt) {
 	for i := 0; i < _len; i++ {
 		emitAssignToVar(funcDef.Retvars[i], meta.Results[i])
 	}
+	if raCur != nil {
+		// callee-saved registers are restored by the epilogue
+		printf("  jmp %s # return\n", raCur.epilogue)
+		return
+	}
 	printf("  leave\n")
 	printf("  ret\n")
 }
@@ -763,6 +1063,17 @@ func emitFreeAndPushReturnedValue(resultList *ast.FieldList) {
 			printf("  pushq %%rax\n")
 		case T_BOOL, T_INT, T_UINTPTR, T_POINTER, T_MAP:
 		case T_SLICE:
+		case T_STRUCT, T_ARRAY:
+			// a struct value is referred to by its address, so move it out of the returnvars area
+			size := getSizeOfType(e2t(retval0.Type))
+			emitCallMalloc(size)
+			printf("  popq %%rax # copy of the result\n")
+			printf("  movq %%rsp, %%rsi\n")
+			printf("  movq %%rax, %%rdi\n")
+			printf("  movq $%d, %%rcx\n", size)
+			printf("  rep movsb\n")
+			printf("  addq $%d, %%rsp # free returnvars area\n", getTotalFieldsSize(resultList))
+			printf("  pushq %%rax\n")
 		default:
 			unexpectedKind(knd)
 		}
@@ -951,6 +1262,10 @@ func emitFuncall(meta *MetaCallExpr) {
 
 	emitComment(2, "[emitFuncall] %T(...)\n", meta.fun)
 	// check if it's a builtin func
+	if meta.builtin == gNew && meta.stackSlot != nil {
+		emitFrameAlloc(meta.stackSlot)
+		return
+	}
 	if meta.builtin != nil {
 		emitBuiltinFunCall(meta.builtin, meta.typeArg0, meta.arg0, meta.arg1, meta.arg2)
 		return
@@ -1025,11 +1340,9 @@ func emitSelectorExpr(meta *MetaSelectorExpr) {
 		case ast.Fun:
 			emitFuncAddr(qi)
 		case ast.Var:
-			m := walkIdent(ident, nil)
-			emitExpr(m)
+			emitExpr(meta.foreign)
 		case ast.Con:
-			m := walkIdent(ident, nil)
-			emitExpr(m)
+			emitExpr(meta.foreign)
 		}
 	} else {
 		// strct.field
@@ -1056,7 +1369,12 @@ func emitBasicLit(mt *MetaBasicLit) {
 	case "CHAR":
 		printf("  pushq $%d # convert char literal to int\n", mt.charVal)
 	case "INT":
-		printf("  pushq $%d # number literal\n", mt.intVal)
+		if isImm32(mt.intVal) {
+			printf("  pushq $%d # number literal\n", mt.intVal)
+		} else {
+			printf("  movabsq $%d, %%rax # number literal\n", mt.intVal)
+			printf("  pushq %%rax\n")
+		}
 	case "STRING":
 		sl := mt.strVal
 		if sl.strlen == 0 {
@@ -1160,6 +1478,7 @@ func emitBinaryExpr(meta *MetaBinaryExpr) {
 		emitExpr(meta.X) // left
 		emitExpr(meta.Y) // right
 		printf("  popq %%rcx # right\n")
+		emitDivideCheck(meta)
 		printf("  popq %%rax # left\n")
 		printf("  movq $0, %%rdx # init %%rdx\n")
 		printf("  divq %%rcx\n")
@@ -1169,6 +1488,7 @@ func emitBinaryExpr(meta *MetaBinaryExpr) {
 		emitExpr(meta.X) // left
 		emitExpr(meta.Y) // right
 		printf("  popq %%rcx # right\n")
+		emitDivideCheck(meta)
 		printf("  popq %%rax # left\n")
 		printf("  movq $0, %%rdx # init %%rdx\n")
 		printf("  divq %%rcx\n")
@@ -1227,9 +1547,49 @@ func emitCompositeLit(meta *MetaCompositLit) {
 }
 
 // 1 value list[low:high]
+// emitSliceCheck checks the indices of a slice expression against each other and the capacity of the operand.
+func emitSliceCheck(meta *MetaSliceExpr) {
+	isString := kind(getTypeOfExpr(meta.X)) == T_STRING
+	if isString {
+		emitLen(meta.X)
+	} else {
+		emitCap(meta.X)
+	}
+	if meta.Max != nil {
+		emitExpr(meta.Max)
+	}
+	if meta.High != nil {
+		emitExpr(meta.High)
+	} else {
+		emitLen(meta.X)
+	}
+	emitExpr(meta.Low)
+	printf("  popq %%rcx # low\n")
+	printf("  popq %%rdx # high\n")
+	pos := meta.e.Lbrack
+	if meta.Max != nil {
+		printf("  popq %%r8 # max\n")
+		printf("  popq %%r9 # cap\n")
+		emitBoundsCheck("be", "%r8", "%r9", "runtime.panicSlice3Acap", pos)
+		emitBoundsCheck("be", "%rdx", "%r8", "runtime.panicSlice3B", pos)
+		emitBoundsCheck("be", "%rcx", "%rdx", "runtime.panicSlice3C", pos)
+	} else {
+		printf("  popq %%r8 # cap\n")
+		if isString {
+			emitBoundsCheck("be", "%rdx", "%r8", "runtime.panicSliceAlen", pos)
+		} else {
+			emitBoundsCheck("be", "%rdx", "%r8", "runtime.panicSliceAcap", pos)
+		}
+		emitBoundsCheck("be", "%rcx", "%rdx", "runtime.panicSliceB", pos)
+	}
+}
+
 func emitSliceExpr(meta *MetaSliceExpr) {
 	list := meta.X
 	listType := getTypeOfExpr(list)
+	if !noRuntimeChecks {
+		emitSliceCheck(meta)
+	}
 
 	switch kind(listType) {
 	case T_SLICE, T_ARRAY:
@@ -1355,6 +1715,10 @@ func emitTypeAssertExpr(meta *MetaTypeAssertExpr) {
 	okContext := meta.NeedsOK
 	e := meta.e
 	emitExpr(meta.X)
+	if isInterface(e2t(e.Type)) {
+		emitInterfaceAssert(e2t(e.Type), okContext, e.Lparen)
+		return
+	}
 	emitDtypeLabelAddr(e2t(e.Type))
 	emitCompareDtypes()
 
@@ -1384,6 +1748,47 @@ func emitTypeAssertExpr(meta *MetaTypeAssertExpr) {
 	printf("  %s:\n", labelEnd)
 }
 
+// emitInterfaceAssert converts the interface value on the stack to the interface type t,
+// which succeeds if its dynamic type has the methods of t.
+func emitInterfaceAssert(t *Type, okContext bool, pos token.Pos) {
+	printf("  movq (%%rsp), %%rax # dtype\n")
+	emitImplements("%rax", t, pos)
+	emitPopBool("type assertion ok value")
+	printf("  cmpq $1, %%rax\n")
+
+	labelid++
+	labelEnd := fmt.Sprintf(".L.end_type_assertion.%d", labelid)
+	labelElse := fmt.Sprintf(".L.unmatch.%d", labelid)
+	printf("  jne %s # jmp if false\n", labelElse)
+
+	// if matched, the value stays as it is
+	if okContext {
+		printf("  pushq $1 # ok = true\n")
+	}
+	printf("  jmp %s\n", labelEnd)
+	// if not matched
+	printf("  %s:\n", labelElse)
+	printf("  popq %%rax # drop ifc.dtype\n")
+	printf("  popq %%rax # drop ifc.data\n")
+	emitZeroValue(t)
+	if okContext {
+		printf("  pushq $0 # ok = false\n")
+	}
+	printf("  %s:\n", labelEnd)
+}
+
+// emitImplements pushes whether the dynamic type in the register dtype has the methods of the interface type t.
+func emitImplements(dtype string, t *Type, pos token.Pos) {
+	names := interfaceMethodNames(t)
+	printf("  subq $8, %%rsp # result\n")
+	printf("  pushq $%d # method names len\n", len(names))
+	printf("  leaq %s(%%rip), %%rsi # %s\n", runtimeStringLabel(names), names)
+	printf("  pushq %%rsi # method names ptr\n")
+	printf("  pushq %s # dtype\n", dtype)
+	emitCallInstr("runtime.ifaceImplements", pos)
+	printf("  addq $24, %%rsp # free parameters area\n")
+}
+
 func isNil(meta MetaExpr) bool {
 	m, ok := meta.(*MetaIdent)
 	if !ok {
@@ -1417,11 +1822,22 @@ func emitExpr(meta MetaExpr) {
 		emitBinaryExpr(m)
 	case *MetaTypeAssertExpr:
 		emitTypeAssertExpr(m) // can be Tuple
+	case *MetaInlineCall:
+		emitInlineCall(m)
 	default:
 		panic(fmt.Sprintf("meta type:%T", meta))
 	}
 }
 
+// 1 value
+func emitInlineCall(meta *MetaInlineCall) {
+	emitComment(2, "[emitInlineCall] %s\n", meta.name)
+	for _, param := range meta.params {
+		emitSingleAssign(param.Lhs, param.Rhs)
+	}
+	emitExpr(meta.result)
+}
+
 // convert stack top value to interface
 func emitConvertToInterface(fromType *Type) {
 	emitComment(2, "ConversionToInterface\n")
@@ -1443,13 +1859,14 @@ type dtypeEntry struct {
 	id         int
 	serialized string
 	label      string
+	typ        *Type
 }
 
 var typeId int
 var typesMap map[string]*dtypeEntry
 
-// "**[1][]*int" => "dtype.8"
-func getDtypeLabel(serializedType string) string {
+// "**[1][]*int" => "dtype._2a_2a_5b1_5d_5b_5d_2aint"
+func getDtypeLabel(serializedType string, t *Type) string {
 	s := serializedType
 	ent, ok := typesMap[s]
 	if ok {
@@ -1459,7 +1876,8 @@ func getDtypeLabel(serializedType string) string {
 		ent = &dtypeEntry{
 			id:         id,
 			serialized: serializedType,
-			label:      "." + "dtype." + strconv.Itoa(id),
+			label:      dtypeSymbol(serializedType),
+			typ:        t,
 		}
 		typesMap[s] = ent
 		typeId++
@@ -1468,59 +1886,32 @@ func getDtypeLabel(serializedType string) string {
 	return ent.label
 }
 
-// Check type identity by comparing its serialization, not id or address of dtype label.
+// Check type identity by comparing the addresses of dtype labels.
+// Each type has a single descriptor in the linked program, so identical types have the same address.
 // pop pop, compare and push 1(match) or 0(not match)
-func emitCompareDtypes() {
-	labelid++
-	labelTrue := fmt.Sprintf(".L.cmpdtypes.%d.true", labelid)
-	labelFalse := fmt.Sprintf(".L.cmpdtypes.%d.false", labelid)
-	labelEnd := fmt.Sprintf(".L.cmpdtypes.%d.end", labelid)
-	labelCmp := fmt.Sprintf(".L.cmpdtypes.%d.cmp", labelid)
-	printf("  popq %%rdx           # dtype label address A\n")
-	printf("  popq %%rcx           # dtype label address B\n")
-
-	printf("  cmpq %%rcx, %%rdx\n")
-	printf("  je %s # jump if match\n", labelTrue)
-
-	printf("  cmpq $0, %%rdx # check if A is nil\n")
-	printf("  je %s # jump if nil\n", labelFalse)
-
-	printf("  cmpq $0, %%rcx # check if B is nil\n")
-	printf("  je %s # jump if nil\n", labelFalse)
-
-	printf("  jmp %s # jump to end\n", labelCmp)
-
-	printf("  %s:\n", labelTrue)
-	printf("  pushq $1\n")
-	printf("  jmp %s # jump to end\n", labelEnd)
-
-	printf("  %s:\n", labelFalse)
-	printf("  pushq $0\n")
-	printf("  jmp %s # jump to end\n", labelEnd)
-
-	printf("  %s:\n", labelCmp)
-	emitAllocReturnVarsArea(SizeOfInt) // for bool
-
-	// push len, push ptr
-	printf("  movq 16(%%rax), %%rdx           # str.len of dtype A\n")
-	printf("  pushq %%rdx\n")
-	printf("  movq 8(%%rax), %%rdx           # str.ptr of dtype A\n")
-	printf("  pushq %%rdx\n")
-
-	// push len, push ptr
-	printf("  movq 16(%%rcx), %%rdx           # str.len of dtype B\n")
-	printf("  pushq %%rdx\n")
-	printf("  movq 8(%%rcx), %%rdx           # str.ptr of dtype B\n")
-	printf("  pushq %%rdx\n")
+// dtypeSymbol returns the linker symbol of the descriptor of a type.
+// Characters other than letters, digits and dots are escaped as _XX.
+func dtypeSymbol(serializedType string) string {
+	var buf []byte
+	for _, ch := range []byte(serializedType) {
+		if ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9') || ch == '.' {
+			buf = append(buf, ch)
+		} else {
+			buf = append(buf, '_')
+			buf = append(buf, hexDigits[ch/16])
+			buf = append(buf, hexDigits[ch%16])
+		}
+	}
+	return "dtype." + string(buf)
+}
 
-	printf("  callq %s\n", "runtime.cmpstrings")
-	emitFreeParametersArea(16 * 2)
-	printf("  %s:\n", labelEnd)
+func emitCompareDtypes() {
+	emitCompExpr("sete")
 }
 
 func emitDtypeLabelAddr(t *Type) {
 	serializedType := serializeType(t)
-	dtypeLabel := getDtypeLabel(serializedType)
+	dtypeLabel := getDtypeLabel(serializedType, t)
 	printf("  leaq %s(%%rip), %%rax # dtype label address \"%s\"\n", dtypeLabel, serializedType)
 	printf("  pushq %%rax           # dtype label address\n")
 }
@@ -1560,6 +1951,33 @@ func emitAddrForMapSet(indexExpr *MetaIndexExpr) {
 	emitCallDirect("runtime.getAddrForMapSet", args, resultList)
 }
 
+// emitCheckedElementAddr is emitListElementAddr checking the index against the length of list.
+func emitCheckedElementAddr(list MetaExpr, elmType *Type, pos token.Pos) {
+	t := getTypeOfExpr(list)
+	switch kind(t) {
+	case T_ARRAY:
+		emitAddr(list)
+		printf("  popq %%rax # array head\n")
+		printf("  movq $%d, %%rdx # array len\n", evalInt(getUnderlyingType(t).E.(*ast.ArrayType).Len))
+	case T_SLICE:
+		emitExpr(list)
+		emitPopSlice()
+		printf("  movq %%rcx, %%rdx # slice.len\n")
+	case T_STRING:
+		emitExpr(list)
+		emitPopString()
+		printf("  movq %%rcx, %%rdx # string.len\n")
+	default:
+		unexpectedKind(kind(t))
+	}
+	printf("  popq %%rcx # index id\n")
+	emitBoundsCheck("b", "%rcx", "%rdx", "runtime.panicIndex", pos)
+	printf("  movq $%d, %%rdx # elm size\n", getSizeOfType(elmType))
+	printf("  imulq %%rdx, %%rcx\n")
+	printf("  addq %%rcx, %%rax\n")
+	printf("  pushq %%rax # addr of element\n")
+}
+
 func emitListElementAddr(list MetaExpr, elmType *Type) {
 	emitListHeadAddr(list)
 	emitPopAddress("list head")
@@ -1623,14 +2041,23 @@ func emitBinaryExprComparison(left MetaExpr, right MetaExpr) {
 		emitExpr(left)  // left
 		emitExpr(right) // right
 		emitCallFF(ff)
+	} else if kind(getTypeOfExpr(left)) == T_SLICE || kind(getTypeOfExpr(right)) == T_SLICE {
+		// slice can only be compared to nil: compare its ptr with 0
+		slice := left
+		if isNil(left) {
+			slice = right
+		}
+		emitExpr(slice)
+		emitPopSlice()
+		printf("  pushq %%rax # slice.ptr\n")
+		printf("  pushq $0 # nil\n")
+		emitCompExpr("sete")
 	} else {
 		// Assuming 64 bit types (int, pointer, map, etc)
 		//var t = getTypeOfExpr(left)
 		emitExpr(left)  // left
 		emitExpr(right) // right
 		emitCompExpr("sete")
-
-		//@TODO: slice <=> nil comparison works accidentally, but stack needs to be popped 2 times more.
 	}
 
 }
@@ -2204,10 +2631,14 @@ func emitTypeSwitchStmt(meta *MetaTypeSwitchStmt) {
 
 			if t == nil { // case nil:
 				printf("  pushq $0 # nil\n")
+				emitCompareDtypes()
+			} else if isInterface(t) { // case I:
+				printf("  popq %%rax # dtype label addr\n")
+				emitImplements("%rax", t, token.NoPos)
 			} else { // case T:s
 				emitDtypeLabelAddr(t)
+				emitCompareDtypes()
 			}
-			emitCompareDtypes()
 			emitPopBool(" of switch-case comparison")
 
 			printf("  cmpq $1, %%rax\n")
@@ -2249,10 +2680,12 @@ func emitTypeSwitchStmt(meta *MetaTypeSwitchStmt) {
 				// push rhs
 				emitVariableAddr(meta.SubjectVariable)
 				emitLoadAndPush(tEface)
-				printf("  popq %%rax # ifc.dtype\n")
-				printf("  popq %%rcx # ifc.data\n")
-				printf("  pushq %%rcx # ifc.data\n")
-				emitLoadAndPush(c.Variable.Typ)
+				if !isInterface(c.Variable.Typ) {
+					printf("  popq %%rax # ifc.dtype\n")
+					printf("  popq %%rcx # ifc.data\n")
+					printf("  pushq %%rcx # ifc.data\n")
+					emitLoadAndPush(c.Variable.Typ)
+				}
 
 				// assign
 				emitStore(c.Variable.Typ, true, false)
@@ -2287,13 +2720,36 @@ func emitGoStmt(m *MetaGoStmt) {
 	printf("  movq %%rax, (%%rcx) # malloced area\n") // *area = fn
 	printf("  pushq %%rcx # malloced area\n")
 	printf("  pushq $0 # arg size\n")
-	printf("  callq runtime.newproc\n") // runtime.newproc(0, area)
+	emitCallInstr("runtime.newproc", token.NoPos) // runtime.newproc(0, area)
 	printf("  popq %%rax\n")
 	printf("  popq %%rax\n")
-	printf("  callq runtime.mstart0\n")
+	emitCallInstr("runtime.mstart0", token.NoPos)
+}
+
+func emitHeapAlloc(meta *MetaHeapAlloc) {
+	for _, vr := range meta.Vars {
+		size := getSizeOfType(vr.Typ)
+		emitCallMalloc(size)
+		if vr.LocalOffset > 0 {
+			// a parameter, which lives above the frame pointer
+			printf("  movq (%%rsp), %%rdi # storage of %s\n", vr.Name)
+			printf("  leaq %d(%%rbp), %%rsi # argument %s\n", vr.LocalOffset, vr.Name)
+			printf("  movq $%d, %%rcx\n", size)
+			printf("  rep movsb\n")
+		}
+		printf("  popq %%rax # storage of %s\n", vr.Name)
+		printf("  movq %%rax, %d(%%rbp) # address of %s\n", vr.HeapAddr.LocalOffset, vr.Name)
+	}
 }
 
 func emitStmt(mtstmt MetaStmt) {
+	pos := stmtPos(mtstmt)
+	if pos != token.NoPos {
+		label := markLine(pos)
+		if label != "" {
+			printf("  %s:\n", label)
+		}
+	}
 	switch meta := mtstmt.(type) {
 	case *MetaBlockStmt:
 		emitBlockStmt(meta)
@@ -2331,6 +2787,8 @@ func emitStmt(mtstmt MetaStmt) {
 		emitBranchStmt(meta)
 	case *MetaGoStmt:
 		emitGoStmt(meta)
+	case *MetaHeapAlloc:
+		emitHeapAlloc(meta)
 	default:
 		panic(fmt.Sprintf("unknown type:%T", mtstmt))
 	}
@@ -2373,15 +2831,20 @@ func emitFuncDecl(pkgName string, fnc *Func) {
 	printf("%s: # args %d, locals %d\n", symbol, fnc.Argsarea, fnc.Localarea)
 	printf("  pushq %%rbp\n")
 	printf("  movq %%rsp, %%rbp\n")
-
-	if fnc.Localarea != 0 {
-		printf("  subq $%d, %%rsp # local area\n", -fnc.Localarea)
-	}
-	for _, m := range fnc.Stmts {
-		emitStmt(m)
+	registerFuncInfo(fnc, symbol)
+	if !noRegalloc {
+		emitFuncBodyRA(fnc)
+	} else {
+		if fnc.Localarea != 0 {
+			printf("  subq $%d, %%rsp # local area\n", -fnc.Localarea)
+		}
+		for _, m := range fnc.Stmts {
+			emitStmt(m)
+		}
+		printf("  leave\n")
+		printf("  ret\n")
 	}
-	printf("  leave\n")
-	printf("  ret\n")
+	printf(".L.end.%s:\n", symbol)
 }
 
 func emitGlobalVariable(pkg *PkgContainer, vr *packageVar) {
@@ -2495,6 +2958,8 @@ func emitGlobalVariable(pkg *PkgContainer, vr *packageVar) {
 		switch knd {
 		case T_INT:
 			zeroValue = "  .quad 0 # int zero value\n"
+		case T_BOOL:
+			zeroValue = "  .quad 0 # bool zero value\n"
 		case T_UINT8:
 			zeroValue = "  .byte 0 # uint8 zero value\n"
 		case T_STRING:
@@ -2556,19 +3021,47 @@ func generateCode(pkg *PkgContainer) {
 			emitSingleAssign(vr.metaVar, vr.metaVal)
 		}
 	}
+	for _, funcDecl := range pkg.funcDecls {
+		if funcDecl.Recv == nil && funcDecl.Name.Name == "init" {
+			printf("  callq %s.init\n", pkg.name)
+		}
+	}
 	printf("  ret\n")
 
+	if pkg.name == "main" {
+		// the runtime calls this to initialize the packages in the order of their dependencies
+		printf(".global main.__initPackages\n")
+		printf("main.__initPackages:\n")
+		for _, name := range initOrder {
+			if name != "runtime" {
+				printf("  callq %s.__initGlobals\n", name)
+			}
+		}
+		printf("  ret\n")
+	}
+
 	for _, fnc := range pkg.funcs {
 		emitFuncDecl(pkg.name, fnc)
 	}
+	callSiteFunc = nil
+
+	emitCallSiteTable(pkg)
+	printf("#--- strings read by the runtime\n")
+	printf(".data\n")
+	for i, s := range pkg.runtimeStrings {
+		printf(".rtstr_%d:\n", i)
+		printf("  .string \"%s\"\n", s)
+	}
 
 	emitDynamicTypes(typesMap)
 	printf("\n")
 }
 
+// emitDynamicTypes emits the descriptors of the types converted to interfaces in the package.
+// Every package using a type emits its descriptor in a COMDAT group named after it,
+// and the linker keeps only one of them.
 func emitDynamicTypes(mapDtypes map[string]*dtypeEntry) {
 	printf("# ------- Dynamic Types ------\n")
-	printf(".data\n")
 
 	sliceTypeMap := make([]string, len(mapDtypes)+1, len(mapDtypes)+1)
 
@@ -2577,2309 +3070,5763 @@ func emitDynamicTypes(mapDtypes map[string]*dtypeEntry) {
 		sliceTypeMap[ent.id] = key
 	}
 
+	// a descriptor refers to the descriptors of its element, key and field types,
+	// which are registered in turn, so that they get the next ids
+	for id := 1; id < len(sliceTypeMap); id++ {
+		for _, t := range dtypeComponents(mapDtypes[sliceTypeMap[id]].typ) {
+			serialized := serializeType(t)
+			_, ok := mapDtypes[serialized]
+			if !ok {
+				getDtypeLabel(serialized, t)
+				sliceTypeMap = append(sliceTypeMap, serialized)
+			}
+		}
+	}
+
 	// skip id=0
 	for id := 1; id < len(sliceTypeMap); id++ {
 		key := sliceTypeMap[id]
 		ent := mapDtypes[key]
 
+		errorMethod := dtypeStringMethod(ent.typ, "Error")
+		stringMethod := dtypeStringMethod(ent.typ, "String")
+		methods := dtypeMethods(ent.typ)
+		ifaceMethods := dtypeInterfaceMethods(ent.typ)
+		fields := dtypeFields(ent.typ)
+		printf(".section .data.%s,\"awG\",@progbits,%s,comdat\n", ent.label, ent.label)
+		printf(".weak %s\n", ent.label)
 		printf("%s: # %s\n", ent.label, key)
-		printf("  .quad %d\n", id)
-		printf("  .quad .string.dtype.%d\n", id)
+		printf("  .quad 0 # id\n")
+		printf("  .quad .L.%s.name\n", ent.label)
 		printf("  .quad %d\n", len(ent.serialized))
-		printf(".string.dtype.%d:\n", id)
+		printf("  .quad %d # kind\n", dtypeKind(ent.typ))
+		printf("  .quad %s # Error\n", dtypeMethodWrapper(ent, errorMethod))
+		printf("  .quad %s # String\n", dtypeMethodWrapper(ent, stringMethod))
+		printf("  .quad %d # size\n", getSizeOfType(ent.typ))
+		printf("  .quad .L.%s.methods\n", ent.label)
+		printf("  .quad %d # number of methods\n", len(methods)+len(ifaceMethods))
+		printf("  .quad %d # reflect kind\n", dtypeReflectKind(ent.typ))
+		printf("  .quad %s # elem\n", dtypeComponentLabel(dtypeElem(ent.typ)))
+		printf("  .quad %s # key\n", dtypeComponentLabel(dtypeKey(ent.typ)))
+		printf("  .quad %d # array length\n", dtypeLen(ent.typ))
+		printf("  .quad .L.%s.fields\n", ent.label)
+		printf("  .quad %d # number of fields\n", len(fields))
+		printf(".L.%s.name:\n", ent.label)
 		printf("  .string \"%s\"\n", ent.serialized)
+		printf(".L.%s.fields:\n", ent.label)
+		for i, field := range fields {
+			printf("  .quad .L.%s.field.%d\n", ent.label, i)
+			printf("  .quad %d\n", len(field.Names[0].Name))
+			printf("  .quad %s\n", dtypeComponentLabel(e2t(field.Type)))
+			printf("  .quad %d # offset\n", getStructFieldOffset(field))
+		}
+		for i, field := range fields {
+			printf(".L.%s.field.%d:\n", ent.label, i)
+			printf("  .string \"%s\"\n", field.Names[0].Name)
+		}
+		printf(".L.%s.methods:\n", ent.label)
+		for _, m := range methods {
+			printf("  .quad .L.%s.name.%s\n", ent.label, m.Name)
+			printf("  .quad %d\n", len(m.Name))
+			printf("  .quad %s\n", dtypeMethodWrapper(ent, m))
+		}
+		for _, name := range ifaceMethods {
+			printf("  .quad .L.%s.name.%s\n", ent.label, name)
+			printf("  .quad %d\n", len(name))
+			printf("  .quad 0 # interface method\n")
+		}
+		for _, m := range methods {
+			printf(".L.%s.name.%s:\n", ent.label, m.Name)
+			printf("  .string \"%s\"\n", m.Name)
+		}
+		for _, name := range ifaceMethods {
+			printf(".L.%s.name.%s:\n", ent.label, name)
+			printf("  .string \"%s\"\n", name)
+		}
+		if len(methods) > 0 {
+			printf(".section .text.%s,\"axG\",@progbits,%s,comdat\n", ent.label, ent.label)
+			for _, m := range methods {
+				emitDtypeMethodWrapper(ent, m)
+			}
+		}
 	}
+	printf(".data\n")
 	printf("\n")
 }
 
-// --- type ---
-type Type struct {
-	E       ast.Expr // original
-	PkgName string
-	Name    string
+// dtypeKind returns the kind of the values of t printed by the runtime:
+// 1 bool, 2 int, 3 int32, 4 uint8, 5 uint16, 6 uintptr, 7 string, 8 pointer, map or func, 0 otherwise.
+func dtypeKind(t *Type) int {
+	switch kind(t) {
+	case T_BOOL:
+		return 1
+	case T_INT:
+		return 2
+	case T_INT32:
+		return 3
+	case T_UINT8:
+		return 4
+	case T_UINT16:
+		return 5
+	case T_UINTPTR:
+		return 6
+	case T_STRING:
+		return 7
+	case T_POINTER, T_MAP, T_FUNC:
+		return 8
+	}
+	return 0
 }
 
-type TypeKind string
+// dtypeReflectKind returns the kind of t numbered like reflect.Kind.
+func dtypeReflectKind(t *Type) int {
+	if serializeType(t) == "unsafe.Pointer" {
+		return 26
+	}
+	switch kind(t) {
+	case T_BOOL:
+		return 1
+	case T_INT:
+		return 2
+	case T_INT32:
+		return 5
+	case T_UINT8:
+		return 8
+	case T_UINT16:
+		return 9
+	case T_UINTPTR:
+		return 12
+	case T_ARRAY:
+		return 17
+	case T_FUNC:
+		return 19
+	case T_INTERFACE:
+		return 20
+	case T_MAP:
+		return 21
+	case T_POINTER:
+		return 22
+	case T_SLICE:
+		return 23
+	case T_STRING:
+		return 24
+	case T_STRUCT:
+		return 25
+	}
+	return 0
+}
 
-const T_STRING TypeKind = "T_STRING"
-const T_INTERFACE TypeKind = "T_INTERFACE"
-const T_FUNC TypeKind = "T_FUNC"
-const T_SLICE TypeKind = "T_SLICE"
-const T_BOOL TypeKind = "T_BOOL"
-const T_INT TypeKind = "T_INT"
-const T_INT32 TypeKind = "T_INT32"
-const T_UINT8 TypeKind = "T_UINT8"
-const T_UINT16 TypeKind = "T_UINT16"
-const T_UINTPTR TypeKind = "T_UINTPTR"
-const T_ARRAY TypeKind = "T_ARRAY"
-const T_STRUCT TypeKind = "T_STRUCT"
-const T_POINTER TypeKind = "T_POINTER"
-const T_MAP TypeKind = "T_MAP"
-
-// types of an expr in Single value context
-func getTypeOfExpr(meta MetaExpr) *Type {
-	switch m := meta.(type) {
-	case *MetaBasicLit:
-		return m.typ
-	case *MetaCompositLit:
-		return m.typ
-	case *MetaIdent:
-		return m.typ
-	case *MetaSelectorExpr:
-		return getTypeOfExprAst(m.e)
-	case *MetaCallExpr: // funcall or conversion
-		return m.typ // can be nil. if Tuple , m.types has types
-	case *MetaIndexExpr:
-		return m.typ
-	case *MetaSliceExpr:
-		return m.typ
-	case *MetaStarExpr:
-		return m.typ
-	case *MetaUnaryExpr:
-		return m.typ
-	case *MetaBinaryExpr:
-		return m.typ
-	case *MetaTypeAssertExpr:
-		return m.typ
+// dtypeElem returns the element type of a pointer, slice, array or map type t, or nil.
+func dtypeElem(t *Type) *Type {
+	if serializeType(t) == "unsafe.Pointer" {
+		return nil
 	}
-	panic("bad type\n")
-}
-
-func getTypeOfExprAst(expr ast.Expr) *Type {
-	switch e := expr.(type) {
-	case *ast.Ident:
-		assert(e.Obj != nil, "Obj is nil in ident '"+e.Name+"'", __func__)
-		switch e.Obj.Kind {
-		case ast.Var:
-			variable, isVariable := e.Obj.Data.(*Variable)
-			if isVariable {
-				return variable.Typ
-			}
-			panic("Variable is not set for ident:" + e.Name)
-		case ast.Con:
-			switch e.Obj {
-			case gTrue, gFalse:
-				return tBool
-			default:
-				switch decl2 := e.Obj.Decl.(type) {
-				case *ast.ValueSpec:
-					return e2t(decl2.Type)
-				default:
-					panic("cannot decide type of cont =" + e.Obj.Name)
-				}
-			}
-		case ast.Fun:
-			return e2t(e.Obj.Decl.(*ast.FuncDecl).Type)
-		default:
-			panic(fmt.Sprintf("Obj=%s, Kind=%s\t\n%s", e.Obj.Name, e.Obj.Kind.String(), fset.Position(e.Pos())))
-		}
-	case *ast.BasicLit:
-		// The default type of an untyped constant is bool, rune, int, float64, complex128 or string respectively,
-		// depending on whether it is a boolean, rune, integer, floating-point, complex, or string constant.
-		switch e.Kind.String() {
-		case "STRING":
-			return tString
-		case "INT":
-			return tInt
-		case "CHAR":
-			return tInt32
-		default:
-			panic(e.Kind.String())
-		}
-	case *ast.UnaryExpr:
-		switch e.Op.String() {
-		case "+":
-			return getTypeOfExprAst(e.X)
-		case "-":
-			return getTypeOfExprAst(e.X)
-		case "!":
-			return tBool
-		case "&":
-			t := getTypeOfExprAst(e.X)
-			starExpr := &ast.StarExpr{
-				X: t.E,
-			}
-			return e2t(starExpr)
-		default:
-			panic(e.Op.String())
-		}
-	case *ast.BinaryExpr:
-		switch e.Op.String() {
-		case "==", "!=", "<", ">", "<=", ">=":
-			return tBool
-		default:
-			return getTypeOfExprAst(e.X)
-		}
-	case *ast.IndexExpr:
-		list := e.X
-		return getElementTypeOfCollectionType(getTypeOfExprAst(list))
-	case *ast.CallExpr: // funcall or conversion
-		types := getCallResultTypes(e)
-		assert(len(types) == 1, "Single value is expected", __func__)
-		return types[0]
-	case *ast.SliceExpr:
-		underlyingCollectionType := getTypeOfExprAst(e.X)
-		if kind(underlyingCollectionType) == T_STRING {
-			// str2 = str1[n:m]
-			return tString
-		}
-		var elementTyp ast.Expr
-		switch colType := underlyingCollectionType.E.(type) {
-		case *ast.ArrayType:
-			elementTyp = colType.Elt
-		}
-		r := &ast.ArrayType{
-			Len: nil,
-			Elt: elementTyp,
-		}
-		return e2t(r)
-	case *ast.StarExpr:
-		t := getTypeOfExprAst(e.X)
-		ptrType := t.E.(*ast.StarExpr)
-		return e2t(ptrType.X)
-	case *ast.SelectorExpr:
-		if isQI(e) { // pkg.SomeType
-			ident := lookupForeignIdent(selector2QI(e))
-			return getTypeOfExprAst(ident)
-		} else { // (e).field
-			ut := getUnderlyingType(getTypeOfExprAst(e.X))
-			var structTypeLiteral *ast.StructType
-			switch typ := ut.E.(type) {
-			case *ast.StructType: // strct.field
-				structTypeLiteral = typ
-			case *ast.StarExpr: // ptr.field
-				structType := e2t(typ.X)
-				structTypeLiteral = getUnderlyingStructType(structType)
-			}
-			field := lookupStructField(structTypeLiteral, e.Sel.Name)
-			return e2t(field.Type)
-		}
-	case *ast.CompositeLit:
-		return e2t(e.Type)
-	case *ast.ParenExpr:
-		return getTypeOfExprAst(e.X)
-	case *ast.TypeAssertExpr:
-		return e2t(e.Type)
+	ut := getUnderlyingType(t)
+	switch kind(ut) {
+	case T_POINTER:
+		return e2t(ut.E.(*ast.StarExpr).X)
+	case T_SLICE, T_ARRAY, T_MAP:
+		return getElementTypeOfCollectionType(ut)
 	}
-	panic("bad type\n")
+	return nil
 }
 
-func fieldList2Types(fldlist *ast.FieldList) []*Type {
-	var r []*Type
-	for _, e2 := range fldlist.List {
-		t := e2t(e2.Type)
-		r = append(r, t)
+// dtypeKey returns the key type of a map type t, or nil.
+func dtypeKey(t *Type) *Type {
+	ut := getUnderlyingType(t)
+	if kind(ut) != T_MAP {
+		return nil
 	}
-	return r
+	return e2t(ut.E.(*ast.MapType).Key)
 }
 
-func getTupleTypes(rhsMeta MetaExpr) []*Type {
-	if IsOkSyntax(rhsMeta) {
-		return []*Type{getTypeOfExpr(rhsMeta), tBool}
-	} else {
-		rhs, ok := rhsMeta.(*MetaCallExpr)
-		if !ok {
-			panic("is not *MetaCallExpr")
-		}
-		return getCallResultTypes(rhs.e)
+// dtypeLen returns the length of an array type t, or 0.
+func dtypeLen(t *Type) int {
+	ut := getUnderlyingType(t)
+	if kind(ut) != T_ARRAY {
+		return 0
 	}
+	return evalInt(ut.E.(*ast.ArrayType).Len)
 }
 
-func getCallResultTypes(e *ast.CallExpr) []*Type {
-	switch fn := e.Fun.(type) {
-	case *ast.Ident:
-		if fn.Obj == nil {
-			throw(fn)
-		}
-		switch fn.Obj.Kind {
-		case ast.Var:
-			switch dcl := fn.Obj.Decl.(type) {
-			case *ast.AssignStmt: // short var decl
-				rhs := dcl.Rhs[0]
-				switch r := rhs.(type) {
-				case *ast.SelectorExpr:
-					assert(isQI(r), "expect QI", __func__)
-					ff := lookupForeignFunc(selector2QI(r))
-					return fieldList2Types(ff.funcType.Results)
-				}
-			case *ast.ValueSpec: // var v func(T1)T2
-				return fieldList2Types(dcl.Type.(*ast.FuncType).Results)
-			default:
-				throw(dcl)
-			}
-		case ast.Typ: // conversion
-			return []*Type{e2t(fn)}
-		case ast.Fun:
-			switch fn.Obj {
-			case gLen, gCap:
-				return []*Type{tInt}
-			case gNew:
-				starExpr := &ast.StarExpr{
-					X: e.Args[0],
-				}
-				// walkExpr(starExpr, nil) // Do we need to walk type ?
-				return []*Type{e2t(starExpr)}
-			case gMake:
-				return []*Type{e2t(e.Args[0])}
-			case gAppend:
-				return []*Type{e2t(e.Args[0])}
-			}
-			decl := fn.Obj.Decl
-			if decl == nil {
-				panic("decl of function " + fn.Name + " should not nil")
-			}
-			switch dcl := decl.(type) {
-			case *ast.FuncDecl:
-				return fieldList2Types(dcl.Type.Results)
-			default:
-				throw(decl)
-			}
-		}
-	case *ast.ParenExpr: // (X)(e) funcall or conversion
-		if isType(fn.X) {
-			return []*Type{e2t(fn.X)}
-		} else {
-			panic("TBI: what should we do ?")
-		}
-	case *ast.ArrayType: // conversion [n]T(e) or []T(e)
-		return []*Type{e2t(fn)}
-	case *ast.SelectorExpr:
-		if isType(fn) {
-			return []*Type{e2t(fn)}
-		}
-		if isQI(fn) { // pkg.Sel()
-			ff := lookupForeignFunc(selector2QI(fn))
-			return fieldList2Types(ff.funcType.Results)
-		} else { // obj.method()
-			rcvType := getTypeOfExprAst(fn.X)
-			method := lookupMethod(rcvType, fn.Sel)
-			return fieldList2Types(method.FuncType.Results)
-		}
-	case *ast.InterfaceType:
-		return []*Type{tEface}
+// dtypeFields returns the fields of a struct type t, whose offsets are set.
+func dtypeFields(t *Type) []*ast.Field {
+	ut := getUnderlyingType(t)
+	if kind(ut) != T_STRUCT {
+		return nil
 	}
-
-	throw(e)
-	return nil
+	structType := ut.E.(*ast.StructType)
+	if structType.Fields == nil {
+		return nil
+	}
+	calcStructSizeAndSetFieldOffset(structType)
+	return structType.Fields.List
 }
 
-func e2t(typeExpr ast.Expr) *Type {
-	if typeExpr == nil {
-		panic("nil is not allowed")
+// dtypeComponents returns the types whose descriptors the descriptor of t refers to.
+func dtypeComponents(t *Type) []*Type {
+	var types []*Type
+	elem := dtypeElem(t)
+	if elem != nil {
+		types = append(types, elem)
 	}
-	return &Type{
-		E: typeExpr,
+	key := dtypeKey(t)
+	if key != nil {
+		types = append(types, key)
+	}
+	for _, field := range dtypeFields(t) {
+		types = append(types, e2t(field.Type))
 	}
+	return types
 }
 
-func serializeType(t *Type) string {
+// dtypeComponentLabel returns the label of the descriptor of t, or "0" if t is nil.
+func dtypeComponentLabel(t *Type) string {
 	if t == nil {
-		panic("nil type is not expected")
+		return "0"
 	}
-	if t.E == generalSlice {
-		panic("TBD: generalSlice")
-	}
-	if t.Name != "" {
-		return t.PkgName + "." + t.Name
+	return getDtypeLabel(serializeType(t), t)
+}
+
+// dtypeNamedType returns the methods of the named type of t or of the named type t points to,
+// and whether t is a pointer.
+func dtypeNamedType(t *Type) (*NamedType, bool) {
+	e := t.E
+	star, isPtr := e.(*ast.StarExpr)
+	if isPtr {
+		e = star.X
 	}
-	switch e := t.E.(type) {
+	var typeObj *ast.Object
+	switch typ := e.(type) {
 	case *ast.Ident:
-		if e.Obj == nil {
-			panic("Unresolved identifier:" + e.Name)
-		}
-		if e.Obj.Kind == ast.Var {
-			throw(e.Obj)
-		} else if e.Obj.Kind == ast.Typ {
-			switch e.Obj {
-			case gUintptr:
-				return "uintptr"
-			case gInt:
-				return "int"
-			case gString:
-				return "string"
-			case gUint8:
-				return "uint8"
-			case gUint16:
-				return "uint16"
-			case gBool:
-				return "bool"
-			case gError:
-				return "error"
-			default:
-				// named type
-				decl := e.Obj.Decl
-				typeSpec := decl.(*ast.TypeSpec)
-				pkgName := typeSpec.Name.Obj.Data.(string)
-				return pkgName + "." + typeSpec.Name.Name
-			}
-		}
-	case *ast.StructType:
-		r := "struct{"
-		if e.Fields != nil {
-			for _, field := range e.Fields.List {
-				name := field.Names[0].Name
-				typ := e2t(field.Type)
-				r += fmt.Sprintf("%s %s;", name, serializeType(typ))
-			}
-		}
-		return r + "}"
-	case *ast.ArrayType:
-		if e.Len == nil {
-			if e.Elt == nil {
-				panic(e)
-			}
-			return "[]" + serializeType(e2t(e.Elt))
-		} else {
-			return "[" + strconv.Itoa(evalInt(e.Len)) + "]" + serializeType(e2t(e.Elt))
-		}
-	case *ast.StarExpr:
-		return "*" + serializeType(e2t(e.X))
-	case *ast.Ellipsis: // x ...T
-		panic("TBD: Ellipsis")
-	case *ast.InterfaceType:
-		return "interface{}" // @TODO list methods
-	case *ast.MapType:
-		return "map[" + serializeType(e2t(e.Key)) + "]" + serializeType(e2t(e.Value))
+		typeObj = typ.Obj
 	case *ast.SelectorExpr:
-		qi := selector2QI(e)
-		return string(qi)
-	case *ast.FuncType:
-		return "func"
+		typeObj = lookupForeignIdent(selector2QI(typ)).Obj
 	default:
-		throw(t)
+		return nil, false
 	}
-	return ""
+	namedType, ok := MethodSets[unsafe.Pointer(typeObj)]
+	if !ok {
+		return nil, false
+	}
+	return namedType, isPtr
 }
 
-func getUnderlyingStructType(t *Type) *ast.StructType {
-	ut := getUnderlyingType(t)
-	return ut.E.(*ast.StructType)
+// dtypeMethods returns the method set of t in the order of the declarations.
+func dtypeMethods(t *Type) []*Method {
+	namedType, isPtr := dtypeNamedType(t)
+	if namedType == nil {
+		return nil
+	}
+	var methods []*Method
+	for _, name := range namedType.names {
+		method := namedType.methodSet[name]
+		if method.IsPtrMethod && !isPtr {
+			continue
+		}
+		methods = append(methods, method)
+	}
+	return methods
 }
 
-func getUnderlyingType(t *Type) *Type {
-	if t == nil {
-		panic("nil type is not expected")
+// dtypeInterfaceMethods returns the method names of an interface type t, which the runtime matches
+// against the method tables of dynamic types, or nil if t is not an interface.
+func dtypeInterfaceMethods(t *Type) []string {
+	if kind(t) != T_INTERFACE {
+		return nil
 	}
-	if t.E == generalSlice {
-		return t
+	it := getUnderlyingType(t).E.(*ast.InterfaceType)
+	var names []string
+	if it.Methods != nil {
+		for _, field := range it.Methods.List {
+			names = append(names, field.Names[0].Name)
+		}
 	}
+	return names
+}
 
-	switch e := t.E.(type) {
-	case *ast.StructType, *ast.ArrayType, *ast.StarExpr, *ast.Ellipsis, *ast.MapType, *ast.InterfaceType:
-		// type literal
-		return t
-	case *ast.Ident:
-		assert(e.Obj != nil, "should not be nil : "+e.Name, __func__)
-		assert(e.Obj.Kind == ast.Typ, "should be ast.Typ : "+e.Name, __func__)
-		switch e.Obj {
-		case gUintptr, gInt, gInt32, gString, gUint8, gUint16, gBool:
-			return t
-		case gError:
-			return e2t(&ast.InterfaceType{
-				Methods: nil, //  @FIXME
-			})
-		}
-		// defined type or alias
-		typeSpec := e.Obj.Decl.(*ast.TypeSpec)
-		// get RHS in its type definition recursively
-		return getUnderlyingType(e2t(typeSpec.Type))
-	case *ast.SelectorExpr:
-		ident := lookupForeignIdent(selector2QI(e))
-		return getUnderlyingType(e2t(ident))
-	case *ast.ParenExpr:
-		return getUnderlyingType(e2t(e.X))
-	case *ast.FuncType:
-		return t
+// dtypeStringMethod returns the method func() string named name in the method set of t, or nil if there is none.
+func dtypeStringMethod(t *Type, name string) *Method {
+	namedType, isPtr := dtypeNamedType(t)
+	if namedType == nil {
+		return nil
 	}
-	throw(t.E)
-	return nil
+	method, ok := namedType.methodSet[name]
+	if !ok || (method.IsPtrMethod && !isPtr) {
+		return nil
+	}
+	ft := method.FuncType
+	if (ft.Params != nil && len(ft.Params.List) > 0) || ft.Results == nil || len(ft.Results.List) != 1 || len(ft.Results.List[0].Names) > 1 {
+		return nil
+	}
+	if kind(e2t(ft.Results.List[0].Type)) != T_STRING {
+		return nil
+	}
+	return method
 }
 
-func kind(t *Type) TypeKind {
+// dtypeMethodWrapper returns the label of the function calling method on a value stored in an interface,
+// or "0" if method is nil.
+func dtypeMethodWrapper(ent *dtypeEntry, method *Method) string {
+	if method == nil {
+		return "0"
+	}
+	return ".L." + ent.label + ".fn." + method.Name
+}
+
+// emitDtypeMethodWrapper emits a function taking the data word of an interface value in place of the receiver,
+// which calls method with the receiver stored at data and the same parameters and results.
+func emitDtypeMethodWrapper(ent *dtypeEntry, method *Method) {
+	printf("%s:\n", dtypeMethodWrapper(ent, method))
+	size := 8
+	if !method.IsPtrMethod {
+		size = getSizeOfType(e2t(method.RcvNamedType))
+	}
+	printf("  movq 8(%%rsp), %%rax # data\n")
+	if kind(ent.typ) == T_POINTER && !method.IsPtrMethod {
+		printf("  movq (%%rax), %%rax # pointer to the receiver\n")
+	}
+	if size == 8 {
+		// the receiver is a word: replace the data word with it
+		printf("  movq (%%rax), %%rax # receiver\n")
+		printf("  movq %%rax, 8(%%rsp)\n")
+		printf("  jmp %s\n", getMethodSymbol(method))
+		return
+	}
+
+	// copy the receiver and the parameters to a new arguments area, and the results back
+	paramsSize := getTotalFieldsSize(method.FuncType.Params)
+	resultsSize := getTotalFieldsSize(method.FuncType.Results)
+	printf("  pushq %%rbp\n")
+	printf("  movq %%rsp, %%rbp\n")
+	printf("  subq $%d, %%rsp # arguments area\n", size+paramsSize+resultsSize)
+	printf("  movq %%rax, %%rsi\n")
+	printf("  movq %%rsp, %%rdi\n")
+	printf("  movq $%d, %%rcx\n", size)
+	printf("  rep movsb # receiver\n")
+	printf("  leaq 24(%%rbp), %%rsi\n")
+	printf("  leaq %d(%%rsp), %%rdi\n", size)
+	printf("  movq $%d, %%rcx\n", paramsSize)
+	printf("  rep movsb # parameters\n")
+	printf("  callq %s\n", getMethodSymbol(method))
+	printf("  leaq %d(%%rsp), %%rsi\n", size+paramsSize)
+	printf("  leaq %d(%%rbp), %%rdi\n", 24+paramsSize)
+	printf("  movq $%d, %%rcx\n", resultsSize)
+	printf("  rep movsb # results\n")
+	printf("  leave\n")
+	printf("  ret\n")
+}
+
+// --- register allocation ---
+// The register backend lowers the meta tree of a function to a three-address code
+// working on virtual registers, allocates machine registers to them by linear scan and emits the result.
+//
+// Statements and expressions it does not lower are emitted by the stack machine emitter
+// and embedded in the code as they are.
+// Scalar variables live in virtual registers, unless their address is taken
+// or they are accessed by such embedded code.
+
+// -noregalloc: emit every function by the stack machine emitter
+var noRegalloc bool
+
+type raInstr struct {
+	op      string
+	dst     int       // virtual register defined by the instruction, 0 if none
+	src1    int       // first virtual register used by the instruction, 0 if none
+	src2    int       // second virtual register used by the instruction, 0 if none
+	imm     int       // immediate operand, memory offset or size
+	useImm  bool      // imm is the second operand instead of src2
+	cc      string    // condition code of set and cmpbr
+	knd     TypeKind  // type of the value loaded or stored
+	sym     string    // label, symbol or memory operand
+	text    []string  // code of asm
+	targets []string  // labels the code of asm may jump to
+	pos     token.Pos // position of call
+}
+
+type raFunc struct {
+	fnc      *Func
+	code     []*raInstr
+	nvreg    int
+	nvars    int                 // virtual registers 1..nvars hold variables
+	frame    int                 // lowest offset of the stack frame in use
+	loops    []*MetaForContainer // enclosing loops lowered by the register backend
+	epilogue string
+	retry    bool     // a variable assumed to be in a register has been accessed in memory
+	loc      []string // machine register or stack slot of each virtual register
+	saved    []string // callee-saved registers in use
+}
+
+// function being lowered
+var raCur *raFunc
+
+// code of the stack machine emitter is captured instead of being written
+var asmCapture bool
+var capturedAsm []string
+
+// raNumCalleeSaved is the number of callee-saved registers at the head of raRegisters.
+// The stack machine code and the runtime never use them,
+// so they keep their values across calls and embedded code.
+var raNumCalleeSaved int = 5
+
+func raRegisters() []string {
+	return []string{"%rbx", "%r12", "%r13", "%r14", "%r15", "%rsi", "%rdi", "%r8", "%r9", "%r10", "%r11"}
+}
+
+// raScalar reports whether a value of type t is held in a register.
+func raScalar(t *Type) bool {
 	if t == nil {
-		panic("nil type is not expected")
+		return false
+	}
+	switch kind(t) {
+	case T_INT, T_BOOL, T_UINT8, T_UINT16, T_UINTPTR, T_POINTER:
+		return true
 	}
+	return false
+}
 
-	ut := getUnderlyingType(t)
-	if ut.E == generalSlice {
-		return T_SLICE
+// raPromotable reports whether the variable vr can live in a virtual register.
+func raPromotable(vr *Variable) bool {
+	if vr.IsGlobal || vr.AddrTaken {
+		return false
+	}
+	switch kind(vr.Typ) {
+	case T_INT, T_BOOL, T_UINTPTR, T_POINTER:
+		return true
 	}
+	return false
+}
 
-	switch e := ut.E.(type) {
-	case *ast.Ident:
-		assert(e.Obj.Kind == ast.Typ, "should be ast.Typ", __func__)
-		switch e.Obj {
-		case gUintptr:
-			return T_UINTPTR
-		case gInt:
-			return T_INT
-		case gInt32:
-			return T_INT32
-		case gString:
-			return T_STRING
-		case gUint8:
-			return T_UINT8
-		case gUint16:
-			return T_UINT16
-		case gBool:
-			return T_BOOL
-		case gError:
-			return T_INTERFACE
-		default:
-			panic("Unexpected type")
-		}
-	case *ast.StructType:
-		return T_STRUCT
-	case *ast.ArrayType:
-		if e.Len == nil {
-			return T_SLICE
-		} else {
-			return T_ARRAY
-		}
-	case *ast.StarExpr:
-		return T_POINTER
-	case *ast.Ellipsis: // x ...T
-		return T_SLICE // @TODO is this right ?
-	case *ast.MapType:
-		return T_MAP
-	case *ast.InterfaceType:
-		return T_INTERFACE
-	case *ast.FuncType:
-		return T_FUNC
+func (f *raFunc) emit(in *raInstr) *raInstr {
+	f.code = append(f.code, in)
+	return in
+}
+
+func (f *raFunc) newVreg() int {
+	f.nvreg++
+	return f.nvreg
+}
+
+func (f *raFunc) li(imm int) int {
+	dst := f.newVreg()
+	f.emit(&raInstr{op: "li", dst: dst, imm: imm})
+	return dst
+}
+
+func (f *raFunc) mov(dst int, src int) {
+	// retarget the instruction which has just computed src into a temporary
+	last := f.code[len(f.code)-1]
+	if src > f.nvars && last.dst == src && last.op != "label" {
+		last.dst = dst
+		return
 	}
-	panic("should not reach here")
+	f.emit(&raInstr{op: "mov", dst: dst, src1: src})
 }
 
-func isInterface(t *Type) bool {
-	return kind(t) == T_INTERFACE
+func (f *raFunc) binop(op string, a int, b int) int {
+	dst := f.newVreg()
+	f.emit(&raInstr{op: op, dst: dst, src1: a, src2: b})
+	return dst
 }
 
-func getElementTypeOfCollectionType(t *Type) *Type {
-	ut := getUnderlyingType(t)
-	switch kind(ut) {
-	case T_SLICE, T_ARRAY:
-		switch e := ut.E.(type) {
-		case *ast.ArrayType:
-			return e2t(e.Elt)
-		case *ast.Ellipsis:
-			return e2t(e.Elt)
-		default:
-			throw(t.E)
-		}
-	case T_STRING:
-		return tUint8
-	case T_MAP:
-		mapType := ut.E.(*ast.MapType)
-		return e2t(mapType.Value)
-	default:
-		unexpectedKind(kind(t))
+func (f *raFunc) binopImm(op string, a int, imm int) int {
+	dst := f.newVreg()
+	f.emit(&raInstr{op: op, dst: dst, src1: a, imm: imm, useImm: true})
+	return dst
+}
+
+func (f *raFunc) addImm(a int, imm int) int {
+	if imm == 0 {
+		return a
 	}
-	return nil
+	return f.binopImm("add", a, imm)
 }
 
-func getKeyTypeOfCollectionType(t *Type) *Type {
-	ut := getUnderlyingType(t)
-	switch kind(ut) {
-	case T_SLICE, T_ARRAY, T_STRING:
-		return tInt
-	case T_MAP:
-		mapType := ut.E.(*ast.MapType)
-		return e2t(mapType.Key)
-	default:
-		unexpectedKind(kind(t))
+// foldAddr returns the base and the offset of an address computed as base+offset by the last instruction.
+func (f *raFunc) foldAddr(addr int) *raInstr {
+	last := f.code[len(f.code)-1]
+	if addr > f.nvars && last.dst == addr && last.op == "add" && last.useImm {
+		f.code = f.code[:len(f.code)-1]
+		return last
 	}
 	return nil
 }
 
-const SizeOfSlice int = 24
-const SizeOfString int = 16
-const SizeOfInt int = 8
-const SizeOfUint8 int = 1
-const SizeOfUint16 int = 2
-const SizeOfPtr int = 8
-const SizeOfInterface int = 16
+func (f *raFunc) load(addr int, offset int, knd TypeKind) int {
+	fold := f.foldAddr(addr)
+	if fold != nil {
+		addr = fold.src1
+		offset = offset + fold.imm
+	}
+	dst := f.newVreg()
+	f.emit(&raInstr{op: "load", dst: dst, src1: addr, imm: offset, knd: knd})
+	return dst
+}
 
-func getSizeOfType(t *Type) int {
-	ut := getUnderlyingType(t)
-	switch kind(ut) {
-	case T_SLICE:
-		return SizeOfSlice
-	case T_STRING:
-		return SizeOfString
-	case T_INT:
-		return SizeOfInt
-	case T_UINTPTR, T_POINTER, T_MAP:
-		return SizeOfPtr
-	case T_UINT8:
-		return SizeOfUint8
-	case T_UINT16:
-		return SizeOfUint16
-	case T_BOOL:
-		return SizeOfInt
-	case T_INTERFACE:
-		return SizeOfInterface
-	case T_ARRAY:
-		arrayType := ut.E.(*ast.ArrayType)
-		elmSize := getSizeOfType(e2t(arrayType.Elt))
-		return elmSize * evalInt(arrayType.Len)
-	case T_STRUCT:
-		return calcStructSizeAndSetFieldOffset(ut.E.(*ast.StructType))
-	case T_FUNC:
-		return SizeOfPtr
-	default:
-		unexpectedKind(kind(t))
-	}
-	return 0
-}
-
-func lookupStructField(structType *ast.StructType, selName string) *ast.Field {
-	for _, field := range structType.Fields.List {
-		if field.Names[0].Name == selName {
-			return field
-		}
-	}
-	panic("Unexpected flow: struct field not found:" + selName)
+func (f *raFunc) store(addr int, offset int, v int, knd TypeKind) {
+	f.emit(&raInstr{op: "store", src1: addr, src2: v, imm: offset, knd: knd})
 }
 
-func calcStructSizeAndSetFieldOffset(structType *ast.StructType) int {
-	var offset int = 0
-	for _, field := range structType.Fields.List {
-		setStructFieldOffset(field, offset)
-		size := getSizeOfType(e2t(field.Type))
-		offset += size
-	}
-	return offset
+func (f *raFunc) label(label string) {
+	f.emit(&raInstr{op: "label", sym: label})
 }
 
-// --- walk ---
-type sliteral struct {
-	label  string
-	strlen int
-	value  string // raw value
+func (f *raFunc) jmp(label string) {
+	f.emit(&raInstr{op: "jmp", sym: label})
 }
 
-func registerParamVariable(fnc *Func, name string, t *Type) *Variable {
-	vr := newLocalVariable(name, fnc.Argsarea, t)
-	size := getSizeOfType(t)
-	fnc.Argsarea += size
-	fnc.Params = append(fnc.Params, vr)
-	return vr
+// tempVar allocates a variable in the stack frame for embedded code.
+func (f *raFunc) tempVar(t *Type) *Variable {
+	f.frame = f.frame - getSizeOfType(t)
+	return newLocalVariable(".ra.tmp", f.frame, t)
 }
 
-func registerReturnVariable(fnc *Func, name string, t *Type) *Variable {
-	vr := newLocalVariable(name, fnc.Argsarea, t)
-	size := getSizeOfType(t)
-	fnc.Argsarea += size
-	fnc.Retvars = append(fnc.Retvars, vr)
-	return vr
+func raVarOperand(vr *Variable) string {
+	if vr.IsGlobal {
+		return vr.GlobalSymbol + "(%rip)"
+	}
+	return fmt.Sprintf("%d(%%rbp)", vr.LocalOffset)
 }
 
-func registerLocalVariable(fnc *Func, name string, t *Type) *Variable {
-	assert(t != nil && t.E != nil, "type of local var should not be nil", __func__)
-	fnc.Localarea -= getSizeOfType(t)
-	vr := newLocalVariable(name, currentFunc.Localarea, t)
-	fnc.LocalVars = append(fnc.LocalVars, vr)
-	return vr
+func (f *raFunc) loadVar(vr *Variable) int {
+	if vr.HeapAddr != nil {
+		return f.load(f.loadVar(vr.HeapAddr), 0, kind(vr.Typ))
+	}
+	if vr.Vreg != 0 {
+		return vr.Vreg
+	}
+	dst := f.newVreg()
+	f.emit(&raInstr{op: "ldm", dst: dst, sym: raVarOperand(vr), knd: kind(vr.Typ)})
+	return dst
 }
 
-var currentFor *MetaForContainer
-var currentFunc *Func
-
-func registerStringLiteral(lit *ast.BasicLit) *sliteral {
-	if currentPkg.name == "" {
-		panic("no pkgName")
+func (f *raFunc) storeVar(vr *Variable, v int) {
+	if vr.HeapAddr != nil {
+		f.store(f.loadVar(vr.HeapAddr), 0, v, kind(vr.Typ))
+		return
 	}
-
-	var strlen int
-	for _, c := range []uint8(lit.Value) {
-		if c != '\\' {
-			strlen++
-		}
+	if vr.Vreg != 0 {
+		f.mov(vr.Vreg, v)
+		return
 	}
+	f.emit(&raInstr{op: "stm", src1: v, sym: raVarOperand(vr), knd: kind(vr.Typ)})
+}
 
-	label := fmt.Sprintf(".string_%d", currentPkg.stringIndex)
-	currentPkg.stringIndex++
-
-	sl := &sliteral{
-		label:  label,
-		strlen: strlen - 2,
-		value:  lit.Value,
+func (f *raFunc) varAddr(vr *Variable) int {
+	if vr.HeapAddr != nil {
+		return f.loadVar(vr.HeapAddr)
 	}
-	currentPkg.stringLiterals = append(currentPkg.stringLiterals, sl)
-	return sl
+	if vr.Vreg != 0 {
+		vr.AddrTaken = true
+		f.retry = true
+	}
+	dst := f.newVreg()
+	f.emit(&raInstr{op: "lea", dst: dst, sym: raVarOperand(vr)})
+	return dst
 }
 
-func newGlobalVariable(pkgName string, name string, t *Type) *Variable {
-	return &Variable{
-		Name:         name,
-		IsGlobal:     true,
-		GlobalSymbol: pkgName + "." + name,
-		Typ:          t,
-	}
+// beginAsm starts capturing the code of the stack machine emitter.
+func (f *raFunc) beginAsm() {
+	asmCapture = true
+	capturedAsm = nil
 }
 
-func newLocalVariable(name string, localoffset int, t *Type) *Variable {
-	return &Variable{
-		Name:        name,
-		IsGlobal:    false,
-		LocalOffset: localoffset,
-		Typ:         t,
+// endAsm embeds the captured code.
+// It may break or continue the enclosing loops.
+func (f *raFunc) endAsm() *raInstr {
+	asmCapture = false
+	in := &raInstr{op: "asm", text: capturedAsm}
+	for _, loop := range f.loops {
+		in.targets = append(in.targets, loop.LabelPost)
+		in.targets = append(in.targets, loop.LabelExit)
 	}
+	capturedAsm = nil
+	return f.emit(in)
 }
 
-type QualifiedIdent string
+// pop moves the value pushed by embedded code to a new virtual register.
+func (f *raFunc) pop(knd TypeKind) int {
+	dst := f.newVreg()
+	f.emit(&raInstr{op: "pop", dst: dst, knd: knd})
+	return dst
+}
 
-func newQI(pkg string, ident string) QualifiedIdent {
-	return QualifiedIdent(pkg + "." + ident)
+func (f *raFunc) fallbackExpr(meta MetaExpr) int {
+	f.beginAsm()
+	emitExpr(meta)
+	f.endAsm()
+	return f.pop(T_INT)
 }
 
-func isQI(e *ast.SelectorExpr) bool {
-	ident, isIdent := e.X.(*ast.Ident)
-	if !isIdent {
-		return false
-	}
-	assert(ident.Obj != nil, "ident.Obj is nil:"+ident.Name, __func__)
-	return ident.Obj.Kind == ast.Pkg
+func (f *raFunc) fallbackAddr(meta MetaExpr) int {
+	f.beginAsm()
+	emitAddr(meta)
+	f.endAsm()
+	return f.pop(T_UINTPTR)
 }
 
-func selector2QI(e *ast.SelectorExpr) QualifiedIdent {
-	pkgName := e.X.(*ast.Ident)
-	assert(pkgName.Obj.Kind == ast.Pkg, "should be ast.Pkg", __func__)
-	return newQI(pkgName.Name, e.Sel.Name)
+func (f *raFunc) fallbackStmt(stmt MetaStmt) {
+	f.beginAsm()
+	emitStmt(stmt)
+	f.endAsm()
 }
 
-func newMethod(pkgName string, funcDecl *ast.FuncDecl) *Method {
-	rcvType := funcDecl.Recv.List[0].Type
-	rcvPointerType, isPtr := rcvType.(*ast.StarExpr)
-	if isPtr {
-		rcvType = rcvPointerType.X
-	}
-	rcvNamedType := rcvType.(*ast.Ident)
-	method := &Method{
-		PkgName:      pkgName,
-		RcvNamedType: rcvNamedType,
-		IsPtrMethod:  isPtr,
-		Name:         funcDecl.Name.Name,
-		FuncType:     funcDecl.Type,
+// raImmediate reports whether meta is an integer constant usable as an immediate operand.
+func raImmediate(meta MetaExpr) bool {
+	switch m := meta.(type) {
+	case *MetaBasicLit:
+		switch m.Kind {
+		case "INT":
+			return isImm32(m.intVal)
+		case "CHAR":
+			return true
+		}
+	case *MetaIdent:
+		if m.kind == "con" {
+			return raImmediate(m.conLiteral)
+		}
 	}
-	return method
+	return false
 }
 
-// https://golang.org/ref/spec#Method_sets
-// @TODO map key should be a QI ?
-var MethodSets = make(map[unsafe.Pointer]*NamedType)
-
-type NamedType struct {
-	methodSet map[string]*Method
+func raImmediateValue(meta MetaExpr) int {
+	switch m := meta.(type) {
+	case *MetaBasicLit:
+		if m.Kind == "CHAR" {
+			return m.charVal
+		}
+		return m.intVal
+	case *MetaIdent:
+		return raImmediateValue(m.conLiteral)
+	}
+	panic("not an immediate")
 }
 
-func registerMethod(method *Method) {
-	key := unsafe.Pointer(method.RcvNamedType.Obj)
-	namedType, ok := MethodSets[key]
-	if !ok {
-		namedType = &NamedType{
-			methodSet: make(map[string]*Method),
-		}
-		MethodSets[key] = namedType
+// lowerOperand lowers y as the second operand of in and emits in.
+func (f *raFunc) lowerOperand(in *raInstr, y MetaExpr) *raInstr {
+	if raImmediate(y) {
+		in.useImm = true
+		in.imm = raImmediateValue(y)
+	} else {
+		in.src2 = f.lowerExpr(y)
 	}
-	namedType.methodSet[method.Name] = method
+	return f.emit(in)
 }
 
-func lookupMethod(rcvT *Type, methodName *ast.Ident) *Method {
-	rcvType := rcvT.E
-	rcvPointerType, isPtr := rcvType.(*ast.StarExpr)
-	if isPtr {
-		rcvType = rcvPointerType.X
+// raBinop returns the instruction of an arithmetic operator or the condition code of a comparison.
+func raBinop(op string) string {
+	switch op {
+	case "+":
+		return "add"
+	case "-":
+		return "sub"
+	case "*":
+		return "imul"
+	case "/":
+		return "div"
+	case "%":
+		return "mod"
+	case "&":
+		return "and"
+	case "|":
+		return "or"
+	case "==":
+		return "e"
+	case "!=":
+		return "ne"
+	case "<":
+		return "l"
+	case "<=":
+		return "le"
+	case ">":
+		return "g"
+	case ">=":
+		return "ge"
 	}
-	var typeObj *ast.Object
-	switch typ := rcvType.(type) {
-	case *ast.Ident:
-		typeObj = typ.Obj
-	case *ast.SelectorExpr:
-		t := lookupForeignIdent(selector2QI(typ))
-		typeObj = t.Obj
-	default:
-		panic(rcvType)
+	panic("unexpected operator " + op)
+}
+
+func raInvertCC(cc string) string {
+	switch cc {
+	case "e":
+		return "ne"
+	case "ne":
+		return "e"
+	case "l":
+		return "ge"
+	case "le":
+		return "g"
+	case "g":
+		return "le"
+	case "ge":
+		return "l"
 	}
+	panic("unknown condition code " + cc)
+}
 
-	namedType, ok := MethodSets[unsafe.Pointer(typeObj)]
+// raCompare reports whether meta is a comparison of scalar values.
+func raCompare(meta MetaExpr) bool {
+	m, ok := meta.(*MetaBinaryExpr)
 	if !ok {
-		panic(typeObj.Name + " has no methodSet")
+		return false
 	}
-	method, ok := namedType.methodSet[methodName.Name]
-	if !ok {
-		panic("method not found: " + methodName.Name)
+	switch m.Op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return raScalar(getTypeOfExpr(m.X))
 	}
-	return method
+	return false
 }
 
-func walkExprStmt(s *ast.ExprStmt) *MetaExprStmt {
-	m := walkExpr(s.X, nil)
-	return &MetaExprStmt{X: m}
+// raCanCall reports whether the call is lowered by the register backend.
+func raCanCall(meta *MetaCallExpr) bool {
+	return !meta.isConversion && meta.builtin == nil && meta.funcVal.isDirect
 }
 
-func walkDeclStmt(s *ast.DeclStmt) *MetaVarDecl {
-	genDecl := s.Decl.(*ast.GenDecl)
-	declSpec := genDecl.Specs[0]
-	switch spec := declSpec.(type) {
-	case *ast.ValueSpec:
-		lhsIdent := spec.Names[0]
-		var rhsMeta MetaExpr
-		var t *Type
-		if spec.Type != nil { // var x T = e
-			// walkExpr(spec.Type, nil) // Do we need to walk type ?
-			t = e2t(spec.Type)
-			if len(spec.Values) > 0 {
-				rhs := spec.Values[0]
-				ctx := &evalContext{_type: t}
-				rhsMeta = walkExpr(rhs, ctx)
-			}
-		} else { // var x = e  infer lhs type from rhs
-			if len(spec.Values) == 0 {
-				panic("invalid syntax")
-			}
-
-			rhs := spec.Values[0]
-			rhsMeta = walkExpr(rhs, nil)
-			t = getTypeOfExpr(rhsMeta)
-			if t == nil {
-				panic("rhs should have a type")
-			}
-		}
-		spec.Type = t.E // set lhs type
-
-		obj := lhsIdent.Obj
-		setVariable(obj, registerLocalVariable(currentFunc, obj.Name, t))
-		lhsMeta := walkIdent(lhsIdent, nil)
-		single := &MetaSingleAssign{
-			Lhs: lhsMeta,
-			Rhs: rhsMeta,
-		}
-		return &MetaVarDecl{
-			Single:  single,
-			LhsType: t,
+// raAddressable reports whether the address of meta is lowered by the register backend.
+func raAddressable(meta MetaExpr) bool {
+	switch m := meta.(type) {
+	case *MetaIdent:
+		return m.kind == "var"
+	case *MetaSelectorExpr:
+		if isQI(m.e) {
+			return lookupForeignIdent(selector2QI(m.e)).Obj.Kind == ast.Var
 		}
-	default:
-		// @TODO type, const, etc
-	}
-
-	panic("TBI")
-}
-
-func IsOkSyntax(rhs MetaExpr) bool {
-	typeAssertion, isTypeAssertion := rhs.(*MetaTypeAssertExpr)
-	if isTypeAssertion && typeAssertion.NeedsOK {
 		return true
-	}
-	indexExpr, isIndexExpr := rhs.(*MetaIndexExpr)
-	if isIndexExpr && indexExpr.NeedsOK {
+	case *MetaIndexExpr:
+		return !m.IsMap
+	case *MetaStarExpr:
 		return true
 	}
 	return false
 }
 
-func walkAssignStmt(s *ast.AssignStmt) MetaStmt {
-	stok := s.Tok.String()
-	switch stok {
-	case "=":
-		if len(s.Lhs) == 1 && len(s.Rhs) == 1 {
-			// Single assignment
-			var lhsMetas []MetaExpr
-			for _, lhs := range s.Lhs {
-				lm := walkExpr(lhs, nil)
-				lhsMetas = append(lhsMetas, lm)
-			}
-			var ctx *evalContext
-			if !isBlankIdentifierMeta(lhsMetas[0]) {
-				ctx = &evalContext{
-					_type: getTypeOfExpr(lhsMetas[0]),
-				}
+// lowerExpr lowers an expression of a scalar type and returns the virtual register holding its value.
+func (f *raFunc) lowerExpr(meta MetaExpr) int {
+	switch m := meta.(type) {
+	case *MetaBasicLit:
+		switch m.Kind {
+		case "INT":
+			return f.li(m.intVal)
+		case "CHAR":
+			return f.li(m.charVal)
+		}
+	case *MetaIdent:
+		switch m.kind {
+		case "true":
+			return f.li(1)
+		case "false", "nil":
+			return f.li(0)
+		case "var":
+			return f.loadVar(m.variable)
+		case "con":
+			return f.lowerExpr(m.conLiteral)
+		}
+	case *MetaSelectorExpr:
+		if isQI(m.e) {
+			ident := lookupForeignIdent(selector2QI(m.e))
+			if ident.Obj.Kind == ast.Var || ident.Obj.Kind == ast.Con {
+				return f.lowerExpr(m.foreign)
 			}
-			rhsMeta := walkExpr(s.Rhs[0], ctx)
-			return &MetaSingleAssign{
-				Lhs: lhsMetas[0],
-				Rhs: rhsMeta,
+		} else {
+			return f.load(f.lowerAddr(m), 0, kind(getTypeOfExpr(m)))
+		}
+	case *MetaIndexExpr:
+		if !m.IsMap {
+			return f.load(f.lowerAddr(m), 0, kind(getTypeOfExpr(m)))
+		}
+	case *MetaStarExpr:
+		return f.load(f.lowerDeref(m), 0, kind(getTypeOfExpr(m)))
+	case *MetaCallExpr:
+		if m.isConversion {
+			if raScalar(getTypeOfExpr(m.arg0)) {
+				return f.lowerExpr(m.arg0)
 			}
-		} else if len(s.Lhs) == len(s.Rhs) {
-			panic("TBI")
-		} else if len(s.Lhs) > 1 && len(s.Rhs) == 1 {
-			// Tuple assignment
-			maybeOkContext := len(s.Lhs) == 2
-			rhsMeta := walkExpr(s.Rhs[0], &evalContext{maybeOK: maybeOkContext})
-			isOK := len(s.Lhs) == 2 && IsOkSyntax(rhsMeta)
-			rhsTypes := getTupleTypes(rhsMeta)
-			assert(len(s.Lhs) == len(rhsTypes), fmt.Sprintf("length unmatches %d <=> %d", len(s.Lhs), len(rhsTypes)), __func__)
-
-			var lhsMetas []MetaExpr
-			for _, lhs := range s.Lhs {
-				lm := walkExpr(lhs, nil)
-				lhsMetas = append(lhsMetas, lm)
+		} else if m.builtin == gLen || m.builtin == gCap {
+			return f.lowerLenCap(m.arg0, m.builtin == gCap)
+		} else if raCanCall(m) && m.funcType.Results != nil && len(m.funcType.Results.List) == 1 {
+			f.lowerCall(m)
+			return f.pop(kind(getTypeOfExpr(m)))
+		}
+	case *MetaUnaryExpr:
+		switch m.e.Op.String() {
+		case "+":
+			return f.lowerExpr(m.X)
+		case "-":
+			dst := f.newVreg()
+			f.emit(&raInstr{op: "neg", dst: dst, src1: f.lowerExpr(m.X)})
+			return dst
+		case "!":
+			dst := f.newVreg()
+			f.emit(&raInstr{op: "not", dst: dst, src1: f.lowerExpr(m.X)})
+			return dst
+		case "&":
+			if raAddressable(m.X) {
+				return f.lowerAddr(m.X)
 			}
-			return &MetaTupleAssign{
-				isOK:     isOK,
-				Lhss:     lhsMetas,
-				Rhs:      rhsMeta,
-				RhsTypes: rhsTypes,
+		}
+	case *MetaInlineCall:
+		if raScalar(m.typ) {
+			for _, param := range m.params {
+				f.lowerAssign(param.Lhs, param.Rhs, param)
 			}
-		} else {
-			panic("Bad syntax")
+			return f.lowerExpr(m.result)
 		}
-	case ":=":
-		if len(s.Lhs) == 1 && len(s.Rhs) == 1 {
-			// Single assignment
-			rhsMeta := walkExpr(s.Rhs[0], nil) // FIXME
-			rhsType := getTypeOfExpr(rhsMeta)
-			lhsTypes := []*Type{rhsType}
-			var lhsMetas []MetaExpr
-			for i, lhs := range s.Lhs {
-				typ := lhsTypes[i]
-				obj := lhs.(*ast.Ident).Obj
-				setVariable(obj, registerLocalVariable(currentFunc, obj.Name, typ))
-				lm := walkExpr(lhs, nil)
-				lhsMetas = append(lhsMetas, lm)
+	case *MetaBinaryExpr:
+		switch m.Op {
+		case "&&", "||":
+			return f.lowerCondValue(m)
+		case "+", "-", "*", "/", "%", "&", "|":
+			dst := f.newVreg()
+			in := &raInstr{op: raBinop(m.Op), dst: dst, src1: f.lowerExpr(m.X)}
+			if (m.Op == "/" || m.Op == "%") && !noRuntimeChecks && !raImmediate(m.Y) {
+				in.src2 = f.lowerExpr(m.Y)
+				f.lowerNonZeroCheck(in.src2, "runtime.panicDivide", m.e.OpPos)
+				f.emit(in)
+			} else {
+				f.lowerOperand(in, m.Y)
 			}
-
-			return &MetaSingleAssign{
-				Lhs: lhsMetas[0],
-				Rhs: rhsMeta,
+			return dst
+		case "==", "!=", "<", "<=", ">", ">=":
+			if raScalar(getTypeOfExpr(m.X)) {
+				dst := f.newVreg()
+				f.lowerOperand(&raInstr{op: "set", dst: dst, cc: raBinop(m.Op), src1: f.lowerExpr(m.X)}, m.Y)
+				return dst
 			}
-		} else if len(s.Lhs) == len(s.Rhs) {
-			panic("TBI")
-		} else if len(s.Lhs) > 1 && len(s.Rhs) == 1 {
-			// Tuple assignment
-			maybeOkContext := len(s.Lhs) == 2
-			rhsMeta := walkExpr(s.Rhs[0], &evalContext{maybeOK: maybeOkContext})
-			isOK := len(s.Lhs) == 2 && IsOkSyntax(rhsMeta)
-			rhsTypes := getTupleTypes(rhsMeta)
-			assert(len(s.Lhs) == len(rhsTypes), fmt.Sprintf("length unmatches %d <=> %d", len(s.Lhs), len(rhsTypes)), __func__)
+		}
+	}
+	return f.fallbackExpr(meta)
+}
 
-			lhsTypes := rhsTypes
-			for i, lhs := range s.Lhs {
-				typ := lhsTypes[i]
-				obj := lhs.(*ast.Ident).Obj
-				setVariable(obj, registerLocalVariable(currentFunc, obj.Name, typ))
+// lowerCondValue lowers a boolean expression evaluated by jumps.
+func (f *raFunc) lowerCondValue(meta MetaExpr) int {
+	labelid++
+	labelFalse := fmt.Sprintf(".L.%d.false", labelid)
+	labelExit := fmt.Sprintf(".L.%d.exit", labelid)
+	dst := f.newVreg()
+	f.lowerCond(meta, labelFalse, false)
+	f.emit(&raInstr{op: "li", dst: dst, imm: 1})
+	f.jmp(labelExit)
+	f.label(labelFalse)
+	f.emit(&raInstr{op: "li", dst: dst, imm: 0})
+	f.label(labelExit)
+	return dst
+}
+
+// lowerCond jumps to label if the condition meta evaluates to jumpIf.
+func (f *raFunc) lowerCond(meta MetaExpr, label string, jumpIf bool) {
+	switch m := meta.(type) {
+	case *MetaBinaryExpr:
+		switch m.Op {
+		case "&&", "||":
+			isAnd := m.Op == "&&"
+			if isAnd != jumpIf {
+				// the left operand alone can decide
+				f.lowerCond(m.X, label, jumpIf)
+				f.lowerCond(m.Y, label, jumpIf)
+			} else {
+				labelid++
+				labelSkip := fmt.Sprintf(".L.%d.skip", labelid)
+				f.lowerCond(m.X, labelSkip, !jumpIf)
+				f.lowerCond(m.Y, label, jumpIf)
+				f.label(labelSkip)
 			}
-
-			var lhsMetas []MetaExpr
-			for _, lhs := range s.Lhs {
-				lm := walkExpr(lhs, nil)
-				lhsMetas = append(lhsMetas, lm)
+			return
+		}
+		if raCompare(m) {
+			cc := raBinop(m.Op)
+			if !jumpIf {
+				cc = raInvertCC(cc)
 			}
-			return &MetaTupleAssign{
-				isOK:     isOK,
-				Lhss:     lhsMetas,
-				Rhs:      rhsMeta,
-				RhsTypes: rhsTypes,
+			f.lowerOperand(&raInstr{op: "cmpbr", cc: cc, sym: label, src1: f.lowerExpr(m.X)}, m.Y)
+			return
+		}
+	case *MetaUnaryExpr:
+		if m.e.Op.String() == "!" {
+			f.lowerCond(m.X, label, !jumpIf)
+			return
+		}
+	}
+	cc := "e"
+	if jumpIf {
+		cc = "ne"
+	}
+	f.emit(&raInstr{op: "cmpbr", cc: cc, sym: label, src1: f.lowerExpr(meta), useImm: true, imm: 0})
+}
+
+// lowerAddr lowers the address of an addressable expression.
+func (f *raFunc) lowerAddr(meta MetaExpr) int {
+	switch m := meta.(type) {
+	case *MetaIdent:
+		if m.kind == "var" {
+			return f.varAddr(m.variable)
+		}
+	case *MetaSelectorExpr:
+		if isQI(m.e) {
+			qi := selector2QI(m.e)
+			if lookupForeignIdent(qi).Obj.Kind == ast.Var {
+				dst := f.newVreg()
+				f.emit(&raInstr{op: "lea", dst: dst, sym: string(qi) + "(%rip)"})
+				return dst
 			}
 		} else {
-			panic("Bad syntax")
+			typeOfX := getUnderlyingType(getTypeOfExpr(m.X))
+			var structTypeLiteral *ast.StructType
+			var base int
+			switch typ := typeOfX.E.(type) {
+			case *ast.StructType: // strct.field
+				structTypeLiteral = typ
+				base = f.lowerAddr(m.X)
+			case *ast.StarExpr: // ptr.field
+				structTypeLiteral = getUnderlyingStructType(e2t(typ.X))
+				base = f.lowerExpr(m.X)
+			default:
+				unexpectedKind(kind(typeOfX))
+			}
+			field := lookupStructField(structTypeLiteral, m.e.Sel.Name)
+			return f.addImm(base, getStructFieldOffset(field))
 		}
-	case "+=", "-=":
-		var op token.Token
-		switch stok {
-		case "+=":
-			op = token.ADD
-		case "-=":
-			op = token.SUB
+	case *MetaIndexExpr:
+		if !m.IsMap {
+			if !noRuntimeChecks {
+				return f.lowerCheckedElementAddr(m)
+			}
+			index := f.lowerExpr(m.Index)
+			return f.lowerElementAddr(m.X, index, getTypeOfExpr(m))
 		}
-		binaryExpr := &ast.BinaryExpr{
-			X:  s.Lhs[0],
-			Op: op,
-			Y:  s.Rhs[0],
+	case *MetaStarExpr:
+		return f.lowerDeref(m)
+	}
+	return f.fallbackAddr(meta)
+}
+
+// lowerDeref lowers the pointer of *X and checks that it is not nil.
+func (f *raFunc) lowerDeref(m *MetaStarExpr) int {
+	p := f.lowerExpr(m.X)
+	if !noRuntimeChecks {
+		f.lowerNonZeroCheck(p, "runtime.panicNil", m.e.Star)
+	}
+	return p
+}
+
+// lowerCheckedElementAddr is lowerElementAddr checking the index against the length of the list.
+func (f *raFunc) lowerCheckedElementAddr(m *MetaIndexExpr) int {
+	t := getTypeOfExpr(m.X)
+	if kind(t) != T_ARRAY && !raAddressable(m.X) {
+		return f.fallbackAddr(m)
+	}
+	index := f.lowerExpr(m.Index)
+	var head int
+	var length int
+	if kind(t) == T_ARRAY {
+		head = f.lowerAddr(m.X)
+		length = f.li(evalInt(getUnderlyingType(t).E.(*ast.ArrayType).Len))
+	} else {
+		// the address is used twice, so it is folded once for both loads
+		addr := f.lowerAddr(m.X)
+		offset := 0
+		fold := f.foldAddr(addr)
+		if fold != nil {
+			addr = fold.src1
+			offset = fold.imm
 		}
-		rhsMeta := walkExpr(binaryExpr, nil)
-		lhsMeta := walkExpr(s.Lhs[0], nil)
-		return &MetaSingleAssign{
-			Lhs: lhsMeta,
-			Rhs: rhsMeta,
+		head = f.newVreg()
+		f.emit(&raInstr{op: "load", dst: head, src1: addr, imm: offset, knd: T_UINTPTR})
+		length = f.newVreg()
+		f.emit(&raInstr{op: "load", dst: length, src1: addr, imm: offset + 8, knd: T_INT})
+	}
+	f.lowerBoundsCheck("b", index, length, "runtime.panicIndex", m.e.Lbrack)
+	size := getSizeOfType(getTypeOfExpr(m))
+	if size != 1 {
+		index = f.binopImm("imul", index, size)
+	}
+	return f.binop("add", head, index)
+}
+
+// lowerBoundsCheck calls fn with x and y unless x cc y holds as unsigned integers.
+func (f *raFunc) lowerBoundsCheck(cc string, x int, y int, fn string, pos token.Pos) {
+	labelid++
+	labelOK := fmt.Sprintf(".L.%d.inbounds", labelid)
+	f.emit(&raInstr{op: "cmpbr", cc: cc, sym: labelOK, src1: x, src2: y})
+	f.lowerCheckFailure(fn, pos, x, y)
+	f.label(labelOK)
+}
+
+// lowerNonZeroCheck calls fn if v is zero.
+func (f *raFunc) lowerNonZeroCheck(v int, fn string, pos token.Pos) {
+	labelid++
+	labelOK := fmt.Sprintf(".L.%d.nonzero", labelid)
+	f.emit(&raInstr{op: "cmpbr", cc: "ne", sym: labelOK, src1: v, useImm: true, imm: 0})
+	f.lowerCheckFailure(fn, pos, 0, 0)
+	f.label(labelOK)
+}
+
+// lowerCheckFailure calls fn reporting a failed check at pos, passing x and y unless they are 0.
+func (f *raFunc) lowerCheckFailure(fn string, pos token.Pos, x int, y int) {
+	var args []int
+	if x != 0 {
+		args = append(args, x)
+	}
+	if y != 0 {
+		args = append(args, y)
+	}
+	size := len(args)*8 + 16
+	f.emit(&raInstr{op: "alloc", imm: size})
+	for i, v := range args {
+		f.emit(&raInstr{op: "starg", src1: v, imm: i * 8, knd: T_INT})
+	}
+	position := checkPosition(pos)
+	ptr := f.newVreg()
+	f.emit(&raInstr{op: "lea", dst: ptr, sym: runtimeStringLabel(position) + "(%rip)"})
+	f.emit(&raInstr{op: "starg", src1: ptr, imm: len(args) * 8, knd: T_UINTPTR})
+	f.emit(&raInstr{op: "starg", src1: f.li(len(position)), imm: len(args)*8 + 8, knd: T_INT})
+	f.emit(&raInstr{op: "call", sym: fn, pos: pos})
+	f.emit(&raInstr{op: "free", imm: size})
+}
+
+func (f *raFunc) lowerElementAddr(list MetaExpr, index int, elmType *Type) int {
+	head := f.lowerListHead(list)
+	size := getSizeOfType(elmType)
+	if size != 1 {
+		index = f.binopImm("imul", index, size)
+	}
+	return f.binop("add", head, index)
+}
+
+func (f *raFunc) lowerListHead(list MetaExpr) int {
+	switch kind(getTypeOfExpr(list)) {
+	case T_ARRAY:
+		return f.lowerAddr(list)
+	case T_SLICE, T_STRING:
+		if raAddressable(list) {
+			return f.load(f.lowerAddr(list), 0, T_UINTPTR)
 		}
-	default:
-		panic("TBI")
 	}
-	return nil
+	f.beginAsm()
+	emitListHeadAddr(list)
+	f.endAsm()
+	return f.pop(T_UINTPTR)
 }
 
-func walkReturnStmt(s *ast.ReturnStmt) *MetaReturnStmt {
-	funcDef := currentFunc
-	if len(funcDef.Retvars) != len(s.Results) {
-		panic("length of return and func type do not match")
+// lowerLenCap lowers len(list) or cap(list).
+func (f *raFunc) lowerLenCap(list MetaExpr, isCap bool) int {
+	switch kind(getTypeOfExpr(list)) {
+	case T_ARRAY:
+		arrayType := getTypeOfExpr(list).E.(*ast.ArrayType)
+		return f.li(evalInt(arrayType.Len))
+	case T_SLICE, T_STRING:
+		if raAddressable(list) {
+			offset := 8
+			if isCap {
+				offset = 16
+			}
+			return f.load(f.lowerAddr(list), offset, T_INT)
+		}
+	}
+	f.beginAsm()
+	if isCap {
+		emitCap(list)
+	} else {
+		emitLen(list)
 	}
+	f.endAsm()
+	return f.pop(T_INT)
+}
 
-	_len := len(funcDef.Retvars)
-	var results []MetaExpr
-	for i := 0; i < _len; i++ {
-		expr := s.Results[i]
-		retTyp := funcDef.Retvars[i].Typ
-		ctx := &evalContext{
-			_type: retTyp,
+// lowerCall calls a function leaving its results on the stack.
+// See "ABI of stack layout" in the emitFuncall comment.
+func (f *raFunc) lowerCall(meta *MetaCallExpr) {
+	var totalParamSize int
+	var offsets []int
+	for _, arg := range meta.metaArgs {
+		offsets = append(offsets, totalParamSize)
+		totalParamSize += getSizeOfType(arg.paramType)
+	}
+	f.emit(&raInstr{op: "alloc", imm: getTotalFieldsSize(meta.funcType.Results) + totalParamSize})
+	for i, arg := range meta.metaArgs {
+		if raScalar(arg.paramType) && raScalar(getTypeOfExpr(arg.meta)) {
+			v := f.lowerExpr(arg.meta)
+			f.emit(&raInstr{op: "starg", src1: v, imm: offsets[i], knd: kind(arg.paramType)})
+		} else {
+			f.beginAsm()
+			emitArgument(arg, offsets[i])
+			f.endAsm()
 		}
-		m := walkExpr(expr, ctx)
-		results = append(results, m)
 	}
-	return &MetaReturnStmt{
-		Fnc:     funcDef,
-		Results: results,
+	f.emit(&raInstr{op: "call", sym: meta.funcVal.symbol, pos: meta.funcVal.pos})
+	f.emit(&raInstr{op: "free", imm: totalParamSize})
+}
+
+// assign stores the value v to lhs.
+func (f *raFunc) assign(lhs MetaExpr, v int, lhsType *Type) {
+	ident, isIdent := lhs.(*MetaIdent)
+	if isIdent && ident.kind == "var" {
+		f.storeVar(ident.variable, v)
+		return
 	}
+	addr := f.lowerAddr(lhs)
+	f.store(addr, 0, v, kind(lhsType))
 }
 
-func walkIfStmt(s *ast.IfStmt) *MetaIfStmt {
-	var mInit MetaStmt
-	var mElse MetaStmt
-	var condMeta MetaExpr
-	if s.Init != nil {
-		mInit = walkStmt(s.Init)
+func (f *raFunc) lowerAssign(lhs MetaExpr, rhs MetaExpr, stmt MetaStmt) {
+	if isBlankIdentifierMeta(lhs) {
+		if raScalar(getTypeOfExpr(rhs)) {
+			f.lowerExpr(rhs)
+		} else {
+			f.fallbackStmt(stmt)
+		}
+		return
 	}
-	if s.Cond != nil {
-		condMeta = walkExpr(s.Cond, nil)
+	lhsType := getTypeOfExpr(lhs)
+	if !raScalar(lhsType) || !raScalar(getTypeOfExpr(rhs)) {
+		f.fallbackStmt(stmt)
+		return
 	}
-	mtBlock := walkBlockStmt(s.Body)
-	if s.Else != nil {
-		mElse = walkStmt(s.Else)
+	ident, isIdent := lhs.(*MetaIdent)
+	if isIdent && ident.kind == "var" {
+		f.storeVar(ident.variable, f.lowerExpr(rhs))
+		return
 	}
-	return &MetaIfStmt{
-		Init: mInit,
-		Cond: condMeta,
-		Body: mtBlock,
-		Else: mElse,
+	// the address of lhs is evaluated first, as the stack machine does
+	addr := f.lowerAddr(lhs)
+	v := f.lowerExpr(rhs)
+	fold := f.foldAddrBefore(addr, v)
+	if fold != nil {
+		f.store(fold.src1, fold.imm, v, kind(lhsType))
+		return
+	}
+	f.store(addr, 0, v, kind(lhsType))
+}
+
+// foldAddrBefore is foldAddr for an address computed just before the value v.
+func (f *raFunc) foldAddrBefore(addr int, v int) *raInstr {
+	n := len(f.code)
+	if n < 2 || addr <= f.nvars {
+		return nil
+	}
+	in := f.code[n-2]
+	if in.dst != addr || in.op != "add" || !in.useImm || f.code[n-1].dst != v {
+		return nil
+	}
+	f.code[n-2] = f.code[n-1]
+	f.code = f.code[:n-1]
+	return in
+}
+
+func (f *raFunc) lowerStmt(stmt MetaStmt) {
+	pos := stmtPos(stmt)
+	if pos != token.NoPos {
+		label := markLine(pos)
+		if label != "" {
+			f.label(label)
+		}
 	}
+	switch s := stmt.(type) {
+	case *MetaBlockStmt:
+		for _, st := range s.List {
+			f.lowerStmt(st)
+		}
+	case *MetaExprStmt:
+		call, isCall := s.X.(*MetaCallExpr)
+		if isCall && raCanCall(call) {
+			f.lowerCall(call)
+			f.emit(&raInstr{op: "free", imm: getTotalFieldsSize(call.funcType.Results)})
+		} else {
+			f.fallbackStmt(stmt)
+		}
+	case *MetaVarDecl:
+		if s.Single.Rhs != nil {
+			f.lowerAssign(s.Single.Lhs, s.Single.Rhs, stmt)
+		} else if raScalar(s.LhsType) {
+			f.assign(s.Single.Lhs, f.li(0), s.LhsType)
+		} else {
+			f.fallbackStmt(stmt)
+		}
+	case *MetaSingleAssign:
+		f.lowerAssign(s.Lhs, s.Rhs, stmt)
+	case *MetaReturnStmt:
+		for i, result := range s.Results {
+			retvar := s.Fnc.Retvars[i]
+			if raScalar(retvar.Typ) && raScalar(getTypeOfExpr(result)) {
+				f.storeVar(retvar, f.lowerExpr(result))
+			} else {
+				f.beginAsm()
+				emitAssignToVar(retvar, result)
+				f.endAsm()
+			}
+		}
+		f.emit(&raInstr{op: "ret", sym: f.epilogue})
+	case *MetaIfStmt:
+		labelid++
+		labelEndif := fmt.Sprintf(".L.endif.%d", labelid)
+		labelElse := fmt.Sprintf(".L.else.%d", labelid)
+		if s.Else != nil {
+			f.lowerCond(s.Cond, labelElse, false)
+			f.lowerStmt(s.Body)
+			f.jmp(labelEndif)
+			f.label(labelElse)
+			f.lowerStmt(s.Else)
+		} else {
+			f.lowerCond(s.Cond, labelEndif, false)
+			f.lowerStmt(s.Body)
+		}
+		f.label(labelEndif)
+	case *MetaForContainer:
+		if s.ForRangeStmt == nil {
+			f.lowerFor(s)
+		} else if !s.ForRangeStmt.IsMap {
+			f.lowerRange(s)
+		} else {
+			f.fallbackStmt(stmt)
+		}
+	case *MetaSwitchStmt:
+		if s.Tag != nil {
+			f.lowerSwitch(s)
+		} else {
+			f.fallbackStmt(stmt)
+		}
+	case *MetaBranchStmt:
+		switch s.ContinueOrBreak {
+		case 1: // continue
+			f.jmp(s.containerForStmt.LabelPost)
+		case 2: // break
+			f.jmp(s.containerForStmt.LabelExit)
+		default:
+			throw(s.ContinueOrBreak)
+		}
+	default:
+		f.fallbackStmt(stmt)
+	}
+}
+
+func (f *raFunc) lowerFor(meta *MetaForContainer) {
+	labelid++
+	labelCond := fmt.Sprintf(".L.for.cond.%d", labelid)
+	labelPost := fmt.Sprintf(".L.for.post.%d", labelid)
+	labelExit := fmt.Sprintf(".L.for.exit.%d", labelid)
+
+	meta.LabelPost = labelPost
+	meta.LabelExit = labelExit
+
+	if meta.ForStmt.Init != nil {
+		f.lowerStmt(meta.ForStmt.Init)
+	}
+	f.label(labelCond)
+	if meta.ForStmt.Cond != nil {
+		f.lowerCond(meta.ForStmt.Cond, labelExit, false)
+	}
+	f.loops = append(f.loops, meta)
+	f.lowerStmt(meta.Body)
+	f.loops = f.loops[:len(f.loops)-1]
+	f.label(labelPost)
+	if meta.ForStmt.Post != nil {
+		f.lowerStmt(meta.ForStmt.Post)
+	}
+	f.jmp(labelCond)
+	f.label(labelExit)
+}
+
+func (f *raFunc) lowerRange(meta *MetaForContainer) {
+	labelid++
+	labelCond := fmt.Sprintf(".L.range.cond.%d", labelid)
+	labelPost := fmt.Sprintf(".L.range.post.%d", labelid)
+	labelExit := fmt.Sprintf(".L.range.exit.%d", labelid)
+
+	meta.LabelPost = labelPost
+	meta.LabelExit = labelExit
+
+	rng := meta.ForRangeStmt
+	f.storeVar(rng.LenVar, f.lowerLenCap(rng.X, false))
+	f.storeVar(rng.Indexvar, f.li(0))
+	keyMeta := rng.Key
+	if keyMeta != nil && isBlankIdentifierMeta(keyMeta) {
+		keyMeta = nil
+	}
+	if keyMeta != nil {
+		f.assign(keyMeta, f.li(0), tInt)
+	}
+
+	f.label(labelCond)
+	f.emit(&raInstr{op: "cmpbr", cc: "ge", sym: labelExit, src1: f.loadVar(rng.Indexvar), src2: f.loadVar(rng.LenVar)})
+
+	// value = list[index]
+	elemType := getTypeOfExpr(rng.Value)
+	addr := f.lowerElementAddr(rng.X, f.loadVar(rng.Indexvar), elemType)
+	if raScalar(elemType) {
+		f.assign(rng.Value, f.load(addr, 0, kind(elemType)), elemType)
+	} else {
+		elemAddr := f.tempVar(tUintptr)
+		f.storeVar(elemAddr, addr)
+		f.beginAsm()
+		emitAddr(rng.Value)
+		emitVariable(elemAddr)
+		emitLoadAndPush(elemType)
+		emitStore(elemType, true, false)
+		f.endAsm()
+	}
+
+	f.loops = append(f.loops, meta)
+	f.lowerStmt(meta.Body)
+	f.loops = f.loops[:len(f.loops)-1]
+
+	f.label(labelPost)
+	f.storeVar(rng.Indexvar, f.addImm(f.loadVar(rng.Indexvar), 1))
+	if keyMeta != nil {
+		f.assign(keyMeta, f.loadVar(rng.Indexvar), tInt)
+	}
+	f.jmp(labelCond)
+	f.label(labelExit)
+}
+
+func (f *raFunc) lowerSwitch(s *MetaSwitchStmt) {
+	labelid++
+	labelEnd := fmt.Sprintf(".L.switch.%d.exit", labelid)
+	if s.Init != nil {
+		panic("TBI")
+	}
+	condType := getTypeOfExpr(s.Tag)
+	var tag int
+	var tagVar *Variable
+	if raScalar(condType) {
+		tag = f.lowerExpr(s.Tag)
+	} else {
+		tagVar = f.tempVar(condType)
+		f.beginAsm()
+		emitAssignToVar(tagVar, s.Tag)
+		f.endAsm()
+	}
+
+	var labels []string
+	var defaultLabel string
+	for _, cc := range s.cases {
+		labelid++
+		labelCase := fmt.Sprintf(".L.case.%d", labelid)
+		labels = append(labels, labelCase)
+		if len(cc.ListMeta) == 0 {
+			defaultLabel = labelCase
+			continue
+		}
+		for _, m := range cc.ListMeta {
+			if tagVar == nil {
+				f.lowerOperand(&raInstr{op: "cmpbr", cc: "e", sym: labelCase, src1: tag}, m)
+				continue
+			}
+			var ff *ForeignFunc
+			switch kind(condType) {
+			case T_STRING:
+				ff = lookupForeignFunc(newQI("runtime", "cmpstrings"))
+			case T_INTERFACE:
+				ff = lookupForeignFunc(newQI("runtime", "cmpinterface"))
+			default:
+				unexpectedKind(kind(condType))
+			}
+			f.beginAsm()
+			emitAllocReturnVarsAreaFF(ff)
+			emitVariable(tagVar)
+			emitExpr(m)
+			emitCallFF(ff)
+			emitPopBool(" of switch-case comparison")
+			printf("  cmpq $1, %%rax\n")
+			printf("  je %s # jump if match\n", labelCase)
+			in := f.endAsm()
+			in.targets = append(in.targets, labelCase)
+		}
+	}
+
+	// if no case matches, then jump to
+	if defaultLabel != "" {
+		f.jmp(defaultLabel)
+	} else {
+		f.jmp(labelEnd)
+	}
+
+	for i, cc := range s.cases {
+		f.label(labels[i])
+		for _, st := range cc.Body {
+			f.lowerStmt(st)
+		}
+		f.jmp(labelEnd)
+	}
+	f.label(labelEnd)
+}
+
+// raLowerFunc lowers the body of fnc.
+func raLowerFunc(fnc *Func, epilogue string) *raFunc {
+	f := &raFunc{
+		fnc:      fnc,
+		frame:    fnc.Localarea,
+		epilogue: epilogue,
+	}
+	raCur = f
+	for _, vr := range fnc.Params {
+		if raPromotable(vr) {
+			vr.Vreg = f.newVreg()
+		}
+	}
+	for _, vr := range fnc.LocalVars {
+		if raPromotable(vr) {
+			vr.Vreg = f.newVreg()
+		}
+	}
+	f.nvars = f.nvreg
+	for _, vr := range fnc.Params {
+		if vr.Vreg != 0 {
+			f.emit(&raInstr{op: "ldm", dst: vr.Vreg, sym: raVarOperand(vr), knd: kind(vr.Typ)})
+		}
+	}
+	for _, stmt := range fnc.Stmts {
+		f.lowerStmt(stmt)
+	}
+	for _, vr := range fnc.Params {
+		vr.Vreg = 0
+	}
+	for _, vr := range fnc.LocalVars {
+		vr.Vreg = 0
+	}
+	raCur = nil
+	return f
+}
+
+// emitFuncBodyRA emits the body of fnc by the register backend.
+func emitFuncBodyRA(fnc *Func) {
+	labelid++
+	epilogue := fmt.Sprintf(".L.return.%d", labelid)
+	firstLabel := labelid
+	firstCallSite := len(currentPkg.callSites)
+	firstLineMark := currentPkg.lineMarks
+	f := raLowerFunc(fnc, epilogue)
+	for f.retry {
+		// some variables turned out to live in memory
+		labelid = firstLabel
+		currentPkg.callSites = currentPkg.callSites[:firstCallSite]
+		currentPkg.lineMarks = firstLineMark
+		callSiteFunc.lines = nil
+		f = raLowerFunc(fnc, epilogue)
+	}
+	raAllocate(f)
+
+	var saveOffsets []int
+	for i := 0; i < len(f.saved); i++ {
+		f.frame = f.frame - 8
+		saveOffsets = append(saveOffsets, f.frame)
+	}
+	if f.frame != 0 {
+		printf("  subq $%d, %%rsp # local area\n", -f.frame)
+	}
+	for i, reg := range f.saved {
+		printf("  movq %s, %d(%%rbp) # save %s\n", reg, saveOffsets[i], reg)
+	}
+	for _, in := range f.code {
+		raEmitInstr(f, in)
+	}
+	printf("  %s:\n", epilogue)
+	for i, reg := range f.saved {
+		printf("  movq %d(%%rbp), %s # restore %s\n", saveOffsets[i], reg, reg)
+	}
+	printf("  leave\n")
+	printf("  ret\n")
+}
+
+// --- liveness and linear scan ---
+
+// raBits[i] is 2 to the i-th power
+var raBits []int
+
+func raSetBit(set []int, v int) {
+	set[v/64] = set[v/64] | raBits[v%64]
+}
+
+func raHasBit(set []int, v int) bool {
+	return set[v/64]&raBits[v%64] != 0
+}
+
+func raBranches(in *raInstr) bool {
+	switch in.op {
+	case "jmp", "ret", "cmpbr":
+		return true
+	case "asm":
+		return len(in.targets) > 0
+	}
+	return false
+}
+
+// raClobbers reports whether the instruction may change the caller-saved registers.
+func raClobbers(in *raInstr) bool {
+	return in.op == "asm" || in.op == "call"
+}
+
+// raSrc2 returns the second virtual register used by in, 0 if none.
+func raSrc2(in *raInstr) int {
+	if in.useImm {
+		return 0
+	}
+	return in.src2
+}
+
+// raAllocate assigns a machine register or a stack slot to every virtual register of f.
+func raAllocate(f *raFunc) {
+	if len(raBits) == 0 {
+		b := 1
+		for i := 0; i < 64; i++ {
+			raBits = append(raBits, b)
+			b = b + b
+		}
+	}
+	n := len(f.code)
+	words := f.nvreg/64 + 1
+
+	// basic blocks
+	var blockStarts []int
+	blockOfLabel := make(map[string]int)
+	leader := true
+	for i, in := range f.code {
+		if in.op == "label" {
+			leader = true
+		}
+		if leader {
+			blockStarts = append(blockStarts, i)
+			leader = false
+		}
+		if in.op == "label" {
+			blockOfLabel[in.sym] = len(blockStarts) - 1
+		}
+		if raBranches(in) {
+			leader = true
+		}
+	}
+	nblocks := len(blockStarts)
+	var blockEnds []int
+	var succs [][]int
+	for b := 0; b < nblocks; b++ {
+		end := n - 1
+		if b+1 < nblocks {
+			end = blockStarts[b+1] - 1
+		}
+		blockEnds = append(blockEnds, end)
+		var ss []int
+		last := f.code[end]
+		switch last.op {
+		case "jmp":
+			ss = append(ss, blockOfLabel[last.sym])
+		case "ret":
+		case "cmpbr":
+			ss = append(ss, blockOfLabel[last.sym])
+		case "asm":
+			for _, target := range last.targets {
+				s, ok := blockOfLabel[target]
+				if ok {
+					ss = append(ss, s)
+				}
+			}
+		}
+		if last.op != "jmp" && last.op != "ret" && b+1 < nblocks {
+			ss = append(ss, b+1)
+		}
+		succs = append(succs, ss)
+	}
+
+	// liveness
+	var gen [][]int
+	var kill [][]int
+	var liveIn [][]int
+	var liveOut [][]int
+	for b := 0; b < nblocks; b++ {
+		g := make([]int, words, words)
+		k := make([]int, words, words)
+		for i := blockStarts[b]; i <= blockEnds[b]; i++ {
+			in := f.code[i]
+			if in.src1 != 0 && !raHasBit(k, in.src1) {
+				raSetBit(g, in.src1)
+			}
+			src2 := raSrc2(in)
+			if src2 != 0 && !raHasBit(k, src2) {
+				raSetBit(g, src2)
+			}
+			if in.dst != 0 {
+				raSetBit(k, in.dst)
+			}
+		}
+		gen = append(gen, g)
+		kill = append(kill, k)
+		liveIn = append(liveIn, make([]int, words, words))
+		liveOut = append(liveOut, make([]int, words, words))
+	}
+	changed := true
+	for changed {
+		changed = false
+		for b := nblocks - 1; b >= 0; b-- {
+			out := liveOut[b]
+			for _, s := range succs[b] {
+				for w := 0; w < words; w++ {
+					out[w] = out[w] | liveIn[s][w]
+				}
+			}
+			for w := 0; w < words; w++ {
+				live := gen[b][w] | (out[w] - out[w]&kill[b][w])
+				if live != liveIn[b][w] {
+					liveIn[b][w] = live
+					changed = true
+				}
+			}
+		}
+	}
+
+	// live intervals
+	start := make([]int, f.nvreg+1, f.nvreg+1)
+	end := make([]int, f.nvreg+1, f.nvreg+1)
+	for v := 0; v <= f.nvreg; v++ {
+		start[v] = -1
+	}
+	for b := 0; b < nblocks; b++ {
+		raExtendSet(start, end, liveIn[b], blockStarts[b])
+		raExtendSet(start, end, liveOut[b], blockEnds[b])
+		for i := blockStarts[b]; i <= blockEnds[b]; i++ {
+			in := f.code[i]
+			if in.src1 != 0 {
+				raExtend(start, end, in.src1, i)
+			}
+			if raSrc2(in) != 0 {
+				raExtend(start, end, in.src2, i)
+			}
+			if in.dst != 0 {
+				raExtend(start, end, in.dst, i)
+			}
+		}
+	}
+
+	// clobbers[i] is the number of clobbering instructions before position i
+	clobbers := make([]int, n+1, n+1)
+	for i, in := range f.code {
+		clobbers[i+1] = clobbers[i]
+		if raClobbers(in) {
+			clobbers[i+1] = clobbers[i] + 1
+		}
+	}
+
+	// intervals starting at each position
+	head := make([]int, n, n)
+	next := make([]int, f.nvreg+1, f.nvreg+1)
+	for v := f.nvreg; v >= 1; v-- {
+		if start[v] >= 0 {
+			next[v] = head[start[v]]
+			head[start[v]] = v
+		}
+	}
+
+	// linear scan
+	regs := raRegisters()
+	owner := make([]int, len(regs), len(regs))
+	regOf := make([]int, f.nvreg+1, f.nvreg+1) // register index + 1
+	usedRegs := make([]bool, len(regs), len(regs))
+	f.loc = make([]string, f.nvreg+1, f.nvreg+1)
+	var active []int
+	for pos := 0; pos < n; pos++ {
+		for v := head[pos]; v != 0; v = next[v] {
+			// expire the intervals ending before v
+			k := 0
+			for _, a := range active {
+				if end[a] < start[v] {
+					owner[regOf[a]-1] = 0
+				} else {
+					active[k] = a
+					k++
+				}
+			}
+			active = active[:k]
+
+			crossesCall := clobbers[end[v]]-clobbers[start[v]+1] > 0
+			r := raPickRegister(owner, crossesCall)
+			if r < 0 {
+				// spill the interval ending last
+				victim := 0
+				for _, a := range active {
+					if (regOf[a]-1 < raNumCalleeSaved || !crossesCall) && (victim == 0 || end[a] > end[victim]) {
+						victim = a
+					}
+				}
+				if victim == 0 || end[victim] <= end[v] {
+					f.spill(v)
+					continue
+				}
+				r = regOf[victim] - 1
+				regOf[victim] = 0
+				f.spill(victim)
+				k = 0
+				for _, a := range active {
+					if a != victim {
+						active[k] = a
+						k++
+					}
+				}
+				active = active[:k]
+			}
+			owner[r] = v
+			regOf[v] = r + 1
+			usedRegs[r] = true
+			active = append(active, v)
+		}
+	}
+	for v := 1; v <= f.nvreg; v++ {
+		if regOf[v] != 0 {
+			f.loc[v] = regs[regOf[v]-1]
+		}
+	}
+	for r := 0; r < raNumCalleeSaved; r++ {
+		if usedRegs[r] {
+			f.saved = append(f.saved, regs[r])
+		}
+	}
+}
+
+// raExtendSet extends the intervals of the virtual registers in set to pos.
+func raExtendSet(start []int, end []int, set []int, pos int) {
+	for w, word := range set {
+		if word == 0 {
+			continue
+		}
+		for bit := 0; bit < 64; bit++ {
+			if word&raBits[bit] != 0 {
+				raExtend(start, end, w*64+bit, pos)
+			}
+		}
+	}
+}
+
+func raExtend(start []int, end []int, v int, pos int) {
+	if start[v] < 0 || pos < start[v] {
+		start[v] = pos
+	}
+	if pos > end[v] {
+		end[v] = pos
+	}
+}
+
+// raPickRegister returns a free register, or -1.
+// An interval living across calls needs a callee-saved register.
+func raPickRegister(owner []int, crossesCall bool) int {
+	if !crossesCall {
+		for r := raNumCalleeSaved; r < len(owner); r++ {
+			if owner[r] == 0 {
+				return r
+			}
+		}
+	}
+	for r := 0; r < raNumCalleeSaved; r++ {
+		if owner[r] == 0 {
+			return r
+		}
+	}
+	return -1
+}
+
+func (f *raFunc) spill(v int) {
+	f.frame = f.frame - 8
+	f.loc[v] = fmt.Sprintf("%d(%%rbp)", f.frame)
+}
+
+// --- emission of three-address code ---
+
+func raIsReg(loc string) bool {
+	return loc[0] == '%'
+}
+
+func raLoadInst(knd TypeKind) string {
+	switch knd {
+	case T_UINT8:
+		return "movzbq"
+	case T_UINT16:
+		return "movzwq"
+	}
+	return "movq"
+}
+
+// raStoreValue returns the instruction and the source operand storing v as a value of kind knd.
+func raStoreValue(f *raFunc, v int, knd TypeKind) string {
+	switch knd {
+	case T_UINT8:
+		printf("  movq %s, %%rcx\n", f.loc[v])
+		return "movb %cl,"
+	case T_UINT16:
+		printf("  movq %s, %%rcx\n", f.loc[v])
+		return "movw %cx,"
+	}
+	return "movq " + raReg(f, v, "%rcx") + ","
+}
+
+// raReg returns a register holding the value of v, moving it to scratch if needed.
+func raReg(f *raFunc, v int, scratch string) string {
+	loc := f.loc[v]
+	if raIsReg(loc) {
+		return loc
+	}
+	printf("  movq %s, %s\n", loc, scratch)
+	return scratch
+}
+
+// raSetDst moves the value of reg to the location of dst.
+func raSetDst(f *raFunc, dst int, reg string) {
+	if f.loc[dst] != reg {
+		printf("  movq %s, %s\n", reg, f.loc[dst])
+	}
+}
+
+// raDstReg returns the register to compute dst in.
+func raDstReg(f *raFunc, dst int) string {
+	if raIsReg(f.loc[dst]) {
+		return f.loc[dst]
+	}
+	return "%rax"
+}
+
+func raSecondOperand(f *raFunc, in *raInstr) string {
+	if in.useImm {
+		return fmt.Sprintf("$%d", in.imm)
+	}
+	return f.loc[in.src2]
+}
+
+// raCompareOperands emits the comparison of the operands of in.
+func raCompareOperands(f *raFunc, in *raInstr) {
+	a := f.loc[in.src1]
+	if !raIsReg(a) {
+		printf("  movq %s, %%rax\n", a)
+		a = "%rax"
+	}
+	printf("  cmpq %s, %s\n", raSecondOperand(f, in), a)
+}
+
+func raEmitInstr(f *raFunc, in *raInstr) {
+	switch in.op {
+	case "label":
+		printf("  %s:\n", in.sym)
+	case "jmp":
+		printf("  jmp %s\n", in.sym)
+	case "ret":
+		printf("  jmp %s # return\n", in.sym)
+	case "asm":
+		for _, s := range in.text {
+			printf("%s", s)
+		}
+	case "li":
+		if isImm32(in.imm) {
+			printf("  movq $%d, %s\n", in.imm, f.loc[in.dst])
+		} else {
+			reg := raDstReg(f, in.dst)
+			printf("  movabsq $%d, %s\n", in.imm, reg)
+			raSetDst(f, in.dst, reg)
+		}
+	case "mov":
+		if f.loc[in.dst] != f.loc[in.src1] {
+			raSetDst(f, in.dst, raReg(f, in.src1, "%rax"))
+		}
+	case "ldm":
+		reg := raDstReg(f, in.dst)
+		printf("  %s %s, %s\n", raLoadInst(in.knd), in.sym, reg)
+		raSetDst(f, in.dst, reg)
+	case "stm":
+		printf("  %s %s\n", raStoreValue(f, in.src1, in.knd), in.sym)
+	case "lea":
+		reg := raDstReg(f, in.dst)
+		printf("  leaq %s, %s\n", in.sym, reg)
+		raSetDst(f, in.dst, reg)
+	case "load":
+		base := raReg(f, in.src1, "%rax")
+		reg := raDstReg(f, in.dst)
+		printf("  %s %d(%s), %s\n", raLoadInst(in.knd), in.imm, base, reg)
+		raSetDst(f, in.dst, reg)
+	case "store":
+		base := raReg(f, in.src1, "%rax")
+		printf("  %s %d(%s)\n", raStoreValue(f, in.src2, in.knd), in.imm, base)
+	case "add", "sub", "imul", "and", "or":
+		reg := raDstReg(f, in.dst)
+		b := raSecondOperand(f, in)
+		if b == reg {
+			reg = "%rax"
+		}
+		if f.loc[in.src1] != reg {
+			printf("  movq %s, %s\n", f.loc[in.src1], reg)
+		}
+		printf("  %sq %s, %s\n", in.op, b, reg)
+		raSetDst(f, in.dst, reg)
+	case "div", "mod":
+		printf("  movq %s, %%rax\n", f.loc[in.src1])
+		printf("  movq $0, %%rdx # init %%rdx\n")
+		if in.useImm {
+			printf("  movq $%d, %%rcx\n", in.imm)
+			printf("  divq %%rcx\n")
+		} else {
+			printf("  divq %s\n", f.loc[in.src2])
+		}
+		if in.op == "div" {
+			raSetDst(f, in.dst, "%rax")
+		} else {
+			raSetDst(f, in.dst, "%rdx")
+		}
+	case "neg", "not":
+		reg := raDstReg(f, in.dst)
+		if f.loc[in.src1] != reg {
+			printf("  movq %s, %s\n", f.loc[in.src1], reg)
+		}
+		if in.op == "neg" {
+			printf("  negq %s\n", reg)
+		} else {
+			printf("  xorq $1, %s\n", reg)
+		}
+		raSetDst(f, in.dst, reg)
+	case "set":
+		raCompareOperands(f, in)
+		printf("  set%s %%al\n", in.cc)
+		printf("  movzbq %%al, %%rax\n")
+		raSetDst(f, in.dst, "%rax")
+	case "cmpbr":
+		raCompareOperands(f, in)
+		printf("  j%s %s\n", in.cc, in.sym)
+	case "pop":
+		if in.knd == T_UINT8 {
+			printf("  movzbq (%%rsp), %%rax # load uint8\n")
+			printf("  addq $1, %%rsp # free returnvars area\n")
+			raSetDst(f, in.dst, "%rax")
+		} else {
+			printf("  popq %s\n", f.loc[in.dst])
+		}
+	case "alloc":
+		if in.imm != 0 {
+			printf("  subq $%d, %%rsp # alloc return vars and parameters area\n", in.imm)
+		}
+	case "free":
+		if in.imm != 0 {
+			printf("  addq $%d, %%rsp # free area\n", in.imm)
+		}
+	case "starg":
+		printf("  %s %d(%%rsp)\n", raStoreValue(f, in.src1, in.knd), in.imm)
+	case "call":
+		emitCallInstr(in.sym, in.pos)
+	default:
+		panic("unknown instruction " + in.op)
+	}
+}
+
+// --- peephole ---
+// At -O1 the assembly emitted by printf goes through a peephole optimizer before it is written to fout.
+// It keeps a window of the latest lines and rewrites its tail as each line arrives:
+//
+//	pushq X; popq X  =>  (nothing)
+//	pushq X; popq R  =>  movq X, R
+//	jmp L; L:        =>  L:
+//
+// Comments between the instructions are kept. Labels and directives end a pattern,
+// so code is never moved across a jump target.
+
+// -O0: write the assembly as it is emitted, -O1: optimize it (default)
+var optLevel int = 1
+
+// lines not written yet, oldest first. A removed line is left empty.
+var peepLines []string
+
+// text after the last newline
+var peepPartial string
+
+// peepWrite splits s into lines and passes them to the optimizer.
+func peepWrite(s string) {
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			line := s[start : i+1]
+			if peepPartial != "" {
+				line = peepPartial + line
+				peepPartial = ""
+			}
+			peepLine(line)
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		peepPartial = peepPartial + s[start:]
+	}
+}
+
+func peepLine(line string) {
+	op, arg := peepInstr(line)
+	if op == "popq" && len(arg) > 0 && arg[0] == '%' {
+		i := peepPrevInstr()
+		if i >= 0 {
+			prevOp, prevArg := peepInstr(peepLines[i])
+			if prevOp == "pushq" {
+				if prevArg == arg {
+					peepLines[i] = ""
+				} else {
+					peepLines[i] = "  movq " + prevArg + ", " + arg + "\n"
+				}
+				return
+			}
+		}
+	}
+
+	label := peepLabel(line)
+	if label != "" {
+		for i := len(peepLines) - 1; i >= 0; i-- {
+			prev := peepLines[i]
+			if peepBlank(prev) || peepLabel(prev) != "" {
+				continue
+			}
+			prevOp, prevArg := peepInstr(prev)
+			if prevOp == "jmp" && prevArg == label {
+				peepLines[i] = ""
+			}
+			break
+		}
+	}
+
+	peepLines = append(peepLines, line)
+	if len(peepLines) >= 128 {
+		peepFlush(64)
+	}
+}
+
+// peepPrevInstr returns the index of the last pending instruction,
+// or -1 if a label or a directive comes after it.
+func peepPrevInstr() int {
+	for i := len(peepLines) - 1; i >= 0; i-- {
+		line := peepLines[i]
+		if peepBlank(line) {
+			continue
+		}
+		op, _ := peepInstr(line)
+		if op == "" {
+			return -1
+		}
+		return i
+	}
+	return -1
+}
+
+// peepBlank reports whether line is removed, empty or a comment.
+func peepBlank(line string) bool {
+	s := strings.TrimSpace(line)
+	return len(s) == 0 || s[0] == '#'
+}
+
+// peepLabel returns the label defined by line, or "" if it is not a label.
+func peepLabel(line string) string {
+	s := strings.TrimSpace(line)
+	if len(s) < 2 || s[len(s)-1] != ':' || strings.Contains(s, " ") {
+		return ""
+	}
+	return s[:len(s)-1]
+}
+
+// peepInstr splits an instruction into its mnemonic and operands without the comment.
+// It returns empty strings for anything other than an instruction.
+func peepInstr(line string) (string, string) {
+	i := 0
+	for i < len(line) && line[i] == ' ' {
+		i++
+	}
+	j := i
+	for j < len(line) && line[j] != ' ' && line[j] != '\n' {
+		j++
+	}
+	op := line[i:j]
+	if len(op) == 0 || op[0] == '#' || op[0] == '.' || op[len(op)-1] == ':' {
+		return "", ""
+	}
+	k := j
+	for k < len(line) && line[k] != '#' && line[k] != '\n' {
+		k++
+	}
+	return op, strings.TrimSpace(line[j:k])
+}
+
+// peepFlush writes the first n pending lines to fout.
+func peepFlush(n int) {
+	var buf []byte
+	for i := 0; i < n; i++ {
+		line := peepLines[i]
+		for j := 0; j < len(line); j++ {
+			buf = append(buf, line[j])
+		}
+	}
+	if len(buf) > 0 {
+		fout.Write(buf)
+	}
+	peepLines = peepLines[n:]
+}
+
+// peepFlushAll writes all the pending output to fout.
+func peepFlushAll() {
+	if peepPartial != "" {
+		peepLines = append(peepLines, peepPartial)
+		peepPartial = ""
+	}
+	peepFlush(len(peepLines))
+}
+
+// --- type ---
+type Type struct {
+	E       ast.Expr // original
+	PkgName string
+	Name    string
+}
+
+type TypeKind string
+
+const T_STRING TypeKind = "T_STRING"
+const T_INTERFACE TypeKind = "T_INTERFACE"
+const T_FUNC TypeKind = "T_FUNC"
+const T_SLICE TypeKind = "T_SLICE"
+const T_BOOL TypeKind = "T_BOOL"
+const T_INT TypeKind = "T_INT"
+const T_INT32 TypeKind = "T_INT32"
+const T_UINT8 TypeKind = "T_UINT8"
+const T_UINT16 TypeKind = "T_UINT16"
+const T_UINTPTR TypeKind = "T_UINTPTR"
+const T_ARRAY TypeKind = "T_ARRAY"
+const T_STRUCT TypeKind = "T_STRUCT"
+const T_POINTER TypeKind = "T_POINTER"
+const T_MAP TypeKind = "T_MAP"
+
+// types of an expr in Single value context
+func getTypeOfExpr(meta MetaExpr) *Type {
+	switch m := meta.(type) {
+	case *MetaBasicLit:
+		return m.typ
+	case *MetaCompositLit:
+		return m.typ
+	case *MetaIdent:
+		return m.typ
+	case *MetaSelectorExpr:
+		return getTypeOfExprAst(m.e)
+	case *MetaCallExpr: // funcall or conversion
+		return m.typ // can be nil. if Tuple , m.types has types
+	case *MetaIndexExpr:
+		return m.typ
+	case *MetaSliceExpr:
+		return m.typ
+	case *MetaStarExpr:
+		return m.typ
+	case *MetaUnaryExpr:
+		return m.typ
+	case *MetaBinaryExpr:
+		return m.typ
+	case *MetaTypeAssertExpr:
+		return m.typ
+	case *MetaInlineCall:
+		return m.typ
+	}
+	panic("bad type\n")
+}
+
+// isUntypedConst reports whether e is an untyped constant expression, which takes the type of the other operand.
+func isUntypedConst(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		return true
+	case *ast.ParenExpr:
+		return isUntypedConst(e.X)
+	case *ast.UnaryExpr:
+		return e.Op.String() != "&" && isUntypedConst(e.X)
+	case *ast.BinaryExpr:
+		return isUntypedConst(e.X) && isUntypedConst(e.Y)
+	case *ast.Ident:
+		if e.Obj == nil || e.Obj.Kind != ast.Con {
+			return false
+		}
+		if e.Obj == gTrue || e.Obj == gFalse {
+			return true
+		}
+		spec, ok := e.Obj.Decl.(*ast.ValueSpec)
+		return ok && spec.Type == nil && isUntypedConst(spec.Values[0])
+	}
+	return false
+}
+
+func getTypeOfExprAst(expr ast.Expr) *Type {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		assert(e.Obj != nil, "Obj is nil in ident '"+e.Name+"'", __func__)
+		switch e.Obj.Kind {
+		case ast.Var:
+			variable, isVariable := e.Obj.Data.(*Variable)
+			if isVariable {
+				return variable.Typ
+			}
+			panic("Variable is not set for ident:" + e.Name)
+		case ast.Con:
+			switch e.Obj {
+			case gTrue, gFalse:
+				return tBool
+			default:
+				switch decl2 := e.Obj.Decl.(type) {
+				case *ast.ValueSpec:
+					if decl2.Type == nil {
+						// untyped constant
+						return getTypeOfExprAst(decl2.Values[0])
+					}
+					return e2t(decl2.Type)
+				default:
+					panic("cannot decide type of cont =" + e.Obj.Name)
+				}
+			}
+		case ast.Fun:
+			return e2t(e.Obj.Decl.(*ast.FuncDecl).Type)
+		default:
+			panic(fmt.Sprintf("Obj=%s, Kind=%s\t\n%s", e.Obj.Name, e.Obj.Kind.String(), positionString(e.Pos())))
+		}
+	case *ast.BasicLit:
+		// The default type of an untyped constant is bool, rune, int, float64, complex128 or string respectively,
+		// depending on whether it is a boolean, rune, integer, floating-point, complex, or string constant.
+		switch e.Kind.String() {
+		case "STRING":
+			return tString
+		case "INT":
+			return tInt
+		case "CHAR":
+			return tInt32
+		default:
+			panic(e.Kind.String())
+		}
+	case *ast.UnaryExpr:
+		switch e.Op.String() {
+		case "+":
+			return getTypeOfExprAst(e.X)
+		case "-":
+			return getTypeOfExprAst(e.X)
+		case "!":
+			return tBool
+		case "&":
+			t := getTypeOfExprAst(e.X)
+			starExpr := &ast.StarExpr{
+				X: t.E,
+			}
+			return e2t(starExpr)
+		default:
+			panic(e.Op.String())
+		}
+	case *ast.BinaryExpr:
+		switch e.Op.String() {
+		case "==", "!=", "<", ">", "<=", ">=":
+			return tBool
+		default:
+			if isUntypedConst(e.X) {
+				// 2 * time.Second is of type time.Duration
+				return getTypeOfExprAst(e.Y)
+			}
+			return getTypeOfExprAst(e.X)
+		}
+	case *ast.IndexExpr:
+		list := e.X
+		return getElementTypeOfCollectionType(getTypeOfExprAst(list))
+	case *ast.CallExpr: // funcall or conversion
+		types := getCallResultTypes(e)
+		assert(len(types) == 1, "Single value is expected", __func__)
+		return types[0]
+	case *ast.SliceExpr:
+		underlyingCollectionType := getTypeOfExprAst(e.X)
+		if kind(underlyingCollectionType) == T_STRING {
+			// str2 = str1[n:m]
+			return tString
+		}
+		var elementTyp ast.Expr
+		switch colType := underlyingCollectionType.E.(type) {
+		case *ast.ArrayType:
+			elementTyp = colType.Elt
+		}
+		r := &ast.ArrayType{
+			Len: nil,
+			Elt: elementTyp,
+		}
+		return e2t(r)
+	case *ast.StarExpr:
+		t := getTypeOfExprAst(e.X)
+		ptrType := t.E.(*ast.StarExpr)
+		return e2t(ptrType.X)
+	case *ast.SelectorExpr:
+		if isQI(e) { // pkg.SomeType
+			ident := lookupForeignIdent(selector2QI(e))
+			return getTypeOfExprAst(ident)
+		} else { // (e).field
+			ut := getUnderlyingType(getTypeOfExprAst(e.X))
+			var structTypeLiteral *ast.StructType
+			switch typ := ut.E.(type) {
+			case *ast.StructType: // strct.field
+				structTypeLiteral = typ
+			case *ast.StarExpr: // ptr.field
+				structType := e2t(typ.X)
+				structTypeLiteral = getUnderlyingStructType(structType)
+			}
+			field := lookupStructField(structTypeLiteral, e.Sel.Name)
+			return e2t(field.Type)
+		}
+	case *ast.CompositeLit:
+		return e2t(e.Type)
+	case *ast.ParenExpr:
+		return getTypeOfExprAst(e.X)
+	case *ast.TypeAssertExpr:
+		return e2t(e.Type)
+	}
+	panic("bad type\n")
+}
+
+func fieldList2Types(fldlist *ast.FieldList) []*Type {
+	var r []*Type
+	for _, e2 := range fldlist.List {
+		t := e2t(e2.Type)
+		r = append(r, t)
+	}
+	return r
+}
+
+func getTupleTypes(rhsMeta MetaExpr) []*Type {
+	if IsOkSyntax(rhsMeta) {
+		return []*Type{getTypeOfExpr(rhsMeta), tBool}
+	} else {
+		rhs, ok := rhsMeta.(*MetaCallExpr)
+		if !ok {
+			panic("is not *MetaCallExpr")
+		}
+		return getCallResultTypes(rhs.e)
+	}
+}
+
+func getCallResultTypes(e *ast.CallExpr) []*Type {
+	switch fn := e.Fun.(type) {
+	case *ast.Ident:
+		if fn.Obj == nil {
+			throw(fn)
+		}
+		switch fn.Obj.Kind {
+		case ast.Var:
+			switch dcl := fn.Obj.Decl.(type) {
+			case *ast.AssignStmt: // short var decl
+				rhs := dcl.Rhs[0]
+				switch r := rhs.(type) {
+				case *ast.SelectorExpr:
+					assert(isQI(r), "expect QI", __func__)
+					ff := lookupForeignFunc(selector2QI(r))
+					return fieldList2Types(ff.funcType.Results)
+				}
+			case *ast.ValueSpec: // var v func(T1)T2 or var v F
+				return fieldList2Types(getUnderlyingType(e2t(dcl.Type)).E.(*ast.FuncType).Results)
+			default:
+				throw(dcl)
+			}
+		case ast.Typ: // conversion
+			return []*Type{e2t(fn)}
+		case ast.Fun:
+			switch fn.Obj {
+			case gLen, gCap:
+				return []*Type{tInt}
+			case gNew:
+				starExpr := &ast.StarExpr{
+					X: e.Args[0],
+				}
+				// walkExpr(starExpr, nil) // Do we need to walk type ?
+				return []*Type{e2t(starExpr)}
+			case gMake:
+				return []*Type{e2t(e.Args[0])}
+			case gAppend:
+				return []*Type{e2t(e.Args[0])}
+			}
+			decl := fn.Obj.Decl
+			if decl == nil {
+				panic("decl of function " + fn.Name + " should not nil")
+			}
+			switch dcl := decl.(type) {
+			case *ast.FuncDecl:
+				return fieldList2Types(dcl.Type.Results)
+			default:
+				throw(decl)
+			}
+		}
+	case *ast.ParenExpr: // (X)(e) funcall or conversion
+		if isType(fn.X) {
+			return []*Type{e2t(fn.X)}
+		} else {
+			panic("TBI: what should we do ?")
+		}
+	case *ast.ArrayType: // conversion [n]T(e) or []T(e)
+		return []*Type{e2t(fn)}
+	case *ast.SelectorExpr:
+		if isType(fn) {
+			return []*Type{e2t(fn)}
+		}
+		if isQI(fn) { // pkg.Sel()
+			ff := lookupForeignFunc(selector2QI(fn))
+			return fieldList2Types(ff.funcType.Results)
+		} else { // obj.method()
+			rcvType := getTypeOfExprAst(fn.X)
+			if isInterface(rcvType) {
+				return fieldList2Types(lookupInterfaceMethod(rcvType, fn.Sel.Name).Results)
+			}
+			method := lookupMethod(rcvType, fn.Sel)
+			return fieldList2Types(method.FuncType.Results)
+		}
+	case *ast.InterfaceType:
+		return []*Type{tEface}
+	}
+
+	throw(e)
+	return nil
+}
+
+func e2t(typeExpr ast.Expr) *Type {
+	if typeExpr == nil {
+		panic("nil is not allowed")
+	}
+	return &Type{
+		E: typeExpr,
+	}
+}
+
+func serializeType(t *Type) string {
+	if t == nil {
+		panic("nil type is not expected")
+	}
+	if t.E == generalSlice {
+		panic("TBD: generalSlice")
+	}
+	if t.Name != "" {
+		return t.PkgName + "." + t.Name
+	}
+	switch e := t.E.(type) {
+	case *ast.Ident:
+		if e.Obj == nil {
+			panic("Unresolved identifier:" + e.Name)
+		}
+		if e.Obj.Kind == ast.Var {
+			throw(e.Obj)
+		} else if e.Obj.Kind == ast.Typ {
+			switch e.Obj {
+			case gUintptr:
+				return "uintptr"
+			case gInt:
+				return "int"
+			case gInt32:
+				return "int32"
+			case gString:
+				return "string"
+			case gUint8:
+				return "uint8"
+			case gUint16:
+				return "uint16"
+			case gBool:
+				return "bool"
+			case gError:
+				return "error"
+			default:
+				// named type
+				decl := e.Obj.Decl
+				typeSpec := decl.(*ast.TypeSpec)
+				pkgName := typeSpec.Name.Obj.Data.(string)
+				return pkgName + "." + typeSpec.Name.Name
+			}
+		}
+	case *ast.StructType:
+		r := "struct{"
+		if e.Fields != nil {
+			for _, field := range e.Fields.List {
+				name := field.Names[0].Name
+				typ := e2t(field.Type)
+				r += fmt.Sprintf("%s %s;", name, serializeType(typ))
+			}
+		}
+		return r + "}"
+	case *ast.ArrayType:
+		if e.Len == nil {
+			if e.Elt == nil {
+				panic(e)
+			}
+			return "[]" + serializeType(e2t(e.Elt))
+		} else {
+			return "[" + strconv.Itoa(evalInt(e.Len)) + "]" + serializeType(e2t(e.Elt))
+		}
+	case *ast.StarExpr:
+		return "*" + serializeType(e2t(e.X))
+	case *ast.Ellipsis: // x ...T
+		panic("TBD: Ellipsis")
+	case *ast.InterfaceType:
+		return "interface{}" // @TODO list methods
+	case *ast.MapType:
+		return "map[" + serializeType(e2t(e.Key)) + "]" + serializeType(e2t(e.Value))
+	case *ast.SelectorExpr:
+		qi := selector2QI(e)
+		return string(qi)
+	case *ast.FuncType:
+		return "func"
+	case *ast.ParenExpr:
+		return serializeType(e2t(e.X))
+	default:
+		throw(t)
+	}
+	return ""
+}
+
+func getUnderlyingStructType(t *Type) *ast.StructType {
+	ut := getUnderlyingType(t)
+	return ut.E.(*ast.StructType)
+}
+
+func getUnderlyingType(t *Type) *Type {
+	if t == nil {
+		panic("nil type is not expected")
+	}
+	if t.E == generalSlice {
+		return t
+	}
+
+	switch e := t.E.(type) {
+	case *ast.StructType, *ast.ArrayType, *ast.StarExpr, *ast.Ellipsis, *ast.MapType, *ast.InterfaceType:
+		// type literal
+		return t
+	case *ast.Ident:
+		assert(e.Obj != nil, "should not be nil : "+e.Name, __func__)
+		assert(e.Obj.Kind == ast.Typ, "should be ast.Typ : "+e.Name, __func__)
+		switch e.Obj {
+		case gUintptr, gInt, gInt32, gString, gUint8, gUint16, gBool:
+			return t
+		case gError:
+			return e2t(getErrorInterface())
+		}
+		// defined type or alias
+		typeSpec := e.Obj.Decl.(*ast.TypeSpec)
+		// get RHS in its type definition recursively
+		return getUnderlyingType(e2t(typeSpec.Type))
+	case *ast.SelectorExpr:
+		ident := lookupForeignIdent(selector2QI(e))
+		return getUnderlyingType(e2t(ident))
+	case *ast.ParenExpr:
+		return getUnderlyingType(e2t(e.X))
+	case *ast.FuncType:
+		return t
+	}
+	throw(t.E)
+	return nil
+}
+
+func kind(t *Type) TypeKind {
+	if t == nil {
+		panic("nil type is not expected")
+	}
+
+	ut := getUnderlyingType(t)
+	if ut.E == generalSlice {
+		return T_SLICE
+	}
+
+	switch e := ut.E.(type) {
+	case *ast.Ident:
+		assert(e.Obj.Kind == ast.Typ, "should be ast.Typ", __func__)
+		switch e.Obj {
+		case gUintptr:
+			return T_UINTPTR
+		case gInt:
+			return T_INT
+		case gInt32:
+			return T_INT32
+		case gString:
+			return T_STRING
+		case gUint8:
+			return T_UINT8
+		case gUint16:
+			return T_UINT16
+		case gBool:
+			return T_BOOL
+		case gError:
+			return T_INTERFACE
+		default:
+			panic("Unexpected type")
+		}
+	case *ast.StructType:
+		return T_STRUCT
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return T_SLICE
+		} else {
+			return T_ARRAY
+		}
+	case *ast.StarExpr:
+		return T_POINTER
+	case *ast.Ellipsis: // x ...T
+		return T_SLICE // @TODO is this right ?
+	case *ast.MapType:
+		return T_MAP
+	case *ast.InterfaceType:
+		return T_INTERFACE
+	case *ast.FuncType:
+		return T_FUNC
+	}
+	panic("should not reach here")
+}
+
+func isInterface(t *Type) bool {
+	return kind(t) == T_INTERFACE
+}
+
+func getElementTypeOfCollectionType(t *Type) *Type {
+	ut := getUnderlyingType(t)
+	switch kind(ut) {
+	case T_SLICE, T_ARRAY:
+		switch e := ut.E.(type) {
+		case *ast.ArrayType:
+			return e2t(e.Elt)
+		case *ast.Ellipsis:
+			return e2t(e.Elt)
+		default:
+			throw(t.E)
+		}
+	case T_STRING:
+		return tUint8
+	case T_MAP:
+		mapType := ut.E.(*ast.MapType)
+		return e2t(mapType.Value)
+	default:
+		unexpectedKind(kind(t))
+	}
+	return nil
+}
+
+func getKeyTypeOfCollectionType(t *Type) *Type {
+	ut := getUnderlyingType(t)
+	switch kind(ut) {
+	case T_SLICE, T_ARRAY, T_STRING:
+		return tInt
+	case T_MAP:
+		mapType := ut.E.(*ast.MapType)
+		return e2t(mapType.Key)
+	default:
+		unexpectedKind(kind(t))
+	}
+	return nil
+}
+
+const SizeOfSlice int = 24
+const SizeOfString int = 16
+const SizeOfInt int = 8
+const SizeOfUint8 int = 1
+const SizeOfUint16 int = 2
+const SizeOfPtr int = 8
+const SizeOfInterface int = 16
+
+func getSizeOfType(t *Type) int {
+	ut := getUnderlyingType(t)
+	switch kind(ut) {
+	case T_SLICE:
+		return SizeOfSlice
+	case T_STRING:
+		return SizeOfString
+	case T_INT:
+		return SizeOfInt
+	case T_UINTPTR, T_POINTER, T_MAP:
+		return SizeOfPtr
+	case T_UINT8:
+		return SizeOfUint8
+	case T_UINT16:
+		return SizeOfUint16
+	case T_BOOL:
+		return SizeOfInt
+	case T_INTERFACE:
+		return SizeOfInterface
+	case T_ARRAY:
+		arrayType := ut.E.(*ast.ArrayType)
+		elmSize := getSizeOfType(e2t(arrayType.Elt))
+		return elmSize * evalInt(arrayType.Len)
+	case T_STRUCT:
+		return calcStructSizeAndSetFieldOffset(ut.E.(*ast.StructType))
+	case T_FUNC:
+		return SizeOfPtr
+	default:
+		unexpectedKind(kind(t))
+	}
+	return 0
+}
+
+func lookupStructField(structType *ast.StructType, selName string) *ast.Field {
+	for _, field := range structType.Fields.List {
+		if field.Names[0].Name == selName {
+			return field
+		}
+	}
+	panic("Unexpected flow: struct field not found:" + selName)
+}
+
+// lookupFieldType returns the type of the field selName of a struct or a pointer to struct,
+// or nil if t has no such field (e.g. selName is a method).
+func lookupFieldType(t *Type, selName string) *Type {
+	if t == nil {
+		return nil
+	}
+	ut := getUnderlyingType(t)
+	var structTypeLiteral *ast.StructType
+	switch typ := ut.E.(type) {
+	case *ast.StructType: // strct.field
+		structTypeLiteral = typ
+	case *ast.StarExpr: // ptr.field
+		structType, isStruct := getUnderlyingType(e2t(typ.X)).E.(*ast.StructType)
+		if isStruct {
+			structTypeLiteral = structType
+		}
+	}
+	if structTypeLiteral == nil || structTypeLiteral.Fields == nil {
+		return nil
+	}
+	for _, field := range structTypeLiteral.Fields.List {
+		if field.Names[0].Name == selName {
+			return e2t(field.Type)
+		}
+	}
+	return nil
+}
+
+func calcStructSizeAndSetFieldOffset(structType *ast.StructType) int {
+	var offset int = 0
+	for _, field := range structType.Fields.List {
+		setStructFieldOffset(field, offset)
+		size := getSizeOfType(e2t(field.Type))
+		offset += size
+	}
+	return offset
+}
+
+// --- walk ---
+type sliteral struct {
+	label  string
+	strlen int
+	value  string // raw value
+}
+
+func registerParamVariable(fnc *Func, name string, t *Type) *Variable {
+	vr := newLocalVariable(name, fnc.Argsarea, t)
+	size := getSizeOfType(t)
+	fnc.Argsarea += size
+	fnc.Params = append(fnc.Params, vr)
+	return vr
+}
+
+func registerReturnVariable(fnc *Func, name string, t *Type) *Variable {
+	vr := newLocalVariable(name, fnc.Argsarea, t)
+	size := getSizeOfType(t)
+	fnc.Argsarea += size
+	fnc.Retvars = append(fnc.Retvars, vr)
+	return vr
+}
+
+func registerLocalVariable(fnc *Func, name string, t *Type) *Variable {
+	assert(t != nil && t.E != nil, "type of local var should not be nil", __func__)
+	fnc.Localarea -= getSizeOfType(t)
+	vr := newLocalVariable(name, currentFunc.Localarea, t)
+	vr.LoopDepth = loopDepth
+	vr.Loop = currentLoopBody
+	fnc.LocalVars = append(fnc.LocalVars, vr)
+	return vr
+}
+
+var currentFor *MetaForContainer
+var loopDepth int                     // number of loop bodies enclosing the statement being walked
+var currentLoopBody *MetaForContainer // innermost loop whose body is being walked
+var currentFunc *Func
+
+func registerStringLiteral(lit *ast.BasicLit) *sliteral {
+	if currentPkg.name == "" {
+		panic("no pkgName")
+	}
+
+	// count the bytes between the quotes, an escape sequence being one byte
+	var strlen int
+	value := lit.Value
+	for i := 1; i < len(value)-1; i++ {
+		if value[i] == '\\' {
+			i++
+		}
+		strlen++
+	}
+
+	label := fmt.Sprintf(".string_%d", currentPkg.stringIndex)
+	currentPkg.stringIndex++
+
+	sl := &sliteral{
+		label:  label,
+		strlen: strlen,
+		value:  lit.Value,
+	}
+	currentPkg.stringLiterals = append(currentPkg.stringLiterals, sl)
+	return sl
+}
+
+func newGlobalVariable(pkgName string, name string, t *Type) *Variable {
+	return &Variable{
+		Name:         name,
+		IsGlobal:     true,
+		GlobalSymbol: pkgName + "." + name,
+		Typ:          t,
+	}
+}
+
+func newLocalVariable(name string, localoffset int, t *Type) *Variable {
+	return &Variable{
+		Name:        name,
+		IsGlobal:    false,
+		LocalOffset: localoffset,
+		Typ:         t,
+	}
+}
+
+type QualifiedIdent string
+
+func newQI(pkg string, ident string) QualifiedIdent {
+	return QualifiedIdent(pkg + "." + ident)
+}
+
+func isQI(e *ast.SelectorExpr) bool {
+	ident, isIdent := e.X.(*ast.Ident)
+	if !isIdent {
+		return false
+	}
+	assert(ident.Obj != nil, "ident.Obj is nil:"+ident.Name, __func__)
+	return ident.Obj.Kind == ast.Pkg
+}
+
+func selector2QI(e *ast.SelectorExpr) QualifiedIdent {
+	pkgName := e.X.(*ast.Ident)
+	assert(pkgName.Obj.Kind == ast.Pkg, "should be ast.Pkg", __func__)
+	return newQI(pkgName.Name, e.Sel.Name)
+}
+
+func newMethod(pkgName string, funcDecl *ast.FuncDecl) *Method {
+	rcvType := funcDecl.Recv.List[0].Type
+	rcvPointerType, isPtr := rcvType.(*ast.StarExpr)
+	if isPtr {
+		rcvType = rcvPointerType.X
+	}
+	rcvNamedType := rcvType.(*ast.Ident)
+	method := &Method{
+		PkgName:      pkgName,
+		RcvNamedType: rcvNamedType,
+		IsPtrMethod:  isPtr,
+		Name:         funcDecl.Name.Name,
+		FuncType:     funcDecl.Type,
+	}
+	return method
+}
+
+// https://golang.org/ref/spec#Method_sets
+// @TODO map key should be a QI ?
+var MethodSets = make(map[unsafe.Pointer]*NamedType)
+
+type NamedType struct {
+	methodSet map[string]*Method
+	names     []string // of the methods in the order of registration
+}
+
+func registerMethod(method *Method) {
+	key := unsafe.Pointer(method.RcvNamedType.Obj)
+	namedType, ok := MethodSets[key]
+	if !ok {
+		namedType = &NamedType{
+			methodSet: make(map[string]*Method),
+		}
+		MethodSets[key] = namedType
+	}
+	_, ok = namedType.methodSet[method.Name]
+	if !ok {
+		namedType.names = append(namedType.names, method.Name)
+	}
+	namedType.methodSet[method.Name] = method
+}
+
+// errorInterface is the underlying type of error: interface{ Error() string }
+var errorInterface *ast.InterfaceType
+
+func getErrorInterface() *ast.InterfaceType {
+	if errorInterface != nil {
+		return errorInterface
+	}
+	ft := &ast.FuncType{
+		Params: &ast.FieldList{},
+		Results: &ast.FieldList{
+			List: []*ast.Field{
+				&ast.Field{
+					Type: tString.E,
+				},
+			},
+		},
+	}
+	errorInterface = &ast.InterfaceType{
+		Methods: &ast.FieldList{
+			List: []*ast.Field{
+				&ast.Field{
+					Names: []*ast.Ident{&ast.Ident{Name: "Error"}},
+					Type:  ft,
+				},
+			},
+		},
+	}
+	return errorInterface
+}
+
+// lookupInterfaceMethod returns the signature of the method name of an interface type, or nil if there is none.
+func lookupInterfaceMethod(t *Type, name string) *ast.FuncType {
+	it := getUnderlyingType(t).E.(*ast.InterfaceType)
+	if it.Methods == nil {
+		return nil
+	}
+	for _, field := range it.Methods.List {
+		if field.Names[0].Name == name {
+			return field.Type.(*ast.FuncType)
+		}
+	}
+	return nil
+}
+
+// interfaceMethodNames returns the names of the methods of an interface type separated by spaces.
+func interfaceMethodNames(t *Type) string {
+	return joinStrings(dtypeInterfaceMethods(t), " ")
+}
+
+func lookupMethod(rcvT *Type, methodName *ast.Ident) *Method {
+	rcvType := rcvT.E
+	rcvPointerType, isPtr := rcvType.(*ast.StarExpr)
+	if isPtr {
+		rcvType = rcvPointerType.X
+	}
+	var typeObj *ast.Object
+	switch typ := rcvType.(type) {
+	case *ast.Ident:
+		typeObj = typ.Obj
+	case *ast.SelectorExpr:
+		t := lookupForeignIdent(selector2QI(typ))
+		typeObj = t.Obj
+	default:
+		panic(rcvType)
+	}
+
+	namedType, ok := MethodSets[unsafe.Pointer(typeObj)]
+	if !ok {
+		panic(typeObj.Name + " has no methodSet")
+	}
+	method, ok := namedType.methodSet[methodName.Name]
+	if !ok {
+		panic("method not found: " + methodName.Name)
+	}
+	return method
+}
+
+func walkExprStmt(s *ast.ExprStmt) *MetaExprStmt {
+	call, isCall := s.X.(*ast.CallExpr)
+	if isCall {
+		// a call whose result is discarded is not inlined
+		return &MetaExprStmt{X: walkCallExpr(call, nil)}
+	}
+	m := walkExpr(s.X, nil)
+	return &MetaExprStmt{X: m}
+}
+
+func walkDeclStmt(s *ast.DeclStmt) *MetaVarDecl {
+	genDecl := s.Decl.(*ast.GenDecl)
+	declSpec := genDecl.Specs[0]
+	switch spec := declSpec.(type) {
+	case *ast.ValueSpec:
+		lhsIdent := spec.Names[0]
+		var rhsMeta MetaExpr
+		var t *Type
+		if spec.Type != nil { // var x T = e
+			// walkExpr(spec.Type, nil) // Do we need to walk type ?
+			t = e2t(spec.Type)
+			if len(spec.Values) > 0 {
+				rhs := spec.Values[0]
+				ctx := &evalContext{_type: t}
+				rhsMeta = walkExpr(rhs, ctx)
+			}
+		} else { // var x = e  infer lhs type from rhs
+			if len(spec.Values) == 0 {
+				panic("invalid syntax")
+			}
+
+			rhs := spec.Values[0]
+			rhsMeta = walkExpr(rhs, nil)
+			t = getTypeOfExpr(rhsMeta)
+			if t == nil {
+				panic("rhs should have a type")
+			}
+		}
+		spec.Type = t.E // set lhs type
+
+		obj := lhsIdent.Obj
+		setVariable(obj, registerLocalVariable(currentFunc, obj.Name, t))
+		lhsMeta := walkIdent(lhsIdent, nil)
+		single := &MetaSingleAssign{
+			Lhs: lhsMeta,
+			Rhs: rhsMeta,
+		}
+		return &MetaVarDecl{
+			Single:  single,
+			LhsType: t,
+		}
+	default:
+		// @TODO type, const, etc
+	}
+
+	panic("TBI")
+}
+
+func IsOkSyntax(rhs MetaExpr) bool {
+	typeAssertion, isTypeAssertion := rhs.(*MetaTypeAssertExpr)
+	if isTypeAssertion && typeAssertion.NeedsOK {
+		return true
+	}
+	indexExpr, isIndexExpr := rhs.(*MetaIndexExpr)
+	if isIndexExpr && indexExpr.NeedsOK {
+		return true
+	}
+	return false
+}
+
+func walkAssignStmt(s *ast.AssignStmt) MetaStmt {
+	stok := s.Tok.String()
+	switch stok {
+	case "=":
+		if len(s.Lhs) == 1 && len(s.Rhs) == 1 {
+			// Single assignment
+			var lhsMetas []MetaExpr
+			for _, lhs := range s.Lhs {
+				lm := walkExpr(lhs, nil)
+				lhsMetas = append(lhsMetas, lm)
+			}
+			var ctx *evalContext
+			if !isBlankIdentifierMeta(lhsMetas[0]) {
+				ctx = &evalContext{
+					_type: getTypeOfExpr(lhsMetas[0]),
+				}
+			}
+			rhsMeta := walkExpr(s.Rhs[0], ctx)
+			return &MetaSingleAssign{
+				Lhs: lhsMetas[0],
+				Rhs: rhsMeta,
+			}
+		} else if len(s.Lhs) == len(s.Rhs) {
+			panic("TBI")
+		} else if len(s.Lhs) > 1 && len(s.Rhs) == 1 {
+			// Tuple assignment
+			maybeOkContext := len(s.Lhs) == 2
+			rhsMeta := walkExpr(s.Rhs[0], &evalContext{maybeOK: maybeOkContext})
+			isOK := len(s.Lhs) == 2 && IsOkSyntax(rhsMeta)
+			rhsTypes := getTupleTypes(rhsMeta)
+			assert(len(s.Lhs) == len(rhsTypes), fmt.Sprintf("length unmatches %d <=> %d", len(s.Lhs), len(rhsTypes)), __func__)
+
+			var lhsMetas []MetaExpr
+			for _, lhs := range s.Lhs {
+				lm := walkExpr(lhs, nil)
+				lhsMetas = append(lhsMetas, lm)
+			}
+			return &MetaTupleAssign{
+				isOK:     isOK,
+				Lhss:     lhsMetas,
+				Rhs:      rhsMeta,
+				RhsTypes: rhsTypes,
+			}
+		} else {
+			panic("Bad syntax")
+		}
+	case ":=":
+		if len(s.Lhs) == 1 && len(s.Rhs) == 1 {
+			// Single assignment
+			rhsMeta := walkExpr(s.Rhs[0], nil) // FIXME
+			rhsType := getTypeOfExpr(rhsMeta)
+			lhsTypes := []*Type{rhsType}
+			var lhsMetas []MetaExpr
+			for i, lhs := range s.Lhs {
+				typ := lhsTypes[i]
+				obj := lhs.(*ast.Ident).Obj
+				setVariable(obj, registerLocalVariable(currentFunc, obj.Name, typ))
+				lm := walkExpr(lhs, nil)
+				lhsMetas = append(lhsMetas, lm)
+			}
+
+			return &MetaSingleAssign{
+				Lhs: lhsMetas[0],
+				Rhs: rhsMeta,
+			}
+		} else if len(s.Lhs) == len(s.Rhs) {
+			panic("TBI")
+		} else if len(s.Lhs) > 1 && len(s.Rhs) == 1 {
+			// Tuple assignment
+			maybeOkContext := len(s.Lhs) == 2
+			rhsMeta := walkExpr(s.Rhs[0], &evalContext{maybeOK: maybeOkContext})
+			isOK := len(s.Lhs) == 2 && IsOkSyntax(rhsMeta)
+			rhsTypes := getTupleTypes(rhsMeta)
+			assert(len(s.Lhs) == len(rhsTypes), fmt.Sprintf("length unmatches %d <=> %d", len(s.Lhs), len(rhsTypes)), __func__)
+
+			lhsTypes := rhsTypes
+			for i, lhs := range s.Lhs {
+				typ := lhsTypes[i]
+				obj := lhs.(*ast.Ident).Obj
+				setVariable(obj, registerLocalVariable(currentFunc, obj.Name, typ))
+			}
+
+			var lhsMetas []MetaExpr
+			for _, lhs := range s.Lhs {
+				lm := walkExpr(lhs, nil)
+				lhsMetas = append(lhsMetas, lm)
+			}
+			return &MetaTupleAssign{
+				isOK:     isOK,
+				Lhss:     lhsMetas,
+				Rhs:      rhsMeta,
+				RhsTypes: rhsTypes,
+			}
+		} else {
+			panic("Bad syntax")
+		}
+	case "+=", "-=":
+		var op token.Token
+		switch stok {
+		case "+=":
+			op = token.ADD
+		case "-=":
+			op = token.SUB
+		}
+		binaryExpr := &ast.BinaryExpr{
+			X:  s.Lhs[0],
+			Op: op,
+			Y:  s.Rhs[0],
+		}
+		rhsMeta := walkExpr(binaryExpr, nil)
+		lhsMeta := walkExpr(s.Lhs[0], nil)
+		return &MetaSingleAssign{
+			Lhs: lhsMeta,
+			Rhs: rhsMeta,
+		}
+	default:
+		panic("TBI")
+	}
+	return nil
+}
+
+func walkReturnStmt(s *ast.ReturnStmt) *MetaReturnStmt {
+	funcDef := currentFunc
+	if len(funcDef.Retvars) != len(s.Results) {
+		panic("length of return and func type do not match")
+	}
+
+	_len := len(funcDef.Retvars)
+	var results []MetaExpr
+	for i := 0; i < _len; i++ {
+		expr := s.Results[i]
+		retTyp := funcDef.Retvars[i].Typ
+		ctx := &evalContext{
+			_type: retTyp,
+		}
+		m := walkExpr(expr, ctx)
+		results = append(results, m)
+	}
+	return &MetaReturnStmt{
+		Fnc:     funcDef,
+		Results: results,
+	}
+}
+
+func walkIfStmt(s *ast.IfStmt) MetaStmt {
+	var mInit MetaStmt
+	var mElse MetaStmt
+	var condMeta MetaExpr
+	if s.Init != nil {
+		mInit = walkStmt(s.Init)
+	}
+	if s.Cond != nil {
+		condMeta = walkExpr(s.Cond, nil)
+	}
+	cond, isConst := constBool(condMeta)
+	if isConst {
+		// only the branch taken is walked
+		mt := &MetaBlockStmt{Pos: s.If}
+		if mInit != nil {
+			mt.List = append(mt.List, mInit)
+		}
+		if cond {
+			mt.List = append(mt.List, walkBlockStmt(s.Body))
+		} else if s.Else != nil {
+			mt.List = append(mt.List, walkStmt(s.Else))
+		}
+		return mt
+	}
+	mtBlock := walkBlockStmt(s.Body)
+	if s.Else != nil {
+		mElse = walkStmt(s.Else)
+	}
+	return &MetaIfStmt{
+		Init: mInit,
+		Cond: condMeta,
+		Body: mtBlock,
+		Else: mElse,
+	}
+}
+
+func walkBlockStmt(s *ast.BlockStmt) *MetaBlockStmt {
+	return &MetaBlockStmt{
+		Pos:  s.Lbrace,
+		List: walkStmtList(s.List),
+	}
+}
+
+func walkForStmt(s *ast.ForStmt) *MetaForContainer {
+	meta := &MetaForContainer{
+		Outer:   currentFor,
+		ForStmt: &MetaForForStmt{},
+	}
+	currentFor = meta
+
+	if s.Init != nil {
+		meta.ForStmt.Init = walkStmt(s.Init)
+	}
+	if s.Cond != nil {
+		meta.ForStmt.Cond = walkExpr(s.Cond, nil)
+	}
+	if s.Post != nil {
+		meta.ForStmt.Post = walkStmt(s.Post)
+	}
+	loopDepth++
+	outerLoopBody := currentLoopBody
+	currentLoopBody = meta
+	meta.Body = walkBlockStmt(s.Body)
+	currentLoopBody = outerLoopBody
+	loopDepth--
+	currentFor = meta.Outer
+	return meta
+}
+func walkRangeStmt(s *ast.RangeStmt) *MetaForContainer {
+	meta := &MetaForContainer{
+		Outer: currentFor,
+	}
+	currentFor = meta
+	metaX := walkExpr(s.X, nil)
+
+	collectionType := getUnderlyingType(getTypeOfExpr(metaX))
+	keyType := getKeyTypeOfCollectionType(collectionType)
+	elmType := getElementTypeOfCollectionType(collectionType)
+	walkExpr(tInt.E, nil)
+	switch kind(collectionType) {
+	case T_SLICE, T_ARRAY:
+		meta.ForRangeStmt = &MetaForRangeStmt{
+			IsMap:    false,
+			LenVar:   registerLocalVariable(currentFunc, ".range.len", tInt),
+			Indexvar: registerLocalVariable(currentFunc, ".range.index", tInt),
+			X:        metaX,
+		}
+	case T_MAP:
+		meta.ForRangeStmt = &MetaForRangeStmt{
+			IsMap:   true,
+			MapVar:  registerLocalVariable(currentFunc, ".range.map", tUintptr),
+			ItemVar: registerLocalVariable(currentFunc, ".range.item", tUintptr),
+			X:       metaX,
+		}
+	default:
+		throw(collectionType)
+	}
+	if s.Tok.String() == ":=" {
+		// declare local variables
+		keyIdent := s.Key.(*ast.Ident)
+		setVariable(keyIdent.Obj, registerLocalVariable(currentFunc, keyIdent.Name, keyType))
+
+		valueIdent := s.Value.(*ast.Ident)
+		setVariable(valueIdent.Obj, registerLocalVariable(currentFunc, valueIdent.Name, elmType))
+	}
+	if s.Key != nil {
+		meta.ForRangeStmt.Key = walkExpr(s.Key, nil)
+	}
+	if s.Value != nil {
+		meta.ForRangeStmt.Value = walkExpr(s.Value, nil)
+	}
+
+	loopDepth++
+	outerLoopBody := currentLoopBody
+	currentLoopBody = meta
+	mtBlock := walkBlockStmt(s.Body)
+	currentLoopBody = outerLoopBody
+	loopDepth--
+	meta.Body = mtBlock
+	currentFor = meta.Outer
+	return meta
+}
+
+func walkIncDecStmt(s *ast.IncDecStmt) *MetaSingleAssign {
+	var binop token.Token
+	switch s.Tok.String() {
+	case "++":
+		binop = token.ADD
+	case "--":
+		binop = token.SUB
+	default:
+		panic("Unexpected Tok=" + s.Tok.String())
+	}
+	exprOne := &ast.BasicLit{Kind: token.INT, Value: "1"}
+	newRhs := &ast.BinaryExpr{
+		X:  s.X,
+		Y:  exprOne,
+		Op: binop,
+	}
+	rhsMeta := walkExpr(newRhs, nil)
+	lhsMeta := walkExpr(s.X, nil)
+	return &MetaSingleAssign{
+		Lhs: lhsMeta,
+		Rhs: rhsMeta,
+	}
+}
+
+func walkSwitchStmt(s *ast.SwitchStmt) *MetaSwitchStmt {
+	meta := &MetaSwitchStmt{}
+	if s.Init != nil {
+		meta.Init = walkStmt(s.Init)
+	}
+	if s.Tag != nil {
+		meta.Tag = walkExpr(s.Tag, nil)
+	}
+	var cases []*MetaCaseClause
+	for _, _case := range s.Body.List {
+		cc := _case.(*ast.CaseClause)
+		_cc := walkCaseClause(cc)
+		cases = append(cases, _cc)
+	}
+	meta.cases = cases
+
+	return meta
+}
+
+func walkTypeSwitchStmt(e *ast.TypeSwitchStmt) *MetaTypeSwitchStmt {
+	typeSwitch := &MetaTypeSwitchStmt{}
+	var assignIdent *ast.Ident
+
+	switch assign := e.Assign.(type) {
+	case *ast.ExprStmt:
+		typeAssertExpr := assign.X.(*ast.TypeAssertExpr)
+		typeSwitch.Subject = walkExpr(typeAssertExpr.X, nil)
+	case *ast.AssignStmt:
+		lhs := assign.Lhs[0]
+		assignIdent = lhs.(*ast.Ident)
+		typeSwitch.assignObj = assignIdent.Obj
+		// ident will be a new local variable in each case clause
+		typeAssertExpr := assign.Rhs[0].(*ast.TypeAssertExpr)
+		typeSwitch.Subject = walkExpr(typeAssertExpr.X, nil)
+	default:
+		throw(e.Assign)
+	}
+
+	typeSwitch.SubjectVariable = registerLocalVariable(currentFunc, ".switch_expr", tEface)
+
+	var cases []*MetaTypeSwitchCaseClose
+	for _, _case := range e.Body.List {
+		cc := _case.(*ast.CaseClause)
+		tscc := &MetaTypeSwitchCaseClose{}
+		cases = append(cases, tscc)
+
+		if assignIdent != nil {
+			if len(cc.List) > 0 {
+				var varType *Type
+				if isNilIdent(cc.List[0]) {
+					varType = getTypeOfExpr(typeSwitch.Subject)
+				} else {
+					varType = e2t(cc.List[0])
+				}
+				// inject a variable of that type
+				vr := registerLocalVariable(currentFunc, assignIdent.Name, varType)
+				tscc.Variable = vr
+				setVariable(assignIdent.Obj, vr)
+			} else {
+				// default clause
+				// inject a variable of subject type
+				varType := getTypeOfExpr(typeSwitch.Subject)
+				vr := registerLocalVariable(currentFunc, assignIdent.Name, varType)
+				tscc.Variable = vr
+				setVariable(assignIdent.Obj, vr)
+			}
+		}
+		tscc.Body = walkStmtList(cc.Body)
+		var types []*Type
+		for _, e := range cc.List {
+			var typ *Type
+			if !isNilIdent(e) {
+				typ = e2t(e)
+			}
+			types = append(types, typ) // universe nil can be appended
+		}
+		tscc.types = types
+		if assignIdent != nil {
+			setVariable(assignIdent.Obj, nil)
+		}
+	}
+	typeSwitch.Cases = cases
+
+	return typeSwitch
+}
+func isNilIdent(e ast.Expr) bool {
+	ident, ok := e.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	return ident.Obj == gNil
+}
+
+func walkCaseClause(s *ast.CaseClause) *MetaCaseClause {
+	var listMeta []MetaExpr
+	for _, e := range s.List {
+		m := walkExpr(e, nil)
+		listMeta = append(listMeta, m)
+	}
+	return &MetaCaseClause{
+		ListMeta: listMeta,
+		Body:     walkStmtList(s.Body),
+	}
+}
+
+func walkBranchStmt(s *ast.BranchStmt) *MetaBranchStmt {
+	assert(currentFor != nil, "break or continue should be in for body", __func__)
+	var continueOrBreak int
+	switch s.Tok.String() {
+	case "continue":
+		continueOrBreak = 1
+	case "break":
+		continueOrBreak = 2
+	default:
+		panic("Unexpected token")
+	}
+
+	return &MetaBranchStmt{
+		containerForStmt: currentFor,
+		ContinueOrBreak:  continueOrBreak,
+	}
+}
+
+func walkGoStmt(s *ast.GoStmt) *MetaGoStmt {
+	fun := walkExpr(s.Call.Fun, nil)
+	return &MetaGoStmt{
+		fun: fun,
+	}
+}
+
+type MetaStmt interface{}
+
+type MetaBlockStmt struct {
+	Pos  token.Pos
+	List []MetaStmt
+}
+
+type MetaExprStmt struct {
+	X MetaExpr
+}
+
+type MetaVarDecl struct {
+	Pos     token.Pos
+	Single  *MetaSingleAssign
+	LhsType *Type
+}
+
+type MetaSingleAssign struct {
+	Pos token.Pos
+	Lhs MetaExpr
+	Rhs MetaExpr // can be nil
+}
+
+type MetaTupleAssign struct {
+	Pos      token.Pos
+	isOK     bool // OK or funcall
+	Lhss     []MetaExpr
+	Rhs      MetaExpr
+	RhsTypes []*Type
+}
+
+type MetaReturnStmt struct {
+	Pos     token.Pos
+	Fnc     *Func
+	Results []MetaExpr
+}
+
+type MetaIfStmt struct {
+	Pos  token.Pos
+	Init MetaStmt
+	Cond MetaExpr
+	Body *MetaBlockStmt
+	Else MetaStmt
+}
+
+type MetaForContainer struct {
+	Pos       token.Pos
+	LabelPost string // for continue
+	LabelExit string // for break
+	Outer     *MetaForContainer
+	Body      *MetaBlockStmt
+
+	ForRangeStmt *MetaForRangeStmt
+	ForStmt      *MetaForForStmt
+}
+
+type MetaForForStmt struct {
+	Init MetaStmt
+	Cond MetaExpr
+	Post MetaStmt
+}
+
+type MetaForRangeStmt struct {
+	IsMap    bool
+	LenVar   *Variable
+	Indexvar *Variable
+	MapVar   *Variable // map
+	ItemVar  *Variable // map element
+	X        MetaExpr
+	Key      MetaExpr
+	Value    MetaExpr
+}
+
+type MetaBranchStmt struct {
+	Pos              token.Pos
+	containerForStmt *MetaForContainer
+	ContinueOrBreak  int // 1: continue, 2:break
+}
+
+type MetaSwitchStmt struct {
+	Pos   token.Pos
+	Init  MetaStmt
+	cases []*MetaCaseClause
+	Tag   MetaExpr
+}
+
+type MetaCaseClause struct {
+	ListMeta []MetaExpr
+	Body     []MetaStmt
+}
+
+type MetaTypeSwitchStmt struct {
+	Pos             token.Pos
+	Subject         MetaExpr
+	SubjectVariable *Variable
+	assignObj       *ast.Object
+	Cases           []*MetaTypeSwitchCaseClose
+	cases           []*MetaCaseClause
+}
+
+type MetaTypeSwitchCaseClose struct {
+	Variable *Variable
+	//VariableType *Type
+	types []*Type
+	Body  []MetaStmt
+}
+
+type MetaGoStmt struct {
+	Pos token.Pos
+	fun MetaExpr
+}
+
+func walkStmt(stmt ast.Stmt) MetaStmt {
+	var mt MetaStmt
+	switch s := stmt.(type) {
+	case *ast.BlockStmt:
+		mt = walkBlockStmt(s)
+	case *ast.ExprStmt:
+		mt = walkExprStmt(s)
+	case *ast.DeclStmt:
+		mt = walkDeclStmt(s)
+	case *ast.AssignStmt:
+		mt = walkAssignStmt(s)
+	case *ast.IncDecStmt:
+		mt = walkIncDecStmt(s)
+	case *ast.ReturnStmt:
+		mt = walkReturnStmt(s)
+	case *ast.IfStmt:
+		mt = walkIfStmt(s)
+	case *ast.ForStmt:
+		mt = walkForStmt(s)
+	case *ast.RangeStmt:
+		mt = walkRangeStmt(s)
+	case *ast.BranchStmt:
+		mt = walkBranchStmt(s)
+	case *ast.SwitchStmt:
+		mt = walkSwitchStmt(s)
+	case *ast.TypeSwitchStmt:
+		mt = walkTypeSwitchStmt(s)
+	case *ast.GoStmt:
+		mt = walkGoStmt(s)
+	default:
+		throw(stmt)
+	}
+
+	assert(mt != nil, "meta should not be nil", __func__)
+	setStmtPos(mt, stmt)
+	return mt
+}
+
+// setStmtPos records the source position of stmt in its meta node.
+// Blocks get theirs in walkBlockStmt, and an ExprStmt has no position
+// of its own; it is taken from its expression.
+func setStmtPos(mt MetaStmt, stmt ast.Stmt) {
+	var pos token.Pos
+	switch s := stmt.(type) {
+	case *ast.DeclStmt:
+		pos = s.Decl.(*ast.GenDecl).TokPos
+	case *ast.AssignStmt:
+		pos = s.TokPos
+	case *ast.IncDecStmt:
+		pos = s.TokPos
+	case *ast.ReturnStmt:
+		pos = s.Return
+	case *ast.IfStmt:
+		pos = s.If
+	case *ast.ForStmt:
+		pos = s.For
+	case *ast.RangeStmt:
+		pos = s.For
+	case *ast.BranchStmt:
+		pos = s.TokPos
+	case *ast.SwitchStmt:
+		pos = s.Switch
+	case *ast.TypeSwitchStmt:
+		pos = s.Switch
+	case *ast.GoStmt:
+		pos = s.Go
+	}
+
+	switch m := mt.(type) {
+	case *MetaVarDecl:
+		m.Pos = pos
+	case *MetaSingleAssign:
+		m.Pos = pos
+	case *MetaTupleAssign:
+		m.Pos = pos
+	case *MetaReturnStmt:
+		m.Pos = pos
+	case *MetaIfStmt:
+		m.Pos = pos
+	case *MetaForContainer:
+		m.Pos = pos
+	case *MetaBranchStmt:
+		m.Pos = pos
+	case *MetaSwitchStmt:
+		m.Pos = pos
+	case *MetaTypeSwitchStmt:
+		m.Pos = pos
+	case *MetaGoStmt:
+		m.Pos = pos
+	}
+}
+
+// stmtPos returns the source position recorded in the meta node of a statement.
+func stmtPos(mt MetaStmt) token.Pos {
+	switch m := mt.(type) {
+	case *MetaExprStmt:
+		return irExprPos(m.X)
+	case *MetaVarDecl:
+		return m.Pos
+	case *MetaSingleAssign:
+		return m.Pos
+	case *MetaTupleAssign:
+		return m.Pos
+	case *MetaReturnStmt:
+		return m.Pos
+	case *MetaIfStmt:
+		return m.Pos
+	case *MetaForContainer:
+		return m.Pos
+	case *MetaBranchStmt:
+		return m.Pos
+	case *MetaSwitchStmt:
+		return m.Pos
+	case *MetaTypeSwitchStmt:
+		return m.Pos
+	case *MetaGoStmt:
+		return m.Pos
+	}
+	return token.NoPos
+}
+
+func isUniverseNil(m *MetaIdent) bool {
+	return m.kind == "nil"
+}
+
+func walkIdent(e *ast.Ident, ctx *evalContext) *MetaIdent {
+	//	logf("(%s) [walkIdent] Pos=%d ident=\"%s\"\n", currentPkg.name, int(e.Pos()), e.Name)
+	meta := &MetaIdent{
+		e:    e,
+		Name: e.Name,
+	}
+	logfncname := "(toplevel)"
+	if currentFunc != nil {
+		logfncname = currentFunc.Name
+	}
+	//logf("walkIdent: pkg=%s func=%s, ident=%s\n", currentPkg.name, logfncname, e.Name)
+	_ = logfncname
+	// what to do ?
+	if e.Name == "_" {
+		// blank identifier
+		// e.Obj is nil in this case.
+		// @TODO do something
+		meta.kind = "blank"
+		meta.typ = nil
+		return meta
+	}
+	assert(e.Obj != nil, currentPkg.name+" ident.Obj should not be nil:"+e.Name, __func__)
+	switch e.Obj {
+	case gNil:
+		assert(ctx != nil, "ctx of nil is not passed", __func__)
+		assert(ctx._type != nil, "ctx._type of nil is not passed", __func__)
+		meta.typ = ctx._type
+		meta.kind = "nil"
+	case gTrue:
+		meta.kind = "true"
+		meta.typ = tBool
+	case gFalse:
+		meta.kind = "false"
+		meta.typ = tBool
+	default:
+		switch e.Obj.Kind {
+		case ast.Var:
+			meta.kind = "var"
+			if e.Obj.Data == nil {
+				panic("ident.Obj.Data should not be nil: name=" + meta.Name)
+			}
+			meta.variable = e.Obj.Data.(*Variable)
+			meta.typ = meta.variable.Typ
+		case ast.Con:
+			meta.kind = "con"
+			// TODO: attach type
+			valSpec := e.Obj.Decl.(*ast.ValueSpec)
+			folded := foldConstExpr(valSpec.Values[0])
+			if folded == nil {
+				panic("not a constant expression: " + meta.Name)
+			}
+			meta.typ = getTypeOfExprAst(e)
+			meta.conLiteral = walkConstExpr(folded, meta.typ)
+		case ast.Fun:
+			meta.kind = "fun"
+			switch e.Obj {
+			case gLen, gCap, gNew, gMake, gAppend, gPanic, gDelete:
+				// builtin funcs have no func type
+			default:
+				//logf("ast.Fun=%s\n", e.Name)
+				meta.typ = e2t(e.Obj.Decl.(*ast.FuncDecl).Type)
+				recordFuncRef(e.Name)
+			}
+		case ast.Typ:
+			// this can happen when walking type nodes intentionally
+			meta.kind = "typ"
+			meta.typ = e2t(e)
+		default: // ast.Pkg
+			panic("Unexpected ident kind:" + e.Obj.Kind.String() + " name:" + e.Name)
+		}
+
+	}
+	return meta
+}
+
+func walkSelectorExpr(e *ast.SelectorExpr, ctx *evalContext) *MetaSelectorExpr {
+	meta := &MetaSelectorExpr{e: e}
+	if isQI(e) {
+		// pkg.ident
+		qi := selector2QI(e)
+		ident := lookupForeignIdent(qi)
+		if ident.Obj.Kind == ast.Fun {
+			// what to do ?
+		} else {
+			// walk it here, so that the string literal of a constant is registered before generating code
+			meta.foreign = walkExpr(ident, ctx)
+		}
+	} else {
+		// expr.field
+		meta.X = walkExpr(e.X, ctx)
+		meta.typ = lookupFieldType(getTypeOfExpr(meta.X), e.Sel.Name)
+	}
+	//logf("%s: walkSelectorExpr %s\n", fset.Position(e.Sel.Pos()), e.Sel.Name)
+	//meta.typ = getTypeOfExprAst(e)
+	return meta
+}
+
+func walkCallExpr(e *ast.CallExpr, ctx *evalContext) *MetaCallExpr {
+	meta := &MetaCallExpr{
+		e: e,
+	}
+	if isType(e.Fun) {
+		meta.isConversion = true
+		meta.toType = e2t(e.Fun)
+		meta.typ = meta.toType
+		assert(len(e.Args) == 1, "convert must take only 1 argument", __func__)
+		//logf("walkCallExpr: is Conversion\n")
+		ctx := &evalContext{
+			_type: e2t(e.Fun),
+		}
+		meta.arg0 = walkExpr(e.Args[0], ctx)
+		return meta
+	}
+
+	meta.isConversion = false
+	meta.fun = e.Fun
+	meta.hasEllipsis = e.Ellipsis != token.NoPos
+	meta.args = e.Args
+
+	// function call
+	metaFun := walkExpr(e.Fun, nil)
+
+	// Replace __func__ ident by a string literal
+	//for i, arg := range meta.args {
+	//	ident, ok := arg.(*ast.Ident)
+	//	if ok {
+	//		if ident.Name == "__func__" && ident.Obj.Kind == ast.Var {
+	//			basicLit := &ast.BasicLit{
+	//				Kind:  token.STRING,
+	//				Value: "\"" + currentFunc.Name + "\"",
+	//			}
+	//			arg = basicLit
+	//			e.Args[i] = arg
+	//		}
+	//	}
+	//
+	//}
+
+	identFun, isIdent := meta.fun.(*ast.Ident)
+	if isIdent {
+		//logf("  fun=%s\n", identFun.Name)
+		switch identFun.Obj {
+		case gLen, gCap:
+			meta.builtin = identFun.Obj
+			meta.arg0 = walkExpr(meta.args[0], nil)
+			meta.typ = tInt
+			return meta
+		case gNew:
+			meta.builtin = identFun.Obj
+			walkExpr(meta.args[0], nil)
+			meta.typeArg0 = e2t(meta.args[0])
+			ptrType := &ast.StarExpr{X: meta.args[0]}
+			meta.typ = e2t(ptrType)
+			return meta
+		case gMake:
+			meta.builtin = identFun.Obj
+			walkExpr(meta.args[0], nil)
+			meta.typeArg0 = e2t(meta.args[0])
+			meta.typ = meta.typeArg0
+			ctx := &evalContext{_type: tInt}
+			if len(meta.args) > 1 {
+				meta.arg1 = walkExpr(meta.args[1], ctx)
+			}
+
+			if len(meta.args) > 2 {
+				meta.arg2 = walkExpr(meta.args[2], ctx)
+			}
+			return meta
+		case gAppend:
+			meta.builtin = identFun.Obj
+			meta.arg0 = walkExpr(meta.args[0], nil)
+			meta.arg1 = walkExpr(meta.args[1], nil)
+			meta.typ = getTypeOfExpr(meta.arg0)
+			return meta
+		case gPanic:
+			meta.builtin = identFun.Obj
+			meta.arg0 = walkExpr(meta.args[0], nil)
+			meta.typ = nil
+			return meta
+		case gDelete:
+			meta.builtin = identFun.Obj
+			meta.arg0 = walkExpr(meta.args[0], nil)
+			meta.arg1 = walkExpr(meta.args[1], nil)
+			meta.typ = nil
+			return meta
+		}
+	}
+
+	var funcType *ast.FuncType
+	var funcVal *FuncValue
+	var receiver ast.Expr
+	var receiverMeta MetaExpr
+	switch fn := meta.fun.(type) {
+	case *ast.Ident:
+		// general function call
+		symbol := getPackageSymbol(currentPkg.name, fn.Name)
+		switch currentPkg.name {
+		case "os":
+			if fn.Name == "runtime_args" {
+				symbol = getPackageSymbol("runtime", "runtime_args")
+			}
+		case "syscall":
+			if fn.Name == "runtime_envs" {
+				symbol = getPackageSymbol("runtime", fn.Name)
+			}
+		case "errors":
+			if fn.Name == "errors_as" {
+				symbol = getPackageSymbol("runtime", fn.Name)
+			}
+		case "signal":
+			switch fn.Name {
+			case "signal_enable", "signal_disable", "signal_ignore":
+				symbol = getPackageSymbol("runtime", fn.Name)
+			}
+		case "exithook":
+			switch fn.Name {
+			case "exithook_add", "exithook_run":
+				symbol = getPackageSymbol("runtime", fn.Name)
+			}
+		case "time":
+			if fn.Name == "time_timerEnable" {
+				symbol = getPackageSymbol("runtime", fn.Name)
+			}
+		case "runtime":
+			if fn.Name == "makeSlice1" || fn.Name == "makeSlice8" || fn.Name == "makeSlice16" || fn.Name == "makeSlice24" {
+				fn.Name = "makeSlice"
+				symbol = getPackageSymbol("runtime", fn.Name)
+			}
+		}
+		funcVal = NewFuncValueFromSymbol(symbol)
+		switch dcl := fn.Obj.Decl.(type) {
+		case *ast.FuncDecl:
+			funcType = dcl.Type
+		case *ast.ValueSpec: // var f func() or var f F with a named func type F
+			funcType = getUnderlyingType(e2t(dcl.Type)).E.(*ast.FuncType)
+			funcVal = &FuncValue{
+				expr: metaFun,
+			}
+		case *ast.AssignStmt: // f := staticF
+			assert(fn.Obj.Data != nil, "funcvalue should be a variable:"+fn.Name, __func__)
+			rhs := dcl.Rhs[0]
+			switch r := rhs.(type) {
+			case *ast.SelectorExpr:
+				assert(isQI(r), "expect QI", __func__)
+				qi := selector2QI(r)
+				ff := lookupForeignFunc(qi)
+				funcType = ff.funcType
+				funcVal = NewFuncValueFromSymbol(string(qi))
+			default:
+				throw(r)
+			}
+		default:
+			throw(dcl)
+		}
+	case *ast.SelectorExpr:
+		if isQI(fn) {
+			// pkg.Sel()
+			qi := selector2QI(fn)
+			funcVal = NewFuncValueFromSymbol(string(qi))
+			ff := lookupForeignFunc(qi)
+			funcType = ff.funcType
+		} else {
+			// method call
+			receiver = fn.X
+			receiverMeta = walkExpr(receiver, nil)
+			receiverType := getTypeOfExpr(receiverMeta)
+			if isInterface(receiverType) {
+				// x.m() through an interface
+				funcType = lookupInterfaceMethod(receiverType, fn.Sel.Name)
+				assert(funcType != nil, "interface has no method "+fn.Sel.Name, __func__)
+				funcVal, receiverMeta = walkInterfaceMethod(receiverMeta, fn.Sel.Name)
+			} else {
+				method := lookupMethod(receiverType, fn.Sel)
+				funcType = method.FuncType
+				funcVal = NewFuncValueFromSymbol(getMethodSymbol(method))
+
+				if kind(receiverType) == T_POINTER {
+					if method.IsPtrMethod {
+						// p.mp() => as it is
+					} else {
+						// p.mv()
+						panic("TBI")
+					}
+				} else {
+					if method.IsPtrMethod {
+						// v.mp() => (&v).mp()
+						// @TODO we should check addressable
+						rcvr := &ast.UnaryExpr{
+							Op: token.AND,
+							X:  receiver,
+						}
+						eTyp := &ast.StarExpr{X: receiverType.E}
+						receiverMeta = &MetaUnaryExpr{
+							e:   rcvr,
+							X:   receiverMeta,
+							typ: e2t(eTyp),
+						}
+					} else {
+						// v.mv() => as it is
+					}
+				}
+			}
+		}
+	default:
+		throw(meta.fun)
+	}
+
+	meta.funcType = funcType
+	if funcType.Results != nil && len(funcType.Results.List) > 0 {
+		meta.types = fieldList2Types(funcType.Results)
+		meta.typ = meta.types[0]
+	}
+	funcVal.pos = e.Lparen
+	meta.funcVal = funcVal
+	meta.metaArgs = prepareArgs(meta.funcType, receiverMeta, meta.args, meta.hasEllipsis)
+	return meta
 }
 
-func walkBlockStmt(s *ast.BlockStmt) *MetaBlockStmt {
-	mt := &MetaBlockStmt{}
-	for _, stmt := range s.List {
-		meta := walkStmt(stmt)
-		mt.List = append(mt.List, meta)
+// walkInterfaceMethod returns the func value of a call of the method name through the interface value x,
+// and the receiver to pass, which is the data word of x.
+// x is evaluated once into a variable, whose dynamic type gives the function to call.
+func walkInterfaceMethod(x MetaExpr, name string) (*FuncValue, MetaExpr) {
+	assert(currentFunc != nil, "interface method call outside of a function", __func__)
+	ifcVar := registerLocalVariable(currentFunc, ".ifc", tEface)
+	dataVar := newLocalVariable(".ifc.data", ifcVar.LocalOffset+8, tUintptr)
+	data := &MetaIdent{
+		e:        &ast.Ident{Name: dataVar.Name},
+		typ:      tUintptr,
+		kind:     "var",
+		Name:     dataVar.Name,
+		variable: dataVar,
 	}
-	return mt
+	funcVal := &FuncValue{
+		expr:   x,
+		method: name,
+		ifcVar: ifcVar,
+	}
+	return funcVal, data
 }
 
-func walkForStmt(s *ast.ForStmt) *MetaForContainer {
-	meta := &MetaForContainer{
-		Outer:   currentFor,
-		ForStmt: &MetaForForStmt{},
+func walkBasicLit(e *ast.BasicLit, ctx *evalContext) *MetaBasicLit {
+	m := &MetaBasicLit{
+		Kind:  e.Kind.String(),
+		Value: e.Value,
 	}
-	currentFor = meta
 
-	if s.Init != nil {
-		meta.ForStmt.Init = walkStmt(s.Init)
-	}
-	if s.Cond != nil {
-		meta.ForStmt.Cond = walkExpr(s.Cond, nil)
-	}
-	if s.Post != nil {
-		meta.ForStmt.Post = walkStmt(s.Post)
+	switch e.Kind.String() {
+	case "CHAR":
+		m.charVal = charLitValue(e.Value)
+	case "INT":
+		m.intVal = strconv.Atoi(m.Value)
+	case "STRING":
+		m.strVal = registerStringLiteral(e)
+	default:
+		panic("Unexpected literal kind:" + e.Kind.String())
 	}
-	meta.Body = walkBlockStmt(s.Body)
-	currentFor = meta.Outer
-	return meta
+	m.typ = getTypeOfExprAst(e)
+	return m
 }
-func walkRangeStmt(s *ast.RangeStmt) *MetaForContainer {
-	meta := &MetaForContainer{
-		Outer: currentFor,
-	}
-	currentFor = meta
-	metaX := walkExpr(s.X, nil)
 
-	collectionType := getUnderlyingType(getTypeOfExpr(metaX))
-	keyType := getKeyTypeOfCollectionType(collectionType)
-	elmType := getElementTypeOfCollectionType(collectionType)
-	walkExpr(tInt.E, nil)
-	switch kind(collectionType) {
-	case T_SLICE, T_ARRAY:
-		meta.ForRangeStmt = &MetaForRangeStmt{
-			IsMap:    false,
-			LenVar:   registerLocalVariable(currentFunc, ".range.len", tInt),
-			Indexvar: registerLocalVariable(currentFunc, ".range.index", tInt),
-			X:        metaX,
-		}
-	case T_MAP:
-		meta.ForRangeStmt = &MetaForRangeStmt{
-			IsMap:   true,
-			MapVar:  registerLocalVariable(currentFunc, ".range.map", tUintptr),
-			ItemVar: registerLocalVariable(currentFunc, ".range.item", tUintptr),
-			X:       metaX,
+// charLitValue returns the value of a rune literal.
+func charLitValue(val string) int {
+	var char = val[1]
+	if val[1] == '\\' {
+		switch val[2] {
+		case '\'':
+			char = '\''
+		case 'n':
+			char = '\n'
+		case '\\':
+			char = '\\'
+		case 't':
+			char = '\t'
+		case 'r':
+			char = '\r'
 		}
-	default:
-		throw(collectionType)
 	}
-	if s.Tok.String() == ":=" {
-		// declare local variables
-		keyIdent := s.Key.(*ast.Ident)
-		setVariable(keyIdent.Obj, registerLocalVariable(currentFunc, keyIdent.Name, keyType))
+	return int(char)
+}
 
-		valueIdent := s.Value.(*ast.Ident)
-		setVariable(valueIdent.Obj, registerLocalVariable(currentFunc, valueIdent.Name, elmType))
-	}
-	if s.Key != nil {
-		meta.ForRangeStmt.Key = walkExpr(s.Key, nil)
+func walkCompositeLit(e *ast.CompositeLit, ctx *evalContext) *MetaCompositLit {
+	walkExpr(e.Type, nil) // a[len("foo")]{...} // "foo" should be walked
+	typ := e2t(e.Type)
+	ut := getUnderlyingType(typ)
+	var knd string
+	switch kind(ut) {
+	case T_STRUCT:
+		knd = "struct"
+	case T_ARRAY:
+		knd = "array"
+	case T_SLICE:
+		knd = "slice"
+	default:
+		unexpectedKind(kind(typ))
 	}
-	if s.Value != nil {
-		meta.ForRangeStmt.Value = walkExpr(s.Value, nil)
+	meta := &MetaCompositLit{
+		e:    e,
+		kind: knd,
+		typ:  typ,
 	}
 
-	mtBlock := walkBlockStmt(s.Body)
-	meta.Body = mtBlock
-	currentFor = meta.Outer
+	switch kind(ut) {
+	case T_STRUCT:
+		structType := meta.typ
+		var metaElms []*MetaStructLiteralElement
+		for _, elm := range e.Elts {
+			kvExpr := elm.(*ast.KeyValueExpr)
+			fieldName := kvExpr.Key.(*ast.Ident)
+			field := lookupStructField(getUnderlyingStructType(structType), fieldName.Name)
+			fieldType := e2t(field.Type)
+			ctx := &evalContext{_type: fieldType}
+			// attach type to nil : STRUCT{Key:nil}
+			valueMeta := walkExpr(kvExpr.Value, ctx)
+
+			metaElm := &MetaStructLiteralElement{
+				field:     field,
+				fieldType: fieldType,
+				ValueMeta: valueMeta,
+			}
+
+			metaElms = append(metaElms, metaElm)
+		}
+		meta.strctEements = metaElms
+	case T_ARRAY:
+		meta.arrayType = ut.E.(*ast.ArrayType)
+		meta.len = evalInt(meta.arrayType.Len)
+		meta.elmType = e2t(meta.arrayType.Elt)
+		ctx := &evalContext{_type: meta.elmType}
+		var ms []MetaExpr
+		for _, v := range e.Elts {
+			m := walkExpr(v, ctx)
+			ms = append(ms, m)
+		}
+		meta.metaElms = ms
+	case T_SLICE:
+		meta.arrayType = ut.E.(*ast.ArrayType)
+		meta.len = len(e.Elts)
+		meta.elmType = e2t(meta.arrayType.Elt)
+		ctx := &evalContext{_type: meta.elmType}
+		var ms []MetaExpr
+		for _, v := range e.Elts {
+			m := walkExpr(v, ctx)
+			ms = append(ms, m)
+		}
+		meta.metaElms = ms
+	}
 	return meta
 }
 
-func walkIncDecStmt(s *ast.IncDecStmt) *MetaSingleAssign {
-	var binop token.Token
-	switch s.Tok.String() {
-	case "++":
-		binop = token.ADD
-	case "--":
-		binop = token.SUB
-	default:
-		panic("Unexpected Tok=" + s.Tok.String())
-	}
-	exprOne := &ast.BasicLit{Kind: token.INT, Value: "1"}
-	newRhs := &ast.BinaryExpr{
-		X:  s.X,
-		Y:  exprOne,
-		Op: binop,
-	}
-	rhsMeta := walkExpr(newRhs, nil)
-	lhsMeta := walkExpr(s.X, nil)
-	return &MetaSingleAssign{
-		Lhs: lhsMeta,
-		Rhs: rhsMeta,
+func walkUnaryExpr(e *ast.UnaryExpr, ctx *evalContext) MetaExpr {
+	folded := foldConstExpr(e)
+	if folded != nil {
+		return walkConstExpr(folded, getTypeOfExprAst(e))
 	}
+	meta := &MetaUnaryExpr{e: e}
+	meta.X = walkExpr(e.X, nil)
+	meta.typ = getTypeOfExprAst(e)
+	return meta
 }
 
-func walkSwitchStmt(s *ast.SwitchStmt) *MetaSwitchStmt {
-	meta := &MetaSwitchStmt{}
-	if s.Init != nil {
-		meta.Init = walkStmt(s.Init)
+func walkBinaryExpr(e *ast.BinaryExpr, ctx *evalContext) MetaExpr {
+	folded := foldConstExpr(e)
+	if folded != nil {
+		return walkConstExpr(folded, getTypeOfExprAst(e))
 	}
-	if s.Tag != nil {
-		meta.Tag = walkExpr(s.Tag, nil)
-	}
-	var cases []*MetaCaseClause
-	for _, _case := range s.Body.List {
-		cc := _case.(*ast.CaseClause)
-		_cc := walkCaseClause(cc)
-		cases = append(cases, _cc)
+	meta := &MetaBinaryExpr{
+		e:  e,
+		Op: e.Op.String(),
 	}
-	meta.cases = cases
+	if isNilIdent(e.X) {
+		// Y should be typed
+		meta.Y = walkExpr(e.Y, nil) // right
+		xCtx := &evalContext{_type: getTypeOfExpr(meta.Y)}
 
+		meta.X = walkExpr(e.X, xCtx) // left
+	} else {
+		// X should be typed
+		meta.X = walkExpr(e.X, nil) // left
+		yCtx := &evalContext{_type: getTypeOfExpr(meta.X)}
+		meta.Y = walkExpr(e.Y, yCtx) // right
+	}
+	meta.typ = getTypeOfExprAst(e)
 	return meta
 }
 
-func walkTypeSwitchStmt(e *ast.TypeSwitchStmt) *MetaTypeSwitchStmt {
-	typeSwitch := &MetaTypeSwitchStmt{}
-	var assignIdent *ast.Ident
-
-	switch assign := e.Assign.(type) {
-	case *ast.ExprStmt:
-		typeAssertExpr := assign.X.(*ast.TypeAssertExpr)
-		typeSwitch.Subject = walkExpr(typeAssertExpr.X, nil)
-	case *ast.AssignStmt:
-		lhs := assign.Lhs[0]
-		assignIdent = lhs.(*ast.Ident)
-		typeSwitch.assignObj = assignIdent.Obj
-		// ident will be a new local variable in each case clause
-		typeAssertExpr := assign.Rhs[0].(*ast.TypeAssertExpr)
-		typeSwitch.Subject = walkExpr(typeAssertExpr.X, nil)
-	default:
-		throw(e.Assign)
-	}
-
-	typeSwitch.SubjectVariable = registerLocalVariable(currentFunc, ".switch_expr", tEface)
-
-	var cases []*MetaTypeSwitchCaseClose
-	for _, _case := range e.Body.List {
-		cc := _case.(*ast.CaseClause)
-		tscc := &MetaTypeSwitchCaseClose{}
-		cases = append(cases, tscc)
-
-		if assignIdent != nil {
-			if len(cc.List) > 0 {
-				var varType *Type
-				if isNilIdent(cc.List[0]) {
-					varType = getTypeOfExpr(typeSwitch.Subject)
-				} else {
-					varType = e2t(cc.List[0])
-				}
-				// inject a variable of that type
-				vr := registerLocalVariable(currentFunc, assignIdent.Name, varType)
-				tscc.Variable = vr
-				setVariable(assignIdent.Obj, vr)
-			} else {
-				// default clause
-				// inject a variable of subject type
-				varType := getTypeOfExpr(typeSwitch.Subject)
-				vr := registerLocalVariable(currentFunc, assignIdent.Name, varType)
-				tscc.Variable = vr
-				setVariable(assignIdent.Obj, vr)
-			}
-		}
-		var body []MetaStmt
-		for _, stmt := range cc.Body {
-			m := walkStmt(stmt)
-			body = append(body, m)
-		}
-		tscc.Body = body
-		var types []*Type
-		for _, e := range cc.List {
-			var typ *Type
-			if !isNilIdent(e) {
-				typ = e2t(e)
-			}
-			types = append(types, typ) // universe nil can be appended
-		}
-		tscc.types = types
-		if assignIdent != nil {
-			setVariable(assignIdent.Obj, nil)
-		}
+func walkIndexExpr(e *ast.IndexExpr, ctx *evalContext) *MetaIndexExpr {
+	meta := &MetaIndexExpr{
+		e: e,
 	}
-	typeSwitch.Cases = cases
-
-	return typeSwitch
-}
-func isNilIdent(e ast.Expr) bool {
-	ident, ok := e.(*ast.Ident)
-	if !ok {
-		return false
+	meta.Index = walkExpr(e.Index, nil) // @TODO pass context for map,slice,array
+	meta.X = walkExpr(e.X, nil)
+	if kind(getTypeOfExpr(meta.X)) == T_MAP {
+		meta.IsMap = true
+		if ctx != nil && ctx.maybeOK {
+			meta.NeedsOK = true
+		}
 	}
-	return ident.Obj == gNil
+	meta.typ = getTypeOfExprAst(e)
+	return meta
 }
 
-func walkCaseClause(s *ast.CaseClause) *MetaCaseClause {
-	var listMeta []MetaExpr
-	for _, e := range s.List {
-		m := walkExpr(e, nil)
-		listMeta = append(listMeta, m)
+func walkSliceExpr(e *ast.SliceExpr, ctx *evalContext) *MetaSliceExpr {
+	meta := &MetaSliceExpr{e: e}
+
+	// For convenience, any of the indices may be omitted.
+
+	// A missing low index defaults to zero;
+	if e.Low != nil {
+		meta.Low = walkExpr(e.Low, nil)
+	} else {
+		eZeroInt := &ast.BasicLit{
+			Value: "0",
+			Kind:  token.INT,
+		}
+		meta.Low = walkExpr(eZeroInt, nil)
 	}
-	var body []MetaStmt
-	for _, stmt := range s.Body {
-		metaStmt := walkStmt(stmt)
-		body = append(body, metaStmt)
+
+	if e.High != nil {
+		meta.High = walkExpr(e.High, nil)
 	}
-	return &MetaCaseClause{
-		ListMeta: listMeta,
-		Body:     body,
+	if e.Max != nil {
+		meta.Max = walkExpr(e.Max, nil)
 	}
+	meta.X = walkExpr(e.X, nil)
+	meta.typ = getTypeOfExprAst(e)
+	return meta
 }
 
-func walkBranchStmt(s *ast.BranchStmt) *MetaBranchStmt {
-	assert(currentFor != nil, "break or continue should be in for body", __func__)
-	var continueOrBreak int
-	switch s.Tok.String() {
-	case "continue":
-		continueOrBreak = 1
-	case "break":
-		continueOrBreak = 2
-	default:
-		panic("Unexpected token")
+// [N]T(e)
+func walkArrayType(e *ast.ArrayType) {
+	// BasicLit in N should be walked
+	// e.g. A[5], A[len("foo")]
+	if e.Len != nil {
+		walkExpr(e.Len, nil)
 	}
+}
+func walkMapType(e *ast.MapType) {
+	// first argument of builtin func
+	// do nothing
+}
+func walkStarExpr(e *ast.StarExpr, ctx *evalContext) *MetaStarExpr {
+	meta := &MetaStarExpr{e: e}
+	meta.X = walkExpr(e.X, nil)
+	meta.typ = getTypeOfExprAst(e)
+	return meta
+}
 
-	return &MetaBranchStmt{
-		containerForStmt: currentFor,
-		ContinueOrBreak:  continueOrBreak,
-	}
+func walkKeyValueExpr(e *ast.KeyValueExpr, ctx *evalContext) {
+	// MYSTRUCT{key:value}
+	// key is not an expression in struct literals.
+	// Actually struct case is handled in walkCompositeLit().
+	// In map, array or slice types, key can be an expression.
+	// // map
+	// expr := "hello"
+	// a := map[string]int{expr: 0}
+	// fmt.Println(a) // => map[hello:0]
+
+	//const key = 1
+	//s := []bool{key: true}
+	//fmt.Println(s) // => map[hello:0]
+
+	// const key = 1
+	// s := []bool{key: true} // => [false true]
+
+	//walkExpr(e.Key, nil)
+	panic("TBI")
 }
 
-func walkGoStmt(s *ast.GoStmt) *MetaGoStmt {
-	fun := walkExpr(s.Call.Fun, nil)
-	return &MetaGoStmt{
-		fun: fun,
+func walkInterfaceType(e *ast.InterfaceType) {
+	// interface{}(e)  conversion. Nothing to do.
+}
+
+func walkTypeAssertExpr(e *ast.TypeAssertExpr, ctx *evalContext) *MetaTypeAssertExpr {
+	meta := &MetaTypeAssertExpr{e: e}
+	if ctx != nil && ctx.maybeOK {
+		meta.NeedsOK = true
 	}
+	meta.X = walkExpr(e.X, nil)
+	meta.typ = getTypeOfExprAst(e)
+	return meta
 }
 
-type MetaStmt interface{}
+type MetaExpr interface{}
 
-type MetaBlockStmt struct {
-	List []MetaStmt
+type MetaBasicLit struct {
+	typ     *Type
+	Kind    string
+	Value   string
+	charVal int
+	intVal  int
+	strVal  *sliteral
 }
 
-type MetaExprStmt struct {
-	X MetaExpr
+type MetaCompositLit struct {
+	e    *ast.CompositeLit
+	typ  *Type  // type of the composite
+	kind string // "struct", "array", "slice" // @TODO "map"
+
+	// for struct
+	strctEements []*MetaStructLiteralElement // for "struct"
+
+	// for array or slice
+	arrayType *ast.ArrayType
+	len       int
+	elmType   *Type
+	metaElms  []MetaExpr
+
+	stackSlot *Variable // storage in the frame, nil if allocated on the heap
 }
 
-type MetaVarDecl struct {
-	Single  *MetaSingleAssign
-	LhsType *Type
+type MetaIdent struct {
+	e    *ast.Ident
+	typ  *Type
+	kind string // "blank|nil|true|false|var|con|fun|typ"
+	Name string
+
+	variable *Variable // for "var"
+
+	conLiteral MetaExpr // for "con": folded value
 }
 
-type MetaSingleAssign struct {
-	Lhs MetaExpr
-	Rhs MetaExpr // can be nil
+type MetaSelectorExpr struct {
+	e       *ast.SelectorExpr
+	typ     *Type
+	X       MetaExpr
+	foreign MetaExpr // the walked ident of pkg.Var or pkg.Const
 }
 
-type MetaTupleAssign struct {
-	isOK     bool // OK or funcall
-	Lhss     []MetaExpr
-	Rhs      MetaExpr
-	RhsTypes []*Type
+type MetaCallExpr struct {
+	e            *ast.CallExpr
+	typ          *Type // result type
+	isConversion bool
+
+	// For Conversion
+	toType *Type
+
+	arg0     MetaExpr // For conversion, len, cap
+	typeArg0 *Type
+	arg1     MetaExpr
+	arg2     MetaExpr
+
+	// For funcall
+	fun         ast.Expr
+	hasEllipsis bool
+	args        []ast.Expr
+
+	builtin *ast.Object
+
+	// general funcall
+	funcType *ast.FuncType
+	funcVal  *FuncValue
+	//receiver ast.Expr
+	metaArgs []*MetaArg
+	types    []*Type
+
+	stackSlot *Variable // for new: storage in the frame, nil if allocated on the heap
 }
 
-type MetaReturnStmt struct {
-	Fnc     *Func
-	Results []MetaExpr
+type MetaIndexExpr struct {
+	IsMap   bool // mp[k]
+	NeedsOK bool // when map, is it ok syntax ?
+	Index   MetaExpr
+	X       MetaExpr
+	e       *ast.IndexExpr
+	typ     *Type
 }
 
-type MetaIfStmt struct {
-	Init MetaStmt
-	Cond MetaExpr
-	Body *MetaBlockStmt
-	Else MetaStmt
+type MetaSliceExpr struct {
+	e    *ast.SliceExpr
+	typ  *Type
+	Low  MetaExpr
+	High MetaExpr
+	Max  MetaExpr
+	X    MetaExpr
+}
+type MetaStarExpr struct {
+	e   *ast.StarExpr
+	typ *Type
+	X   MetaExpr
+}
+type MetaUnaryExpr struct {
+	e   *ast.UnaryExpr
+	X   MetaExpr
+	typ *Type
+}
+type MetaBinaryExpr struct {
+	e   *ast.BinaryExpr
+	typ *Type
+	Op  string
+	X   MetaExpr
+	Y   MetaExpr
 }
 
-type MetaForContainer struct {
-	LabelPost string // for continue
-	LabelExit string // for break
-	Outer     *MetaForContainer
-	Body      *MetaBlockStmt
+type MetaTypeAssertExpr struct {
+	NeedsOK bool
+	X       MetaExpr
+	e       *ast.TypeAssertExpr
+	typ     *Type
+}
+
+// ctx type is the type of someone who receives the expr value.
+// There are various forms:
+//
+//	Assignment:       x = expr
+//	Function call:    x(expr)
+//	Return:           return expr
+//	CompositeLiteral: T{key:expr}
+//
+// targetType is used when:
+//   - the expr is nil
+//   - the target type is interface and expr is not.
+func walkExpr(expr ast.Expr, ctx *evalContext) MetaExpr {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		return walkBasicLit(e, ctx)
+	case *ast.CompositeLit:
+		return walkCompositeLit(e, ctx)
+	case *ast.Ident:
+		return walkIdent(e, ctx)
+	case *ast.SelectorExpr:
+		return walkSelectorExpr(e, ctx)
+	case *ast.CallExpr:
+		inlined := inlineCall(e, ctx)
+		if inlined != nil {
+			return inlined
+		}
+		return walkCallExpr(e, ctx)
+	case *ast.IndexExpr:
+		return walkIndexExpr(e, ctx)
+	case *ast.SliceExpr:
+		return walkSliceExpr(e, ctx)
+	case *ast.StarExpr:
+		return walkStarExpr(e, ctx)
+	case *ast.UnaryExpr:
+		return walkUnaryExpr(e, ctx)
+	case *ast.BinaryExpr:
+		return walkBinaryExpr(e, ctx)
+	case *ast.TypeAssertExpr:
+		return walkTypeAssertExpr(e, ctx)
 
-	ForRangeStmt *MetaForRangeStmt
-	ForStmt      *MetaForForStmt
-}
+	case *ast.ParenExpr:
+		return walkExpr(e.X, ctx)
+	case *ast.KeyValueExpr:
+		walkKeyValueExpr(e, ctx)
+		return nil
 
-type MetaForForStmt struct {
-	Init MetaStmt
-	Cond MetaExpr
-	Post MetaStmt
+	// Each one below is not an expr but a type
+	case *ast.ArrayType: // type
+		walkArrayType(e) // []T(e)
+		return nil
+	case *ast.MapType: // type
+		walkMapType(e)
+		return nil
+	case *ast.InterfaceType: // type
+		walkInterfaceType(e)
+		return nil
+	case *ast.FuncType:
+		return nil // @TODO walk
+	default:
+		panic(fmt.Sprintf("unknown type %T", expr))
+	}
 }
 
-type MetaForRangeStmt struct {
-	IsMap    bool
-	LenVar   *Variable
-	Indexvar *Variable
-	MapVar   *Variable // map
-	ItemVar  *Variable // map element
-	X        MetaExpr
-	Key      MetaExpr
-	Value    MetaExpr
-}
+var ExportedQualifiedIdents = make(map[string]*ast.Ident)
 
-type MetaBranchStmt struct {
-	containerForStmt *MetaForContainer
-	ContinueOrBreak  int // 1: continue, 2:break
+func lookupForeignIdent(qi QualifiedIdent) *ast.Ident {
+	ident, ok := ExportedQualifiedIdents[string(qi)]
+	if !ok {
+		panic(qi + " Not found in ExportedQualifiedIdents")
+	}
+	return ident
 }
 
-type MetaSwitchStmt struct {
-	Init  MetaStmt
-	cases []*MetaCaseClause
-	Tag   MetaExpr
+type ForeignFunc struct {
+	symbol   string
+	funcType *ast.FuncType
 }
 
-type MetaCaseClause struct {
-	ListMeta []MetaExpr
-	Body     []MetaStmt
+func lookupForeignFunc(qi QualifiedIdent) *ForeignFunc {
+	ident := lookupForeignIdent(qi)
+	assert(ident.Obj.Kind == ast.Fun, "should be Fun", __func__)
+	decl := ident.Obj.Decl.(*ast.FuncDecl)
+	return &ForeignFunc{
+		symbol:   string(qi),
+		funcType: decl.Type,
+	}
 }
 
-type MetaTypeSwitchStmt struct {
-	Subject         MetaExpr
-	SubjectVariable *Variable
-	assignObj       *ast.Object
-	Cases           []*MetaTypeSwitchCaseClose
-	cases           []*MetaCaseClause
+type Func struct {
+	Pos       token.Pos
+	Name      string
+	Stmts     []MetaStmt
+	Localarea int
+	Argsarea  int
+	LocalVars []*Variable
+	Params    []*Variable
+	Retvars   []*Variable
+	FuncType  *ast.FuncType
+	Method    *Method
+	Refs      []string // functions of the package referenced in the body
 }
-
-type MetaTypeSwitchCaseClose struct {
-	Variable *Variable
-	//VariableType *Type
-	types []*Type
-	Body  []MetaStmt
+type Method struct {
+	PkgName      string
+	RcvNamedType *ast.Ident
+	IsPtrMethod  bool
+	Name         string
+	FuncType     *ast.FuncType
 }
-
-type MetaGoStmt struct {
-	fun MetaExpr
+type Variable struct {
+	Name         string
+	IsGlobal     bool
+	GlobalSymbol string
+	LocalOffset  int
+	Typ          *Type
+	Vreg         int               // virtual register holding the variable in the register backend, 0 if none
+	AddrTaken    bool              // the variable has to live in memory
+	LoopDepth    int               // number of loop bodies enclosing the declaration
+	EscLevel     int               // lowest loop depth a pointer held by the variable flows to, -1 if it escapes
+	Loop         *MetaForContainer // innermost loop whose body declares the variable
+	HeapAddr     *Variable         // frame slot holding the address of the variable if it is moved to the heap
 }
 
-func walkStmt(stmt ast.Stmt) MetaStmt {
-	var mt MetaStmt
-	switch s := stmt.(type) {
-	case *ast.BlockStmt:
-		mt = walkBlockStmt(s)
-	case *ast.ExprStmt:
-		mt = walkExprStmt(s)
-	case *ast.DeclStmt:
-		mt = walkDeclStmt(s)
-	case *ast.AssignStmt:
-		mt = walkAssignStmt(s)
-	case *ast.IncDecStmt:
-		mt = walkIncDecStmt(s)
-	case *ast.ReturnStmt:
-		mt = walkReturnStmt(s)
-	case *ast.IfStmt:
-		mt = walkIfStmt(s)
-	case *ast.ForStmt:
-		mt = walkForStmt(s)
-	case *ast.RangeStmt:
-		mt = walkRangeStmt(s)
-	case *ast.BranchStmt:
-		mt = walkBranchStmt(s)
-	case *ast.SwitchStmt:
-		mt = walkSwitchStmt(s)
-	case *ast.TypeSwitchStmt:
-		mt = walkTypeSwitchStmt(s)
-	case *ast.GoStmt:
-		mt = walkGoStmt(s)
-	default:
-		throw(stmt)
+func setVariable(obj *ast.Object, vr *Variable) {
+	assert(obj.Kind == ast.Var, "obj is not  ast.Var", __func__)
+	if vr == nil {
+		obj.Data = nil
+	} else {
+		obj.Data = vr
 	}
-
-	assert(mt != nil, "meta should not be nil", __func__)
-	return mt
 }
 
-func isUniverseNil(m *MetaIdent) bool {
-	return m.kind == "nil"
-}
+// Purpose of walk:
+// - collect string literals
+// - collect method declarations
+// - collect global variables
+// - collect local variables and set offset
+// - determine struct size and field offset
+// - determine types of variable declarations
+// - attach type to universe nil
+// - transmit ok sytanx context
+// - (hope) attach type to untyped constants
+// - (hope) transmit the need of interface conversion
+func walk(pkg *PkgContainer) {
+	var typeSpecs []*ast.TypeSpec
+	var funcDecls []*ast.FuncDecl
+	var varSpecs []*ast.ValueSpec
+	var constSpecs []*ast.ValueSpec
 
-func walkIdent(e *ast.Ident, ctx *evalContext) *MetaIdent {
-	//	logf("(%s) [walkIdent] Pos=%d ident=\"%s\"\n", currentPkg.name, int(e.Pos()), e.Name)
-	meta := &MetaIdent{
-		e:    e,
-		Name: e.Name,
-	}
-	logfncname := "(toplevel)"
-	if currentFunc != nil {
-		logfncname = currentFunc.Name
-	}
-	//logf("walkIdent: pkg=%s func=%s, ident=%s\n", currentPkg.name, logfncname, e.Name)
-	_ = logfncname
-	// what to do ?
-	if e.Name == "_" {
-		// blank identifier
-		// e.Obj is nil in this case.
-		// @TODO do something
-		meta.kind = "blank"
-		meta.typ = nil
-		return meta
-	}
-	assert(e.Obj != nil, currentPkg.name+" ident.Obj should not be nil:"+e.Name, __func__)
-	switch e.Obj {
-	case gNil:
-		assert(ctx != nil, "ctx of nil is not passed", __func__)
-		assert(ctx._type != nil, "ctx._type of nil is not passed", __func__)
-		meta.typ = ctx._type
-		meta.kind = "nil"
-	case gTrue:
-		meta.kind = "true"
-		meta.typ = tBool
-	case gFalse:
-		meta.kind = "false"
-		meta.typ = tBool
-	default:
-		switch e.Obj.Kind {
-		case ast.Var:
-			meta.kind = "var"
-			if e.Obj.Data == nil {
-				panic("ident.Obj.Data should not be nil: name=" + meta.Name)
-			}
-			meta.variable = e.Obj.Data.(*Variable)
-			meta.typ = meta.variable.Typ
-		case ast.Con:
-			meta.kind = "con"
-			// TODO: attach type
-			valSpec := e.Obj.Decl.(*ast.ValueSpec)
-			lit := valSpec.Values[0].(*ast.BasicLit)
-			meta.conLiteral = walkBasicLit(lit, nil)
-			if valSpec.Type != nil {
-				meta.typ = e2t(valSpec.Type)
-			} else {
-				meta.typ = getTypeOfExpr(meta.conLiteral)
-			}
-		case ast.Fun:
-			meta.kind = "fun"
-			switch e.Obj {
-			case gLen, gCap, gNew, gMake, gAppend, gPanic, gDelete:
-				// builtin funcs have no func type
-			default:
-				//logf("ast.Fun=%s\n", e.Name)
-				meta.typ = e2t(e.Obj.Decl.(*ast.FuncDecl).Type)
+	var exportedTpyes []*Type
+	//logf("grouping declarations by type\n")
+	for _, decl := range pkg.Decls {
+		switch dcl := decl.(type) {
+		case *ast.GenDecl:
+			specInterface := dcl.Specs[0]
+			switch spec := specInterface.(type) {
+			case *ast.TypeSpec:
+				typeSpecs = append(typeSpecs, spec)
+			case *ast.ValueSpec:
+				nameIdent := spec.Names[0]
+				switch nameIdent.Obj.Kind {
+				case ast.Var:
+					varSpecs = append(varSpecs, spec)
+				case ast.Con:
+					constSpecs = append(constSpecs, spec)
+				default:
+					panic("Unexpected")
+				}
 			}
-		case ast.Typ:
-			// this can happen when walking type nodes intentionally
-			meta.kind = "typ"
-			meta.typ = e2t(e)
-		default: // ast.Pkg
-			panic("Unexpected ident kind:" + e.Obj.Kind.String() + " name:" + e.Name)
+		case *ast.FuncDecl:
+			funcDecls = append(funcDecls, dcl)
+		default:
+			panic("Unexpected")
 		}
-
 	}
-	return meta
-}
 
-func walkSelectorExpr(e *ast.SelectorExpr, ctx *evalContext) *MetaSelectorExpr {
-	meta := &MetaSelectorExpr{e: e}
-	if isQI(e) {
-		// pkg.ident
-		qi := selector2QI(e)
-		ident := lookupForeignIdent(qi)
-		if ident.Obj.Kind == ast.Fun {
-			// what to do ?
-		} else {
-			walkExpr(ident, ctx)
+	//logf("checking typeSpecs...\n")
+	for _, typeSpec := range typeSpecs {
+		//@TODO check serializeType()'s *ast.Ident case
+		typeSpec.Name.Obj.Data = pkg.name // package to which the type belongs to
+		eType := &ast.Ident{
+			Obj: &ast.Object{
+				Kind: ast.Typ,
+				Decl: typeSpec,
+			},
 		}
-	} else {
-		// expr.field
-		meta.X = walkExpr(e.X, ctx)
+		t := e2t(eType)
+		t.PkgName = pkg.name
+		t.Name = typeSpec.Name.Name
+		exportedTpyes = append(exportedTpyes, t)
+		switch kind(t) {
+		case T_STRUCT:
+			structType := getUnderlyingType(t)
+			calcStructSizeAndSetFieldOffset(structType.E.(*ast.StructType))
+		}
+		ExportedQualifiedIdents[string(newQI(pkg.name, typeSpec.Name.Name))] = typeSpec.Name
 	}
-	//logf("%s: walkSelectorExpr %s\n", fset.Position(e.Sel.Pos()), e.Sel.Name)
-	//meta.typ = getTypeOfExprAst(e)
-	return meta
-}
 
-func walkCallExpr(e *ast.CallExpr, ctx *evalContext) *MetaCallExpr {
-	meta := &MetaCallExpr{
-		e: e,
-	}
-	if isType(e.Fun) {
-		meta.isConversion = true
-		meta.toType = e2t(e.Fun)
-		meta.typ = meta.toType
-		assert(len(e.Args) == 1, "convert must take only 1 argument", __func__)
-		//logf("walkCallExpr: is Conversion\n")
-		ctx := &evalContext{
-			_type: e2t(e.Fun),
+	//logf("checking funcDecls...\n")
+
+	// collect methods in advance
+	for _, funcDecl := range funcDecls {
+		if funcDecl.Recv == nil { // non-method function
+			qi := newQI(pkg.name, funcDecl.Name.Name)
+			ExportedQualifiedIdents[string(qi)] = funcDecl.Name
+		} else { // is method
+			if funcDecl.Body != nil || pkg.fromExportData {
+				method := newMethod(pkg.name, funcDecl)
+				registerMethod(method)
+			}
 		}
-		meta.arg0 = walkExpr(e.Args[0], ctx)
-		return meta
 	}
 
-	meta.isConversion = false
-	meta.fun = e.Fun
-	meta.hasEllipsis = e.Ellipsis != token.NoPos
-	meta.args = e.Args
+	//logf("walking constSpecs...\n")
 
-	// function call
-	metaFun := walkExpr(e.Fun, nil)
+	for _, constSpec := range constSpecs {
+		pkg.consts = append(pkg.consts, constSpec)
+		ExportedQualifiedIdents[string(newQI(pkg.name, constSpec.Names[0].Name))] = constSpec.Names[0]
+		if pkg.fromExportData {
+			continue
+		}
+		for _, v := range constSpec.Values {
+			walkExpr(v, nil) // @TODO: store meta
+		}
+	}
 
-	// Replace __func__ ident by a string literal
-	//for i, arg := range meta.args {
-	//	ident, ok := arg.(*ast.Ident)
-	//	if ok {
-	//		if ident.Name == "__func__" && ident.Obj.Kind == ast.Var {
-	//			basicLit := &ast.BasicLit{
-	//				Kind:  token.STRING,
-	//				Value: "\"" + currentFunc.Name + "\"",
-	//			}
-	//			arg = basicLit
-	//			e.Args[i] = arg
-	//		}
-	//	}
-	//
-	//}
+	//logf("walking varSpecs...\n")
+	for _, spec := range varSpecs {
+		lhsIdent := spec.Names[0]
+		assert(lhsIdent.Obj.Kind == ast.Var, "should be Var", __func__)
+		var rhsMeta MetaExpr
+		var t *Type
+		if spec.Type != nil { // var x T = e
+			// walkExpr(spec.Type, nil) // Do we need walk type ?s
+			t = e2t(spec.Type)
+			if len(spec.Values) > 0 {
+				rhs := spec.Values[0]
+				ctx := &evalContext{_type: t}
+				rhsMeta = walkExpr(rhs, ctx)
+			}
+		} else { // var x = e  infer lhs type from rhs
+			if len(spec.Values) == 0 {
+				panic("invalid syntax")
+			}
 
-	identFun, isIdent := meta.fun.(*ast.Ident)
-	if isIdent {
-		//logf("  fun=%s\n", identFun.Name)
-		switch identFun.Obj {
-		case gLen, gCap:
-			meta.builtin = identFun.Obj
-			meta.arg0 = walkExpr(meta.args[0], nil)
-			meta.typ = tInt
-			return meta
-		case gNew:
-			meta.builtin = identFun.Obj
-			walkExpr(meta.args[0], nil)
-			meta.typeArg0 = e2t(meta.args[0])
-			ptrType := &ast.StarExpr{X: meta.args[0]}
-			meta.typ = e2t(ptrType)
-			return meta
-		case gMake:
-			meta.builtin = identFun.Obj
-			walkExpr(meta.args[0], nil)
-			meta.typeArg0 = e2t(meta.args[0])
-			meta.typ = meta.typeArg0
-			ctx := &evalContext{_type: tInt}
-			if len(meta.args) > 1 {
-				meta.arg1 = walkExpr(meta.args[1], ctx)
+			rhs := spec.Values[0]
+			rhsMeta = walkExpr(rhs, nil)
+			t = getTypeOfExpr(rhsMeta)
+			if t == nil {
+				panic("variable type is not determined : " + lhsIdent.Name)
 			}
+		}
+		spec.Type = t.E
 
-			if len(meta.args) > 2 {
-				meta.arg2 = walkExpr(meta.args[2], ctx)
+		variable := newGlobalVariable(pkg.name, lhsIdent.Obj.Name, t)
+		setVariable(lhsIdent.Obj, variable)
+		metaVar := walkIdent(lhsIdent, nil)
+
+		var rhs ast.Expr
+		if len(spec.Values) > 0 {
+			rhs = spec.Values[0]
+			folded := foldConstExpr(rhs)
+			if folded != nil {
+				rhs = folded
 			}
-			return meta
-		case gAppend:
-			meta.builtin = identFun.Obj
-			meta.arg0 = walkExpr(meta.args[0], nil)
-			meta.arg1 = walkExpr(meta.args[1], nil)
-			meta.typ = getTypeOfExpr(meta.arg0)
-			return meta
-		case gPanic:
-			meta.builtin = identFun.Obj
-			meta.arg0 = walkExpr(meta.args[0], nil)
-			meta.typ = nil
-			return meta
-		case gDelete:
-			meta.builtin = identFun.Obj
-			meta.arg0 = walkExpr(meta.args[0], nil)
-			meta.arg1 = walkExpr(meta.args[1], nil)
-			meta.typ = nil
-			return meta
 		}
+		pkgVar := &packageVar{
+			spec:    spec,
+			name:    lhsIdent,
+			val:     rhs,
+			metaVal: rhsMeta, // can be nil
+			metaVar: metaVar,
+			typ:     t,
+		}
+		pkg.vars = append(pkg.vars, pkgVar)
+		ExportedQualifiedIdents[string(newQI(pkg.name, lhsIdent.Name))] = lhsIdent
 	}
 
-	var funcType *ast.FuncType
-	var funcVal *FuncValue
-	var receiver ast.Expr
-	var receiverMeta MetaExpr
-	switch fn := meta.fun.(type) {
-	case *ast.Ident:
-		// general function call
-		symbol := getPackageSymbol(currentPkg.name, fn.Name)
-		switch currentPkg.name {
-		case "os":
-			switch fn.Name {
-			case "runtime_args":
-				symbol = getPackageSymbol("runtime", "runtime_args")
-			case "runtime_getenv":
-				symbol = getPackageSymbol("runtime", "runtime_getenv")
-			}
-		case "runtime":
-			if fn.Name == "makeSlice1" || fn.Name == "makeSlice8" || fn.Name == "makeSlice16" || fn.Name == "makeSlice24" {
-				fn.Name = "makeSlice"
-				symbol = getPackageSymbol("runtime", fn.Name)
-			}
+	pkg.typeSpecs = typeSpecs
+	pkg.funcDecls = funcDecls
+	if pkg.fromExportData {
+		// functions have no bodies
+		return
+	}
+
+	//logf("walking funcDecls in detail ...\n")
+	for _, funcDecl := range funcDecls {
+		//logf("[walk] (package:%s) (pos:%d) (%s) walking funcDecl \"%s\" \n",
+		//	pkg.name, int(funcDecl.Pos()), pkg.fset.Position(funcDecl.Pos()), funcDecl.Name.Name)
+		//logf("walking funcDecl \"%s\" \n", funcDecl.Name.Name)
+		fnc := &Func{
+			Pos:       funcDecl.Name.NamePos,
+			Name:      funcDecl.Name.Name,
+			FuncType:  funcDecl.Type,
+			Localarea: 0,
+			Argsarea:  16, // return address + previous rbp
 		}
-		funcVal = NewFuncValueFromSymbol(symbol)
-		switch dcl := fn.Obj.Decl.(type) {
-		case *ast.FuncDecl:
-			funcType = dcl.Type
-		case *ast.ValueSpec: // var f func()
-			funcType = dcl.Type.(*ast.FuncType)
-			funcVal = &FuncValue{
-				expr: metaFun,
-			}
-		case *ast.AssignStmt: // f := staticF
-			assert(fn.Obj.Data != nil, "funcvalue should be a variable:"+fn.Name, __func__)
-			rhs := dcl.Rhs[0]
-			switch r := rhs.(type) {
-			case *ast.SelectorExpr:
-				assert(isQI(r), "expect QI", __func__)
-				qi := selector2QI(r)
-				ff := lookupForeignFunc(qi)
-				funcType = ff.funcType
-				funcVal = NewFuncValueFromSymbol(string(qi))
-			default:
-				throw(r)
-			}
-		default:
-			throw(dcl)
+		currentFunc = fnc
+
+		var paramFields []*ast.Field
+		var resultFields []*ast.Field
+
+		if funcDecl.Recv != nil { // Method
+			paramFields = append(paramFields, funcDecl.Recv.List[0])
+		}
+		for _, field := range funcDecl.Type.Params.List {
+			paramFields = append(paramFields, field)
+		}
+
+		if funcDecl.Type.Results != nil {
+			for _, field := range funcDecl.Type.Results.List {
+				resultFields = append(resultFields, field)
+			}
 		}
-	case *ast.SelectorExpr:
-		if isQI(fn) {
-			// pkg.Sel()
-			qi := selector2QI(fn)
-			funcVal = NewFuncValueFromSymbol(string(qi))
-			ff := lookupForeignFunc(qi)
-			funcType = ff.funcType
-		} else {
-			// method call
-			receiver = fn.X
-			receiverMeta = walkExpr(receiver, nil)
-			receiverType := getTypeOfExpr(receiverMeta)
-			method := lookupMethod(receiverType, fn.Sel)
-			funcType = method.FuncType
-			funcVal = NewFuncValueFromSymbol(getMethodSymbol(method))
 
-			if kind(receiverType) == T_POINTER {
-				if method.IsPtrMethod {
-					// p.mp() => as it is
-				} else {
-					// p.mv()
-					panic("TBI")
-				}
+		for _, field := range paramFields {
+			obj := field.Names[0].Obj
+			setVariable(obj, registerParamVariable(fnc, obj.Name, e2t(field.Type)))
+		}
+
+		for i, field := range resultFields {
+			if len(field.Names) == 0 {
+				// unnamed retval
+				registerReturnVariable(fnc, ".r"+strconv.Itoa(i), e2t(field.Type))
 			} else {
-				if method.IsPtrMethod {
-					// v.mp() => (&v).mp()
-					// @TODO we should check addressable
-					rcvr := &ast.UnaryExpr{
-						Op: token.AND,
-						X:  receiver,
-					}
-					eTyp := &ast.StarExpr{X: receiver}
-					receiverMeta = &MetaUnaryExpr{
-						e:   rcvr,
-						X:   receiverMeta,
-						typ: e2t(eTyp),
-					}
-				} else {
-					// v.mv() => as it is
-				}
+				panic("TBI: named return variable is not supported")
 			}
 		}
-	default:
-		throw(meta.fun)
-	}
 
-	meta.funcType = funcType
-	if funcType.Results != nil && len(funcType.Results.List) > 0 {
-		meta.types = fieldList2Types(funcType.Results)
-		meta.typ = meta.types[0]
-	}
-	meta.funcVal = funcVal
-	meta.metaArgs = prepareArgs(meta.funcType, receiverMeta, meta.args, meta.hasEllipsis)
-	return meta
-}
+		if funcDecl.Body != nil {
+			fnc.Stmts = walkStmtList(funcDecl.Body.List)
+			escapeAnalysis(fnc)
 
-func walkBasicLit(e *ast.BasicLit, ctx *evalContext) *MetaBasicLit {
-	m := &MetaBasicLit{
-		Kind:  e.Kind.String(),
-		Value: e.Value,
+			if funcDecl.Recv != nil { // is Method
+				fnc.Method = newMethod(pkg.name, funcDecl)
+			}
+			pkg.funcs = append(pkg.funcs, fnc)
+		}
+		currentFunc = nil
 	}
 
-	switch e.Kind.String() {
-	case "CHAR":
-		var val = e.Value
-		var char = val[1]
-		if val[1] == '\\' {
-			switch val[2] {
-			case '\'':
-				char = '\''
-			case 'n':
-				char = '\n'
-			case '\\':
-				char = '\\'
-			case 't':
-				char = '\t'
-			case 'r':
-				char = '\r'
-			}
-		}
-		m.charVal = int(char)
-	case "INT":
-		m.intVal = strconv.Atoi(m.Value)
-	case "STRING":
-		m.strVal = registerStringLiteral(e)
-	default:
-		panic("Unexpected literal kind:" + e.Kind.String())
+	printf("# Package types:\n")
+	for _, typ := range exportedTpyes {
+		printf("# type %s %s\n", serializeType(typ), serializeType(getUnderlyingType(typ)))
 	}
-	m.typ = getTypeOfExprAst(e)
-	return m
 }
 
-func walkCompositeLit(e *ast.CompositeLit, ctx *evalContext) *MetaCompositLit {
-	walkExpr(e.Type, nil) // a[len("foo")]{...} // "foo" should be walked
-	typ := e2t(e.Type)
-	ut := getUnderlyingType(typ)
-	var knd string
-	switch kind(ut) {
-	case T_STRUCT:
-		knd = "struct"
-	case T_ARRAY:
-		knd = "array"
-	case T_SLICE:
-		knd = "slice"
-	default:
-		unexpectedKind(kind(typ))
-	}
-	meta := &MetaCompositLit{
-		e:    e,
-		kind: knd,
-		typ:  typ,
-	}
-
-	switch kind(ut) {
-	case T_STRUCT:
-		structType := meta.typ
-		var metaElms []*MetaStructLiteralElement
-		for _, elm := range e.Elts {
-			kvExpr := elm.(*ast.KeyValueExpr)
-			fieldName := kvExpr.Key.(*ast.Ident)
-			field := lookupStructField(getUnderlyingStructType(structType), fieldName.Name)
-			fieldType := e2t(field.Type)
-			ctx := &evalContext{_type: fieldType}
-			// attach type to nil : STRUCT{Key:nil}
-			valueMeta := walkExpr(kvExpr.Value, ctx)
+// --- constant folding ---
+// Constant expressions are evaluated in the walk phase and replaced by literals.
+// Branches under a constant condition and statements after a return, a break, a continue
+// or a panic are dropped without being walked.
 
-			metaElm := &MetaStructLiteralElement{
-				field:     field,
-				fieldType: fieldType,
-				ValueMeta: valueMeta,
+// foldConstExpr evaluates a constant expression.
+// It returns the value as a basic literal or as the ident true or false,
+// or nil if expr is not constant.
+func foldConstExpr(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		return e
+	case *ast.ParenExpr:
+		return foldConstExpr(e.X)
+	case *ast.Ident:
+		if e.Obj == nil || e.Obj.Kind != ast.Con || e.Obj == gNil {
+			return nil
+		}
+		if e.Obj == gTrue || e.Obj == gFalse {
+			return e
+		}
+		valSpec, ok := e.Obj.Decl.(*ast.ValueSpec)
+		if !ok || len(valSpec.Values) == 0 {
+			return nil
+		}
+		return foldConstExpr(valSpec.Values[0])
+	case *ast.UnaryExpr:
+		x := foldConstExpr(e.X)
+		if x == nil {
+			return nil
+		}
+		switch e.Op.String() {
+		case "+":
+			_, isInt := constLitInt(x)
+			if isInt {
+				return x
+			}
+		case "-":
+			v, isInt := constLitInt(x)
+			if isInt {
+				return newIntLit(-v)
+			}
+		case "!":
+			b, isBool := constLitBool(x)
+			if isBool {
+				return newBoolIdent(!b)
 			}
-
-			metaElms = append(metaElms, metaElm)
 		}
-		meta.strctEements = metaElms
-	case T_ARRAY:
-		meta.arrayType = ut.E.(*ast.ArrayType)
-		meta.len = evalInt(meta.arrayType.Len)
-		meta.elmType = e2t(meta.arrayType.Elt)
-		ctx := &evalContext{_type: meta.elmType}
-		var ms []MetaExpr
-		for _, v := range e.Elts {
-			m := walkExpr(v, ctx)
-			ms = append(ms, m)
+	case *ast.BinaryExpr:
+		x := foldConstExpr(e.X)
+		if x == nil {
+			return nil
 		}
-		meta.metaElms = ms
-	case T_SLICE:
-		meta.arrayType = ut.E.(*ast.ArrayType)
-		meta.len = len(e.Elts)
-		meta.elmType = e2t(meta.arrayType.Elt)
-		ctx := &evalContext{_type: meta.elmType}
-		var ms []MetaExpr
-		for _, v := range e.Elts {
-			m := walkExpr(v, ctx)
-			ms = append(ms, m)
+		y := foldConstExpr(e.Y)
+		if y == nil {
+			return nil
 		}
-		meta.metaElms = ms
+		return foldBinaryExpr(e.Op.String(), x, y)
 	}
-	return meta
+	return nil
 }
 
-func walkUnaryExpr(e *ast.UnaryExpr, ctx *evalContext) *MetaUnaryExpr {
-	meta := &MetaUnaryExpr{e: e}
-	meta.X = walkExpr(e.X, nil)
-	meta.typ = getTypeOfExprAst(e)
-	return meta
-}
+func foldBinaryExpr(op string, x ast.Expr, y ast.Expr) ast.Expr {
+	xi, xIsInt := constLitInt(x)
+	yi, yIsInt := constLitInt(y)
+	if xIsInt && yIsInt {
+		switch op {
+		case "+":
+			return newIntLit(xi + yi)
+		case "-":
+			return newIntLit(xi - yi)
+		case "*":
+			return newIntLit(xi * yi)
+		case "/":
+			// division is unsigned at run time, so only non-negative operands are folded
+			if xi >= 0 && yi > 0 {
+				return newIntLit(xi / yi)
+			}
+		case "%":
+			if xi >= 0 && yi > 0 {
+				return newIntLit(xi % yi)
+			}
+		case "==":
+			return newBoolIdent(xi == yi)
+		case "!=":
+			return newBoolIdent(xi != yi)
+		case "<":
+			return newBoolIdent(xi < yi)
+		case "<=":
+			return newBoolIdent(xi <= yi)
+		case ">":
+			return newBoolIdent(xi > yi)
+		case ">=":
+			return newBoolIdent(xi >= yi)
+		}
+		return nil
+	}
 
-func walkBinaryExpr(e *ast.BinaryExpr, ctx *evalContext) *MetaBinaryExpr {
-	meta := &MetaBinaryExpr{
-		e:  e,
-		Op: e.Op.String(),
+	xs, xIsString := constLitString(x)
+	ys, yIsString := constLitString(y)
+	if xIsString && yIsString {
+		switch op {
+		case "+":
+			return &ast.BasicLit{
+				Kind:  token.STRING,
+				Value: xs[:len(xs)-1] + ys[1:],
+			}
+		case "==":
+			return newBoolIdent(xs == ys)
+		case "!=":
+			return newBoolIdent(xs != ys)
+		}
+		return nil
 	}
-	if isNilIdent(e.X) {
-		// Y should be typed
-		meta.Y = walkExpr(e.Y, nil) // right
-		xCtx := &evalContext{_type: getTypeOfExpr(meta.Y)}
 
-		meta.X = walkExpr(e.X, xCtx) // left
-	} else {
-		// X should be typed
-		meta.X = walkExpr(e.X, nil) // left
-		yCtx := &evalContext{_type: getTypeOfExpr(meta.X)}
-		meta.Y = walkExpr(e.Y, yCtx) // right
+	xb, xIsBool := constLitBool(x)
+	yb, yIsBool := constLitBool(y)
+	if xIsBool && yIsBool {
+		switch op {
+		case "&&":
+			return newBoolIdent(xb && yb)
+		case "||":
+			return newBoolIdent(xb || yb)
+		case "==":
+			return newBoolIdent(xb == yb)
+		case "!=":
+			return newBoolIdent(xb != yb)
+		}
 	}
-	meta.typ = getTypeOfExprAst(e)
-	return meta
+	return nil
 }
 
-func walkIndexExpr(e *ast.IndexExpr, ctx *evalContext) *MetaIndexExpr {
-	meta := &MetaIndexExpr{
-		e: e,
+// constLitInt returns the value of an integer or rune literal.
+func constLitInt(x ast.Expr) (int, bool) {
+	lit, ok := x.(*ast.BasicLit)
+	if !ok {
+		return 0, false
 	}
-	meta.Index = walkExpr(e.Index, nil) // @TODO pass context for map,slice,array
-	meta.X = walkExpr(e.X, nil)
-	if kind(getTypeOfExpr(meta.X)) == T_MAP {
-		meta.IsMap = true
-		if ctx != nil && ctx.maybeOK {
-			meta.NeedsOK = true
-		}
+	switch lit.Kind.String() {
+	case "INT":
+		return strconv.Atoi(lit.Value), true
+	case "CHAR":
+		return charLitValue(lit.Value), true
 	}
-	meta.typ = getTypeOfExprAst(e)
-	return meta
+	return 0, false
 }
 
-func walkSliceExpr(e *ast.SliceExpr, ctx *evalContext) *MetaSliceExpr {
-	meta := &MetaSliceExpr{e: e}
-
-	// For convenience, any of the indices may be omitted.
-
-	// A missing low index defaults to zero;
-	if e.Low != nil {
-		meta.Low = walkExpr(e.Low, nil)
-	} else {
-		eZeroInt := &ast.BasicLit{
-			Value: "0",
-			Kind:  token.INT,
+// constLitString returns the quoted value of a string literal.
+// Escape sequences are compared as they are written, so literals having any are not folded.
+func constLitString(x ast.Expr) (string, bool) {
+	lit, ok := x.(*ast.BasicLit)
+	if !ok || lit.Kind.String() != "STRING" || lit.Value[0] != '"' {
+		return "", false
+	}
+	for i := 0; i < len(lit.Value); i++ {
+		if lit.Value[i] == '\\' {
+			return "", false
 		}
-		meta.Low = walkExpr(eZeroInt, nil)
 	}
+	return lit.Value, true
+}
 
-	if e.High != nil {
-		meta.High = walkExpr(e.High, nil)
+func constLitBool(x ast.Expr) (bool, bool) {
+	ident, ok := x.(*ast.Ident)
+	if !ok {
+		return false, false
 	}
-	if e.Max != nil {
-		meta.Max = walkExpr(e.Max, nil)
+	switch ident.Obj {
+	case gTrue:
+		return true, true
+	case gFalse:
+		return false, true
 	}
-	meta.X = walkExpr(e.X, nil)
-	meta.typ = getTypeOfExprAst(e)
-	return meta
+	return false, false
 }
 
-// [N]T(e)
-func walkArrayType(e *ast.ArrayType) {
-	// BasicLit in N should be walked
-	// e.g. A[5], A[len("foo")]
-	if e.Len != nil {
-		walkExpr(e.Len, nil)
+func newIntLit(v int) *ast.BasicLit {
+	return &ast.BasicLit{
+		Kind:  token.INT,
+		Value: strconv.Itoa(v),
 	}
 }
-func walkMapType(e *ast.MapType) {
-	// first argument of builtin func
-	// do nothing
-}
-func walkStarExpr(e *ast.StarExpr, ctx *evalContext) *MetaStarExpr {
-	meta := &MetaStarExpr{e: e}
-	meta.X = walkExpr(e.X, nil)
-	meta.typ = getTypeOfExprAst(e)
-	return meta
-}
-
-func walkKeyValueExpr(e *ast.KeyValueExpr, ctx *evalContext) {
-	// MYSTRUCT{key:value}
-	// key is not an expression in struct literals.
-	// Actually struct case is handled in walkCompositeLit().
-	// In map, array or slice types, key can be an expression.
-	// // map
-	// expr := "hello"
-	// a := map[string]int{expr: 0}
-	// fmt.Println(a) // => map[hello:0]
-
-	//const key = 1
-	//s := []bool{key: true}
-	//fmt.Println(s) // => map[hello:0]
-
-	// const key = 1
-	// s := []bool{key: true} // => [false true]
-
-	//walkExpr(e.Key, nil)
-	panic("TBI")
-}
 
-func walkInterfaceType(e *ast.InterfaceType) {
-	// interface{}(e)  conversion. Nothing to do.
+func newBoolIdent(b bool) *ast.Ident {
+	if b {
+		return &ast.Ident{Name: "true", Obj: gTrue}
+	}
+	return &ast.Ident{Name: "false", Obj: gFalse}
 }
 
-func walkTypeAssertExpr(e *ast.TypeAssertExpr, ctx *evalContext) *MetaTypeAssertExpr {
-	meta := &MetaTypeAssertExpr{e: e}
-	if ctx != nil && ctx.maybeOK {
-		meta.NeedsOK = true
+// walkConstExpr walks the folded value of a constant expression whose type is t.
+func walkConstExpr(folded ast.Expr, t *Type) MetaExpr {
+	meta := walkExpr(folded, nil)
+	switch m := meta.(type) {
+	case *MetaBasicLit:
+		m.typ = t
+	case *MetaIdent:
+		m.typ = t
 	}
-	meta.X = walkExpr(e.X, nil)
-	meta.typ = getTypeOfExprAst(e)
 	return meta
 }
 
-type MetaExpr interface{}
-
-type MetaBasicLit struct {
-	typ     *Type
-	Kind    string
-	Value   string
-	charVal int
-	intVal  int
-	strVal  *sliteral
+// constBool reports the value of meta if it is a boolean constant.
+func constBool(meta MetaExpr) (bool, bool) {
+	m, ok := meta.(*MetaIdent)
+	if !ok {
+		return false, false
+	}
+	switch m.kind {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	case "con":
+		b, isConst := constBool(m.conLiteral)
+		return b, isConst
+	}
+	return false, false
 }
 
-type MetaCompositLit struct {
-	e    *ast.CompositeLit
-	typ  *Type  // type of the composite
-	kind string // "struct", "array", "slice" // @TODO "map"
+// walkStmtList walks a list of statements, dropping the ones that cannot be reached.
+func walkStmtList(list []ast.Stmt) []MetaStmt {
+	var ms []MetaStmt
+	for _, stmt := range list {
+		ms = append(ms, walkStmt(stmt))
+		if isTerminatingStmt(stmt) {
+			break
+		}
+	}
+	return ms
+}
 
-	// for struct
-	strctEements []*MetaStructLiteralElement // for "struct"
+// isTerminatingStmt reports whether the statements following stmt in a list are unreachable.
+func isTerminatingStmt(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.BranchStmt:
+		return true
+	case *ast.ExprStmt:
+		call, ok := s.X.(*ast.CallExpr)
+		if ok {
+			fn, isIdent := call.Fun.(*ast.Ident)
+			return isIdent && fn.Obj == gPanic
+		}
+	}
+	return false
+}
 
-	// for array or slice
-	arrayType *ast.ArrayType
-	len       int
-	elmType   *Type
-	metaElms  []MetaExpr
+// recordFuncRef records a reference to a function of the current package.
+func recordFuncRef(name string) {
+	if currentFunc != nil {
+		currentFunc.Refs = append(currentFunc.Refs, name)
+	} else {
+		currentPkg.funcRefs = append(currentPkg.funcRefs, name)
+	}
 }
 
-type MetaIdent struct {
-	e    *ast.Ident
-	typ  *Type
-	kind string // "blank|nil|true|false|var|con|fun|typ"
-	Name string
+// removeUnreferencedFuncs drops the unexported functions of pkg which cannot be called.
+// Functions are reachable from main, init, exported functions, methods and package-level initializers.
+func removeUnreferencedFuncs(pkg *PkgContainer) {
+	funcsByName := make(map[string]*Func)
+	for _, fnc := range pkg.funcs {
+		if fnc.Method == nil {
+			funcsByName[fnc.Name] = fnc
+		}
+	}
 
-	variable *Variable // for "var"
+	var work []string
+	for _, name := range pkg.funcRefs {
+		work = append(work, name)
+	}
+	for _, fnc := range pkg.funcs {
+		if isRootFunc(fnc) {
+			for _, name := range fnc.Refs {
+				work = append(work, name)
+			}
+		}
+	}
+
+	reached := make(map[string]bool)
+	for len(work) > 0 {
+		name := work[len(work)-1]
+		work = work[:len(work)-1]
+		if reached[name] {
+			continue
+		}
+		reached[name] = true
+		fnc, ok := funcsByName[name]
+		if ok {
+			for _, ref := range fnc.Refs {
+				work = append(work, ref)
+			}
+		}
+	}
 
-	conLiteral *MetaBasicLit // for "con"
+	var funcs []*Func
+	for _, fnc := range pkg.funcs {
+		if isRootFunc(fnc) || reached[fnc.Name] {
+			funcs = append(funcs, fnc)
+		} else {
+			logff("removing unreferenced func %s.%s\n", pkg.name, fnc.Name)
+		}
+	}
+	pkg.funcs = funcs
 }
 
-type MetaSelectorExpr struct {
-	e   *ast.SelectorExpr
-	typ *Type
-	X   MetaExpr
+func isRootFunc(fnc *Func) bool {
+	return fnc.Method != nil || fnc.Name == "main" || fnc.Name == "init" || isExportedName(fnc.Name)
 }
 
-type MetaCallExpr struct {
-	e            *ast.CallExpr
-	typ          *Type // result type
-	isConversion bool
+func isExportedName(name string) bool {
+	return 'A' <= name[0] && name[0] <= 'Z'
+}
 
-	// For Conversion
-	toType *Type
+// --- inlining ---
+// A call to a small function whose body is a single return statement is replaced by the body.
+// The arguments are assigned to fresh local variables standing for the parameters,
+// so that they are evaluated once and in order, and the returned expression is walked with them.
+// The body may only use parameters, constants, package variables, operators, field and index access,
+// conversions, len and cap, and its size is limited by -inline=N.
+// Bodies of inlinable functions are written to export data, so that they are inlined in other packages too.
+// A function is not inlined if its doc comment has a //go:noinline line.
 
-	arg0     MetaExpr // For conversion, len, cap
-	typeArg0 *Type
-	arg1     MetaExpr
-	arg2     MetaExpr
+// -inline=N: maximum number of nodes of an inlined body. 0 disables inlining.
+var inlineBudget int = 20
 
-	// For funcall
-	fun         ast.Expr
-	hasEllipsis bool
-	args        []ast.Expr
+type MetaInlineCall struct {
+	e      *ast.CallExpr
+	typ    *Type
+	name   string              // name of the inlined function
+	params []*MetaSingleAssign // assignments of the arguments to the parameters
+	result MetaExpr
+}
 
-	builtin *ast.Object
+// inlineCall returns the inlined body of a call, or nil if it cannot be inlined.
+func inlineCall(e *ast.CallExpr, ctx *evalContext) MetaExpr {
+	if optLevel == 0 || currentFunc == nil || e.Ellipsis != token.NoPos {
+		return nil
+	}
+	decl := inlineCallee(e.Fun)
+	if decl == nil || !isInlinable(decl) || len(decl.Type.Params.List) != len(e.Args) {
+		return nil
+	}
 
-	// general funcall
-	funcType *ast.FuncType
-	funcVal  *FuncValue
-	//receiver ast.Expr
-	metaArgs []*MetaArg
-	types    []*Type
+	if escapeDiag {
+		pos := e.Lparen
+		switch fn := e.Fun.(type) {
+		case *ast.Ident:
+			pos = fn.NamePos
+		case *ast.SelectorExpr:
+			pos = fn.X.(*ast.Ident).NamePos
+		}
+		fmt.Fprintf(os.Stderr, "%s: inlining call to %s\n", positionString(pos), decl.Name.Name)
+	}
+	meta := &MetaInlineCall{
+		e:    e,
+		typ:  e2t(decl.Type.Results.List[0].Type),
+		name: decl.Name.Name,
+	}
+	var vars []*Variable
+	for i, field := range decl.Type.Params.List {
+		t := e2t(field.Type)
+		arg := walkExpr(e.Args[i], &evalContext{_type: t})
+		vr := registerLocalVariable(currentFunc, decl.Name.Name+"."+field.Names[0].Name, t)
+		lhs := &MetaIdent{
+			e:        field.Names[0],
+			typ:      t,
+			kind:     "var",
+			Name:     vr.Name,
+			variable: vr,
+		}
+		meta.params = append(meta.params, &MetaSingleAssign{Pos: e.Lparen, Lhs: lhs, Rhs: arg})
+		vars = append(vars, vr)
+	}
+
+	// bind the parameters to the new variables while the body is walked.
+	// A binding is kept if there was none, as types of expressions are looked up through it later.
+	var saved []interface{}
+	for i, field := range decl.Type.Params.List {
+		obj := field.Names[0].Obj
+		saved = append(saved, obj.Data)
+		setVariable(obj, vars[i])
+	}
+	ret := decl.Body.List[0].(*ast.ReturnStmt)
+	meta.result = walkExpr(ret.Results[0], &evalContext{_type: meta.typ})
+	for i, field := range decl.Type.Params.List {
+		if saved[i] != nil {
+			field.Names[0].Obj.Data = saved[i]
+		}
+	}
+	return meta
 }
 
-type MetaIndexExpr struct {
-	IsMap   bool // mp[k]
-	NeedsOK bool // when map, is it ok syntax ?
-	Index   MetaExpr
-	X       MetaExpr
-	e       *ast.IndexExpr
-	typ     *Type
+// inlineCallee returns the declaration of a function called by name, or nil.
+func inlineCallee(fun ast.Expr) *ast.FuncDecl {
+	var ident *ast.Ident
+	switch fn := fun.(type) {
+	case *ast.Ident:
+		ident = fn
+	case *ast.SelectorExpr:
+		if !isQI(fn) {
+			return nil
+		}
+		ident = lookupForeignIdent(selector2QI(fn))
+	default:
+		return nil
+	}
+	if ident.Obj == nil || ident.Obj.Kind != ast.Fun {
+		return nil
+	}
+	decl, ok := ident.Obj.Decl.(*ast.FuncDecl)
+	if !ok {
+		return nil
+	}
+	return decl
 }
 
-type MetaSliceExpr struct {
-	e    *ast.SliceExpr
-	typ  *Type
-	Low  MetaExpr
-	High MetaExpr
-	Max  MetaExpr
-	X    MetaExpr
-}
-type MetaStarExpr struct {
-	e   *ast.StarExpr
-	typ *Type
-	X   MetaExpr
+// isInlinable reports whether calls to a function are inlined.
+func isInlinable(decl *ast.FuncDecl) bool {
+	return optLevel != 0 && inlineBlocker(decl) == ""
 }
-type MetaUnaryExpr struct {
-	e   *ast.UnaryExpr
-	X   MetaExpr
-	typ *Type
+
+// inlineBlocker returns why calls to a function are not inlined, or "" if they are.
+// Methods and bodies of more than one statement are not inlined: the body is walked as an expression in the caller.
+func inlineBlocker(decl *ast.FuncDecl) string {
+	if decl.Recv != nil {
+		return "method"
+	}
+	if decl.Body == nil {
+		return "no body"
+	}
+	if hasNoinline(decl) {
+		return "marked go:noinline"
+	}
+	if len(decl.Body.List) != 1 {
+		return "body is not a single return statement"
+	}
+	results := decl.Type.Results
+	if results == nil || len(results.List) != 1 || len(results.List[0].Names) != 0 || isInterface(e2t(results.List[0].Type)) {
+		return "result is not a single unnamed value of a concrete type"
+	}
+	for _, field := range decl.Type.Params.List {
+		if len(field.Names) != 1 || field.Names[0].Obj == nil {
+			return "unnamed or grouped parameters"
+		}
+		_, isEllipsis := field.Type.(*ast.Ellipsis)
+		if isEllipsis || isInterface(e2t(field.Type)) {
+			return "variadic or interface parameter"
+		}
+	}
+	ret, isReturn := decl.Body.List[0].(*ast.ReturnStmt)
+	if !isReturn || len(ret.Results) != 1 {
+		return "body is not a single return statement"
+	}
+	cost := inlineCost(ret.Results[0])
+	if cost < 0 {
+		return "unsupported expression"
+	}
+	if cost > inlineBudget {
+		return "cost " + strconv.Itoa(cost) + " exceeds budget " + strconv.Itoa(inlineBudget)
+	}
+	return ""
 }
-type MetaBinaryExpr struct {
-	e   *ast.BinaryExpr
-	typ *Type
-	Op  string
-	X   MetaExpr
-	Y   MetaExpr
+
+// printInlineDecisions prints with -m whether calls to each function of the package are inlined.
+func printInlineDecisions(files []*ast.File) {
+	for _, file := range files {
+		for _, d := range file.Decls {
+			decl, ok := d.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			name := decl.Name.Name
+			if decl.Recv != nil {
+				switch recv := decl.Recv.List[0].Type.(type) {
+				case *ast.StarExpr:
+					name = "(*" + recv.X.(*ast.Ident).Name + ")." + name
+				case *ast.Ident:
+					name = "(" + recv.Name + ")." + name
+				}
+			}
+			reason := inlineBlocker(decl)
+			if reason == "" {
+				fmt.Fprintf(os.Stderr, "%s: can inline %s\n", positionString(decl.Pos()), name)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: cannot inline %s: %s\n", positionString(decl.Pos()), name, reason)
+			}
+		}
+	}
 }
 
-type MetaTypeAssertExpr struct {
-	NeedsOK bool
-	X       MetaExpr
-	e       *ast.TypeAssertExpr
-	typ     *Type
+func hasNoinline(decl *ast.FuncDecl) bool {
+	if decl.Doc == nil {
+		return false
+	}
+	for _, c := range decl.Doc.List {
+		if c.Text == "//go:noinline" {
+			return true
+		}
+	}
+	return false
 }
 
-// ctx type is the type of someone who receives the expr value.
-// There are various forms:
-//
-//	Assignment:       x = expr
-//	Function call:    x(expr)
-//	Return:           return expr
-//	CompositeLiteral: T{key:expr}
-//
-// targetType is used when:
-//   - the expr is nil
-//   - the target type is interface and expr is not.
-func walkExpr(expr ast.Expr, ctx *evalContext) MetaExpr {
+// inlineCost returns the number of nodes of an expression, or -1 if it cannot be inlined.
+// Parentheses are not counted, as export data adds them.
+func inlineCost(expr ast.Expr) int {
 	switch e := expr.(type) {
 	case *ast.BasicLit:
-		return walkBasicLit(e, ctx)
-	case *ast.CompositeLit:
-		return walkCompositeLit(e, ctx)
+		return 1
 	case *ast.Ident:
-		return walkIdent(e, ctx)
+		if e.Obj == nil || (e.Obj.Kind != ast.Var && e.Obj.Kind != ast.Con) {
+			return -1
+		}
+		return 1
+	case *ast.ParenExpr:
+		return inlineCost(e.X)
+	case *ast.UnaryExpr:
+		if e.Op.String() == "&" {
+			return -1
+		}
+		return inlineCostSum(1, e.X, nil)
+	case *ast.BinaryExpr:
+		return inlineCostSum(1, e.X, e.Y)
 	case *ast.SelectorExpr:
-		return walkSelectorExpr(e, ctx)
-	case *ast.CallExpr:
-		return walkCallExpr(e, ctx)
+		if isQI(e) {
+			return -1
+		}
+		return inlineCostSum(1, e.X, nil)
 	case *ast.IndexExpr:
-		return walkIndexExpr(e, ctx)
-	case *ast.SliceExpr:
-		return walkSliceExpr(e, ctx)
+		return inlineCostSum(1, e.X, e.Index)
 	case *ast.StarExpr:
-		return walkStarExpr(e, ctx)
-	case *ast.UnaryExpr:
-		return walkUnaryExpr(e, ctx)
-	case *ast.BinaryExpr:
-		return walkBinaryExpr(e, ctx)
-	case *ast.TypeAssertExpr:
-		return walkTypeAssertExpr(e, ctx)
-
-	case *ast.ParenExpr:
-		return walkExpr(e.X, ctx)
-	case *ast.KeyValueExpr:
-		walkKeyValueExpr(e, ctx)
-		return nil
-
-	// Each one below is not an expr but a type
-	case *ast.ArrayType: // type
-		walkArrayType(e) // []T(e)
-		return nil
-	case *ast.MapType: // type
-		walkMapType(e)
-		return nil
-	case *ast.InterfaceType: // type
-		walkInterfaceType(e)
-		return nil
-	case *ast.FuncType:
-		return nil // @TODO walk
-	default:
-		panic(fmt.Sprintf("unknown type %T", expr))
-	}
-}
-
-var ExportedQualifiedIdents = make(map[string]*ast.Ident)
-
-func lookupForeignIdent(qi QualifiedIdent) *ast.Ident {
-	ident, ok := ExportedQualifiedIdents[string(qi)]
-	if !ok {
-		panic(qi + " Not found in ExportedQualifiedIdents")
+		return inlineCostSum(1, e.X, nil)
+	case *ast.CallExpr:
+		if len(e.Args) != 1 {
+			return -1
+		}
+		if isType(e.Fun) {
+			return inlineCostSum(1, e.Args[0], nil)
+		}
+		fn, isIdent := e.Fun.(*ast.Ident)
+		if isIdent && (fn.Obj == gLen || fn.Obj == gCap) {
+			return inlineCostSum(1, e.Args[0], nil)
+		}
 	}
-	return ident
-}
-
-type ForeignFunc struct {
-	symbol   string
-	funcType *ast.FuncType
+	return -1
 }
 
-func lookupForeignFunc(qi QualifiedIdent) *ForeignFunc {
-	ident := lookupForeignIdent(qi)
-	assert(ident.Obj.Kind == ast.Fun, "should be Fun", __func__)
-	decl := ident.Obj.Decl.(*ast.FuncDecl)
-	return &ForeignFunc{
-		symbol:   string(qi),
-		funcType: decl.Type,
+func inlineCostSum(n int, x ast.Expr, y ast.Expr) int {
+	cx := inlineCost(x)
+	if cx < 0 {
+		return -1
+	}
+	n = n + cx
+	if y != nil {
+		cy := inlineCost(y)
+		if cy < 0 {
+			return -1
+		}
+		n = n + cy
 	}
+	return n
 }
 
-type Func struct {
-	Name      string
-	Stmts     []MetaStmt
-	Localarea int
-	Argsarea  int
-	LocalVars []*Variable
-	Params    []*Variable
-	Retvars   []*Variable
-	FuncType  *ast.FuncType
-	Method    *Method
-}
-type Method struct {
-	PkgName      string
-	RcvNamedType *ast.Ident
-	IsPtrMethod  bool
-	Name         string
-	FuncType     *ast.FuncType
+// --- escape analysis ---
+// An allocation by new(T) or &T{...} is placed in the frame of the function when the pointer cannot outlive it.
+// The pointer may be kept in local variables, dereferenced, indexed and compared.
+// It escapes when it is returned, stored in memory, passed to a function, converted to another type than a pointer,
+// or when its variable or a part of the storage has its address taken.
+// An allocation in a loop also escapes if the pointer flows to a variable declared outside the loop body,
+// because the storage is reused by every iteration.
+// Struct and array literals used as values are temporaries and always live in the frame.
+//
+// The same goes for a local variable or parameter whose address is taken by &x, by slicing an array
+// or by calling a pointer method. If the address escapes, the variable is moved to the heap:
+// its storage is allocated when it is declared, and the frame only holds the address of it.
+// The storage of a variable declared in a loop body is allocated by every iteration.
+//
+// The storage passed to the thread started by a go statement always escapes, as the thread outlives the frame.
+
+// -m: print the escape and inlining decisions
+var escapeDiag bool
+
+// escHeap is the destination of pointers which escape.
+var escHeap = &Variable{Name: ".heap", EscLevel: -1}
+
+type escapeFlow struct {
+	from *Variable
+	to   *Variable
 }
-type Variable struct {
-	Name         string
-	IsGlobal     bool
-	GlobalSymbol string
-	LocalOffset  int
-	Typ          *Type
+
+type escapeSite struct {
+	pos      token.Pos
+	desc     string    // "new(T)" or "&T{...}"
+	typ      *Type     // type of the storage
+	vr       *Variable // stands for the pointer to the storage
+	depth    int
+	lit      *MetaCompositLit
+	call     *MetaCallExpr
+	variable *Variable // the variable whose address is taken
 }
 
-func setVariable(obj *ast.Object, vr *Variable) {
-	assert(obj.Kind == ast.Var, "obj is not  ast.Var", __func__)
-	if vr == nil {
-		obj.Data = nil
-	} else {
-		obj.Data = vr
-	}
+type escapeState struct {
+	fnc   *Func
+	depth int // number of loop bodies enclosing the current node
+	flows []*escapeFlow
+	sites []*escapeSite
 }
 
-// Purpose of walk:
-// - collect string literals
-// - collect method declarations
-// - collect global variables
-// - collect local variables and set offset
-// - determine struct size and field offset
-// - determine types of variable declarations
-// - attach type to universe nil
-// - transmit ok sytanx context
-// - (hope) attach type to untyped constants
-// - (hope) transmit the need of interface conversion
-func walk(pkg *PkgContainer) {
-	var typeSpecs []*ast.TypeSpec
-	var funcDecls []*ast.FuncDecl
-	var varSpecs []*ast.ValueSpec
-	var constSpecs []*ast.ValueSpec
+// escapeAnalysis decides where the allocations of fnc live and reserves frame storage for those which do not escape.
+// Without optimizations, only the variables are analysed, and all the allocations are on the heap.
+func escapeAnalysis(fnc *Func) {
+	es := &escapeState{fnc: fnc}
+	for _, vr := range fnc.Params {
+		vr.EscLevel = 0
+	}
+	for _, vr := range fnc.LocalVars {
+		vr.EscLevel = vr.LoopDepth
+	}
+	es.stmts(fnc.Stmts)
 
-	var exportedTpyes []*Type
-	//logf("grouping declarations by type\n")
-	for _, decl := range pkg.Decls {
-		switch dcl := decl.(type) {
-		case *ast.GenDecl:
-			specInterface := dcl.Specs[0]
-			switch spec := specInterface.(type) {
-			case *ast.TypeSpec:
-				typeSpecs = append(typeSpecs, spec)
-			case *ast.ValueSpec:
-				nameIdent := spec.Names[0]
-				switch nameIdent.Obj.Kind {
-				case ast.Var:
-					varSpecs = append(varSpecs, spec)
-				case ast.Con:
-					constSpecs = append(constSpecs, spec)
-				default:
-					panic("Unexpected")
-				}
+	changed := true
+	for changed {
+		changed = false
+		for _, fl := range es.flows {
+			if fl.to.EscLevel < fl.from.EscLevel {
+				fl.from.EscLevel = fl.to.EscLevel
+				changed = true
 			}
-		case *ast.FuncDecl:
-			funcDecls = append(funcDecls, dcl)
-		default:
-			panic("Unexpected")
 		}
 	}
 
-	//logf("checking typeSpecs...\n")
-	for _, typeSpec := range typeSpecs {
-		//@TODO check serializeType()'s *ast.Ident case
-		typeSpec.Name.Obj.Data = pkg.name // package to which the type belongs to
-		eType := &ast.Ident{
-			Obj: &ast.Object{
-				Kind: ast.Typ,
-				Decl: typeSpec,
-			},
+	var moved []*Variable
+	for _, site := range es.sites {
+		if site.variable != nil {
+			if site.vr.EscLevel < site.depth && site.variable.HeapAddr == nil {
+				if escapeDiag {
+					fmt.Fprintf(os.Stderr, "%s: moved to heap: %s\n", positionString(site.pos), site.variable.Name)
+				}
+				site.variable.HeapAddr = registerFrameSlot(fnc, tUintptr)
+				site.variable.AddrTaken = true
+				moved = append(moved, site.variable)
+			}
+			continue
 		}
-		t := e2t(eType)
-		t.PkgName = pkg.name
-		t.Name = typeSpec.Name.Name
-		exportedTpyes = append(exportedTpyes, t)
-		switch kind(t) {
-		case T_STRUCT:
-			structType := getUnderlyingType(t)
-			calcStructSizeAndSetFieldOffset(structType.E.(*ast.StructType))
+		if optLevel == 0 {
+			continue
+		}
+		if site.vr.EscLevel < site.depth {
+			if escapeDiag {
+				fmt.Fprintf(os.Stderr, "%s: %s escapes to heap\n", positionString(site.pos), site.desc)
+			}
+			continue
+		}
+		if escapeDiag {
+			fmt.Fprintf(os.Stderr, "%s: %s does not escape\n", positionString(site.pos), site.desc)
+		}
+		slot := registerFrameSlot(fnc, site.typ)
+		if site.lit != nil {
+			site.lit.stackSlot = slot
+		} else {
+			site.call.stackSlot = slot
 		}
-		ExportedQualifiedIdents[string(newQI(pkg.name, typeSpec.Name.Name))] = typeSpec.Name
 	}
+	insertHeapAllocs(fnc, moved)
+}
 
-	//logf("checking funcDecls...\n")
+// MetaHeapAlloc allocates the storage of variables moved to the heap.
+// A parameter is copied to its storage.
+type MetaHeapAlloc struct {
+	Vars []*Variable
+}
 
-	// collect methods in advance
-	for _, funcDecl := range funcDecls {
-		if funcDecl.Recv == nil { // non-method function
-			qi := newQI(pkg.name, funcDecl.Name.Name)
-			ExportedQualifiedIdents[string(qi)] = funcDecl.Name
-		} else { // is method
-			if funcDecl.Body != nil {
-				method := newMethod(pkg.name, funcDecl)
-				registerMethod(method)
+// insertHeapAllocs inserts the allocations of the moved variables at the start of the loop bodies declaring them,
+// or of the function.
+func insertHeapAllocs(fnc *Func, moved []*Variable) {
+	var loops []*MetaForContainer
+	var allocs []*MetaHeapAlloc
+	for _, vr := range moved {
+		var alloc *MetaHeapAlloc
+		for i, loop := range loops {
+			if loop == vr.Loop {
+				alloc = allocs[i]
 			}
 		}
+		if alloc == nil {
+			alloc = &MetaHeapAlloc{}
+			loops = append(loops, vr.Loop)
+			allocs = append(allocs, alloc)
+		}
+		alloc.Vars = append(alloc.Vars, vr)
+	}
+	for i, loop := range loops {
+		if loop == nil {
+			fnc.Stmts = prependStmt(allocs[i], fnc.Stmts)
+		} else {
+			loop.Body.List = prependStmt(allocs[i], loop.Body.List)
+		}
 	}
+}
 
-	//logf("walking constSpecs...\n")
+func prependStmt(stmt MetaStmt, list []MetaStmt) []MetaStmt {
+	var r []MetaStmt
+	r = append(r, stmt)
+	for _, st := range list {
+		r = append(r, st)
+	}
+	return r
+}
 
-	for _, constSpec := range constSpecs {
-		for _, v := range constSpec.Values {
-			walkExpr(v, nil) // @TODO: store meta
-		}
+// registerFrameSlot reserves a storage of type t in the frame of fnc.
+// It is aligned and its size is rounded up to words, so that it can be cleared word by word.
+func registerFrameSlot(fnc *Func, t *Type) *Variable {
+	for (-fnc.Localarea)%8 != 0 {
+		fnc.Localarea--
 	}
+	size := getSizeOfType(t)
+	fnc.Localarea = fnc.Localarea - (size+7)/8*8 + size
+	vr := registerLocalVariable(fnc, ".alloc", t)
+	vr.AddrTaken = true
+	return vr
+}
 
-	//logf("walking varSpecs...\n")
-	for _, spec := range varSpecs {
-		lhsIdent := spec.Names[0]
-		assert(lhsIdent.Obj.Kind == ast.Var, "should be Var", __func__)
-		var rhsMeta MetaExpr
-		var t *Type
-		if spec.Type != nil { // var x T = e
-			// walkExpr(spec.Type, nil) // Do we need walk type ?s
-			t = e2t(spec.Type)
-			if len(spec.Values) > 0 {
-				rhs := spec.Values[0]
-				ctx := &evalContext{_type: t}
-				rhsMeta = walkExpr(rhs, ctx)
-			}
-		} else { // var x = e  infer lhs type from rhs
-			if len(spec.Values) == 0 {
-				panic("invalid syntax")
-			}
+// flow records that the value of from is copied to to.
+func (es *escapeState) flow(from *Variable, to *Variable) {
+	if to == nil {
+		return
+	}
+	es.flows = append(es.flows, &escapeFlow{from: from, to: to})
+}
 
-			rhs := spec.Values[0]
-			rhsMeta = walkExpr(rhs, nil)
-			t = getTypeOfExpr(rhsMeta)
-			if t == nil {
-				panic("variable type is not determined : " + lhsIdent.Name)
-			}
-		}
-		spec.Type = t.E
+func (es *escapeState) site(pos token.Pos, desc string, typ *Type, to *Variable) *escapeSite {
+	site := &escapeSite{
+		pos:   pos,
+		desc:  desc,
+		typ:   typ,
+		vr:    &Variable{Name: ".site", EscLevel: es.depth},
+		depth: es.depth,
+	}
+	es.flow(site.vr, to)
+	es.sites = append(es.sites, site)
+	return site
+}
 
-		variable := newGlobalVariable(pkg.name, lhsIdent.Obj.Name, t)
-		setVariable(lhsIdent.Obj, variable)
-		metaVar := walkIdent(lhsIdent, nil)
+func (es *escapeState) stmts(stmts []MetaStmt) {
+	for _, stmt := range stmts {
+		es.stmt(stmt)
+	}
+}
 
-		var rhs ast.Expr
-		if len(spec.Values) > 0 {
-			rhs = spec.Values[0]
-			// collect string literals
+func (es *escapeState) stmt(stmt MetaStmt) {
+	if stmt == nil {
+		return
+	}
+	switch m := stmt.(type) {
+	case *MetaBlockStmt:
+		es.stmts(m.List)
+	case *MetaExprStmt:
+		es.expr(m.X, nil)
+	case *MetaVarDecl:
+		es.assign(m.Single.Lhs, m.Single.Rhs)
+	case *MetaSingleAssign:
+		es.assign(m.Lhs, m.Rhs)
+	case *MetaTupleAssign:
+		es.expr(m.Rhs, nil)
+		for _, lhs := range m.Lhss {
+			es.lhs(lhs)
 		}
-		pkgVar := &packageVar{
-			spec:    spec,
-			name:    lhsIdent,
-			val:     rhs,
-			metaVal: rhsMeta, // can be nil
-			metaVar: metaVar,
-			typ:     t,
+	case *MetaReturnStmt:
+		for _, result := range m.Results {
+			es.expr(result, escHeap)
 		}
-		pkg.vars = append(pkg.vars, pkgVar)
-		ExportedQualifiedIdents[string(newQI(pkg.name, lhsIdent.Name))] = lhsIdent
-	}
-
-	//logf("walking funcDecls in detail ...\n")
-	for _, funcDecl := range funcDecls {
-		//logf("[walk] (package:%s) (pos:%d) (%s) walking funcDecl \"%s\" \n",
-		//	pkg.name, int(funcDecl.Pos()), pkg.fset.Position(funcDecl.Pos()), funcDecl.Name.Name)
-		//logf("walking funcDecl \"%s\" \n", funcDecl.Name.Name)
-		fnc := &Func{
-			Name:      funcDecl.Name.Name,
-			FuncType:  funcDecl.Type,
-			Localarea: 0,
-			Argsarea:  16, // return address + previous rbp
+	case *MetaIfStmt:
+		es.stmt(m.Init)
+		es.expr(m.Cond, nil)
+		es.stmt(m.Body)
+		es.stmt(m.Else)
+	case *MetaForContainer:
+		if m.ForRangeStmt != nil {
+			es.expr(m.ForRangeStmt.X, escHeap)
+			es.lhs(m.ForRangeStmt.Key)
+			es.lhs(m.ForRangeStmt.Value)
+			es.depth++
+		} else {
+			es.stmt(m.ForStmt.Init)
+			es.depth++
+			es.expr(m.ForStmt.Cond, nil)
+			es.stmt(m.ForStmt.Post)
 		}
-		currentFunc = fnc
-
-		var paramFields []*ast.Field
-		var resultFields []*ast.Field
-
-		if funcDecl.Recv != nil { // Method
-			paramFields = append(paramFields, funcDecl.Recv.List[0])
+		es.stmt(m.Body)
+		es.depth--
+	case *MetaBranchStmt:
+	case *MetaSwitchStmt:
+		es.stmt(m.Init)
+		es.expr(m.Tag, nil)
+		for _, cc := range m.cases {
+			for _, e := range cc.ListMeta {
+				es.expr(e, nil)
+			}
+			es.stmts(cc.Body)
 		}
-		for _, field := range funcDecl.Type.Params.List {
-			paramFields = append(paramFields, field)
+	case *MetaTypeSwitchStmt:
+		es.expr(m.Subject, nil)
+		for _, cc := range m.Cases {
+			es.stmts(cc.Body)
 		}
+	case *MetaGoStmt:
+		es.expr(m.fun, escHeap)
+		es.site(m.Pos, "go statement", nil, escHeap)
+	default:
+		throw(stmt)
+	}
+}
 
-		if funcDecl.Type.Results != nil {
-			for _, field := range funcDecl.Type.Results.List {
-				resultFields = append(resultFields, field)
+func (es *escapeState) assign(lhs MetaExpr, rhs MetaExpr) {
+	if rhs == nil {
+		es.lhs(lhs)
+		return
+	}
+	if isBlankIdentifierMeta(lhs) {
+		es.expr(rhs, nil)
+		return
+	}
+	ident, isIdent := lhs.(*MetaIdent)
+	if isIdent && ident.kind == "var" && !ident.variable.IsGlobal && kind(ident.variable.Typ) == T_POINTER {
+		es.expr(rhs, ident.variable)
+		return
+	}
+	es.lhs(lhs)
+	es.expr(rhs, escHeap)
+}
+
+// lhs visits the operands of a location which is stored to.
+func (es *escapeState) lhs(lhs MetaExpr) {
+	if lhs == nil {
+		return
+	}
+	switch m := lhs.(type) {
+	case *MetaIdent:
+	case *MetaSelectorExpr:
+		if m.X != nil {
+			if kind(getTypeOfExpr(m.X)) == T_POINTER {
+				es.expr(m.X, nil)
+			} else {
+				es.lhs(m.X)
 			}
 		}
+	case *MetaIndexExpr:
+		switch kind(getTypeOfExpr(m.X)) {
+		case T_MAP:
+			es.expr(m.X, nil)
+			es.expr(m.Index, escHeap)
+		case T_ARRAY:
+			es.lhs(m.X)
+			es.expr(m.Index, nil)
+		default:
+			es.expr(m.X, nil)
+			es.expr(m.Index, nil)
+		}
+	case *MetaStarExpr:
+		es.expr(m.X, nil)
+	default:
+		es.expr(lhs, nil)
+	}
+}
 
-		for _, field := range paramFields {
-			obj := field.Names[0].Obj
-			setVariable(obj, registerParamVariable(fnc, obj.Name, e2t(field.Type)))
+// addr visits an expression whose address flows to to.
+func (es *escapeState) addr(meta MetaExpr, to *Variable) {
+	switch m := meta.(type) {
+	case *MetaIdent:
+		if m.kind == "var" && !m.variable.IsGlobal && to != nil {
+			es.flow(m.variable, escHeap)
+			site := es.site(declPos(m.e.Obj, irExprPos(m)), "&"+m.Name, m.variable.Typ, to)
+			site.vr.EscLevel = m.variable.LoopDepth
+			site.depth = m.variable.LoopDepth
+			site.variable = m.variable
 		}
-
-		for i, field := range resultFields {
-			if len(field.Names) == 0 {
-				// unnamed retval
-				registerReturnVariable(fnc, ".r"+strconv.Itoa(i), e2t(field.Type))
+	case *MetaSelectorExpr:
+		if m.X != nil {
+			if kind(getTypeOfExpr(m.X)) == T_POINTER {
+				es.expr(m.X, to)
 			} else {
-				panic("TBI: named return variable is not supported")
+				es.addr(m.X, to)
 			}
 		}
+	case *MetaIndexExpr:
+		switch kind(getTypeOfExpr(m.X)) {
+		case T_MAP:
+			es.expr(m.X, nil)
+			es.expr(m.Index, escHeap)
+		case T_ARRAY:
+			es.addr(m.X, to)
+			es.expr(m.Index, nil)
+		case T_POINTER:
+			es.expr(m.X, to)
+			es.expr(m.Index, nil)
+		default:
+			es.expr(m.X, nil)
+			es.expr(m.Index, nil)
+		}
+	case *MetaStarExpr:
+		es.expr(m.X, to)
+	case *MetaCompositLit:
+		es.lit(m, irExprPos(m), to)
+	default:
+		es.expr(meta, to)
+	}
+}
 
-		if funcDecl.Body != nil {
-			var ms []MetaStmt
-			for _, stmt := range funcDecl.Body.List {
-				m := walkStmt(stmt)
-				ms = append(ms, m)
-			}
-			fnc.Stmts = ms
+// arg visits an argument of a call.
+// A pointer converted to uintptr by the argument does not escape, as a system call argument,
+// whose storage is used only until the call returns.
+func (es *escapeState) arg(meta MetaExpr) {
+	conv, isCall := meta.(*MetaCallExpr)
+	if isCall && conv.isConversion && kind(conv.toType) == T_UINTPTR {
+		es.expr(conv.arg0, nil)
+		return
+	}
+	es.expr(meta, escHeap)
+}
 
-			if funcDecl.Recv != nil { // is Method
-				fnc.Method = newMethod(pkg.name, funcDecl)
+// declPos returns the position of the name of the variable obj in its declaration, or pos if it is not found.
+func declPos(obj *ast.Object, pos token.Pos) token.Pos {
+	if obj == nil {
+		return pos
+	}
+	var names []*ast.Ident
+	switch decl := obj.Decl.(type) {
+	case *ast.AssignStmt:
+		for _, lhs := range decl.Lhs {
+			ident, isIdent := lhs.(*ast.Ident)
+			if isIdent {
+				names = append(names, ident)
 			}
-			pkg.funcs = append(pkg.funcs, fnc)
 		}
-		currentFunc = nil
+	case *ast.ValueSpec:
+		names = decl.Names
+	case *ast.Field:
+		names = decl.Names
+	}
+	for _, ident := range names {
+		if ident.Obj == obj {
+			return ident.NamePos
+		}
 	}
+	return pos
+}
 
-	printf("# Package types:\n")
-	for _, typ := range exportedTpyes {
-		printf("# type %s %s\n", serializeType(typ), serializeType(getUnderlyingType(typ)))
+// lit visits a composite literal whose address is taken.
+func (es *escapeState) lit(m *MetaCompositLit, pos token.Pos, to *Variable) {
+	es.elements(m)
+	site := es.site(pos, "&"+serializeType(m.typ)+"{...}", m.typ, to)
+	site.lit = m
+}
+
+func (es *escapeState) elements(m *MetaCompositLit) {
+	for _, elm := range m.strctEements {
+		es.expr(elm.ValueMeta, escHeap)
+	}
+	for _, elm := range m.metaElms {
+		es.expr(elm, escHeap)
+	}
+}
+
+// expr visits an expression whose value flows to to. to is nil if the value is not kept.
+func (es *escapeState) expr(meta MetaExpr, to *Variable) {
+	if meta == nil {
+		return
+	}
+	switch m := meta.(type) {
+	case *MetaBasicLit:
+	case *MetaCompositLit:
+		es.elements(m)
+		if optLevel > 0 && (m.kind == "struct" || m.kind == "array") {
+			m.stackSlot = registerFrameSlot(es.fnc, m.typ)
+		}
+	case *MetaIdent:
+		if m.kind == "var" && !m.variable.IsGlobal {
+			es.flow(m.variable, to)
+		}
+	case *MetaSelectorExpr:
+		es.expr(m.X, nil)
+	case *MetaCallExpr:
+		if m.isConversion {
+			if kind(m.toType) == T_POINTER {
+				es.expr(m.arg0, to)
+			} else {
+				es.expr(m.arg0, escHeap)
+			}
+			return
+		}
+		switch m.builtin {
+		case gNew:
+			site := es.site(m.e.Fun.(*ast.Ident).NamePos, "new("+serializeType(m.typeArg0)+")", m.typeArg0, to)
+			site.call = m
+			return
+		case gLen, gCap:
+			es.expr(m.arg0, nil)
+			return
+		}
+		es.expr(m.arg0, escHeap)
+		es.expr(m.arg1, escHeap)
+		es.expr(m.arg2, escHeap)
+		if m.builtin == nil && !m.funcVal.isDirect {
+			es.expr(m.funcVal.expr, nil)
+		}
+		for _, arg := range m.metaArgs {
+			es.arg(arg.meta)
+		}
+	case *MetaIndexExpr:
+		es.expr(m.X, nil)
+		es.expr(m.Index, nil)
+	case *MetaSliceExpr:
+		if kind(getTypeOfExpr(m.X)) == T_ARRAY {
+			es.addr(m.X, escHeap)
+		} else {
+			es.expr(m.X, escHeap)
+		}
+		es.expr(m.Low, nil)
+		es.expr(m.High, nil)
+		es.expr(m.Max, nil)
+	case *MetaStarExpr:
+		es.expr(m.X, nil)
+	case *MetaUnaryExpr:
+		lit, isLit := m.X.(*MetaCompositLit)
+		if m.e.Op.String() != "&" {
+			es.expr(m.X, nil)
+		} else if isLit {
+			es.lit(lit, m.e.OpPos, to)
+		} else {
+			es.addr(m.X, to)
+		}
+	case *MetaBinaryExpr:
+		es.expr(m.X, nil)
+		es.expr(m.Y, nil)
+	case *MetaTypeAssertExpr:
+		es.expr(m.X, nil)
+	case *MetaInlineCall:
+		for _, param := range m.params {
+			es.assign(param.Lhs, param.Rhs)
+		}
+		es.expr(m.result, to)
+	default:
+		throw(meta)
 	}
 }
 
@@ -4990,57 +8937,679 @@ var tInt32 *Type = &Type{
 	},
 }
 
-var tUintptr *Type = &Type{
-	E: &ast.Ident{
-		Name: "uintptr",
-		Obj:  gUintptr,
-	},
-}
-var tUint8 *Type = &Type{
-	E: &ast.Ident{
-		Name: "uint8",
-		Obj:  gUint8,
-	},
+var tUintptr *Type = &Type{
+	E: &ast.Ident{
+		Name: "uintptr",
+		Obj:  gUintptr,
+	},
+}
+var tUint8 *Type = &Type{
+	E: &ast.Ident{
+		Name: "uint8",
+		Obj:  gUint8,
+	},
+}
+
+var tUint16 *Type = &Type{
+	E: &ast.Ident{
+		Name: "uint16",
+		Obj:  gUint16,
+	},
+}
+var tString *Type = &Type{
+	E: &ast.Ident{
+		Name: "string",
+		Obj:  gString,
+	},
+}
+
+var tEface *Type = &Type{
+	E: &ast.InterfaceType{},
+}
+
+var generalSlice ast.Expr = &ast.Ident{}
+
+func createUniverse() *ast.Scope {
+	universe := ast.NewScope(nil)
+	objects := []*ast.Object{
+		gNil,
+		// constants
+		gTrue, gFalse,
+		// types
+		gString, gUintptr, gBool, gInt, gUint8, gUint16, gInt32, gError,
+		// funcs
+		gNew, gMake, gAppend, gLen, gCap, gPanic, gDelete,
+	}
+	for _, obj := range objects {
+		universe.Insert(obj)
+	}
+
+	// setting aliases
+	universe.Objects["byte"] = gUint8
+
+	return universe
+}
+
+// --- IR dump ---
+// The Meta tree of a package is written either as json or as indented text while it is walked.
+// Each node is written as soon as it is visited, attributes first, so that no tree is built in memory.
+var dumpIRFormat string // "json" or "text". Empty means no dump.
+
+// irWriter writes the nodes of the IR to a buffered file.
+// The attributes of a node must be written before its children.
+type irWriter struct {
+	out    *bufio.Writer
+	json   bool
+	frames []*irFrame // the open nodes and lists, innermost last
+}
+
+// irFrame is an open node, or an open list of nodes.
+type irFrame struct {
+	isList bool
+	depth  int
+	key    string // key of a list
+	count  int    // number of children written
+}
+
+func (w *irWriter) write(s string) {
+	w.out.WriteString(s)
+}
+
+func (w *irWriter) writeIndent(depth int) {
+	for i := 0; i < depth; i++ {
+		w.write("  ")
+	}
+}
+
+func (w *irWriter) top() *irFrame {
+	if len(w.frames) == 0 {
+		return nil
+	}
+	return w.frames[len(w.frames)-1]
+}
+
+func (w *irWriter) push(fr *irFrame) {
+	w.frames = append(w.frames, fr)
+}
+
+func (w *irWriter) pop() {
+	w.frames = w.frames[:len(w.frames)-1]
+}
+
+// startChild ends the header of the node whose child is about to be written.
+func (w *irWriter) startChild(parent *irFrame) {
+	if !w.json && parent.count == 0 {
+		w.write("\n")
+	}
+	parent.count++
+}
+
+// begin opens a node of the given kind, which is the child key of the enclosing node, or an element of the enclosing list.
+func (w *irWriter) begin(key string, kind string) {
+	depth := 0
+	parent := w.top()
+	if parent != nil && parent.isList {
+		if parent.count == 0 {
+			if w.json {
+				w.write("[\n")
+			} else {
+				w.writeIndent(parent.depth)
+				w.write(parent.key + ":\n")
+			}
+		} else if w.json {
+			w.write(",\n")
+		}
+		parent.count++
+		depth = parent.depth + 1
+		w.writeIndent(depth)
+	} else if parent != nil {
+		w.startChild(parent)
+		depth = parent.depth + 1
+		if w.json {
+			w.write(",\n")
+			w.writeIndent(depth)
+			w.write(jsonQuote(key) + ": ")
+		} else {
+			w.writeIndent(depth)
+			w.write(key + ": ")
+		}
+	}
+	if w.json {
+		w.write("{\n")
+		w.writeIndent(depth + 1)
+		w.write("\"kind\": " + jsonQuote(kind))
+	} else {
+		w.write(kind)
+	}
+	w.push(&irFrame{depth: depth})
+}
+
+// end closes the current node.
+func (w *irWriter) end() {
+	fr := w.top()
+	w.pop()
+	if w.json {
+		w.write("\n")
+		w.writeIndent(fr.depth)
+		w.write("}")
+	} else if fr.count == 0 {
+		w.write("\n")
+	}
+}
+
+func (w *irWriter) rawAttr(key string, value string) {
+	if w.json {
+		w.write(",\n")
+		w.writeIndent(w.top().depth + 1)
+		w.write(jsonQuote(key) + ": " + value)
+	} else {
+		w.write(" " + key + "=" + value)
+	}
+}
+
+func (w *irWriter) attr(key string, value string) {
+	if w.json {
+		w.rawAttr(key, jsonQuote(value))
+	} else {
+		w.rawAttr(key, value)
+	}
+}
+
+func (w *irWriter) intAttr(key string, value int) {
+	w.rawAttr(key, strconv.Itoa(value))
+}
+
+func (w *irWriter) boolAttr(key string, value bool) {
+	v := "false"
+	if value {
+		v = "true"
+	}
+	w.rawAttr(key, v)
+}
+
+func (w *irWriter) posAttr(pos token.Pos) {
+	if pos.IsValid() {
+		w.attr("pos", positionString(pos))
+	}
+}
+
+// beginList opens a list of nodes under key. An empty list is left out of the text format.
+func (w *irWriter) beginList(key string) {
+	parent := w.top()
+	w.startChild(parent)
+	if w.json {
+		w.write(",\n")
+		w.writeIndent(parent.depth + 1)
+		w.write(jsonQuote(key) + ": ")
+	}
+	w.push(&irFrame{isList: true, depth: parent.depth + 1, key: key})
+}
+
+func (w *irWriter) endList() {
+	fr := w.top()
+	w.pop()
+	if !w.json {
+		return
+	}
+	if fr.count == 0 {
+		w.write("[]")
+		return
+	}
+	w.write("\n")
+	w.writeIndent(fr.depth)
+	w.write("]")
+}
+
+// positionString returns pos in the form "file:line:column".
+func positionString(pos token.Pos) string {
+	position := fset.Position(pos)
+	return fmt.Sprintf("%s:%d:%d", position.Filename, position.Line, position.Column)
+}
+
+func irType(t *Type) string {
+	if t == nil {
+		return ""
+	}
+	if t.E == generalSlice {
+		return "[]interface{}"
+	}
+	ellipsis, isEllipsis := t.E.(*ast.Ellipsis)
+	if isEllipsis {
+		return "..." + serializeType(e2t(ellipsis.Elt))
+	}
+	return serializeType(t)
+}
+
+func irTupleType(types []*Type) string {
+	r := "("
+	for i, t := range types {
+		if i > 0 {
+			r += ", "
+		}
+		r += irType(t)
+	}
+	return r + ")"
+}
+
+func irVariable(w *irWriter, key string, vr *Variable) {
+	if vr == nil {
+		return
+	}
+	w.begin(key, "Variable")
+	w.attr("name", vr.Name)
+	w.attr("type", irType(vr.Typ))
+	if vr.IsGlobal {
+		w.attr("symbol", vr.GlobalSymbol)
+	} else {
+		w.intAttr("offset", vr.LocalOffset)
+	}
+	if vr.HeapAddr != nil {
+		w.intAttr("heapaddr", vr.HeapAddr.LocalOffset)
+	}
+	w.end()
+}
+
+func irVariables(w *irWriter, key string, vars []*Variable) {
+	w.beginList(key)
+	for _, vr := range vars {
+		irVariable(w, "", vr)
+	}
+	w.endList()
+}
+
+func irPackage(w *irWriter, pkg *PkgContainer) {
+	w.begin("", "Package")
+	w.attr("path", pkg.path)
+	w.attr("name", pkg.name)
+	w.beginList("vars")
+	for _, vr := range pkg.vars {
+		w.begin("", "PackageVar")
+		w.attr("name", vr.name.Name)
+		w.attr("type", irType(vr.typ))
+		w.posAttr(vr.name.NamePos)
+		irExpr(w, "value", vr.metaVal)
+		w.end()
+	}
+	w.endList()
+	w.beginList("funcs")
+	for _, fnc := range pkg.funcs {
+		irFunc(w, pkg.name, fnc)
+	}
+	w.endList()
+	w.end()
+}
+
+func irFunc(w *irWriter, pkgName string, fnc *Func) {
+	w.begin("", "Func")
+	if fnc.Method != nil {
+		w.attr("symbol", getMethodSymbol(fnc.Method))
+	} else {
+		w.attr("symbol", getPackageSymbol(pkgName, fnc.Name))
+	}
+	w.posAttr(fnc.Pos)
+	w.intAttr("argsarea", fnc.Argsarea)
+	w.intAttr("localarea", fnc.Localarea)
+	irVariables(w, "params", fnc.Params)
+	irVariables(w, "results", fnc.Retvars)
+	irVariables(w, "locals", fnc.LocalVars)
+	irStmts(w, "body", fnc.Stmts)
+	w.end()
+}
+
+func irStmts(w *irWriter, key string, stmts []MetaStmt) {
+	w.beginList(key)
+	for _, stmt := range stmts {
+		irStmt(w, "", stmt)
+	}
+	w.endList()
+}
+
+func irStmt(w *irWriter, key string, stmt MetaStmt) {
+	if stmt == nil {
+		return
+	}
+	switch m := stmt.(type) {
+	case *MetaBlockStmt:
+		w.begin(key, "Block")
+		w.posAttr(m.Pos)
+		irStmts(w, "list", m.List)
+	case *MetaExprStmt:
+		w.begin(key, "ExprStmt")
+		w.posAttr(irExprPos(m.X))
+		irExpr(w, "x", m.X)
+	case *MetaVarDecl:
+		w.begin(key, "VarDecl")
+		w.posAttr(m.Pos)
+		w.attr("type", irType(m.LhsType))
+		irExpr(w, "lhs", m.Single.Lhs)
+		irExpr(w, "rhs", m.Single.Rhs)
+	case *MetaSingleAssign:
+		w.begin(key, "SingleAssign")
+		w.posAttr(m.Pos)
+		irExpr(w, "lhs", m.Lhs)
+		irExpr(w, "rhs", m.Rhs)
+	case *MetaTupleAssign:
+		w.begin(key, "TupleAssign")
+		w.posAttr(m.Pos)
+		w.boolAttr("isOK", m.isOK)
+		w.attr("rhsTypes", irTupleType(m.RhsTypes))
+		irExprs(w, "lhs", m.Lhss)
+		irExpr(w, "rhs", m.Rhs)
+	case *MetaReturnStmt:
+		w.begin(key, "Return")
+		w.posAttr(m.Pos)
+		irExprs(w, "results", m.Results)
+	case *MetaIfStmt:
+		w.begin(key, "If")
+		w.posAttr(m.Pos)
+		irStmt(w, "init", m.Init)
+		irExpr(w, "cond", m.Cond)
+		irStmt(w, "body", m.Body)
+		irStmt(w, "else", m.Else)
+	case *MetaForContainer:
+		if m.ForRangeStmt != nil {
+			r := m.ForRangeStmt
+			w.begin(key, "Range")
+			w.posAttr(m.Pos)
+			w.boolAttr("isMap", r.IsMap)
+			irExpr(w, "x", r.X)
+			irExpr(w, "key", r.Key)
+			irExpr(w, "value", r.Value)
+			irVariable(w, "lenVar", r.LenVar)
+			irVariable(w, "indexVar", r.Indexvar)
+			irVariable(w, "mapVar", r.MapVar)
+			irVariable(w, "itemVar", r.ItemVar)
+		} else {
+			w.begin(key, "For")
+			w.posAttr(m.Pos)
+			irStmt(w, "init", m.ForStmt.Init)
+			irExpr(w, "cond", m.ForStmt.Cond)
+			irStmt(w, "post", m.ForStmt.Post)
+		}
+		irStmt(w, "body", m.Body)
+	case *MetaBranchStmt:
+		w.begin(key, "Branch")
+		w.posAttr(m.Pos)
+		if m.ContinueOrBreak == 1 {
+			w.attr("tok", "continue")
+		} else {
+			w.attr("tok", "break")
+		}
+	case *MetaSwitchStmt:
+		w.begin(key, "Switch")
+		w.posAttr(m.Pos)
+		irStmt(w, "init", m.Init)
+		irExpr(w, "tag", m.Tag)
+		w.beginList("cases")
+		for _, cc := range m.cases {
+			w.begin("", "CaseClause")
+			irExprs(w, "list", cc.ListMeta)
+			irStmts(w, "body", cc.Body)
+			w.end()
+		}
+		w.endList()
+	case *MetaTypeSwitchStmt:
+		w.begin(key, "TypeSwitch")
+		w.posAttr(m.Pos)
+		irExpr(w, "subject", m.Subject)
+		irVariable(w, "subjectVar", m.SubjectVariable)
+		w.beginList("cases")
+		for _, cc := range m.Cases {
+			w.begin("", "TypeCaseClause")
+			if len(cc.types) > 0 {
+				w.attr("types", irTupleType(cc.types))
+			}
+			irVariable(w, "variable", cc.Variable)
+			irStmts(w, "body", cc.Body)
+			w.end()
+		}
+		w.endList()
+	case *MetaGoStmt:
+		w.begin(key, "Go")
+		w.posAttr(m.Pos)
+		irExpr(w, "fun", m.fun)
+	case *MetaHeapAlloc:
+		w.begin(key, "HeapAlloc")
+		irVariables(w, "vars", m.Vars)
+	default:
+		throw(stmt)
+	}
+	w.end()
+}
+
+func irExprs(w *irWriter, key string, exprs []MetaExpr) {
+	w.beginList(key)
+	for _, expr := range exprs {
+		irExpr(w, "", expr)
+	}
+	w.endList()
 }
 
-var tUint16 *Type = &Type{
-	E: &ast.Ident{
-		Name: "uint16",
-		Obj:  gUint16,
-	},
-}
-var tString *Type = &Type{
-	E: &ast.Ident{
-		Name: "string",
-		Obj:  gString,
-	},
+// irExprPos returns the position of the operator or opening token of an expression.
+func irExprPos(expr MetaExpr) token.Pos {
+	switch m := expr.(type) {
+	case *MetaCompositLit:
+		return m.e.Lbrace
+	case *MetaIdent:
+		return m.e.NamePos
+	case *MetaSelectorExpr:
+		return m.e.Sel.NamePos
+	case *MetaCallExpr:
+		return m.e.Lparen
+	case *MetaIndexExpr:
+		return m.e.Lbrack
+	case *MetaSliceExpr:
+		return m.e.Lbrack
+	case *MetaStarExpr:
+		return m.e.Star
+	case *MetaUnaryExpr:
+		return m.e.OpPos
+	case *MetaBinaryExpr:
+		return m.e.OpPos
+	case *MetaTypeAssertExpr:
+		return m.e.Lparen
+	case *MetaInlineCall:
+		return m.e.Lparen
+	}
+	return token.NoPos
+}
+
+// irExprCommonAttrs writes the type and the position of an expression, which follow its own attributes.
+func irExprCommonAttrs(w *irWriter, expr MetaExpr) {
+	var typ *Type
+	sel, isSelector := expr.(*MetaSelectorExpr)
+	if isSelector {
+		// the type of a selector cannot be recomputed from the AST after walk
+		// because variables of type switch clauses are already unset.
+		typ = sel.typ
+		if sel.foreign != nil {
+			typ = getTypeOfExpr(sel.foreign)
+		} else if isQI(sel.e) {
+			typ = getTypeOfExpr(sel) // pkg.Func
+		}
+	} else {
+		typ = getTypeOfExpr(expr)
+	}
+	if typ != nil {
+		w.attr("type", irType(typ))
+	}
+	w.posAttr(irExprPos(expr))
 }
 
-var tEface *Type = &Type{
-	E: &ast.InterfaceType{},
+func irExpr(w *irWriter, key string, expr MetaExpr) {
+	if expr == nil {
+		return
+	}
+	switch m := expr.(type) {
+	case *MetaBasicLit:
+		w.begin(key, "BasicLit")
+		w.attr("litKind", m.Kind)
+		w.attr("value", m.Value)
+		irExprCommonAttrs(w, expr)
+	case *MetaCompositLit:
+		w.begin(key, "CompositeLit")
+		w.attr("litKind", m.kind)
+		if m.kind == "struct" {
+			irExprCommonAttrs(w, expr)
+			w.beginList("elts")
+			for _, elm := range m.strctEements {
+				w.begin("", "Element")
+				w.attr("field", elm.field.Names[0].Name)
+				irExpr(w, "value", elm.ValueMeta)
+				w.end()
+			}
+			w.endList()
+		} else {
+			w.intAttr("len", m.len)
+			irExprCommonAttrs(w, expr)
+			irExprs(w, "elts", m.metaElms)
+		}
+	case *MetaIdent:
+		w.begin(key, "Ident")
+		w.attr("name", m.Name)
+		w.attr("identKind", m.kind)
+		irExprCommonAttrs(w, expr)
+		irVariable(w, "variable", m.variable)
+		if m.conLiteral != nil {
+			irExpr(w, "const", m.conLiteral)
+		}
+	case *MetaSelectorExpr:
+		w.begin(key, "Selector")
+		if isQI(m.e) {
+			// pkg.Var, pkg.Const or pkg.Func
+			w.attr("qualified", string(selector2QI(m.e)))
+			irExprCommonAttrs(w, expr)
+			irExpr(w, "foreign", m.foreign)
+		} else {
+			w.attr("sel", m.e.Sel.Name)
+			irExprCommonAttrs(w, expr)
+			irExpr(w, "x", m.X)
+		}
+	case *MetaCallExpr:
+		w.begin(key, "Call")
+		if m.isConversion {
+			w.attr("conversion", irType(m.toType))
+		} else if m.builtin != nil {
+			w.attr("builtin", m.builtin.Name)
+			if m.typeArg0 != nil {
+				w.attr("typeArg", irType(m.typeArg0))
+			}
+		} else if m.funcVal.isDirect {
+			w.attr("func", m.funcVal.symbol)
+		}
+		if m.typ == nil && len(m.types) > 1 {
+			w.attr("types", irTupleType(m.types))
+		}
+		irExprCommonAttrs(w, expr)
+		if !m.isConversion && m.builtin == nil && !m.funcVal.isDirect {
+			irExpr(w, "func", m.funcVal.expr)
+		}
+		irExpr(w, "arg0", m.arg0)
+		irExpr(w, "arg1", m.arg1)
+		irExpr(w, "arg2", m.arg2)
+		if len(m.metaArgs) > 0 {
+			w.beginList("args")
+			for _, arg := range m.metaArgs {
+				irExpr(w, "", arg.meta)
+			}
+			w.endList()
+		}
+	case *MetaIndexExpr:
+		w.begin(key, "Index")
+		w.boolAttr("isMap", m.IsMap)
+		w.boolAttr("needsOK", m.NeedsOK)
+		irExprCommonAttrs(w, expr)
+		irExpr(w, "x", m.X)
+		irExpr(w, "index", m.Index)
+	case *MetaSliceExpr:
+		w.begin(key, "Slice")
+		irExprCommonAttrs(w, expr)
+		irExpr(w, "x", m.X)
+		irExpr(w, "low", m.Low)
+		irExpr(w, "high", m.High)
+		irExpr(w, "max", m.Max)
+	case *MetaStarExpr:
+		w.begin(key, "Star")
+		irExprCommonAttrs(w, expr)
+		irExpr(w, "x", m.X)
+	case *MetaUnaryExpr:
+		w.begin(key, "Unary")
+		w.attr("op", m.e.Op.String())
+		irExprCommonAttrs(w, expr)
+		irExpr(w, "x", m.X)
+	case *MetaBinaryExpr:
+		w.begin(key, "Binary")
+		w.attr("op", m.Op)
+		irExprCommonAttrs(w, expr)
+		irExpr(w, "x", m.X)
+		irExpr(w, "y", m.Y)
+	case *MetaTypeAssertExpr:
+		w.begin(key, "TypeAssert")
+		w.boolAttr("needsOK", m.NeedsOK)
+		irExprCommonAttrs(w, expr)
+		irExpr(w, "x", m.X)
+	case *MetaInlineCall:
+		w.begin(key, "InlineCall")
+		w.attr("func", m.name)
+		irExprCommonAttrs(w, expr)
+		w.beginList("params")
+		for _, param := range m.params {
+			irStmt(w, "", param)
+		}
+		w.endList()
+		irExpr(w, "result", m.result)
+	default:
+		throw(expr)
+	}
+	w.end()
+}
+
+func jsonQuote(s string) string {
+
+	var buf []byte
+	buf = append(buf, '"')
+	for _, ch := range []byte(s) {
+		if ch == '"' || ch == '\\' {
+			buf = append(buf, '\\')
+			buf = append(buf, ch)
+		} else if ch == '\n' {
+			buf = append(buf, '\\')
+			buf = append(buf, 'n')
+		} else if ch == '\t' {
+			buf = append(buf, '\\')
+			buf = append(buf, 't')
+		} else if ch < 32 {
+			hex := "0123456789abcdef"
+			buf = append(buf, '\\')
+			buf = append(buf, 'u')
+			buf = append(buf, '0')
+			buf = append(buf, '0')
+			buf = append(buf, hex[ch/16])
+			buf = append(buf, hex[ch%16])
+		} else {
+			buf = append(buf, ch)
+		}
+	}
+	buf = append(buf, '"')
+	return string(buf)
 }
 
-var generalSlice ast.Expr = &ast.Ident{}
-
-func createUniverse() *ast.Scope {
-	universe := ast.NewScope(nil)
-	objects := []*ast.Object{
-		gNil,
-		// constants
-		gTrue, gFalse,
-		// types
-		gString, gUintptr, gBool, gInt, gUint8, gUint16, gInt32, gError,
-		// funcs
-		gNew, gMake, gAppend, gLen, gCap, gPanic, gDelete,
+// dumpIR writes the Meta tree of pkg to outFilePath in the format given by -dump-ir.
+func dumpIR(pkg *PkgContainer, outFilePath string) {
+	f, err := os.Create(outFilePath)
+	if err != nil {
+		panic(err)
 	}
-	for _, obj := range objects {
-		universe.Insert(obj)
+	w := &irWriter{out: bufio.NewWriter(f), json: dumpIRFormat == "json"}
+	irPackage(w, pkg)
+	if w.json {
+		w.write("\n")
 	}
-
-	// setting aliases
-	universe.Objects["byte"] = gUint8
-
-	return universe
+	err = w.out.Flush()
+	if err != nil {
+		panic(err)
+	}
+	f.Close()
 }
 
 // --- builder ---
@@ -5062,15 +9631,26 @@ type packageVar struct {
 }
 
 type PkgContainer struct {
-	path           string
-	name           string
-	astFiles       []*ast.File
-	vars           []*packageVar
-	funcs          []*Func
-	stringLiterals []*sliteral
-	stringIndex    int
-	Decls          []ast.Decl
-	fset           *token.FileSet
+	path                string
+	name                string
+	astFiles            []*ast.File
+	vars                []*packageVar
+	funcs               []*Func
+	stringLiterals      []*sliteral
+	stringIndex         int
+	runtimeStrings      []string          // strings read by the runtime, indexed by the number of their label
+	runtimeStringLabels map[string]string // label of each runtime string
+	funcInfos           []*funcInfo       // functions in the call site table
+	callSites           []*callSite       // calls whose return address is in the call site table
+	lineMarks           int               // number of statement labels of the function table
+	funcRefs            []string          // functions referenced by package-level initializers
+	Decls               []ast.Decl
+	typeSpecs           []*ast.TypeSpec
+	consts              []*ast.ValueSpec
+	funcDecls           []*ast.FuncDecl
+	imports             []string // import paths
+	fset                *token.FileSet
+	fromExportData      bool // declarations only, loaded from the build cache
 }
 
 func resolveImports(file *ast.File) {
@@ -5079,8 +9659,11 @@ func resolveImports(file *ast.File) {
 		// unwrap double quote "..."
 		rawValue := imprt.Path.Value
 		pth := rawValue[1 : len(rawValue)-1]
-		base := path.Base(pth)
-		mapImports[base] = true
+		name, ok := pkgNameByPath[pth]
+		if !ok {
+			name = path.Base(pth)
+		}
+		mapImports[name] = true
 	}
 	for _, ident := range file.Unresolved {
 		// lookup imported package name
@@ -5095,20 +9678,312 @@ func resolveImports(file *ast.File) {
 }
 
 // "some/dir" => []string{"a.go", "b.go"}
+// findFilesInDir returns the names of the .go and .s files in dir that belong to the build.
 func findFilesInDir(dir string) []string {
 	dirents, _ := mylib.Readdirnames(dir)
 	var r []string
 	for _, dirent := range dirents {
-		if dirent == "_.s" {
-			continue
-		}
 		if strings.HasSuffix(dirent, ".go") || strings.HasSuffix(dirent, ".s") {
-			r = append(r, dirent)
+			if matchFile(dir, dirent) {
+				r = append(r, dirent)
+			}
 		}
 	}
 	return r
 }
 
+// --- build constraints ---
+
+// value of the -tags flag
+var buildTagsFlag string
+
+// tags satisfied by the current build
+var buildTags map[string]bool
+
+// cache of matchFile results by file path
+var matchFileCache map[string]bool
+
+// initBuildTags sets up the tag set from the target platform and a comma separated list of extra tags.
+func initBuildTags(extraTags string) {
+	buildTags = make(map[string]bool)
+	buildTags["linux"] = true
+	buildTags["amd64"] = true
+	buildTags["unix"] = true
+	buildTags["babygo"] = true
+	if extraTags != "" {
+		for _, tag := range strings.Split(extraTags, ",") {
+			if tag != "" {
+				buildTags[tag] = true
+			}
+		}
+	}
+	matchFileCache = make(map[string]bool)
+}
+
+// parseTagsFlag handles "-tags=a,b" and "-tags a,b" in args[i].
+// It returns the index of the last argument consumed, or -1 if args[i] is not a -tags flag.
+func parseTagsFlag(args []string, i int) int {
+	arg := args[i]
+	if strings.HasPrefix(arg, "-tags=") {
+		buildTagsFlag = arg[len("-tags="):]
+		return i
+	}
+	if arg == "-tags" {
+		if i+1 >= len(args) {
+			panic("flag needs an argument: -tags")
+		}
+		buildTagsFlag = args[i+1]
+		return i + 1
+	}
+	return -1
+}
+
+// matchFile reports whether the file name in dir belongs to the build.
+// Like the go command, it ignores files whose name starts with "_" or ".",
+// _test.go files, files with a non-matching _GOOS or _GOARCH suffix,
+// and files whose build constraints are not satisfied.
+func matchFile(dir string, name string) bool {
+	if strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") {
+		return false
+	}
+	if strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+	if !goodOSArchFile(name) {
+		return false
+	}
+	filePath := dir + "/" + name
+	matched, cached := matchFileCache[filePath]
+	if cached {
+		return matched
+	}
+	matched = shouldBuild(filePath)
+	matchFileCache[filePath] = matched
+	return matched
+}
+
+func isKnownOS(s string) bool {
+	switch s {
+	case "aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios", "js",
+		"linux", "nacl", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows", "zos":
+		return true
+	}
+	return false
+}
+
+func isKnownArch(s string) bool {
+	switch s {
+	case "386", "amd64", "amd64p32", "arm", "armbe", "arm64", "arm64be", "loong64",
+		"mips", "mipsle", "mips64", "mips64le", "mips64p32", "mips64p32le",
+		"ppc", "ppc64", "ppc64le", "riscv", "riscv64", "s390", "s390x", "sparc", "sparc64", "wasm":
+		return true
+	}
+	return false
+}
+
+// goodOSArchFile reports whether the name is acceptable for the tag set,
+// looking at name_GOOS, name_GOARCH and name_GOOS_GOARCH suffixes (before an optional _test).
+func goodOSArchFile(name string) bool {
+	dot := strings.LastIndexByte(name, '.')
+	if dot >= 0 {
+		name = name[:dot]
+	}
+	// everything before the first _ is ignored, so "linux.go" is not constrained
+	i := strings.Index(name, "_")
+	if i < 0 {
+		return true
+	}
+	l := strings.Split(name[i:], "_")
+	n := len(l)
+	if n > 0 && l[n-1] == "test" {
+		n--
+	}
+	if n >= 2 && isKnownOS(l[n-2]) && isKnownArch(l[n-1]) {
+		return buildTags[l[n-2]] && buildTags[l[n-1]]
+	}
+	if n >= 1 && (isKnownOS(l[n-1]) || isKnownArch(l[n-1])) {
+		return buildTags[l[n-1]]
+	}
+	return true
+}
+
+// shouldBuild evaluates the build constraints in the header of a file, which ends at the package clause.
+// As go/build does, a //go:build line is looked for in the whole header outside /* */ comments,
+// while // +build lines are only looked for in the leading blank lines and // comments, up to the last blank line.
+// A //go:build line takes precedence over // +build lines.
+func shouldBuild(filePath string) bool {
+	src, _ := os.ReadFile(filePath)
+	lines := strings.Split(string(src), "\n")
+	var goBuild string
+	var hasGoBuild bool
+	var ended bool       // a line other than a blank line or a // comment has been seen
+	var inSlashStar bool // within a /* */ comment
+	var end int          // number of leading lines in which // +build lines are looked for
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" && !ended {
+			end = i
+			continue
+		}
+		if !strings.HasPrefix(line, "//") {
+			ended = true
+		}
+		if !inSlashStar && strings.HasPrefix(line, "//go:build ") {
+			if hasGoBuild {
+				panic(filePath + ": multiple //go:build comments")
+			}
+			goBuild = line[len("//go:build "):]
+			hasGoBuild = true
+		}
+		var isCode bool
+		inSlashStar, isCode = skipComments(line, inSlashStar)
+		if isCode {
+			break
+		}
+	}
+	var plusBuildOK bool = true
+	for _, line := range lines[:end] {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "// +build ") {
+			if !matchPlusBuild(line[len("// +build "):]) {
+				plusBuildOK = false
+			}
+		}
+	}
+	if hasGoBuild {
+		p := &constraintParser{
+			filePath: filePath,
+			src:      goBuild,
+		}
+		ok := p.parseOr()
+		p.skipSpace()
+		if p.pos < len(p.src) {
+			p.fail("unexpected " + p.src[p.pos:])
+		}
+		return ok
+	}
+	return plusBuildOK
+}
+
+// skipComments skips the comments of a line, which begins within a /* */ comment if inSlashStar is true.
+// It returns whether the line ends within a /* */ comment, and whether something else than comments follows.
+func skipComments(line string, inSlashStar bool) (bool, bool) {
+	for line != "" {
+		if inSlashStar {
+			i := strings.Index(line, "*/")
+			if i < 0 {
+				return true, false
+			}
+			inSlashStar = false
+			line = strings.TrimSpace(line[i+len("*/"):])
+			continue
+		}
+		if strings.HasPrefix(line, "//") {
+			return false, false
+		}
+		if strings.HasPrefix(line, "/*") {
+			inSlashStar = true
+			line = strings.TrimSpace(line[len("/*"):])
+			continue
+		}
+		return false, true
+	}
+	return inSlashStar, false
+}
+
+// matchPlusBuild evaluates a // +build line: space separated options are ORed,
+// comma separated terms are ANDed and "!" negates a term.
+func matchPlusBuild(line string) bool {
+	for _, option := range strings.Split(line, " ") {
+		if option == "" {
+			continue
+		}
+		ok := true
+		for _, term := range strings.Split(option, ",") {
+			if strings.HasPrefix(term, "!") {
+				if buildTags[term[1:]] {
+					ok = false
+				}
+			} else if !buildTags[term] {
+				ok = false
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// constraintParser evaluates a //go:build expression made of tags, !, &&, || and parentheses.
+type constraintParser struct {
+	filePath string
+	src      string
+	pos      int
+}
+
+func (p *constraintParser) fail(msg string) {
+	panic(p.filePath + ": invalid //go:build line: " + msg)
+}
+
+func (p *constraintParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *constraintParser) got(op string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], op) {
+		p.pos = p.pos + len(op)
+		return true
+	}
+	return false
+}
+
+func (p *constraintParser) parseOr() bool {
+	x := p.parseAnd()
+	for p.got("||") {
+		y := p.parseAnd()
+		x = x || y
+	}
+	return x
+}
+
+func (p *constraintParser) parseAnd() bool {
+	x := p.parseNot()
+	for p.got("&&") {
+		y := p.parseNot()
+		x = x && y
+	}
+	return x
+}
+
+func (p *constraintParser) parseNot() bool {
+	if p.got("!") {
+		return !p.parseNot()
+	}
+	if p.got("(") {
+		x := p.parseOr()
+		if !p.got(")") {
+			p.fail("missing )")
+		}
+		return x
+	}
+	start := p.pos
+	for p.pos < len(p.src) && isTagChar(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.fail("tag expected")
+	}
+	return buildTags[p.src[start:p.pos]]
+}
+
+func isTagChar(ch uint8) bool {
+	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9') || ch == '_' || ch == '.'
+}
+
 func isStdLib(pth string) bool {
 	return !strings.Contains(pth, ".")
 }
@@ -5122,55 +9997,238 @@ func getImportPathsFromFile(file string) []string {
 		pth := rawValue[1 : len(rawValue)-1]
 		paths = append(paths, pth)
 	}
-	return paths
+	return paths
+}
+
+func removeNode(tree DependencyTree, node string) {
+	for _, paths := range tree {
+		delete(paths, node)
+	}
+
+	delete(tree, node)
+}
+
+func getKeys(tree DependencyTree) []string {
+	var keys []string
+	for k, _ := range tree {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+type DependencyTree map[string]map[string]bool
+
+// Do topological sort
+// In the result list, the independent (lowest level) packages come first.
+func sortTopologically(tree DependencyTree) []string {
+	var sorted []string
+	for len(tree) > 0 {
+		keys := getKeys(tree)
+		mylib.SortStrings(keys)
+		for _, _path := range keys {
+			children, ok := tree[_path]
+			if !ok {
+				panic("not found in tree")
+			}
+			if len(children) == 0 {
+				// collect leaf node
+				sorted = append(sorted, _path)
+				removeNode(tree, _path)
+			}
+		}
+	}
+	return sorted
+}
+
+// findImportCycle returns an import cycle in tree as a list of package paths
+// which starts and ends with the same package, or nil if there is no cycle.
+func findImportCycle(tree DependencyTree) []string {
+	state := make(map[string]int) // 0: not visited, 1: visiting, 2: done
+	keys := getKeys(tree)
+	mylib.SortStrings(keys)
+	for _, pth := range keys {
+		var stack []string
+		cycle := visitImports(tree, pth, state, stack)
+		if len(cycle) > 0 {
+			return cycle
+		}
+	}
+	return nil
+}
+
+func visitImports(tree DependencyTree, pth string, state map[string]int, stack []string) []string {
+	stack = append(stack, pth)
+	switch state[pth] {
+	case 1:
+		// pth is already on the stack
+		for i, p := range stack {
+			if p == pth {
+				return stack[i:]
+			}
+		}
+	case 2:
+		return nil
+	}
+
+	state[pth] = 1
+	var children []string
+	for child, _ := range tree[pth] {
+		children = append(children, child)
+	}
+	mylib.SortStrings(children)
+	for _, child := range children {
+		cycle := visitImports(tree, child, state, stack)
+		if len(cycle) > 0 {
+			return cycle
+		}
+	}
+	state[pth] = 2
+	return nil
+}
+
+func joinStrings(elems []string, sep string) string {
+	var r string
+	for i, s := range elems {
+		if i > 0 {
+			r += sep
+		}
+		r += s
+	}
+	return r
 }
 
-func removeNode(tree DependencyTree, node string) {
-	for _, paths := range tree {
-		delete(paths, node)
+// getPackageDir maps an import path to the directory holding its sources.
+// Std packages come from stdSrcPath, packages of the current module from its root,
+// and other packages from the module's vendor directory or $GOPATH/src.
+// getPackageDir returns the directory of a package, or exits if there is none.
+func getPackageDir(importPath string) string {
+	dir := findPackageDir(importPath)
+	if !fileExists(dir) {
+		fmt.Fprintf(os.Stderr, "cannot find package \"%s\"\n", importPath)
+		os.Exit(1)
 	}
+	return dir
+}
 
-	delete(tree, node)
+func findPackageDir(importPath string) string {
+	if isStdLib(importPath) {
+		return stdSrcPath + "/" + importPath
+	}
+	if modulePath != "" {
+		if importPath == modulePath {
+			return moduleRoot
+		}
+		if strings.HasPrefix(importPath, modulePath+"/") {
+			return moduleRoot + importPath[len(modulePath):]
+		}
+		vendorDir := moduleRoot + "/vendor/" + importPath
+		if fileExists(vendorDir) {
+			return vendorDir
+		}
+	}
+	return goPathSrc + "/" + importPath
 }
 
-func getKeys(tree DependencyTree) []string {
-	var keys []string
-	for k, _ := range tree {
-		keys = append(keys, k)
+func fileExists(name string) bool {
+	f, err := os.Open(name)
+	if err != nil {
+		return false
 	}
-	return keys
+	f.Close()
+	return true
 }
 
-type DependencyTree map[string]map[string]bool
+// absPath makes a path absolute by prepending the current directory.
+func absPath(p string) string {
+	if strings.HasPrefix(p, "/") {
+		return p
+	}
+	for strings.HasPrefix(p, "./") {
+		p = p[2:]
+	}
+	wd, _ := os.Getwd()
+	if p == "." {
+		return wd
+	}
+	return wd + "/" + p
+}
 
-// Do topological sort
-// In the result list, the independent (lowest level) packages come first.
-func sortTopologically(tree DependencyTree) []string {
-	var sorted []string
-	for len(tree) > 0 {
-		keys := getKeys(tree)
-		mylib.SortStrings(keys)
-		for _, _path := range keys {
-			children, ok := tree[_path]
-			if !ok {
-				panic("not found in tree")
+// findModuleRoot returns the nearest directory at or above dir containing go.mod, or "".
+func findModuleRoot(dir string) string {
+	for {
+		if fileExists(dir + "/go.mod") {
+			if dir == "" {
+				return "/"
 			}
-			if len(children) == 0 {
-				// collect leaf node
-				sorted = append(sorted, _path)
-				removeNode(tree, _path)
+			return dir
+		}
+		if dir == "" || dir == "/" {
+			return ""
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+// readModulePath returns the path declared by the module directive of a go.mod file.
+func readModulePath(gomod string) string {
+	src, _ := os.ReadFile(gomod)
+	for _, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "module ") || strings.HasPrefix(line, "module\t") {
+			mpath := strings.TrimSpace(line[len("module"):])
+			if len(mpath) >= 2 && mpath[0] == '"' {
+				mpath = mpath[1 : len(mpath)-1]
 			}
+			return mpath
 		}
 	}
-	return sorted
+	return ""
 }
 
-func getPackageDir(importPath string) string {
-	if isStdLib(importPath) {
-		return prjSrcPath + "/" + importPath
+// initImportPaths sets up import path resolution for a program made of inputFiles.
+// The module is the one enclosing the first input file.
+// Std sources are taken from $BABYGOROOT/src if set, and from babygo's own src directory otherwise:
+// that of the module when babygo builds itself, or the one next to the running executable.
+func initImportPaths(inputFiles []string) {
+	goPathSrc = os.Getenv("GOPATH") + "/src"
+
+	dir := absPath(".")
+	if len(inputFiles) > 0 {
+		dir = path.Dir(absPath(inputFiles[0]))
+	}
+	moduleRoot = findModuleRoot(dir)
+	modulePath = ""
+	if moduleRoot != "" {
+		modulePath = readModulePath(moduleRoot + "/go.mod")
+	}
+
+	babygoRoot := os.Getenv("BABYGOROOT")
+	if babygoRoot != "" {
+		stdSrcPath = babygoRoot + "/src"
+	} else if modulePath == "github.com/DQNEO/babygo" {
+		stdSrcPath = moduleRoot + "/src"
 	} else {
-		return srcPath + "/" + importPath
+		stdSrcPath = findStdSrc()
+	}
+}
+
+// findStdSrc returns the src directory next to the running executable, or next to the bin directory it is installed in.
+func findStdSrc() string {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "babygo: cannot find the std sources: %s; set BABYGOROOT\n", err.Error())
+		os.Exit(1)
+	}
+	dir := path.Dir(exe)
+	roots := []string{dir, path.Dir(dir)}
+	for _, root := range roots {
+		if fileExists(root + "/src/runtime") {
+			return root + "/src"
+		}
 	}
+	fmt.Fprintf(os.Stderr, "babygo: cannot find the std sources next to %s; set BABYGOROOT\n", exe)
+	os.Exit(1)
+	return ""
 }
 
 func collectDependency(tree DependencyTree, paths map[string]bool) {
@@ -5178,6 +10236,10 @@ func collectDependency(tree DependencyTree, paths map[string]bool) {
 		if pkgPath == "unsafe" || pkgPath == "runtime" {
 			continue
 		}
+		_, visited := tree[pkgPath]
+		if visited {
+			continue
+		}
 		packageDir := getPackageDir(pkgPath)
 		fnames := findFilesInDir(packageDir)
 		children := make(map[string]bool)
@@ -5199,13 +10261,20 @@ func collectDependency(tree DependencyTree, paths map[string]bool) {
 	}
 }
 
-var srcPath string
-var prjSrcPath string
+var goPathSrc string  // $GOPATH/src
+var stdSrcPath string // directory of the std packages
+var moduleRoot string // directory containing go.mod, if any
+var modulePath string // module path declared in go.mod
 
 func collectAllPackages(inputFiles []string) []string {
 	directChildren := collectDirectDependents(inputFiles)
 	tree := make(DependencyTree)
 	collectDependency(tree, directChildren)
+	cycle := findImportCycle(tree)
+	if len(cycle) > 0 {
+		fmt.Fprintf(os.Stderr, "import cycle not allowed: %s\n", joinStrings(cycle, " -> "))
+		os.Exit(1)
+	}
 	sortedPaths := sortTopologically(tree)
 
 	// sort packages by this order
@@ -5247,26 +10316,33 @@ func collectSourceFiles(pkgDir string) []string {
 	return files
 }
 
-const parserImportsOnly = 2 // parser.ImportsOnly
+const parserImportsOnly = 2   // parser.ImportsOnly
+const parserParseComments = 4 // parser.ParseComments
 
 func parseImports(fset *token.FileSet, filename string) *ast.File {
 	f, err := ParseFile(fset, filename, nil, parserImportsOnly)
 	if err != nil {
-		panic(filename + ":" + err.Error())
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
 	}
 	return f
 }
 
 func parseFile(fset *token.FileSet, filename string) *ast.File {
-	f, err := ParseFile(fset, filename, nil, 0)
+	// doc comments carry directives such as //go:noinline
+	f, err := ParseFile(fset, filename, nil, parserParseComments)
 	if err != nil {
-		panic(err.Error())
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
 	}
 	return f
 }
 
 // compile compiles go files of a package into an assembly file, and copy input assembly files into it.
-func compile(universe *ast.Scope, fset *token.FileSet, pkgPath string, name string, gofiles []string, asmfiles []string, outFilePath string) {
+// compile compiles a package into outFilePath, and writes its export data to exportFilePath unless it is "".
+// The IR of the functions is released once the code is generated, so that only the declarations,
+// which the importers resolve against, stay alive while the other packages are compiled.
+func compile(universe *ast.Scope, fset *token.FileSet, pkgPath string, name string, gofiles []string, asmfiles []string, outFilePath string, exportFilePath string) *PkgContainer {
 	_pkg := &PkgContainer{name: name, path: pkgPath, fset: fset}
 	currentPkg = _pkg
 
@@ -5274,12 +10350,63 @@ func compile(universe *ast.Scope, fset *token.FileSet, pkgPath string, name stri
 	if err != nil {
 		panic(err)
 	}
-	fout = outAsmFile
+	fout = bufio.NewWriter(outAsmFile)
 
 	typesMap = make(map[string]*dtypeEntry)
 	typeId = 1
+	// labels are local to each assembly file, so that its contents only depend on the package
+	labelid = 0
 
 	logff("Building package : %s\n", _pkg.path)
+	parsePackage(universe, fset, _pkg, gofiles)
+	logff("Walking package: %s\n", _pkg.name)
+	printf("#=== Package %s\n", _pkg.path)
+	printf("#--- walk \n")
+	walk(_pkg)
+	if escapeDiag && optLevel > 0 {
+		printInlineDecisions(_pkg.astFiles)
+	}
+	if dumpIRFormat != "" {
+		irFilePath := outFilePath[:len(outFilePath)-len(".s")] + ".ir." + dumpIRFormat
+		dumpIR(_pkg, irFilePath)
+	}
+	// functions of a package with assembly files may be called from them
+	if optLevel > 0 && len(asmfiles) == 0 {
+		removeUnreferencedFuncs(_pkg)
+	}
+	generateCode(_pkg)
+	peepFlushAll()
+
+	// append static asm files
+	for _, file := range asmfiles {
+		fmt.Fprintf(fout, "# === static assembly %s ====\n", file)
+		asmContents, err := os.ReadFile(file)
+		if err != nil {
+			panic(err)
+		}
+		fout.Write(asmContents)
+	}
+
+	err = fout.Flush()
+	if err != nil {
+		panic(err)
+	}
+	outAsmFile.Close()
+	fout = nil
+	if exportFilePath != "" {
+		writeExportData(_pkg, exportFilePath)
+	}
+	_pkg.funcs = nil
+	_pkg.stringLiterals = nil
+	_pkg.runtimeStrings = nil
+	_pkg.funcInfos = nil
+	_pkg.callSites = nil
+	_pkg.astFiles = nil
+	return _pkg
+}
+
+// parsePackage parses go files into _pkg and resolves their identifiers in the package scope.
+func parsePackage(universe *ast.Scope, fset *token.FileSet, _pkg *PkgContainer, gofiles []string) {
 	pkgScope := ast.NewScope(universe)
 	for _, file := range gofiles {
 		logff("Parsing file: %s\n", file)
@@ -5290,6 +10417,12 @@ func compile(universe *ast.Scope, fset *token.FileSet, pkgPath string, name stri
 		for name, obj := range astFile.Scope.Objects {
 			pkgScope.Objects[name] = obj
 		}
+		for _, imprt := range astFile.Imports {
+			pth := imprt.Path.Value[1 : len(imprt.Path.Value)-1]
+			if !mylib.InArray(pth, _pkg.imports) {
+				_pkg.imports = append(_pkg.imports, pth)
+			}
+		}
 	}
 	for _, astFile := range _pkg.astFiles {
 		resolveImports(astFile)
@@ -5313,41 +10446,487 @@ func compile(universe *ast.Scope, fset *token.FileSet, pkgPath string, name stri
 				}
 			}
 		}
-		for _, dcl := range astFile.Decls {
-			_pkg.Decls = append(_pkg.Decls, dcl)
+		for _, dcl := range astFile.Decls {
+			_pkg.Decls = append(_pkg.Decls, dcl)
+		}
+	}
+}
+
+// --- export data ---
+// Export data lets a package be compiled against a dependency without its sources.
+// It is go source made of the declarations of the dependency with function bodies stripped,
+// and is loaded by parsing it and walking only the declarations.
+// Unexported declarations are kept too, as exported ones may refer to them.
+
+// package name => package path, of the packages compiled or loaded so far
+var pkgPathByName = make(map[string]string)
+
+// package path => package name, of the packages compiled or loaded so far
+var pkgNameByPath = make(map[string]string)
+
+type exportWriter struct {
+	pkg     *PkgContainer
+	lines   []string
+	pkgRefs []string // names of the other packages referred to
+}
+
+func (w *exportWriter) qualify(pkgName string, name string) string {
+	if pkgName == w.pkg.name {
+		return name
+	}
+	if !mylib.InArray(pkgName, w.pkgRefs) {
+		w.pkgRefs = append(w.pkgRefs, pkgName)
+	}
+	return pkgName + "." + name
+}
+
+func (w *exportWriter) typeExpr(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if e.Obj == nil {
+			panic("Unresolved identifier:" + e.Name)
+		}
+		if e.Obj.Decl == nil { // predeclared type
+			return e.Name
+		}
+		typeSpec := e.Obj.Decl.(*ast.TypeSpec)
+		pkgName := typeSpec.Name.Obj.Data.(string)
+		return w.qualify(pkgName, typeSpec.Name.Name)
+	case *ast.SelectorExpr:
+		return w.qualify(e.X.(*ast.Ident).Name, e.Sel.Name)
+	case *ast.StarExpr:
+		return "*" + w.typeExpr(e.X)
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return "[]" + w.typeExpr(e.Elt)
+		}
+		return "[" + strconv.Itoa(evalInt(e.Len)) + "]" + w.typeExpr(e.Elt)
+	case *ast.MapType:
+		return "map[" + w.typeExpr(e.Key) + "]" + w.typeExpr(e.Value)
+	case *ast.StructType:
+		var fields []string
+		if e.Fields != nil {
+			for _, field := range e.Fields.List {
+				fields = append(fields, field.Names[0].Name+" "+w.typeExpr(field.Type))
+			}
+		}
+		return "struct{" + joinStrings(fields, "; ") + "}"
+	case *ast.InterfaceType:
+		var methods []string
+		if e.Methods != nil {
+			for _, field := range e.Methods.List {
+				methods = append(methods, field.Names[0].Name+w.signature(field.Type.(*ast.FuncType)))
+			}
+		}
+		return "interface{" + joinStrings(methods, "; ") + "}"
+	case *ast.FuncType:
+		return "func" + w.signature(e)
+	case *ast.Ellipsis:
+		return "..." + w.typeExpr(e.Elt)
+	case *ast.ParenExpr:
+		return w.typeExpr(e.X)
+	default:
+		panic(fmt.Sprintf("export: unexpected type expr %T", expr))
+	}
+	return ""
+}
+
+func (w *exportWriter) fieldList(fields *ast.FieldList) string {
+	var r []string
+	if fields != nil {
+		for _, field := range fields.List {
+			typ := w.typeExpr(field.Type)
+			if len(field.Names) > 0 {
+				typ = field.Names[0].Name + " " + typ
+			}
+			r = append(r, typ)
+		}
+	}
+	return joinStrings(r, ", ")
+}
+
+// signature returns the parameters and results of a function type: "(a int, b string) (int, error)"
+func (w *exportWriter) signature(funcType *ast.FuncType) string {
+	r := "(" + w.fieldList(funcType.Params) + ")"
+	if funcType.Results == nil || len(funcType.Results.List) == 0 {
+		return r
+	}
+	if len(funcType.Results.List) == 1 && len(funcType.Results.List[0].Names) == 0 {
+		return r + " " + w.typeExpr(funcType.Results.List[0].Type)
+	}
+	return r + " (" + w.fieldList(funcType.Results) + ")"
+}
+
+// constExpr returns the source of a constant expression.
+func (w *exportWriter) constExpr(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		return e.Value
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return w.qualify(e.X.(*ast.Ident).Name, e.Sel.Name)
+	case *ast.ParenExpr:
+		return "(" + w.constExpr(e.X) + ")"
+	case *ast.UnaryExpr:
+		return e.Op.String() + w.constExpr(e.X)
+	case *ast.BinaryExpr:
+		return w.constExpr(e.X) + " " + e.Op.String() + " " + w.constExpr(e.Y)
+	case *ast.CallExpr: // conversion
+		var args []string
+		for _, arg := range e.Args {
+			args = append(args, w.constExpr(arg))
+		}
+		return w.typeExpr(e.Fun) + "(" + joinStrings(args, ", ") + ")"
+	default:
+		panic(fmt.Sprintf("export: unexpected const expr %T", expr))
+	}
+	return ""
+}
+
+func (w *exportWriter) funcDecl(funcDecl *ast.FuncDecl) string {
+	r := "func "
+	if funcDecl.Recv != nil {
+		r = r + "(" + w.fieldList(funcDecl.Recv) + ") "
+	}
+	r = r + funcDecl.Name.Name + w.signature(funcDecl.Type)
+	if isInlinable(funcDecl) {
+		ret := funcDecl.Body.List[0].(*ast.ReturnStmt)
+		r = r + " { return " + w.inlineExpr(ret.Results[0]) + " }"
+	}
+	return r
+}
+
+// inlineExpr returns the source of the body of an inlinable function.
+// Operations are parenthesized, so that the parsed body has the same structure.
+func (w *exportWriter) inlineExpr(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		return e.Value
+	case *ast.Ident:
+		return e.Name
+	case *ast.ParenExpr:
+		return w.inlineExpr(e.X)
+	case *ast.UnaryExpr:
+		return "(" + e.Op.String() + w.inlineExpr(e.X) + ")"
+	case *ast.BinaryExpr:
+		return "(" + w.inlineExpr(e.X) + " " + e.Op.String() + " " + w.inlineExpr(e.Y) + ")"
+	case *ast.SelectorExpr:
+		return w.inlineExpr(e.X) + "." + e.Sel.Name
+	case *ast.IndexExpr:
+		return w.inlineExpr(e.X) + "[" + w.inlineExpr(e.Index) + "]"
+	case *ast.StarExpr:
+		return "(*" + w.inlineExpr(e.X) + ")"
+	case *ast.CallExpr:
+		if isType(e.Fun) {
+			return "(" + w.typeExpr(e.Fun) + ")(" + w.inlineExpr(e.Args[0]) + ")"
+		}
+		return e.Fun.(*ast.Ident).Name + "(" + w.inlineExpr(e.Args[0]) + ")"
+	default:
+		panic(fmt.Sprintf("export: unexpected inline expr %T", expr))
+	}
+	return ""
+}
+
+// writeExportData writes the export data of a walked package to a file.
+// The dtype table of the package is recorded as comments.
+func writeExportData(pkg *PkgContainer, filePath string) {
+	w := &exportWriter{pkg: pkg}
+	for _, typeSpec := range pkg.typeSpecs {
+		w.lines = append(w.lines, "type "+typeSpec.Name.Name+" "+w.typeExpr(typeSpec.Type))
+	}
+	for _, spec := range pkg.consts {
+		decl := "const " + spec.Names[0].Name
+		if spec.Type != nil {
+			decl = decl + " " + w.typeExpr(spec.Type)
+		}
+		w.lines = append(w.lines, decl+" = "+w.constExpr(spec.Values[0]))
+	}
+	for _, v := range pkg.vars {
+		w.lines = append(w.lines, "var "+v.name.Name+" "+w.typeExpr(v.typ.E))
+	}
+	for _, funcDecl := range pkg.funcDecls {
+		if funcDecl.Recv == nil && funcDecl.Name.Name == "init" {
+			continue
+		}
+		w.lines = append(w.lines, w.funcDecl(funcDecl))
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(f, "// babygo export data for %s\n", pkg.path)
+	dtypes := make([]*dtypeEntry, typeId, typeId)
+	for _, ent := range typesMap {
+		dtypes[ent.id] = ent
+	}
+	for _, ent := range dtypes {
+		if ent != nil {
+			fmt.Fprintf(f, "//dtype %s %s\n", ent.label, ent.serialized)
+		}
+	}
+	fmt.Fprintf(f, "\npackage %s\n", pkg.name)
+	if len(w.pkgRefs) > 0 {
+		fmt.Fprintf(f, "\n")
+	}
+	for _, name := range w.pkgRefs {
+		pth, ok := pkgPathByName[name]
+		if !ok {
+			panic("export: unknown package " + name)
+		}
+		fmt.Fprintf(f, "import \"%s\"\n", pth)
+	}
+	for _, line := range w.lines {
+		fmt.Fprintf(f, "\n%s\n", line)
+	}
+	f.Close()
+}
+
+// loadExportData declares the package described by an export data file, in place of compiling it.
+// The name of the package is read from the package clause of the file.
+func loadExportData(universe *ast.Scope, fset *token.FileSet, pkgPath string, filePath string) *PkgContainer {
+	_pkg := &PkgContainer{path: pkgPath, fset: fset, fromExportData: true}
+	currentPkg = _pkg
+	logff("Loading export data of package : %s\n", _pkg.path)
+	parsePackage(universe, fset, _pkg, []string{filePath})
+	walk(_pkg)
+	return _pkg
+}
+
+// --- build cache ---
+// Compiled packages are cached by a hash of the compiler, the build tags, their sources and the keys of their imports.
+// A package whose key is found in the cache is not compiled: its assembly is copied and its export data is loaded.
+// The main package is always compiled.
+
+// directory of the build cache, or "" if the cache is disabled
+var cacheDir string
+
+// hash of the running compiler binary
+var compilerID string
+
+// set by -a: rebuild all packages, ignoring the cache
+var forceRebuild bool
+
+// initBuildCache sets up the cache directory from $BABYGOCACHE, which defaults to $HOME/.cache/babygo.
+// BABYGOCACHE=off disables the cache.
+func initBuildCache() {
+	dir := os.Getenv("BABYGOCACHE")
+	if dir == "off" {
+		return
+	}
+	if dir == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return
+		}
+		dir = home + "/.cache/babygo"
+	}
+	err := os.MkdirAll(dir, 493) // 0755
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "babygo: build cache disabled: cannot create %s\n", dir)
+		return
+	}
+	cacheDir = dir
+
+	exe, _ := os.ReadFile("/proc/self/exe")
+	hs := newCacheHash()
+	hs.write(exe)
+	compilerID = hs.sum()
+}
+
+// cacheHash is a 64-bit FNV-1a hash.
+type cacheHash struct {
+	h     int
+	prime int
+}
+
+// The FNV constants do not fit in the 32-bit immediates of the generated code,
+// so they are built from smaller numbers.
+func newCacheHash() *cacheHash {
+	basis := (3*1000000000+750763034)*1000000000 + 362895579
+	return &cacheHash{
+		h:     -basis,                // offset basis 14695981039346656037
+		prime: 1048576*1048576 + 435, // 1099511628211
+	}
+}
+
+func (hs *cacheHash) write(b []byte) {
+	for _, c := range b {
+		x := int(c)
+		hs.h = (hs.h | x) - (hs.h & x) // h ^= x
+		hs.h = hs.h * hs.prime
+	}
+}
+
+// writeString writes s followed by a separator.
+func (hs *cacheHash) writeString(s string) {
+	hs.write([]byte(s))
+	hs.write([]byte{0})
+}
+
+var hexDigits string = "0123456789abcdef"
+
+func (hs *cacheHash) sum() string {
+	var digits []byte
+	h := hs.h
+	for i := 0; i < 16; i++ {
+		d := h & 15
+		digits = append(digits, hexDigits[d])
+		h = (h - d) / 16
+	}
+	var r []byte
+	for i := len(digits) - 1; i >= 0; i-- {
+		r = append(r, digits[i])
+	}
+	return string(r)
+}
+
+// packageCacheKey returns the cache key of a package. keys holds the keys of the packages it imports.
+func packageCacheKey(pkg *PackageToBuild, keys map[string]string) string {
+	hs := newCacheHash()
+	hs.writeString(Version)
+	hs.writeString(compilerID)
+	hs.writeString(buildTagsFlag)
+	if noRegalloc {
+		hs.writeString("-noregalloc")
+	}
+	if optLevel == 0 {
+		hs.writeString("-O0")
+	}
+	hs.writeString("-inline=" + strconv.Itoa(inlineBudget))
+	if noRuntimeChecks {
+		hs.writeString("-B")
+	}
+	hs.writeString(pkg.path)
+	for _, file := range pkg.files {
+		hs.writeString(path.Base(file))
+		src, _ := os.ReadFile(file)
+		hs.write(src)
+	}
+	for _, imp := range getBuildDeps(pkg) {
+		hs.writeString(imp)
+		hs.writeString(keys[imp])
+	}
+	return hs.sum()
+}
+
+// isCached reports whether a cache entry can be used.
+// The export data is written last, so its presence means the entry is complete.
+// Packages are compiled again to produce the IR dump or the escape diagnostics.
+func isCached(exportCache string) bool {
+	return !forceRebuild && dumpIRFormat == "" && !escapeDiag && fileExists(exportCache)
+}
+
+// touchCacheEntry records that an entry is used now, by the modification time of its export data.
+func touchCacheEntry(key string) {
+	now := time.Now()
+	os.Chtimes(cacheDir+"/"+key+".export", now, now)
+}
+
+// default limit of the size of the build cache, which $BABYGOCACHESIZE sets in bytes
+const defaultCacheMaxSize int = 100000000
+
+type cacheEntry struct {
+	key     string
+	size    int       // size of the assembly and of the export data
+	used    time.Time // modification time of the export data
+	removed bool
+}
+
+// trimBuildCache removes the least recently used entries while the cache is larger than its limit.
+// The entries used by the current build are kept, even if they alone exceed the limit.
+func trimBuildCache(keep map[string]bool) {
+	if cacheDir == "" {
+		return
+	}
+	maxSize := defaultCacheMaxSize
+	if os.Getenv("BABYGOCACHESIZE") != "" {
+		maxSize = strconv.Atoi(os.Getenv("BABYGOCACHESIZE"))
+	}
+	dirents, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return
+	}
+	var entries []*cacheEntry
+	entryByKey := make(map[string]*cacheEntry)
+	var total int
+	for _, de := range dirents {
+		name := de.Name()
+		var key string
+		if strings.HasSuffix(name, ".s") {
+			key = name[:len(name)-len(".s")]
+		} else if strings.HasSuffix(name, ".export") {
+			key = name[:len(name)-len(".export")]
+		} else {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
 		}
+		ent, ok := entryByKey[key]
+		if !ok {
+			ent = &cacheEntry{key: key}
+			entryByKey[key] = ent
+			entries = append(entries, ent)
+		}
+		ent.size = ent.size + int(info.Size())
+		if strings.HasSuffix(name, ".export") {
+			ent.used = info.ModTime()
+		}
+		total = total + int(info.Size())
 	}
-	logff("Walking package: %s\n", _pkg.name)
-	printf("#=== Package %s\n", _pkg.path)
-	printf("#--- walk \n")
-	walk(_pkg)
-	generateCode(_pkg)
-
-	// append static asm files
-	for _, file := range asmfiles {
-		fmt.Fprintf(outAsmFile, "# === static assembly %s ====\n", file)
-		asmContents, err := os.ReadFile(file)
-		if err != nil {
-			panic(err)
+	for total > maxSize {
+		var oldest *cacheEntry
+		for _, ent := range entries {
+			if ent.removed || keep[ent.key] {
+				continue
+			}
+			if oldest == nil || ent.used.Before(oldest.used) {
+				oldest = ent
+			}
+		}
+		if oldest == nil {
+			return
 		}
-		outAsmFile.Write(asmContents)
+		// the export data goes first, as its presence marks a complete entry
+		os.Remove(cacheDir + "/" + oldest.key + ".export")
+		os.Remove(cacheDir + "/" + oldest.key + ".s")
+		oldest.removed = true
+		total = total - oldest.size
 	}
+}
 
-	outAsmFile.Close()
-	fout = nil
+func copyFile(src string, dst string) {
+	content, _ := os.ReadFile(src)
+	f, err := os.Create(dst)
+	if err != nil {
+		panic(err)
+	}
+	f.Write(content)
+	f.Close()
 }
 
 // --- main ---
+// parseInlineFlag sets the inlining budget if arg is -inline=N, and reports whether it is.
+func parseInlineFlag(arg string) bool {
+	if !strings.HasPrefix(arg, "-inline=") {
+		return false
+	}
+	inlineBudget = strconv.Atoi(arg[len("-inline="):])
+	return true
+}
+
 func showHelp() {
 	fmt.Printf("Usage:\n")
 	fmt.Printf("    %s version:  show version\n", ProgName)
-	fmt.Printf("    %s [-DF] [-DG] filename\n", ProgName)
+	fmt.Printf("    %s [-DF] [-DG] [-a] [-p n] [-noregalloc] [-O0|-O1] [-inline=n] [-m] [-B] [-dump-ir=json|text] [-tags tag,...] [-o exe] [-x] filename\n", ProgName)
+	fmt.Printf("    %s fmt [-l] [-w] [-d] filename...:  reformat go source files\n", ProgName)
+	fmt.Printf("    %s list [-deps] [-json] [-graph=dot] [-tags tag,...] filename...:  list packages in build order\n", ProgName)
 }
 
 func main() {
-	srcPath = os.Getenv("GOPATH") + "/src"
-	prjSrcPath = srcPath + "/github.com/DQNEO/babygo/src"
-
 	if len(os.Args) == 1 {
 		showHelp()
 		return
@@ -5362,6 +10941,15 @@ func main() {
 	} else if os.Args[1] == "panic" {
 		panicVersion := strconv.Itoa(mylib.Sum(1, 1))
 		panic("I am panic version " + panicVersion)
+	} else if os.Args[1] == "fmt" {
+		formatAll(os.Args[2:])
+		return
+	} else if os.Args[1] == "list" {
+		listPackages(os.Args[2:])
+		return
+	} else if os.Args[1] == "compile" {
+		compilePackage(os.Args[2:])
+		return
 	}
 
 	buildAll(os.Args[1:])
@@ -5369,69 +10957,189 @@ func main() {
 
 var fset *token.FileSet
 
+// collectPackagesToBuild returns all the packages needed to build inputFiles in build order.
+// The main package made of inputFiles comes last.
+// Build constraints are not applied to inputFiles, so that a file marked "//go:build ignore" can still be built by naming it.
+func collectPackagesToBuild(inputFiles []string) []*PackageToBuild {
+	initImportPaths(inputFiles)
+	initBuildTags(buildTagsFlag)
+	paths := collectAllPackages(inputFiles)
+	var packagesToBuild []*PackageToBuild
+	for _, _path := range paths {
+		files := collectSourceFiles(getPackageDir(_path))
+		packagesToBuild = append(packagesToBuild, &PackageToBuild{
+			name:  packageName(_path, files),
+			path:  _path,
+			files: files,
+		})
+	}
+
+	packagesToBuild = append(packagesToBuild, &PackageToBuild{
+		name:  "main",
+		path:  "main",
+		files: inputFiles,
+	})
+	return packagesToBuild
+}
+
+// packageName returns the name declared by the package clause of the go files of a package,
+// which may differ from the last element of its path.
+func packageName(pkgPath string, files []string) string {
+	for _, file := range files {
+		if strings.HasSuffix(file, ".go") {
+			astFile := parseImports(&token.FileSet{}, file)
+			return astFile.Name.Name
+		}
+	}
+	return path.Base(pkgPath)
+}
+
+// initOrder is the names of the packages of the program in the order of their dependencies, main last.
+var initOrder []string
+
 func buildAll(args []string) {
+	buildStart := time.Now()
 	workdir := os.Getenv("WORKDIR")
 	if workdir == "" {
 		workdir = "/tmp"
 	}
+	err := os.MkdirAll(workdir, 493) // 0755
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
 	logff("Build start\n")
 
 	var inputFiles []string
-	for _, arg := range args {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		last := parseTagsFlag(args, i)
+		if last >= 0 {
+			i = last
+			continue
+		}
 		switch arg {
 		case "-DF":
 			debugFrontEnd = true
 		case "-DG":
 			debugCodeGen = true
+		case "-dump-ir=json":
+			dumpIRFormat = "json"
+		case "-dump-ir=text":
+			dumpIRFormat = "text"
+		case "-a":
+			forceRebuild = true
+		case "-noregalloc":
+			noRegalloc = true
+		case "-O0":
+			optLevel = 0
+		case "-O1":
+			optLevel = 1
+		case "-m":
+			escapeDiag = true
+		case "-B":
+			noRuntimeChecks = true
+		case "-x":
+			printSteps = true
+		case "-o":
+			if i+1 >= len(args) {
+				panic("flag needs an argument: -o")
+			}
+			i++
+			outputFile = args[i]
+		case "-p":
+			if i+1 >= len(args) {
+				panic("flag needs an argument: -p")
+			}
+			i++
+			buildJobs = strconv.Atoi(args[i])
+			if buildJobs < 1 {
+				panic("invalid -p: " + args[i])
+			}
 		default:
-			inputFiles = append(inputFiles, arg)
+			if !parseInlineFlag(arg) {
+				inputFiles = append(inputFiles, arg)
+			}
 		}
 	}
 
-	paths := collectAllPackages(inputFiles)
-	var packagesToBuild []*PackageToBuild
-	for _, _path := range paths {
-		files := collectSourceFiles(getPackageDir(_path))
-		packagesToBuild = append(packagesToBuild, &PackageToBuild{
-			name:  path.Base(_path),
-			path:  _path,
-			files: files,
-		})
+	packagesToBuild := collectPackagesToBuild(inputFiles)
+	for _, _pkg := range packagesToBuild {
+		initOrder = append(initOrder, _pkg.name)
 	}
-
-	packagesToBuild = append(packagesToBuild, &PackageToBuild{
-		name:  "main",
-		path:  "main",
-		files: inputFiles,
-	})
+	initBuildCache()
 
 	var universe = createUniverse()
 	fset = token.NewFileSet()
 
+	if buildJobs > 1 {
+		mainPkg := packagesToBuild[len(packagesToBuild)-1]
+		jobs := runBuildJobs(packagesToBuild[:len(packagesToBuild)-1], workdir)
+		for _, job := range jobs {
+			pkg := loadExportData(universe, fset, job.pkg.path, job.exportFile)
+			pkgPathByName[pkg.name] = pkg.path
+			pkgNameByPath[pkg.path] = pkg.name
+		}
+		keep := make(map[string]bool)
+		for _, job := range jobs {
+			keep[job.cacheKey] = true
+		}
+		trimBuildCache(keep)
+		gofiles, asmfiles := splitSourceFiles(mainPkg.files)
+		start := time.Now()
+		compile(universe, fset, mainPkg.path, mainPkg.name, gofiles, asmfiles, asmFilePath(workdir, mainPkg.path), "")
+		logStep(start, "compile %s", mainPkg.path)
+		if outputFile != "" {
+			assembleAndLink(workdir, packagesToBuild, outputFile)
+		}
+		logStep(buildStart, "build")
+		return
+	}
+
+	cacheKeys := make(map[string]string)
 	for _, _pkg := range packagesToBuild {
 		if _pkg.name == "" {
 			panic("empty pkg name")
 		}
-		var asmBasename []byte
-		for _, ch := range []byte(_pkg.path) {
-			if ch == '/' {
-				ch = '@'
-			}
-			asmBasename = append(asmBasename, ch)
+		outFilePath := asmFilePath(workdir, _pkg.path)
+		gofiles, asmfiles := splitSourceFiles(_pkg.files)
+		start := time.Now()
+		if _pkg.path == "main" || cacheDir == "" {
+			pkg := compile(universe, fset, _pkg.path, _pkg.name, gofiles, asmfiles, outFilePath, "")
+			logStep(start, "compile %s", _pkg.path)
+			pkgNameByPath[pkg.path] = pkg.name
+			continue
 		}
-		outFilePath := fmt.Sprintf("%s/%s", workdir, string(asmBasename)+".s")
-		var gofiles []string
-		var asmfiles []string
-		for _, f := range _pkg.files {
-			if strings.HasSuffix(f, ".go") {
-				gofiles = append(gofiles, f)
-			} else if strings.HasSuffix(f, ".s") {
-				asmfiles = append(asmfiles, f)
-			}
 
+		key := packageCacheKey(_pkg, cacheKeys)
+		cacheKeys[_pkg.path] = key
+		asmCache := cacheDir + "/" + key + ".s"
+		exportCache := cacheDir + "/" + key + ".export"
+		var pkg *PkgContainer
+		if isCached(exportCache) {
+			copyFile(asmCache, outFilePath)
+			touchCacheEntry(key)
+			pkg = loadExportData(universe, fset, _pkg.path, exportCache)
+			logStep(start, "cached %s", _pkg.path)
+		} else {
+			exportFile := outFilePath[:len(outFilePath)-len(".s")] + ".export"
+			pkg = compile(universe, fset, _pkg.path, _pkg.name, gofiles, asmfiles, outFilePath, exportFile)
+			copyFile(outFilePath, asmCache)
+			copyFile(exportFile, exportCache)
+			logStep(start, "compile %s", _pkg.path)
 		}
-		compile(universe, fset, _pkg.path, _pkg.name, gofiles, asmfiles, outFilePath)
+		pkgPathByName[pkg.name] = pkg.path
+		pkgNameByPath[pkg.path] = pkg.name
+	}
+	keep := make(map[string]bool)
+	for _, key := range cacheKeys {
+		keep[key] = true
 	}
+	trimBuildCache(keep)
+	if outputFile != "" {
+		assembleAndLink(workdir, packagesToBuild, outputFile)
+	}
+	logStep(buildStart, "build")
 
 	//fmt.Fprintf(os.Stderr, "### Debugging File Postions\n")
 	//for _, f := range fset.Files {
@@ -5440,6 +11148,533 @@ func buildAll(args []string) {
 	//}
 }
 
+// printSteps is set by -x: the steps of the build are printed to stderr with the time they took.
+var printSteps bool
+
+// logStep prints a step of the build which began at start, if -x is given.
+func logStep(start time.Time, format string, a ...interface{}) {
+	if !printSteps {
+		return
+	}
+	d := time.Since(start)
+	fmt.Fprintf(os.Stderr, "# %s (%s)\n", fmt.Sprintf(format, a...), d.String())
+}
+
+// outputFile is the executable to make by -o. Without it, only the assembly files are generated.
+var outputFile string
+
+// assembleAndLink assembles the assembly file of each package with as, and links the objects into the executable exe with ld.
+func assembleAndLink(workdir string, packagesToBuild []*PackageToBuild, exe string) {
+	var objFiles []string
+	for _, _pkg := range packagesToBuild {
+		asmFile := asmFilePath(workdir, _pkg.path)
+		objFile := asmFile[:len(asmFile)-len(".s")] + ".o"
+		runTool("as", "-o", objFile, asmFile)
+		objFiles = append(objFiles, objFile)
+	}
+	var ldArgs []string
+	ldArgs = append(ldArgs, "-o")
+	ldArgs = append(ldArgs, exe)
+	for _, o := range objFiles {
+		ldArgs = append(ldArgs, o)
+	}
+	runTool("ld", ldArgs...)
+}
+
+// runTool runs an external tool, and exits if it fails.
+func runTool(name string, args ...string) {
+	cmd := exec.Command(name, args...)
+	if printSteps {
+		fmt.Fprintf(os.Stderr, "%s\n", cmd.String())
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	start := time.Now()
+	err := cmd.Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err.Error())
+		os.Exit(1)
+	}
+	logStep(start, "%s", name)
+}
+
+// asmFilePath returns the path of the assembly file of a package: "$WORKDIR/github.com@foo@bar.s"
+func asmFilePath(workdir string, pkgPath string) string {
+	var asmBasename []byte
+	for _, ch := range []byte(pkgPath) {
+		if ch == '/' {
+			ch = '@'
+		}
+		asmBasename = append(asmBasename, ch)
+	}
+	return fmt.Sprintf("%s/%s", workdir, string(asmBasename)+".s")
+}
+
+func splitSourceFiles(files []string) ([]string, []string) {
+	var gofiles []string
+	var asmfiles []string
+	for _, f := range files {
+		if strings.HasSuffix(f, ".go") {
+			gofiles = append(gofiles, f)
+		} else if strings.HasSuffix(f, ".s") {
+			asmfiles = append(asmfiles, f)
+		}
+	}
+	return gofiles, asmfiles
+}
+
+// --- parallel build ---
+// With -p n, the imported packages are compiled by up to n worker processes running "babygo compile".
+// A worker compiles one package against the export data of its dependencies,
+// and its output does not depend on what else is compiled, so the result is the same as a sequential build.
+
+// maximum number of packages compiled at the same time
+var buildJobs int = 1
+
+type buildJob struct {
+	pkg        *PackageToBuild
+	deps       []*buildJob // packages imported directly or indirectly, in build order
+	outFile    string
+	exportFile string // export data, set once the package is built
+	cacheKey   string
+	pid        int // pid of the worker compiling the package
+	start      time.Time
+	done       bool
+}
+
+// runBuildJobs builds packages, which are in build order, and returns their jobs.
+func runBuildJobs(pkgs []*PackageToBuild, workdir string) []*buildJob {
+	var jobs []*buildJob
+	jobByPath := make(map[string]*buildJob)
+	cacheKeys := make(map[string]string)
+	for _, pkg := range pkgs {
+		job := &buildJob{
+			pkg:     pkg,
+			outFile: asmFilePath(workdir, pkg.path),
+		}
+		needed := make(map[string]bool)
+		for _, imp := range getBuildDeps(pkg) {
+			dep, ok := jobByPath[imp]
+			if ok {
+				needed[imp] = true
+				for _, d := range dep.deps {
+					needed[d.pkg.path] = true
+				}
+			}
+		}
+		for _, prev := range jobs {
+			if needed[prev.pkg.path] {
+				job.deps = append(job.deps, prev)
+			}
+		}
+		if cacheDir != "" {
+			job.cacheKey = packageCacheKey(pkg, cacheKeys)
+			cacheKeys[pkg.path] = job.cacheKey
+		}
+		jobs = append(jobs, job)
+		jobByPath[pkg.path] = job
+	}
+
+	// start the jobs whose dependencies are done, then wait for one of the running ones, until none runs
+	var running int
+	var finished int
+	for {
+		for _, job := range jobs {
+			if running >= buildJobs {
+				break
+			}
+			if job.done || job.pid != 0 || !depsDone(job) {
+				continue
+			}
+			if job.cacheKey != "" && isCached(cacheDir+"/"+job.cacheKey+".export") {
+				copyFile(cacheDir+"/"+job.cacheKey+".s", job.outFile)
+				touchCacheEntry(job.cacheKey)
+				job.exportFile = cacheDir + "/" + job.cacheKey + ".export"
+				logStep(time.Now(), "cached %s", job.pkg.path)
+				job.done = true
+				finished++
+				continue
+			}
+			job.start = time.Now()
+			startBuildJob(job)
+			running++
+		}
+		if running == 0 {
+			break
+		}
+
+		var ws syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &ws, 0, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "babygo: waiting for the workers: %s\n", err.Error())
+			os.Exit(1)
+		}
+		var job *buildJob
+		for _, j := range jobs {
+			if j.pid != 0 && j.pid == pid {
+				job = j
+			}
+		}
+		if job == nil {
+			// not a worker, such as a command started before the build
+			continue
+		}
+		running--
+		job.pid = 0
+		if int(ws) != 0 {
+			fmt.Fprintf(os.Stderr, "babygo: compiling %s failed\n", job.pkg.path)
+			for running > 0 {
+				syscall.Wait4(-1, &ws, 0, nil)
+				running--
+			}
+			os.Exit(1)
+		}
+		job.exportFile = job.outFile[:len(job.outFile)-len(".s")] + ".export"
+		logStep(job.start, "compile %s", job.pkg.path)
+		if job.cacheKey != "" {
+			copyFile(job.outFile, cacheDir+"/"+job.cacheKey+".s")
+			copyFile(job.exportFile, cacheDir+"/"+job.cacheKey+".export")
+		}
+		job.done = true
+		finished++
+	}
+	if finished < len(jobs) {
+		// the jobs are in build order, so this would be a bug of the dependencies of the jobs
+		for _, job := range jobs {
+			if !job.done {
+				fmt.Fprintf(os.Stderr, "babygo: %s cannot be compiled: its dependencies are not built\n", job.pkg.path)
+			}
+		}
+		os.Exit(1)
+	}
+	return jobs
+}
+
+// getBuildDeps returns the packages needed to compile pkg: its imports, and runtime which every package calls into.
+func getBuildDeps(pkg *PackageToBuild) []string {
+	deps := getPackageImports(pkg)
+	if pkg.path != "runtime" && pkg.path != "unsafe" && !mylib.InArray("runtime", deps) {
+		deps = append(deps, "runtime")
+	}
+	return deps
+}
+
+func depsDone(job *buildJob) bool {
+	for _, dep := range job.deps {
+		if !dep.done {
+			return false
+		}
+	}
+	return true
+}
+
+// startBuildJob starts a worker compiling the package of job.
+func startBuildJob(job *buildJob) {
+	argv := []string{"babygo", "compile", "-pkg", job.pkg.path, "-name", job.pkg.name, "-o", job.outFile,
+		"-export", job.outFile[:len(job.outFile)-len(".s")] + ".export"}
+	if debugFrontEnd {
+		argv = append(argv, "-DF")
+	}
+	if debugCodeGen {
+		argv = append(argv, "-DG")
+	}
+	if noRegalloc {
+		argv = append(argv, "-noregalloc")
+	}
+	if optLevel == 0 {
+		argv = append(argv, "-O0")
+	}
+	argv = append(argv, "-inline="+strconv.Itoa(inlineBudget))
+	if escapeDiag {
+		argv = append(argv, "-m")
+	}
+	if noRuntimeChecks {
+		argv = append(argv, "-B")
+	}
+	if dumpIRFormat != "" {
+		argv = append(argv, "-dump-ir="+dumpIRFormat)
+	}
+	for _, dep := range job.deps {
+		argv = append(argv, "-import")
+		argv = append(argv, dep.pkg.path+"="+dep.exportFile)
+	}
+	for _, f := range job.pkg.files {
+		argv = append(argv, f)
+	}
+	attr := &syscall.ProcAttr{
+		Files: []uintptr{0, 1, 2},
+	}
+	pid, err := syscall.ForkExec("/proc/self/exe", argv, attr)
+	if err != nil || pid <= 0 {
+		panic("cannot start a worker for " + job.pkg.path)
+	}
+	job.pid = pid
+}
+
+// compilePackage is the worker of a parallel build.
+// It compiles a package and writes its export data.
+//
+//	babygo compile -pkg path -name name -o out.s -export out.export [-noregalloc] [-O0] [-inline=n] [-m] [-B] [-import path=export]... files...
+//
+// The imported packages must be listed in build order, including the indirect ones.
+func compilePackage(args []string) {
+	var pkgPath string
+	var pkgName string
+	var outFile string
+	var exportFile string
+	var imports []string
+	var files []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-pkg":
+			i++
+			pkgPath = args[i]
+		case "-name":
+			i++
+			pkgName = args[i]
+		case "-o":
+			i++
+			outFile = args[i]
+		case "-export":
+			i++
+			exportFile = args[i]
+		case "-import":
+			i++
+			imports = append(imports, args[i])
+		case "-DF":
+			debugFrontEnd = true
+		case "-DG":
+			debugCodeGen = true
+		case "-noregalloc":
+			noRegalloc = true
+		case "-O0":
+			optLevel = 0
+		case "-O1":
+			optLevel = 1
+		case "-m":
+			escapeDiag = true
+		case "-B":
+			noRuntimeChecks = true
+		case "-dump-ir=json":
+			dumpIRFormat = "json"
+		case "-dump-ir=text":
+			dumpIRFormat = "text"
+		default:
+			if !parseInlineFlag(args[i]) {
+				files = append(files, args[i])
+			}
+		}
+	}
+
+	var universe = createUniverse()
+	fset = token.NewFileSet()
+	for _, imp := range imports {
+		eq := strings.Index(imp, "=")
+		impPath := imp[:eq]
+		pkg := loadExportData(universe, fset, impPath, imp[eq+1:])
+		pkgPathByName[pkg.name] = pkg.path
+		pkgNameByPath[pkg.path] = pkg.name
+	}
+	gofiles, asmfiles := splitSourceFiles(files)
+	compile(universe, fset, pkgPath, pkgName, gofiles, asmfiles, outFile, exportFile)
+}
+
+// --- fmt ---
+func formatAll(args []string) {
+	var listOnly bool
+	var writeBack bool
+	var showDiff bool
+	var files []string
+	for _, arg := range args {
+		switch arg {
+		case "-l":
+			listOnly = true
+		case "-w":
+			writeBack = true
+		case "-d":
+			showDiff = true
+		default:
+			files = append(files, arg)
+		}
+	}
+
+	fset = token.NewFileSet()
+	for _, filename := range files {
+		formatFile(filename, listOnly, writeBack, showDiff)
+	}
+}
+
+// formatBuffer collects the output of the printer in memory.
+type formatBuffer struct {
+	buf []byte
+}
+
+func (b *formatBuffer) Write(p []byte) (int, error) {
+	for _, c := range p {
+		b.buf = append(b.buf, c)
+	}
+	return len(p), nil
+}
+
+// formatFile reformats a go source file.
+// Without any flags the result is printed to stdout.
+func formatFile(filename string, listOnly bool, writeBack bool, showDiff bool) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	// a syntax error is reported by the parser, which exits with status 2
+	f, perr := ParseFile(fset, filename, nil, parserParseComments)
+	if perr != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", perr.Error())
+		os.Exit(2)
+	}
+
+	var out formatBuffer
+	cfg := &printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	cfg.Fprint(&out, fset, f)
+	res := out.buf
+
+	if !listOnly && !writeBack && !showDiff {
+		os.Stdout.Write(res)
+		return
+	}
+	if string(src) == string(res) {
+		return
+	}
+	if listOnly {
+		fmt.Printf("%s\n", filename)
+	}
+	if writeBack {
+		err = os.WriteFile(filename, res, 420) // 0644
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	if showDiff {
+		os.Stdout.Write(diff.Unified(filename+".orig", src, filename, res))
+	}
+}
+
+// --- list ---
+func listPackages(args []string) {
+	var withDeps bool
+	var asJSON bool
+	var asDot bool
+	var inputFiles []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		last := parseTagsFlag(args, i)
+		if last >= 0 {
+			i = last
+			continue
+		}
+		switch arg {
+		case "-deps":
+			withDeps = true
+		case "-json":
+			asJSON = true
+		case "-graph=dot":
+			asDot = true
+		default:
+			inputFiles = append(inputFiles, arg)
+		}
+	}
+
+	pkgs := collectPackagesToBuild(inputFiles)
+	if !withDeps {
+		pkgs = pkgs[len(pkgs)-1:]
+	}
+
+	if asDot {
+		fmt.Printf("digraph imports {\n")
+		for _, pkg := range pkgs {
+			shape := "ellipse"
+			if isStdPackage(pkg) {
+				shape = "box"
+			}
+			fmt.Printf("\t%s [shape=%s];\n", jsonQuote(pkg.path), shape)
+			for _, imp := range getPackageImports(pkg) {
+				fmt.Printf("\t%s -> %s;\n", jsonQuote(pkg.path), jsonQuote(imp))
+			}
+		}
+		fmt.Printf("}\n")
+		return
+	}
+
+	for _, pkg := range pkgs {
+		var goFiles []string
+		var asmFiles []string
+		for _, f := range pkg.files {
+			if strings.HasSuffix(f, ".go") {
+				goFiles = append(goFiles, f)
+			} else if strings.HasSuffix(f, ".s") {
+				asmFiles = append(asmFiles, f)
+			}
+		}
+		imports := getPackageImports(pkg)
+		if asJSON {
+			fmt.Printf("{\n")
+			fmt.Printf("\t\"ImportPath\": %s,\n", jsonQuote(pkg.path))
+			fmt.Printf("\t\"Name\": %s,\n", jsonQuote(pkg.name))
+			if isStdPackage(pkg) {
+				fmt.Printf("\t\"Standard\": true,\n")
+			} else {
+				fmt.Printf("\t\"Standard\": false,\n")
+			}
+			fmt.Printf("\t\"GoFiles\": %s,\n", jsonStringList(goFiles))
+			fmt.Printf("\t\"SFiles\": %s,\n", jsonStringList(asmFiles))
+			fmt.Printf("\t\"Imports\": %s\n", jsonStringList(imports))
+			fmt.Printf("}\n")
+		} else {
+			kind := "project"
+			if isStdPackage(pkg) {
+				kind = "std"
+			}
+			fmt.Printf("%s (%s)\n", pkg.path, kind)
+			for _, f := range pkg.files {
+				fmt.Printf("\tfile %s\n", f)
+			}
+			for _, imp := range imports {
+				fmt.Printf("\timport %s\n", imp)
+			}
+		}
+	}
+}
+
+func isStdPackage(pkg *PackageToBuild) bool {
+	return pkg.path != "main" && isStdLib(pkg.path)
+}
+
+// getPackageImports returns the sorted import paths of the go files of pkg.
+func getPackageImports(pkg *PackageToBuild) []string {
+	seen := make(map[string]bool)
+	var imports []string
+	for _, f := range pkg.files {
+		if !strings.HasSuffix(f, ".go") {
+			continue
+		}
+		for _, pth := range getImportPathsFromFile(f) {
+			if !seen[pth] {
+				seen[pth] = true
+				imports = append(imports, pth)
+			}
+		}
+	}
+	mylib.SortStrings(imports)
+	return imports
+}
+
+func jsonStringList(list []string) string {
+	var quoted []string
+	for _, s := range list {
+		quoted = append(quoted, jsonQuote(s))
+	}
+	return "[" + joinStrings(quoted, ", ") + "]"
+}
+
 // --- AST meta data ---
 var mapFieldOffset = make(map[unsafe.Pointer]int)
 
@@ -113,11 +113,66 @@ func (s *scanner) scanChar() string {
 }
 
 func (s *scanner) scanComment() string {
+	// initial '/' already consumed
 	var offset = s.offset - 1
-	for s.ch != '\n' {
+	if s.ch == '/' {
+		//-style comment
+		for s.ch != '\n' && s.ch != 1 {
+			s.next()
+		}
+		return string(s.src[offset:s.offset])
+	}
+
+	/*-style comment */
+	s.next() // consume '*'
+	for s.ch != 1 {
+		ch := s.ch
 		s.next()
+		if ch == '*' && s.ch == '/' {
+			s.next()
+			return string(s.src[offset:s.offset])
+		}
 	}
-	return string(s.src[offset:s.offset])
+	syntaxError(s.File, token.Pos(s.File.Base+offset), "comment not terminated")
+	return ""
+}
+
+// findLineEnd reports whether the comment starting at the current position
+// contains a newline or is followed by one, in which case a semicolon has to
+// be inserted before it.
+func (s *scanner) findLineEnd() bool {
+	// initial '/' already consumed
+	if s.ch == '/' {
+		return true
+	}
+	// /*-style comment: look for newline within the comment or until the next token
+	var i = s.offset + 1
+	for i < len(s.src) {
+		ch := s.src[i]
+		if ch == '\n' {
+			return true
+		}
+		if ch == '*' && i+1 < len(s.src) && s.src[i+1] == '/' {
+			i = i + 2
+			for i < len(s.src) && (s.src[i] == ' ' || s.src[i] == '\t' || s.src[i] == '\r') {
+				i++
+			}
+			if i == len(s.src) || s.src[i] == '\n' {
+				return true
+			}
+			if s.src[i] != '/' || i+1 == len(s.src) || (s.src[i+1] != '/' && s.src[i+1] != '*') {
+				return false
+			}
+			// another comment follows
+			i = i + 2
+			if s.src[i-1] == '/' {
+				return true
+			}
+		} else {
+			i++
+		}
+	}
+	return true
 }
 
 type TokenContainer struct {
@@ -248,21 +303,23 @@ func (s *scanner) Scan() *TokenContainer {
 				tok = "*"
 			}
 		case '/':
-			if s.ch == '/' {
+			if s.ch == '/' || s.ch == '*' {
 				// comment
-				// @TODO block comment
-				if s.insertSemi {
+				if s.insertSemi && s.findLineEnd() {
 					s.ch = '/'
 					s.offset = s.offset - 1
 					s.nextOffset = s.offset + 1
+					tc.pos = pos
 					tc.lit = "\n"
 					tc.tok = ";"
 					s.insertSemi = false
 					return tc
 				}
+				insertSemi = s.insertSemi // preserve insertSemi info
 				lit = s.scanComment()
 				tok = "COMMENT"
 			} else if s.ch == '=' {
+				s.next()
 				tok = "/="
 			} else {
 				tok = "/"
@@ -370,7 +427,7 @@ func (s *scanner) Scan() *TokenContainer {
 			tok = "EOF"
 			//			logf("[scanner] EOF @ file=%s line=%d final_offset=%d, Pos=%d\n", s.File.Name, len(s.File.Lines)-1, s.offset, pos)
 		default:
-			panic2(__func__, "unknown char:"+string([]uint8{ch})+":"+strconv.Itoa(int(ch)))
+			syntaxError(s.File, token.Pos(pos), "unknown char:"+string([]uint8{ch})+":"+strconv.Itoa(int(ch)))
 			tok = "UNKNOWN"
 		}
 	}
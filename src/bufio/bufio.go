@@ -0,0 +1,491 @@
+// Package bufio implements buffered I/O. It wraps an io.Reader or io.Writer
+// object, creating another object (Reader or Writer) that also implements
+// the interface but provides buffering and some help for textual I/O.
+package bufio
+
+import "errors"
+import "internal/exithook"
+import "io"
+
+const defaultBufSize int = 4096
+
+var ErrInvalidUnreadByte = errors.New("bufio: invalid use of UnreadByte")
+var ErrBufferFull = errors.New("bufio: buffer full")
+var ErrNegativeCount = errors.New("bufio: negative count")
+
+// copyBytes copies src into dst, and returns the number of bytes copied.
+func copyBytes(dst []byte, src []byte) int {
+	n := len(src)
+	if len(dst) < n {
+		n = len(dst)
+	}
+	for i := 0; i < n; i++ {
+		dst[i] = src[i]
+	}
+	return n
+}
+
+// indexByte returns the index of the first instance of c in b, or -1 if c is not present in b.
+func indexByte(b []byte, c byte) int {
+	for i := 0; i < len(b); i++ {
+		if b[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// Reader implements buffering for an io.Reader object.
+type Reader struct {
+	buf      []byte
+	rd       io.Reader // reader provided by the client
+	r        int       // buf read position
+	w        int       // buf write position
+	err      error
+	lastByte int // last byte read for UnreadByte; -1 means invalid
+}
+
+const minReadBufferSize int = 16
+
+// NewReaderSize returns a new Reader whose buffer has at least the specified size.
+// If the argument io.Reader is already a Reader with large enough size, it returns the underlying Reader.
+func NewReaderSize(rd io.Reader, size int) *Reader {
+	b, ok := rd.(*Reader)
+	if ok && len(b.buf) >= size {
+		return b
+	}
+	if size < minReadBufferSize {
+		size = minReadBufferSize
+	}
+	r := &Reader{
+		buf:      make([]byte, size, size),
+		rd:       rd,
+		lastByte: -1,
+	}
+	return r
+}
+
+// NewReader returns a new Reader whose buffer has the default size.
+func NewReader(rd io.Reader) *Reader {
+	return NewReaderSize(rd, defaultBufSize)
+}
+
+// Size returns the size of the underlying buffer in bytes.
+func (b *Reader) Size() int {
+	return len(b.buf)
+}
+
+// Reset discards any buffered data, resets all state, and switches the buffered reader to read from r.
+func (b *Reader) Reset(r io.Reader) {
+	if b.buf == nil {
+		b.buf = make([]byte, defaultBufSize, defaultBufSize)
+	}
+	b.rd = r
+	b.r = 0
+	b.w = 0
+	b.err = nil
+	b.lastByte = -1
+}
+
+// fill reads a new chunk into the buffer.
+func (b *Reader) fill() {
+	// Slide existing data to beginning.
+	if b.r > 0 {
+		copyBytes(b.buf, b.buf[b.r:b.w])
+		b.w -= b.r
+		b.r = 0
+	}
+
+	if b.w >= len(b.buf) {
+		panic("bufio: tried to fill full buffer")
+	}
+
+	// Read new data: try a limited number of times.
+	for i := 100; i > 0; i-- {
+		n, err := b.rd.Read(b.buf[b.w:])
+		if n < 0 {
+			panic("bufio: reader returned negative count from Read")
+		}
+		b.w += n
+		if err != nil {
+			b.err = err
+			return
+		}
+		if n > 0 {
+			return
+		}
+	}
+	b.err = io.ErrNoProgress
+}
+
+func (b *Reader) readErr() error {
+	err := b.err
+	b.err = nil
+	return err
+}
+
+// Buffered returns the number of bytes that can be read from the current buffer.
+func (b *Reader) Buffered() int {
+	return b.w - b.r
+}
+
+// Read reads data into p. It returns the number of bytes read into p.
+// The bytes are taken from at most one Read on the underlying Reader, hence n may be less than len(p).
+// At EOF, the count will be zero and err will be io.EOF.
+func (b *Reader) Read(p []byte) (int, error) {
+	n := len(p)
+	if n == 0 {
+		if b.Buffered() > 0 {
+			return 0, nil
+		}
+		return 0, b.readErr()
+	}
+	if b.r == b.w {
+		if b.err != nil {
+			return 0, b.readErr()
+		}
+		if len(p) >= len(b.buf) {
+			// Large read, empty buffer.
+			// Read directly into p to avoid copy.
+			n, b.err = b.rd.Read(p)
+			if n < 0 {
+				panic("bufio: reader returned negative count from Read")
+			}
+			if n > 0 {
+				b.lastByte = int(p[n-1])
+			}
+			return n, b.readErr()
+		}
+		// One read.
+		b.r = 0
+		b.w = 0
+		n, b.err = b.rd.Read(b.buf)
+		if n < 0 {
+			panic("bufio: reader returned negative count from Read")
+		}
+		if n == 0 {
+			return 0, b.readErr()
+		}
+		b.w += n
+	}
+
+	// copy as much as we can
+	n = copyBytes(p, b.buf[b.r:b.w])
+	b.r += n
+	b.lastByte = int(b.buf[b.r-1])
+	return n, nil
+}
+
+// ReadByte reads and returns a single byte. If no byte is available, returns an error.
+func (b *Reader) ReadByte() (byte, error) {
+	for b.r == b.w {
+		if b.err != nil {
+			return 0, b.readErr()
+		}
+		b.fill() // buffer is empty
+	}
+	c := b.buf[b.r]
+	b.r++
+	b.lastByte = int(c)
+	return c, nil
+}
+
+// UnreadByte unreads the last byte. Only the most recently read byte can be unread.
+func (b *Reader) UnreadByte() error {
+	if b.lastByte < 0 || b.r == 0 && b.w > 0 {
+		return ErrInvalidUnreadByte
+	}
+	// b.r > 0 || b.w == 0
+	if b.r > 0 {
+		b.r--
+	} else {
+		// b.r == 0 && b.w == 0
+		b.w = 1
+	}
+	b.buf[b.r] = byte(b.lastByte)
+	b.lastByte = -1
+	return nil
+}
+
+// Peek returns the next n bytes without advancing the reader.
+// If Peek returns fewer than n bytes, it also returns an error explaining why the read is short.
+// The error is ErrBufferFull if n is larger than b's buffer size.
+func (b *Reader) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+	b.lastByte = -1
+
+	for b.w-b.r < n && b.w-b.r < len(b.buf) && b.err == nil {
+		b.fill() // b.w-b.r < len(b.buf) => buffer is not full
+	}
+
+	if n > len(b.buf) {
+		return b.buf[b.r:b.w], ErrBufferFull
+	}
+
+	// 0 <= n <= len(b.buf)
+	var err error
+	avail := b.w - b.r
+	if avail < n {
+		// not enough data in buffer
+		n = avail
+		err = b.readErr()
+		if err == nil {
+			err = ErrBufferFull
+		}
+	}
+	return b.buf[b.r : b.r+n], err
+}
+
+// ReadSlice reads until the first occurrence of delim in the input,
+// returning a slice pointing at the bytes in the buffer.
+// The bytes stop being valid at the next read.
+// ReadSlice fails with error ErrBufferFull if the buffer fills without a delim.
+func (b *Reader) ReadSlice(delim byte) ([]byte, error) {
+	s := 0 // search start index
+	var line []byte
+	var err error
+	for {
+		// Search buffer.
+		i := indexByte(b.buf[b.r+s:b.w], delim)
+		if i >= 0 {
+			i += s
+			line = b.buf[b.r : b.r+i+1]
+			b.r += i + 1
+			break
+		}
+
+		// Pending error?
+		if b.err != nil {
+			line = b.buf[b.r:b.w]
+			b.r = b.w
+			err = b.readErr()
+			break
+		}
+
+		// Buffer full?
+		if b.Buffered() >= len(b.buf) {
+			b.r = b.w
+			line = b.buf
+			err = ErrBufferFull
+			break
+		}
+
+		s = b.w - b.r // do not rescan area we scanned before
+
+		b.fill() // buffer is not full
+	}
+
+	// Handle last byte, if any.
+	i := len(line) - 1
+	if i >= 0 {
+		b.lastByte = int(line[i])
+	}
+
+	return line, err
+}
+
+// ReadBytes reads until the first occurrence of delim in the input,
+// returning a slice containing the data up to and including the delimiter.
+// If ReadBytes encounters an error before finding a delimiter,
+// it returns the data read before the error and the error itself (often io.EOF).
+func (b *Reader) ReadBytes(delim byte) ([]byte, error) {
+	var line []byte
+	for {
+		frag, err := b.ReadSlice(delim)
+		for _, c := range frag {
+			line = append(line, c)
+		}
+		if err != ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+// ReadString reads until the first occurrence of delim in the input,
+// returning a string containing the data up to and including the delimiter.
+func (b *Reader) ReadString(delim byte) (string, error) {
+	line, err := b.ReadBytes(delim)
+	return string(line), err
+}
+
+// Writer implements buffering for an io.Writer object.
+// If an error occurs writing to a Writer, no more data will be accepted and
+// all subsequent writes, and Flush, will return the error.
+// After all data has been written, the client should call the Flush method
+// to guarantee all data has been forwarded to the underlying io.Writer.
+// A Writer of the standard output or the standard error is also flushed by os.Exit.
+type Writer struct {
+	err error
+	buf []byte
+	n   int
+	wr  io.Writer
+}
+
+// stdWriters are the Writers of the standard output and the standard error, which are flushed at exit.
+var stdWriters []*Writer
+
+func flushStdWriters() {
+	for _, b := range stdWriters {
+		b.Flush()
+	}
+}
+
+// registerStdWriter makes b flushed at exit if it writes to the standard output or the standard error.
+func registerStdWriter(b *Writer) {
+	f, ok := b.wr.(interface{ Fd() uintptr })
+	if !ok || f.Fd() != 1 && f.Fd() != 2 {
+		return
+	}
+	for _, w := range stdWriters {
+		if w == b {
+			return
+		}
+	}
+	if len(stdWriters) == 0 {
+		exithook.Add(exithook.Hook{F: flushStdWriters, RunOnFailure: true})
+	}
+	stdWriters = append(stdWriters, b)
+}
+
+// NewWriterSize returns a new Writer whose buffer has at least the specified size.
+// If the argument io.Writer is already a Writer with large enough size, it returns the underlying Writer.
+func NewWriterSize(w io.Writer, size int) *Writer {
+	b, ok := w.(*Writer)
+	if ok && len(b.buf) >= size {
+		return b
+	}
+	if size <= 0 {
+		size = defaultBufSize
+	}
+	b = &Writer{
+		buf: make([]byte, size, size),
+		wr:  w,
+	}
+	registerStdWriter(b)
+	return b
+}
+
+// NewWriter returns a new Writer whose buffer has the default size.
+func NewWriter(w io.Writer) *Writer {
+	return NewWriterSize(w, defaultBufSize)
+}
+
+// Size returns the size of the underlying buffer in bytes.
+func (b *Writer) Size() int {
+	return len(b.buf)
+}
+
+// Reset discards any unflushed buffered data, clears any error, and resets b to write its output to w.
+func (b *Writer) Reset(w io.Writer) {
+	if b.buf == nil {
+		b.buf = make([]byte, defaultBufSize, defaultBufSize)
+	}
+	b.err = nil
+	b.n = 0
+	b.wr = w
+	registerStdWriter(b)
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (b *Writer) Flush() error {
+	if b.err != nil {
+		return b.err
+	}
+	if b.n == 0 {
+		return nil
+	}
+	n, err := b.wr.Write(b.buf[0:b.n])
+	if n < b.n && err == nil {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		if n > 0 && n < b.n {
+			copyBytes(b.buf[0:b.n-n], b.buf[n:b.n])
+		}
+		b.n -= n
+		b.err = err
+		return err
+	}
+	b.n = 0
+	return nil
+}
+
+// Available returns how many bytes are unused in the buffer.
+func (b *Writer) Available() int {
+	return len(b.buf) - b.n
+}
+
+// Buffered returns the number of bytes that have been written into the current buffer.
+func (b *Writer) Buffered() int {
+	return b.n
+}
+
+// Write writes the contents of p into the buffer.
+// It returns the number of bytes written.
+// If nn < len(p), it also returns an error explaining why the write is short.
+func (b *Writer) Write(p []byte) (int, error) {
+	var nn int
+	for len(p) > b.Available() && b.err == nil {
+		var n int
+		if b.Buffered() == 0 {
+			// Large write, empty buffer.
+			// Write directly from p to avoid copy.
+			n, b.err = b.wr.Write(p)
+		} else {
+			n = copyBytes(b.buf[b.n:], p)
+			b.n += n
+			b.Flush()
+		}
+		nn += n
+		p = p[n:]
+	}
+	if b.err != nil {
+		return nn, b.err
+	}
+	n := copyBytes(b.buf[b.n:], p)
+	b.n += n
+	nn += n
+	return nn, nil
+}
+
+// WriteByte writes a single byte.
+func (b *Writer) WriteByte(c byte) error {
+	if b.err != nil {
+		return b.err
+	}
+	if b.Available() <= 0 && b.Flush() != nil {
+		return b.err
+	}
+	b.buf[b.n] = c
+	b.n++
+	return nil
+}
+
+// WriteString writes a string.
+// It returns the number of bytes written.
+// If the count is less than len(s), it also returns an error explaining why the write is short.
+func (b *Writer) WriteString(s string) (int, error) {
+	var nn int
+	for len(s) > b.Available() && b.err == nil {
+		n := 0
+		for n < b.Available() {
+			b.buf[b.n+n] = s[n]
+			n++
+		}
+		b.n += n
+		nn += n
+		s = s[n:]
+		b.Flush()
+	}
+	if b.err != nil {
+		return nn, b.err
+	}
+	for i := 0; i < len(s); i++ {
+		b.buf[b.n+i] = s[i]
+	}
+	b.n += len(s)
+	nn += len(s)
+	return nn, nil
+}
@@ -0,0 +1,5 @@
+// This file is nothing more than a dummy to deceive Goland. Babygo is actually not using this.
+#include "textflag.h"
+
+TEXT	 errors·errors_as(SB), NOSPLIT
+    RET
@@ -0,0 +1,22 @@
+// Package exithook runs functions when the program exits, by os.Exit or by returning from main.
+package exithook
+
+// A Hook is a function to run at exit.
+type Hook struct {
+	F            func() // func to run
+	RunOnFailure bool   // whether to run on non-zero exit code
+}
+
+// Add adds a new exit hook. The hooks run in the reverse order of their registration.
+func Add(h Hook) {
+	exithook_add(h.F, h.RunOnFailure)
+}
+
+// Run runs the exit hooks.
+// If code is not 0, only the hooks with RunOnFailure run.
+func Run(code int) {
+	exithook_run(code)
+}
+
+func exithook_add(f func(), runOnFailure bool)
+func exithook_run(code int)
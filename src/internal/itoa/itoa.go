@@ -0,0 +1,27 @@
+// Simple conversions to avoid depending on strconv.
+
+package itoa
+
+// Itoa converts val to a decimal string.
+func Itoa(val int) string {
+	if val == 0 { // avoid string allocation
+		return "0"
+	}
+	var neg bool
+	if val < 0 {
+		neg = true
+		val = -val
+	}
+	var buf []byte = make([]byte, 20, 20) // big enough for 64bit value base 10
+	i := len(buf)
+	for val > 0 {
+		i--
+		buf[i] = byte('0' + val%10)
+		val = val / 10
+	}
+	if neg {
+		i--
+		buf[i] = '-'
+	}
+	return string(buf[i:])
+}
@@ -0,0 +1,12 @@
+// Package oserror defines errors values used in the os package.
+//
+// These types are defined here to permit the syscall package to reference them.
+package oserror
+
+import "errors"
+
+var ErrInvalid = errors.New("invalid argument")
+var ErrPermission = errors.New("permission denied")
+var ErrExist = errors.New("file already exists")
+var ErrNotExist = errors.New("file does not exist")
+var ErrClosed = errors.New("file already closed")
@@ -0,0 +1,90 @@
+// Package io provides basic interfaces to I/O primitives.
+package io
+
+import "errors"
+
+// Seek whence values.
+const SeekStart int = 0   // seek relative to the origin of the file
+const SeekCurrent int = 1 // seek relative to the current offset
+const SeekEnd int = 2     // seek relative to the end
+
+// EOF is the error returned by Read when no more input is available.
+var EOF = errors.New("EOF")
+
+// ErrUnexpectedEOF means that EOF was encountered in the middle of reading a fixed-size block or data structure.
+var ErrUnexpectedEOF = errors.New("unexpected EOF")
+
+// ErrNoProgress is returned by some clients of a Reader when many calls to Read have failed
+// to return any data or error, usually the sign of a broken Reader implementation.
+var ErrNoProgress = errors.New("multiple Read calls return no data or error")
+
+// ErrShortWrite means that a write accepted fewer bytes than requested but failed to return an explicit error.
+var ErrShortWrite = errors.New("short write")
+
+// Reader is the interface that wraps the basic Read method.
+type Reader interface {
+	Read(p []byte) (int, error)
+}
+
+// Writer is the interface that wraps the basic Write method.
+type Writer interface {
+	Write(p []byte) (int, error)
+}
+
+// Closer is the interface that wraps the basic Close method.
+type Closer interface {
+	Close() error
+}
+
+// Seeker is the interface that wraps the basic Seek method.
+type Seeker interface {
+	Seek(offset int, whence int) (int, error)
+}
+
+// ReadAll reads from r until an error or EOF and returns the data it read.
+// A successful call returns err == nil, not err == EOF.
+func ReadAll(r Reader) ([]byte, error) {
+	b := make([]byte, 0, 512)
+	for {
+		if len(b) == cap(b) {
+			// Add more capacity (let append pick how much).
+			b = append(b, 0)
+			b = b[:len(b)-1]
+		}
+		n, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
+		if err != nil {
+			if err == EOF {
+				err = nil
+			}
+			return b, err
+		}
+	}
+}
+
+// Copy copies from src to dst until either EOF is reached on src or an error occurs.
+// It returns the number of bytes copied and the first error encountered while copying, if any.
+// A successful Copy returns err == nil, not err == EOF.
+func Copy(dst Writer, src Reader) (int, error) {
+	var buf []byte = make([]byte, 32768, 32768)
+	var written int
+	for {
+		nr, er := src.Read(buf)
+		if nr > 0 {
+			nw, ew := dst.Write(buf[0:nr])
+			written = written + nw
+			if ew != nil {
+				return written, ew
+			}
+			if nr != nw {
+				return written, ErrShortWrite
+			}
+		}
+		if er != nil {
+			if er == EOF {
+				return written, nil
+			}
+			return written, er
+		}
+	}
+}
@@ -3,6 +3,3 @@
 
 TEXT	 os·runtime_args(SB), NOSPLIT
     RET
-
-TEXT	 os·runtime_getenv(SB), NOSPLIT
-    RET
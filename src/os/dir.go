@@ -0,0 +1,189 @@
+package os
+
+import "syscall"
+import "unsafe"
+
+func Cstring2string(b *byte) string {
+	var bs []byte
+	for {
+		if b == nil || *b == 0 {
+			break
+		}
+		bs = append(bs, *b)
+		p := uintptr(unsafe.Pointer(b)) + 1
+		b = (*byte)(unsafe.Pointer(p))
+	}
+	return string(bs)
+}
+
+// Translation of http://man7.org/linux/man-pages/man2/getdents64.2.html#top_of_page
+
+//struct linux_dirent64 {
+//	ino64_t        d_ino;    // 8 bytes: 64-bit inode number
+//	off64_t        d_off;    // 8 bytes: 64-bit offset to next structure
+//	unsigned short d_reclen; // 2 bytes: Size of this dirent
+//	unsigned char  d_type;   // 1 byte: File type
+//	char           d_name[]; // Filename (null-terminated)
+//};
+
+type linux_dirent struct {
+	d_ino     int
+	d_off     int
+	d_reclen1 uint16
+	d_type    byte
+	d_name    byte
+}
+
+// Values of linux_dirent.d_type
+const DT_UNKNOWN byte = 0
+const DT_FIFO byte = 1
+const DT_CHR byte = 2
+const DT_DIR byte = 4
+const DT_BLK byte = 6
+const DT_REG byte = 8
+const DT_LNK byte = 10
+const DT_SOCK byte = 12
+
+// readdir reads all the entries of the directory f but . and .., and returns their names and d_type.
+func (f *File) readdir() ([]string, []byte, error) {
+	var buf []byte = make([]byte, 1024, 1024)
+	var names []string
+	var types []byte
+	for {
+		nread, err := syscall.Getdents(f.fd, buf)
+		if err != nil {
+			return names, types, NewSyscallError("readdirent", err)
+		}
+		if nread == 0 {
+			break
+		}
+
+		var bpos int
+		for bpos < nread {
+			var dirp *linux_dirent
+			p := uintptr(unsafe.Pointer(&buf[0])) + uintptr(bpos)
+			dirp = (*linux_dirent)(unsafe.Pointer(p))
+			var bytes *byte = &dirp.d_name
+			var s string = Cstring2string(bytes)
+			bpos = bpos + int(dirp.d_reclen1)
+			if s == "." || s == ".." {
+				continue
+			}
+			names = append(names, s)
+			types = append(types, dirp.d_type)
+		}
+	}
+	return names, types, nil
+}
+
+// Readdirnames reads the contents of the directory associated with file and returns a slice of the names of the files in the directory.
+// All the names are read regardless of n.
+func (f *File) Readdirnames(n int) ([]string, error) {
+	names, _, err := f.readdir()
+	return names, err
+}
+
+// A DirEntry is an entry read from a directory (using the ReadDir function or a File.ReadDir method).
+type DirEntry interface {
+	// Name returns the name of the file (or subdirectory) described by the entry.
+	Name() string
+
+	// IsDir reports whether the entry describes a directory.
+	IsDir() bool
+
+	// Type returns the type bits for the entry.
+	Type() FileMode
+
+	// Info returns the FileInfo for the file or subdirectory described by the entry.
+	Info() (FileInfo, error)
+}
+
+type dirEntry struct {
+	parent string
+	name   string
+	typ    FileMode
+}
+
+func (d *dirEntry) Name() string {
+	return d.name
+}
+
+func (d *dirEntry) IsDir() bool {
+	return d.typ.IsDir()
+}
+
+func (d *dirEntry) Type() FileMode {
+	return d.typ
+}
+
+func (d *dirEntry) Info() (FileInfo, error) {
+	info, err := Lstat(d.parent + "/" + d.name)
+	return info, err
+}
+
+// dtToType converts a d_type to the type bits of a FileMode.
+func dtToType(typ byte) FileMode {
+	switch typ {
+	case DT_BLK:
+		return ModeDevice
+	case DT_CHR:
+		return ModeDevice | ModeCharDevice
+	case DT_DIR:
+		return ModeDir
+	case DT_FIFO:
+		return ModeNamedPipe
+	case DT_LNK:
+		return ModeSymlink
+	case DT_SOCK:
+		return ModeSocket
+	}
+	return 0
+}
+
+// ReadDir reads the contents of the directory associated with the file f and returns a slice of DirEntry values in directory order.
+// All the entries are read regardless of n.
+func (f *File) ReadDir(n int) ([]DirEntry, error) {
+	names, types, err := f.readdir()
+	var entries []DirEntry
+	for i, name := range names {
+		d := &dirEntry{parent: f.name, name: name, typ: dtToType(types[i])}
+		if types[i] == DT_UNKNOWN {
+			// the file system does not report types
+			info, err := Lstat(f.name + "/" + name)
+			if err != nil {
+				continue
+			}
+			d.typ = info.Mode().Type()
+		}
+		entries = append(entries, d)
+	}
+	return entries, err
+}
+
+// ReadDir reads the named directory, returning all its directory entries sorted by filename.
+func ReadDir(name string) ([]DirEntry, error) {
+	f, err := Open(name)
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := f.ReadDir(-1)
+	f.Close()
+	// insertion sort by name
+	for i := 1; i < len(dirs); i++ {
+		for j := i; j > 0 && lessString(dirs[j].Name(), dirs[j-1].Name()); j-- {
+			d := dirs[j]
+			dirs[j] = dirs[j-1]
+			dirs[j-1] = d
+		}
+	}
+	return dirs, err
+}
+
+func lessString(a string, b string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
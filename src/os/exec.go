@@ -0,0 +1,149 @@
+package os
+
+import "errors"
+import "internal/itoa"
+import "syscall"
+
+// ProcAttr holds the attributes that will be applied to a new process started by StartProcess.
+type ProcAttr struct {
+	// If Dir is non-empty, the child changes into the directory before creating the process.
+	Dir string
+	// If Env is non-nil, it gives the environment variables for the new process in the form returned by Environ.
+	// If it is nil, the result of Environ will be used.
+	Env []string
+	// Files specifies the open files inherited by the new process.
+	// The first three entries correspond to standard input, standard output, and standard error.
+	Files []*File
+}
+
+// ErrProcessDone indicates a Process has finished.
+var ErrProcessDone = errors.New("os: process already finished")
+
+// Process stores the information about a process created by StartProcess.
+type Process struct {
+	Pid  int
+	done bool
+}
+
+// ProcessState stores information about a process, as reported by Wait.
+type ProcessState struct {
+	pid    int
+	status syscall.WaitStatus
+}
+
+// StartProcess starts a new process with the program, arguments and attributes specified by name, argv and attr.
+// The argv slice will become os.Args in the new process, so it normally starts with the program name.
+func StartProcess(name string, argv []string, attr *ProcAttr) (*Process, error) {
+	sysattr := &syscall.ProcAttr{
+		Dir: attr.Dir,
+		Env: attr.Env,
+	}
+	if sysattr.Env == nil {
+		sysattr.Env = syscall.Environ()
+	}
+	for _, f := range attr.Files {
+		sysattr.Files = append(sysattr.Files, f.Fd())
+	}
+	pid, err := syscall.ForkExec(name, argv, sysattr)
+	if err != nil {
+		return nil, &PathError{Op: "fork/exec", Path: name, Err: err}
+	}
+	return &Process{Pid: pid}, nil
+}
+
+// Wait waits for the Process to exit, and then returns a ProcessState describing its status and an error, if any.
+func (p *Process) Wait() (*ProcessState, error) {
+	if p.Pid == -1 {
+		return nil, syscall.EINVAL
+	}
+	var status syscall.WaitStatus
+	var err error
+	for {
+		_, err = syscall.Wait4(p.Pid, &status, 0, nil)
+		if !isErrno(err, syscall.EINTR) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, NewSyscallError("wait", err)
+	}
+	p.done = true
+	return &ProcessState{pid: p.Pid, status: status}, nil
+}
+
+// Signal sends a signal to the Process.
+func (p *Process) Signal(sig Signal) error {
+	if p.Pid == -1 {
+		return errors.New("os: process already released")
+	}
+	if p.done {
+		return ErrProcessDone
+	}
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return errors.New("os: unsupported signal type")
+	}
+	err := syscall.Kill(p.Pid, s)
+	if err != nil {
+		return NewSyscallError("kill", err)
+	}
+	return nil
+}
+
+// Kill causes the Process to exit immediately.
+func (p *Process) Kill() error {
+	return p.Signal(Kill)
+}
+
+// Release releases any resources associated with the Process p, rendering it unusable in the future.
+func (p *Process) Release() error {
+	p.Pid = -1
+	return nil
+}
+
+// Pid returns the process id of the exited process.
+func (p *ProcessState) Pid() int {
+	return p.pid
+}
+
+// Exited reports whether the program has exited.
+func (p *ProcessState) Exited() bool {
+	return p.status.Exited()
+}
+
+// Success reports whether the program exited successfully, such as with exit status 0 on Unix.
+func (p *ProcessState) Success() bool {
+	return p.status.ExitStatus() == 0
+}
+
+// Sys returns system-dependent exit information about the process, a syscall.WaitStatus.
+func (p *ProcessState) Sys() interface{} {
+	return p.status
+}
+
+// ExitCode returns the exit code of the exited process, or -1 if the process hasn't exited or was terminated by a signal.
+func (p *ProcessState) ExitCode() int {
+	if p == nil {
+		return -1
+	}
+	return p.status.ExitStatus()
+}
+
+func (p *ProcessState) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	status := p.status
+	res := ""
+	if status.Exited() {
+		res = "exit status " + itoa.Itoa(status.ExitStatus())
+	} else if status.Signaled() {
+		res = "signal: " + status.Signal().String()
+	} else if status.Stopped() {
+		res = "stop signal: " + status.StopSignal().String()
+	}
+	if status.CoreDump() {
+		res = res + " (core dumped)"
+	}
+	return res
+}
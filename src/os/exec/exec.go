@@ -0,0 +1,416 @@
+// Package exec runs external commands.
+//
+// There are no goroutines to pump pipes, so standard streams which are not
+// *os.File are passed to the command through temporary files.
+package exec
+
+import "errors"
+import "internal/itoa"
+import "io"
+import "os"
+import "syscall"
+
+// Error is returned by LookPath when it fails to classify a file as an executable.
+type Error struct {
+	// Name is the file name for which the error occurred.
+	Name string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *Error) Error() string {
+	return "exec: \"" + e.Name + "\": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrNotFound is the error resulting if a path search failed to find an executable file.
+var ErrNotFound = errors.New("executable file not found in $PATH")
+
+// An ExitError reports an unsuccessful exit by a command.
+type ExitError struct {
+	ProcessState *os.ProcessState
+
+	// Stderr holds a subset of the standard error output from the Cmd.Output method
+	// if standard error was not otherwise being collected.
+	Stderr []byte
+}
+
+func (e *ExitError) Error() string {
+	return e.ProcessState.String()
+}
+
+func (e *ExitError) ExitCode() int {
+	return e.ProcessState.ExitCode()
+}
+
+func (e *ExitError) Exited() bool {
+	return e.ProcessState.Exited()
+}
+
+func (e *ExitError) Success() bool {
+	return e.ProcessState.Success()
+}
+
+func (e *ExitError) Pid() int {
+	return e.ProcessState.Pid()
+}
+
+func (e *ExitError) Sys() interface{} {
+	return e.ProcessState.Sys()
+}
+
+// Cmd represents an external command being prepared or run.
+// A Cmd cannot be reused after calling its Run, Output or CombinedOutput methods.
+type Cmd struct {
+	// Path is the path of the command to run.
+	Path string
+
+	// Args holds command line arguments, including the command as Args[0].
+	Args []string
+
+	// Env specifies the environment of the process, each entry of the form "key=value".
+	// If Env is nil, the new process uses the current process's environment.
+	Env []string
+
+	// Dir specifies the working directory of the command.
+	// If Dir is the empty string, Run runs the command in the calling process's current directory.
+	Dir string
+
+	// Stdin specifies the process's standard input.
+	// If Stdin is nil, the process reads from the null device (os.DevNull).
+	Stdin io.Reader
+
+	// Stdout and Stderr specify the process's standard output and error.
+	// If either is nil, Run connects the corresponding file descriptor to the null device.
+	// If Stdout and Stderr are the same writer, both are written to it.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Process is the underlying process, once started.
+	Process *os.Process
+
+	// ProcessState contains information about an exited process, available after a call to Wait or Run.
+	ProcessState *os.ProcessState
+
+	// Err holds the error of the LookPath done by Command, if any.
+	Err error
+
+	childFiles  []*os.File // the files passed to the process
+	closeAfter  []*os.File // the files to close after Wait
+	copyOutputs []*output  // the outputs to copy back after Wait
+}
+
+// output is a temporary file collecting the output of the process for a writer.
+type output struct {
+	f *os.File
+	w io.Writer
+}
+
+// Command returns the Cmd struct to execute the named program with the given arguments.
+// If name contains no path separators, Command uses LookPath to resolve name to a complete path if possible.
+func Command(name string, arg ...string) *Cmd {
+	cmd := &Cmd{
+		Path: name,
+	}
+	cmd.Args = append(cmd.Args, name)
+	for _, a := range arg {
+		cmd.Args = append(cmd.Args, a)
+	}
+	if !containsSlash(name) {
+		lp, err := LookPath(name)
+		if lp != "" {
+			cmd.Path = lp
+		}
+		if err != nil {
+			cmd.Err = err
+		}
+	}
+	return cmd
+}
+
+// String returns a human-readable description of c.
+func (c *Cmd) String() string {
+	b := c.Path
+	for i, a := range c.Args {
+		if i > 0 {
+			b = b + " " + a
+		}
+	}
+	return b
+}
+
+var tempSeq int
+
+// tempFile creates a temporary file and unlinks it at once: only its descriptor remains.
+func tempFile() (*os.File, error) {
+	for {
+		tempSeq++
+		name := "/tmp/babygo-exec-" + itoa.Itoa(syscall.Getpid()) + "-" + itoa.Itoa(tempSeq)
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 384) // 0600
+		if err != nil {
+			if os.IsExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		os.Remove(name)
+		return f, nil
+	}
+}
+
+func (c *Cmd) closeDescriptors() {
+	for _, f := range c.closeAfter {
+		f.Close()
+	}
+	c.closeAfter = nil
+}
+
+func (c *Cmd) stdin() (*os.File, error) {
+	if c.Stdin == nil {
+		f, err := os.Open(os.DevNull)
+		if err != nil {
+			return nil, err
+		}
+		c.closeAfter = append(c.closeAfter, f)
+		return f, nil
+	}
+	f, ok := c.Stdin.(*os.File)
+	if ok {
+		return f, nil
+	}
+	tmp, err := tempFile()
+	if err != nil {
+		return nil, err
+	}
+	c.closeAfter = append(c.closeAfter, tmp)
+	_, err = io.Copy(tmp, c.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tmp.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	return tmp, nil
+}
+
+func (c *Cmd) writerDescriptor(w io.Writer) (*os.File, error) {
+	if w == nil {
+		f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		c.closeAfter = append(c.closeAfter, f)
+		return f, nil
+	}
+	f, ok := w.(*os.File)
+	if ok {
+		return f, nil
+	}
+	tmp, err := tempFile()
+	if err != nil {
+		return nil, err
+	}
+	c.closeAfter = append(c.closeAfter, tmp)
+	c.copyOutputs = append(c.copyOutputs, &output{f: tmp, w: w})
+	return tmp, nil
+}
+
+// Start starts the specified command but does not wait for it to complete.
+func (c *Cmd) Start() error {
+	if c.Path == "" && c.Err == nil {
+		c.Err = errors.New("exec: no command")
+	}
+	if c.Err != nil {
+		return c.Err
+	}
+	if c.Process != nil {
+		return errors.New("exec: already started")
+	}
+
+	fd, err := c.stdin()
+	if err != nil {
+		c.closeDescriptors()
+		return err
+	}
+	c.childFiles = append(c.childFiles, fd)
+	fd, err = c.writerDescriptor(c.Stdout)
+	if err != nil {
+		c.closeDescriptors()
+		return err
+	}
+	c.childFiles = append(c.childFiles, fd)
+	if c.Stderr != nil && c.Stderr == c.Stdout {
+		fd = c.childFiles[1]
+	} else {
+		fd, err = c.writerDescriptor(c.Stderr)
+		if err != nil {
+			c.closeDescriptors()
+			return err
+		}
+	}
+	c.childFiles = append(c.childFiles, fd)
+
+	p, err := os.StartProcess(c.Path, c.Args, &os.ProcAttr{
+		Dir:   c.Dir,
+		Env:   c.Env,
+		Files: c.childFiles,
+	})
+	if err != nil {
+		c.closeDescriptors()
+		return err
+	}
+	c.Process = p
+	return nil
+}
+
+// Wait waits for the command to exit, and copies its output to the writers which are not *os.File.
+// The returned error is nil if the command runs and exits with a zero exit status.
+// If the command fails to run or doesn't complete successfully, the error is of type *ExitError.
+func (c *Cmd) Wait() error {
+	if c.Process == nil {
+		return errors.New("exec: not started")
+	}
+	if c.ProcessState != nil {
+		return errors.New("exec: Wait was already called")
+	}
+	state, err := c.Process.Wait()
+	if err == nil {
+		c.ProcessState = state
+	}
+	for _, o := range c.copyOutputs {
+		_, e := o.f.Seek(0, io.SeekStart)
+		if e == nil {
+			_, e = io.Copy(o.w, o.f)
+		}
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	c.copyOutputs = nil
+	c.closeDescriptors()
+	if err != nil {
+		return err
+	}
+	if !state.Success() {
+		return &ExitError{ProcessState: state}
+	}
+	return nil
+}
+
+// Run starts the specified command and waits for it to complete.
+func (c *Cmd) Run() error {
+	err := c.Start()
+	if err != nil {
+		return err
+	}
+	err = c.Wait()
+	return err
+}
+
+// buffer is a Writer collecting the output of a command.
+type buffer struct {
+	b []byte
+}
+
+func (b *buffer) Write(p []byte) (int, error) {
+	for _, c := range p {
+		b.b = append(b.b, c)
+	}
+	return len(p), nil
+}
+
+// Output runs the command and returns its standard output.
+// If c.Stderr was nil, Output populates ExitError.Stderr.
+func (c *Cmd) Output() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	stdout := &buffer{}
+	c.Stdout = stdout
+	var stderr *buffer
+	if c.Stderr == nil {
+		stderr = &buffer{}
+		c.Stderr = stderr
+	}
+	err := c.Run()
+	if err != nil && stderr != nil {
+		ee, ok := err.(*ExitError)
+		if ok {
+			ee.Stderr = stderr.b
+		}
+	}
+	return stdout.b, err
+}
+
+// CombinedOutput runs the command and returns its combined standard output and standard error.
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	if c.Stderr != nil {
+		return nil, errors.New("exec: Stderr already set")
+	}
+	b := &buffer{}
+	c.Stdout = b
+	c.Stderr = b
+	err := c.Run()
+	return b.b, err
+}
+
+// LookPath searches for an executable named file in the directories named by the PATH environment variable.
+// If file contains a slash, it is tried directly and the PATH is not consulted.
+func LookPath(file string) (string, error) {
+	if containsSlash(file) {
+		err := findExecutable(file)
+		if err != nil {
+			return "", &Error{Name: file, Err: err}
+		}
+		return file, nil
+	}
+	path := os.Getenv("PATH")
+	start := 0
+	for i := 0; i <= len(path); i++ {
+		if i == len(path) || path[i] == ':' {
+			dir := path[start:i]
+			start = i + 1
+			if dir == "" {
+				// Unix shell semantics: path element "" means "."
+				dir = "."
+			}
+			p := dir + "/" + file
+			err := findExecutable(p)
+			if err == nil {
+				return p, nil
+			}
+		}
+	}
+	return "", &Error{Name: file, Err: ErrNotFound}
+}
+
+func findExecutable(file string) error {
+	d, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	m := d.Mode()
+	if m.IsDir() {
+		return syscall.EISDIR
+	}
+	if int(m.Perm())&73 != 0 { // 0111
+		return nil
+	}
+	return os.ErrPermission
+}
+
+func containsSlash(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '/' {
+			return true
+		}
+	}
+	return false
+}
@@ -1,9 +1,12 @@
 package os
 
+import "internal/exithook"
+import "internal/oserror"
+import "io"
 import "syscall"
-import "unsafe"
+import "time"
 
-const SYS_EXIT int = 60
+const SYS_EXIT_GROUP int = 231
 
 var Args []string
 
@@ -12,155 +15,437 @@ var Stdout *File
 var Stderr *File
 
 type File struct {
-	fd int
+	fd   int
+	name string
 }
 
-const FILE_SIZE int = 2000000
-
 const O_READONLY int = 0
+const O_RDONLY int = 0
+const O_WRONLY int = 1
 const O_RDWR int = 2
 const O_CREATE int = 64       // 0x40
+const O_EXCL int = 128        // 0x80
 const O_TRUNC int = 512       // 0x200
+const O_APPEND int = 1024     // 0x400
 const O_CLOSEXEC int = 524288 // 0x80000
 
-func Open(name string) (*File, error) {
-	var fd int
-	fd, _ = syscall.Open(name, O_READONLY, 438)
-	if fd < 0 {
-		panic("unable to create file " + name)
+// DevNull is the name of the operating system's null device.
+const DevNull string = "/dev/null"
+
+// Portable analogs of some common system call errors.
+var ErrInvalid = oserror.ErrInvalid       // "invalid argument"
+var ErrPermission = oserror.ErrPermission // "permission denied"
+var ErrExist = oserror.ErrExist           // "file already exists"
+var ErrNotExist = oserror.ErrNotExist     // "file does not exist"
+var ErrClosed = oserror.ErrClosed         // "file already closed"
+
+// PathError records an error and the operation and file path that caused it.
+type PathError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// SyscallError records an error from a specific system call.
+type SyscallError struct {
+	Syscall string
+	Err     error
+}
+
+func (e *SyscallError) Error() string {
+	return e.Syscall + ": " + e.Err.Error()
+}
+
+func (e *SyscallError) Unwrap() error {
+	return e.Err
+}
+
+// NewSyscallError returns, as an error, a new SyscallError with the given system call name and error details.
+// As a convenience, if err is nil, NewSyscallError returns nil.
+func NewSyscallError(syscall string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &SyscallError{Syscall: syscall, Err: err}
+}
+
+// IsExist reports whether the error is known to report that a file or directory already exists.
+func IsExist(err error) bool {
+	return underlyingErrorIs(err, ErrExist)
+}
+
+// IsNotExist reports whether the error is known to report that a file or directory does not exist.
+func IsNotExist(err error) bool {
+	return underlyingErrorIs(err, ErrNotExist)
+}
+
+// IsPermission reports whether the error is known to report that permission is denied.
+func IsPermission(err error) bool {
+	return underlyingErrorIs(err, ErrPermission)
+}
+
+func underlyingErrorIs(err error, target error) bool {
+	err = underlyingError(err)
+	if err == target {
+		return true
+	}
+	e, ok := err.(syscall.Errno)
+	return ok && e.Is(target)
+}
+
+// underlyingError returns the underlying error for known os error types.
+func underlyingError(err error) error {
+	pe, ok := err.(*PathError)
+	if ok {
+		return pe.Err
 	}
+	se, ok := err.(*SyscallError)
+	if ok {
+		return se.Err
+	}
+	return err
+}
 
-	f := new(File)
-	f.fd = fd
-	return f, nil
+func Open(name string) (*File, error) {
+	f, err := OpenFile(name, O_READONLY, 0)
+	return f, err
 }
 
 func Create(name string) (*File, error) {
-	var fd int
-	fd, _ = syscall.Open(name, O_RDWR|O_CREATE|O_TRUNC|O_CLOSEXEC, 438)
-	if fd < 0 {
-		panic("unable to create file " + name)
+	f, err := OpenFile(name, O_RDWR|O_CREATE|O_TRUNC, 438)
+	return f, err
+}
+
+// OpenFile opens the named file with specified flag (O_RDONLY etc.).
+// If the file does not exist, and the O_CREATE flag is passed, it is created with mode perm (before umask).
+func OpenFile(name string, flag int, perm FileMode) (*File, error) {
+	fd, err := syscall.Open(name, flag|O_CLOSEXEC, int(perm.Perm()))
+	if err != nil {
+		return nil, &PathError{Op: "open", Path: name, Err: err}
 	}
+	return &File{fd: fd, name: name}, nil
+}
 
-	f := new(File)
-	f.fd = fd
-	return f, nil
+// Name returns the name of the file as presented to Open.
+func (f *File) Name() string {
+	return f.name
 }
 
 func (f *File) Fd() uintptr {
 	return uintptr(f.fd)
 }
 
-func Cstring2string(b *byte) string {
-	var bs []byte
-	for {
-		if b == nil || *b == 0 {
-			break
-		}
-		bs = append(bs, *b)
-		p := uintptr(unsafe.Pointer(b)) + 1
-		b = (*byte)(unsafe.Pointer(p))
+func (f *File) Close() error {
+	if f == nil {
+		return ErrInvalid
 	}
-	return string(bs)
+	err := syscall.Close(f.fd)
+	if err != nil {
+		return &PathError{Op: "close", Path: f.name, Err: err}
+	}
+	return nil
 }
 
-// Translation of http://man7.org/linux/man-pages/man2/getdents64.2.html#top_of_page
+func (f *File) Write(p []byte) (int, error) {
+	if f == nil {
+		return 0, ErrInvalid
+	}
+	var n int
+	for n < len(p) {
+		m, err := syscall.Write(f.fd, p[n:])
+		if err != nil {
+			return n, &PathError{Op: "write", Path: f.name, Err: err}
+		}
+		n = n + m
+	}
+	return n, nil
+}
 
-//struct linux_dirent64 {
-//	ino64_t        d_ino;    // 8 bytes: 64-bit inode number
-//	off64_t        d_off;    // 8 bytes: 64-bit offset to next structure
-//	unsigned short d_reclen; // 2 bytes: Size of this dirent
-//	unsigned char  d_type;   // 1 byte: File type
-//	char           d_name[]; // Filename (null-terminated)
-//};
+// Read reads up to len(b) bytes from the File and stores them in b.
+// At end of file, Read returns 0, io.EOF.
+func (f *File) Read(b []byte) (int, error) {
+	if f == nil {
+		return 0, ErrInvalid
+	}
+	n, err := syscall.Read(f.fd, b)
+	if err != nil {
+		return 0, &PathError{Op: "read", Path: f.name, Err: err}
+	}
+	if n == 0 && len(b) > 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
 
-type linux_dirent struct {
-	d_ino     int
-	d_off     int
-	d_reclen1 uint16
-	d_type    byte
-	d_name    byte
+// Seek sets the offset for the next Read or Write on file to offset, interpreted
+// according to whence: 0 means relative to the origin of the file, 1 means
+// relative to the current offset, and 2 means relative to the end.
+// It returns the new offset and an error, if any.
+func (f *File) Seek(offset int, whence int) (int, error) {
+	if f == nil {
+		return 0, ErrInvalid
+	}
+	ret, err := syscall.Seek(f.fd, offset, whence)
+	if err != nil {
+		return 0, &PathError{Op: "seek", Path: f.name, Err: err}
+	}
+	return ret, nil
 }
 
-func (f *File) Readdirnames(n int) ([]string, error) {
-	var fd uintptr = f.Fd()
-	var buf []byte = make([]byte, 1024, 1024)
-	var counter int
-	var entries []string
+// ReadFile reads the named file and returns the contents.
+// A successful call returns err == nil, not err == EOF.
+func ReadFile(name string) ([]byte, error) {
+	f, err := Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var size int
+	var st syscall.Stat_t
+	if syscall.Fstat(f.fd, &st) == nil {
+		size = st.Size
+	}
+	size++ // one byte for final read at EOF
+
+	// If a file claims a small size, read at least 512 bytes.
+	// In particular, files in Linux's /proc claim size 0 but
+	// then do not work right if read in small pieces.
+	if size < 512 {
+		size = 512
+	}
+
+	data := make([]byte, 0, size)
 	for {
-		nread, _ := syscall.Getdents(int(fd), buf)
-		if nread == -1 {
-			panic("getdents failed")
+		if len(data) >= cap(data) {
+			data = append(data, 0)
+			data = data[:len(data)-1]
 		}
-		if nread == 0 {
-			break
-		}
-
-		var bpos int
-		for bpos < nread {
-			var dirp *linux_dirent
-			p := uintptr(unsafe.Pointer(&buf[0])) + uintptr(bpos)
-			dirp = (*linux_dirent)(unsafe.Pointer(p))
-			var bytes *byte = &dirp.d_name
-			var s string = Cstring2string(bytes)
-			bpos = bpos + int(dirp.d_reclen1)
-			counter++
-			if s == "." || s == ".." {
-				continue
+		n, err := f.Read(data[len(data):cap(data)])
+		data = data[:len(data)+n]
+		if err != nil {
+			f.Close()
+			if err == io.EOF {
+				return data, nil
 			}
-			entries = append(entries, s)
+			return data, err
 		}
 	}
-	f.Close()
-	return entries, nil
 }
 
-func (f *File) Close() error {
-	err := syscall.Close(f.fd)
+// WriteFile writes data to the named file, creating it if necessary.
+// If the file does not exist, WriteFile creates it with permissions perm (before umask);
+// otherwise WriteFile truncates it before writing, without changing permissions.
+func WriteFile(name string, data []byte, perm FileMode) error {
+	f, err := OpenFile(name, O_WRONLY|O_CREATE|O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	err1 := f.Close()
+	if err1 != nil && err == nil {
+		err = err1
+	}
 	return err
 }
 
-func (f *File) Write(p []byte) (int, error) {
-	syscall.Write(f.fd, p)
-	return 0, nil
+// Mkdir creates a new directory with the specified name and permission bits (before umask).
+func Mkdir(name string, perm FileMode) error {
+	err := syscall.Mkdir(name, int(perm.Perm()))
+	if err != nil {
+		return &PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
 }
 
-func ReadFile(filename string) ([]uint8, error) {
-	// @TODO check error
-	var fd int
-	fd, _ = syscall.Open(filename, O_READONLY, 0)
-	if fd < 0 {
-		panic("syscall.Open failed: " + filename)
+// Remove removes the named file or (empty) directory.
+func Remove(name string) error {
+	e := syscall.Unlink(name)
+	if e == nil {
+		return nil
 	}
-	var buf = make([]uint8, FILE_SIZE, FILE_SIZE)
-	var n int
-	n, _ = syscall.Read(fd, buf)
-	syscall.Close(fd)
-	var readbytes = buf[0:n]
-	return readbytes, nil
+	e1 := syscall.Rmdir(name)
+	if e1 == nil {
+		return nil
+	}
+
+	// Both failed: figure out which error to return.
+	// Unlink fails with EISDIR (or EPERM) for a directory, and Rmdir with ENOTDIR for a file.
+	if !isErrno(e1, syscall.ENOTDIR) {
+		e = e1
+	}
+	return &PathError{Op: "remove", Path: name, Err: e}
+}
+
+func isErrno(err error, errno syscall.Errno) bool {
+	e, ok := err.(syscall.Errno)
+	return ok && e == errno
+}
+
+// LinkError records an error during a link or symlink or rename system call and the paths that caused it.
+type LinkError struct {
+	Op  string
+	Old string
+	New string
+	Err error
+}
+
+func (e *LinkError) Error() string {
+	return e.Op + " " + e.Old + " " + e.New + ": " + e.Err.Error()
+}
+
+func (e *LinkError) Unwrap() error {
+	return e.Err
+}
+
+// Rename renames (moves) oldpath to newpath.
+// If newpath already exists and is not a directory, Rename replaces it.
+func Rename(oldpath string, newpath string) error {
+	err := syscall.Rename(oldpath, newpath)
+	if err != nil {
+		return &LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	return nil
+}
+
+// Chtimes changes the access and modification times of the named file.
+func Chtimes(name string, atime time.Time, mtime time.Time) error {
+	var ts []syscall.Timespec = make([]syscall.Timespec, 2, 2)
+	ts[0] = syscall.NsecToTimespec(atime.UnixNano())
+	ts[1] = syscall.NsecToTimespec(mtime.UnixNano())
+	err := syscall.UtimesNano(name, ts)
+	if err != nil {
+		return &PathError{Op: "chtimes", Path: name, Err: err}
+	}
+	return nil
 }
 
+// Readlink returns the destination of the named symbolic link.
+func Readlink(name string) (string, error) {
+	var buf []byte = make([]byte, 4096, 4096)
+	n, err := syscall.Readlink(name, buf)
+	if err != nil {
+		return "", &PathError{Op: "readlink", Path: name, Err: err}
+	}
+	return string(buf[0:n]), nil
+}
+
+// Chdir changes the current working directory to the named directory.
+func Chdir(dir string) error {
+	err := syscall.Chdir(dir)
+	if err != nil {
+		return &PathError{Op: "chdir", Path: dir, Err: err}
+	}
+	return nil
+}
+
+// A Signal represents an operating system signal.
+type Signal interface {
+	String() string
+	Signal() // to distinguish from other Stringers
+}
+
+// The signals guaranteed to be present on all systems.
+var Interrupt Signal // syscall.SIGINT
+var Kill Signal      // syscall.SIGKILL
+
 func init() {
 	Args = runtime_args()
+	Interrupt = syscall.SIGINT
+	Kill = syscall.SIGKILL
 	Stdin = &File{
-		fd: 0,
+		fd:   0,
+		name: "/dev/stdin",
 	}
 	Stdout = &File{
-		fd: 1,
+		fd:   1,
+		name: "/dev/stdout",
 	}
 	Stderr = &File{
-		fd: 2,
+		fd:   2,
+		name: "/dev/stderr",
 	}
 }
 
+// Getenv retrieves the value of the environment variable named by the key.
+// It returns the value, which will be empty if the variable is not present.
+// To distinguish between an empty value and an unset value, use LookupEnv.
 func Getenv(key string) string {
-	v := runtime_getenv(key)
+	v, _ := syscall.Getenv(key)
 	return v
 }
 
+// LookupEnv retrieves the value of the environment variable named by the key.
+// If the variable is present in the environment the value (which may be empty) is returned and the boolean is true.
+// Otherwise the returned value will be empty and the boolean will be false.
+func LookupEnv(key string) (string, bool) {
+	v, ok := syscall.Getenv(key)
+	return v, ok
+}
+
+// Setenv sets the value of the environment variable named by the key.
+// The environment is passed to the processes started by StartProcess.
+func Setenv(key string, value string) error {
+	err := syscall.Setenv(key, value)
+	if err != nil {
+		return NewSyscallError("setenv", err)
+	}
+	return nil
+}
+
+// Unsetenv unsets a single environment variable.
+func Unsetenv(key string) error {
+	err := syscall.Unsetenv(key)
+	return err
+}
+
+// Clearenv deletes all environment variables.
+func Clearenv() {
+	syscall.Clearenv()
+}
+
+// Environ returns a copy of strings representing the environment, in the form "key=value".
+func Environ() []string {
+	env := syscall.Environ()
+	return env
+}
+
+// Getwd returns the absolute path of the current directory.
+func Getwd() (string, error) {
+	var buf []byte = make([]byte, 4096, 4096)
+	n, err := syscall.Getcwd(buf)
+	if err != nil {
+		return "", NewSyscallError("getwd", err)
+	}
+	// n includes the null terminator
+	return string(buf[0 : n-1]), nil
+}
+
+// Getpid returns the process id of the caller.
+func Getpid() int {
+	return syscall.Getpid()
+}
+
+// Executable returns the path name of the executable that started the current process.
+func Executable() (string, error) {
+	p, err := Readlink("/proc/self/exe")
+	return p, err
+}
+
+// Exit causes the current program to exit with the given status code.
+// Conventionally, code zero indicates success, non-zero an error.
+// The exit hooks run first, which flush the buffered writers registered by internal/exithook;
+// with a non-zero code, only the hooks to run on failure do.
 func Exit(status int) {
-	syscall.Syscall(uintptr(SYS_EXIT), uintptr(status), 0, 0)
+	exithook.Run(status)
+	syscall.Syscall(uintptr(SYS_EXIT_GROUP), uintptr(status), 0, 0)
 }
 
 func runtime_args() []string
-func runtime_getenv(key string) string
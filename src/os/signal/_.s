@@ -0,0 +1,11 @@
+// This file is nothing more than a dummy to deceive Goland. Babygo is actually not using this.
+#include "textflag.h"
+
+TEXT	 signal·signal_enable(SB), NOSPLIT
+    RET
+
+TEXT	 signal·signal_disable(SB), NOSPLIT
+    RET
+
+TEXT	 signal·signal_ignore(SB), NOSPLIT
+    RET
@@ -0,0 +1,170 @@
+// Package signal implements access to incoming signals.
+//
+// Babygo has no channels yet, so a signal is delivered by calling the functions
+// registered for it by Notify. They are called from the signal handler,
+// with the other signals blocked.
+package signal
+
+import "os"
+import "syscall"
+import "unsafe"
+
+const numSig int = 65
+
+type handler struct {
+	fn   func(sig os.Signal)
+	mask [65]bool
+}
+
+var handlers []*handler
+
+func signum(sig os.Signal) int {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return -1
+	}
+	i := int(s)
+	if i < 0 || i >= numSig {
+		return -1
+	}
+	return i
+}
+
+func funcPC(fn func(sig os.Signal)) uintptr {
+	return *(*uintptr)(unsafe.Pointer(&fn))
+}
+
+// wanted reports whether a handler is registered for sig.
+func wanted(sig int) bool {
+	for _, h := range handlers {
+		if h.mask[sig] {
+			return true
+		}
+	}
+	return false
+}
+
+// catchable reports whether sig can be delivered by Notify.
+// SIGKILL and SIGSTOP cannot be caught, and faults always panic.
+func catchable(sig int) bool {
+	switch syscall.Signal(sig) {
+	case syscall.SIGKILL, syscall.SIGSTOP, syscall.SIGBUS, syscall.SIGFPE, syscall.SIGSEGV:
+		return false
+	}
+	return 0 < sig && sig < numSig
+}
+
+// Notify causes fn to be called with the incoming signals.
+// If no signals are provided, all incoming signals are delivered to fn.
+// Otherwise, just the provided signals will.
+// Calling Notify again with the same fn adds signals to it.
+func Notify(fn func(sig os.Signal), sig ...os.Signal) {
+	if funcPC(fn) == 0 {
+		panic("os/signal: Notify using nil func")
+	}
+
+	var h *handler
+	for _, hh := range handlers {
+		if funcPC(hh.fn) == funcPC(fn) {
+			h = hh
+		}
+	}
+	if h == nil {
+		h = &handler{fn: fn}
+		handlers = append(handlers, h)
+	}
+
+	if len(sig) == 0 {
+		for n := 1; n < numSig; n++ {
+			if catchable(n) {
+				h.mask[n] = true
+				signal_enable(n, process)
+			}
+		}
+		return
+	}
+	for _, s := range sig {
+		n := signum(s)
+		if n >= 0 && catchable(n) {
+			h.mask[n] = true
+			signal_enable(n, process)
+		}
+	}
+}
+
+// Stop causes fn not to be called any more.
+// The signals no longer wanted by any function are reset to their default behavior.
+func Stop(fn func(sig os.Signal)) {
+	var rest []*handler
+	var stopped *handler
+	for _, h := range handlers {
+		if funcPC(h.fn) == funcPC(fn) {
+			stopped = h
+		} else {
+			rest = append(rest, h)
+		}
+	}
+	if stopped == nil {
+		return
+	}
+	handlers = rest
+	for n := 1; n < numSig; n++ {
+		if stopped.mask[n] && !wanted(n) {
+			signal_disable(n)
+		}
+	}
+}
+
+// Ignore causes the provided signals to be ignored.
+// If no signals are provided, all incoming signals will be ignored.
+func Ignore(sig ...os.Signal) {
+	cancel(sig, true)
+}
+
+// Reset undoes the effect of any prior calls to Notify for the provided signals.
+// If no signals are provided, all signal handlers will be reset.
+func Reset(sig ...os.Signal) {
+	cancel(sig, false)
+}
+
+func cancel(sigs []os.Signal, ignore bool) {
+	var mask [65]bool
+	if len(sigs) == 0 {
+		for n := 1; n < numSig; n++ {
+			mask[n] = true
+		}
+	}
+	for _, s := range sigs {
+		n := signum(s)
+		if n >= 0 {
+			mask[n] = true
+		}
+	}
+	for n := 1; n < numSig; n++ {
+		if !mask[n] || !catchable(n) {
+			continue
+		}
+		for _, h := range handlers {
+			h.mask[n] = false
+		}
+		if ignore {
+			signal_ignore(n)
+		} else {
+			signal_disable(n)
+		}
+	}
+}
+
+// process is called by the runtime for each incoming signal.
+func process(sig int) {
+	for _, h := range handlers {
+		if h.mask[sig] {
+			var fn func(sig os.Signal) = h.fn
+			fn(syscall.Signal(sig))
+		}
+	}
+}
+
+func signal_enable(sig int, fn func(sig int))
+func signal_disable(sig int)
+func signal_ignore(sig int)
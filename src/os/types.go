@@ -0,0 +1,202 @@
+package os
+
+import "syscall"
+import "time"
+
+// A FileInfo describes a file and is returned by Stat and Lstat.
+type FileInfo interface {
+	Name() string       // base name of the file
+	Size() int          // length in bytes for regular files; system-dependent for others
+	Mode() FileMode     // file mode bits
+	ModTime() time.Time // modification time
+	IsDir() bool        // abbreviation for Mode().IsDir()
+	Sys() interface{}   // underlying data source, a *syscall.Stat_t
+}
+
+// A FileMode represents a file's mode and permission bits.
+type FileMode int
+
+// The single letters are the abbreviations used by the String method's formatting.
+const ModeDir FileMode = 2147483648      // d: is a directory
+const ModeAppend FileMode = 1073741824   // a: append-only
+const ModeExclusive FileMode = 536870912 // l: exclusive use
+const ModeTemporary FileMode = 268435456 // T: temporary file; Plan 9 only
+const ModeSymlink FileMode = 134217728   // L: symbolic link
+const ModeDevice FileMode = 67108864     // D: device file
+const ModeNamedPipe FileMode = 33554432  // p: named pipe (FIFO)
+const ModeSocket FileMode = 16777216     // S: Unix domain socket
+const ModeSetuid FileMode = 8388608      // u: setuid
+const ModeSetgid FileMode = 4194304      // g: setgid
+const ModeCharDevice FileMode = 2097152  // c: Unix character device, when ModeDevice is set
+const ModeSticky FileMode = 1048576      // t: sticky
+const ModeIrregular FileMode = 524288    // ?: non-regular file; nothing else is known about this file
+
+// Mask for the type bits. For regular files, none will be set.
+const ModeType FileMode = 2401763328 // ModeDir | ModeSymlink | ModeNamedPipe | ModeSocket | ModeDevice | ModeCharDevice | ModeIrregular
+
+const ModePerm FileMode = 511 // 0777, Unix permission bits
+
+func (m FileMode) String() string {
+	str := "dalTLDpSugct?"
+	var buf []byte = make([]byte, 0, 32)
+	bit := ModeDir
+	for i := 0; i < len(str); i++ {
+		if m&bit != 0 {
+			buf = append(buf, str[i])
+		}
+		bit = bit / 2
+	}
+	if len(buf) == 0 {
+		buf = append(buf, '-')
+	}
+	rwx := "rwxrwxrwx"
+	bit = 256
+	for i := 0; i < len(rwx); i++ {
+		if m&bit != 0 {
+			buf = append(buf, rwx[i])
+		} else {
+			buf = append(buf, '-')
+		}
+		bit = bit / 2
+	}
+	return string(buf)
+}
+
+// IsDir reports whether m describes a directory.
+func (m FileMode) IsDir() bool {
+	return m&ModeDir != 0
+}
+
+// IsRegular reports whether m describes a regular file.
+func (m FileMode) IsRegular() bool {
+	return m&ModeType == 0
+}
+
+// Perm returns the Unix permission bits in m (m & ModePerm).
+func (m FileMode) Perm() FileMode {
+	return m & ModePerm
+}
+
+// Type returns type bits in m (m & ModeType).
+func (m FileMode) Type() FileMode {
+	return m & ModeType
+}
+
+// A fileStat is the implementation of FileInfo returned by Stat and Lstat.
+type fileStat struct {
+	name    string
+	size    int
+	mode    FileMode
+	modTime time.Time
+	sys     syscall.Stat_t
+}
+
+func (fs *fileStat) Name() string {
+	return fs.name
+}
+
+func (fs *fileStat) Size() int {
+	return fs.size
+}
+
+func (fs *fileStat) Mode() FileMode {
+	return fs.mode
+}
+
+func (fs *fileStat) ModTime() time.Time {
+	return fs.modTime
+}
+
+func (fs *fileStat) IsDir() bool {
+	return fs.mode.IsDir()
+}
+
+func (fs *fileStat) Sys() interface{} {
+	return &fs.sys
+}
+
+func fillFileStatFromSys(fs *fileStat, name string) {
+	fs.name = basename(name)
+	fs.size = fs.sys.Size
+	fs.modTime = time.Unix(fs.sys.MtimSec, fs.sys.MtimNsec)
+	mode := fs.sys.Mode()
+	fs.mode = FileMode(mode) & ModePerm
+	switch mode & syscall.S_IFMT {
+	case syscall.S_IFBLK:
+		fs.mode = fs.mode | ModeDevice
+	case syscall.S_IFCHR:
+		fs.mode = fs.mode | ModeDevice | ModeCharDevice
+	case syscall.S_IFDIR:
+		fs.mode = fs.mode | ModeDir
+	case syscall.S_IFIFO:
+		fs.mode = fs.mode | ModeNamedPipe
+	case syscall.S_IFLNK:
+		fs.mode = fs.mode | ModeSymlink
+	case syscall.S_IFSOCK:
+		fs.mode = fs.mode | ModeSocket
+	}
+	if mode&syscall.S_ISGID != 0 {
+		fs.mode = fs.mode | ModeSetgid
+	}
+	if mode&syscall.S_ISUID != 0 {
+		fs.mode = fs.mode | ModeSetuid
+	}
+	if mode&syscall.S_ISVTX != 0 {
+		fs.mode = fs.mode | ModeSticky
+	}
+}
+
+// basename removes trailing slashes and the leading directory name from path name.
+func basename(name string) string {
+	i := len(name) - 1
+	// Remove trailing slashes
+	for i > 0 && name[i] == '/' {
+		name = name[:i]
+		i--
+	}
+	// Remove leading directory name
+	for j := i - 1; j >= 0; j-- {
+		if name[j] == '/' {
+			return name[j+1:]
+		}
+	}
+	return name
+}
+
+// Stat returns a FileInfo describing the named file.
+// If there is an error, it will be of type *PathError.
+func Stat(name string) (FileInfo, error) {
+	fs := &fileStat{}
+	err := syscall.Stat(name, &fs.sys)
+	if err != nil {
+		return nil, &PathError{Op: "stat", Path: name, Err: err}
+	}
+	fillFileStatFromSys(fs, name)
+	return fs, nil
+}
+
+// Lstat returns a FileInfo describing the named file.
+// If the file is a symbolic link, the returned FileInfo describes the symbolic link.
+func Lstat(name string) (FileInfo, error) {
+	fs := &fileStat{}
+	err := syscall.Lstat(name, &fs.sys)
+	if err != nil {
+		return nil, &PathError{Op: "lstat", Path: name, Err: err}
+	}
+	fillFileStatFromSys(fs, name)
+	return fs, nil
+}
+
+// Stat returns the FileInfo structure describing file.
+func (f *File) Stat() (FileInfo, error) {
+	if f == nil {
+		return nil, ErrInvalid
+	}
+	fs := &fileStat{}
+	err := syscall.Fstat(f.fd, &fs.sys)
+	if err != nil {
+		return nil, &PathError{Op: "stat", Path: f.name, Err: err}
+	}
+	fillFileStatFromSys(fs, f.name)
+	return fs, nil
+}
@@ -1,7 +1,106 @@
+// Package reflect inspects the dynamic types and values of interface values,
+// through the type descriptors emitted by the compiler.
 package reflect
 
 import "unsafe"
 
+// A Kind represents the specific kind of type that a Type represents.
+type Kind int
+
+// The kinds of the types babygo does not have are never produced, and exist for compatibility with Go.
+const Invalid Kind = 0
+const Bool Kind = 1
+const Int Kind = 2
+const Int8 Kind = 3
+const Int16 Kind = 4
+const Int32 Kind = 5
+const Int64 Kind = 6
+const Uint Kind = 7
+const Uint8 Kind = 8
+const Uint16 Kind = 9
+const Uint32 Kind = 10
+const Uint64 Kind = 11
+const Uintptr Kind = 12
+const Float32 Kind = 13
+const Float64 Kind = 14
+const Complex64 Kind = 15
+const Complex128 Kind = 16
+const Array Kind = 17
+const Chan Kind = 18
+const Func Kind = 19
+const Interface Kind = 20
+const Map Kind = 21
+const Pointer Kind = 22
+const Slice Kind = 23
+const String Kind = 24
+const Struct Kind = 25
+const UnsafePointer Kind = 26
+
+// Ptr is the old name for the Pointer kind.
+const Ptr Kind = 22
+
+// String returns the name of k.
+func (k Kind) String() string {
+	switch k {
+	case Invalid:
+		return "invalid"
+	case Bool:
+		return "bool"
+	case Int:
+		return "int"
+	case Int8:
+		return "int8"
+	case Int16:
+		return "int16"
+	case Int32:
+		return "int32"
+	case Int64:
+		return "int64"
+	case Uint:
+		return "uint"
+	case Uint8:
+		return "uint8"
+	case Uint16:
+		return "uint16"
+	case Uint32:
+		return "uint32"
+	case Uint64:
+		return "uint64"
+	case Uintptr:
+		return "uintptr"
+	case Float32:
+		return "float32"
+	case Float64:
+		return "float64"
+	case Complex64:
+		return "complex64"
+	case Complex128:
+		return "complex128"
+	case Array:
+		return "array"
+	case Chan:
+		return "chan"
+	case Func:
+		return "func"
+	case Interface:
+		return "interface"
+	case Map:
+		return "map"
+	case Pointer:
+		return "ptr"
+	case Slice:
+		return "slice"
+	case String:
+		return "string"
+	case Struct:
+		return "struct"
+	case UnsafePointer:
+		return "unsafe.Pointer"
+	}
+	return "kind" + itoa(int(k))
+}
+
+// Type is the representation of a Go type.
 type Type struct {
 	typ *rtype
 }
@@ -11,18 +110,46 @@ type emptyInterface struct {
 	data unsafe.Pointer // pointer to the actual data of the dynamic type
 }
 
+// rtype is the descriptor of a dynamic type emitted by the compiler, see emitDynamicTypes.
 type rtype struct {
-	id   int
-	name string // string representation of type
+	id       int
+	name     string // string representation of type
+	kind     int    // the kind printed by the runtime
+	errorFn  uintptr
+	stringFn uintptr
+	size     int
+	methods  uintptr
+	nmethods int
+	rkind    Kind
+	elem     *rtype
+	key      *rtype
+	len      int
+	fields   unsafe.Pointer // [nfields]structField
+	nfields  int
 }
 
-func TypeOf(x interface{}) *Type {
-	eface := (*emptyInterface)(unsafe.Pointer(&x))
+// structField is a field in the descriptor of a struct type.
+type structField struct {
+	name   string
+	typ    *rtype
+	offset int
+}
+
+func toType(t *rtype) *Type {
+	if t == nil {
+		return nil
+	}
 	return &Type{
-		typ: eface.typ,
+		typ: t,
 	}
 }
 
+// TypeOf returns the dynamic type of x. If x is a nil interface value, TypeOf returns nil.
+func TypeOf(x interface{}) *Type {
+	eface := (*emptyInterface)(unsafe.Pointer(&x))
+	return toType(eface.typ)
+}
+
 func (t *Type) String() string {
 	return t.typ.String()
 }
@@ -30,3 +157,141 @@ func (t *Type) String() string {
 func (t *rtype) String() string {
 	return t.name
 }
+
+// Name returns the type's name within its package for a named type, and "" for other types.
+func (t *Type) Name() string {
+	s := t.typ.name
+	if s == "" || s[0] == '*' || s[0] == '[' || hasPrefix(s, "map[") || hasPrefix(s, "struct{") || hasPrefix(s, "func") || hasPrefix(s, "interface{") {
+		return ""
+	}
+	i := len(s) - 1
+	for i >= 0 && s[i] != '.' {
+		i--
+	}
+	return s[i+1:]
+}
+
+// Kind returns the specific kind of this type.
+func (t *Type) Kind() Kind {
+	return t.typ.rkind
+}
+
+// Size returns the number of bytes needed to store a value of the given type.
+func (t *Type) Size() uintptr {
+	return uintptr(t.typ.size)
+}
+
+// Elem returns a type's element type. It panics if the type's Kind is not Array, Map, Pointer or Slice.
+func (t *Type) Elem() *Type {
+	if t.typ.elem == nil {
+		panic("reflect: Elem of invalid type " + t.typ.name)
+	}
+	return toType(t.typ.elem)
+}
+
+// Key returns a map type's key type. It panics if the type's Kind is not Map.
+func (t *Type) Key() *Type {
+	if t.typ.rkind != Map {
+		panic("reflect: Key of non-map type " + t.typ.name)
+	}
+	return toType(t.typ.key)
+}
+
+// Len returns an array type's length. It panics if the type's Kind is not Array.
+func (t *Type) Len() int {
+	if t.typ.rkind != Array {
+		panic("reflect: Len of non-array type " + t.typ.name)
+	}
+	return t.typ.len
+}
+
+// NumField returns a struct type's field count. It panics if the type's Kind is not Struct.
+func (t *Type) NumField() int {
+	if t.typ.rkind != Struct {
+		panic("reflect: NumField of non-struct type " + t.typ.name)
+	}
+	return t.typ.nfields
+}
+
+// A StructField describes a single field in a struct.
+type StructField struct {
+	Name    string // the field name
+	PkgPath string // empty for exported fields
+	Type    *Type  // field type
+	Offset  uintptr
+}
+
+// IsExported reports whether the field is exported.
+func (f StructField) IsExported() bool {
+	return f.PkgPath == ""
+}
+
+// Field returns a struct type's i'th field. It panics if the type's Kind is not Struct or i is not in the range [0, NumField()).
+func (t *Type) Field(i int) StructField {
+	f := t.typ.field(i)
+	sf := StructField{
+		Name:   f.name,
+		Type:   toType(f.typ),
+		Offset: uintptr(f.offset),
+	}
+	if !isExported(f.name) {
+		// the descriptor does not know the package path: use the package name
+		sf.PkgPath = pkgName(t.typ.name)
+	}
+	return sf
+}
+
+func (t *rtype) field(i int) *structField {
+	if t.rkind != Struct {
+		panic("reflect: Field of non-struct type " + t.name)
+	}
+	if i < 0 || i >= t.nfields {
+		panic("reflect: Field index out of bounds")
+	}
+	return (*structField)(unsafe.Pointer(uintptr(t.fields) + uintptr(i*32)))
+}
+
+// isExported reports whether name starts with an upper-case letter.
+func isExported(name string) bool {
+	return len(name) > 0 && name[0] >= 'A' && name[0] <= 'Z'
+}
+
+// pkgName returns the package name of a named type, or "" for other types.
+func pkgName(typeName string) string {
+	for i := 0; i < len(typeName); i++ {
+		if typeName[i] == '.' {
+			return typeName[0:i]
+		}
+		if typeName[i] == '*' || typeName[i] == '[' || typeName[i] == '{' {
+			return ""
+		}
+	}
+	return ""
+}
+
+func hasPrefix(s string, prefix string) bool {
+	return len(s) >= len(prefix) && s[0:len(prefix)] == prefix
+}
+
+func itoa(ival int) string {
+	if ival == 0 {
+		return "0"
+	}
+	var buf [20]uint8
+	i := len(buf)
+	neg := ival < 0
+	for ival != 0 {
+		d := ival % 10
+		if d < 0 {
+			d = -d
+		}
+		i--
+		buf[i] = uint8('0' + d)
+		ival = ival / 10
+	}
+	if neg {
+		i--
+		buf[i] = '-'
+	}
+	return string(buf[i:])
+}
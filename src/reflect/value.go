@@ -0,0 +1,343 @@
+package reflect
+
+import "unsafe"
+
+// Value is the reflection interface to a Go value.
+// The zero Value represents no value: its IsValid method returns false.
+type Value struct {
+	typ  *rtype
+	ptr  unsafe.Pointer // pointer to the value
+	flag int
+}
+
+// flagRO marks a value obtained through an unexported struct field, which cannot be made an interface value.
+const flagRO int = 1
+
+// ValueOf returns a new Value initialized to the concrete value stored in the interface i.
+// ValueOf(nil) returns the zero Value.
+func ValueOf(i interface{}) Value {
+	eface := (*emptyInterface)(unsafe.Pointer(&i))
+	if eface.typ == nil {
+		return Value{}
+	}
+	return Value{typ: eface.typ, ptr: eface.data}
+}
+
+// A ValueError occurs when a Value method is invoked on a Value that does not support it.
+type ValueError struct {
+	Method string
+	Kind   Kind
+}
+
+func (e *ValueError) Error() string {
+	if e.Kind == Invalid {
+		return "reflect: call of " + e.Method + " on zero Value"
+	}
+	return "reflect: call of " + e.Method + " on " + e.Kind.String() + " Value"
+}
+
+func (v Value) mustBe(method string, k Kind) {
+	if v.Kind() != k {
+		panic(&ValueError{Method: method, Kind: v.Kind()})
+	}
+}
+
+// IsValid reports whether v represents a value.
+func (v Value) IsValid() bool {
+	return v.typ != nil
+}
+
+// Kind returns v's Kind. If v is the zero Value, Kind returns Invalid.
+func (v Value) Kind() Kind {
+	if v.typ == nil {
+		return Invalid
+	}
+	return v.typ.rkind
+}
+
+// Type returns v's type.
+func (v Value) Type() *Type {
+	if v.typ == nil {
+		panic(&ValueError{Method: "reflect.Value.Type", Kind: Invalid})
+	}
+	return toType(v.typ)
+}
+
+// word returns the word v holds, for the kinds stored in a word.
+func (v Value) word() uintptr {
+	return *(*uintptr)(v.ptr)
+}
+
+// Bool returns v's underlying value. It panics if v's kind is not Bool.
+func (v Value) Bool() bool {
+	v.mustBe("reflect.Value.Bool", Bool)
+	return *(*bool)(v.ptr)
+}
+
+// Int returns v's underlying value. It panics if v's Kind is not Int or Int32.
+func (v Value) Int() int {
+	switch v.Kind() {
+	case Int:
+		return *(*int)(v.ptr)
+	case Int32:
+		// the low 4 bytes of a word
+		i := *(*int)(v.ptr) % 4294967296
+		if i >= 2147483648 {
+			i = i - 4294967296
+		}
+		return i
+	}
+	panic(&ValueError{Method: "reflect.Value.Int", Kind: v.Kind()})
+	return 0
+}
+
+// Uint returns v's underlying value. It panics if v's Kind is not Uint8, Uint16 or Uintptr.
+func (v Value) Uint() int {
+	switch v.Kind() {
+	case Uint8:
+		return int(*(*uint8)(v.ptr))
+	case Uint16:
+		return int(*(*uint16)(v.ptr))
+	case Uintptr:
+		return int(*(*uintptr)(v.ptr))
+	}
+	panic(&ValueError{Method: "reflect.Value.Uint", Kind: v.Kind()})
+	return 0
+}
+
+// String returns the string v's underlying value, as a string.
+// Unlike the other getters, it does not panic if v's Kind is not String.
+// Instead, it returns a string of the form "<T value>" where T is v's type.
+func (v Value) String() string {
+	switch v.Kind() {
+	case Invalid:
+		return "<invalid Value>"
+	case String:
+		return *(*string)(v.ptr)
+	}
+	return "<" + v.typ.name + " Value>"
+}
+
+// sliceHeader is the runtime representation of a slice.
+type sliceHeader struct {
+	data unsafe.Pointer
+	len  int
+	cap  int
+}
+
+// Len returns v's length. It panics if v's Kind is not Array, Map, Slice or String.
+func (v Value) Len() int {
+	switch v.Kind() {
+	case Array:
+		return v.typ.len
+	case Slice:
+		return (*sliceHeader)(v.ptr).len
+	case String:
+		return len(*(*string)(v.ptr))
+	case Map:
+		m := (*mapHeader)(unsafe.Pointer(v.word()))
+		if m == nil {
+			return 0
+		}
+		return m.length
+	}
+	panic(&ValueError{Method: "reflect.Value.Len", Kind: v.Kind()})
+	return 0
+}
+
+var uint8Type *rtype
+
+// Index returns v's i'th element. It panics if v's Kind is not Array, Slice, or String or i is out of range.
+func (v Value) Index(i int) Value {
+	switch v.Kind() {
+	case Array:
+		if i < 0 || i >= v.typ.len {
+			panic("reflect: array index out of range")
+		}
+		return Value{typ: v.typ.elem, ptr: unsafe.Pointer(uintptr(v.ptr) + uintptr(i*v.typ.elem.size)), flag: v.flag}
+	case Slice:
+		s := (*sliceHeader)(v.ptr)
+		if i < 0 || i >= s.len {
+			panic("reflect: slice index out of range")
+		}
+		return Value{typ: v.typ.elem, ptr: unsafe.Pointer(uintptr(s.data) + uintptr(i*v.typ.elem.size)), flag: v.flag}
+	case String:
+		s := *(*string)(v.ptr)
+		if i < 0 || i >= len(s) {
+			panic("reflect: string index out of range")
+		}
+		if uint8Type == nil {
+			uint8Type = ValueOf(uint8(0)).typ
+		}
+		b := s[i]
+		return Value{typ: uint8Type, ptr: unsafe.Pointer(&b), flag: v.flag}
+	}
+	panic(&ValueError{Method: "reflect.Value.Index", Kind: v.Kind()})
+	return Value{}
+}
+
+// NumField returns the number of fields in the struct v. It panics if v's Kind is not Struct.
+func (v Value) NumField() int {
+	v.mustBe("reflect.Value.NumField", Struct)
+	return v.typ.nfields
+}
+
+// Field returns the i'th field of the struct v. It panics if v's Kind is not Struct or i is out of range.
+func (v Value) Field(i int) Value {
+	v.mustBe("reflect.Value.Field", Struct)
+	f := v.typ.field(i)
+	fl := v.flag
+	if !isExported(f.name) {
+		fl = flagRO
+	}
+	return Value{typ: f.typ, ptr: unsafe.Pointer(uintptr(v.ptr) + uintptr(f.offset)), flag: fl}
+}
+
+// Elem returns the value that the interface v contains or that the pointer v points to.
+// It panics if v's Kind is not Interface or Pointer. It returns the zero Value if v is nil.
+func (v Value) Elem() Value {
+	switch v.Kind() {
+	case Interface:
+		eface := (*emptyInterface)(v.ptr)
+		if eface.typ == nil {
+			return Value{}
+		}
+		return Value{typ: eface.typ, ptr: eface.data, flag: v.flag}
+	case Pointer:
+		p := *(*unsafe.Pointer)(v.ptr)
+		if p == nil {
+			return Value{}
+		}
+		return Value{typ: v.typ.elem, ptr: p, flag: v.flag}
+	}
+	panic(&ValueError{Method: "reflect.Value.Elem", Kind: v.Kind()})
+	return Value{}
+}
+
+// IsNil reports whether its argument v is nil.
+// The argument must be a func, interface, map, pointer, or slice value; if it is not, IsNil panics.
+func (v Value) IsNil() bool {
+	switch v.Kind() {
+	case Func, Map, Pointer, UnsafePointer:
+		return v.word() == 0
+	case Slice:
+		return (*sliceHeader)(v.ptr).data == nil
+	case Interface:
+		return (*emptyInterface)(v.ptr).typ == nil
+	}
+	panic(&ValueError{Method: "reflect.Value.IsNil", Kind: v.Kind()})
+	return false
+}
+
+// Pointer returns v's value as a uintptr.
+// It panics if v's Kind is not Func, Map, Pointer, Slice, or UnsafePointer.
+func (v Value) Pointer() uintptr {
+	switch v.Kind() {
+	case Func, Map, Pointer, UnsafePointer:
+		return v.word()
+	case Slice:
+		return uintptr((*sliceHeader)(v.ptr).data)
+	}
+	panic(&ValueError{Method: "reflect.Value.Pointer", Kind: v.Kind()})
+	return 0
+}
+
+// CanInterface reports whether Interface can be used without panicking.
+func (v Value) CanInterface() bool {
+	if v.typ == nil {
+		panic(&ValueError{Method: "reflect.Value.CanInterface", Kind: Invalid})
+	}
+	return v.flag&flagRO == 0
+}
+
+// Interface returns v's current value as an interface{}.
+// It panics if the Value was obtained by accessing unexported struct fields.
+func (v Value) Interface() interface{} {
+	if v.typ == nil {
+		panic(&ValueError{Method: "reflect.Value.Interface", Kind: Invalid})
+	}
+	if v.flag&flagRO != 0 {
+		panic("reflect.Value.Interface: cannot return value obtained from unexported field or method")
+	}
+	if v.typ.rkind == Interface {
+		return *(*interface{})(v.ptr)
+	}
+	// copy the value, as an interface value does not share its storage
+	var i interface{}
+	eface := (*emptyInterface)(unsafe.Pointer(&i))
+	eface.typ = v.typ
+	eface.data = v.ptr
+	if v.typ.size > 0 {
+		buf := make([]uint8, v.typ.size, v.typ.size)
+		for j := 0; j < v.typ.size; j++ {
+			buf[j] = *(*uint8)(unsafe.Pointer(uintptr(v.ptr) + uintptr(j)))
+		}
+		eface.data = unsafe.Pointer(&buf[0])
+	}
+	return i
+}
+
+// mapHeader and mapItem mirror the representation of maps in the runtime.
+type mapHeader struct {
+	first     *mapItem
+	length    int
+	valueSize uintptr
+}
+
+type mapItem struct {
+	next  *mapItem
+	key   interface{}
+	value uintptr
+}
+
+// A MapIter is an iterator for ranging over a map. See Value.MapRange.
+type MapIter struct {
+	m    Value
+	it   *mapItem
+	done bool
+}
+
+// MapRange returns a range iterator for a map. It panics if v's Kind is not Map.
+// Call Next to advance the iterator, and Key/Value to access each entry.
+func (v Value) MapRange() *MapIter {
+	v.mustBe("reflect.Value.MapRange", Map)
+	return &MapIter{m: v}
+}
+
+// Next advances the map iterator and reports whether there is another entry.
+// It returns false when iter is exhausted.
+func (iter *MapIter) Next() bool {
+	if iter.done {
+		return false
+	}
+	if iter.it == nil {
+		m := (*mapHeader)(unsafe.Pointer(iter.m.word()))
+		if m != nil {
+			iter.it = m.first
+		}
+	} else {
+		iter.it = iter.it.next
+	}
+	if iter.it == nil {
+		iter.done = true
+		return false
+	}
+	return true
+}
+
+// Key returns the key of iter's current map entry.
+func (iter *MapIter) Key() Value {
+	if iter.it == nil {
+		panic("MapIter.Key called before Next")
+	}
+	eface := (*emptyInterface)(unsafe.Pointer(&iter.it.key))
+	return Value{typ: iter.m.typ.key, ptr: eface.data, flag: iter.m.flag}
+}
+
+// Value returns the value of iter's current map entry.
+func (iter *MapIter) Value() Value {
+	if iter.it == nil {
+		panic("MapIter.Value called before Next")
+	}
+	return Value{typ: iter.m.typ.elem, ptr: unsafe.Pointer(iter.it.value), flag: iter.m.flag}
+}
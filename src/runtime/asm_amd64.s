@@ -19,6 +19,7 @@ runtime.rt0_go:
   movq %rax, 8(%rsp)
   callq runtime.args
 
+  movq 16(%rsp), %rdi # argc
   addq $32, %rsp
 
   movq %rdi, %rax # argc
@@ -33,8 +34,7 @@ runtime.rt0_go:
 
   callq runtime.__initGlobals
   callq runtime.schedinit
-  callq main.__initGlobals
-  callq os.init # set os.Args
+  callq main.__initPackages # initialize the other packages
 
   // wrapper to runtime.main
   leaq runtime.mainPC(%rip), %rax # entry
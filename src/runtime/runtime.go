@@ -3,14 +3,15 @@ package runtime
 
 import "unsafe"
 
-const heapSize uintptr = 620205360
+// The heap is reserved by brk at startup, and its pages are only backed by memory when they are used.
+// It has to hold everything the self-hosted compiler allocates while building itself, as nothing is freed.
+const heapSize uintptr = 1240410720
 
 var heapHead uintptr
 var heapCurrent uintptr
 var heapTail uintptr
 
 const SYS_BRK int = 12
-const SYS_EXIT int = 60
 
 var argc int
 var argv **uint8
@@ -38,6 +39,216 @@ func schedinit() {
 	futexp = malloc(4) // futexp must be aligned on a four-byte boundary.
 	goargs()
 	envInit()
+	initsig()
+}
+
+// Hardware faults are turned into panics.
+// The signal handler runs on an alternate stack, and makes the faulting thread call sigpanic
+// as if the faulting instruction did.
+
+const _SIGBUS int = 7
+const _SIGFPE int = 8
+const _SIGSEGV int = 11
+const _SIGALRM int = 14
+
+const SYS_RT_SIGACTION uintptr = 13
+const SYS_SIGALTSTACK uintptr = 131
+
+const _SA_SIGINFO int = 4
+const _SA_RESTORER int = 67108864 // 0x04000000
+const _SA_ONSTACK int = 134217728 // 0x08000000
+const _SA_RESTART int = 268435456 // 0x10000000
+
+const _SIG_DFL uintptr = 0
+const _SIG_IGN uintptr = 1
+
+const _SIG_SETMASK int = 2
+
+// sigBlockMask blocks every signal but SIGBUS, SIGFPE and SIGSEGV,
+// so that a fault still panics while the signals are blocked.
+const sigBlockMask int = -1217
+
+const sigStackSize uintptr = 65536
+
+// struct sigaction of the kernel
+type sigactiont struct {
+	handler  uintptr
+	flags    int
+	restorer uintptr
+	mask     int
+}
+
+// stack_t
+type stackt struct {
+	sp    uintptr
+	flags int
+	size  uintptr
+}
+
+// the last signal turned into a panic
+var sigNo int
+var sigCode int
+var sigAddr uintptr
+var sigPC uintptr
+
+func initsig() {
+	var st stackt
+	st.sp = malloc(sigStackSize)
+	st.size = sigStackSize
+	Syscall(SYS_SIGALTSTACK, uintptr(unsafe.Pointer(&st)), 0, 0)
+
+	setsig(_SIGSEGV, sigtrampPC())
+	setsig(_SIGBUS, sigtrampPC())
+	setsig(_SIGFPE, sigtrampPC())
+}
+
+func sigtrampPC() uintptr {
+	var handler func() = sigtramp
+	return *(*uintptr)(unsafe.Pointer(&handler))
+}
+
+// setsig sets the handler of sig to fn, which is sigtramp, _SIG_DFL or _SIG_IGN.
+func setsig(sig int, fn uintptr) {
+	var sa sigactiont
+	sa.handler = fn
+	if fn != _SIG_DFL && fn != _SIG_IGN {
+		var restorer func() = sigreturn
+		sa.flags = _SA_SIGINFO | _SA_ONSTACK | _SA_RESTORER | _SA_RESTART
+		sa.restorer = *(*uintptr)(unsafe.Pointer(&restorer))
+		sa.mask = sigBlockMask
+	}
+	rt_sigaction(uintptr(sig), uintptr(unsafe.Pointer(&sa)), 0)
+}
+
+// sighandler is called by sigtramp with the siginfo_t and the ucontext_t of the signal.
+func sighandler(sig int, info uintptr, ctx uintptr) {
+	if sig != _SIGSEGV && sig != _SIGBUS && sig != _SIGFPE {
+		sigqueue(sig)
+		return
+	}
+	sigNo = sig
+	code := *(*int)(unsafe.Pointer(info + 8))
+	sigCode = code % 4294967296                      // si_code
+	sigAddr = *(*uintptr)(unsafe.Pointer(info + 16)) // si_addr
+
+	// general registers in uc_mcontext
+	var rsp *uintptr = (*uintptr)(unsafe.Pointer(ctx + 160))
+	var rip *uintptr = (*uintptr)(unsafe.Pointer(ctx + 168))
+	sigPC = *rip
+
+	// push the faulting pc as the return address of sigpanic
+	*rsp = *rsp - 8
+	var ret *uintptr = (*uintptr)(unsafe.Pointer(*rsp))
+	*ret = sigPC
+	var fn func() = sigpanic
+	*rip = *(*uintptr)(unsafe.Pointer(&fn))
+}
+
+func sigpanic() {
+	var name string
+	var msg string
+	if sigNo == _SIGFPE {
+		name = "SIGFPE: floating-point exception"
+		if sigCode == 1 {
+			msg = "integer divide by zero"
+		} else if sigCode == 2 {
+			msg = "integer overflow"
+		} else {
+			msg = "floating point error"
+		}
+	} else {
+		if sigNo == _SIGBUS {
+			name = "SIGBUS: bus error"
+		} else {
+			name = "SIGSEGV: segmentation violation"
+		}
+		if sigAddr < 4096 {
+			msg = "invalid memory address or nil pointer dereference"
+		} else {
+			msg = "unexpected fault address 0x" + hex(sigAddr)
+		}
+	}
+	panic("runtime error: " + msg + "\n[signal " + name + " code=0x" + hex(uintptr(sigCode)) + " addr=0x" + hex(sigAddr) + " pc=0x" + hex(sigPC) + "]")
+}
+
+// Other signals are delivered to the function registered by os/signal.
+// The handler calls it right away, unless the signal interrupted malloc,
+// in which case malloc calls it when it is done.
+
+const _NSIG int = 65
+
+var sigRecv func(sig int)
+var sigPending [65]bool
+var sigNPending int
+var sigDelivering bool
+var mallocing bool
+
+func sigqueue(sig int) {
+	if !sigPending[sig] {
+		sigPending[sig] = true
+		sigNPending++
+	}
+	if mallocing || sigDelivering {
+		return
+	}
+	sigdeliver()
+}
+
+// sigdeliver calls sigRecv for each pending signal. The signals must be blocked.
+func sigdeliver() {
+	sigDelivering = true
+	for sigNPending > 0 {
+		for sig := 1; sig < _NSIG; sig++ {
+			if sigPending[sig] {
+				sigPending[sig] = false
+				sigNPending--
+				if sig == _SIGALRM && timerEnabled {
+					var tfn func() = timerRecv
+					tfn()
+				} else {
+					var fn func(sig int) = sigRecv
+					fn(sig)
+				}
+			}
+		}
+	}
+	sigDelivering = false
+}
+
+// sigdeliverBlocked delivers the pending signals with the signals blocked.
+func sigdeliverBlocked() {
+	var set int = sigBlockMask
+	var old int
+	rt_sigprocmask(uintptr(_SIG_SETMASK), uintptr(unsafe.Pointer(&set)), uintptr(unsafe.Pointer(&old)))
+	sigdeliver()
+	rt_sigprocmask(uintptr(_SIG_SETMASK), uintptr(unsafe.Pointer(&old)), 0)
+}
+
+// This func has an alias in os/signal package
+func signal_enable(sig int, fn func(sig int)) {
+	sigRecv = fn
+	setsig(sig, sigtrampPC())
+}
+
+// This func has an alias in os/signal package
+func signal_disable(sig int) {
+	setsig(sig, _SIG_DFL)
+}
+
+// This func has an alias in os/signal package
+func signal_ignore(sig int) {
+	setsig(sig, _SIG_IGN)
+}
+
+// SIGALRM is delivered to the time package instead, once it has started a timer.
+var timerRecv func()
+var timerEnabled bool
+
+// This func has an alias in time package
+func time_timerEnable(fn func()) {
+	timerRecv = fn
+	timerEnabled = true
+	setsig(_SIGALRM, sigtrampPC())
 }
 
 var mainStarted bool
@@ -48,9 +259,43 @@ func main() {
 	mainStarted = true
 	var fn = main_main
 	fn()
+	exithook_run(0)
 	exit(0)
 }
 
+// Exit hooks are registered by internal/exithook, and run by os.Exit and when main returns.
+type exitHook struct {
+	f            func()
+	runOnFailure bool
+}
+
+var exitHooks []*exitHook
+var runningExitHooks bool
+
+// This func has an alias in internal/exithook package
+func exithook_add(f func(), runOnFailure bool) {
+	exitHooks = append(exitHooks, &exitHook{f: f, runOnFailure: runOnFailure})
+}
+
+// exithook_run runs the exit hooks in the reverse order of their registration.
+// If code is not 0, only the hooks to run on failure are run. A hook calling os.Exit does not run them again.
+// This func has an alias in internal/exithook package
+func exithook_run(code int) {
+	if runningExitHooks {
+		return
+	}
+	runningExitHooks = true
+	for i := len(exitHooks) - 1; i >= 0; i-- {
+		h := exitHooks[i]
+		if code == 0 || h.runOnFailure {
+			var fn func() = h.f
+			fn()
+		}
+	}
+	exitHooks = nil
+	runningExitHooks = false
+}
+
 type p struct {
 	runq func()
 }
@@ -87,7 +332,14 @@ func newosproc() {
 	var fn func() = mstart1
 	stackSize := uintptr(1024)
 	stack := malloc(stackSize + 8)
+
+	// The thread inherits the signal mask. Block all the signals in it,
+	// so that the signals sent to the process are handled by the main thread.
+	var set int = -1
+	var old int
+	rt_sigprocmask(uintptr(_SIG_SETMASK), uintptr(unsafe.Pointer(&set)), uintptr(unsafe.Pointer(&old)))
 	clone(cloneFlags, stack+stackSize, fn)
+	rt_sigprocmask(uintptr(_SIG_SETMASK), uintptr(unsafe.Pointer(&old)), 0)
 }
 
 func mstart0() {
@@ -97,14 +349,7 @@ func mstart0() {
 
 // Environment variables
 var envp uintptr
-var envlines []string // []{"FOO=BAR\0", "HOME=/home/...\0", ..}
-
-type envEntry struct {
-	key   string
-	value string
-}
-
-var Envs []*envEntry
+var envlines []string // []{"FOO=BAR", "HOME=/home/...", ..}
 
 func heapInit() {
 	heapHead = brk(0)
@@ -129,35 +374,11 @@ func envInit() {
 		}
 		envlines = append(envlines, cstring2string(*bpp))
 	}
-
-	for _, envline := range envlines {
-		var i int
-		var c byte
-		for i, c = range []byte(envline) {
-			if c == '=' {
-				break
-			}
-		}
-		key := envline[:i]
-		value := envline[i+1:]
-
-		entry := &envEntry{
-			key:   key,
-			value: value,
-		}
-		Envs = append(Envs, entry)
-
-	}
 }
 
-func runtime_getenv(key string) string {
-	for _, e := range Envs {
-		if e.key == key {
-			return e.value
-		}
-	}
-
-	return ""
+// This func has an alias in syscall package
+func runtime_envs() []string {
+	return envlines
 }
 
 func cstring2string(b *uint8) string {
@@ -185,16 +406,386 @@ func brk(addr uintptr) uintptr {
 }
 
 func panic(ifc interface{}) {
-	switch x := ifc.(type) {
-	case string:
-		var s = "panic: " + x + "\n\n"
-		Write(2, []uint8(s))
-		Syscall(uintptr(SYS_EXIT), 1, uintptr(0), uintptr(0))
+	var s = "panic: " + panicValue(ifc) + "\n\n"
+	Write(2, []uint8(s))
+	traceback(getfp())
+	exit(2)
+}
+
+// dtype is the descriptor of a dynamic type emitted by the compiler.
+type dtype struct {
+	id       int
+	name     string
+	kind     int     // see dtypeKind in the compiler
+	errorFn  uintptr // func(data unsafe.Pointer) string calling the Error method, 0 if none
+	stringFn uintptr // same for the String method
+	size     int
+	methods  uintptr // [nmethods]imethod
+	nmethods int
+
+	// the rest is read by the reflect package
+	rkind   int     // reflect.Kind
+	elem    *dtype  // element type of a pointer, slice, array or map
+	key     *dtype  // key type of a map
+	len     int     // length of an array
+	fields  uintptr // [nfields]reflect.structField
+	nfields int
+}
+
+// imethod is a method of a dynamic type.
+// fn takes the data word of an interface value as its receiver.
+type imethod struct {
+	name string
+	fn   uintptr
+}
+
+type eface struct {
+	typ  *dtype
+	data unsafe.Pointer
+}
+
+// panicValue formats the argument of panic like the reference runtime does.
+func panicValue(ifc interface{}) string {
+	if ifc == nil {
+		return "runtime error: panic called with nil argument"
+	}
+	e := (*eface)(unsafe.Pointer(&ifc))
+	t := e.typ
+	if t.errorFn != 0 {
+		return callDtypeMethod(t.errorFn, e.data)
+	}
+	if t.stringFn != 0 {
+		return callDtypeMethod(t.stringFn, e.data)
+	}
+	var s string
+	switch t.kind {
+	case 1:
+		if *(*bool)(e.data) {
+			s = "true"
+		} else {
+			s = "false"
+		}
+	case 2:
+		s = itoa(*(*int)(e.data))
+	case 3:
+		// the low 4 bytes of a word
+		v := *(*int)(e.data) % 4294967296
+		if v >= 2147483648 {
+			v = v - 4294967296
+		}
+		s = itoa(v)
+	case 4:
+		s = itoa(int(*(*uint8)(e.data)))
+	case 5:
+		s = itoa(int(*(*uint16)(e.data)))
+	case 6:
+		s = utoa(*(*uintptr)(e.data))
+	case 7:
+		s = *(*string)(e.data)
+		if isNamedType(t) {
+			s = "\"" + s + "\""
+		}
+	case 8:
+		return "(" + t.name + ") 0x" + hex(*(*uintptr)(e.data))
 	default:
-		var s = "panic: " + "Unknown type" + "\n\n"
-		Write(2, []uint8(s))
-		Syscall(uintptr(SYS_EXIT), 1, uintptr(0), uintptr(0))
+		return "(" + t.name + ") 0x" + hex(uintptr(e.data))
+	}
+	if isNamedType(t) {
+		return t.name + "(" + s + ")"
 	}
+	return s
+}
+
+// isNamedType reports whether t is a named type other than a predeclared one.
+func isNamedType(t *dtype) bool {
+	for i := 0; i < len(t.name); i++ {
+		if t.name[i] == '.' {
+			return true
+		}
+	}
+	return false
+}
+
+// ifaceMethod returns the function to call for the method name of a value of the dynamic type t.
+// The compiler emits calls to it for method calls through interfaces.
+func ifaceMethod(t *dtype, name string) uintptr {
+	if t == nil {
+		panic("runtime error: invalid memory address or nil pointer dereference")
+	}
+	fn := findMethod(t, name)
+	if fn == 0 {
+		panic("runtime error: " + t.name + " has no method " + name)
+	}
+	return fn
+}
+
+// ifaceImplements reports whether the dynamic type t has the methods, whose names are separated by spaces.
+// The compiler emits calls to it for type assertions to interface types.
+func ifaceImplements(t *dtype, methods string) bool {
+	if t == nil {
+		return false
+	}
+	var start int
+	for i := 0; i <= len(methods); i++ {
+		if i == len(methods) || methods[i] == ' ' {
+			if i > start && findMethod(t, methods[start:i]) == 0 {
+				return false
+			}
+			start = i + 1
+		}
+	}
+	return true
+}
+
+func findMethod(t *dtype, name string) uintptr {
+	for i := 0; i < t.nmethods; i++ {
+		var m *imethod = (*imethod)(unsafe.Pointer(t.methods + uintptr(i*24)))
+		if m.name == name {
+			return m.fn
+		}
+	}
+	return 0
+}
+
+// errors_as implements errors.As for one error of the chain.
+// target points to a variable of a concrete type, which must be the dynamic type of err,
+// or of an interface type, whose methods the dynamic type of err must have.
+// Types are compared by the address of their descriptors, as the linker keeps one descriptor of each type.
+func errors_as(err interface{}, target interface{}) bool {
+	e := (*eface)(unsafe.Pointer(&err))
+	t := (*eface)(unsafe.Pointer(&target))
+	if t.typ.rkind != 22 || t.typ.elem == nil {
+		panic("errors: target must be a non-nil pointer")
+	}
+	p := *(*uintptr)(t.data)
+	if p == 0 {
+		panic("errors: target must be a non-nil pointer")
+	}
+	elem := t.typ.elem
+	if elem.rkind == 20 {
+		// the method table of an interface type lists its methods, without functions
+		for i := 0; i < elem.nmethods; i++ {
+			var m *imethod = (*imethod)(unsafe.Pointer(elem.methods + uintptr(i*24)))
+			if findMethod(e.typ, m.name) == 0 {
+				return false
+			}
+		}
+		var v *eface = (*eface)(unsafe.Pointer(p))
+		v.typ = e.typ
+		v.data = e.data
+		return true
+	}
+	if e.typ != elem {
+		return false
+	}
+	for i := 0; i < e.typ.size; i++ {
+		var dst *uint8 = (*uint8)(unsafe.Pointer(p + uintptr(i)))
+		var src *uint8 = (*uint8)(unsafe.Pointer(uintptr(e.data) + uintptr(i)))
+		*dst = *src
+	}
+	return true
+}
+
+func callDtypeMethod(fn uintptr, data unsafe.Pointer) string {
+	var f func(data unsafe.Pointer) string
+	*(*uintptr)(unsafe.Pointer(&f)) = fn
+	return f(data)
+}
+
+// callSite and funcInfo are the entries of the call site table emitted by the compiler.
+type callSite struct {
+	pc   uintptr // return address of the call
+	fn   *funcInfo
+	line int
+}
+
+type funcInfo struct {
+	entry  uintptr
+	end    uintptr
+	name   string
+	file   string
+	line   int
+	params int
+	lines  uintptr // pairs of 4 byte integers: offset of a statement from entry and its line
+	nlines int
+}
+
+func findCallSite(pc uintptr) *callSite {
+	var start uintptr
+	var end uintptr
+	start, end = calltab()
+	for p := start; p < end; p = p + 24 {
+		site := (*callSite)(unsafe.Pointer(p))
+		if site.pc == pc {
+			return site
+		}
+	}
+	return nil
+}
+
+// findFunc returns the function whose code contains pc.
+func findFunc(pc uintptr) *funcInfo {
+	var start uintptr
+	var end uintptr
+	start, end = functab()
+	for p := start; p < end; p = p + 80 {
+		fn := (*funcInfo)(unsafe.Pointer(p))
+		if fn.entry <= pc && pc < fn.end {
+			return fn
+		}
+	}
+	return nil
+}
+
+// funcLine returns the line of the statement of fn containing pc.
+func funcLine(fn *funcInfo, pc uintptr) int {
+	line := fn.line
+	var best uintptr
+	for i := 0; i < fn.nlines; i++ {
+		v := *(*uintptr)(unsafe.Pointer(fn.lines + uintptr(i)*8))
+		off := v % 4294967296
+		if off <= pc-fn.entry && off >= best {
+			best = off
+			line = int(v / 4294967296)
+		}
+	}
+	return line
+}
+
+// traceback prints the callers of the function whose frame pointer is fp, up to main.main.
+// The functions reporting a panic are not printed.
+// The return address pushed by the signal handler is the faulting instruction itself.
+func traceback(fp uintptr) {
+	var s = "goroutine 1 [running]:\n"
+	for i := 0; fp != 0 && i < 100; i++ {
+		pc := *(*uintptr)(unsafe.Pointer(fp + 8))
+		var fn *funcInfo
+		var line int
+		site := findCallSite(pc)
+		if site != nil {
+			fn = site.fn
+			line = site.line
+		} else if sigNo != 0 && pc == sigPC {
+			fn = findFunc(pc)
+			if fn != nil {
+				line = funcLine(fn, pc)
+			}
+		}
+		if fn == nil {
+			// a frame of code the compiler emits by itself
+			fp = *(*uintptr)(unsafe.Pointer(fp))
+			continue
+		}
+		if !(len(fn.name) >= 13 && fn.name[:13] == "runtime.panic") && fn.name != "runtime.sigpanic" {
+			var args = "()"
+			if fn.params > 0 {
+				args = "(...)"
+			}
+			s = s + fn.name + args + "\n\t" + fn.file + ":" + itoa(line) + " +0x" + hex(pc-fn.entry) + "\n"
+		}
+		if fn.name == "main.main" {
+			break
+		}
+		fp = *(*uintptr)(unsafe.Pointer(fp))
+	}
+	Write(2, []uint8(s))
+}
+
+// The compiler emits calls to the following functions where a runtime check fails.
+// pos is the position of the check in the form "file:line".
+
+func panicIndex(x int, y int, pos string) {
+	panicCheck("index out of range ["+itoa(x)+"] with length "+itoa(y), pos)
+}
+
+func panicSliceAlen(x int, y int, pos string) {
+	panicCheck("slice bounds out of range [:"+itoa(x)+"] with length "+itoa(y), pos)
+}
+
+func panicSliceAcap(x int, y int, pos string) {
+	panicCheck("slice bounds out of range [:"+itoa(x)+"] with capacity "+itoa(y), pos)
+}
+
+func panicSliceB(x int, y int, pos string) {
+	panicCheck("slice bounds out of range ["+itoa(x)+":"+itoa(y)+"]", pos)
+}
+
+func panicSlice3Acap(x int, y int, pos string) {
+	panicCheck("slice bounds out of range [::"+itoa(x)+"] with capacity "+itoa(y), pos)
+}
+
+func panicSlice3B(x int, y int, pos string) {
+	panicCheck("slice bounds out of range [:"+itoa(x)+":"+itoa(y)+"]", pos)
+}
+
+func panicSlice3C(x int, y int, pos string) {
+	panicCheck("slice bounds out of range ["+itoa(x)+":"+itoa(y)+":]", pos)
+}
+
+func panicNil(pos string) {
+	panicCheck("invalid memory address or nil pointer dereference", pos)
+}
+
+func panicDivide(pos string) {
+	panicCheck("integer divide by zero", pos)
+}
+
+func panicCheck(msg string, pos string) {
+	panic("runtime error: " + msg + " at " + pos)
+}
+
+func itoa(x int) string {
+	if x == 0 {
+		return "0"
+	}
+	var neg bool
+	if x < 0 {
+		neg = true
+		x = -x
+	}
+	// the string shares the bytes of buf, so buf must not be in the frame
+	buf := make([]uint8, 20, 20)
+	i := len(buf)
+	for x > 0 {
+		i--
+		buf[i] = uint8('0' + x%10)
+		x = x / 10
+	}
+	if neg {
+		i--
+		buf[i] = '-'
+	}
+	return string(buf[i:])
+}
+
+func utoa(x uintptr) string {
+	if x == 0 {
+		return "0"
+	}
+	buf := make([]uint8, 20, 20)
+	i := len(buf)
+	for x > 0 {
+		i--
+		buf[i] = uint8('0' + x%10)
+		x = x / 10
+	}
+	return string(buf[i:])
+}
+
+var hexDigits string = "0123456789abcdef"
+
+func hex(x uintptr) string {
+	if x == 0 {
+		return "0"
+	}
+	buf := make([]uint8, 16, 16)
+	i := len(buf)
+	for x > 0 {
+		i--
+		buf[i] = hexDigits[int(x%16)]
+		x = x / 16
+	}
+	return string(buf[i:])
 }
 
 func memzeropad(addr1 uintptr, size uintptr) {
@@ -223,12 +814,17 @@ func memcopy(src uintptr, dst uintptr, length int) {
 func malloc(size uintptr) uintptr {
 	if heapCurrent+size > heapTail {
 		Write(2, []uint8("malloc exceeded heap max"))
-		Syscall(uintptr(SYS_EXIT), 1, uintptr(0), uintptr(0))
+		exit(1)
 		return 0
 	}
 	var r uintptr
+	mallocing = true
 	r = heapCurrent
 	heapCurrent = heapCurrent + size
+	mallocing = false
+	if sigNPending > 0 && !sigDelivering {
+		sigdeliverBlocked()
+	}
 	memzeropad(r, size)
 	return r
 }
@@ -375,16 +971,39 @@ func cmpstrings(a string, b string) bool {
 }
 
 // Two interface values are equal if they have identical dynamic types and equal dynamic values or if both have value nil.
+// cmpinterface compares two interface values (a, b) and (c, d).
+// Values of the same dynamic type are equal if their data are.
 func cmpinterface(a uintptr, b uintptr, c uintptr, d uintptr) bool {
-	if a == c && b == d {
+	if a != c {
+		return false
+	}
+	if b == d {
 		return true
 	}
-	return false
+	var t *dtype = (*dtype)(unsafe.Pointer(a))
+	if t.kind == 7 {
+		return cmpstrings(*(*string)(unsafe.Pointer(b)), *(*string)(unsafe.Pointer(d)))
+	}
+	for i := 0; i < t.size; i++ {
+		var x *uint8 = (*uint8)(unsafe.Pointer(b + uintptr(i)))
+		var y *uint8 = (*uint8)(unsafe.Pointer(d + uintptr(i)))
+		if *x != *y {
+			return false
+		}
+	}
+	return true
 }
 
 func Write(fd int, p []byte) int
 func Syscall(trap uintptr, a1 uintptr, a2 uintptr, a3 uintptr) uintptr
 func exit(c int)
+func getfp() uintptr
+func calltab() (uintptr, uintptr)
+func functab() (uintptr, uintptr)
+func rt_sigaction(sig uintptr, act uintptr, oact uintptr) uintptr
+func rt_sigprocmask(how uintptr, set uintptr, oset uintptr) uintptr
+func sigtramp()
+func sigreturn()
 func exitThread()
 func clone(flags int, stack uintptr, fn func())
 func futex(addr unsafe.Pointer, op int, val int)
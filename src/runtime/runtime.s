@@ -39,6 +39,65 @@ runtime.printstring:
   popq %rax # retval
   ret
 
+// func getfp() uintptr
+runtime.getfp:
+  movq %rbp, 8(%rsp) # frame pointer of the caller
+  ret
+
+// func calltab() (uintptr, uintptr)
+// The linker defines the start and stop symbols of the call site table emitted by the compiler.
+runtime.calltab:
+  leaq __start_babygo_calltab(%rip), %rax
+  movq %rax, 8(%rsp)
+  leaq __stop_babygo_calltab(%rip), %rax
+  movq %rax, 16(%rsp)
+  ret
+
+// func functab() (uintptr, uintptr)
+runtime.functab:
+  leaq __start_babygo_functab(%rip), %rax
+  movq %rax, 8(%rsp)
+  leaq __stop_babygo_functab(%rip), %rax
+  movq %rax, 16(%rsp)
+  ret
+
+// func rt_sigaction(sig uintptr, act uintptr, oact uintptr) uintptr
+runtime.rt_sigaction:
+  movq 8(%rsp), %rdi # sig
+  movq 16(%rsp), %rsi # act
+  movq 24(%rsp), %rdx # oact
+  movq $8, %r10 # sizeof(sigset_t)
+  movq $13, %rax # sys_rt_sigaction
+  syscall
+  movq %rax, 32(%rsp)
+  ret
+
+// func rt_sigprocmask(how uintptr, set uintptr, oset uintptr) uintptr
+runtime.rt_sigprocmask:
+  movq 8(%rsp), %rdi # how
+  movq 16(%rsp), %rsi # set
+  movq 24(%rsp), %rdx # oset
+  movq $8, %r10 # sizeof(sigset_t)
+  movq $14, %rax # sys_rt_sigprocmask
+  syscall
+  movq %rax, 32(%rsp)
+  ret
+
+// The kernel calls the handler like a C function: sigtramp(sig, info, ctx)
+runtime.sigtramp:
+  subq $24, %rsp
+  movq %rdi, 0(%rsp) # sig
+  movq %rsi, 8(%rsp) # info
+  movq %rdx, 16(%rsp) # ctx
+  callq runtime.sighandler
+  addq $24, %rsp
+  ret
+
+// The handler returns here.
+runtime.sigreturn:
+  movq $15, %rax # sys_rt_sigreturn
+  syscall
+
 // func futex(addr unsafe.Pointer, op int, val int)
 runtime.futex:
   # https://man7.org/linux/man-pages/man2/futex.2.html
@@ -66,7 +125,7 @@ runtime.futex:
 
 // func clone(flags int, stack uintptr, fn func())
 runtime.clone:
-  movq 24(%rsp), %r12
+  movq 24(%rsp), %r9 # fn
   movl $56, %eax # sys_clone
   movq 8(%rsp), %rdi # flags
   movq 16(%rsp), %rsi # stack
@@ -82,7 +141,8 @@ runtime.clone:
 
 .L.child:
   movq %rsi , %rsp # start from new stack
-  callq *%r12
+  movq $0, %rbp # the outermost frame
+  callq *%r9
   ret
 
 // func Syscall(trap, a1, a2, a3 uintptr) uintptr
@@ -107,3 +167,17 @@ syscall.Syscall:
   movq %rax, 40(%rsp) # r0 uintptr
   ret
 
+// func Syscall6(trap, a1, a2, a3, a4, a5, a6 uintptr) uintptr
+.global syscall.Syscall6
+syscall.Syscall6:
+  movq   8(%rsp), %rax # syscall number
+  movq  16(%rsp), %rdi # arg0
+  movq  24(%rsp), %rsi # arg1
+  movq  32(%rsp), %rdx # arg2
+  movq  40(%rsp), %r10 # arg3
+  movq  48(%rsp), %r8  # arg4
+  movq  56(%rsp), %r9  # arg5
+  syscall
+  movq %rax, 64(%rsp) # r0 uintptr
+  ret
+
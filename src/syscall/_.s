@@ -3,3 +3,9 @@
 
 TEXT	 syscall·Syscall(SB), NOSPLIT
     RET
+
+TEXT	 syscall·Syscall6(SB), NOSPLIT
+    RET
+
+TEXT	 syscall·runtime_envs(SB), NOSPLIT
+    RET
@@ -0,0 +1,94 @@
+package syscall
+
+// envs is the environment in the form "key=value", copied from the runtime on first use.
+// An empty string is an unset variable.
+var envs []string
+var envCopied bool
+
+func copyenv() {
+	if envCopied {
+		return
+	}
+	envCopied = true
+	for _, kv := range runtime_envs() {
+		envs = append(envs, kv)
+	}
+}
+
+// envIndex returns the index of the first entry of key in envs, or -1.
+func envIndex(key string) int {
+	for i, kv := range envs {
+		if len(kv) > len(key) && kv[len(key)] == '=' && kv[:len(key)] == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func Getenv(key string) (string, bool) {
+	copyenv()
+	if len(key) == 0 {
+		return "", false
+	}
+	i := envIndex(key)
+	if i < 0 {
+		return "", false
+	}
+	kv := envs[i]
+	return kv[len(key)+1:], true
+}
+
+func Setenv(key string, value string) error {
+	copyenv()
+	if len(key) == 0 {
+		return EINVAL
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] == '=' || key[i] == 0 {
+			return EINVAL
+		}
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] == 0 {
+			return EINVAL
+		}
+	}
+	kv := key + "=" + value
+	i := envIndex(key)
+	if i >= 0 {
+		envs[i] = kv
+	} else {
+		envs = append(envs, kv)
+	}
+	return nil
+}
+
+func Unsetenv(key string) error {
+	copyenv()
+	for {
+		i := envIndex(key)
+		if i < 0 {
+			return nil
+		}
+		envs[i] = ""
+	}
+}
+
+func Clearenv() {
+	copyenv()
+	envs = nil
+}
+
+// Environ returns a copy of the environment in the form "key=value".
+func Environ() []string {
+	copyenv()
+	var a []string
+	for _, kv := range envs {
+		if kv != "" {
+			a = append(a, kv)
+		}
+	}
+	return a
+}
+
+func runtime_envs() []string
@@ -1,5 +1,6 @@
 package syscall
 
+import "internal/oserror"
 import "unsafe"
 
 // cheat sheet: https://chromium.googlesource.com/chromiumos/docs/+/HEAD/constants/syscalls.md#x86_64-64_bit
@@ -7,43 +8,893 @@ const SYS_READ uintptr = 0
 const SYS_WRITE uintptr = 1
 const SYS_OPEN uintptr = 2
 const SYS_CLOSE uintptr = 3
+const SYS_STAT uintptr = 4
+const SYS_FSTAT uintptr = 5
+const SYS_LSTAT uintptr = 6
+const SYS_LSEEK uintptr = 8
+const SYS_RT_SIGACTION uintptr = 13
+const SYS_RT_SIGPROCMASK uintptr = 14
+const SYS_DUP2 uintptr = 33
+const SYS_NANOSLEEP uintptr = 35
+const SYS_SETITIMER uintptr = 38
+const SYS_GETPID uintptr = 39
+const SYS_CLONE uintptr = 56
+const SYS_FORK uintptr = 57
+const SYS_EXECVE uintptr = 59
+const SYS_WAIT4 uintptr = 61
+const SYS_KILL uintptr = 62
+const SYS_FCNTL uintptr = 72
+const SYS_GETCWD uintptr = 79
+const SYS_CHDIR uintptr = 80
+const SYS_RENAME uintptr = 82
+const SYS_MKDIR uintptr = 83
+const SYS_RMDIR uintptr = 84
+const SYS_UNLINK uintptr = 87
+const SYS_READLINK uintptr = 89
 const SYS_GETDENTS64 uintptr = 217
+const SYS_CLOCK_GETTIME uintptr = 228
+const SYS_EXIT_GROUP uintptr = 231
+const SYS_DUP3 uintptr = 292
+const SYS_UTIMENSAT uintptr = 280
+const SYS_PIPE2 uintptr = 293
 
-func Read(fd int, buf []byte) (uintptr, error) {
-	p := &buf[0]
-	_cap := cap(buf)
-	var ret uintptr
-	ret = Syscall(SYS_READ, uintptr(fd), uintptr(unsafe.Pointer(p)), uintptr(_cap))
-	return ret, nil
+// An Errno is an unsigned number describing an error condition.
+// It implements the error interface.
+type Errno uintptr
+
+const EPERM Errno = 1
+const ENOENT Errno = 2
+const ESRCH Errno = 3
+const EINTR Errno = 4
+const EIO Errno = 5
+const ENXIO Errno = 6
+const E2BIG Errno = 7
+const ENOEXEC Errno = 8
+const EBADF Errno = 9
+const ECHILD Errno = 10
+const EAGAIN Errno = 11
+const ENOMEM Errno = 12
+const EACCES Errno = 13
+const EFAULT Errno = 14
+const EBUSY Errno = 16
+const EEXIST Errno = 17
+const EXDEV Errno = 18
+const ENODEV Errno = 19
+const ENOTDIR Errno = 20
+const EISDIR Errno = 21
+const EINVAL Errno = 22
+const ENFILE Errno = 23
+const EMFILE Errno = 24
+const ENOTTY Errno = 25
+const EFBIG Errno = 27
+const ENOSPC Errno = 28
+const ESPIPE Errno = 29
+const EROFS Errno = 30
+const EMLINK Errno = 31
+const EPIPE Errno = 32
+const ERANGE Errno = 34
+const ENAMETOOLONG Errno = 36
+const ENOSYS Errno = 38
+const ENOTEMPTY Errno = 39
+const ELOOP Errno = 40
+
+func (e Errno) Error() string {
+	switch int(e) {
+	case 1:
+		return "operation not permitted"
+	case 2:
+		return "no such file or directory"
+	case 3:
+		return "no such process"
+	case 4:
+		return "interrupted system call"
+	case 5:
+		return "input/output error"
+	case 6:
+		return "no such device or address"
+	case 7:
+		return "argument list too long"
+	case 8:
+		return "exec format error"
+	case 9:
+		return "bad file descriptor"
+	case 10:
+		return "no child processes"
+	case 11:
+		return "resource temporarily unavailable"
+	case 12:
+		return "cannot allocate memory"
+	case 13:
+		return "permission denied"
+	case 14:
+		return "bad address"
+	case 16:
+		return "device or resource busy"
+	case 17:
+		return "file exists"
+	case 18:
+		return "invalid cross-device link"
+	case 19:
+		return "no such device"
+	case 20:
+		return "not a directory"
+	case 21:
+		return "is a directory"
+	case 22:
+		return "invalid argument"
+	case 23:
+		return "too many open files in system"
+	case 24:
+		return "too many open files"
+	case 25:
+		return "inappropriate ioctl for device"
+	case 27:
+		return "file too large"
+	case 28:
+		return "no space left on device"
+	case 29:
+		return "illegal seek"
+	case 30:
+		return "read-only file system"
+	case 31:
+		return "too many links"
+	case 32:
+		return "broken pipe"
+	case 34:
+		return "numerical result out of range"
+	case 36:
+		return "file name too long"
+	case 38:
+		return "function not implemented"
+	case 39:
+		return "directory not empty"
+	case 40:
+		return "too many levels of symbolic links"
+	}
+	return "errno " + itoa(int(e))
+}
+
+// Is makes errors.Is(err, fs.ErrNotExist) and the like work for errnos.
+func (e Errno) Is(target error) bool {
+	if target == oserror.ErrPermission {
+		return e == EACCES || e == EPERM
+	}
+	if target == oserror.ErrExist {
+		return e == EEXIST || e == ENOTEMPTY
+	}
+	if target == oserror.ErrNotExist {
+		return e == ENOENT
+	}
+	return false
+}
+
+// errnoErr returns the error of the raw result r of a system call, which is -errno on failure.
+func errnoErr(r uintptr) error {
+	n := int(r)
+	if n < 0 && n > -4096 {
+		return Errno(-n)
+	}
+	return nil
+}
+
+func Read(fd int, buf []byte) (int, error) {
+	var p *byte
+	if len(buf) > 0 {
+		p = &buf[0]
+	}
+	r := Syscall(SYS_READ, uintptr(fd), uintptr(unsafe.Pointer(p)), uintptr(len(buf)))
+	err := errnoErr(r)
+	if err != nil {
+		return -1, err
+	}
+	return int(r), nil
 }
 
-func Open(path string, mode int, perm int) (uintptr, error) {
+func Open(path string, mode int, perm int) (int, error) {
 	buf := []byte(path)
 	buf = append(buf, 0) // add null terminator
 	p := &buf[0]
-	var fd uintptr
-	fd = Syscall(SYS_OPEN, uintptr(unsafe.Pointer(p)), uintptr(mode), uintptr(perm))
-	return fd, nil
+	r := Syscall(SYS_OPEN, uintptr(unsafe.Pointer(p)), uintptr(mode), uintptr(perm))
+	err := errnoErr(r)
+	if err != nil {
+		return -1, err
+	}
+	return int(r), nil
 }
 
 func Close(fd int) error {
-	Syscall(SYS_CLOSE, uintptr(fd), 0, 0)
-	return nil
+	r := Syscall(SYS_CLOSE, uintptr(fd), 0, 0)
+	return errnoErr(r)
 }
 
-func Write(fd int, buf []byte) (uintptr, error) {
-	p := &buf[0]
-	_len := len(buf)
-	var ret uintptr
-	ret = Syscall(SYS_WRITE, uintptr(fd), uintptr(unsafe.Pointer(p)), uintptr(_len))
-	return ret, nil
+func Write(fd int, buf []byte) (int, error) {
+	var p *byte
+	if len(buf) > 0 {
+		p = &buf[0]
+	}
+	r := Syscall(SYS_WRITE, uintptr(fd), uintptr(unsafe.Pointer(p)), uintptr(len(buf)))
+	err := errnoErr(r)
+	if err != nil {
+		return -1, err
+	}
+	return int(r), nil
+}
+
+// Seek sets the offset of fd to offset, interpreted according to whence, and returns the new offset.
+func Seek(fd int, offset int, whence int) (int, error) {
+	r := Syscall(SYS_LSEEK, uintptr(fd), uintptr(offset), uintptr(whence))
+	err := errnoErr(r)
+	if err != nil {
+		return -1, err
+	}
+	return int(r), nil
 }
 
 func Getdents(fd int, buf []byte) (int, error) {
 	var _p0 unsafe.Pointer
 	_p0 = unsafe.Pointer(&buf[0])
-	nread := Syscall(SYS_GETDENTS64, uintptr(fd), uintptr(_p0), uintptr(len(buf)))
-	return int(nread), nil
+	r := Syscall(SYS_GETDENTS64, uintptr(fd), uintptr(_p0), uintptr(len(buf)))
+	err := errnoErr(r)
+	if err != nil {
+		return -1, err
+	}
+	return int(r), nil
+}
+
+// Stat_t is struct stat of linux/amd64.
+// Fields narrower than 8 bytes are paired up into one int.
+type Stat_t struct {
+	Dev      int
+	Ino      int
+	Nlink    int
+	ModeUid  int // st_mode and st_uid
+	GidPad   int // st_gid and padding
+	Rdev     int
+	Size     int
+	Blksize  int
+	Blocks   int
+	AtimSec  int
+	AtimNsec int
+	MtimSec  int
+	MtimNsec int
+	CtimSec  int
+	CtimNsec int
+	Unused   [3]int
+}
+
+// File types and permission bits of Stat_t.Mode.
+const S_IFMT int = 61440   // 0170000
+const S_IFBLK int = 24576  // 0060000
+const S_IFCHR int = 8192   // 0020000
+const S_IFDIR int = 16384  // 0040000
+const S_IFIFO int = 4096   // 0010000
+const S_IFLNK int = 40960  // 0120000
+const S_IFREG int = 32768  // 0100000
+const S_IFSOCK int = 49152 // 0140000
+const S_ISUID int = 2048   // 0004000
+const S_ISGID int = 1024   // 0002000
+const S_ISVTX int = 512    // 0001000
+
+// Mode returns st_mode.
+func (st *Stat_t) Mode() int {
+	return st.ModeUid & 4294967295
+}
+
+// BytePtrFromString returns a pointer to a NUL-terminated array of bytes containing the text of s.
+// If s contains a NUL byte, it returns (nil, EINVAL).
+func BytePtrFromString(s string) (*byte, error) {
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0 {
+			return nil, EINVAL
+		}
+	}
+	var a []byte = make([]byte, len(s)+1, len(s)+1)
+	for i := 0; i < len(s); i++ {
+		a[i] = s[i]
+	}
+	return &a[0], nil
+}
+
+func Stat(path string, stat *Stat_t) error {
+	p, err := BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	r := Syscall(SYS_STAT, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(stat)), 0)
+	return errnoErr(r)
+}
+
+func Lstat(path string, stat *Stat_t) error {
+	p, err := BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	r := Syscall(SYS_LSTAT, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(stat)), 0)
+	return errnoErr(r)
+}
+
+func Fstat(fd int, stat *Stat_t) error {
+	r := Syscall(SYS_FSTAT, uintptr(fd), uintptr(unsafe.Pointer(stat)), 0)
+	return errnoErr(r)
+}
+
+// AT_FDCWD makes the *at system calls resolve relative paths against the current directory.
+const AT_FDCWD int = -100
+
+// UtimesNano sets the access and modification times of the named file to ts[0] and ts[1].
+func UtimesNano(path string, ts []Timespec) error {
+	if len(ts) != 2 {
+		return EINVAL
+	}
+	p, err := BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	dirfd := AT_FDCWD
+	r := Syscall6(SYS_UTIMENSAT, uintptr(dirfd), uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&ts[0])), 0, 0, 0)
+	return errnoErr(r)
+}
+
+func Mkdir(path string, mode int) error {
+	buf := []byte(path)
+	buf = append(buf, 0) // add null terminator
+	p := &buf[0]
+	r := Syscall(SYS_MKDIR, uintptr(unsafe.Pointer(p)), uintptr(mode), 0)
+	return errnoErr(r)
+}
+
+func Rmdir(path string) error {
+	p, err := BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	r := Syscall(SYS_RMDIR, uintptr(unsafe.Pointer(p)), 0, 0)
+	return errnoErr(r)
+}
+
+func Unlink(path string) error {
+	p, err := BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	r := Syscall(SYS_UNLINK, uintptr(unsafe.Pointer(p)), 0, 0)
+	return errnoErr(r)
+}
+
+func Rename(from string, to string) error {
+	p1, err := BytePtrFromString(from)
+	if err != nil {
+		return err
+	}
+	p2, err := BytePtrFromString(to)
+	if err != nil {
+		return err
+	}
+	r := Syscall(SYS_RENAME, uintptr(unsafe.Pointer(p1)), uintptr(unsafe.Pointer(p2)), 0)
+	return errnoErr(r)
+}
+
+// Readlink reads the target of the symbolic link path into buf, and returns its length.
+func Readlink(path string, buf []byte) (int, error) {
+	p, err := BytePtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	r := Syscall(SYS_READLINK, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	err = errnoErr(r)
+	if err != nil {
+		return 0, err
+	}
+	return int(r), nil
+}
+
+func Chdir(path string) error {
+	p, err := BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	r := Syscall(SYS_CHDIR, uintptr(unsafe.Pointer(p)), 0, 0)
+	return errnoErr(r)
+}
+
+func Getcwd(buf []byte) (int, error) {
+	var _p0 unsafe.Pointer
+	_p0 = unsafe.Pointer(&buf[0])
+	r := Syscall(SYS_GETCWD, uintptr(_p0), uintptr(len(buf)), 0)
+	err := errnoErr(r)
+	if err != nil {
+		return -1, err
+	}
+	return int(r), nil
+}
+
+// ProcAttr holds the attributes of a process started by ForkExec.
+// The file descriptor i of the new process is Files[i]. Without Files, it inherits all the open files.
+type ProcAttr struct {
+	Dir   string
+	Env   []string
+	Files []uintptr
+}
+
+// cstringArray returns a null terminated array of pointers to null terminated strings.
+func cstringArray(ss []string) []uintptr {
+	var r []uintptr
+	for _, s := range ss {
+		buf := []byte(s)
+		buf = append(buf, 0)
+		r = append(r, uintptr(unsafe.Pointer(&buf[0])))
+	}
+	r = append(r, 0)
+	return r
+}
+
+// ForkExec starts the program argv0 in a new process and returns its pid.
+// A failure of execve in the new process is reported as the error.
+func ForkExec(argv0 string, argv []string, attr *ProcAttr) (int, error) {
+	path, err := BytePtrFromString(argv0)
+	if err != nil {
+		return -1, err
+	}
+	dir, err := BytePtrFromString(attr.Dir)
+	if err != nil {
+		return -1, err
+	}
+	args := cstringArray(argv)
+	env := cstringArray(attr.Env)
+	var fds []int
+	for _, f := range attr.Files {
+		fds = append(fds, int(f))
+	}
+	var errbuf []byte = make([]byte, 8, 8)
+
+	// The child reports the error of execve through a pipe, which is closed by a successful execve.
+	var p []int = make([]int, 2, 2)
+	err = Pipe2(p, O_CLOEXEC)
+	if err != nil {
+		return -1, err
+	}
+
+	pid := Syscall6(SYS_CLONE, uintptr(SIGCHLD), 0, 0, 0, 0, 0)
+	err = errnoErr(pid)
+	if err != nil {
+		Close(p[0])
+		Close(p[1])
+		return -1, err
+	}
+	if pid == 0 {
+		forkExecChild(path, args, env, dir, fds, p[1], errbuf)
+	}
+
+	Close(p[1])
+	n, _ := Read(p[0], errbuf)
+	Close(p[0])
+	if n == 8 {
+		// execve failed: reap the child
+		var ws WaitStatus
+		Wait4(int(pid), &ws, 0, nil)
+		var errno *int = (*int)(unsafe.Pointer(&errbuf[0]))
+		return -1, Errno(*errno)
+	}
+	return int(pid), nil
+}
+
+// forkExecChild runs in the new process. It sets up the files and the directory, and executes the program.
+// It must not allocate memory. It writes the errno of a failure to the pipe.
+func forkExecChild(path *byte, args []uintptr, env []uintptr, dir *byte, fds []int, pipe int, errbuf []byte) {
+	var r uintptr
+	// Pass 1: move the descriptors which pass 2 would overwrite above all the others.
+	nextfd := len(fds)
+	if pipe < nextfd {
+		r = Syscall(SYS_DUP3, uintptr(pipe), uintptr(nextfd), uintptr(O_CLOEXEC))
+		if failed(r) {
+			childFailed(r, pipe, errbuf)
+		}
+		pipe = nextfd
+		nextfd++
+	}
+	for i := 0; i < len(fds); i++ {
+		if fds[i] < i {
+			if nextfd == pipe { // don't stomp on pipe
+				nextfd++
+			}
+			r = Syscall(SYS_DUP3, uintptr(fds[i]), uintptr(nextfd), uintptr(O_CLOEXEC))
+			if failed(r) {
+				childFailed(r, pipe, errbuf)
+			}
+			fds[i] = nextfd
+			nextfd++
+		}
+	}
+
+	// Pass 2: dup fds[i] down onto i.
+	for i := 0; i < len(fds); i++ {
+		if fds[i] == i {
+			// dup2(i, i) won't clear close-on-exec flag
+			r = Syscall(SYS_FCNTL, uintptr(i), uintptr(F_SETFD), 0)
+		} else {
+			r = Syscall(SYS_DUP3, uintptr(fds[i]), uintptr(i), 0)
+		}
+		if failed(r) {
+			childFailed(r, pipe, errbuf)
+		}
+	}
+
+	if *dir != 0 {
+		r = Syscall(SYS_CHDIR, uintptr(unsafe.Pointer(dir)), 0, 0)
+		if failed(r) {
+			childFailed(r, pipe, errbuf)
+		}
+	}
+	r = Syscall(SYS_EXECVE, uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&args[0])), uintptr(unsafe.Pointer(&env[0])))
+	childFailed(r, pipe, errbuf)
+}
+
+// failed reports whether the raw result r of a system call is an error.
+func failed(r uintptr) bool {
+	n := int(r)
+	return n < 0 && n > -4096
+}
+
+// childFailed writes the errno of the raw result r to the pipe and exits.
+func childFailed(r uintptr, pipe int, errbuf []byte) {
+	var errno *int = (*int)(unsafe.Pointer(&errbuf[0]))
+	*errno = -int(r)
+	Syscall(SYS_WRITE, uintptr(pipe), uintptr(unsafe.Pointer(&errbuf[0])), 8)
+	Syscall(SYS_EXIT_GROUP, 127, 0, 0)
+}
+
+// Exec invokes the execve system call.
+func Exec(argv0 string, argv []string, envv []string) error {
+	path, err := BytePtrFromString(argv0)
+	if err != nil {
+		return err
+	}
+	args := cstringArray(argv)
+	env := cstringArray(envv)
+	r := Syscall(SYS_EXECVE, uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&args[0])), uintptr(unsafe.Pointer(&env[0])))
+	return errnoErr(r)
+}
+
+const O_CLOEXEC int = 524288 // 0x80000
+const F_SETFD int = 2
+
+// Pipe2 creates a pipe with the flags, and stores its read and write ends in p[0] and p[1].
+func Pipe2(p []int, flags int) error {
+	if len(p) != 2 {
+		return EINVAL
+	}
+	var fds int // int32[2]
+	r := Syscall(SYS_PIPE2, uintptr(unsafe.Pointer(&fds)), uintptr(flags), 0)
+	err := errnoErr(r)
+	if err != nil {
+		return err
+	}
+	p[0] = fds % 4294967296
+	p[1] = fds / 4294967296
+	return nil
+}
+
+func Pipe(p []int) error {
+	return Pipe2(p, 0)
+}
+
+func Dup2(oldfd int, newfd int) error {
+	r := Syscall(SYS_DUP2, uintptr(oldfd), uintptr(newfd), 0)
+	return errnoErr(r)
+}
+
+// WaitStatus is the status word filled by Wait4.
+type WaitStatus int
+
+func (w WaitStatus) Exited() bool {
+	return int(w)%128 == 0
+}
+
+func (w WaitStatus) Signaled() bool {
+	return int(w)%128 != 127 && int(w)%128 != 0
+}
+
+func (w WaitStatus) Stopped() bool {
+	return int(w)%256 == 127
+}
+
+func (w WaitStatus) CoreDump() bool {
+	return w.Signaled() && int(w)/128%2 == 1
+}
+
+// ExitStatus returns the exit status of the process, or -1 if it has not exited.
+func (w WaitStatus) ExitStatus() int {
+	if !w.Exited() {
+		return -1
+	}
+	return int(w) / 256 % 256
+}
+
+// Signal returns the signal which terminated the process, or -1 if it was not terminated by a signal.
+func (w WaitStatus) Signal() Signal {
+	if !w.Signaled() {
+		return -1
+	}
+	return Signal(int(w) % 128)
+}
+
+// StopSignal returns the signal which stopped the process, or -1 if it is not stopped.
+func (w WaitStatus) StopSignal() Signal {
+	if !w.Stopped() {
+		return -1
+	}
+	return Signal(int(w) / 256 % 256)
+}
+
+// Rusage is struct rusage of linux/amd64.
+type Rusage struct {
+	data [18]int
+}
+
+// Wait4 waits for the child process pid (or any child if pid is -1) to exit.
+func Wait4(pid int, wstatus *WaitStatus, options int, rusage *Rusage) (int, error) {
+	wpid := Syscall6(SYS_WAIT4, uintptr(pid), uintptr(unsafe.Pointer(wstatus)), uintptr(options), uintptr(unsafe.Pointer(rusage)), 0, 0)
+	err := errnoErr(wpid)
+	if err != nil {
+		return -1, err
+	}
+	return int(wpid), nil
+}
+
+// A Signal is a number describing a process signal.
+type Signal int
+
+const SIGHUP Signal = 1
+const SIGINT Signal = 2
+const SIGQUIT Signal = 3
+const SIGILL Signal = 4
+const SIGTRAP Signal = 5
+const SIGABRT Signal = 6
+const SIGBUS Signal = 7
+const SIGFPE Signal = 8
+const SIGKILL Signal = 9
+const SIGUSR1 Signal = 10
+const SIGSEGV Signal = 11
+const SIGUSR2 Signal = 12
+const SIGPIPE Signal = 13
+const SIGALRM Signal = 14
+const SIGTERM Signal = 15
+const SIGCHLD Signal = 17
+const SIGCONT Signal = 18
+const SIGSTOP Signal = 19
+const SIGTSTP Signal = 20
+const SIGTTIN Signal = 21
+const SIGTTOU Signal = 22
+const SIGURG Signal = 23
+const SIGXCPU Signal = 24
+const SIGXFSZ Signal = 25
+const SIGVTALRM Signal = 26
+const SIGPROF Signal = 27
+const SIGWINCH Signal = 28
+const SIGIO Signal = 29
+const SIGPWR Signal = 30
+const SIGSYS Signal = 31
+
+func (s Signal) Signal() {}
+
+func (s Signal) String() string {
+	switch int(s) {
+	case 1:
+		return "hangup"
+	case 2:
+		return "interrupt"
+	case 3:
+		return "quit"
+	case 4:
+		return "illegal instruction"
+	case 5:
+		return "trace/breakpoint trap"
+	case 6:
+		return "aborted"
+	case 7:
+		return "bus error"
+	case 8:
+		return "floating point exception"
+	case 9:
+		return "killed"
+	case 10:
+		return "user defined signal 1"
+	case 11:
+		return "segmentation fault"
+	case 12:
+		return "user defined signal 2"
+	case 13:
+		return "broken pipe"
+	case 14:
+		return "alarm clock"
+	case 15:
+		return "terminated"
+	case 16:
+		return "stack fault"
+	case 17:
+		return "child exited"
+	case 18:
+		return "continued"
+	case 19:
+		return "stopped (signal)"
+	case 20:
+		return "stopped"
+	case 21:
+		return "stopped (tty input)"
+	case 22:
+		return "stopped (tty output)"
+	case 23:
+		return "urgent I/O condition"
+	case 24:
+		return "CPU time limit exceeded"
+	case 25:
+		return "file size limit exceeded"
+	case 26:
+		return "virtual timer expired"
+	case 27:
+		return "profiling timer expired"
+	case 28:
+		return "window changed"
+	case 29:
+		return "I/O possible"
+	case 30:
+		return "power failure"
+	case 31:
+		return "bad system call"
+	}
+	return "signal " + itoa(int(s))
+}
+
+func itoa(x int) string {
+	if x == 0 {
+		return "0"
+	}
+	var neg bool
+	if x < 0 {
+		neg = true
+		x = -x
+	}
+	var buf []byte = make([]byte, 20, 20)
+	i := len(buf)
+	for x > 0 {
+		i--
+		buf[i] = byte('0' + x%10)
+		x = x / 10
+	}
+	if neg {
+		i--
+		buf[i] = '-'
+	}
+	return string(buf[i:])
+}
+
+const SIG_DFL uintptr = 0
+const SIG_IGN uintptr = 1
+
+const SA_SIGINFO int = 4
+const SA_ONSTACK int = 134217728 // 0x08000000
+const SA_RESTART int = 268435456 // 0x10000000
+const SA_RESTORER int = 67108864 // 0x04000000
+
+// Sigset_t is the kernel sigset_t: bit sig-1 stands for the signal sig.
+type Sigset_t struct {
+	Val uintptr
+}
+
+// Add adds sig to the set.
+func (set *Sigset_t) Add(sig Signal) {
+	if !set.Has(sig) {
+		set.Val = set.Val + sigbit(sig)
+	}
+}
+
+// Del removes sig from the set.
+func (set *Sigset_t) Del(sig Signal) {
+	if set.Has(sig) {
+		set.Val = set.Val - sigbit(sig)
+	}
+}
+
+// Has reports whether sig is in the set.
+func (set *Sigset_t) Has(sig Signal) bool {
+	return set.Val/sigbit(sig)%2 == 1
+}
+
+func sigbit(sig Signal) uintptr {
+	var bit uintptr = 1
+	for i := 1; i < int(sig); i++ {
+		bit = bit * 2
+	}
+	return bit
+}
+
+// Sigaction is struct sigaction of the kernel.
+type Sigaction struct {
+	Handler  uintptr
+	Flags    int
+	Restorer uintptr
+	Mask     Sigset_t
+}
+
+// RtSigaction sets the action for sig to act, and stores the previous one in oact.
+// Either of them may be nil.
+func RtSigaction(sig Signal, act *Sigaction, oact *Sigaction) error {
+	r := Syscall6(SYS_RT_SIGACTION, uintptr(sig), uintptr(unsafe.Pointer(act)), uintptr(unsafe.Pointer(oact)), 8, 0, 0)
+	return errnoErr(r)
+}
+
+const SIG_BLOCK int = 0
+const SIG_UNBLOCK int = 1
+const SIG_SETMASK int = 2
+
+// RtSigprocmask changes the signal mask of the calling thread, and stores the previous one in oset.
+// Either of them may be nil.
+func RtSigprocmask(how int, set *Sigset_t, oset *Sigset_t) error {
+	r := Syscall6(SYS_RT_SIGPROCMASK, uintptr(how), uintptr(unsafe.Pointer(set)), uintptr(unsafe.Pointer(oset)), 8, 0, 0)
+	return errnoErr(r)
+}
+
+// Timespec is struct timespec of linux/amd64.
+type Timespec struct {
+	Sec  int
+	Nsec int
+}
+
+// NsecToTimespec converts a number of nanoseconds into a Timespec.
+func NsecToTimespec(nsec int) Timespec {
+	return Timespec{Sec: nsec / 1000000000, Nsec: nsec % 1000000000}
+}
+
+// Nano returns the time stored in ts as nanoseconds.
+func (ts *Timespec) Nano() int {
+	return ts.Sec*1000000000 + ts.Nsec
+}
+
+// Timeval is struct timeval of linux/amd64.
+type Timeval struct {
+	Sec  int
+	Usec int
+}
+
+// Itimerval is struct itimerval: the timer fires after Value, and then every Interval unless it is zero.
+type Itimerval struct {
+	Interval Timeval
+	Value    Timeval
+}
+
+const CLOCK_REALTIME int = 0
+const CLOCK_MONOTONIC int = 1
+
+const ITIMER_REAL int = 0
+
+func ClockGettime(clockid int, ts *Timespec) error {
+	r := Syscall(SYS_CLOCK_GETTIME, uintptr(clockid), uintptr(unsafe.Pointer(ts)), 0)
+	return errnoErr(r)
+}
+
+// Nanosleep suspends the thread for the time req.
+// When it is interrupted by a signal, it fails with EINTR and stores the remaining time in rem unless it is nil.
+func Nanosleep(req *Timespec, rem *Timespec) error {
+	r := Syscall(SYS_NANOSLEEP, uintptr(unsafe.Pointer(req)), uintptr(unsafe.Pointer(rem)), 0)
+	return errnoErr(r)
+}
+
+// Setitimer arms or disarms the timer which, and stores its previous setting in old unless it is nil.
+// ITIMER_REAL sends SIGALRM when it fires.
+func Setitimer(which int, value *Itimerval, old *Itimerval) error {
+	r := Syscall(SYS_SETITIMER, uintptr(which), uintptr(unsafe.Pointer(value)), uintptr(unsafe.Pointer(old)))
+	return errnoErr(r)
+}
+
+func Getpid() int {
+	pid := Syscall(SYS_GETPID, 0, 0, 0)
+	return int(pid)
+}
+
+func Kill(pid int, sig Signal) error {
+	r := Syscall(SYS_KILL, uintptr(pid), uintptr(sig), 0)
+	return errnoErr(r)
 }
 
 func Syscall(trap uintptr, a1 uintptr, a2 uintptr, a3 uintptr) uintptr
+func Syscall6(trap uintptr, a1 uintptr, a2 uintptr, a3 uintptr, a4 uintptr, a5 uintptr, a6 uintptr) uintptr
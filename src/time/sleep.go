@@ -0,0 +1,142 @@
+package time
+
+import "syscall"
+
+// Babygo has no channels yet, so a timer calls a function when it fires.
+// The functions are called from the handler of SIGALRM, with the other signals blocked,
+// and the real-time interval timer is armed for the earliest timer.
+
+// The Timer type represents a single event.
+// When the Timer expires, its function is called.
+type Timer struct {
+	when   int  // monotonic time in nanoseconds when the timer fires
+	period int  // interval of a Ticker, or 0
+	active bool // whether the timer is waiting to fire
+	f      func()
+	ticker *Ticker // the Ticker of the timer, or nil
+}
+
+// timers are the active timers.
+var timers []*Timer
+
+var timerStarted bool
+
+// AfterFunc waits for the duration to elapse and then calls f from the signal handler.
+// It returns a Timer that can be used to cancel the call using its Stop method.
+func AfterFunc(d Duration, f func()) *Timer {
+	t := &Timer{f: f}
+	t.Reset(d)
+	return t
+}
+
+// Stop prevents the Timer from firing.
+// It returns true if the call stops the timer, false if the timer has already expired or been stopped.
+func (t *Timer) Stop() bool {
+	var old syscall.Sigset_t
+	blockTimers(&old)
+	wasActive := t.active
+	removeTimer(t)
+	startTimer()
+	unblockTimers(&old)
+	return wasActive
+}
+
+// Reset changes the timer to expire after duration d.
+// It returns true if the timer had been active, false if the timer had expired or been stopped.
+func (t *Timer) Reset(d Duration) bool {
+	var old syscall.Sigset_t
+	blockTimers(&old)
+	wasActive := t.active
+	removeTimer(t)
+	t.when = nanotime() + int(d)
+	t.active = true
+	timers = append(timers, t)
+	startTimer()
+	unblockTimers(&old)
+	return wasActive
+}
+
+// blockTimers blocks SIGALRM while the timers are changed, and stores the previous signal mask in old.
+func blockTimers(old *syscall.Sigset_t) {
+	var set syscall.Sigset_t
+	set.Add(syscall.SIGALRM)
+	syscall.RtSigprocmask(syscall.SIG_BLOCK, &set, old)
+}
+
+func unblockTimers(old *syscall.Sigset_t) {
+	syscall.RtSigprocmask(syscall.SIG_SETMASK, old, nil)
+}
+
+func removeTimer(t *Timer) {
+	if !t.active {
+		return
+	}
+	t.active = false
+	var rest []*Timer
+	for _, tt := range timers {
+		if tt != t {
+			rest = append(rest, tt)
+		}
+	}
+	timers = rest
+}
+
+// startTimer arms the interval timer for the earliest timer, or disarms it if there are none.
+func startTimer() {
+	if !timerStarted {
+		time_timerEnable(runTimers)
+		timerStarted = true
+	}
+	var it syscall.Itimerval
+	if len(timers) > 0 {
+		when := timers[0].when
+		for _, t := range timers {
+			if t.when < when {
+				when = t.when
+			}
+		}
+		delta := when - nanotime()
+		if delta < 1000 {
+			// zero would disarm the timer
+			delta = 1000
+		}
+		it.Value.Sec = delta / 1000000000
+		it.Value.Usec = delta % 1000000000 / 1000
+	}
+	syscall.Setitimer(syscall.ITIMER_REAL, &it, nil)
+}
+
+// runTimers is called by the runtime for each SIGALRM. It fires the timers which are due.
+func runTimers() {
+	for {
+		now := nanotime()
+		var t *Timer
+		for _, tt := range timers {
+			if tt.when <= now && (t == nil || tt.when < t.when) {
+				t = tt
+			}
+		}
+		if t == nil {
+			break
+		}
+		if t.period > 0 {
+			t.when = t.when + t.period
+			if t.when <= now {
+				// the ticker fell behind: drop the ticks it missed
+				t.when = now + t.period
+			}
+		} else {
+			removeTimer(t)
+		}
+		if t.ticker != nil {
+			var tick func(t Time) = t.ticker.f
+			tick(Now())
+		} else {
+			var fn func() = t.f
+			fn()
+		}
+	}
+	startTimer()
+}
+
+func time_timerEnable(fn func())
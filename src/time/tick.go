@@ -0,0 +1,34 @@
+package time
+
+// A Ticker calls a function with the current time at intervals.
+type Ticker struct {
+	f func(t Time)
+	r *Timer
+}
+
+// NewTicker returns a new Ticker which calls f with the current time after each period d.
+// Ticks are dropped to make up for slow functions. The duration d must be greater than zero.
+func NewTicker(d Duration, f func(t Time)) *Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	tk := &Ticker{f: f}
+	tk.r = &Timer{period: int(d), ticker: tk}
+	tk.r.Reset(d)
+	return tk
+}
+
+// Stop turns off a ticker. After Stop, no more ticks will be sent.
+func (tk *Ticker) Stop() {
+	tk.r.Stop()
+}
+
+// Reset stops a ticker and resets its period to the specified duration.
+// The next tick will arrive after the new period elapses. The duration d must be greater than zero.
+func (tk *Ticker) Reset(d Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	tk.r.period = int(d)
+	tk.r.Reset(d)
+}
@@ -0,0 +1,323 @@
+// Package time provides functionality for measuring and displaying time.
+//
+// The current time is read by the clock_gettime system call, both from the wall clock
+// and from the monotonic clock. Durations between times read by Now use the monotonic reading,
+// so that they are not affected by changes of the wall clock.
+package time
+
+import "syscall"
+
+// A Time represents an instant in time with nanosecond precision.
+type Time struct {
+	sec  int // seconds since January 1, 1970 UTC
+	nsec int // nanoseconds within the second, in [0, 999999999]
+	mono int // monotonic clock reading in nanoseconds, or 0 if t has none
+}
+
+// Now returns the current local time.
+func Now() Time {
+	var ts syscall.Timespec
+	syscall.ClockGettime(syscall.CLOCK_REALTIME, &ts)
+	return Time{sec: ts.Sec, nsec: ts.Nsec, mono: nanotime()}
+}
+
+// nanotime returns the current reading of the monotonic clock in nanoseconds.
+func nanotime() int {
+	var ts syscall.Timespec
+	syscall.ClockGettime(syscall.CLOCK_MONOTONIC, &ts)
+	return ts.Nano()
+}
+
+// Unix returns the local Time corresponding to the given Unix time,
+// sec seconds and nsec nanoseconds since January 1, 1970 UTC.
+func Unix(sec int, nsec int) Time {
+	if nsec < 0 || nsec >= 1000000000 {
+		n := nsec / 1000000000
+		sec = sec + n
+		nsec = nsec - n*1000000000
+		if nsec < 0 {
+			nsec = nsec + 1000000000
+			sec--
+		}
+	}
+	return Time{sec: sec, nsec: nsec}
+}
+
+// Unix returns t as a Unix time, the number of seconds elapsed since January 1, 1970 UTC.
+func (t Time) Unix() int {
+	return t.sec
+}
+
+// UnixMilli returns t as a Unix time, the number of milliseconds elapsed since January 1, 1970 UTC.
+func (t Time) UnixMilli() int {
+	return t.sec*1000 + t.nsec/1000000
+}
+
+// UnixMicro returns t as a Unix time, the number of microseconds elapsed since January 1, 1970 UTC.
+func (t Time) UnixMicro() int {
+	return t.sec*1000000 + t.nsec/1000
+}
+
+// UnixNano returns t as a Unix time, the number of nanoseconds elapsed since January 1, 1970 UTC.
+func (t Time) UnixNano() int {
+	return t.sec*1000000000 + t.nsec
+}
+
+// Nanosecond returns the nanosecond offset within the second specified by t.
+func (t Time) Nanosecond() int {
+	return t.nsec
+}
+
+// IsZero reports whether t represents the zero time instant.
+func (t Time) IsZero() bool {
+	return t.sec == 0 && t.nsec == 0
+}
+
+// Equal reports whether t and u represent the same time instant.
+// If both have monotonic clock readings, they are compared instead.
+func (t Time) Equal(u Time) bool {
+	if t.mono != 0 && u.mono != 0 {
+		return t.mono == u.mono
+	}
+	return t.sec == u.sec && t.nsec == u.nsec
+}
+
+// Before reports whether the time instant t is before u.
+func (t Time) Before(u Time) bool {
+	if t.mono != 0 && u.mono != 0 {
+		return t.mono < u.mono
+	}
+	return t.sec < u.sec || t.sec == u.sec && t.nsec < u.nsec
+}
+
+// After reports whether the time instant t is after u.
+func (t Time) After(u Time) bool {
+	if t.mono != 0 && u.mono != 0 {
+		return t.mono > u.mono
+	}
+	return t.sec > u.sec || t.sec == u.sec && t.nsec > u.nsec
+}
+
+// Add returns the time t+d.
+func (t Time) Add(d Duration) Time {
+	r := Unix(t.sec, t.nsec+int(d))
+	if t.mono != 0 {
+		r.mono = t.mono + int(d)
+	}
+	return r
+}
+
+// Sub returns the duration t-u.
+// If both have monotonic clock readings, the duration is computed from them.
+func (t Time) Sub(u Time) Duration {
+	if t.mono != 0 && u.mono != 0 {
+		return Duration(t.mono - u.mono)
+	}
+	return Duration((t.sec-u.sec)*1000000000 + t.nsec - u.nsec)
+}
+
+// Since returns the time elapsed since t. It is shorthand for time.Now().Sub(t).
+func Since(t Time) Duration {
+	return Now().Sub(t)
+}
+
+// Until returns the duration until t. It is shorthand for t.Sub(time.Now()).
+func Until(t Time) Duration {
+	return t.Sub(Now())
+}
+
+// A Duration represents the elapsed time between two instants as an int nanosecond count.
+type Duration int
+
+const Nanosecond Duration = 1
+const Microsecond Duration = 1000
+const Millisecond Duration = 1000000
+const Second Duration = 1000000000
+const Minute Duration = 60000000000
+const Hour Duration = 3600000000000
+
+// String returns a string representing the duration in the form "72h3m0.5s".
+// Leading zero units are omitted. Durations less than one second use a smaller unit
+// (milli-, micro-, or nanoseconds) so that the leading digit is non-zero.
+// The zero duration formats as 0s.
+func (d Duration) String() string {
+	var buf []byte = make([]byte, 32, 32)
+	w := len(buf)
+
+	u := int(d)
+	neg := d < 0
+	if neg {
+		u = -u
+	}
+
+	if u < int(Second) {
+		// Special case: if duration is smaller than a second,
+		// use smaller units, like 1.2ms
+		var prec int
+		w--
+		buf[w] = 's'
+		w--
+		if u == 0 {
+			return "0s"
+		} else if u < int(Microsecond) {
+			prec = 0
+			buf[w] = 'n'
+		} else if u < int(Millisecond) {
+			prec = 3
+			// U+00B5 'µ' micro sign == 0xC2 0xB5
+			buf[w] = 181 // 0xB5
+			w--
+			buf[w] = 194 // 0xC2
+		} else {
+			prec = 6
+			buf[w] = 'm'
+		}
+		w, u = fmtFrac(buf, w, u, prec)
+		w = fmtInt(buf, w, u)
+	} else {
+		w--
+		buf[w] = 's'
+
+		w, u = fmtFrac(buf, w, u, 9)
+
+		// u is now integer seconds
+		w = fmtInt(buf, w, u%60)
+		u = u / 60
+
+		// u is now integer minutes
+		if u > 0 {
+			w--
+			buf[w] = 'm'
+			w = fmtInt(buf, w, u%60)
+			u = u / 60
+
+			// u is now integer hours
+			if u > 0 {
+				w--
+				buf[w] = 'h'
+				w = fmtInt(buf, w, u)
+			}
+		}
+	}
+
+	if neg {
+		w--
+		buf[w] = '-'
+	}
+
+	return string(buf[w:])
+}
+
+// fmtFrac formats the fraction of v/10**prec (e.g., ".12345") into the tail of buf[:w],
+// omitting trailing zeros. It omits the decimal point too when the fraction is 0.
+// It returns the index where the output bytes begin and the value v/10**prec.
+func fmtFrac(buf []byte, w int, v int, prec int) (int, int) {
+	// Omit trailing zeros up to and including decimal point.
+	printing := false
+	for i := 0; i < prec; i++ {
+		digit := v % 10
+		printing = printing || digit != 0
+		if printing {
+			w--
+			buf[w] = byte(digit) + '0'
+		}
+		v = v / 10
+	}
+	if printing {
+		w--
+		buf[w] = '.'
+	}
+	return w, v
+}
+
+// fmtInt formats v into the tail of buf[:w].
+// It returns the index where the output begins.
+func fmtInt(buf []byte, w int, v int) int {
+	if v == 0 {
+		w--
+		buf[w] = '0'
+	} else {
+		for v > 0 {
+			w--
+			buf[w] = byte(v%10) + '0'
+			v = v / 10
+		}
+	}
+	return w
+}
+
+// Nanoseconds returns the duration as an integer nanosecond count.
+func (d Duration) Nanoseconds() int {
+	return int(d)
+}
+
+// Microseconds returns the duration as an integer microsecond count.
+func (d Duration) Microseconds() int {
+	return int(d) / 1000
+}
+
+// Milliseconds returns the duration as an integer millisecond count.
+func (d Duration) Milliseconds() int {
+	return int(d) / 1000000
+}
+
+// Truncate returns the result of rounding d toward zero to a multiple of m.
+// If m <= 0, Truncate returns d unchanged.
+func (d Duration) Truncate(m Duration) Duration {
+	if m <= 0 {
+		return d
+	}
+	return d - d%m
+}
+
+// Round returns the result of rounding d to the nearest multiple of m.
+// The rounding behavior for halfway values is to round away from zero.
+// If m <= 0, Round returns d unchanged.
+func (d Duration) Round(m Duration) Duration {
+	if m <= 0 {
+		return d
+	}
+	r := d % m
+	if d < 0 {
+		r = -r
+		if r+r < m {
+			return d + r
+		}
+		return d - m + r
+	}
+	if r+r < m {
+		return d - r
+	}
+	return d + m - r
+}
+
+// Abs returns the absolute value of d.
+func (d Duration) Abs() Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// Sleep pauses the current goroutine for at least the duration d.
+// A negative or zero duration causes Sleep to return immediately.
+// Goroutines run to completion when they are started, so Sleep suspends the thread by nanosleep.
+// Timers fire while it sleeps: their functions are called from the signal handler, and Sleep resumes.
+func Sleep(d Duration) {
+	if d <= 0 {
+		return
+	}
+	ts := syscall.NsecToTimespec(int(d))
+	var rem syscall.Timespec
+	for {
+		err := syscall.Nanosleep(&ts, &rem)
+		if err == nil {
+			return
+		}
+		errno, ok := err.(syscall.Errno)
+		if !ok || errno != syscall.EINTR {
+			return
+		}
+		ts = rem
+	}
+}
@@ -0,0 +1,57 @@
+// Each case fails a runtime check and panics with its position.
+package main
+
+import "os"
+
+type point struct {
+	x int
+}
+
+var five int = 5
+
+func index(s []int, i int) int {
+	return s[i]
+}
+
+func (p *point) getX() int {
+	return p.x
+}
+
+func main() {
+	s := []int{1, 2, 3}
+	var arr [3]int
+	str := "abc"
+	var p *point
+	var ip *int
+	zero := 0
+	switch os.Args[1] {
+	case "index":
+		index(s, 3)
+	case "negative":
+		index(s, -1)
+	case "array":
+		arr[five] = 1
+	case "string":
+		_ = str[five]
+	case "slice":
+		_ = s[1:five]
+	case "slice-string":
+		_ = str[:five]
+	case "slice-order":
+		_ = s[2 : 1+zero]
+	case "slice3":
+		_ = s[0:1:five]
+	case "nil":
+		*ip = 1
+	case "nil-struct":
+		_ = (*p).x
+	case "nil-field":
+		p.x = 1
+	case "nil-method":
+		_ = p.getX()
+	case "divide":
+		_ = five / zero
+	case "modulo":
+		_ = five % zero
+	}
+}
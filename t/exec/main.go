@@ -0,0 +1,100 @@
+// External commands are run by os/exec, with their output collected or redirected.
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/DQNEO/babygo/lib/fmt"
+)
+
+// lines is a Reader which gives its string once.
+type lines struct {
+	s    string
+	done bool
+}
+
+func (r *lines) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+	r.done = true
+	for i := 0; i < len(r.s); i++ {
+		p[i] = r.s[i]
+	}
+	return len(r.s), nil
+}
+
+func report(err error) {
+	if err == nil {
+		fmt.Printf("ok\n")
+		return
+	}
+	ee, ok := err.(*exec.ExitError)
+	if ok {
+		fmt.Printf("%s (code %d)\n", ee.Error(), ee.ExitCode())
+		return
+	}
+	fmt.Printf("error: %s\n", err.Error())
+}
+
+func yesno(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func main() {
+	out, err := exec.Command("echo", "hello", "world").Output()
+	fmt.Printf("%s", string(out))
+	report(err)
+
+	out, err = exec.Command("sh", "-c", "echo out; echo err 1>&2; exit 3").CombinedOutput()
+	fmt.Printf("%s", string(out))
+	report(err)
+
+	out, err = exec.Command("sh", "-c", "echo oops 1>&2; exit 1").Output()
+	fmt.Printf("stdout %d bytes\n", len(out))
+	ee, ok := err.(*exec.ExitError)
+	if ok {
+		fmt.Printf("stderr %s", string(ee.Stderr))
+	}
+
+	cmd := exec.Command("cat")
+	cmd.Stdin = &lines{s: "from stdin\n"}
+	cmd.Stdout = os.Stdout
+	report(cmd.Run())
+
+	cmd = exec.Command("pwd")
+	cmd.Dir = "/"
+	out, err = cmd.Output()
+	fmt.Printf("%s", string(out))
+	report(err)
+
+	cmd = exec.Command("sh", "-c", "echo $GREETING")
+	cmd.Env = []string{"GREETING=hi"}
+	out, err = cmd.Output()
+	fmt.Printf("%s", string(out))
+	report(err)
+
+	report(exec.Command("sh", "-c", "kill -TERM $$").Run())
+
+	err = exec.Command("no-such-command-bbg").Run()
+	report(err)
+	fmt.Printf("not found: %s\n", yesno(errors.Is(err, exec.ErrNotFound)))
+
+	err = exec.Command("/no/such/command").Run()
+	report(err)
+	fmt.Printf("not exist: %s\n", yesno(os.IsNotExist(err)))
+
+	cmd = exec.Command("true")
+	err = cmd.Run()
+	fmt.Printf("%s, success: %s\n", cmd.ProcessState.String(), yesno(cmd.ProcessState.Success()))
+	report(err)
+
+	_, err = exec.LookPath("sh")
+	report(err)
+}
@@ -0,0 +1,44 @@
+//go:build babygo
+
+// Exit hooks run when main returns or os.Exit is called, in the reverse order of their registration.
+// With a non-zero exit code, only the hooks to run on failure do.
+// The program imports an internal package, so it is built by babygo only.
+package main
+
+import (
+	"internal/exithook"
+	"os"
+
+	"github.com/DQNEO/babygo/lib/fmt"
+)
+
+func flush() {
+	fmt.Printf("flush (runs on failure)\n")
+}
+
+func cleanup() {
+	fmt.Printf("cleanup\n")
+}
+
+func exitAgain() {
+	fmt.Printf("exit in a hook\n")
+	os.Exit(4)
+}
+
+func main() {
+	exithook.Add(exithook.Hook{F: flush, RunOnFailure: true})
+	exithook.Add(exithook.Hook{F: cleanup})
+	switch os.Args[1] {
+	case "return":
+		fmt.Printf("returning\n")
+	case "exit":
+		fmt.Printf("exiting\n")
+		os.Exit(0)
+	case "fail":
+		fmt.Printf("failing\n")
+		os.Exit(3)
+	case "reenter":
+		exithook.Add(exithook.Hook{F: exitAgain})
+		os.Exit(0)
+	}
+}
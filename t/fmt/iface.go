@@ -0,0 +1,24 @@
+// Package fmt holds sources which babygo fmt must leave as they are.
+package fmt
+
+type celsius int
+
+func (c celsius) String() string {
+	return "°C"
+}
+
+func (c celsius) Kelvin() int {
+	return int(c) + 273
+}
+
+// interface methods are printed with their names
+type temperature interface {
+	String() string
+	Kelvin() int
+}
+
+type kelviner interface{ Kelvin() int }
+
+type thermometer interface {
+	Read(scale string) (temperature, error)
+}
@@ -0,0 +1,296 @@
+// The formatting and scanning of lib/fmt.
+// expected.txt is the output of this program built by Go with the standard fmt,
+// so lib/fmt must print exactly what Go's fmt does.
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/DQNEO/babygo/lib/fmt"
+)
+
+type point struct {
+	X int
+	y int
+}
+
+type named struct {
+	Name  string
+	Tags  []string
+	Any   interface{}
+	Inner point
+	Next  *named
+}
+
+type celsius int
+
+func (c celsius) String() string {
+	return fmt.Sprintf("%d°C", int(c))
+}
+
+type gopher string
+
+func (g gopher) GoString() string {
+	return "gopher(" + string(g) + ")"
+}
+
+type myError struct {
+	code int
+}
+
+func (e *myError) Error() string {
+	return fmt.Sprintf("error %d", e.code)
+}
+
+const maxInt int = 9223372036854775807
+
+func testIntegers() {
+	fmt.Printf("[%v] [%d] [%+d] [% d] [%5d] [%-5d] [%05d]\n", 42, -42, 42, 42, 42, 42, -42)
+	fmt.Printf("[%x] [%X] [%#x] [%#X] [%x] [%08x]\n", 255, 255, 255, 255, -255, 48879)
+	fmt.Printf("[%o] [%#o] [%O] [%b] [%#b] [%.3d] [%8.3d] [%+.3d]\n", 8, 8, 8, 5, 5, 7, 7, 7)
+	fmt.Printf("[%.0d] [%5.0d] [%c] [%c] [%q] [%+q] [%U] [%#U] [%#U]\n", 0, 0, 65, 19990, 120, 19990, 19990, 19990, 10)
+	min := -maxInt
+	min--
+	fmt.Printf("[%d] [%d] [%x] [%b]\n", maxInt, min, min, min)
+	var u8 uint8 = 200
+	var u16 uint16 = 65535
+	var up uintptr = 4096
+	fmt.Printf("[%v] [%d] [%x] [%v] [%#v] [%v] [%#v]\n", u8, u16, u16, up, up, uintptr(min), u8)
+	var c celsius = 21
+	fmt.Printf("[%v] [%s] [%d] [%x] [%5v]\n", c, c, c, c, c)
+}
+
+func testStrings() {
+	s := "héllo, 世界"
+	fmt.Printf("[%s] [%v] [%q] [%+q] [%#q] [%x] [% x] [%X] [% #x]\n", s, s, s, s, "back`quote", "hi", "hi", "hi", "hi")
+	fmt.Printf("[%10s] [%-10s] [%.2s] [%5.1s] [%-5.3s] [%.0s] [%010s]\n", "abc", "abc", s, s, s, s, "abc")
+	fmt.Printf("[%q] [%#q] [%q] [%x]\n", "tab\tnew\nline\\", "tab\t", string([]uint8{1, 127, 200}), "")
+	fmt.Printf("[%t] [%v] [%6t] [%-6t]\n", true, false, true, false)
+	b := []uint8("bytes")
+	fmt.Printf("[%v] [%d] [%s] [%q] [%x] [%X] [%#v]\n", b, b, b, b, b, b, b)
+	var nb []uint8
+	fmt.Printf("[%v] [%s] [%#v]\n", nb, nb, nb)
+	arr := [3]uint8{1, 2, 3}
+	fmt.Printf("[%v] [%x] [%#v]\n", arr, arr, arr)
+}
+
+func testComposites() {
+	ints := []int{1, -2, 3}
+	strs := []string{"a", "b c"}
+	fmt.Printf("[%v] [%d] [%x] [%#v] [%v] [%q] [%#v]\n", ints, ints, ints, ints, strs, strs, strs)
+	var nilInts []int
+	fmt.Printf("[%v] [%#v] [%v]\n", nilInts, nilInts, [2]bool{true, false})
+	m := make(map[string]int)
+	m["zero"] = 0
+	m["one"] = 1
+	m["two"] = 2
+	m["three"] = 3
+	fmt.Printf("[%v] [%#v] [%d]\n", m, m, len(m))
+	m2 := make(map[string]celsius)
+	m2["b"] = 2
+	m2["ab"] = 1
+	m2["a"] = 0
+	m2[""] = -1
+	fmt.Printf("[%v] [%q] [%d]\n", m2, m2, m2)
+	var nilMap map[string]bool
+	fmt.Printf("[%v] [%#v]\n", nilMap, nilMap)
+
+	p := point{X: 1, y: 2}
+	fmt.Printf("[%v] [%+v] [%#v] [%v] [%+v]\n", p, p, p, &p, &p)
+	n := named{Name: "n", Tags: []string{"x", "y"}, Any: 3, Inner: point{X: 4, y: 5}}
+	fmt.Printf("[%v]\n[%+v]\n[%#v]\n", n, n, n)
+	n.Any = "str"
+	n.Next = nil
+	fmt.Printf("[%v] [%+v]\n", n, n.Any)
+	var anys []interface{}
+	var none interface{}
+	anys = append(anys, 1)
+	anys = append(anys, "two")
+	anys = append(anys, none)
+	anys = append(anys, point{X: 3})
+	anys = append(anys, celsius(4))
+	fmt.Printf("[%v] [%d]\n", anys, anys)
+
+	temps := []celsius{10, 20}
+	fmt.Printf("[%v] [%d]\n", temps, temps)
+	fmt.Printf("[%v] [%#v] [%s]\n", gopher("g"), gopher("g"), gopher("g"))
+	var e error = &myError{code: 7}
+	fmt.Printf("[%v] [%s] [%q] [%d]\n", e, e, e, e)
+}
+
+func testTypes() {
+	var ip *int
+	var pp *point
+	var e error
+	var ns []int
+	fmt.Printf("[%T] [%T] [%T] [%T] [%T] [%T]\n", 1, "s", true, []int{}, make(map[string]int), [2]uint8{})
+	fmt.Printf("[%T] [%T] [%T] [%T] [%T] [%T]\n", point{}, &point{}, celsius(1), ip, e, []uint8{})
+	fmt.Printf("[%v] [%v] [%v] [%d] [%s] [%p] [%p] [%x]\n", ip, pp, e, ip, nil, ip, ns, ip)
+	fmt.Printf("[%v] [%#v] [%+v]\n", nil, ip, pp)
+}
+
+func testErrors() {
+	fmt.Printf("[%d] [%s] [%t] [%x]\n", "str", 1, 2, true)
+	fmt.Printf("[%d] [%d]\n", 1)
+	fmt.Printf("[%d]\n", 1, 2, "three", nil)
+	fmt.Printf("[%!] [%z] [%]\n", 1, 2)
+	fmt.Printf("[%")
+	fmt.Printf("]\n")
+	fmt.Printf("[%[2]d %[1]d] [%[3]d] [%[2]*[1]d] [%[0]d] [%[x]d]\n", 1, 2)
+	fmt.Printf("[%*d] [%-*d] [%.*s] [%*d] [%.*d] [%*d]\n", 5, 1, 5, 1, 2, "abcd", -4, 1, -1, 3, "x", 1)
+	fmt.Printf("[%%] [%5%] [%d%%]\n", 50)
+	fmt.Printf("[%w]\n", errors.New("not in Errorf"))
+}
+
+func testPrint() {
+	fmt.Print("a", 1, 2, "b", 3, true, "\n")
+	fmt.Print(1, 2, "\n")
+	fmt.Println("a", 1, 2, "b", nil, []int{1}, point{})
+	s := fmt.Sprint("x", 1, 2) + "|" + fmt.Sprintln("y", 3) + "|" + fmt.Sprint()
+	fmt.Print(s, "\n")
+	n, err := fmt.Printf("%s=%d\n", "answer", 42)
+	fmt.Println(n, err)
+	n, err = fmt.Println("héllo")
+	fmt.Println(n, err)
+	n, err = fmt.Fprint(os.Stdout, "to stdout", 1, "\n")
+	fmt.Println(n, err)
+	n, err = fmt.Fprintf(os.Stdout, "%05d\n", 12)
+	fmt.Println(n, err)
+	n, err = fmt.Fprintln(os.Stdout, "line")
+	fmt.Println(n, err)
+}
+
+func testErrorf() {
+	base := errors.New("base")
+	e1 := fmt.Errorf("wrapped: %w", base)
+	fmt.Println(e1, errors.Unwrap(e1) == base, errors.Is(e1, base))
+	e2 := fmt.Errorf("again: %w", e1)
+	fmt.Println(e2, errors.Is(e2, base), errors.Unwrap(errors.Unwrap(e2)) == base)
+	e3 := fmt.Errorf("plain %d %v", 1, base)
+	fmt.Println(e3, errors.Unwrap(e3) == nil, errors.Is(e3, base))
+	other := &myError{code: 9}
+	e4 := fmt.Errorf("two: %w, %w", base, other)
+	var target *myError
+	fmt.Println(e4, errors.Is(e4, base), errors.Is(e4, other), errors.As(e4, &target), target.code, errors.Unwrap(e4) == nil)
+	e5 := fmt.Errorf("reordered: %[2]w %[1]w %[2]w", base, other)
+	fmt.Println(e5, errors.Is(e5, base), errors.Is(e5, other))
+	e6 := fmt.Errorf("not an error: %w", 1)
+	fmt.Println(e6, errors.Unwrap(e6) == nil)
+	e7 := fmt.Errorf("no args")
+	fmt.Println(e7)
+}
+
+func testSscan() {
+	var i int
+	var j int
+	var s string
+	var b bool
+	var u8 uint8
+	var u16 uint16
+	var up uintptr
+	var bs []uint8
+
+	n, err := fmt.Sscan(" 12 -3\n abc  true ", &i, &j, &s, &b)
+	fmt.Println(n, err, i, j, s, b)
+	n, err = fmt.Sscan("0x1F 0b101 0o17 017 1_000", &i, &j, &u8, &u16, &up)
+	fmt.Println(n, err, i, j, u8, u16, up)
+	n, err = fmt.Sscan("-9223372036854775808 +7 bytes", &i, &j, &bs)
+	fmt.Println(n, err, i, j, string(bs))
+	n, err = fmt.Sscan("T F 1 0 false", &b, &b, &b, &b, &b)
+	fmt.Println(n, err, b)
+
+	i = 5
+	n, err = fmt.Sscan("", &i)
+	fmt.Println(n, err, i)
+	n, err = fmt.Sscan("8", &i, &j)
+	fmt.Println(n, err, i)
+	n, err = fmt.Sscan("abc", &i)
+	fmt.Println(n, err, i)
+	n, err = fmt.Sscan("300", &u8)
+	fmt.Println(n, err, u8)
+	n, err = fmt.Sscan("99999999999999999999", &i)
+	fmt.Println(n, err, i)
+	n, err = fmt.Sscan("-5", &u16)
+	fmt.Println(n, err, u16)
+	n, err = fmt.Sscan("0x", &i)
+	fmt.Println(n, err, i)
+	n, err = fmt.Sscan("1__0", &i)
+	fmt.Println(n, err, i)
+	n, err = fmt.Sscan("tru", &b)
+	fmt.Println(n, err, b)
+	n, err = fmt.Sscan("12", i)
+	fmt.Println(n, err)
+
+	n, err = fmt.Sscanln("1 2\n3", &i, &j)
+	fmt.Println(n, err, i, j)
+	n, err = fmt.Sscanln("4\n5", &i, &j)
+	fmt.Println(n, err, i, j)
+	n, err = fmt.Sscanln("6 7 8", &i, &j)
+	fmt.Println(n, err, i, j)
+}
+
+func testSscanf() {
+	var i int
+	var j int
+	var s string
+	var t string
+	var b bool
+	var u8 uint8
+
+	n, err := fmt.Sscanf("7 apples, 3 pears", "%d apples, %d %s", &i, &j, &s)
+	fmt.Println(n, err, i, j, s)
+	n, err = fmt.Sscanf("x=10 y=0x1f", "x=%d y=%v", &i, &j)
+	fmt.Println(n, err, i, j)
+	n, err = fmt.Sscanf("ff 101 17 FE", "%x %b %o %X", &i, &j, &u8, &s)
+	fmt.Println(n, err, i, j, u8, s)
+	n, err = fmt.Sscanf("hello world", "%3s%s", &s, &t)
+	fmt.Println(n, err, s, t)
+	n, err = fmt.Sscanf("\"a\\tb\" `raw`", "%q %q", &s, &t)
+	fmt.Printf("%d %v %q %q\n", n, err, s, t)
+	n, err = fmt.Sscanf("%5 A", "%%%d %c", &i, &j)
+	fmt.Println(n, err, i, j)
+	n, err = fmt.Sscanf("5\n6", "%d\n%d", &i, &j)
+	fmt.Println(n, err, i, j)
+	n, err = fmt.Sscanf("1 true", "%d %t", &i, &b)
+	fmt.Println(n, err, i, b)
+
+	n, err = fmt.Sscanf("abc", "%d", &i)
+	fmt.Println(n, err)
+	n, err = fmt.Sscanf("1 2", "%d", &i)
+	fmt.Println(n, err, i)
+	n, err = fmt.Sscanf("3", "%d %d", &i, &j)
+	fmt.Println(n, err, i)
+	n, err = fmt.Sscanf("4", "%d", &i, &j)
+	fmt.Println(n, err, i)
+	n, err = fmt.Sscanf("1 2", "%d %d", &i)
+	fmt.Println(n, err)
+	n, err = fmt.Sscanf("5\n6", "%d %d", &i, &j)
+	fmt.Println(n, err, i)
+	n, err = fmt.Sscanf("true", "%d", &b)
+	fmt.Println(n, err)
+	n, err = fmt.Sscanf("1", "%t", &i)
+	fmt.Println(n, err)
+	n, err = fmt.Sscanf("a-1", "b-%d", &i)
+	fmt.Println(n, err)
+	n, err = fmt.Sscanf("1", "%d%", &i)
+	fmt.Println(n, err)
+	n, err = fmt.Sscanf("\"open", "%q", &s)
+	fmt.Println(n, err)
+	n, err = fmt.Sscanf("\"bad\\z\"", "%q", &s)
+	fmt.Println(n, err)
+	n, err = fmt.Sscanf("zz", "%x", &s)
+	fmt.Println(n, err)
+}
+
+func main() {
+	testIntegers()
+	testStrings()
+	testComposites()
+	testTypes()
+	testErrors()
+	testPrint()
+	testErrorf()
+	testSscan()
+	testSscanf()
+}
@@ -0,0 +1,43 @@
+package main
+
+import "os"
+
+type point struct {
+	x int
+	y int
+}
+
+func add(a int, b int) int {
+	return a + b
+}
+
+// a method is not inlined
+func (p *point) sum() int {
+	return p.x + p.y
+}
+
+// a body of more than one statement is not inlined
+func eq2(a string, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return a == b
+}
+
+//go:noinline
+func sub(a int, b int) int {
+	return a - b
+}
+
+func large(a int) int {
+	return a + a + a + a + a + a + a + a + a + a + a + a
+}
+
+func main() {
+	p := &point{x: 1, y: 2}
+	n := add(p.x, p.y) + p.sum() + sub(3, 2) + large(1)
+	if eq2("a", "a") {
+		n = add(n, 1)
+	}
+	os.Exit(n - 20)
+}
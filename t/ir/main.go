@@ -0,0 +1,17 @@
+package main
+
+import "os"
+
+type point struct {
+	x int
+	y int
+}
+
+const greeting string = "hi\n"
+
+func main() {
+	p := point{x: 1, y: 2}
+	exit := os.Exit
+	os.Stdout.Write([]byte(greeting))
+	exit(p.y - p.x + os.O_RDONLY)
+}
@@ -0,0 +1,7 @@
+package a
+
+import "example.com/list/b"
+
+func A() int {
+	return b.B() + 1
+}
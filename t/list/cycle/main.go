@@ -0,0 +1,7 @@
+package main
+
+import "example.com/list/cycle/x"
+
+func main() {
+	x.X()
+}
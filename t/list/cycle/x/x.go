@@ -0,0 +1,7 @@
+package x
+
+import "example.com/list/cycle/y"
+
+func X() int {
+	return y.Y()
+}
@@ -0,0 +1,7 @@
+package y
+
+import "example.com/list/cycle/x"
+
+func Y() int {
+	return x.X()
+}
@@ -0,0 +1,7 @@
+package main
+
+import "example.com/list/a"
+
+func main() {
+	a.A()
+}
@@ -0,0 +1,5 @@
+module example.com/app
+
+go 1.20
+
+require example.com/quote v1.0.0
@@ -0,0 +1,8 @@
+// Package greet is a package of the module.
+package greet
+
+import "example.com/quote"
+
+func Greet(name string) string {
+	return name + " says: " + quote.Go()
+}
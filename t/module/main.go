@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"example.com/app/greet"
+)
+
+func main() {
+	os.Stdout.Write([]byte(greet.Greet("gopher") + "\n"))
+}
@@ -0,0 +1,77 @@
+// Each case panics with a value of another type, a few calls deep.
+package main
+
+import "os"
+
+type myInt int
+
+type myString string
+
+type point struct {
+	x int
+	y int
+}
+
+type pathError struct {
+	path string
+}
+
+func (e *pathError) Error() string {
+	return "open " + e.path + ": no such file"
+}
+
+type color struct {
+	name string
+	rgb  int
+}
+
+func (c color) String() string {
+	return "color " + c.name
+}
+
+type thrower struct {
+	depth int
+}
+
+func (t *thrower) throw(v interface{}) {
+	if t.depth > 0 {
+		t.depth--
+		t.throw(v)
+		return
+	}
+	panic(v)
+}
+
+func value(name string) interface{} {
+	switch name {
+	case "string":
+		return "boom"
+	case "int":
+		return 42
+	case "negative":
+		return -7
+	case "bool":
+		return true
+	case "byte":
+		var b uint8 = 200
+		return b
+	case "named-int":
+		return myInt(-3)
+	case "named-string":
+		return myString("hi")
+	case "error":
+		return &pathError{path: "/nowhere"}
+	case "stringer":
+		return color{name: "red", rgb: 16711680}
+	case "struct":
+		return point{x: 1, y: 2}
+	case "pointer":
+		return &point{x: 1, y: 2}
+	}
+	return nil
+}
+
+func main() {
+	t := &thrower{depth: 2}
+	t.throw(value(os.Args[1]))
+}
@@ -0,0 +1,6 @@
+// Package greet is in a directory whose name is not the package name.
+package greet
+
+func Hello(name string) string {
+	return "hello, " + name
+}
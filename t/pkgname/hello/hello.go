@@ -0,0 +1,7 @@
+package hello
+
+import "example.com/pkgname/go-greet"
+
+func World() string {
+	return greet.Hello("world")
+}
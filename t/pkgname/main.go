@@ -0,0 +1,13 @@
+package main
+
+import (
+	"os"
+
+	"example.com/pkgname/go-greet"
+	"example.com/pkgname/hello"
+)
+
+func main() {
+	os.Stdout.Write([]byte(greet.Hello("gopher") + "\n"))
+	os.Stdout.Write([]byte(hello.World() + "\n"))
+}
@@ -0,0 +1,57 @@
+//go:build babygo
+
+// Signals sent to the process itself are delivered to the functions registered by signal.Notify.
+// This program uses the callback form of Notify, so it is built by babygo only.
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/DQNEO/babygo/lib/fmt"
+)
+
+var count int
+
+func onSignal(sig os.Signal) {
+	count++
+	s := sig.(syscall.Signal)
+	fmt.Printf("received %s\n", s.String())
+}
+
+func onTerm(sig os.Signal) {
+	fmt.Printf("terminating\n")
+}
+
+func kill(sig syscall.Signal) {
+	syscall.Kill(syscall.Getpid(), sig)
+}
+
+func main() {
+	signal.Notify(onSignal, os.Interrupt, syscall.SIGUSR1)
+	kill(syscall.SIGINT)
+	kill(syscall.SIGUSR1)
+
+	// a blocked signal is delivered when it is unblocked
+	var set syscall.Sigset_t
+	set.Add(syscall.SIGUSR1)
+	syscall.RtSigprocmask(syscall.SIG_BLOCK, &set, nil)
+	kill(syscall.SIGUSR1)
+	fmt.Printf("blocked, count = %d\n", count)
+	syscall.RtSigprocmask(syscall.SIG_UNBLOCK, &set, nil)
+	fmt.Printf("unblocked, count = %d\n", count)
+
+	signal.Ignore(syscall.SIGUSR1)
+	kill(syscall.SIGUSR1)
+	fmt.Printf("ignored, count = %d\n", count)
+
+	signal.Notify(onTerm, syscall.SIGTERM)
+	kill(syscall.SIGTERM)
+	signal.Stop(onSignal)
+	kill(syscall.SIGTERM)
+
+	// the default action of SIGINT terminates the process
+	kill(syscall.SIGINT)
+	fmt.Printf("not reached\n")
+}
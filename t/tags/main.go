@@ -0,0 +1,14 @@
+package main
+
+import (
+	"os"
+
+	"example.com/tags/header"
+)
+
+func main() {
+	os.Stdout.Write([]byte(header.Name() + "\n"))
+	os.Stdout.Write([]byte(header.InBlock() + "\n"))
+	os.Stdout.Write([]byte(header.PlusNoBlank() + "\n"))
+	os.Stdout.Write([]byte(header.PlusAfterBlock() + "\n"))
+}
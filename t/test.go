@@ -1,15 +1,21 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"io"
 	"os"
+	"os/exec"
 	"reflect"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"github.com/DQNEO/babygo/lib/token"
 
 	"github.com/DQNEO/babygo/lib/fmt"
 	"github.com/DQNEO/babygo/lib/mylib"
+	"github.com/DQNEO/babygo/lib/mylib2"
 	"github.com/DQNEO/babygo/lib/mymap"
 	"github.com/DQNEO/babygo/lib/path"
 	"github.com/DQNEO/babygo/lib/strconv"
@@ -478,6 +484,698 @@ func testAnotherFile() {
 	anotherFunc()
 }
 
+const cfWidth int = 8
+const cfHeight = cfWidth * 3
+const cfGreeting = "hello, " + "const"
+const cfDebug = false
+const cfLarge = 1000000 * 1000000
+const cfLetter uint8 = 'a' + 2
+
+var cfCells [cfWidth + 2]int
+var cfArea int = cfWidth * cfHeight
+
+func cfTrace(s string) {
+	fmt.Printf("trace %s\n", s)
+}
+
+type escNode struct {
+	val  int
+	flag uint8
+	next *escNode
+}
+
+var escKept *escNode
+
+func escNew(v int) *escNode {
+	return &escNode{val: v}
+}
+
+func testStringEscapes() {
+	s := "a\\b"
+	t := "x\\"
+	u := "\"\\\""
+	fmt.Printf("%d %s %d %s %d %s\n", len(s), s, len(t), t, len(u), u)
+	fmt.Printf("%q %v\n", "tab\t\\", []uint8("\\\n"))
+}
+
+// chunkReader returns its data at most n bytes per Read.
+type chunkReader struct {
+	data []byte
+	n    int
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	n := r.n
+	if n > len(r.data) {
+		n = len(r.data)
+	}
+	if n > len(p) {
+		n = len(p)
+	}
+	for i := 0; i < n; i++ {
+		p[i] = r.data[i]
+	}
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// countWriter collects what is written to it and counts the calls to Write.
+type countWriter struct {
+	buf   []byte
+	calls int
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.calls++
+	for _, c := range p {
+		w.buf = append(w.buf, c)
+	}
+	return len(p), nil
+}
+
+func testBufio() {
+	text := "first line\nsecond  line\r\n\nlast"
+	br := bufio.NewReader(&chunkReader{data: []byte(text), n: 3})
+	for {
+		line, err := br.ReadString('\n')
+		fmt.Printf("ReadString %d [%s]\n", len(line), line)
+		if err != nil {
+			fmt.Printf("%s\n", err.Error())
+			break
+		}
+	}
+
+	br = bufio.NewReaderSize(&chunkReader{data: []byte("abcdef"), n: 4}, 16)
+	b, _ := br.ReadByte()
+	br.UnreadByte()
+	peek, _ := br.Peek(3)
+	fmt.Printf("ReadByte %s Peek %s Buffered %d\n", string([]byte{b}), string(peek), br.Buffered())
+	p := make([]byte, 10, 10)
+	n, _ := br.Read(p)
+	fmt.Printf("Read %d [%s]\n", n, string(p[0:n]))
+
+	sc := bufio.NewScanner(&chunkReader{data: []byte(text), n: 5})
+	for sc.Scan() {
+		fmt.Printf("line [%s]\n", sc.Text())
+	}
+	sc = bufio.NewScanner(&chunkReader{data: []byte(text), n: 2})
+	sc.Split(bufio.ScanWords)
+	var words int
+	for sc.Scan() {
+		words++
+		fmt.Printf("word %d [%s]\n", words, sc.Text())
+	}
+	if sc.Err() == nil {
+		fmt.Printf("no error\n")
+	}
+	sc = bufio.NewScanner(&chunkReader{data: []byte("0123456789abcdef\n"), n: 16})
+	sc.Buffer(make([]byte, 0, 8), 8)
+	for sc.Scan() {
+	}
+	fmt.Printf("%s\n", sc.Err().Error())
+
+	cw := &countWriter{}
+	bw := bufio.NewWriterSize(cw, 16)
+	for i := 0; i < 10; i++ {
+		bw.WriteString("abc")
+	}
+	bw.WriteByte('!')
+	fmt.Printf("calls %d Buffered %d Available %d\n", cw.calls, bw.Buffered(), bw.Available())
+	bw.Write([]byte("0123456789012345678901234567890123456789"))
+	bw.Flush()
+	fmt.Printf("calls %d [%s]\n", cw.calls, string(cw.buf))
+}
+
+func lookupEnv(key string) {
+	v, ok := os.LookupEnv(key)
+	if ok {
+		fmt.Printf("%s=[%s]\n", key, v)
+	} else {
+		fmt.Printf("%s unset\n", key)
+	}
+}
+
+func testSetenv() {
+	lookupEnv("FOO")
+	lookupEnv("BBG_VAR")
+	os.Setenv("BBG_VAR", "one")
+	os.Setenv("BBG_VAR", "two")
+	os.Setenv("BBG_EMPTY", "")
+	lookupEnv("BBG_VAR")
+	lookupEnv("BBG_EMPTY")
+	var n int
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "BBG_") {
+			fmt.Printf("%s\n", kv)
+			n++
+		}
+	}
+	fmt.Printf("%d in environ\n", n)
+	out, _ := exec.Command("sh", "-c", "echo \"child: $BBG_VAR\"").Output()
+	fmt.Printf("%s", string(out))
+
+	err := os.Setenv("BBG=VAR", "x")
+	fmt.Printf("%s\n", err.Error())
+
+	os.Unsetenv("BBG_VAR")
+	lookupEnv("BBG_VAR")
+	fmt.Printf("[%s]\n", os.Getenv("BBG_VAR"))
+	out, _ = exec.Command("sh", "-c", "echo \"child: ${BBG_VAR-unset}\"").Output()
+	fmt.Printf("%s", string(out))
+	os.Unsetenv("BBG_EMPTY")
+}
+
+var fired int
+
+func onTimer() {
+	fired++
+}
+
+func testTime() {
+	ds := []time.Duration{0, 1, 999, 1500 * time.Nanosecond, 2 * time.Millisecond, 1234567 * time.Microsecond,
+		90 * time.Second, 3*time.Hour + 25*time.Minute, -time.Second - 500*time.Millisecond}
+	for _, d := range ds {
+		fmt.Printf("%s ", d.String())
+	}
+	fmt.Printf("\n")
+	d := 1499 * time.Millisecond
+	fmt.Printf("%s %s %d\n", d.Round(time.Second).String(), d.Truncate(time.Second).String(), int(d.Milliseconds()))
+
+	t0 := time.Unix(100, 999999999)
+	t1 := t0.Add(2 * time.Nanosecond)
+	fmt.Printf("%d %d %s\n", int(t1.Unix()), t1.Nanosecond(), t1.Sub(t0).String())
+
+	start := time.Now()
+	tm := time.AfterFunc(10*time.Millisecond, onTimer)
+	stopped := time.AfterFunc(20*time.Millisecond, onTimer)
+	if stopped.Stop() {
+		fmt.Printf("stopped\n")
+	}
+	time.Sleep(50 * time.Millisecond)
+	elapsed := time.Since(start)
+	fmt.Printf("fired %d\n", fired)
+	if tm.Stop() {
+		fmt.Printf("stopped after firing\n")
+	}
+	if elapsed >= 50*time.Millisecond && elapsed < 5*time.Second {
+		fmt.Printf("slept\n")
+	}
+	now := time.Now()
+	if now.After(start) && start.Before(now) && !now.Equal(start) {
+		fmt.Printf("monotonic\n")
+	}
+}
+
+func nilness(s []string) string {
+	if s == nil {
+		return "nil"
+	}
+	return "non-nil"
+}
+
+func testSliceNil() {
+	var s []string
+	fmt.Printf("%s %s\n", nilness(s), nilness(nil))
+	if nil == s {
+		fmt.Printf("nil == s\n")
+	}
+	s = append(s, "a")
+	fmt.Printf("%s %s\n", nilness(s), nilness(s[0:0]))
+	if s != nil {
+		fmt.Printf("s != nil\n")
+	}
+	var e []string = []string{}
+	fmt.Printf("%s\n", nilness(e))
+
+	h := &sliceHolder{}
+	fmt.Printf("%s ", nilness(h.items))
+	h.items = make([]string, 0, 4)
+	fmt.Printf("%s\n", nilness(h.items))
+	var list [][]byte
+	for i := 0; i < 30; i++ {
+		var b []byte
+		if i%3 != 0 {
+			b = []byte("x")
+		}
+		list = append(list, b)
+	}
+	fmt.Printf("%d\n", countNonNil(list))
+}
+
+type sliceHolder struct {
+	items []string
+}
+
+func countNonNil(list [][]byte) int {
+	var n int
+	for _, b := range list {
+		if b != nil {
+			n++
+		}
+	}
+	return n
+}
+
+type pair struct {
+	a int
+	b string
+}
+
+func makePair(a int, b string) pair {
+	return pair{a: a, b: b}
+}
+
+func (p pair) swap() pair {
+	return makePair(len(p.b), strconv.Itoa(p.a))
+}
+
+func makeTriple(n int) [3]int {
+	var a [3]int
+	for i := 0; i < 3; i++ {
+		a[i] = n * (i + 1)
+	}
+	return a
+}
+
+func sumTriple(a [3]int) int {
+	return a[0] + a[1] + a[2]
+}
+
+func testReturnStruct() {
+	p := makePair(1, "one")
+	q := makePair(2, "two")
+	fmt.Printf("%d %s %d %s\n", p.a, p.b, q.a, q.b)
+	fmt.Printf("%s\n", makePair(3, "three").b)
+	r := makePair(45, "four").swap()
+	fmt.Printf("%d %s %s\n", r.a, r.b, makePair(6, "six").swap().b)
+
+	t := makeTriple(1)
+	u := makeTriple(10)
+	fmt.Printf("%d %d %d %d %d\n", t[0], t[2], u[1], makeTriple(100)[2], sumTriple(makeTriple(1000)))
+}
+
+// testForeignConst uses a string constant of another package that is referred to nowhere else,
+// so that its literal has to be registered when the selector is walked.
+func testForeignConst() {
+	null := os.DevNull
+	fmt.Printf("%s %s %d\n", os.DevNull, null, len(os.DevNull))
+}
+
+func testFilesystem() {
+	base := "/tmp/bbgfs"
+	os.RemoveAll(base)
+	err := os.MkdirAll(base+"/a/b", 493)
+	if err == nil {
+		err = os.MkdirAll(base+"/a/b/", 493)
+	}
+	if err == nil {
+		err = os.WriteFile(base+"/a/hello.txt", []byte("hello\n"), 420)
+	}
+	if err == nil {
+		fmt.Printf("made dirs\n")
+	}
+
+	fi, err := os.Stat(base + "/a/hello.txt")
+	if err == nil && fi.Mode().IsRegular() && !fi.IsDir() && fi.ModTime().Unix() > 0 {
+		fmt.Printf("%s %d\n", fi.Name(), int(fi.Size()))
+	}
+	fi, _ = os.Lstat(base + "/a/b")
+	if fi.IsDir() && fi.Mode()&os.ModeType == os.ModeDir {
+		fmt.Printf("%s is a directory\n", fi.Name())
+	}
+	fmt.Printf("%s %s\n", (os.ModeDir | 493).String(), os.FileMode(420).String())
+
+	err = os.MkdirAll(base+"/a/hello.txt/c", 493)
+	fmt.Printf("%s\n", err.Error())
+	err = os.Rename(base+"/a/hello.txt", base+"/a/b/world.txt")
+	_, err2 := os.Stat(base + "/a/hello.txt")
+	if err == nil && os.IsNotExist(err2) {
+		fmt.Printf("renamed\n")
+	}
+	err = os.Rename(base+"/nope", base+"/x")
+	fmt.Printf("%s\n", err.Error())
+
+	os.WriteFile(base+"/a/z.txt", []byte("z"), 420)
+	os.WriteFile(base+"/a/m.txt", []byte("m"), 420)
+	entries, _ := os.ReadDir(base + "/a")
+	for _, e := range entries {
+		if e.IsDir() {
+			fmt.Printf("dir %s\n", e.Name())
+		} else {
+			info, _ := e.Info()
+			fmt.Printf("file %s %d\n", e.Name(), int(info.Size()))
+		}
+	}
+
+	err = os.Remove(base + "/a")
+	fmt.Printf("%s\n", err.Error())
+	err = os.Remove(base + "/a/z.txt")
+	if err == nil {
+		fmt.Printf("removed\n")
+	}
+
+	wd, _ := os.Getwd()
+	os.Chdir(base + "/a/b")
+	data, _ := os.ReadFile("world.txt")
+	cwd, _ := os.Getwd()
+	fmt.Printf("%s %s", cwd, string(data))
+	os.Chdir(wd)
+	err = os.Chdir(base + "/nope")
+	fmt.Printf("%s\n", err.Error())
+
+	err = os.RemoveAll(base)
+	_, err2 = os.Stat(base)
+	if err == nil && os.IsNotExist(err2) {
+		fmt.Printf("removed all\n")
+	}
+}
+
+func testReadFile() {
+	f, _ := os.Create("/tmp/bbgbig.txt")
+	line := []byte("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcd\n")
+	for i := 0; i < 40000; i++ {
+		f.Write(line)
+	}
+	f.Close()
+	data, err := os.ReadFile("/tmp/bbgbig.txt")
+	if err == nil {
+		fmt.Printf("read %d bytes: %s", len(data), string(data[len(data)-16:]))
+	}
+
+	f, _ = os.Open("t/text.txt")
+	var r io.Reader = f
+	buf := make([]byte, 10, 10)
+	n, _ := r.Read(buf)
+	fmt.Printf("%d %s\n", n, string(buf[:n]))
+	pos, _ := f.Seek(-11, io.SeekEnd)
+	all, _ := io.ReadAll(f)
+	fmt.Printf("%d %s", int(pos), string(all))
+	n, err = f.Read(buf)
+	if n == 0 && err == io.EOF {
+		fmt.Printf("EOF\n")
+	}
+	f.Close()
+
+	status, _ := os.ReadFile("/proc/self/status")
+	fmt.Printf("%s\n", string(status[:5]))
+}
+
+type shape interface {
+	area() int
+	name() string
+}
+
+type rect struct {
+	w int
+	h int
+}
+
+func (r rect) area() int {
+	return r.w * r.h
+}
+
+func (r rect) name() string {
+	return "rect"
+}
+
+type square struct {
+	side int
+}
+
+func (s *square) area() int {
+	return s.side * s.side
+}
+
+func (s *square) name() string {
+	return "square"
+}
+
+type timeoutError struct {
+	op string
+}
+
+func (e *timeoutError) Error() string {
+	return e.op + ": timeout"
+}
+
+func (e *timeoutError) Temporary() bool {
+	return true
+}
+
+type temporary interface {
+	Temporary() bool
+}
+
+// a named error interface, whose name differs from that of any dynamic type
+type opError interface {
+	Error() string
+}
+
+func testErrors() {
+	shapes := []shape{rect{w: 2, h: 3}, &square{side: 4}}
+	for _, s := range shapes {
+		fmt.Printf("%s %d\n", s.name(), s.area())
+	}
+
+	_, err := os.Open("/nonexistent/file")
+	fmt.Printf("%s\n", err.Error())
+	if os.IsNotExist(err) && !os.IsExist(err) {
+		fmt.Printf("not exist\n")
+	}
+	if errors.Is(err, os.ErrNotExist) && errors.Is(err, syscall.ENOENT) && !errors.Is(err, os.ErrPermission) {
+		fmt.Printf("is ErrNotExist\n")
+	}
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		fmt.Printf("op=%s path=%s\n", pe.Op, pe.Path)
+	}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		fmt.Printf("errno %d %s\n", int(errno), errno.Error())
+	}
+	var tErr *timeoutError
+	if !errors.As(err, &tErr) {
+		fmt.Printf("not a timeout\n")
+	}
+
+	_, err = os.ReadFile("/nonexistent/file")
+	fmt.Printf("%s\n", err.Error())
+	_, err = os.Create("/nonexistent/file")
+	fmt.Printf("%s\n", err.Error())
+	_, err = os.ReadFile("t/text.txt")
+	if err == nil {
+		fmt.Printf("read ok\n")
+	}
+
+	sentinel := errors.New("sentinel")
+	var wrapped error = &os.PathError{Op: "stat", Path: "x", Err: sentinel}
+	fmt.Printf("%s\n", wrapped.Error())
+	if errors.Unwrap(wrapped) == sentinel && errors.Unwrap(sentinel) == nil {
+		fmt.Printf("unwrapped\n")
+	}
+	if errors.Is(wrapped, sentinel) && !errors.Is(sentinel, errors.New("sentinel")) && !errors.Is(nil, sentinel) {
+		fmt.Printf("is sentinel\n")
+	}
+
+	var te error = &timeoutError{op: "dial"}
+	if errors.As(te, &tErr) {
+		fmt.Printf("as %s\n", tErr.op)
+	}
+	var target error
+	if errors.As(wrapped, &target) {
+		fmt.Printf("as error %s\n", target.Error())
+	}
+	var tmp temporary
+	var wrappedTimeout error = &os.PathError{Op: "dial", Path: "y", Err: te}
+	if errors.As(wrappedTimeout, &tmp) && !errors.As(wrapped, &tmp) {
+		fmt.Printf("as temporary %v\n", tmp.Temporary())
+	}
+	var oe opError
+	if errors.As(te, &oe) {
+		fmt.Printf("as opError %s\n", oe.Error())
+	}
+	var pe2 *os.PathError
+	if errors.As(wrappedTimeout, &pe2) && !errors.As(te, &pe2) {
+		fmt.Printf("as PathError %s\n", pe2.Path)
+	}
+	fmt.Printf("%s, %s\n", syscall.EACCES.Error(), syscall.Errno(200).Error())
+}
+
+func testEscape() {
+	var list *escNode
+	sum := 0
+	for i := 1; i <= 3; i++ {
+		tmp := &escNode{val: i * 10}
+		cnt := new(int)
+		*cnt = *cnt + tmp.val
+		sum = sum + *cnt + int(tmp.flag)
+		tmp.flag = 1
+		n := &escNode{val: i, next: list}
+		list = n
+	}
+	for n := list; n != nil; n = n.next {
+		fmt.Printf("%d ", n.val)
+	}
+	fmt.Printf("sum=%d\n", sum)
+	p := &escNode{val: 7}
+	q := p
+	q.val = q.val + 1
+	escKept = &escNode{val: 9}
+	fmt.Printf("%d %d %d\n", p.val, escKept.val, escNew(5).val)
+	pt := escNode{val: 3}
+	arr := [3]int{4, 5, 6}
+	fmt.Printf("%d %d %d\n", pt.val, arr[1], len(arr))
+}
+
+var escGlobal *int
+
+func escLocal() *int {
+	x := 5
+	return &x
+}
+
+func escParam(p escNode) *escNode {
+	return &p
+}
+
+func escStore() {
+	y := 7
+	escGlobal = &y
+}
+
+func escCount(n *int) {
+	*n = *n + 1
+}
+
+func (n *escNode) keep() {
+	escKept = n
+}
+
+func escKeep() {
+	var local escNode
+	local.val = 11
+	local.keep()
+}
+
+// escClobber reuses the stack where the frames of the functions above were
+func escClobber(a int, b int, c int) int {
+	d := a * b * c
+	return d
+}
+
+func testEscapeLocals() {
+	p := escLocal()
+	escStore()
+	q := escParam(escNode{val: 9})
+	escKeep()
+	escClobber(100, 200, 300)
+	var ps []*int
+	for i := 0; i < 3; i++ {
+		v := i * 10
+		ps = append(ps, &v)
+	}
+	count := 0
+	for i := 0; i < 3; i++ {
+		escCount(&count)
+	}
+	arr := [3]int{1, 2, 3}
+	s := arr[:]
+	s[0] = 4
+	fmt.Printf("%d %d %d %d\n", *p, *escGlobal, q.val, escKept.val)
+	fmt.Printf("%d %d %d %d %d\n", *ps[0], *ps[1], *ps[2], count, arr[0])
+}
+
+type inlPoint struct {
+	x int
+	y int
+}
+
+func inlAdd(a int, b int) int {
+	return a + b
+}
+
+func inlSum(p *inlPoint) int {
+	return p.x + p.y*2
+}
+
+func inlFirst(s string) uint8 {
+	return s[0]
+}
+
+func inlIsZero(p *inlPoint) bool {
+	return p == nil || (p.x == 0 && p.y == 0)
+}
+
+//go:noinline
+func inlNoinline(x int) int {
+	return x * 2
+}
+
+func inlNext(p *int) int {
+	*p = *p + 1
+	return *p
+}
+
+func testInline() {
+	fmt.Printf("%d %d\n", inlAdd(inlAdd(1, 2), 3), mylib.Sum(10, 20))
+	n := 0
+	fmt.Printf("%d %d\n", inlAdd(inlNext(&n)*10, inlNext(&n)), n)
+	p := &inlPoint{x: 1, y: 2}
+	fmt.Printf("%d %d\n", inlSum(p), int(inlFirst("xyz")))
+	if !inlIsZero(p) && inlIsZero(nil) {
+		fmt.Printf("not zero\n")
+	}
+	fmt.Printf("%d\n", inlNoinline(21))
+	inlAdd(1, 2)
+}
+
+func testConstFolding() {
+	fmt.Printf("%d %d %d\n", cfHeight, cfArea, (cfWidth+1)*-2)
+	fmt.Printf("%s %d\n", cfGreeting, len(cfGreeting))
+	fmt.Printf("%d %d %d\n", len(cfCells), cfLarge, int(cfLetter))
+	if cfDebug {
+		cfTrace("unreachable")
+	}
+	if !cfDebug && cfHeight > cfWidth {
+		fmt.Printf("folded condition\n")
+	} else {
+		fmt.Printf("unreachable\n")
+	}
+	var large int = cfLarge / 1000
+	fmt.Printf("%d\n", large+1)
+	for i := 0; i < 3; i++ {
+		if i == 1 {
+			continue
+		}
+		fmt.Printf("i=%d\n", i)
+	}
+}
+
+func testCrossPackageDtype() {
+	for i := 0; i < 2; i++ {
+		x := mylib.AsInterface(i)
+		switch v := x.(type) {
+		case string:
+			fmt.Printf("string %s\n", v)
+		case *mylib.Type:
+			fmt.Printf("*mylib.Type %d\n", v.Field)
+		default:
+			fmt.Printf("unknown\n")
+		}
+		_, ok := x.(string)
+		if ok {
+			fmt.Printf("is string\n")
+		}
+	}
+}
+
+func testBuildConstraints() {
+	fmt.Printf("%s %d\n", mylib2.Platform(), mylib2.Sum3(1, 2, 3))
+}
+
 func testSortStrings() {
 	ss := []string{
 		// sample strings
@@ -587,6 +1285,10 @@ func testStrings() {
 	} else {
 		panic("ERROR")
 	}
+
+	// Index
+	fmt.Printf("%d %d %d %d %d\n", strings.Index("/* a */ b */", "*/"), strings.Index("aab", "ab"),
+		strings.Index("foo", "oox"), strings.Index("foo", ""), strings.Index("", "a"))
 }
 
 func XXX() {
@@ -2367,6 +3069,22 @@ func testMisc() {
 }
 
 func main() {
+	testStringEscapes()
+	testBufio()
+	testSetenv()
+	testTime()
+	testSliceNil()
+	testReturnStruct()
+	testForeignConst()
+	testFilesystem()
+	testReadFile()
+	testErrors()
+	testEscape()
+	testEscapeLocals()
+	testInline()
+	testConstFolding()
+	testCrossPackageDtype()
+	testBuildConstraints()
 	testBlankAssign()
 	testBitWiseAnd()
 	testBitWiseOr()
@@ -0,0 +1,87 @@
+//go:build babygo
+
+// Timers and tickers call functions from the handler of SIGALRM, also while the program sleeps.
+// Tickers take a function instead of a channel, so this program is built by babygo only.
+package main
+
+import (
+	"time"
+
+	"github.com/DQNEO/babygo/lib/fmt"
+)
+
+var order []int
+
+func first() {
+	order = append(order, 1)
+}
+
+func second() {
+	order = append(order, 2)
+}
+
+func third() {
+	order = append(order, 3)
+}
+
+var ticks int
+var lastTick time.Time
+
+func onTick(t time.Time) {
+	ticks++
+	lastTick = t
+}
+
+var again *time.Timer
+var rounds int
+
+func onAgain() {
+	rounds++
+	if rounds < 3 {
+		again.Reset(5 * time.Millisecond)
+	}
+}
+
+func main() {
+	start := time.Now()
+	time.AfterFunc(30*time.Millisecond, third)
+	time.AfterFunc(10*time.Millisecond, first)
+	time.AfterFunc(20*time.Millisecond, second)
+	time.Sleep(60 * time.Millisecond)
+	for _, n := range order {
+		fmt.Printf("%d ", n)
+	}
+	fmt.Printf("\n")
+
+	// a timer can be reset by its own function
+	again = time.AfterFunc(5*time.Millisecond, onAgain)
+	time.Sleep(50 * time.Millisecond)
+	fmt.Printf("rounds %d\n", rounds)
+
+	tk := time.NewTicker(10*time.Millisecond, onTick)
+	time.Sleep(105 * time.Millisecond)
+	tk.Stop()
+	n := ticks
+	if n >= 5 && n <= 11 {
+		fmt.Printf("ticked\n")
+	} else {
+		fmt.Printf("ticks %d\n", n)
+	}
+	if lastTick.After(start) {
+		fmt.Printf("tick time ok\n")
+	}
+	time.Sleep(30 * time.Millisecond)
+	fmt.Printf("ticks after stop %d\n", ticks-n)
+
+	tk.Reset(5 * time.Millisecond)
+	time.Sleep(52 * time.Millisecond)
+	tk.Stop()
+	if ticks-n >= 5 {
+		fmt.Printf("reset\n")
+	}
+
+	elapsed := time.Since(start)
+	if elapsed >= 297*time.Millisecond {
+		fmt.Printf("slept\n")
+	}
+}